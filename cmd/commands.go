@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"predictionbot/internal/storage"
+)
+
+// printUsage lists the available subcommands
+func printUsage() {
+	fmt.Fprintf(os.Stderr, `Usage: predictionbot [command] [flags]
+
+Commands:
+  serve          Run the bot, market worker and web server (default)
+  migrate        Create or upgrade the database schema and exit (--status, --down)
+  backup         Write a consistent copy of the database to --out
+  export         Dump a table as CSV or JSON (--table, --format, --out)
+  create-admin   Grant the admin role to a registered user (--telegram-id)
+
+Every command accepts --db to choose the database file or postgres:// URL.
+Run "predictionbot <command> -h" for command flags.
+`)
+}
+
+// addDBFlag registers the shared --db flag on a subcommand
+func addDBFlag(fs *flag.FlagSet) *string {
+	return fs.String("db", "", "path to the SQLite database file or a postgres:// URL (overrides DATABASE_URL and DATABASE_PATH)")
+}
+
+// openDB resolves the database (--db flag, then DATABASE_URL for PostgreSQL, then DATABASE_PATH,
+// then a per-platform default SQLite file) and initializes storage, which also runs migrations
+func openDB(dbFlag string) error {
+	dbPath := dbFlag
+	if dbPath == "" {
+		dbPath = os.Getenv("DATABASE_URL")
+	}
+	if dbPath == "" {
+		dbPath = os.Getenv("DATABASE_PATH")
+	}
+	if dbPath == "" {
+		dbPath = storage.DefaultDBPath()
+	}
+	if storage.IsPostgresURL(dbPath) {
+		location := "(invalid URL)"
+		if u, err := url.Parse(dbPath); err == nil {
+			location = u.Redacted()
+		}
+		log.Printf("Initializing PostgreSQL database at: %s", location)
+	} else {
+		log.Printf("Initializing database at: %s", dbPath)
+	}
+	if err := storage.InitDB(dbPath); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	return nil
+}
+
+// runMigrate applies schema migrations and exits; --status lists them and --down reverts
+// them to an earlier version
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dbFlag := addDBFlag(fs)
+	status := fs.Bool("status", false, "list the migrations and when each was applied")
+	down := fs.Int("down", -1, "revert migrations newer than this version (0 reverts everything and deletes all data)")
+	fs.Parse(args)
+
+	if err := openDB(*dbFlag); err != nil {
+		return err
+	}
+	defer storage.CloseDB()
+
+	ctx := context.Background()
+	if *down >= 0 {
+		if err := storage.MigrateDown(ctx, *down); err != nil {
+			return err
+		}
+		log.Printf("Database schema reverted to version %d", *down)
+		return nil
+	}
+
+	if *status {
+		statuses, err := storage.MigrationStatuses(ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range statuses {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = "applied " + m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", m.Version, m.Name, applied)
+		}
+		return nil
+	}
+
+	version, err := storage.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	log.Printf("Database schema is up to date (version %d)", version)
+	return nil
+}
+
+// runBackup copies the live database to a new file
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dbFlag := addDBFlag(fs)
+	out := fs.String("out", "", "destination file for the backup (must not exist)")
+	fs.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("--out is required")
+	}
+
+	if err := openDB(*dbFlag); err != nil {
+		return err
+	}
+	defer storage.CloseDB()
+
+	if err := storage.BackupDB(context.Background(), *out); err != nil {
+		return err
+	}
+	log.Printf("Backup written to %s", *out)
+	return nil
+}
+
+// runExport dumps one table to stdout or a file
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dbFlag := addDBFlag(fs)
+	table := fs.String("table", "", "table to export: "+strings.Join(storage.ExportableTables, ", "))
+	format := fs.String("format", "csv", "output format: csv or json")
+	out := fs.String("out", "", "output file (default stdout)")
+	fs.Parse(args)
+
+	if *table == "" {
+		return fmt.Errorf("--table is required")
+	}
+
+	if err := openDB(*dbFlag); err != nil {
+		return err
+	}
+	defer storage.CloseDB()
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return storage.ExportTable(context.Background(), w, *table, *format)
+}
+
+// runCreateAdmin grants the admin role to an existing user
+func runCreateAdmin(args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	dbFlag := addDBFlag(fs)
+	telegramID := fs.Int64("telegram-id", 0, "Telegram ID of the user to promote")
+	fs.Parse(args)
+
+	if *telegramID == 0 {
+		return fmt.Errorf("--telegram-id is required")
+	}
+
+	if err := openDB(*dbFlag); err != nil {
+		return err
+	}
+	defer storage.CloseDB()
+
+	user, err := storage.GetUserByTelegramID(*telegramID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user with telegram_id %d not found: they must /start the bot first", *telegramID)
+	}
+
+	// granted_by 0 marks grants made outside the bot, like bootstrap admins
+	if err := storage.GrantRole(user.ID, storage.RoleAdmin, 0); err != nil {
+		return err
+	}
+	log.Printf("Granted admin to telegram_id %d", *telegramID)
+	return nil
+}
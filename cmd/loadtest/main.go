@@ -0,0 +1,292 @@
+// Command loadtest simulates many Telegram users placing bets on a few hot markets
+// against a running instance and reports throughput, latency and SQLITE_BUSY rates.
+//
+// It signs initData with the instance's bot token, so only run it against a server
+// you operate (ideally a staging copy; simulated users get real accounts).
+//
+//	go run ./cmd/loadtest -url http://localhost:8080 -users 50 -markets 2 -duration 30s
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type config struct {
+	baseURL   string
+	botToken  string
+	users     int
+	markets   int
+	marketIDs []int64
+	duration  time.Duration
+	amount    int64
+	baseID    int64
+}
+
+// result is the outcome of a single bet request
+type result struct {
+	status  int
+	busy    bool
+	err     error
+	latency time.Duration
+}
+
+func main() {
+	cfg := config{}
+	marketList := ""
+	flag.StringVar(&cfg.baseURL, "url", "http://localhost:8080", "base URL of the running instance")
+	flag.StringVar(&cfg.botToken, "token", os.Getenv("TELEGRAM_BOT_TOKEN"), "bot token used to sign initData (default $TELEGRAM_BOT_TOKEN)")
+	flag.IntVar(&cfg.users, "users", 50, "number of concurrent simulated users")
+	flag.IntVar(&cfg.markets, "markets", 2, "number of hot markets to create when -market-ids is empty")
+	flag.StringVar(&marketList, "market-ids", "", "comma-separated existing market IDs to bet on instead of creating markets")
+	flag.DurationVar(&cfg.duration, "duration", 30*time.Second, "how long to keep placing bets")
+	flag.Int64Var(&cfg.amount, "amount", 1, "WSC per bet")
+	flag.Int64Var(&cfg.baseID, "base-id", 900000000, "first simulated Telegram ID")
+	flag.Parse()
+
+	if cfg.botToken == "" {
+		log.Fatal("a bot token is required (-token or TELEGRAM_BOT_TOKEN)")
+	}
+	if cfg.users <= 0 || cfg.amount <= 0 {
+		log.Fatal("-users and -amount must be positive")
+	}
+	for _, part := range strings.Split(marketList, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			log.Fatalf("invalid market ID %q", part)
+		}
+		cfg.marketIDs = append(cfg.marketIDs, id)
+	}
+	cfg.baseURL = strings.TrimRight(cfg.baseURL, "/")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	// Register every simulated user up front so registration is not part of the measurement
+	initData := make([]string, cfg.users)
+	for i := range initData {
+		initData[i] = signInitData(cfg.botToken, cfg.baseID+int64(i))
+		if _, _, err := doRequest(client, http.MethodGet, cfg.baseURL+"/api/me", initData[i], nil); err != nil {
+			log.Fatalf("failed to register user %d: %v", i, err)
+		}
+	}
+
+	if len(cfg.marketIDs) == 0 {
+		for i := 0; i < cfg.markets; i++ {
+			id, err := createMarket(client, cfg.baseURL, initData[0], i)
+			if err != nil {
+				log.Fatalf("failed to create market: %v", err)
+			}
+			cfg.marketIDs = append(cfg.marketIDs, id)
+		}
+	}
+	if len(cfg.marketIDs) == 0 {
+		log.Fatal("no markets to bet on")
+	}
+
+	log.Printf("Placing bets with %d users on markets %v for %s", cfg.users, cfg.marketIDs, cfg.duration)
+
+	results := make(chan result, cfg.users*4)
+	deadline := time.Now().Add(cfg.duration)
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.users; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(i)))
+			for time.Now().Before(deadline) {
+				outcome := "YES"
+				if rng.Intn(2) == 0 {
+					outcome = "NO"
+				}
+				body, _ := json.Marshal(map[string]interface{}{
+					"market_id": cfg.marketIDs[rng.Intn(len(cfg.marketIDs))],
+					"outcome":   outcome,
+					"amount":    cfg.amount,
+				})
+
+				start := time.Now()
+				status, respBody, err := doRequest(client, http.MethodPost, cfg.baseURL+"/api/bets", initData[i], body)
+				results <- result{
+					status:  status,
+					busy:    status == http.StatusServiceUnavailable || isBusyMessage(respBody),
+					err:     err,
+					latency: time.Since(start),
+				}
+			}
+		}(i)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	report(collect(results), cfg.duration)
+}
+
+// signInitData builds initData for a fake user, signed the way Telegram signs Web App data
+func signInitData(botToken string, telegramID int64) string {
+	values := map[string]string{
+		"auth_date": strconv.FormatInt(time.Now().Unix(), 10),
+		"query_id":  fmt.Sprintf("loadtest-%d", telegramID),
+		"user":      fmt.Sprintf(`{"id":%d,"first_name":"Load %d","username":"load%d"}`, telegramID, telegramID, telegramID),
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, k+"="+values[k])
+	}
+
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(strings.TrimSpace(botToken)))
+	h := hmac.New(sha256.New, secret.Sum(nil))
+	h.Write([]byte(strings.Join(lines, "\n")))
+
+	query := url.Values{}
+	for k, v := range values {
+		query.Set(k, v)
+	}
+	query.Set("hash", hex.EncodeToString(h.Sum(nil)))
+	return query.Encode()
+}
+
+// createMarket opens a market far enough in the future to outlive the test
+func createMarket(client *http.Client, baseURL, initData string, n int) (int64, error) {
+	body, _ := json.Marshal(map[string]string{
+		"question":   fmt.Sprintf("Load test market %d started at %s?", n+1, time.Now().Format("15:04:05")),
+		"expires_at": time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339),
+	})
+	status, respBody, err := doRequest(client, http.MethodPost, baseURL+"/api/markets", initData, body)
+	if err != nil {
+		return 0, err
+	}
+	if status != http.StatusCreated {
+		return 0, fmt.Errorf("unexpected status %d: %s", status, strings.TrimSpace(string(respBody)))
+	}
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &created); err != nil {
+		return 0, fmt.Errorf("failed to decode market: %w", err)
+	}
+	return created.ID, nil
+}
+
+// doRequest sends an authenticated request and returns the status and body
+func doRequest(client *http.Client, method, target, initData string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("X-Telegram-Init-Data", initData)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, respBody, err
+}
+
+// isBusyMessage catches lock errors from servers that still report them as a 500
+func isBusyMessage(body []byte) bool {
+	msg := string(body)
+	return strings.Contains(msg, "SQLITE_BUSY") || strings.Contains(msg, "database is locked") || strings.Contains(msg, "Database busy")
+}
+
+// summary aggregates the results of a run
+type summary struct {
+	total     int
+	succeeded int
+	busy      int
+	transport int
+	byStatus  map[int]int
+	latencies []time.Duration
+}
+
+func collect(results <-chan result) summary {
+	s := summary{byStatus: make(map[int]int)}
+	for r := range results {
+		s.total++
+		if r.err != nil {
+			s.transport++
+			continue
+		}
+		s.byStatus[r.status]++
+		if r.status == http.StatusCreated {
+			s.succeeded++
+		}
+		if r.busy {
+			s.busy++
+		}
+		s.latencies = append(s.latencies, r.latency)
+	}
+	return s
+}
+
+func report(s summary, duration time.Duration) {
+	seconds := duration.Seconds()
+	fmt.Println()
+	fmt.Println("=== Bet throughput ===")
+	fmt.Printf("Requests:        %d (%.1f req/s)\n", s.total, float64(s.total)/seconds)
+	fmt.Printf("Bets placed:     %d (%.1f bets/s)\n", s.succeeded, float64(s.succeeded)/seconds)
+	fmt.Printf("SQLITE_BUSY:     %d (%.2f%%)\n", s.busy, percent(s.busy, s.total))
+	fmt.Printf("Transport errors: %d\n", s.transport)
+
+	statuses := make([]int, 0, len(s.byStatus))
+	for status := range s.byStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		fmt.Printf("  HTTP %d: %d\n", status, s.byStatus[status])
+	}
+
+	if len(s.latencies) > 0 {
+		sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+		fmt.Printf("Latency p50/p95/p99: %s / %s / %s\n",
+			quantile(s.latencies, 0.50), quantile(s.latencies, 0.95), quantile(s.latencies, 0.99))
+	}
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+// quantile expects sorted latencies
+func quantile(sorted []time.Duration, q float64) time.Duration {
+	idx := int(float64(len(sorted)-1) * q)
+	return sorted[idx].Round(time.Microsecond)
+}
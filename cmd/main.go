@@ -1,12 +1,15 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	_ "time/tzdata" // the runtime image has no zoneinfo; users' timezones need it
 
 	"predictionbot/internal/auth"
 	"predictionbot/internal/bot"
@@ -16,27 +19,74 @@ import (
 )
 
 func main() {
+	// The first argument selects a subcommand; flags alone (or nothing) mean "serve"
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	var err error
+	switch command {
+	case "serve":
+		err = runServe(args)
+	case "migrate":
+		err = runMigrate(args)
+	case "backup":
+		err = runBackup(args)
+	case "export":
+		err = runExport(args)
+	case "create-admin":
+		err = runCreateAdmin(args)
+	case "help":
+		printUsage()
+	default:
+		printUsage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", command, err)
+	}
+}
+
+// runServe starts the bot, the market worker and the HTTP server
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	dbFlag := addDBFlag(fs)
+	fs.Parse(args)
+
 	// Get port from environment or use default
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	// Initialize SQLite database
-	dbPath := os.Getenv("DATABASE_PATH")
-	if dbPath == "" {
-		dbPath = "/app/data/market.db"
-	}
-	log.Printf("Initializing database at: %s", dbPath)
-	if err := storage.InitDB(dbPath); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	if err := openDB(*dbFlag); err != nil {
+		return err
 	}
 	defer storage.CloseDB()
 
+	// Seed bootstrap admins from the environment and load the role cache
+	if err := auth.LoadRoles(); err != nil {
+		return fmt.Errorf("failed to load roles: %w", err)
+	}
+
+	// Throttle rapid betting and bound stakes (BET_COOLDOWN, DAILY_BET_CAP, MIN_BET, MAX_BET_PER_MARKET)
+	storage.SetBetLimits(service.LoadBetLimits())
+
+	// Let users cancel fresh bets (BET_CANCEL_WINDOW, BET_CANCEL_FEE_PERCENT)
+	storage.SetBetCancelPolicy(service.LoadBetCancelPolicy())
+
+	// Label amounts with the deployment's currency (CURRENCY_NAME, CURRENCY_EMOJI)
+	service.SetCurrency(service.LoadCurrency())
+
+	// Offer other DM transports in the notification settings (MATRIX_*, USER_WEBHOOKS)
+	service.RegisterConfiguredTransports()
+
 	// Start bot in a goroutine
 	go bot.StartBot()
 
 	// Initialize notification service for Telegram messages
+	var notifier service.Notifier = service.NoopNotifier{}
 	notificationService, err := service.NewNotificationService()
 	if err != nil {
 		log.Printf("Warning: Failed to initialize notification service: %v", err)
@@ -45,16 +95,31 @@ func main() {
 		log.Println("Notification service initialized")
 		// Set global notification service for use in handlers
 		service.SetNotificationService(notificationService)
+		notifier = notificationService
 	}
 
-	// Start market worker for auto-locking expired markets
-	marketWorker := service.NewMarketWorker()
+	// Start market worker for auto-locking expired markets and auto-finalization
+	marketWorker := service.NewMarketWorker(service.WithIntegrations(notifier))
 	marketWorker.Start()
 	defer marketWorker.Stop()
 
-	// Wire up notification service to market worker (for auto-finalization)
-	if notificationService != nil {
-		marketWorker.SetNotificationService(notificationService)
+	// Resolve weather template markets once their day is over (WEATHER_ORACLE)
+	if service.WeatherOracleEnabled() {
+		weatherWorker := service.NewWeatherWorker()
+		weatherWorker.Start()
+		defer weatherWorker.Stop()
+	}
+
+	// Resolve markets from their admin-approved HTTP-JSON oracle once they lock
+	httpOracleWorker := service.NewHTTPOracleWorker()
+	httpOracleWorker.Start()
+	defer httpOracleWorker.Stop()
+
+	// Queue sports fixtures for review and resolve their markets from the final score (SPORTS_API_URL)
+	if source := service.LoadFixtureSource(); source != nil {
+		sportsWorker := service.NewSportsWorker(source)
+		sportsWorker.Start()
+		defer sportsWorker.Stop()
 	}
 
 	// Set up HTTP server with auth middleware
@@ -66,16 +131,45 @@ func main() {
 	apiMux.HandleFunc("/me", handlers.HandleMe)
 	apiMux.HandleFunc("/me/bets", handlers.HandleUserBets)
 	apiMux.HandleFunc("/me/stats", handlers.HandleUserStats)
+	apiMux.HandleFunc("/me/activity", handlers.HandleUserActivity)
+	apiMux.HandleFunc("/me/transactions", handlers.HandleUserTransactions)
 	apiMux.HandleFunc("/me/bailout", handlers.HandleBailout)
+	apiMux.HandleFunc("/me/preferences", handlers.HandlePreferences)
+	apiMux.HandleFunc("/me/redeem", handlers.HandleRedeemPromo)
 	apiMux.HandleFunc("/leaderboard", handlers.HandleLeaderboard)
+	apiMux.HandleFunc("/leaderboard/", handlers.HandleLeaderboardSubpath) // Handles /api/leaderboard/me
+	apiMux.HandleFunc("/stream", handlers.HandleStream)                   // Server-Sent Events of live market updates
+	apiMux.HandleFunc("/stats/calibration", handlers.HandleCalibration)
+	apiMux.HandleFunc("/content/", handlers.HandleContent) // Handles /api/content/rules and /faq
 	apiMux.HandleFunc("/markets", handlers.HandleMarkets)
-	// Use a single handler for /markets/{id}/resolve and /markets/{id}/dispute
+	// Use a single handler for /markets/search, /markets/weather, /markets/{id} and its /resolve, /dispute, /transfer, /hide, /winners, /translations, /suggested-stakes, /webhook and /oracle subpaths
 	apiMux.HandleFunc("/markets/", handlers.HandleMarketSubpath)
-	apiMux.HandleFunc("/admin/resolve", handlers.HandleAdminResolve) // Handles /api/admin/resolve
+	apiMux.HandleFunc("/admin/resolve", handlers.HandleAdminResolve)                // Handles /api/admin/resolve
+	apiMux.HandleFunc("/admin/roles", handlers.HandleAdminRoles)                    // Handles /api/admin/roles
+	apiMux.HandleFunc("/admin/markets/", handlers.HandleAdminMarketSubpath)         // Handles /api/admin/markets/{id}/hide, /unhide, /merge and /retry-finalization
+	apiMux.HandleFunc("/admin/balance", handlers.HandleAdminBalance)                // Handles /api/admin/balance
+	apiMux.HandleFunc("/admin/vouchers", handlers.HandleAdminVouchers)              // Handles /api/admin/vouchers
+	apiMux.HandleFunc("/admin/promo-codes", handlers.HandleAdminPromoCodes)         // Handles /api/admin/promo-codes
+	apiMux.HandleFunc("/admin/activity", handlers.HandleAdminActivity)              // Handles /api/admin/activity
+	apiMux.HandleFunc("/admin/transactions", handlers.HandleAdminTransactions)      // Handles /api/admin/transactions
+	apiMux.HandleFunc("/admin/disputes", handlers.HandleAdminDisputes)              // Handles /api/admin/disputes
+	apiMux.HandleFunc("/admin/disputes/", handlers.HandleAdminDisputeSubpath)       // Handles /api/admin/disputes/{market_id}/reject
+	apiMux.HandleFunc("/admin/cosigns", handlers.HandleAdminCosigns)                // Handles /api/admin/cosigns
+	apiMux.HandleFunc("/admin/escrow", handlers.HandleAdminEscrow)                  // Handles /api/admin/escrow
+	apiMux.HandleFunc("/admin/stats", handlers.HandleAdminStats)                    // Handles /api/admin/stats
+	apiMux.HandleFunc("/admin/slow-queries", handlers.HandleAdminSlowQueries)       // Handles /api/admin/slow-queries
+	apiMux.HandleFunc("/admin/telegram-errors", handlers.HandleAdminTelegramErrors) // Handles /api/admin/telegram-errors
+	apiMux.HandleFunc("/admin/account-merges", handlers.HandleAdminAccountMerges)   // Handles /api/admin/account-merges
+	apiMux.HandleFunc("/admin/fixtures", handlers.HandleAdminFixtures)              // Handles /api/admin/fixtures
+	apiMux.HandleFunc("/admin/fixtures/", handlers.HandleAdminFixtureSubpath)       // Handles /api/admin/fixtures/{id}/approve and /reject
+	apiMux.HandleFunc("/admin/oracles", handlers.HandleAdminOracles)                // Handles /api/admin/oracles
+	apiMux.HandleFunc("/admin/oracles/", handlers.HandleAdminOracleSubpath)         // Handles /api/admin/oracles/{market_id}/approve, /reject and /preview
 	apiMux.HandleFunc("/bets", handlers.HandleBets)
+	apiMux.HandleFunc("/bets/", handlers.HandleBetSubpath) // Handles /api/bets/{id}/cancel
 
-	// Apply auth middleware to API routes (except ping for testing)
-	mux.Handle("/api/", auth.Middleware(http.StripPrefix("/api", apiMux)))
+	// Apply auth middleware to API routes (except ping for testing), counting error responses
+	// for the admin stats, authentication failures included
+	mux.Handle("/api/", handlers.CountErrors(auth.Middleware(http.StripPrefix("/api", apiMux))))
 
 	// Static file serving (web directory)
 	mux.Handle("/", http.FileServer(http.Dir("./web")))
@@ -97,4 +191,5 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -25,10 +26,18 @@ const (
 	UserIDKey ContextKey = "user_id"
 )
 
+// MaxInitDataLength caps the initData accepted from clients
+const MaxInitDataLength = 8 << 10
+
 // ValidateInitData validates the Telegram initData string
 // It checks the HMAC-SHA256 signature and the auth_date
 func ValidateInitData(initData string) (int64, error) {
 
+	// Real initData is well under 1KB; refuse anything large before parsing it
+	if len(initData) > MaxInitDataLength {
+		return 0, fmt.Errorf("initData too long")
+	}
+
 	// Parse the initData string using url.ParseQuery
 	// This automatically URL-decodes the values
 	parsedData, err := url.ParseQuery(initData)
@@ -44,7 +53,11 @@ func ValidateInitData(initData string) (int64, error) {
 		if len(values) == 0 {
 			continue
 		}
-		value := values[0] // Use first value
+		// A repeated key would let unsigned values ride along with signed ones
+		if len(values) > 1 {
+			return 0, fmt.Errorf("duplicate field %s in initData", key)
+		}
+		value := values[0]
 
 		if key == "hash" {
 			hash = value
@@ -104,7 +117,7 @@ func ValidateInitData(initData string) (int64, error) {
 	computedHash := hex.EncodeToString(h.Sum(nil))
 
 	// Compare hashes
-	if hash != computedHash {
+	if !hmac.Equal([]byte(hash), []byte(computedHash)) {
 		logger.Debug(0, "auth_invalid_hash", "hash_mismatch")
 		return 0, fmt.Errorf("invalid hash")
 	}
@@ -145,79 +158,45 @@ func ValidateInitData(initData string) (int64, error) {
 	return userID, nil
 }
 
-// extractUserID extracts the user ID from the user JSON string
-func extractUserID(userJSON string) (int64, error) {
-	// Look for "id": followed by digits
-	prefix := `"id":`
-	idx := strings.Index(userJSON, prefix)
-	if idx == -1 {
-		return 0, fmt.Errorf("id field not found")
-	}
-
-	// Find the number after "id":
-	start := idx + len(prefix)
-	var numStr string
-	for i := start; i < len(userJSON); i++ {
-		if userJSON[i] >= '0' && userJSON[i] <= '9' {
-			numStr += string(userJSON[i])
-		} else if len(numStr) > 0 {
-			break
-		}
-	}
+// telegramUser is the subset of the initData "user" object we rely on
+type telegramUser struct {
+	ID        int64  `json:"id"`
+	FirstName string `json:"first_name"`
+	Username  string `json:"username"`
+}
 
-	if len(numStr) == 0 {
-		return 0, fmt.Errorf("user id not found")
+// parseTelegramUser decodes the user JSON with a real JSON parser, so escaped quotes,
+// unicode escapes and keys hidden inside string values cannot confuse the fields
+func parseTelegramUser(userJSON string) (*telegramUser, error) {
+	var user telegramUser
+	if err := json.Unmarshal([]byte(userJSON), &user); err != nil {
+		return nil, fmt.Errorf("invalid user JSON: %w", err)
 	}
+	return &user, nil
+}
 
-	var userID int64
-	if _, err := fmt.Sscanf(numStr, "%d", &userID); err != nil {
+// extractUserID extracts the user ID from the user JSON string
+func extractUserID(userJSON string) (int64, error) {
+	user, err := parseTelegramUser(userJSON)
+	if err != nil {
 		return 0, err
 	}
-
-	return userID, nil
+	if user.ID <= 0 {
+		return 0, fmt.Errorf("user id not found")
+	}
+	return user.ID, nil
 }
 
 // extractUserInfo extracts username and first_name from the user JSON string
 func extractUserInfo(userJSON string) (username, firstName string, err error) {
-	// Extract first_name
-	firstNamePrefix := `"first_name":"`
-	idx := strings.Index(userJSON, firstNamePrefix)
-	if idx != -1 {
-		start := idx + len(firstNamePrefix)
-		var end int
-		for i := start; i < len(userJSON); i++ {
-			if userJSON[i] == '"' {
-				end = i
-				break
-			}
-		}
-		if end > start {
-			firstName = userJSON[start:end]
-		}
-	}
-
-	// Extract username (optional)
-	usernamePrefix := `"username":"`
-	idx = strings.Index(userJSON, usernamePrefix)
-	if idx != -1 {
-		start := idx + len(usernamePrefix)
-		var end int
-		for i := start; i < len(userJSON); i++ {
-			if userJSON[i] == '"' {
-				end = i
-				break
-			}
-		}
-		if end > start {
-			username = userJSON[start:end]
-		}
+	user, err := parseTelegramUser(userJSON)
+	if err != nil {
+		return "", "", err
 	}
-
-	if firstName == "" {
+	if user.FirstName == "" {
 		return "", "", fmt.Errorf("first_name not found in user JSON")
 	}
-
-	return username, firstName, nil
+	return user.Username, user.FirstName, nil
 }
 
 // GetOrCreateUser retrieves an existing user or creates a new one with welcome bonus
@@ -248,7 +227,7 @@ func GetOrCreateUser(telegramID int64, username, firstName string) (*storage.Use
 func writeJSONError(w http.ResponseWriter, statusCode int, errorMessage string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	fmt.Fprintf(w, `{"error": "%s"}`, errorMessage)
+	json.NewEncoder(w).Encode(map[string]string{"error": errorMessage})
 }
 
 // Middleware returns an HTTP middleware that validates Telegram initData
@@ -266,6 +245,12 @@ func Middleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Market image cards and QR codes are public so link previews and printed posters can fetch them
+		if strings.HasPrefix(r.URL.Path, "/api/markets/") && (strings.HasSuffix(r.URL.Path, "/card.png") || strings.HasSuffix(r.URL.Path, "/card.svg") || strings.HasSuffix(r.URL.Path, "/qr.png")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		initData := r.Header.Get("X-Telegram-Init-Data")
 		if initData == "" {
 			logger.Debug(0, "auth_missing_header", fmt.Sprintf("path=%s", r.URL.Path))
@@ -274,6 +259,15 @@ func Middleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Check the signature before trusting any field
+		userID, err := ValidateInitData(initData)
+		if err != nil {
+			logger.Debug(0, "auth_validation_failed", fmt.Sprintf("path=%s error=%v", r.URL.Path, err))
+			log.Printf("[AUTH] Validation failed for %s: %v", r.URL.Path, err)
+			writeJSONError(w, http.StatusUnauthorized, "Authentication failed: "+err.Error())
+			return
+		}
+
 		// Parse initData to get user info
 		parsedData, err := url.ParseQuery(initData)
 		if err != nil {
@@ -302,25 +296,20 @@ func Middleware(next http.Handler) http.Handler {
 			return
 		}
 
-		userID, err := ValidateInitData(initData)
-		if err != nil {
-			logger.Debug(0, "auth_validation_failed", fmt.Sprintf("path=%s error=%v", r.URL.Path, err))
-			log.Printf("[AUTH] Validation failed for %s: %v", r.URL.Path, err)
-			writeJSONError(w, http.StatusUnauthorized, "Authentication failed: "+err.Error())
-			return
-		}
-
 		logger.Debug(userID, "auth_middleware_success", fmt.Sprintf("path=%s", r.URL.Path))
 		log.Printf("[AUTH] Success: user_id=%d path=%s", userID, r.URL.Path)
 
 		// Get or create user (auto-registration with welcome bonus)
-		_, err = GetOrCreateUser(userID, username, firstName)
+		user, err := GetOrCreateUser(userID, username, firstName)
 		if err != nil {
 			logger.Debug(userID, "auth_user_failed", fmt.Sprintf("error=%v", err))
 			log.Printf("[AUTH] Failed to get/create user %d: %v", userID, err)
 			writeJSONError(w, http.StatusInternalServerError, "Failed to load user profile")
 			return
 		}
+		if err := storage.TouchLastSeen(user.ID); err != nil {
+			logger.Debug(userID, "auth_last_seen_failed", fmt.Sprintf("error=%v", err))
+		}
 
 		// Add user ID to context
 		ctx := r.Context()
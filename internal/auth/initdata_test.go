@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBotToken = "123456:TEST-TOKEN"
+
+// signTestInitData signs fields the way Telegram does and returns the query string
+func signTestInitData(fields map[string]string) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, k+"="+fields[k])
+	}
+
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(testBotToken))
+	h := hmac.New(sha256.New, secret.Sum(nil))
+	h.Write([]byte(strings.Join(lines, "\n")))
+
+	values := url.Values{}
+	for k, v := range fields {
+		values.Set(k, v)
+	}
+	values.Set("hash", hex.EncodeToString(h.Sum(nil)))
+	return values.Encode()
+}
+
+func freshFields(user string) map[string]string {
+	return map[string]string{
+		"auth_date": strconv.FormatInt(time.Now().Unix(), 10),
+		"query_id":  "AAF",
+		"user":      user,
+	}
+}
+
+func TestValidateInitData(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", testBotToken)
+
+	valid := signTestInitData(freshFields(`{"id":42,"first_name":"Ann"}`))
+	if id, err := ValidateInitData(valid); err != nil || id != 42 {
+		t.Fatalf("Expected id 42, got %d (err=%v)", id, err)
+	}
+
+	tests := []struct {
+		name     string
+		initData string
+	}{
+		{"missing hash", "auth_date=1&user=%7B%22id%22%3A1%7D"},
+		{"tampered user", strings.Replace(valid, "42", "43", 1)},
+		{"duplicate user field", valid + "&user=" + url.QueryEscape(`{"id":1,"first_name":"Eve"}`)},
+		{"oversized", valid + "&pad=" + strings.Repeat("a", MaxInitDataLength)},
+		{"expired", signTestInitData(map[string]string{"auth_date": "1", "user": `{"id":42,"first_name":"Ann"}`})},
+		{"missing user", signTestInitData(map[string]string{"auth_date": strconv.FormatInt(time.Now().Unix(), 10)})},
+		{"missing auth_date", signTestInitData(map[string]string{"user": `{"id":42,"first_name":"Ann"}`})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ValidateInitData(tt.initData); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
+
+func TestExtractUserFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		userJSON  string
+		wantID    int64
+		wantFirst string
+		wantUser  string
+		wantErr   bool
+	}{
+		{name: "plain", userJSON: `{"id":7,"first_name":"Bob","username":"bob"}`, wantID: 7, wantFirst: "Bob", wantUser: "bob"},
+		{name: "escaped quote in name", userJSON: `{"id":7,"first_name":"Bo\"b"}`, wantID: 7, wantFirst: `Bo"b`},
+		{name: "unicode escape", userJSON: `{"id":7,"first_name":"\u0418\u0432\u0430\u043d"}`, wantID: 7, wantFirst: "Иван"},
+		{name: "id inside a string is ignored", userJSON: `{"first_name":"\"id\":1","id":99}`, wantID: 99, wantFirst: `"id":1`},
+		{name: "spaced id", userJSON: `{"id": 7, "first_name": "Bob"}`, wantID: 7, wantFirst: "Bob"},
+		{name: "string id", userJSON: `{"id":"abc9","first_name":"Bob"}`, wantErr: true},
+		{name: "null id", userJSON: `{"id":null,"first_name":"Bob"}`, wantErr: true},
+		{name: "negative id", userJSON: `{"id":-5,"first_name":"Bob"}`, wantErr: true},
+		{name: "overflowing id", userJSON: `{"id":99999999999999999999,"first_name":"Bob"}`, wantErr: true},
+		{name: "missing first name", userJSON: `{"id":7}`, wantErr: true},
+		{name: "not json", userJSON: `id:7`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, idErr := extractUserID(tt.userJSON)
+			username, firstName, infoErr := extractUserInfo(tt.userJSON)
+			if tt.wantErr {
+				if idErr == nil && infoErr == nil {
+					t.Errorf("Expected an error, got id=%d first_name=%q", id, firstName)
+				}
+				return
+			}
+			if idErr != nil || infoErr != nil {
+				t.Fatalf("Unexpected errors: id=%v info=%v", idErr, infoErr)
+			}
+			if id != tt.wantID || firstName != tt.wantFirst || username != tt.wantUser {
+				t.Errorf("Got id=%d first_name=%q username=%q", id, firstName, username)
+			}
+		})
+	}
+}
+
+func FuzzValidateInitData(f *testing.F) {
+	f.Setenv("TELEGRAM_BOT_TOKEN", testBotToken)
+
+	f.Add(`{"id":42,"first_name":"Ann"}`, "AAF", false)
+	f.Add(`{"id":1,"first_name":"Bo\"b","username":"b"}`, "", true)
+	f.Add(`{"first_name":"\"id\":1","id":99}`, "x&user=1", false)
+	f.Add(strings.Repeat("9", 100), "q", true)
+
+	f.Fuzz(func(t *testing.T, user, queryID string, tamper bool) {
+		initData := signTestInitData(freshFields(user))
+		if queryID != "" {
+			fields := freshFields(user)
+			fields["query_id"] = queryID
+			initData = signTestInitData(fields)
+		}
+		if tamper {
+			initData += "&extra=1"
+		}
+
+		id, err := ValidateInitData(initData)
+		if err != nil {
+			return
+		}
+		if tamper {
+			t.Fatalf("Tampered initData accepted: %q", initData)
+		}
+		wantID, idErr := extractUserID(user)
+		if idErr != nil || wantID != id || id <= 0 {
+			t.Fatalf("Accepted id %d does not match user JSON %q (want %d, err=%v)", id, user, wantID, idErr)
+		}
+	})
+}
+
+func FuzzExtractUserID(f *testing.F) {
+	f.Add(`{"id":42,"first_name":"Ann"}`)
+	f.Add(`{"id":"7"}`)
+	f.Add(`{"first_name":"\"id\":1","id":99}`)
+	f.Add(`"id":` + strings.Repeat("1", 1000))
+
+	f.Fuzz(func(t *testing.T, userJSON string) {
+		id, err := extractUserID(userJSON)
+		if err == nil && id <= 0 {
+			t.Fatalf("Accepted non-positive id %d from %q", id, userJSON)
+		}
+	})
+}
+
+func FuzzExtractUserInfo(f *testing.F) {
+	f.Add(`{"id":42,"first_name":"Ann","username":"ann"}`)
+	f.Add(`{"first_name":"A\"nn"}`)
+	f.Add(`{"first_name":""}`)
+	f.Add(`{"username":"`)
+
+	f.Fuzz(func(t *testing.T, userJSON string) {
+		_, firstName, err := extractUserInfo(userJSON)
+		if err == nil && firstName == "" {
+			t.Fatalf("Accepted empty first_name from %q", userJSON)
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// Permission is an action that requires an elevated role
+type Permission string
+
+const (
+	// PermissionHideMarkets allows hiding and unhiding markets
+	PermissionHideMarkets Permission = "hide_markets"
+	// PermissionResolveDisputes allows forcing the outcome of disputed markets
+	PermissionResolveDisputes Permission = "resolve_disputes"
+	// PermissionAdjustBalances allows crediting or debiting user balances
+	PermissionAdjustBalances Permission = "adjust_balances"
+	// PermissionManageRoles allows granting and revoking roles
+	PermissionManageRoles Permission = "manage_roles"
+	// PermissionMergeMarkets allows merging duplicate markets
+	PermissionMergeMarkets Permission = "merge_markets"
+	// PermissionViewStats allows viewing platform-wide statistics
+	PermissionViewStats Permission = "view_stats"
+	// PermissionEditContent allows editing the house rules and FAQ
+	PermissionEditContent Permission = "edit_content"
+	// PermissionReviewFixtures allows approving sports fixtures into markets or rejecting them
+	PermissionReviewFixtures Permission = "review_fixtures"
+	// PermissionReviewOracles allows approving or rejecting the mapping of HTTP-JSON oracles
+	PermissionReviewOracles Permission = "review_oracles"
+)
+
+// rolePermissions maps each role to the permissions it grants
+var rolePermissions = map[storage.Role][]Permission{
+	storage.RoleAdmin: {
+		PermissionHideMarkets,
+		PermissionResolveDisputes,
+		PermissionAdjustBalances,
+		PermissionManageRoles,
+		PermissionMergeMarkets,
+		PermissionViewStats,
+		PermissionEditContent,
+		PermissionReviewFixtures,
+		PermissionReviewOracles,
+	},
+	storage.RoleModerator: {
+		PermissionHideMarkets,
+	},
+	storage.RoleOracle: {
+		PermissionResolveDisputes,
+	},
+}
+
+// RoleHasPermission reports whether a role grants the given permission
+func RoleHasPermission(role storage.Role, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	rolesMu     sync.RWMutex
+	rolesLoaded bool
+	// bootstrapAdmins are Telegram IDs from the environment that are always admins,
+	// so a fresh deployment has someone able to grant roles.
+	bootstrapAdmins map[int64]bool
+	// roleCache maps Telegram IDs to the roles stored in the database
+	roleCache map[int64][]storage.Role
+)
+
+// LoadRoles parses the bootstrap admins from ADMIN_USER_IDS and ADMIN_TELEGRAM_ID,
+// seeds them into the roles table and (re)loads the in-memory role cache.
+// It must be called after storage.InitDB; it is also called after every grant or revoke.
+func LoadRoles() error {
+	admins := make(map[int64]bool)
+	for _, id := range getBootstrapAdminIDs() {
+		admins[id] = true
+
+		// Seed registered bootstrap admins so they show up in role listings
+		user, err := storage.GetUserByTelegramID(id)
+		if err != nil {
+			return err
+		}
+		if user != nil {
+			if err := storage.GrantRole(user.ID, storage.RoleAdmin, 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	assignments, err := storage.ListRoleAssignments()
+	if err != nil {
+		return err
+	}
+
+	cache := make(map[int64][]storage.Role)
+	for _, a := range assignments {
+		cache[a.TelegramID] = append(cache[a.TelegramID], a.Role)
+	}
+
+	rolesMu.Lock()
+	bootstrapAdmins = admins
+	roleCache = cache
+	rolesLoaded = true
+	rolesMu.Unlock()
+
+	logger.Debug(0, "roles_loaded", fmt.Sprintf("bootstrap_admins=%d assignments=%d", len(admins), len(assignments)))
+	return nil
+}
+
+// GrantRole gives a role to a user (internal IDs) and refreshes the role cache
+func GrantRole(userID int64, role storage.Role, grantedBy int64) error {
+	if err := storage.GrantRole(userID, role, grantedBy); err != nil {
+		return err
+	}
+	return LoadRoles()
+}
+
+// RevokeRole removes a role from a user (internal IDs) and refreshes the role cache
+func RevokeRole(userID int64, role storage.Role, revokedBy int64) error {
+	if err := storage.RevokeRole(userID, role, revokedBy); err != nil {
+		return err
+	}
+	return LoadRoles()
+}
+
+// GetRoles returns the roles held by a Telegram user.
+// Bootstrap admins from the environment are always admins, even before they register.
+func GetRoles(telegramID int64) []storage.Role {
+	rolesMu.RLock()
+	loaded := rolesLoaded
+	rolesMu.RUnlock()
+	if !loaded {
+		if err := LoadRoles(); err != nil {
+			logger.Debug(telegramID, "roles_lookup_failed", fmt.Sprintf("error=%v", err))
+		}
+	}
+
+	rolesMu.RLock()
+	defer rolesMu.RUnlock()
+
+	var roles []storage.Role
+	if bootstrapAdmins[telegramID] {
+		roles = append(roles, storage.RoleAdmin)
+	}
+	return append(roles, roleCache[telegramID]...)
+}
+
+// HasPermission reports whether a Telegram user holds a role granting the permission
+func HasPermission(telegramID int64, perm Permission) bool {
+	for _, role := range GetRoles(telegramID) {
+		if RoleHasPermission(role, perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// getBootstrapAdminIDs returns the admin Telegram IDs from ADMIN_USER_IDS (comma-separated)
+// and ADMIN_TELEGRAM_ID
+func getBootstrapAdminIDs() []int64 {
+	adminIDsEnv := os.Getenv("ADMIN_USER_IDS")
+	if telegramAdmin := os.Getenv("ADMIN_TELEGRAM_ID"); telegramAdmin != "" {
+		adminIDsEnv += "," + telegramAdmin
+	}
+
+	var adminIDs []int64
+	parts := strings.Split(adminIDsEnv, ",")
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var id int64
+		if _, err := fmt.Sscanf(part, "%d", &id); err == nil {
+			adminIDs = append(adminIDs, id)
+		}
+	}
+	return adminIDs
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"testing"
+
+	"predictionbot/internal/storage"
+)
+
+func TestRoleHasPermission(t *testing.T) {
+	tests := []struct {
+		role     storage.Role
+		perm     Permission
+		expected bool
+	}{
+		{storage.RoleAdmin, PermissionAdjustBalances, true},
+		{storage.RoleAdmin, PermissionManageRoles, true},
+		{storage.RoleModerator, PermissionHideMarkets, true},
+		{storage.RoleModerator, PermissionAdjustBalances, false},
+		{storage.RoleOracle, PermissionResolveDisputes, true},
+		{storage.RoleOracle, PermissionHideMarkets, false},
+		{storage.RoleAdmin, PermissionEditContent, true},
+		{storage.RoleModerator, PermissionEditContent, false},
+		{storage.RoleAdmin, PermissionReviewFixtures, true},
+		{storage.RoleOracle, PermissionReviewFixtures, false},
+		{storage.RoleAdmin, PermissionReviewOracles, true},
+		{storage.RoleModerator, PermissionReviewOracles, false},
+	}
+
+	for _, tt := range tests {
+		if got := RoleHasPermission(tt.role, tt.perm); got != tt.expected {
+			t.Errorf("RoleHasPermission(%s, %s) = %v, want %v", tt.role, tt.perm, got, tt.expected)
+		}
+	}
+}
+
+func TestLoadRolesSeedsBootstrapAdmins(t *testing.T) {
+	if err := storage.InitTestDB(); err != nil {
+		t.Fatalf("Failed to initialize test database: %v", err)
+	}
+	defer storage.CloseDB()
+
+	admin, _ := storage.CreateUser(1001, "admin", "Admin")
+	user, _ := storage.CreateUser(1002, "user", "User")
+	t.Setenv("ADMIN_USER_IDS", "1001, 9999")
+	t.Setenv("ADMIN_TELEGRAM_ID", "")
+
+	if err := LoadRoles(); err != nil {
+		t.Fatalf("LoadRoles failed: %v", err)
+	}
+
+	// Registered bootstrap admins are seeded into the roles table
+	roles, _ := storage.GetUserRoles(admin.ID)
+	if len(roles) != 1 || roles[0] != storage.RoleAdmin {
+		t.Errorf("Expected seeded admin role, got %v", roles)
+	}
+
+	// Unregistered bootstrap admins still get admin permissions
+	if !HasPermission(9999, PermissionManageRoles) {
+		t.Error("Expected unregistered bootstrap admin to manage roles")
+	}
+
+	if HasPermission(user.TelegramID, PermissionHideMarkets) {
+		t.Error("Expected regular user to lack permissions")
+	}
+
+	// Grants are reflected in the cache immediately
+	if err := GrantRole(user.ID, storage.RoleModerator, admin.ID); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+	if !HasPermission(user.TelegramID, PermissionHideMarkets) {
+		t.Error("Expected moderator to hide markets after grant")
+	}
+
+	if err := RevokeRole(user.ID, storage.RoleModerator, admin.ID); err != nil {
+		t.Fatalf("RevokeRole failed: %v", err)
+	}
+	if HasPermission(user.TelegramID, PermissionHideMarkets) {
+		t.Error("Expected permission to be gone after revoke")
+	}
+}
@@ -2,12 +2,15 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"predictionbot/internal/auth"
 	"predictionbot/internal/logger"
 	"predictionbot/internal/service"
 	"predictionbot/internal/storage"
@@ -15,9 +18,9 @@ import (
 	"gopkg.in/telebot.v3"
 )
 
-// formatBalance formats balance as WSC
+// formatBalance labels a balance with the configured currency
 func formatBalance(balance int64) string {
-	return fmt.Sprintf("%d WSC", balance)
+	return service.FormatAmount(balance)
 }
 
 // escapeMarkdown escapes special characters for Telegram Markdown mode (legacy)
@@ -31,6 +34,9 @@ func escapeMarkdown(s string) string {
 	return escaped
 }
 
+// searchResultsLimit is how many markets /search lists
+const searchResultsLimit = 10
+
 // StartBot initializes and starts the Telegram bot
 func StartBot() {
 	// Get bot token from environment
@@ -50,6 +56,9 @@ func StartBot() {
 		log.Fatalf("Failed to create bot: %v", err)
 	}
 
+	// Every command, message and button tap counts as being active
+	b.Use(trackLastSeen)
+
 	// Register /start command handler
 	b.Handle("/start", func(c telebot.Context) error {
 		telegramID := c.Sender().ID
@@ -73,18 +82,53 @@ func StartBot() {
 				return c.Send("Error creating user. Please try again.")
 			}
 			logger.Debug(telegramID, "user_created", fmt.Sprintf("welcome_bonus=1000 user_id=%d", user.ID))
+			if err := storage.StartOnboarding(user.ID); err != nil {
+				logger.Debug(telegramID, "error", fmt.Sprintf("failed to start onboarding: %v", err))
+			}
+			if voucher, err := service.GrantFirstBetVoucher(user.ID); err != nil {
+				logger.Debug(telegramID, "error", fmt.Sprintf("failed to grant first bet voucher: %v", err))
+			} else if voucher != nil {
+				logger.Debug(telegramID, "voucher_granted", fmt.Sprintf("voucher_id=%d kind=%s max_amount=%d", voucher.ID, voucher.Kind, voucher.MaxAmount))
+			}
+		}
+
+		// Remember the Telegram client language for translated questions unless one is already set
+		if lang := service.NormalizeLanguage(c.Sender().LanguageCode); lang != "" {
+			if current, err := storage.GetUserLanguage(user.ID); err == nil && current == "" {
+				if err := storage.SetUserLanguage(user.ID, lang); err != nil {
+					logger.Debug(telegramID, "error", fmt.Sprintf("failed to save language: %v", err))
+				}
+			}
 		}
 
-		// Get the web app URL from environment or use default
-		webAppURL := os.Getenv("WEB_APP_URL")
-		if webAppURL == "" {
-			webAppURL = "http://localhost:8080"
+		// A market deep link (/start market_<id>) opens the Web App on that market
+		if marketID, ok := service.ParseMarketStartParam(c.Message().Payload); ok {
+			if market, err := storage.GetMarketByID(marketID); err == nil && market != nil && !market.Hidden {
+				logger.Debug(telegramID, "welcome_sent", fmt.Sprintf("balance=%d market_id=%d", user.Balance, marketID))
+				return c.Send(fmt.Sprintf("Welcome to the Prediction Market! 🎉\n\nHi, %s! You have %s.\n\n%s\n\nTap the button below to see the market and place your bet:",
+					user.FirstName, formatBalance(user.Balance), strings.TrimSpace(market.Icon+" "+market.Question)), &telebot.ReplyMarkup{
+					InlineKeyboard: [][]telebot.InlineButton{{{
+						Text:   "🎯 Open Market",
+						WebApp: &telebot.WebApp{URL: service.MarketWebAppURL(webAppURL(), marketID)},
+					}}},
+				})
+			}
+			logger.Debug(telegramID, "start_market_not_found", fmt.Sprintf("market_id=%d", marketID))
+		}
+
+		// New users are walked through onboarding; /start mid-way repeats the current step
+		if step, err := storage.GetOnboardingStep(user.ID); err != nil {
+			logger.Debug(telegramID, "error", fmt.Sprintf("failed to get onboarding step: %v", err))
+		} else if step != storage.OnboardingNone && step != storage.OnboardingDone {
+			logger.Debug(telegramID, "onboarding_prompt_sent", fmt.Sprintf("step=%s", step))
+			prompt := service.OnboardingPrompt(user, step)
+			return c.Send(prompt.Text, onboardingMarkup(prompt))
 		}
 
 		// Create Web App button
 		btn := telebot.InlineButton{
 			Text:   "🎯 Open Prediction Market",
-			WebApp: &telebot.WebApp{URL: webAppURL},
+			WebApp: &telebot.WebApp{URL: webAppURL()},
 		}
 
 		// Send welcome message with user info
@@ -103,21 +147,49 @@ func StartBot() {
 		telegramID := c.Sender().ID
 		logger.Debug(telegramID, "command_help", "")
 		helpText := "📚 *Available Commands*\n\n" +
-			"/start - Register and get 1000 WSC bonus\n" +
+			"/start - Register and get a " + formatBalance(storage.WelcomeBonusAmount) + " bonus\n" +
 			"/help - Show this help message\n" +
-			"/balance - Check your WSC balance\n" +
+			"/balance - Check your balance\n" +
+			"/history - Your last 10 balance movements\n" +
+			"/redeem - Redeem a promo code, e.g. /redeem LAUNCH50\n" +
 			"/me - View your profile and stats\n" +
-			"/list - View all active prediction markets\n" +
+			"/leaderboard - Top users by balance; /leaderboard week or month by profit\n" +
+			"/list - View active markets and bet on them; in a group, the group's markets\n" +
+			"/search - Find markets by keywords, e.g. /search derby\n" +
 			"/mybets - View your active bets\n" +
 			"/mymarkets - View markets you created\n" +
+			"/create - Create a market, e.g. /create friday 18:00 Will it rain tomorrow? | YES if the weather service reports rain\n" +
+			"/create (alone) - Create a market step by step; /cancel stops\n" +
+			"/create in a group - Create a market for that group only\n" +
+			"/weather - A self-resolving weather market, e.g. /weather Berlin tomorrow rain\n" +
+			"/timezone - Show or set the timezone deadlines are read in\n" +
+			"/digest - Today's summary; /digest 8 sends it every morning at 8\n" +
+			"/mute - Mute every DM except payouts and refunds; /unmute turns them back on\n" +
+			"/groupdigest - In a group: post a morning digest there, e.g. /groupdigest 8 (group admins)\n" +
 			"/resolve - Resolve a market you created (interactive)\n" +
-			"/dispute - Raise a dispute on a resolved market (interactive)\n\n" +
+			"/dispute - Raise a dispute on a resolved market (interactive)\n" +
+			"/propose - Propose the outcome of a market its creator abandoned, e.g. /propose 12 YES\n" +
+			"/rules - Read the house rules\n\n" +
 			"🎯 Open the Prediction Market web app to create markets and place bets!"
 		return c.Send(helpText, &telebot.SendOptions{
 			ParseMode: telebot.ModeMarkdown,
 		})
 	})
 
+	// Register /rules command handler; the text is edited by admins via PUT /api/content/rules
+	b.Handle("/rules", func(c telebot.Context) error {
+		telegramID := c.Sender().ID
+		logger.Debug(telegramID, "command_rules", "")
+
+		page, err := service.GetContent(service.ContentRules)
+		if err != nil {
+			logger.Debug(telegramID, "error", fmt.Sprintf("failed to get rules: %v", err))
+			return c.Send("Error retrieving the rules. Please try again.")
+		}
+		// Sent as plain text so admins don't have to escape Markdown
+		return c.Send(page.Body)
+	})
+
 	// Register /balance command handler
 	b.Handle("/balance", func(c telebot.Context) error {
 		telegramID := c.Sender().ID
@@ -143,6 +215,90 @@ func StartBot() {
 		})
 	})
 
+	// Register /history command handler: the last historySize balance movements
+	b.Handle("/history", func(c telebot.Context) error {
+		telegramID := c.Sender().ID
+		logger.Debug(telegramID, "command_history", "")
+
+		user, err := storage.GetUserByTelegramID(telegramID)
+		if err != nil {
+			logger.Debug(telegramID, "error", fmt.Sprintf("failed to get user: %v", err))
+			return c.Send("Error retrieving user data. Please try again.")
+		}
+		if user == nil {
+			return c.Send("You haven't started the bot yet. Use /start to create your account!")
+		}
+
+		transactions, total, err := storage.ListUserTransactions(user.ID, storage.TransactionFilter{Limit: historySize})
+		if err != nil {
+			logger.Debug(telegramID, "error", fmt.Sprintf("failed to get transactions: %v", err))
+			return c.Send("Error retrieving your history. Please try again.")
+		}
+		if len(transactions) == 0 {
+			return c.Send("📜 No balance movements yet.")
+		}
+
+		loc := service.UserLocation(user.ID)
+		lines := []string{fmt.Sprintf("📜 *Your Last %d Transactions*", len(transactions)), ""}
+		for _, t := range transactions {
+			amount := service.FormatMoney(t.Amount)
+			if t.Amount > 0 {
+				amount = "+" + amount
+			}
+			lines = append(lines, fmt.Sprintf("%s  *%s*  %s", t.CreatedAt.In(loc).Format("Jan 2 15:04"), amount,
+				escapeMarkdown(transactionLabel(t))))
+		}
+		lines = append(lines, "", fmt.Sprintf("Balance: %s", formatBalance(user.Balance)))
+		if total > len(transactions) {
+			lines = append(lines, fmt.Sprintf("%d older entries are in the web app.", total-len(transactions)))
+		}
+
+		logger.Debug(telegramID, "history_displayed", fmt.Sprintf("count=%d total=%d", len(transactions), total))
+		return c.Send(strings.Join(lines, "\n"), &telebot.SendOptions{
+			ParseMode: telebot.ModeMarkdown,
+		})
+	})
+
+	// Register /redeem command handler, e.g. /redeem LAUNCH50
+	b.Handle("/redeem", func(c telebot.Context) error {
+		telegramID := c.Sender().ID
+		code := strings.TrimSpace(c.Message().Payload)
+		logger.Debug(telegramID, "command_redeem", "code="+storage.NormalizePromoCode(code))
+		if code == "" {
+			return c.Send("Usage: /redeem <code>, e.g. /redeem LAUNCH50")
+		}
+
+		user, err := storage.GetUserByTelegramID(telegramID)
+		if err != nil {
+			logger.Debug(telegramID, "error", fmt.Sprintf("failed to get user: %v", err))
+			return c.Send("Error retrieving user data. Please try again.")
+		}
+		if user == nil {
+			return c.Send("You haven't started the bot yet. Use /start to create your account!")
+		}
+
+		redemption, err := storage.RedeemPromoCode(context.Background(), user.ID, code)
+		if err != nil {
+			logger.Debug(telegramID, "promo_redeem_failed", "error="+err.Error())
+			switch errMsg := err.Error(); {
+			case strings.Contains(errMsg, "not found"):
+				return c.Send("❌ Unknown promo code.")
+			case strings.Contains(errMsg, "already redeemed"):
+				return c.Send("❌ You have already redeemed this code.")
+			case strings.Contains(errMsg, "expired"):
+				return c.Send("❌ This promo code has expired.")
+			case strings.Contains(errMsg, "used up"):
+				return c.Send("❌ This promo code has been used up.")
+			default:
+				return c.Send("Error redeeming the code. Please try again.")
+			}
+		}
+
+		logger.Debug(telegramID, "promo_redeemed", fmt.Sprintf("code=%s amount=%d", redemption.Code, redemption.Amount))
+		return c.Send(fmt.Sprintf("🎁 Promo code %s redeemed: %s added.\n\nNew balance: %s",
+			redemption.Code, formatBalance(redemption.Amount), formatBalance(redemption.NewBalance)))
+	})
+
 	// Register /me command handler
 	b.Handle("/me", func(c telebot.Context) error {
 		telegramID := c.Sender().ID
@@ -194,8 +350,8 @@ func StartBot() {
 			"📈 Win Rate: %.1f%%",
 			stats.TotalBets, stats.Wins, stats.Losses, winRatePercent)
 
-		// Get user bets
-		bets, err := storage.GetUserBets(user.ID)
+		// Get the 10 most recent bets
+		bets, _, err := storage.GetUserBetsPage(user.ID, storage.BetHistoryFilter{Limit: 10})
 		if err != nil {
 			logger.Debug(telegramID, "error", fmt.Sprintf("failed to get user bets: %v", err))
 			bets = []storage.BetHistoryItem{}
@@ -249,19 +405,19 @@ func StartBot() {
 				// Format payout
 				payoutText := ""
 				if bet.Status == storage.BetStatusWon && bet.Payout > 0 {
-					payoutText = fmt.Sprintf(" | 💰 Payout: %d WSC", bet.Payout)
+					payoutText = " | 💰 Payout: " + formatBalance(bet.Payout)
 				}
 
 				historyText += fmt.Sprintf("\n*%d.* %s\n"+
 					"   📝 %s\n"+
-					"   🎯 %s %s | %d WSC%s\n"+
+					"   🎯 %s %s | %s%s\n"+
 					"   %s %s",
 					i+1,
 					statusEmoji,
-					escapeMarkdown(question),
+					bet.Icon+" "+escapeMarkdown(question),
 					outcomeEmoji,
 					bet.OutcomeChosen,
-					bet.Amount,
+					formatBalance(bet.Amount),
 					payoutText,
 					statusEmoji,
 					statusText)
@@ -281,68 +437,205 @@ func StartBot() {
 		})
 	})
 
+	// Register /leaderboard command handler: /leaderboard ranks by balance, /leaderboard week and
+	// /leaderboard month by profit this week or month
+	b.Handle("/leaderboard", func(c telebot.Context) error {
+		telegramID := c.Sender().ID
+		logger.Debug(telegramID, "command_leaderboard", c.Message().Payload)
+
+		var period storage.LeaderboardPeriod
+		var title string
+		switch strings.ToLower(strings.TrimSpace(c.Message().Payload)) {
+		case "", "all", "all_time":
+			period, title = storage.LeaderboardAllTime, "All-Time Leaderboard"
+		case "week", "weekly":
+			period, title = storage.LeaderboardWeekly, "This Week's Top Traders"
+		case "month", "monthly":
+			period, title = storage.LeaderboardMonthly, "This Month's Top Traders"
+		default:
+			return c.Send("Usage: /leaderboard [week|month|all]")
+		}
+
+		var entries []storage.LeaderboardEntry
+		var err error
+		if period == storage.LeaderboardAllTime {
+			entries, err = storage.GetTopUsers(leaderboardSize)
+		} else {
+			entries, _, err = storage.GetProfitLeaderboardPage(period.Since(time.Now()), leaderboardSize, 0)
+		}
+		if err != nil {
+			logger.Debug(telegramID, "error", fmt.Sprintf("failed to get leaderboard: %v", err))
+			return c.Send("Error retrieving the leaderboard. Please try again.")
+		}
+		if len(entries) == 0 {
+			return c.Send("🏆 *"+title+"*\n\nNobody has traded yet. Be the first!", &telebot.SendOptions{ParseMode: telebot.ModeMarkdown})
+		}
+
+		lines := []string{"🏆 *" + title + "*", ""}
+		for _, entry := range entries {
+			score := formatBalance(entry.Balance)
+			if period != storage.LeaderboardAllTime {
+				score = formatBalance(entry.Profit)
+				if entry.Profit > 0 {
+					score = "+" + score
+				}
+			}
+			lines = append(lines, fmt.Sprintf("%d. %s — %s", entry.Rank, escapeMarkdown(entry.Name), score))
+		}
+		if period == storage.LeaderboardAllTime {
+			lines = append(lines, "", "See who is on a run with /leaderboard week or /leaderboard month.")
+		}
+
+		logger.Debug(telegramID, "leaderboard_displayed", fmt.Sprintf("period=%s count=%d", period, len(entries)))
+		return c.Send(strings.Join(lines, "\n"), &telebot.SendOptions{ParseMode: telebot.ModeMarkdown})
+	})
+
 	// Register /list command handler
 	b.Handle("/list", func(c telebot.Context) error {
 		telegramID := c.Sender().ID
 		logger.Debug(telegramID, "command_list", "")
 
-		// Get all active markets with creator info
-		markets, err := storage.ListActiveMarketsWithCreator()
+		// Get all active markets with creator info, minus the ones the user hid; in a group
+		// chat only the group's own markets
+		var markets []storage.MarketWithCreator
+		var err error
+		var viewerID int64
+		if user, _ := storage.GetUserByTelegramID(telegramID); user != nil {
+			viewerID = user.ID
+		}
+		inGroup := isGroupChat(c.Chat())
+		if inGroup {
+			markets, err = storage.ListGroupMarkets(c.Chat().ID, viewerID)
+		} else if viewerID != 0 {
+			markets, err = storage.ListActiveMarketsForUser(viewerID)
+		} else {
+			markets, err = storage.ListActiveMarketsWithCreator()
+		}
 		if err != nil {
 			logger.Debug(telegramID, "error", fmt.Sprintf("failed to list markets: %v", err))
 			return c.Send("Error retrieving markets. Please try again.")
 		}
 
+		title := "Active Markets"
+		if inGroup {
+			title = "Active Markets in this Group"
+		}
+
 		// Handle empty list case
 		if len(markets) == 0 {
-			noMarketsText := "📊 *Active Markets*\n\n" +
+			noMarketsText := "📊 *" + title + "*\n\n" +
 				"No active markets at the moment.\n" +
 				"Open the Prediction Market web app to create one!"
+			if inGroup {
+				noMarketsText = "📊 *" + title + "*\n\n" +
+					"This group has no markets yet.\n" +
+					"Create one for everyone here with /create <deadline> <question>"
+			}
 			return c.Send(noMarketsText, &telebot.SendOptions{
 				ParseMode: telebot.ModeMarkdown,
 			})
 		}
 
-		// Format the list of markets
-		var listText string
-		if telegramID == c.Sender().ID {
-			listText = fmt.Sprintf("📊 *Active Markets* (%d)\n\n", len(markets))
-		} else {
-			listText = fmt.Sprintf("📊 *Active Markets* (%d)\n\n", len(markets))
+		// Send a header, then one message per market so each carries its own bet buttons
+		header := fmt.Sprintf("📊 *%s* (%d)\n\nTap *Bet YES* or *Bet NO* under a market to bet %s.", title, len(markets), quickBetLabels())
+		if err := c.Send(header, &telebot.SendOptions{ParseMode: telebot.ModeMarkdown}); err != nil {
+			return err
 		}
 
 		for i, market := range markets {
+			if i == listMarketMessages {
+				break
+			}
+
 			// Truncate long questions
 			question := market.Question
 			if len(question) > 50 {
 				question = question[:47] + "..."
 			}
 
-			// Format pool amounts
-			poolYes := market.PoolYes
-			poolNo := market.PoolNo
+			// Format pool amounts; blind markets keep them hidden until they lock, sealed ones only show the total
+			pools := fmt.Sprintf("YES: %d | NO: %d", market.PoolYes, market.PoolNo)
+			if market.PoolsHidden {
+				pools = "🙈 hidden until close"
+			} else if market.SidesHidden {
+				pools = fmt.Sprintf("YES: ? | NO: ? (total %d)", market.PoolTotal)
+			}
 
 			// Escape special characters in question
-			escapedQuestion := escapeMarkdown(question)
+			escapedQuestion := market.Icon + " " + escapeMarkdown(question)
+			if market.Status == string(storage.MarketStatusLastCall) {
+				escapedQuestion = "⏳ " + escapedQuestion
+			}
+
+			// Markets locked on a signal only use their deadline as the latest close
+			deadline := market.ExpiresAt
+			switch storage.LockMode(market.LockMode) {
+			case storage.LockModeManual:
+				deadline = "when the creator locks it, at the latest " + deadline
+			case storage.LockModeOracle:
+				deadline = "when the event ends, at the latest " + deadline
+			}
 
-			// Add market entry
-			listText += fmt.Sprintf("*%d.* %s\n"+
+			marketText := fmt.Sprintf("*%d.* %s\n"+
 				"   👤 %s\n"+
-				"   💰 YES: %d | NO: %d\n"+
-				"   ⏰ %s\n\n",
+				"   💰 %s\n"+
+				"   ⏰ %s",
 				i+1,
 				escapedQuestion,
 				escapeMarkdown(market.CreatorName),
-				poolYes,
-				poolNo,
-				market.ExpiresAt)
+				pools,
+				deadline)
+			if err := c.Send(marketText, &telebot.SendOptions{ParseMode: telebot.ModeMarkdown}, betSideMarkup(market.ID)); err != nil {
+				return err
+			}
+		}
+
+		// The rest, and other amounts, are in the web app
+		footer := "Open the Prediction Market web app to bet other amounts!"
+		if len(markets) > listMarketMessages {
+			footer = fmt.Sprintf("…and %d more. Open the Prediction Market web app to see them all and bet other amounts!", len(markets)-listMarketMessages)
+		}
+
+		logger.Debug(telegramID, "list_displayed", fmt.Sprintf("markets_count=%d group=%v", len(markets), inGroup))
+		// Web App buttons only work in private chats; a group gets a link that opens its markets
+		if link := service.GroupDeepLink(c.Chat().ID); inGroup && link != "" {
+			markup := &telebot.ReplyMarkup{}
+			markup.InlineKeyboard = [][]telebot.InlineButton{{{Text: "📱 Open the group's markets", URL: link}}}
+			return c.Send(footer, markup)
+		}
+		return c.Send(footer)
+	})
+
+	// Register /search command handler, e.g. /search derby
+	b.Handle("/search", func(c telebot.Context) error {
+		telegramID := c.Sender().ID
+		query := strings.TrimSpace(c.Message().Payload)
+		logger.Debug(telegramID, "command_search", "q="+query)
+		if query == "" {
+			return c.Send("Usage: /search <words>, e.g. /search derby")
+		}
+
+		markets, err := storage.SearchMarkets(query, searchResultsLimit)
+		if err != nil {
+			logger.Debug(telegramID, "error", fmt.Sprintf("failed to search markets: %v", err))
+			return c.Send("Error searching markets. Please try again.")
+		}
+		if len(markets) == 0 {
+			return c.Send("🔍 No markets match \"" + query + "\".")
 		}
 
-		// Add footer with instruction
-		listText += "Use the Prediction Market web app to place bets!"
+		text := fmt.Sprintf("🔍 *Markets matching* \"%s\" (%d)\n\n", escapeMarkdown(query), len(markets))
+		for i, market := range markets {
+			question := market.Question
+			if len(question) > 50 {
+				question = question[:47] + "..."
+			}
+			text += fmt.Sprintf("*%d.* %s %s\n   #%d · %s\n\n", i+1, market.Icon, escapeMarkdown(question), market.ID, strings.ToLower(market.Status))
+		}
+		text += "Use the Prediction Market web app to place bets!"
 
-		logger.Debug(telegramID, "list_displayed", fmt.Sprintf("markets_count=%d", len(markets)))
-		return c.Send(listText, &telebot.SendOptions{
+		logger.Debug(telegramID, "search_displayed", fmt.Sprintf("markets_count=%d", len(markets)))
+		return c.Send(text, &telebot.SendOptions{
 			ParseMode: telebot.ModeMarkdown,
 		})
 	})
@@ -407,6 +700,18 @@ func StartBot() {
 				potentialPayout = bet.Amount * int64(100/odds)
 			}
 
+			pools := fmt.Sprintf("Pool: %s/%s | 🎲 %d%%", service.FormatMoney(bet.PoolYes), service.FormatMoney(bet.PoolNo), int(odds))
+			potential := formatBalance(potentialPayout)
+			if bet.PoolsHidden {
+				// Blind market: nothing about the pools until it locks
+				pools = "Pool: 🙈 hidden until close"
+				potential = "revealed at close"
+			} else if bet.SidesHidden {
+				// Sealed market: only the total stake is known
+				pools = fmt.Sprintf("Pool: ?/? (total %s)", service.FormatMoney(bet.PoolTotal))
+				potential = "revealed at payout"
+			}
+
 			// Outcome emoji
 			outcomeEmoji := "✅"
 			if bet.OutcomeChosen == "NO" {
@@ -415,20 +720,18 @@ func StartBot() {
 
 			mybetsText += fmt.Sprintf("*%d.* %s\n"+
 				"   📝 %s\n"+
-				"   🎯 %s %s | %d WSC\n"+
-				"   💰 Pool: %d/%d | 🎲 %d%%\n"+
-				"   💸 Potential: %d WSC\n"+
+				"   🎯 %s %s | %s\n"+
+				"   💰 %s\n"+
+				"   💸 Potential: %s\n"+
 				"   ⏰ Expires: %s\n\n",
 				i+1,
 				escapeMarkdown(question),
-				escapeMarkdown(question),
+				bet.Icon+" "+escapeMarkdown(question),
 				outcomeEmoji,
 				bet.OutcomeChosen,
-				bet.Amount,
-				bet.PoolYes,
-				bet.PoolNo,
-				int(odds),
-				potentialPayout,
+				formatBalance(bet.Amount),
+				pools,
+				potential,
 				bet.ExpiresAt)
 		}
 
@@ -497,6 +800,288 @@ func StartBot() {
 		})
 	})
 
+	// Register /create command handler: /create <deadline> <question>, where the deadline is
+	// anything service.ParseDeadline understands ("48h", "friday 18:00", "end of month"),
+	// read in the user's timezone. A bare /create walks the user through it instead.
+	b.Handle("/create", func(c telebot.Context) error {
+		telegramID := c.Sender().ID
+		logger.Debug(telegramID, "command_create", "")
+
+		user, err := storage.GetUserByTelegramID(telegramID)
+		if err != nil {
+			logger.Debug(telegramID, "error", fmt.Sprintf("failed to get user: %v", err))
+			return c.Send("Error retrieving user data. Please try again.")
+		}
+		if user == nil {
+			return c.Send("You haven't started the bot yet. Use /start to create your account!")
+		}
+
+		usage := "Usage: /create <deadline> <question> [| <resolution criteria>]\n" +
+			"Example: /create friday 18:00 Will it snow in Berlin this weekend? | YES if the DWD reports snowfall in Berlin\n\n" +
+			"Deadlines can be like 48h, in 3 days, tomorrow 9am, fri 18:00, nov 30 or end of month. Set your timezone with /timezone."
+
+		// In a group the market belongs to the group; the step-by-step conversation is private only
+		inGroup := isGroupChat(c.Chat())
+		if strings.TrimSpace(c.Message().Payload) == "" {
+			if inGroup {
+				return c.Send("Create a market for this group in one message.\n\n" + usage)
+			}
+			return startCreateConversation(c, telegramID)
+		}
+		loc := service.UserLocation(user.ID)
+		expiresAt, rest, err := service.SplitDeadline(strings.TrimSpace(c.Message().Payload), time.Now(), loc)
+		if err != nil {
+			if errors.Is(err, service.ErrDeadlinePast) {
+				return c.Send("❌ That deadline is in the past.\n\n" + usage)
+			}
+			return c.Send("❌ Invalid deadline.\n\n" + usage)
+		}
+
+		// Anything after a "|" is the resolution criteria
+		question, criteria, _ := strings.Cut(rest, "|")
+
+		// Telegram redelivers the same message on retries, so its ID makes the creation idempotent
+		opts := storage.MarketOptions{IdempotencyKey: fmt.Sprintf("tg-%d-%d", c.Chat().ID, c.Message().ID)}
+		if inGroup {
+			opts.GroupID = c.Chat().ID
+		}
+		marketService := service.NewMarketService()
+		market, err := marketService.CreateMarket(context.Background(), user, question, expiresAt, criteria, opts)
+		var duplicate *service.DuplicateMarketError
+		if errors.As(err, &duplicate) {
+			return c.Send(fmt.Sprintf("✅ Market #%d was already created from this request.", duplicate.Market.ID))
+		}
+		if err != nil {
+			logger.Debug(telegramID, "create_failed", fmt.Sprintf("error=%v", err))
+			if strings.Contains(err.Error(), "invalid") {
+				return c.Send("❌ " + err.Error())
+			}
+			return c.Send("Error creating market. Please try again.")
+		}
+
+		created := "✅ Market #%d created!\n\n%s\n\nExpires: %s"
+		if inGroup {
+			created = "✅ Market #%d created for this group! See it with /list.\n\n%s\n\nExpires: %s"
+			logger.Debug(telegramID, "group_market_created", fmt.Sprintf("market_id=%d chat_id=%d", market.ID, c.Chat().ID))
+		}
+		return c.Send(fmt.Sprintf(created,
+			market.ID, market.Question, market.ExpiresAt.In(loc).Format("Mon Jan 2, 2006 15:04 MST")))
+	})
+
+	// Register /weather command handler: creates a market from the weather template, e.g.
+	// /weather Berlin tomorrow rain or /weather New York 2026-07-04 above 30
+	b.Handle("/weather", func(c telebot.Context) error {
+		telegramID := c.Sender().ID
+		logger.Debug(telegramID, "command_weather", c.Message().Payload)
+
+		usage := "Usage: /weather <city> <tomorrow|YYYY-MM-DD> <rain|snow|above °C|below °C>\n" +
+			"Examples: /weather Berlin tomorrow rain, /weather Madrid 2026-07-04 above 35\n\n" +
+			"Betting closes when the day starts there, and the market resolves itself from the observed weather once the day is over."
+		if !service.WeatherOracleEnabled() {
+			return c.Send("Weather markets are not enabled here.")
+		}
+
+		user, err := storage.GetUserByTelegramID(telegramID)
+		if err != nil {
+			logger.Debug(telegramID, "error", fmt.Sprintf("failed to get user: %v", err))
+			return c.Send("Error retrieving user data. Please try again.")
+		}
+		if user == nil {
+			return c.Send("You haven't started the bot yet. Use /start to create your account!")
+		}
+
+		template, ok := parseWeatherCommand(strings.Fields(c.Message().Payload))
+		if !ok {
+			return c.Send(usage)
+		}
+		template.Key = fmt.Sprintf("tg-%d-%d", c.Chat().ID, c.Message().ID)
+
+		weather, err := service.CreateWeatherMarket(context.Background(), user, template)
+		var duplicate *service.DuplicateMarketError
+		if errors.As(err, &duplicate) {
+			return c.Send(fmt.Sprintf("✅ Market #%d was already created from this request.", duplicate.Market.ID))
+		}
+		if err != nil {
+			logger.Debug(telegramID, "weather_create_failed", fmt.Sprintf("error=%v", err))
+			if errMsg := err.Error(); strings.Contains(errMsg, "invalid") || strings.Contains(errMsg, "not found") {
+				return c.Send("❌ " + errMsg + "\n\n" + usage)
+			}
+			return c.Send("Error creating market. Please try again.")
+		}
+
+		market, err := storage.GetMarketByID(weather.MarketID)
+		if err != nil || market == nil {
+			return c.Send(fmt.Sprintf("✅ Market #%d created!", weather.MarketID))
+		}
+		return c.Send(fmt.Sprintf("✅ Market #%d created!\n\n%s\n\nBetting closes: %s\nResolves automatically after the day ends in %s.",
+			market.ID, market.Question, market.ExpiresAt.In(service.UserLocation(user.ID)).Format("Mon Jan 2, 2006 15:04 MST"), weather.Place))
+	})
+
+	// Register /cancel command handler: drops a /create conversation
+	b.Handle("/cancel", func(c telebot.Context) error {
+		telegramID := c.Sender().ID
+		logger.Debug(telegramID, "command_cancel", "")
+		if !createFlow.Cancel(telegramID) {
+			return c.Send("Nothing to cancel.")
+		}
+		return c.Send("❌ Market creation cancelled.")
+	})
+
+	// Plain messages answer the /create conversation in private chats; others are ignored
+	b.Handle(telebot.OnText, func(c telebot.Context) error {
+		if c.Chat() == nil || c.Chat().Type != telebot.ChatPrivate {
+			return nil
+		}
+		return handleCreateText(c, c.Sender().ID, c.Text())
+	})
+
+	// Register /timezone command handler: /timezone shows the timezone deadlines are read in,
+	// /timezone Europe/Berlin changes it
+	b.Handle("/timezone", func(c telebot.Context) error {
+		telegramID := c.Sender().ID
+		logger.Debug(telegramID, "command_timezone", c.Message().Payload)
+
+		user, err := storage.GetUserByTelegramID(telegramID)
+		if err != nil || user == nil {
+			logger.Debug(telegramID, "error", "user_not_found")
+			return c.Send("You haven't started the bot yet. Use /start to create your account!")
+		}
+
+		name := strings.TrimSpace(c.Message().Payload)
+		if name == "" {
+			return c.Send(fmt.Sprintf("🕒 Your timezone is %s.\n\nChange it with /timezone <name>, e.g. /timezone Europe/Berlin", service.UserLocation(user.ID)))
+		}
+
+		loc, err := service.LoadTimezone(name)
+		if err != nil {
+			return c.Send("❌ Unknown timezone. Use a name like Europe/Berlin, America/New_York or UTC.")
+		}
+		if err := storage.SetUserTimezone(user.ID, loc.String()); err != nil {
+			logger.Debug(telegramID, "timezone_error", "error="+err.Error())
+			return c.Send("Error saving your timezone. Please try again.")
+		}
+
+		logger.Debug(telegramID, "timezone_updated", "timezone="+loc.String())
+		return c.Send(fmt.Sprintf("✅ Timezone set to %s. It is now %s there.", loc, time.Now().In(loc).Format("Mon 15:04")))
+	})
+
+	// Register /mute and /unmute command handlers: /mute silences every DM except payouts and
+	// refunds, /unmute brings the other DMs back
+	b.Handle("/mute", func(c telebot.Context) error {
+		return handleMuteCommand(c, true)
+	})
+	b.Handle("/unmute", func(c telebot.Context) error {
+		return handleMuteCommand(c, false)
+	})
+
+	// Register /digest command handler: /digest shows today's digest, /digest 8 (or 08:00)
+	// sends it every day at 8:00 in the user's timezone and /digest off stops it
+	b.Handle("/digest", func(c telebot.Context) error {
+		telegramID := c.Sender().ID
+		logger.Debug(telegramID, "command_digest", c.Message().Payload)
+
+		user, err := storage.GetUserByTelegramID(telegramID)
+		if err != nil || user == nil {
+			logger.Debug(telegramID, "error", "user_not_found")
+			return c.Send("You haven't started the bot yet. Use /start to create your account!")
+		}
+
+		arg := strings.ToLower(strings.TrimSpace(c.Message().Payload))
+		switch {
+		case arg == "":
+			digest, err := service.BuildDigest(user, time.Now())
+			if err != nil {
+				logger.Debug(telegramID, "error", fmt.Sprintf("failed to build digest: %v", err))
+				return c.Send("Error building your digest. Please try again.")
+			}
+			return c.Send(digest.Text() + "\n\nGet it every morning with /digest 8, stop it with /digest off.")
+		case arg == "off":
+			if err := storage.SetDigestHour(user.ID, storage.DigestOff); err != nil {
+				logger.Debug(telegramID, "digest_error", "error="+err.Error())
+				return c.Send("Error saving your digest settings. Please try again.")
+			}
+			logger.Debug(telegramID, "digest_unsubscribed", "")
+			return c.Send("🔕 Daily digest turned off.")
+		}
+
+		hour, err := strconv.Atoi(strings.TrimSuffix(arg, ":00"))
+		if err != nil || hour < 0 || hour > 23 {
+			return c.Send("Usage: /digest to see it now, /digest <hour 0-23> to get it daily, /digest off to stop it")
+		}
+		if err := storage.SetDigestHour(user.ID, hour); err != nil {
+			logger.Debug(telegramID, "digest_error", "error="+err.Error())
+			return c.Send("Error saving your digest settings. Please try again.")
+		}
+		logger.Debug(telegramID, "digest_subscribed", fmt.Sprintf("hour=%d", hour))
+		return c.Send(fmt.Sprintf("🔔 You'll get your digest every day at %02d:00 (%s). Change the timezone with /timezone.", hour, service.UserLocation(user.ID)))
+	})
+
+	// Register /groupdigest command handler for group chats: /groupdigest 8 [Europe/Berlin] posts
+	// a morning digest to the group every day at 8:00, /groupdigest off stops it.
+	// Only the group's admins can change it.
+	b.Handle("/groupdigest", func(c telebot.Context) error {
+		telegramID := c.Sender().ID
+		logger.Debug(telegramID, "command_group_digest", c.Message().Payload)
+
+		chat := c.Chat()
+		if !isGroupChat(chat) {
+			return c.Send("Add me to a group and use /groupdigest there to post a morning digest to it. For your own digest use /digest.")
+		}
+
+		args := strings.Fields(c.Message().Payload)
+		if len(args) == 0 {
+			group, err := storage.GetGroupDigest(chat.ID)
+			if err != nil {
+				logger.Debug(telegramID, "error", fmt.Sprintf("failed to get group digest: %v", err))
+				return c.Send("Error retrieving the digest settings. Please try again.")
+			}
+			if group == nil {
+				return c.Send("The morning digest is off. A group admin can turn it on with /groupdigest <hour 0-23> [timezone], e.g. /groupdigest 8 Europe/Berlin")
+			}
+			tz := group.Timezone
+			if tz == "" {
+				tz = "UTC"
+			}
+			return c.Send(fmt.Sprintf("☀️ The morning digest is posted every day at %02d:00 (%s). Turn it off with /groupdigest off.", group.Hour, tz))
+		}
+
+		member, err := c.Bot().ChatMemberOf(chat, c.Sender())
+		if err != nil || (member.Role != telebot.Creator && member.Role != telebot.Administrator) {
+			logger.Debug(telegramID, "group_digest_denied", fmt.Sprintf("chat_id=%d", chat.ID))
+			return c.Send("⛔ Only the group's admins can change the digest.")
+		}
+
+		if strings.EqualFold(args[0], "off") {
+			if err := storage.DisableGroupDigest(chat.ID); err != nil {
+				logger.Debug(telegramID, "group_digest_error", "error="+err.Error())
+				return c.Send("Error saving the digest settings. Please try again.")
+			}
+			logger.Debug(telegramID, "group_digest_disabled", fmt.Sprintf("chat_id=%d", chat.ID))
+			return c.Send("🔕 Morning digest turned off.")
+		}
+
+		hour, err := strconv.Atoi(strings.TrimSuffix(args[0], ":00"))
+		if err != nil || hour < 0 || hour > 23 || len(args) > 2 {
+			return c.Send("Usage: /groupdigest <hour 0-23> [timezone], e.g. /groupdigest 8 Europe/Berlin, or /groupdigest off")
+		}
+		// Without a timezone the admin's own is used
+		loc := time.UTC
+		if len(args) == 2 {
+			if loc, err = service.LoadTimezone(args[1]); err != nil {
+				return c.Send("❌ Unknown timezone. Use a name like Europe/Berlin, America/New_York or UTC.")
+			}
+		} else if user, err := storage.GetUserByTelegramID(telegramID); err == nil && user != nil {
+			loc = service.UserLocation(user.ID)
+		}
+
+		if err := storage.EnableGroupDigest(chat.ID, hour, loc.String(), telegramID); err != nil {
+			logger.Debug(telegramID, "group_digest_error", "error="+err.Error())
+			return c.Send("Error saving the digest settings. Please try again.")
+		}
+		logger.Debug(telegramID, "group_digest_enabled", fmt.Sprintf("chat_id=%d hour=%d timezone=%s", chat.ID, hour, loc))
+		return c.Send(fmt.Sprintf("☀️ I'll post new markets, today's deadlines and yesterday's results here every day at %02d:00 (%s).", hour, loc))
+	})
+
 	// Register /mymarkets command handler
 	b.Handle("/mymarkets", func(c telebot.Context) error {
 		telegramID := c.Sender().ID
@@ -546,6 +1131,9 @@ func StartBot() {
 			case "ACTIVE":
 				statusEmoji = "🟢"
 				statusText = "ACTIVE"
+			case "LAST_CALL":
+				statusEmoji = "⏳"
+				statusText = "LAST CALL"
 			case "LOCKED":
 				statusEmoji = "🔒"
 				statusText = "LOCKED"
@@ -558,19 +1146,29 @@ func StartBot() {
 			case "DISPUTED":
 				statusEmoji = "⚠️"
 				statusText = "DISPUTED"
+			case "MERGED":
+				statusEmoji = "🔀"
+				statusText = "MERGED"
+			}
+
+			// Blind and sealed markets hide their pools from the creator too
+			pools := fmt.Sprintf("%d/%d", market.PoolYes, market.PoolNo)
+			if market.PoolsHidden {
+				pools = "🙈"
+			} else if market.SidesHidden {
+				pools = fmt.Sprintf("?/? (%d)", market.PoolTotal)
 			}
 
 			myMarketsText += fmt.Sprintf("*%d.* %s\n"+
 				"   📝 %s\n"+
-				"   %s %s | 💰 %d/%d\n"+
+				"   %s %s | 💰 %s\n"+
 				"   ⏰ %s\n\n",
 				i+1,
 				statusEmoji,
-				escapeMarkdown(question),
+				market.Icon+" "+escapeMarkdown(question),
 				statusEmoji,
 				statusText,
-				market.PoolYes,
-				market.PoolNo,
+				pools,
 				market.ExpiresAt)
 		}
 
@@ -623,27 +1221,63 @@ func StartBot() {
 			keyboard = append(keyboard, []telebot.InlineButton{disputeButton})
 		}
 
-		return c.Send("⚠️ *Raise a Dispute*\n\nSelect a market to dispute:\n\nDisputing will freeze payouts and notify the admin for review.", &telebot.SendOptions{
+		// Show the agreed resolution criteria so users can check the outcome against them first
+		var criteria strings.Builder
+		for _, market := range markets {
+			if market.ResolutionCriteria != "" {
+				criteria.WriteString(fmt.Sprintf("\n\n*#%d* 📋 %s", market.ID, escapeMarkdown(market.ResolutionCriteria)))
+			}
+		}
+		if criteria.Len() > 0 {
+			criteria.WriteString("\n\nOnly dispute if the resolution does not match these criteria.")
+		}
+
+		return c.Send("⚠️ *Raise a Dispute*\n\nSelect a market to dispute:\n\nDisputing will freeze payouts and notify the admin for review."+criteria.String(), &telebot.SendOptions{
 			ParseMode: telebot.ModeMarkdown,
 		}, &telebot.ReplyMarkup{
 			InlineKeyboard: keyboard,
 		})
 	})
 
+	// Register /propose command handler: /propose <market_id> YES|NO for markets whose
+	// creator has not resolved them in time. The other bettors then vote on the outcome.
+	b.Handle("/propose", func(c telebot.Context) error {
+		telegramID := c.Sender().ID
+		logger.Debug(telegramID, "command_propose", c.Message().Payload)
+
+		user, err := storage.GetUserByTelegramID(telegramID)
+		if err != nil || user == nil {
+			logger.Debug(telegramID, "error", "user_not_found")
+			return c.Send("You haven't started the bot yet. Use /start to create your account!")
+		}
+
+		args := strings.Fields(c.Message().Payload)
+		if len(args) != 2 {
+			return c.Send("Usage: /propose <market_id> YES|NO")
+		}
+		marketID, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+		if err != nil {
+			return c.Send("❌ Invalid market ID. Usage: /propose <market_id> YES|NO")
+		}
+
+		proposal, err := service.NewPayoutService().ProposeOutcome(context.Background(), marketID, user, strings.ToUpper(args[1]))
+		if err != nil {
+			logger.Debug(telegramID, "propose_error", fmt.Sprintf("market_id=%d error=%s", marketID, err.Error()))
+			return c.Send(fmt.Sprintf("❌ Proposal Failed: %s", err.Error()))
+		}
+
+		return c.Send(fmt.Sprintf("🗳️ You proposed %s for market #%d. The other bettors vote until %s; your stake counts as the first confirmation.",
+			proposal.Outcome, proposal.MarketID, proposal.ClosesAt.UTC().Format("2006-01-02 15:04 UTC")))
+	})
+
 	// Register /resolve_disputes command handler (admin only)
 	b.Handle("/resolve_disputes", func(c telebot.Context) error {
 		telegramID := c.Sender().ID
 		logger.Debug(telegramID, "command_resolve_disputes", "")
 
-		// Check if user is admin
-		adminIDStr := os.Getenv("ADMIN_TELEGRAM_ID")
-		if adminIDStr == "" {
-			return c.Send("❌ Admin functionality not configured.")
-		}
-
-		adminID, err := strconv.ParseInt(adminIDStr, 10, 64)
-		if err != nil || telegramID != adminID {
-			logger.Debug(telegramID, "unauthorized_admin_access", fmt.Sprintf("admin_id=%s", adminIDStr))
+		// Check if user may resolve disputes (admins and oracles)
+		if !auth.HasPermission(telegramID, auth.PermissionResolveDisputes) {
+			logger.Debug(telegramID, "unauthorized_admin_access", "")
 			return c.Send("❌ This command is only available to administrators.")
 		}
 
@@ -686,6 +1320,14 @@ func StartBot() {
 		})
 	})
 
+	// Register /grant_admin and /revoke_admin command handlers (admin only)
+	b.Handle("/grant_admin", func(c telebot.Context) error {
+		return handleRoleCommand(c, true)
+	})
+	b.Handle("/revoke_admin", func(c telebot.Context) error {
+		return handleRoleCommand(c, false)
+	})
+
 	// Register universal callback query handler for all interactive buttons
 	b.Handle(telebot.OnCallback, func(c telebot.Context) error {
 		telegramID := c.Sender().ID
@@ -705,6 +1347,24 @@ func StartBot() {
 		} else if strings.HasPrefix(callbackData, "admin_") {
 			// Admin resolution of disputed market
 			return handleAdminResolveCallback(c, telegramID, callbackData)
+		} else if strings.HasPrefix(callbackData, "transfer_") {
+			// Market ownership transfer confirmation/acceptance
+			return handleTransferCallback(c, telegramID, callbackData)
+		} else if strings.HasPrefix(callbackData, "cosign_") {
+			// Second signature on a big market's resolution
+			return handleCosignCallback(c, telegramID, callbackData)
+		} else if strings.HasPrefix(callbackData, "proposal_") {
+			// Bettor vote on an outcome proposed in the creator's absence
+			return handleProposalVoteCallback(c, telegramID, callbackData)
+		} else if strings.HasPrefix(callbackData, "onboarding_") {
+			// Next or skip in a new user's onboarding
+			return handleOnboardingCallback(c, telegramID, callbackData)
+		} else if strings.HasPrefix(callbackData, "create_") {
+			// Deadline, category or confirmation in a /create conversation
+			return handleCreateCallback(c, telegramID, callbackData)
+		} else if strings.HasPrefix(callbackData, "bet_") {
+			// Side or amount of a bet placed from /list
+			return handleBetCallback(c, telegramID, callbackData)
 		}
 
 		logger.Debug(telegramID, "callback_ignored", fmt.Sprintf("unknown callback: %s", callbackData))
@@ -746,7 +1406,7 @@ func handleResolveCallback(c telebot.Context, telegramID int64, callbackData str
 
 	// Resolve market
 	payoutService := service.NewPayoutService()
-	err = payoutService.ResolveMarket(context.Background(), marketID, user.ID, outcome)
+	cosign, err := payoutService.ResolveMarket(context.Background(), marketID, user, outcome)
 	if err != nil {
 		logger.Debug(telegramID, "resolve_error", fmt.Sprintf("market_id=%d error=%s", marketID, err.Error()))
 		return c.Respond(&telebot.CallbackResponse{
@@ -773,6 +1433,14 @@ func handleResolveCallback(c telebot.Context, telegramID int64, callbackData str
 		outcomeEmoji = "🔴"
 	}
 
+	if cosign != nil {
+		// Big market: the outcome waits for a second signer
+		_ = c.Edit(fmt.Sprintf("✍️ *Resolution as %s sent for co-signing*%s\n\nMarket #%d has a big pool, so an admin or oracle has to confirm the outcome. The dispute period starts once they do.", outcome, marketInfo, marketID), &telebot.SendOptions{
+			ParseMode: telebot.ModeMarkdown,
+		})
+		return c.Respond(&telebot.CallbackResponse{Text: "✍️ Waiting for a co-signer"})
+	}
+
 	// Edit the original message
 	_ = c.Edit(fmt.Sprintf("%s *Market Resolved as %s*%s\n\nMarket #%d has been resolved.\n\nPayouts will be distributed after the dispute period.", outcomeEmoji, outcome, marketInfo, marketID), &telebot.SendOptions{
 		ParseMode: telebot.ModeMarkdown,
@@ -808,7 +1476,7 @@ func handleDisputeCallback(c telebot.Context, telegramID int64, callbackData str
 
 	// Raise dispute
 	payoutService := service.NewPayoutService()
-	err = payoutService.RaiseDispute(context.Background(), marketID, user.ID)
+	err = payoutService.RaiseDispute(context.Background(), marketID, user, "", "")
 	if err != nil {
 		logger.Debug(telegramID, "dispute_error", fmt.Sprintf("market_id=%d error=%s", marketID, err.Error()))
 		return c.Respond(&telebot.CallbackResponse{
@@ -830,8 +1498,13 @@ func handleDisputeCallback(c telebot.Context, telegramID int64, callbackData str
 		marketInfo = fmt.Sprintf("\n\n📝 %s", question)
 	}
 
+	bondNote := ""
+	if bond := service.LoadDisputeBond(); bond > 0 {
+		bondNote = fmt.Sprintf("\n\nYour %s WSC bond comes back if the resolution is overturned.", service.FormatMoney(bond))
+	}
+
 	// Edit message
-	_ = c.Edit(fmt.Sprintf("⚠️ *Dispute Raised*%s\n\nMarket #%d is now under dispute.\n\nPayouts are frozen. An admin will review and make the final decision.", marketInfo, marketID), &telebot.SendOptions{
+	_ = c.Edit(fmt.Sprintf("⚠️ *Dispute Raised*%s\n\nMarket #%d is now under dispute.\n\nPayouts are frozen. An admin will review and make the final decision.%s", marketInfo, marketID, bondNote), &telebot.SendOptions{
 		ParseMode: telebot.ModeMarkdown,
 	})
 
@@ -841,13 +1514,7 @@ func handleDisputeCallback(c telebot.Context, telegramID int64, callbackData str
 // handleAdminResolveCallback handles admin resolution of disputed markets
 func handleAdminResolveCallback(c telebot.Context, telegramID int64, callbackData string) error {
 	// Verify admin
-	adminIDStr := os.Getenv("ADMIN_TELEGRAM_ID")
-	if adminIDStr == "" {
-		return c.Respond(&telebot.CallbackResponse{Text: "❌ Admin not configured", ShowAlert: true})
-	}
-
-	adminID, err := strconv.ParseInt(adminIDStr, 10, 64)
-	if err != nil || telegramID != adminID {
+	if !auth.HasPermission(telegramID, auth.PermissionResolveDisputes) {
 		logger.Debug(telegramID, "unauthorized_admin_callback", "")
 		return c.Respond(&telebot.CallbackResponse{Text: "❌ Admin only", ShowAlert: true})
 	}
@@ -905,3 +1572,620 @@ func handleAdminResolveCallback(c telebot.Context, telegramID int64, callbackDat
 
 	return c.Respond(&telebot.CallbackResponse{Text: fmt.Sprintf("✅ Finalized as %s! %d payouts distributed.", outcome, payoutsProcessed)})
 }
+
+// handleTransferCallback handles market ownership transfer buttons
+// The creator sees confirm/cancel, the recipient sees accept/decline
+func handleTransferCallback(c telebot.Context, telegramID int64, callbackData string) error {
+	// Parse callback: transfer_{action}_{transferID}
+	parts := strings.Split(callbackData, "_")
+	if len(parts) != 3 {
+		logger.Debug(telegramID, "callback_error", fmt.Sprintf("invalid transfer format: %s", callbackData))
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Invalid button format"})
+	}
+
+	action := parts[1]
+	transferID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		logger.Debug(telegramID, "callback_error", fmt.Sprintf("invalid_transfer_id: %s", parts[2]))
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Invalid transfer ID"})
+	}
+
+	// Get user
+	user, err := storage.GetUserByTelegramID(telegramID)
+	if err != nil || user == nil {
+		logger.Debug(telegramID, "error", "user_not_found")
+		return c.Respond(&telebot.CallbackResponse{Text: "You haven't started the bot yet. Use /start!"})
+	}
+
+	logger.Debug(telegramID, "callback_transfer_start", fmt.Sprintf("transfer_id=%d action=%s", transferID, action))
+
+	transferService := service.NewTransferService()
+	var transfer *storage.MarketTransfer
+	var resultText string
+	switch action {
+	case "confirm":
+		transfer, err = transferService.ConfirmTransfer(context.Background(), transferID, user.ID)
+		resultText = "✅ Transfer confirmed. Waiting for the recipient to accept."
+	case "cancel":
+		transfer, err = transferService.CancelTransfer(context.Background(), transferID, user.ID)
+		resultText = "✖️ Transfer cancelled. You remain the market creator."
+	case "accept":
+		transfer, err = transferService.AcceptTransfer(context.Background(), transferID, user.ID)
+		resultText = "✅ You are now the creator of this market. You will be reminded when it needs resolving."
+	case "decline":
+		transfer, err = transferService.DeclineTransfer(context.Background(), transferID, user.ID)
+		resultText = "✖️ Transfer declined."
+	default:
+		logger.Debug(telegramID, "callback_error", fmt.Sprintf("unknown transfer action: %s", action))
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Invalid button format"})
+	}
+	if err != nil {
+		logger.Debug(telegramID, "transfer_error", fmt.Sprintf("transfer_id=%d action=%s error=%s", transferID, action, err.Error()))
+		return c.Respond(&telebot.CallbackResponse{
+			Text:      fmt.Sprintf("❌ Transfer Failed: %s", err.Error()),
+			ShowAlert: true,
+		})
+	}
+
+	logger.Debug(telegramID, "transfer_"+action, fmt.Sprintf("transfer_id=%d market_id=%d", transferID, transfer.MarketID))
+
+	_ = c.Edit(fmt.Sprintf("🔁 Market #%d\n\n%s", transfer.MarketID, resultText))
+
+	return c.Respond(&telebot.CallbackResponse{Text: resultText})
+}
+
+// handleCosignCallback handles co-signature buttons sent to admins and oracles
+// for the resolution of big markets: cosign_{confirm|reject}_{cosignID}
+func handleCosignCallback(c telebot.Context, telegramID int64, callbackData string) error {
+	parts := strings.Split(callbackData, "_")
+	if len(parts) != 3 || (parts[1] != "confirm" && parts[1] != "reject") {
+		logger.Debug(telegramID, "callback_error", fmt.Sprintf("invalid cosign format: %s", callbackData))
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Invalid button format"})
+	}
+
+	confirm := parts[1] == "confirm"
+	cosignID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		logger.Debug(telegramID, "callback_error", fmt.Sprintf("invalid_cosign_id: %s", parts[2]))
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Invalid co-signature ID"})
+	}
+
+	user, err := storage.GetUserByTelegramID(telegramID)
+	if err != nil || user == nil {
+		logger.Debug(telegramID, "error", "user_not_found")
+		return c.Respond(&telebot.CallbackResponse{Text: "You haven't started the bot yet. Use /start!"})
+	}
+
+	logger.Debug(telegramID, "callback_cosign_start", fmt.Sprintf("cosign_id=%d confirm=%t", cosignID, confirm))
+
+	allowed := auth.HasPermission(telegramID, auth.PermissionResolveDisputes)
+	cosign, err := service.NewPayoutService().DecideCosign(context.Background(), cosignID, user, allowed, confirm)
+	if err != nil {
+		logger.Debug(telegramID, "cosign_error", fmt.Sprintf("cosign_id=%d error=%s", cosignID, err.Error()))
+		return c.Respond(&telebot.CallbackResponse{
+			Text:      fmt.Sprintf("❌ Co-signing Failed: %s", err.Error()),
+			ShowAlert: true,
+		})
+	}
+
+	resultText := fmt.Sprintf("✅ Co-signed: market #%d is resolved as %s. The dispute period has started.", cosign.MarketID, cosign.Outcome)
+	if !confirm {
+		resultText = fmt.Sprintf("✖️ Rejected: market #%d goes back to its creator to resolve again.", cosign.MarketID)
+	}
+	_ = c.Edit(resultText)
+
+	return c.Respond(&telebot.CallbackResponse{Text: resultText})
+}
+
+// handleProposalVoteCallback records a bettor's vote on a community-proposed outcome:
+// proposal_{confirm|reject}_{proposalID}
+func handleProposalVoteCallback(c telebot.Context, telegramID int64, callbackData string) error {
+	parts := strings.Split(callbackData, "_")
+	if len(parts) != 3 || (parts[1] != "confirm" && parts[1] != "reject") {
+		logger.Debug(telegramID, "callback_error", fmt.Sprintf("invalid proposal format: %s", callbackData))
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Invalid button format"})
+	}
+
+	confirm := parts[1] == "confirm"
+	proposalID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		logger.Debug(telegramID, "callback_error", fmt.Sprintf("invalid_proposal_id: %s", parts[2]))
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Invalid proposal ID"})
+	}
+
+	user, err := storage.GetUserByTelegramID(telegramID)
+	if err != nil || user == nil {
+		logger.Debug(telegramID, "error", "user_not_found")
+		return c.Respond(&telebot.CallbackResponse{Text: "You haven't started the bot yet. Use /start!"})
+	}
+
+	logger.Debug(telegramID, "callback_proposal_vote_start", fmt.Sprintf("proposal_id=%d confirm=%t", proposalID, confirm))
+
+	proposal, err := service.NewPayoutService().VoteOnProposal(context.Background(), proposalID, user, confirm)
+	if err != nil {
+		logger.Debug(telegramID, "proposal_vote_error", fmt.Sprintf("proposal_id=%d error=%s", proposalID, err.Error()))
+		return c.Respond(&telebot.CallbackResponse{
+			Text:      fmt.Sprintf("❌ Vote Failed: %s", err.Error()),
+			ShowAlert: true,
+		})
+	}
+
+	resultText := fmt.Sprintf("✅ You confirmed %s for market #%d. Voting closes %s.",
+		proposal.Outcome, proposal.MarketID, proposal.ClosesAt.UTC().Format("2006-01-02 15:04 UTC"))
+	if !confirm {
+		resultText = fmt.Sprintf("✖️ You rejected %s for market #%d. Voting closes %s.",
+			proposal.Outcome, proposal.MarketID, proposal.ClosesAt.UTC().Format("2006-01-02 15:04 UTC"))
+	}
+	_ = c.Edit(resultText)
+
+	return c.Respond(&telebot.CallbackResponse{Text: resultText})
+}
+
+// handleOnboardingCallback moves a new user on to the next onboarding step, or to the end when
+// they skip, and shows that step in place of the previous one
+func handleOnboardingCallback(c telebot.Context, telegramID int64, callbackData string) error {
+	if callbackData != service.OnboardingNextData && callbackData != service.OnboardingSkipData {
+		logger.Debug(telegramID, "callback_error", fmt.Sprintf("invalid onboarding format: %s", callbackData))
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Invalid button format"})
+	}
+
+	user, err := storage.GetUserByTelegramID(telegramID)
+	if err != nil || user == nil {
+		logger.Debug(telegramID, "error", "user_not_found")
+		return c.Respond(&telebot.CallbackResponse{Text: "You haven't started the bot yet. Use /start!"})
+	}
+
+	step, err := service.AdvanceOnboarding(user.ID, callbackData == service.OnboardingSkipData)
+	if err != nil {
+		logger.Debug(telegramID, "onboarding_error", fmt.Sprintf("error=%s", err.Error()))
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Something went wrong. Please try again."})
+	}
+	logger.Debug(telegramID, "onboarding_step", fmt.Sprintf("step=%s skipped=%t", step, callbackData == service.OnboardingSkipData))
+
+	prompt := service.OnboardingPrompt(user, step)
+	if err := c.Edit(prompt.Text, onboardingMarkup(prompt)); err != nil {
+		// The original message may be too old to edit
+		_ = c.Send(prompt.Text, onboardingMarkup(prompt))
+	}
+	return c.Respond()
+}
+
+// onboardingMarkup turns an onboarding message's buttons into an inline keyboard
+func onboardingMarkup(msg service.OnboardingMessage) *telebot.ReplyMarkup {
+	var keyboard [][]telebot.InlineButton
+	for _, row := range msg.Buttons {
+		var buttons []telebot.InlineButton
+		for _, b := range row {
+			btn := telebot.InlineButton{Text: b.Text, Data: b.Data, URL: b.URL}
+			if b.WebApp {
+				btn.WebApp = &telebot.WebApp{URL: webAppURL()}
+			}
+			buttons = append(buttons, btn)
+		}
+		keyboard = append(keyboard, buttons)
+	}
+	return &telebot.ReplyMarkup{InlineKeyboard: keyboard}
+}
+
+// createFlow holds the /create conversations in progress
+var createFlow = service.NewCreateFlow()
+
+// createCancelButton ends a /create conversation
+var createCancelButton = telebot.InlineButton{Text: "❌ Cancel", Data: "create_cancel"}
+
+// startCreateConversation starts a /create conversation by asking for the question
+func startCreateConversation(c telebot.Context, telegramID int64) error {
+	if c.Chat().Type != telebot.ChatPrivate {
+		return c.Send("Send /create to me in a private chat to create a market step by step.")
+	}
+
+	// The idempotency key comes from the /create message, like one-line creations
+	createFlow.Start(telegramID, fmt.Sprintf("tg-%d-%d", c.Chat().ID, c.Message().ID))
+	logger.Debug(telegramID, "create_conversation_started", "")
+
+	return c.Send("📝 *New Market* (1/4)\n\nWhat's your question? Send it as a message, e.g. _Will it snow in Berlin this weekend?_\n\n"+
+		"To add resolution criteria, put them after a \"|\": _... | YES if the DWD reports snowfall in Berlin_",
+		&telebot.SendOptions{ParseMode: telebot.ModeMarkdown},
+		&telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{{createCancelButton}}})
+}
+
+// handleCreateText takes the question or a typed deadline of a /create conversation. Messages
+// from users without a conversation, or at a step that needs a button, are ignored.
+func handleCreateText(c telebot.Context, telegramID int64, text string) error {
+	draft, ok := createFlow.Get(telegramID)
+	if !ok {
+		return nil
+	}
+
+	switch draft.Step {
+	case service.CreateStepQuestion:
+		draft, err := createFlow.SetQuestion(telegramID, text)
+		if err != nil {
+			return c.Send("❌ " + err.Error() + "\n\nPlease send the question again, or /cancel.")
+		}
+		logger.Debug(telegramID, "create_question_set", "")
+		return sendCreateDeadlinePrompt(c, draft)
+	case service.CreateStepDeadline:
+		user, err := storage.GetUserByTelegramID(telegramID)
+		if err != nil || user == nil {
+			return c.Send("You haven't started the bot yet. Use /start to create your account!")
+		}
+		draft, err := createFlow.SetDeadline(telegramID, text, service.UserLocation(user.ID))
+		if err != nil {
+			if errors.Is(err, service.ErrDeadlinePast) {
+				return c.Send("❌ That deadline is in the past. Please send another one, or /cancel.")
+			}
+			return c.Send("❌ Invalid deadline. Try something like 48h, fri 18:00 or nov 30, or /cancel.")
+		}
+		logger.Debug(telegramID, "create_deadline_set", "input=typed")
+		return sendCreateCategoryPrompt(c, draft)
+	}
+	return nil
+}
+
+// handleCreateCallback handles the buttons of a /create conversation:
+// create_deadline_{preset}, create_category_{category|none}, create_confirm and create_cancel
+func handleCreateCallback(c telebot.Context, telegramID int64, callbackData string) error {
+	if callbackData == "create_cancel" {
+		createFlow.Cancel(telegramID)
+		logger.Debug(telegramID, "create_conversation_cancelled", "")
+		_ = c.Edit("❌ Market creation cancelled.")
+		return c.Respond()
+	}
+
+	user, err := storage.GetUserByTelegramID(telegramID)
+	if err != nil || user == nil {
+		logger.Debug(telegramID, "error", "user_not_found")
+		return c.Respond(&telebot.CallbackResponse{Text: "You haven't started the bot yet. Use /start!"})
+	}
+
+	switch {
+	case strings.HasPrefix(callbackData, "create_deadline_"):
+		preset, err := strconv.Atoi(strings.TrimPrefix(callbackData, "create_deadline_"))
+		if err != nil || preset < 0 || preset >= len(service.CreateDeadlinePresets) {
+			logger.Debug(telegramID, "callback_error", fmt.Sprintf("invalid create format: %s", callbackData))
+			return c.Respond(&telebot.CallbackResponse{Text: "❌ Invalid button format"})
+		}
+		draft, err := createFlow.SetDeadline(telegramID, service.CreateDeadlinePresets[preset].Input, service.UserLocation(user.ID))
+		if err != nil {
+			return c.Respond(&telebot.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+		}
+		logger.Debug(telegramID, "create_deadline_set", "input="+service.CreateDeadlinePresets[preset].Input)
+		_ = c.Edit(fmt.Sprintf("⏰ Deadline: %s", service.CreateDeadlinePresets[preset].Label))
+		if err := sendCreateCategoryPrompt(c, draft); err != nil {
+			return err
+		}
+		return c.Respond()
+
+	case strings.HasPrefix(callbackData, "create_category_"):
+		category := strings.TrimPrefix(callbackData, "create_category_")
+		if category == "none" {
+			category = ""
+		}
+		draft, err := createFlow.SetCategory(telegramID, category)
+		if err != nil {
+			return c.Respond(&telebot.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+		}
+		logger.Debug(telegramID, "create_category_set", "category="+category)
+		confirm := fmt.Sprintf("✅ *Confirm New Market* (4/4)\n\n📝 %s\n⏰ Expires: %s",
+			escapeMarkdown(draft.FullQuestion()), draft.ExpiresAt.In(service.UserLocation(user.ID)).Format("Mon Jan 2, 2006 15:04 MST"))
+		if draft.Criteria != "" {
+			confirm += "\n📋 Resolution: " + escapeMarkdown(draft.Criteria)
+		}
+		_ = c.Edit(confirm, &telebot.SendOptions{ParseMode: telebot.ModeMarkdown}, &telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{
+			{{Text: "✅ Create", Data: "create_confirm"}, createCancelButton},
+		}})
+		return c.Respond()
+
+	case callbackData == "create_confirm":
+		draft, err := createFlow.Finish(telegramID)
+		if err != nil {
+			return c.Respond(&telebot.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+		}
+
+		marketService := service.NewMarketService()
+		market, err := marketService.CreateMarket(context.Background(), user, draft.FullQuestion(), draft.ExpiresAt, draft.Criteria, storage.MarketOptions{IdempotencyKey: draft.Key})
+		var duplicate *service.DuplicateMarketError
+		if errors.As(err, &duplicate) {
+			_ = c.Edit(fmt.Sprintf("✅ Market #%d was already created from this request.", duplicate.Market.ID))
+			return c.Respond()
+		}
+		if err != nil {
+			logger.Debug(telegramID, "create_failed", fmt.Sprintf("error=%v", err))
+			text := "Error creating market. Please try again with /create."
+			if strings.Contains(err.Error(), "invalid") {
+				text = "❌ " + err.Error()
+			}
+			_ = c.Edit(text)
+			return c.Respond()
+		}
+
+		logger.Debug(telegramID, "create_conversation_finished", fmt.Sprintf("market_id=%d", market.ID))
+		_ = c.Edit(fmt.Sprintf("✅ Market #%d created!\n\n%s\n\nExpires: %s",
+			market.ID, market.Question, market.ExpiresAt.In(service.UserLocation(user.ID)).Format("Mon Jan 2, 2006 15:04 MST")))
+		return c.Respond(&telebot.CallbackResponse{Text: "✅ Market created!"})
+	}
+
+	logger.Debug(telegramID, "callback_error", fmt.Sprintf("invalid create format: %s", callbackData))
+	return c.Respond(&telebot.CallbackResponse{Text: "❌ Invalid button format"})
+}
+
+// sendCreateDeadlinePrompt asks for the deadline with the preset buttons
+func sendCreateDeadlinePrompt(c telebot.Context, draft service.MarketDraft) error {
+	var row []telebot.InlineButton
+	for i, preset := range service.CreateDeadlinePresets {
+		row = append(row, telebot.InlineButton{Text: preset.Label, Data: fmt.Sprintf("create_deadline_%d", i)})
+	}
+	return c.Send(fmt.Sprintf("⏰ *Deadline* (2/4)\n\n📝 %s\n\nWhen should betting close? Pick one or type a deadline like _fri 18:00_ or _nov 30_ (in your /timezone).", escapeMarkdown(draft.Question)),
+		&telebot.SendOptions{ParseMode: telebot.ModeMarkdown},
+		&telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{row, {createCancelButton}}})
+}
+
+// sendCreateCategoryPrompt asks for the category, two buttons per row
+func sendCreateCategoryPrompt(c telebot.Context, draft service.MarketDraft) error {
+	var keyboard [][]telebot.InlineButton
+	for i, category := range service.CreateCategories {
+		button := telebot.InlineButton{Text: service.CategoryLabel(category), Data: "create_category_" + category}
+		if i%2 == 0 {
+			keyboard = append(keyboard, []telebot.InlineButton{button})
+		} else {
+			keyboard[len(keyboard)-1] = append(keyboard[len(keyboard)-1], button)
+		}
+	}
+	keyboard = append(keyboard, []telebot.InlineButton{{Text: "No category", Data: "create_category_none"}, createCancelButton})
+	return c.Send("🏷️ *Category* (3/4)\n\nPick a category; it's added to the question as a hashtag.",
+		&telebot.SendOptions{ParseMode: telebot.ModeMarkdown},
+		&telebot.ReplyMarkup{InlineKeyboard: keyboard})
+}
+
+// listMarketMessages caps how many markets /list sends as separate messages
+const listMarketMessages = 10
+
+// leaderboardSize is how many users /leaderboard shows
+const leaderboardSize = 10
+
+// parseWeatherCommand reads "/weather <city> <day> <condition>"; the city may have spaces, so the
+// condition and day are read from the end
+func parseWeatherCommand(args []string) (service.WeatherTemplate, bool) {
+	for n := 1; n <= 2 && n < len(args)-1; n++ {
+		condition, threshold, err := service.ParseWeatherCondition(args[len(args)-n:])
+		if err != nil {
+			continue
+		}
+		rest := args[:len(args)-n]
+		return service.WeatherTemplate{
+			Place:     strings.Join(rest[:len(rest)-1], " "),
+			Day:       rest[len(rest)-1],
+			Condition: condition,
+			Threshold: threshold,
+		}, true
+	}
+	return service.WeatherTemplate{}, false
+}
+
+// historySize is how many transactions /history shows
+const historySize = 10
+
+// transactionLabel describes a transaction in /history: its description, or its type in words
+func transactionLabel(t storage.Transaction) string {
+	if t.Description != "" {
+		return t.Description
+	}
+	label := strings.ToLower(strings.ReplaceAll(t.SourceType, "_", " "))
+	if label == "" {
+		return "Transaction"
+	}
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// quickBetLabels lists the bet buttons' amounts, e.g. "50, 100 or 500 WSC"
+func quickBetLabels() string {
+	amounts := service.QuickBetAmounts()
+	labels := make([]string, len(amounts)-1)
+	for i, amount := range amounts[:len(amounts)-1] {
+		labels[i] = service.FormatMoney(amount)
+	}
+	return strings.Join(labels, ", ") + " or " + service.FormatAmount(amounts[len(amounts)-1])
+}
+
+// betSideMarkup is the keyboard under a /list market: the side to bet on
+func betSideMarkup(marketID int64) *telebot.ReplyMarkup {
+	return &telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{{
+		{Text: "✅ Bet YES", Data: fmt.Sprintf("bet_%d_YES", marketID)},
+		{Text: "🔴 Bet NO", Data: fmt.Sprintf("bet_%d_NO", marketID)},
+	}}}
+}
+
+// betAmountMarkup is the keyboard after a side was picked: the preset amounts and a way back
+func betAmountMarkup(marketID int64, outcome string) *telebot.ReplyMarkup {
+	var row []telebot.InlineButton
+	for _, amount := range service.QuickBetAmounts() {
+		row = append(row, telebot.InlineButton{
+			Text: fmt.Sprintf("%s %s", outcome, service.FormatAmount(amount)),
+			Data: fmt.Sprintf("bet_%d_%s_%d", marketID, outcome, amount),
+		})
+	}
+	return &telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{row, {{Text: "« Back", Data: fmt.Sprintf("bet_%d_back", marketID)}}}}
+}
+
+// handleBetCallback handles the bet buttons under /list: bet_{marketID}_{outcome} shows the
+// amounts, bet_{marketID}_{outcome}_{amount} places the bet and bet_{marketID}_back goes back
+func handleBetCallback(c telebot.Context, telegramID int64, callbackData string) error {
+	parts := strings.Split(callbackData, "_")
+	if len(parts) != 3 && len(parts) != 4 {
+		logger.Debug(telegramID, "callback_error", fmt.Sprintf("invalid bet format: %s", callbackData))
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Invalid button format"})
+	}
+
+	marketID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		logger.Debug(telegramID, "callback_error", fmt.Sprintf("invalid_market_id: %s", parts[1]))
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Invalid market ID"})
+	}
+
+	outcome := parts[2]
+	if outcome == "back" && len(parts) == 3 {
+		_ = c.Edit(betSideMarkup(marketID))
+		return c.Respond()
+	}
+	if outcome != string(storage.OutcomeYes) && outcome != string(storage.OutcomeNo) {
+		logger.Debug(telegramID, "callback_error", fmt.Sprintf("invalid bet format: %s", callbackData))
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Invalid button format"})
+	}
+	if len(parts) == 3 {
+		_ = c.Edit(betAmountMarkup(marketID, outcome))
+		return c.Respond()
+	}
+
+	// Only the preset amounts can be bet from a button
+	amount, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil || !service.IsQuickBetAmount(amount) {
+		logger.Debug(telegramID, "callback_error", fmt.Sprintf("invalid bet format: %s", callbackData))
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Invalid button format"})
+	}
+
+	user, err := storage.GetUserByTelegramID(telegramID)
+	if err != nil || user == nil {
+		logger.Debug(telegramID, "error", "user_not_found")
+		return c.Respond(&telebot.CallbackResponse{Text: "You haven't started the bot yet. Use /start!"})
+	}
+
+	logger.Debug(telegramID, "bet_attempt", fmt.Sprintf("market_id=%d outcome=%s amount=%d source=%s", marketID, outcome, amount, storage.BetSourceInlineButton))
+	if err := storage.PlaceBetFrom(context.Background(), user.ID, marketID, outcome, amount, storage.BetSourceInlineButton); err != nil {
+		logger.Debug(telegramID, "bet_failed", "error="+err.Error())
+		text := "❌ Bet failed: " + err.Error()
+		if storage.IsBusyError(err) {
+			text = "❌ The bot is busy, please try again."
+		}
+		return c.Respond(&telebot.CallbackResponse{Text: text, ShowAlert: true})
+	}
+
+	// The same follow-ups as a bet placed in the web app
+	if poolYes, poolNo, err := storage.GetPoolTotals(marketID); err == nil {
+		service.CheckWhaleBet(nil, marketID, outcome, amount, poolYes, poolNo)
+	}
+	balance := user.Balance - amount
+	if updated, err := storage.GetUserByID(user.ID); err == nil && updated != nil {
+		balance = updated.Balance
+	}
+	service.QueueBetReceipt(nil, user.ID, marketID, outcome, amount, balance)
+	service.GetEventBus().PublishBetPlaced(marketID)
+
+	logger.Debug(telegramID, "bet_success", fmt.Sprintf("market_id=%d outcome=%s amount=%d new_balance=%d source=%s", marketID, outcome, amount, balance, storage.BetSourceInlineButton))
+	_ = c.Edit(betSideMarkup(marketID))
+	return c.Respond(&telebot.CallbackResponse{
+		Text:      fmt.Sprintf("✅ Bet %s on %s placed! Balance: %s", service.FormatAmount(amount), outcome, service.FormatAmount(balance)),
+		ShowAlert: true,
+	})
+}
+
+// isGroupChat reports whether chat is a group or supergroup
+func isGroupChat(chat *telebot.Chat) bool {
+	return chat != nil && (chat.Type == telebot.ChatGroup || chat.Type == telebot.ChatSuperGroup)
+}
+
+// webAppURL returns the Web App's URL from WEB_APP_URL, defaulting to a local server
+func webAppURL() string {
+	if url := os.Getenv("WEB_APP_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:8080"
+}
+
+// handleMuteCommand turns the user's DMs off (/mute) or back on (/unmute)
+func handleMuteCommand(c telebot.Context, mute bool) error {
+	telegramID := c.Sender().ID
+	logger.Debug(telegramID, "command_mute", fmt.Sprintf("mute=%t", mute))
+
+	user, err := storage.GetUserByTelegramID(telegramID)
+	if err != nil || user == nil {
+		logger.Debug(telegramID, "error", "user_not_found")
+		return c.Send("You haven't started the bot yet. Use /start to create your account!")
+	}
+
+	if err := storage.SetNotificationsMuted(user.ID, mute); err != nil {
+		logger.Debug(telegramID, "mute_error", "error="+err.Error())
+		return c.Send("Error saving your notification settings. Please try again.")
+	}
+
+	if mute {
+		return c.Send("🔕 DMs muted. You will still hear about payouts and refunds. Use /unmute to get everything again.")
+	}
+	return c.Send("🔔 DMs back on. Your notification settings apply again.")
+}
+
+// handleRoleCommand grants or revokes the admin role: /grant_admin @username or /revoke_admin @username
+func handleRoleCommand(c telebot.Context, grant bool) error {
+	telegramID := c.Sender().ID
+	command := "revoke_admin"
+	if grant {
+		command = "grant_admin"
+	}
+	logger.Debug(telegramID, "command_"+command, c.Message().Payload)
+
+	if !auth.HasPermission(telegramID, auth.PermissionManageRoles) {
+		logger.Debug(telegramID, "unauthorized_admin_access", "command="+command)
+		return c.Send("❌ This command is only available to administrators.")
+	}
+
+	username := strings.TrimPrefix(strings.TrimSpace(c.Message().Payload), "@")
+	if username == "" {
+		return c.Send(fmt.Sprintf("Usage: /%s @username", command))
+	}
+
+	actor, err := storage.GetUserByTelegramID(telegramID)
+	if err != nil || actor == nil {
+		return c.Send("Please use /start first to register.")
+	}
+
+	target, err := storage.GetUserByUsername(username)
+	if err != nil {
+		logger.Debug(telegramID, "error", fmt.Sprintf("failed to look up user: %v", err))
+		return c.Send("Error looking up user. Please try again.")
+	}
+	if target == nil {
+		return c.Send(fmt.Sprintf("❌ @%s has not started the bot yet.", username))
+	}
+
+	if grant {
+		err = auth.GrantRole(target.ID, storage.RoleAdmin, actor.ID)
+	} else {
+		if target.ID == actor.ID {
+			return c.Send("❌ You cannot revoke your own admin role.")
+		}
+		err = auth.RevokeRole(target.ID, storage.RoleAdmin, actor.ID)
+	}
+	if err != nil {
+		logger.Debug(telegramID, "error", fmt.Sprintf("%s failed: %v", command, err))
+		if strings.Contains(err.Error(), "not found") {
+			return c.Send(fmt.Sprintf("❌ @%s is not an admin.", username))
+		}
+		return c.Send("Error updating roles. Please try again.")
+	}
+
+	logger.Debug(telegramID, command, fmt.Sprintf("target_user_id=%d", target.ID))
+	if grant {
+		return c.Send(fmt.Sprintf("✅ @%s is now an admin.", username))
+	}
+	return c.Send(fmt.Sprintf("✅ @%s is no longer an admin.", username))
+}
+
+// trackLastSeen records that the sender of an update is active. Senders without an account are skipped.
+func trackLastSeen(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(c telebot.Context) error {
+		if sender := c.Sender(); sender != nil {
+			if user, err := storage.GetUserByTelegramID(sender.ID); err == nil && user != nil {
+				if err := storage.TouchLastSeen(user.ID); err != nil {
+					logger.Debug(sender.ID, "error", fmt.Sprintf("failed to record last seen: %v", err))
+				}
+				// Talking to the bot means it is no longer blocked
+				if enabled, err := storage.EnableDMs(user.ID); err != nil {
+					logger.Debug(sender.ID, "error", fmt.Sprintf("failed to enable DMs: %v", err))
+				} else if enabled {
+					logger.Debug(sender.ID, "dm_enabled", "")
+				}
+			}
+		}
+		return next(c)
+	}
+}
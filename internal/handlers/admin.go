@@ -0,0 +1,593 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+	"predictionbot/internal/storage"
+)
+
+// RoleRequest is the request body for granting or revoking a role
+type RoleRequest struct {
+	TelegramID int64  `json:"telegram_id"`
+	Role       string `json:"role"`
+}
+
+// AdjustBalanceRequest is the request body for an admin balance adjustment
+type AdjustBalanceRequest struct {
+	TelegramID int64  `json:"telegram_id"`
+	Amount     int64  `json:"amount"`
+	Reason     string `json:"reason"`
+}
+
+// AdjustBalanceResponse is the response for an admin balance adjustment
+type AdjustBalanceResponse struct {
+	TelegramID int64 `json:"telegram_id"`
+	Balance    int64 `json:"balance"`
+}
+
+// AccountMergeRequest is the request body for /api/admin/account-merges. POST opens a merge with
+// the two Telegram IDs; PUT completes merge_id with both codes; DELETE cancels merge_id.
+type AccountMergeRequest struct {
+	FromTelegramID int64  `json:"from_telegram_id"`
+	ToTelegramID   int64  `json:"to_telegram_id"`
+	MergeID        int64  `json:"merge_id"`
+	FromCode       string `json:"from_code"`
+	ToCode         string `json:"to_code"`
+}
+
+// requirePermission resolves the caller and checks that they hold the permission.
+// It writes the error response and returns nil if the caller is not allowed.
+func requirePermission(w http.ResponseWriter, r *http.Request, perm auth.Permission, action string) *storage.User {
+	user := currentUser(w, r, action)
+	if user == nil {
+		return nil
+	}
+
+	if !auth.HasPermission(user.TelegramID, perm) {
+		logger.Debug(user.TelegramID, action+"_forbidden", "permission="+string(perm))
+		respondWithError(w, "Forbidden: missing permission "+string(perm), http.StatusForbidden)
+		return nil
+	}
+
+	return user
+}
+
+// HandleAdminRoles handles /api/admin/roles
+// GET lists role assignments, POST grants a role, DELETE revokes a role.
+func HandleAdminRoles(w http.ResponseWriter, r *http.Request) {
+	actor := requirePermission(w, r, auth.PermissionManageRoles, "admin_roles")
+	if actor == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		assignments, err := storage.ListRoleAssignments()
+		if err != nil {
+			logger.Debug(actor.TelegramID, "admin_roles_list_failed", "error="+err.Error())
+			respondWithError(w, "Failed to list roles", http.StatusInternalServerError)
+			return
+		}
+		if assignments == nil {
+			assignments = []storage.RoleAssignment{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(assignments)
+
+	case http.MethodPost, http.MethodDelete:
+		var req RoleRequest
+		if err := decodeJSONBody(w, r, &req); err != nil {
+			logger.Debug(actor.TelegramID, "admin_roles_invalid_body", "error="+err.Error())
+			respondWithBodyError(w, err)
+			return
+		}
+
+		role := storage.Role(strings.ToLower(strings.TrimSpace(req.Role)))
+		if !storage.IsValidRole(role) {
+			logger.Debug(actor.TelegramID, "admin_roles_invalid_role", "role="+req.Role)
+			respondWithError(w, "Invalid role: must be 'admin', 'moderator' or 'oracle'", http.StatusBadRequest)
+			return
+		}
+
+		target, err := storage.GetUserByTelegramID(req.TelegramID)
+		if err != nil || target == nil {
+			logger.Debug(actor.TelegramID, "admin_roles_target_not_found", fmt.Sprintf("telegram_id=%d", req.TelegramID))
+			respondWithError(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			err = auth.GrantRole(target.ID, role, actor.ID)
+		} else {
+			err = auth.RevokeRole(target.ID, role, actor.ID)
+		}
+		if err != nil {
+			errMsg := err.Error()
+			logger.Debug(actor.TelegramID, "admin_roles_update_failed", fmt.Sprintf("telegram_id=%d role=%s error=%s", req.TelegramID, role, errMsg))
+			if strings.Contains(errMsg, "not found") {
+				respondWithError(w, errMsg, http.StatusNotFound)
+			} else {
+				respondWithError(w, "Failed to update role", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		logger.Debug(actor.TelegramID, "admin_roles_updated", fmt.Sprintf("method=%s telegram_id=%d role=%s", r.Method, req.TelegramID, role))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		logger.Debug(actor.TelegramID, "admin_roles_invalid_method", "method="+r.Method)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleAdminMarketSubpath routes /api/admin/markets/{id}/{action}
+// hide and unhide need the hide_markets permission, merge needs merge_markets.
+func HandleAdminMarketSubpath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Debug(0, "admin_market_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Expected path: /admin/markets/{id}/{action} (after StripPrefix removes /api)
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 4 || pathParts[0] != "admin" || pathParts[1] != "markets" {
+		logger.Debug(0, "admin_market_invalid_path", "path="+r.URL.Path)
+		respondWithError(w, "Invalid path format", http.StatusBadRequest)
+		return
+	}
+
+	marketID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		logger.Debug(0, "admin_market_invalid_id", "id="+pathParts[2])
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	switch pathParts[3] {
+	case "hide", "unhide":
+		handleAdminMarketVisibility(w, r, marketID, pathParts[3] == "hide")
+	case "merge":
+		handleAdminMarketMerge(w, r, marketID)
+	case "retry-finalization":
+		handleAdminRetryFinalization(w, r, marketID)
+	default:
+		respondWithError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// handleAdminMarketVisibility handles POST /api/admin/markets/{id}/hide and /unhide
+func handleAdminMarketVisibility(w http.ResponseWriter, r *http.Request, marketID int64, hidden bool) {
+	actor := requirePermission(w, r, auth.PermissionHideMarkets, "admin_market")
+	if actor == nil {
+		return
+	}
+
+	if err := storage.SetMarketHidden(marketID, hidden, actor.ID); err != nil {
+		errMsg := err.Error()
+		logger.Debug(actor.TelegramID, "admin_market_hide_failed", fmt.Sprintf("market_id=%d error=%s", marketID, errMsg))
+		if strings.Contains(errMsg, "not found") {
+			respondWithError(w, errMsg, http.StatusNotFound)
+		} else {
+			respondWithError(w, "Failed to update market", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logger.Debug(actor.TelegramID, "admin_market_visibility", fmt.Sprintf("market_id=%d hidden=%t", marketID, hidden))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"market_id": marketID,
+		"hidden":    hidden,
+	})
+}
+
+// MergeMarketRequest is the request body for merging a duplicate market into another
+type MergeMarketRequest struct {
+	IntoMarketID int64 `json:"into_market_id"`
+}
+
+// handleAdminMarketMerge handles POST /api/admin/markets/{id}/merge
+// The market in the path is the duplicate; it is closed and its bets move to into_market_id.
+func handleAdminMarketMerge(w http.ResponseWriter, r *http.Request, marketID int64) {
+	actor := requirePermission(w, r, auth.PermissionMergeMarkets, "admin_merge")
+	if actor == nil {
+		return
+	}
+
+	var req MergeMarketRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		logger.Debug(actor.TelegramID, "admin_merge_invalid_body", "error="+err.Error())
+		respondWithBodyError(w, err)
+		return
+	}
+
+	marketService := service.NewMarketService()
+	result, err := marketService.MergeMarkets(r.Context(), marketID, req.IntoMarketID, actor.ID)
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(actor.TelegramID, "admin_merge_failed", fmt.Sprintf("source_id=%d target_id=%d error=%s", marketID, req.IntoMarketID, errMsg))
+		if strings.Contains(errMsg, "not found") {
+			respondWithError(w, errMsg, http.StatusNotFound)
+		} else if strings.Contains(errMsg, "cannot be merged") {
+			respondWithError(w, errMsg, http.StatusConflict)
+		} else if strings.Contains(errMsg, "invalid") {
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		} else {
+			respondWithError(w, "Failed to merge markets", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logger.Debug(actor.TelegramID, "admin_merge_success", fmt.Sprintf("source_id=%d target_id=%d moved=%d refunded=%d", marketID, req.IntoMarketID, len(result.Moved), len(result.Refunded)))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleAdminRetryFinalization handles POST /api/admin/markets/{id}/retry-finalization
+// A market the worker gave up finalizing goes back to RESOLVED and is finalized on the worker's next run.
+func handleAdminRetryFinalization(w http.ResponseWriter, r *http.Request, marketID int64) {
+	actor := requirePermission(w, r, auth.PermissionResolveDisputes, "admin_retry_finalization")
+	if actor == nil {
+		return
+	}
+
+	if err := storage.RetryFinalization(r.Context(), marketID, actor.ID); err != nil {
+		errMsg := err.Error()
+		logger.Debug(actor.TelegramID, "admin_retry_finalization_failed", fmt.Sprintf("market_id=%d error=%s", marketID, errMsg))
+		if strings.Contains(errMsg, "not found") {
+			respondWithError(w, errMsg, http.StatusNotFound)
+		} else if strings.Contains(errMsg, "invalid status") {
+			respondWithError(w, errMsg, http.StatusConflict)
+		} else {
+			respondWithError(w, "Failed to retry finalization", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logger.Debug(actor.TelegramID, "admin_retry_finalization", fmt.Sprintf("market_id=%d", marketID))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"market_id": marketID,
+		"status":    storage.MarketStatusResolved,
+	})
+}
+
+// CosignDecisionRequest is the request body for co-signing or rejecting a pending resolution
+type CosignDecisionRequest struct {
+	CosignID int64 `json:"cosign_id"`
+	Confirm  bool  `json:"confirm"`
+}
+
+// HandleAdminCosigns handles /api/admin/cosigns
+// GET lists the resolutions waiting for a second signer, POST confirms or rejects one.
+// The creator of the market cannot co-sign their own resolution.
+func HandleAdminCosigns(w http.ResponseWriter, r *http.Request) {
+	actor := requirePermission(w, r, auth.PermissionResolveDisputes, "admin_cosigns")
+	if actor == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		cosigns, err := storage.ListPendingResolutionCosigns()
+		if err != nil {
+			logger.Debug(actor.TelegramID, "admin_cosigns_list_failed", "error="+err.Error())
+			respondWithError(w, "Failed to list co-signatures", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cosigns)
+
+	case http.MethodPost:
+		var req CosignDecisionRequest
+		if err := decodeJSONBody(w, r, &req); err != nil {
+			logger.Debug(actor.TelegramID, "admin_cosigns_invalid_body", "error="+err.Error())
+			respondWithBodyError(w, err)
+			return
+		}
+
+		cosign, err := service.NewPayoutService().DecideCosign(r.Context(), req.CosignID, actor, true, req.Confirm)
+		if err != nil {
+			errMsg := err.Error()
+			logger.Debug(actor.TelegramID, "admin_cosigns_failed", fmt.Sprintf("cosign_id=%d error=%s", req.CosignID, errMsg))
+			if strings.Contains(errMsg, "not found") {
+				respondWithError(w, errMsg, http.StatusNotFound)
+			} else if strings.Contains(errMsg, "your own resolution") {
+				respondWithError(w, errMsg, http.StatusForbidden)
+			} else if strings.Contains(errMsg, "no longer") {
+				respondWithError(w, errMsg, http.StatusConflict)
+			} else {
+				respondWithError(w, "Failed to co-sign resolution", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cosign)
+
+	default:
+		logger.Debug(actor.TelegramID, "admin_cosigns_invalid_method", "method="+r.Method)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleAdminBalance handles POST /api/admin/balance
+func HandleAdminBalance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Debug(0, "admin_balance_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	actor := requirePermission(w, r, auth.PermissionAdjustBalances, "admin_balance")
+	if actor == nil {
+		return
+	}
+
+	var req AdjustBalanceRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		logger.Debug(actor.TelegramID, "admin_balance_invalid_body", "error="+err.Error())
+		respondWithBodyError(w, err)
+		return
+	}
+
+	if strings.TrimSpace(req.Reason) == "" {
+		respondWithError(w, "Reason is required", http.StatusBadRequest)
+		return
+	}
+
+	target, err := storage.GetUserByTelegramID(req.TelegramID)
+	if err != nil || target == nil {
+		logger.Debug(actor.TelegramID, "admin_balance_target_not_found", fmt.Sprintf("telegram_id=%d", req.TelegramID))
+		respondWithError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	balance, err := storage.AdjustBalance(r.Context(), target.ID, req.Amount, req.Reason, actor.ID)
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(actor.TelegramID, "admin_balance_failed", fmt.Sprintf("telegram_id=%d amount=%d error=%s", req.TelegramID, req.Amount, errMsg))
+		if strings.Contains(errMsg, "invalid") || strings.Contains(errMsg, "insufficient") {
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		} else if strings.Contains(errMsg, "not found") {
+			respondWithError(w, errMsg, http.StatusNotFound)
+		} else {
+			respondWithError(w, "Failed to adjust balance", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logger.Debug(actor.TelegramID, "admin_balance_adjusted", fmt.Sprintf("telegram_id=%d amount=%d balance=%d", req.TelegramID, req.Amount, balance))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AdjustBalanceResponse{
+		TelegramID: req.TelegramID,
+		Balance:    balance,
+	})
+}
+
+// HandleAdminActivity handles GET /api/admin/activity?tz_offset=
+// It returns platform-wide bet counts by weekday and hour, like /api/me/activity.
+func HandleAdminActivity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "admin_activity_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	actor := requirePermission(w, r, auth.PermissionViewStats, "admin_activity")
+	if actor == nil {
+		return
+	}
+
+	writeActivity(w, r, actor.TelegramID, 0, "admin_activity")
+}
+
+// adminStatsWindows are the windows (days) GET /api/admin/stats can aggregate over
+var adminStatsWindows = map[int]bool{1: true, 7: true, 30: true, 90: true}
+
+// AdminStatsResponse is the response for GET /api/admin/stats
+type AdminStatsResponse struct {
+	*storage.PlatformStats
+	// Errors are the API error counters since the server started, whatever the window
+	Errors ErrorMetrics `json:"errors"`
+}
+
+// HandleAdminStats handles GET /api/admin/stats?days=
+// It returns DAU/WAU, new users, daily bet volume, bailout usage, the dispute rate, the
+// unresolved market backlog and the API error rates for the admin dashboard. days is 1, 7, 30
+// (default) or 90.
+func HandleAdminStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "admin_stats_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	actor := requirePermission(w, r, auth.PermissionViewStats, "admin_stats")
+	if actor == nil {
+		return
+	}
+
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || !adminStatsWindows[parsed] {
+			respondWithError(w, "Invalid days: must be 1, 7, 30 or 90", http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+
+	stats, err := storage.GetPlatformStats(days, time.Now())
+	if err != nil {
+		logger.Debug(actor.TelegramID, "admin_stats_failed", "error="+err.Error())
+		respondWithError(w, "Failed to compute statistics", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Debug(actor.TelegramID, "admin_stats_success", fmt.Sprintf("days=%d dau=%d wau=%d", days, stats.DAU, stats.WAU))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AdminStatsResponse{PlatformStats: stats, Errors: GetErrorMetrics()})
+}
+
+// HandleAdminEscrow handles GET /api/admin/escrow
+// It lists the payouts finalization held because the bettors' accounts no longer exist.
+func HandleAdminEscrow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "admin_escrow_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	actor := requirePermission(w, r, auth.PermissionAdjustBalances, "admin_escrow")
+	if actor == nil {
+		return
+	}
+
+	payouts, err := storage.ListEscrowedPayouts()
+	if err != nil {
+		logger.Debug(actor.TelegramID, "admin_escrow_list_failed", "error="+err.Error())
+		respondWithError(w, "Failed to list escrowed payouts", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payouts)
+}
+
+// HandleAdminSlowQueries handles GET /api/admin/slow-queries
+// It returns the query counters and the statements that took longer than SLOW_QUERY_MS.
+func HandleAdminSlowQueries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "admin_slow_queries_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	actor := requirePermission(w, r, auth.PermissionViewStats, "admin_slow_queries")
+	if actor == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(storage.GetQueryMetrics())
+}
+
+// adminDMFailuresLimit caps how many users GET /api/admin/telegram-errors lists
+const adminDMFailuresLimit = 50
+
+// TelegramErrorsResponse is the response for GET /api/admin/telegram-errors
+type TelegramErrorsResponse struct {
+	service.TelegramSendMetrics
+	// Users are the users whose DMs failed, those with DMs turned off first
+	Users []storage.DMFailures `json:"users"`
+}
+
+// HandleAdminTelegramErrors handles GET /api/admin/telegram-errors
+// It returns the Telegram send counters by error category and the users whose DMs keep failing.
+func HandleAdminTelegramErrors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "admin_telegram_errors_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	actor := requirePermission(w, r, auth.PermissionViewStats, "admin_telegram_errors")
+	if actor == nil {
+		return
+	}
+
+	users, err := storage.ListDMFailures(adminDMFailuresLimit)
+	if err != nil {
+		logger.Debug(actor.TelegramID, "admin_telegram_errors_failed", "error="+err.Error())
+		respondWithError(w, "Failed to list DM failures", http.StatusInternalServerError)
+		return
+	}
+	if users == nil {
+		users = []storage.DMFailures{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TelegramErrorsResponse{TelegramSendMetrics: service.GetTelegramSendMetrics(), Users: users})
+}
+
+// HandleAdminAccountMerges handles /api/admin/account-merges
+// GET lists pending merges, POST opens a merge and DMs both accounts a confirmation code,
+// PUT completes a merge with both codes, DELETE cancels it.
+func HandleAdminAccountMerges(w http.ResponseWriter, r *http.Request) {
+	actor := requirePermission(w, r, auth.PermissionAdjustBalances, "admin_account_merges")
+	if actor == nil {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		merges, err := storage.ListPendingAccountMerges()
+		if err != nil {
+			logger.Debug(actor.TelegramID, "admin_account_merges_list_failed", "error="+err.Error())
+			respondWithError(w, "Failed to list account merges", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(merges)
+		return
+	}
+
+	if r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		logger.Debug(actor.TelegramID, "admin_account_merges_invalid_method", "method="+r.Method)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AccountMergeRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		logger.Debug(actor.TelegramID, "admin_account_merges_invalid_body", "error="+err.Error())
+		respondWithBodyError(w, err)
+		return
+	}
+
+	mergeService := service.NewAccountMergeService()
+	var result interface{}
+	var err error
+	status := http.StatusOK
+	switch r.Method {
+	case http.MethodPost:
+		result, err = mergeService.RequestMerge(r.Context(), actor, req.FromTelegramID, req.ToTelegramID)
+		status = http.StatusAccepted
+	case http.MethodPut:
+		result, err = mergeService.CompleteMerge(r.Context(), actor, req.MergeID, strings.TrimSpace(req.FromCode), strings.TrimSpace(req.ToCode))
+	case http.MethodDelete:
+		err = mergeService.CancelMerge(r.Context(), actor, req.MergeID)
+		status = http.StatusNoContent
+	}
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(actor.TelegramID, "admin_account_merges_failed", fmt.Sprintf("method=%s merge_id=%d error=%s", r.Method, req.MergeID, errMsg))
+		if strings.Contains(errMsg, "not found") {
+			respondWithError(w, errMsg, http.StatusNotFound)
+		} else if strings.Contains(errMsg, "invalid") {
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		} else if strings.Contains(errMsg, "already pending") || strings.Contains(errMsg, "no longer pending") {
+			respondWithError(w, errMsg, http.StatusConflict)
+		} else {
+			respondWithError(w, "Failed to update account merge", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+	"predictionbot/internal/storage"
+)
+
+// CancelBetResponse is the response for POST /api/bets/{id}/cancel
+type CancelBetResponse struct {
+	storage.BetCancellation
+	NewBalance int64 `json:"new_balance"`
+}
+
+// HandleBetSubpath routes /api/bets/{id}/cancel
+func HandleBetSubpath(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/cancel") {
+		HandleCancelBet(w, r)
+		return
+	}
+	respondWithError(w, "Not found", http.StatusNotFound)
+}
+
+// HandleCancelBet handles POST /api/bets/{id}/cancel, refunding a bet on a market that still
+// takes bets, less the cancellation fee
+func HandleCancelBet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Debug(0, "bet_cancel_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(w, r, "bet_cancel")
+	if user == nil {
+		return
+	}
+	telegramID := user.TelegramID
+
+	// Expected path: /bets/{id}/cancel (after StripPrefix removes /api)
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 || pathParts[0] != "bets" || pathParts[2] != "cancel" {
+		logger.Debug(telegramID, "bet_cancel_invalid_path", "path="+r.URL.Path)
+		respondWithError(w, "Invalid path format", http.StatusBadRequest)
+		return
+	}
+	betID, err := strconv.ParseInt(pathParts[1], 10, 64)
+	if err != nil {
+		logger.Debug(telegramID, "bet_cancel_invalid_id", "id="+pathParts[1])
+		respondWithError(w, "Invalid bet ID", http.StatusBadRequest)
+		return
+	}
+
+	logger.Debug(telegramID, "bet_cancel_attempt", fmt.Sprintf("bet_id=%d", betID))
+	cancellation, err := storage.CancelBet(r.Context(), user.ID, betID)
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(telegramID, "bet_cancel_failed", fmt.Sprintf("bet_id=%d error=%s", betID, errMsg))
+		switch {
+		case storage.IsBusyError(err):
+			w.Header().Set("Retry-After", "1")
+			respondWithError(w, "Database busy, please retry", http.StatusServiceUnavailable)
+		case strings.Contains(errMsg, "not found"):
+			respondWithError(w, errMsg, http.StatusNotFound)
+		case strings.Contains(errMsg, "not active"), strings.Contains(errMsg, "expired"), strings.Contains(errMsg, "cancel window"):
+			respondWithError(w, errMsg, http.StatusForbidden)
+		default:
+			respondWithError(w, "Failed to cancel bet", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Push the new pools to /api/stream listeners
+	service.GetEventBus().PublishBetCancelled(cancellation.MarketID)
+
+	response := CancelBetResponse{BetCancellation: *cancellation}
+	if updated, err := storage.GetUserByID(user.ID); err == nil && updated != nil {
+		response.NewBalance = updated.Balance
+	}
+
+	logger.Debug(telegramID, "bet_cancel_success", fmt.Sprintf("bet_id=%d market_id=%d refund=%d fee=%d", betID, cancellation.MarketID, cancellation.Refund, cancellation.Fee))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -6,16 +6,18 @@ import (
 	"net/http"
 	"strings"
 
-	"predictionbot/internal/auth"
 	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
 	"predictionbot/internal/storage"
 )
 
 // PlaceBetRequest is the request body for placing a bet
 type PlaceBetRequest struct {
-	MarketID int64  `json:"market_id"`
-	Outcome  string `json:"outcome"`
-	Amount   int64  `json:"amount"`
+	MarketID int64  `json:"market_id" validate:"required,min=1"`
+	Outcome  string `json:"outcome" validate:"required,oneof=YES NO"`
+	Amount   int64  `json:"amount" validate:"min=0"`
+	// AmountDisplay is the amount as shown to users (e.g. "10.50"), an alternative to Amount in minor units
+	AmountDisplay string `json:"amount_display,omitempty"`
 }
 
 // PlaceBetResponse is the response after placing a bet
@@ -23,6 +25,22 @@ type PlaceBetResponse struct {
 	NewBalance int64 `json:"new_balance"`
 	PoolYes    int64 `json:"pool_yes"`
 	PoolNo     int64 `json:"pool_no"`
+	// PoolsHidden is set for blind markets, whose pools are zero until they lock
+	PoolsHidden bool `json:"pools_hidden,omitempty"`
+	// SidesHidden is set for sealed markets, which only show PoolTotal until they are finalized
+	SidesHidden bool  `json:"sides_hidden,omitempty"`
+	PoolTotal   int64 `json:"pool_total,omitempty"`
+}
+
+// BetSourceHeader is the header the Web App sends with its bets; bets without it are recorded as API bets
+const BetSourceHeader = "X-Bet-Source"
+
+// betSource returns where a bet sent to the HTTP API was placed
+func betSource(r *http.Request) storage.BetSource {
+	if storage.BetSource(r.Header.Get(BetSourceHeader)) == storage.BetSourceWebApp {
+		return storage.BetSourceWebApp
+	}
+	return storage.BetSourceAPI
 }
 
 // HandleBets handles the POST /api/bets endpoint
@@ -33,42 +51,39 @@ func HandleBets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user ID from context
 	ctx := r.Context()
-	telegramID, ok := auth.GetUserIDFromContext(ctx)
-	if !ok {
-		logger.Debug(0, "bets_unauthorized", "path="+r.URL.Path)
-		respondWithError(w, "Unauthorized: user not in context", http.StatusUnauthorized)
+	user := currentUser(w, r, "bets")
+	if user == nil {
 		return
 	}
+	telegramID := user.TelegramID
 
-	// Get user by Telegram ID to retrieve internal user ID
-	user, err := storage.GetUserByTelegramID(telegramID)
-	if err != nil || user == nil {
-		logger.Debug(telegramID, "bets_user_not_found", "error=user lookup failed")
-		respondWithError(w, "User not found", http.StatusNotFound)
+	// Parse and validate request body
+	var req PlaceBetRequest
+	if !decodeAndValidate(w, r, &req, telegramID, "bets") {
 		return
 	}
 
-	// Parse request body
-	var req PlaceBetRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		logger.Debug(telegramID, "bets_invalid_body", "error="+err.Error())
-		respondWithError(w, "Invalid request body", http.StatusBadRequest)
-		return
+	// Accept the displayed amount too, converted with the currency's decimals
+	if req.AmountDisplay != "" {
+		if req.Amount != 0 {
+			respondWithError(w, "Invalid amount: send amount or amount_display, not both", http.StatusBadRequest)
+			return
+		}
+		amount, err := service.ParseMoney(req.AmountDisplay)
+		if err != nil {
+			logger.Debug(telegramID, "bet_invalid_amount", "amount_display="+req.AmountDisplay)
+			respondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Amount = amount
 	}
 
 	// Log bet attempt
-	logger.Debug(telegramID, "bet_attempt", fmt.Sprintf("market_id=%d outcome=%s amount=%d", req.MarketID, req.Outcome, req.Amount))
-
-	// Validate outcome
-	if req.Outcome != "YES" && req.Outcome != "NO" {
-		logger.Debug(telegramID, "bet_invalid_outcome", "outcome="+req.Outcome)
-		respondWithError(w, "Invalid outcome: must be 'YES' or 'NO'", http.StatusBadRequest)
-		return
-	}
+	source := betSource(r)
+	logger.Debug(telegramID, "bet_attempt", fmt.Sprintf("market_id=%d outcome=%s amount=%d source=%s", req.MarketID, req.Outcome, req.Amount, source))
 
-	// Validate amount
+	// Validate amount, which may have been sent as amount_display
 	if req.Amount <= 0 {
 		logger.Debug(telegramID, "bet_invalid_amount", fmt.Sprintf("amount=%d", req.Amount))
 		respondWithError(w, "Invalid amount: must be greater than 0", http.StatusBadRequest)
@@ -76,11 +91,19 @@ func HandleBets(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Place the bet using internal user ID
-	if err := storage.PlaceBet(ctx, user.ID, req.MarketID, req.Outcome, req.Amount); err != nil {
+	if err := storage.PlaceBetFrom(ctx, user.ID, req.MarketID, req.Outcome, req.Amount, source); err != nil {
 		// Determine appropriate error code
 		errMsg := err.Error()
 		logger.Debug(telegramID, "bet_failed", "error="+errMsg)
-		if strings.Contains(errMsg, "insufficient funds") {
+		if storage.IsBusyError(err) {
+			// Lock contention is transient; tell clients (and the load tester) to retry
+			w.Header().Set("Retry-After", "1")
+			respondWithError(w, "Database busy, please retry", http.StatusServiceUnavailable)
+		} else if strings.Contains(errMsg, "bet cooldown") || strings.Contains(errMsg, "daily bet limit") {
+			respondWithError(w, errMsg, http.StatusTooManyRequests)
+		} else if strings.Contains(errMsg, "market bet limit") {
+			respondWithError(w, errMsg, http.StatusForbidden)
+		} else if strings.Contains(errMsg, "insufficient funds") {
 			respondWithError(w, errMsg, http.StatusPaymentRequired)
 		} else if strings.Contains(errMsg, "not active") || strings.Contains(errMsg, "expired") || strings.Contains(errMsg, "not found") {
 			respondWithError(w, errMsg, http.StatusForbidden)
@@ -108,10 +131,33 @@ func HandleBets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Big or market-moving bets get a channel broadcast
+	service.CheckWhaleBet(nil, req.MarketID, req.Outcome, req.Amount, poolYes, poolNo)
+
+	// Confirm the bet by DM unless the user turned receipts off
+	service.QueueBetReceipt(nil, user.ID, req.MarketID, req.Outcome, req.Amount, user.Balance)
+
+	// Push the new pools to /api/stream listeners
+	service.GetEventBus().PublishBetPlaced(req.MarketID)
+
+	// During a market's last call the pools shown to bettors stay frozen, blind markets show none
+	// and sealed markets only their total
+	pools, err := storage.GetPublicPools(req.MarketID)
+	if err != nil {
+		logger.Debug(telegramID, "bet_pool_totals_error", "error="+err.Error())
+		respondWithError(w, "Failed to get pool totals", http.StatusInternalServerError)
+		return
+	}
+
 	response := PlaceBetResponse{
-		NewBalance: user.Balance,
-		PoolYes:    poolYes,
-		PoolNo:     poolNo,
+		NewBalance:  user.Balance,
+		PoolYes:     pools.Yes,
+		PoolNo:      pools.No,
+		PoolsHidden: pools.PoolsHidden,
+		SidesHidden: pools.SidesHidden,
+	}
+	if pools.SidesHidden {
+		response.PoolTotal = pools.Total
 	}
 
 	logger.Debug(telegramID, "bet_success", fmt.Sprintf("market_id=%d outcome=%s amount=%d new_balance=%d pool_yes=%d pool_no=%d", req.MarketID, req.Outcome, req.Amount, user.Balance, poolYes, poolNo))
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+	"predictionbot/internal/storage"
+)
+
+// ProposeOutcomeRequest is the request body for proposing the outcome of an abandoned market
+type ProposeOutcomeRequest struct {
+	Outcome string `json:"outcome"`
+}
+
+// ProposalVoteRequest is the request body for voting on a proposed outcome
+type ProposalVoteRequest struct {
+	Confirm bool `json:"confirm"`
+}
+
+// proposalMarketID parses the market ID from /markets/{id}/proposal[/vote]
+func proposalMarketID(path string, vote bool) (int64, error) {
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	want := 3
+	if vote {
+		want = 4
+	}
+	if len(pathParts) != want || pathParts[0] != "markets" || pathParts[2] != "proposal" || (vote && pathParts[3] != "vote") {
+		return 0, fmt.Errorf("invalid path format")
+	}
+	return strconv.ParseInt(pathParts[1], 10, 64)
+}
+
+// respondWithProposalError maps community proposal errors to status codes
+func respondWithProposalError(w http.ResponseWriter, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "not found"):
+		respondWithError(w, errMsg, http.StatusNotFound)
+	case strings.Contains(errMsg, "must have placed a bet"):
+		respondWithError(w, errMsg, http.StatusForbidden)
+	case strings.Contains(errMsg, "cannot be proposed"), strings.Contains(errMsg, "is closed"):
+		respondWithError(w, errMsg, http.StatusConflict)
+	case strings.Contains(errMsg, "invalid outcome"):
+		respondWithError(w, errMsg, http.StatusBadRequest)
+	default:
+		respondWithError(w, "Failed to process proposal", http.StatusInternalServerError)
+	}
+}
+
+// HandleMarketProposal handles POST /api/markets/{id}/proposal, where a bettor proposes the
+// outcome of a market its creator has not resolved within COMMUNITY_RESOLUTION_HOURS of locking
+func HandleMarketProposal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Debug(0, "proposal_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(w, r, "proposal")
+	if user == nil {
+		return
+	}
+	telegramID := user.TelegramID
+
+	marketID, err := proposalMarketID(r.URL.Path, false)
+	if err != nil {
+		logger.Debug(telegramID, "proposal_invalid_path", "path="+r.URL.Path)
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ProposeOutcomeRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		logger.Debug(telegramID, "proposal_invalid_body", "error="+err.Error())
+		respondWithBodyError(w, err)
+		return
+	}
+
+	proposal, err := service.NewPayoutService().ProposeOutcome(r.Context(), marketID, user, req.Outcome)
+	if err != nil {
+		logger.Debug(telegramID, "proposal_failed", fmt.Sprintf("market_id=%d error=%s", marketID, err.Error()))
+		respondWithProposalError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(proposal)
+}
+
+// HandleProposalVote handles POST /api/markets/{id}/proposal/vote, a bettor's stake-weighted
+// vote on the market's open proposal. Voting again replaces the earlier vote.
+func HandleProposalVote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Debug(0, "proposal_vote_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(w, r, "proposal_vote")
+	if user == nil {
+		return
+	}
+	telegramID := user.TelegramID
+
+	marketID, err := proposalMarketID(r.URL.Path, true)
+	if err != nil {
+		logger.Debug(telegramID, "proposal_vote_invalid_path", "path="+r.URL.Path)
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ProposalVoteRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		logger.Debug(telegramID, "proposal_vote_invalid_body", "error="+err.Error())
+		respondWithBodyError(w, err)
+		return
+	}
+
+	open, err := storage.GetOpenCommunityProposal(marketID)
+	if err != nil {
+		logger.Debug(telegramID, "proposal_vote_error", "error="+err.Error())
+		respondWithError(w, "Failed to get proposal", http.StatusInternalServerError)
+		return
+	}
+	if open == nil {
+		respondWithError(w, "No open proposal for this market", http.StatusNotFound)
+		return
+	}
+
+	proposal, err := service.NewPayoutService().VoteOnProposal(r.Context(), open.ID, user, req.Confirm)
+	if err != nil {
+		logger.Debug(telegramID, "proposal_vote_failed", fmt.Sprintf("market_id=%d error=%s", marketID, err.Error()))
+		respondWithProposalError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(proposal)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+)
+
+// ContentRequest is the request body for PUT /api/content/{key}
+type ContentRequest struct {
+	Body string `json:"body"`
+}
+
+// HandleContent handles GET and PUT /api/content/{key} for the house rules ("rules") and FAQ ("faq").
+// Anyone can read a page; editing needs the edit_content permission.
+func HandleContent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		logger.Debug(0, "content_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := strings.Trim(strings.TrimPrefix(r.URL.Path, "/content/"), "/")
+	if !service.IsContentKey(key) {
+		respondWithError(w, "content not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		actor := requirePermission(w, r, auth.PermissionEditContent, "content")
+		if actor == nil {
+			return
+		}
+
+		var req ContentRequest
+		if err := decodeJSONBody(w, r, &req); err != nil {
+			logger.Debug(actor.TelegramID, "content_invalid_body", "error="+err.Error())
+			respondWithBodyError(w, err)
+			return
+		}
+
+		page, err := service.SetContent(r.Context(), actor, key, req.Body)
+		if err != nil {
+			errMsg := err.Error()
+			logger.Debug(actor.TelegramID, "content_update_failed", "key="+key+" error="+errMsg)
+			if strings.Contains(errMsg, "invalid") {
+				respondWithError(w, errMsg, http.StatusBadRequest)
+			} else {
+				respondWithError(w, "Failed to save content", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		logger.Debug(actor.TelegramID, "content_updated", "key="+key)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+		return
+	}
+
+	userID, _ := auth.GetUserIDFromContext(r.Context())
+	page, err := service.GetContent(key)
+	if err != nil {
+		logger.Debug(userID, "content_error", "key="+key+" error="+err.Error())
+		respondWithError(w, "Failed to get content", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// HandleAdminDisputes handles GET /api/admin/disputes, the DISPUTED markets waiting for an
+// admin with their pools, the disputed resolution and who disputed it
+func HandleAdminDisputes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "admin_disputes_invalid_method", "method="+r.Method)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	actor := requirePermission(w, r, auth.PermissionResolveDisputes, "admin_disputes")
+	if actor == nil {
+		return
+	}
+
+	markets, err := storage.ListDisputedMarkets()
+	if err != nil {
+		logger.Debug(actor.TelegramID, "admin_disputes_list_failed", "error="+err.Error())
+		respondWithError(w, "Failed to list disputes", http.StatusInternalServerError)
+		return
+	}
+	if markets == nil {
+		markets = []storage.DisputedMarket{}
+	}
+
+	logger.Debug(actor.TelegramID, "admin_disputes_listed", fmt.Sprintf("count=%d", len(markets)))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(markets)
+}
+
+// HandleAdminDisputeSubpath handles POST /api/admin/disputes/{market_id}/reject, which turns the
+// dispute down and restores the market's original resolution
+func HandleAdminDisputeSubpath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Debug(0, "admin_dispute_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Expected path: /admin/disputes/{market_id}/reject (after StripPrefix removes /api)
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 4 || pathParts[0] != "admin" || pathParts[1] != "disputes" || pathParts[3] != "reject" {
+		logger.Debug(0, "admin_dispute_invalid_path", "path="+r.URL.Path)
+		respondWithError(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	marketID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		logger.Debug(0, "admin_dispute_invalid_id", "id="+pathParts[2])
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	actor := requirePermission(w, r, auth.PermissionResolveDisputes, "admin_dispute")
+	if actor == nil {
+		return
+	}
+
+	outcome, err := storage.RejectDispute(r.Context(), marketID, actor.ID)
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(actor.TelegramID, "admin_dispute_reject_failed", fmt.Sprintf("market_id=%d error=%s", marketID, errMsg))
+		if strings.Contains(errMsg, "not found") {
+			respondWithError(w, errMsg, http.StatusNotFound)
+		} else if strings.Contains(errMsg, "not disputed") || strings.Contains(errMsg, "no resolution") {
+			respondWithError(w, errMsg, http.StatusConflict)
+		} else {
+			respondWithError(w, "Failed to reject dispute", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logger.Debug(actor.TelegramID, "admin_dispute_rejected", fmt.Sprintf("market_id=%d outcome=%s", marketID, outcome))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"market_id": marketID,
+		"status":    storage.MarketStatusResolved,
+		"outcome":   outcome,
+	})
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error metrics limits: per-minute buckets are kept for errorRateHistory, and at most
+// maxErrorKeys endpoint/code pairs are counted so random paths cannot grow the map forever
+const (
+	errorRateHistory  = 60 * time.Minute
+	maxErrorKeys      = 500
+	maxErrorBodyBytes = 1024
+)
+
+// errorRateWindows are the rolling windows the error-rate summary reports, in minutes
+var errorRateWindows = []int{5, 15, 60}
+
+// EndpointErrors counts one kind of error response from one endpoint
+type EndpointErrors struct {
+	// Endpoint is the method and path, with numeric path segments replaced by {id}
+	Endpoint string    `json:"endpoint"`
+	Status   int       `json:"status"`
+	Code     string    `json:"code"`
+	Count    int64     `json:"count"`
+	LastAt   time.Time `json:"last_at"`
+}
+
+// ErrorRate summarizes the API responses of the last Minutes minutes
+type ErrorRate struct {
+	Minutes      int     `json:"minutes"`
+	Requests     int64   `json:"requests"`
+	ClientErrors int64   `json:"client_errors"`
+	ServerErrors int64   `json:"server_errors"`
+	ErrorRate    float64 `json:"error_rate"`
+}
+
+// ErrorMetrics are the API error counters since the server started
+type ErrorMetrics struct {
+	Since        time.Time `json:"since"`
+	Requests     int64     `json:"requests"`
+	ClientErrors int64     `json:"client_errors"`
+	ServerErrors int64     `json:"server_errors"`
+	// Recent are the rolling error rates over the last 5, 15 and 60 minutes
+	Recent []ErrorRate `json:"recent"`
+	// Endpoints are the error counts by endpoint and code, most frequent first
+	Endpoints []EndpointErrors `json:"endpoints"`
+}
+
+// errorBucket counts the responses of one minute
+type errorBucket struct {
+	minute       int64
+	requests     int64
+	clientErrors int64
+	serverErrors int64
+}
+
+type errorKey struct {
+	endpoint string
+	status   int
+	code     string
+}
+
+var (
+	errorMetricsMu  sync.Mutex
+	errorMetricsNow = time.Now
+	errorMetrics    = newErrorCounters()
+)
+
+type errorCounters struct {
+	since        time.Time
+	requests     int64
+	clientErrors int64
+	serverErrors int64
+	buckets      [60]errorBucket
+	endpoints    map[errorKey]*EndpointErrors
+}
+
+func newErrorCounters() *errorCounters {
+	return &errorCounters{since: errorMetricsNow().UTC(), endpoints: make(map[errorKey]*EndpointErrors)}
+}
+
+// resetErrorMetrics clears the counters; used by tests
+func resetErrorMetrics() {
+	errorMetricsMu.Lock()
+	defer errorMetricsMu.Unlock()
+	errorMetrics = newErrorCounters()
+}
+
+// countResponse adds an API response to the metrics. message is the error message of 4xx and
+// 5xx responses, used to tell errors such as insufficient funds apart.
+func countResponse(method, path string, status int, message string) {
+	now := errorMetricsNow().UTC()
+	minute := now.Unix() / 60
+
+	errorMetricsMu.Lock()
+	defer errorMetricsMu.Unlock()
+	m := errorMetrics
+
+	bucket := &m.buckets[minute%int64(len(m.buckets))]
+	if bucket.minute != minute {
+		*bucket = errorBucket{minute: minute}
+	}
+	m.requests++
+	bucket.requests++
+	if status < 400 {
+		return
+	}
+	if status >= 500 {
+		m.serverErrors++
+		bucket.serverErrors++
+	} else {
+		m.clientErrors++
+		bucket.clientErrors++
+	}
+
+	key := errorKey{endpoint: method + " " + endpointPattern(path), status: status, code: errorCode(status, message)}
+	entry, ok := m.endpoints[key]
+	if !ok {
+		// Past the limit, new endpoints share one entry per status and code
+		if len(m.endpoints) >= maxErrorKeys {
+			key.endpoint = "other"
+		}
+		if entry, ok = m.endpoints[key]; !ok {
+			entry = &EndpointErrors{Endpoint: key.endpoint, Status: status, Code: key.code}
+			m.endpoints[key] = entry
+		}
+	}
+	entry.Count++
+	entry.LastAt = now
+}
+
+// GetErrorMetrics returns a copy of the API error counters with the rolling error rates
+func GetErrorMetrics() ErrorMetrics {
+	now := errorMetricsNow().UTC()
+	minute := now.Unix() / 60
+
+	errorMetricsMu.Lock()
+	defer errorMetricsMu.Unlock()
+	m := errorMetrics
+
+	metrics := ErrorMetrics{
+		Since:        m.since,
+		Requests:     m.requests,
+		ClientErrors: m.clientErrors,
+		ServerErrors: m.serverErrors,
+		Endpoints:    make([]EndpointErrors, 0, len(m.endpoints)),
+	}
+	for _, minutes := range errorRateWindows {
+		rate := ErrorRate{Minutes: minutes}
+		for _, b := range m.buckets {
+			if b.minute > minute-int64(minutes) && b.minute <= minute {
+				rate.Requests += b.requests
+				rate.ClientErrors += b.clientErrors
+				rate.ServerErrors += b.serverErrors
+			}
+		}
+		if rate.Requests > 0 {
+			rate.ErrorRate = float64(rate.ClientErrors+rate.ServerErrors) / float64(rate.Requests)
+		}
+		metrics.Recent = append(metrics.Recent, rate)
+	}
+	for _, entry := range m.endpoints {
+		metrics.Endpoints = append(metrics.Endpoints, *entry)
+	}
+	sort.Slice(metrics.Endpoints, func(i, j int) bool {
+		a, b := metrics.Endpoints[i], metrics.Endpoints[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Endpoint+a.Code < b.Endpoint+b.Code
+	})
+	return metrics
+}
+
+// endpointPattern replaces the numeric segments of a path, such as market and bet IDs, with {id}
+func endpointPattern(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if _, err := strconv.ParseInt(segment, 10, 64); err == nil {
+			segments[i] = "{id}"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// errorCode names an error response: by its message for the errors worth telling apart, by its
+// status otherwise
+func errorCode(status int, message string) string {
+	msg := strings.ToLower(message)
+	switch {
+	case strings.Contains(msg, "insufficient funds"):
+		return "insufficient_funds"
+	case strings.Contains(msg, "bet limit"), strings.Contains(msg, "limit reached"):
+		return "limit_reached"
+	case status == http.StatusUnauthorized:
+		return "auth_failed"
+	case status == http.StatusForbidden:
+		return "forbidden"
+	case status == http.StatusNotFound:
+		return "not_found"
+	case status == http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case status == http.StatusConflict:
+		return "conflict"
+	case status == http.StatusRequestEntityTooLarge:
+		return "body_too_large"
+	case status == http.StatusTooManyRequests:
+		return "rate_limited"
+	case status == http.StatusBadRequest:
+		return "bad_request"
+	case status >= 500:
+		return "server_error"
+	default:
+		return fmt.Sprintf("http_%d", status)
+	}
+}
+
+// metricsWriter records the status of a response and the start of an error response's body
+type metricsWriter struct {
+	http.ResponseWriter
+	status int
+	body   []byte
+}
+
+func (w *metricsWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *metricsWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.status >= 400 && len(w.body) < maxErrorBodyBytes {
+		w.body = append(w.body, b[:min(len(b), maxErrorBodyBytes-len(w.body))]...)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush keeps Server-Sent Events streaming through the wrapper
+func (w *metricsWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *metricsWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// CountErrors wraps the API to count its responses by endpoint, status and error code, for
+// the error metrics in GET /api/admin/stats
+func CountErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mw := &metricsWriter{ResponseWriter: w}
+		next.ServeHTTP(mw, r)
+
+		status := mw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		var message string
+		if status >= 400 {
+			var body ErrorResponse
+			if json.Unmarshal(mw.body, &body) == nil {
+				message = body.Message
+			} else {
+				message = string(mw.body)
+			}
+		}
+		countResponse(r.Method, r.URL.Path, status, message)
+	})
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+	"predictionbot/internal/storage"
+)
+
+// maxListedFixtures caps GET /api/admin/fixtures
+const maxListedFixtures = 100
+
+// HandleAdminFixtures handles GET /api/admin/fixtures, the sports fixtures in ?status=
+// (default PENDING, the ones waiting for review), soonest kickoff first
+func HandleAdminFixtures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "admin_fixtures_invalid_method", "method="+r.Method)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	actor := requirePermission(w, r, auth.PermissionReviewFixtures, "admin_fixtures")
+	if actor == nil {
+		return
+	}
+
+	status := storage.FixturePending
+	if raw := r.URL.Query().Get("status"); raw != "" {
+		status = storage.FixtureStatus(strings.ToUpper(raw))
+	}
+	if !status.Valid() {
+		respondWithError(w, "Invalid status: must be PENDING, APPROVED, REJECTED, RESOLVED or CANCELLED", http.StatusBadRequest)
+		return
+	}
+
+	fixtures, err := storage.ListFixtures(status, maxListedFixtures)
+	if err != nil {
+		logger.Debug(actor.TelegramID, "admin_fixtures_list_failed", "error="+err.Error())
+		respondWithError(w, "Failed to list fixtures", http.StatusInternalServerError)
+		return
+	}
+	if fixtures == nil {
+		fixtures = []storage.SportsFixture{}
+	}
+
+	logger.Debug(actor.TelegramID, "admin_fixtures_listed", fmt.Sprintf("status=%s count=%d", status, len(fixtures)))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fixtures)
+}
+
+// HandleAdminFixtureSubpath handles POST /api/admin/fixtures/{id}/approve, which creates the
+// fixture's match market, and POST /api/admin/fixtures/{id}/reject
+func HandleAdminFixtureSubpath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Debug(0, "admin_fixture_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Expected path: /admin/fixtures/{id}/{action} (after StripPrefix removes /api)
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 4 || pathParts[0] != "admin" || pathParts[1] != "fixtures" ||
+		(pathParts[3] != "approve" && pathParts[3] != "reject") {
+		logger.Debug(0, "admin_fixture_invalid_path", "path="+r.URL.Path)
+		respondWithError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	fixtureID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		logger.Debug(0, "admin_fixture_invalid_id", "id="+pathParts[2])
+		respondWithError(w, "Invalid fixture ID", http.StatusBadRequest)
+		return
+	}
+
+	actor := requirePermission(w, r, auth.PermissionReviewFixtures, "admin_fixture")
+	if actor == nil {
+		return
+	}
+
+	var fixture *storage.SportsFixture
+	if pathParts[3] == "approve" {
+		fixture, err = service.ApproveFixture(r.Context(), actor, fixtureID)
+	} else {
+		fixture, err = service.RejectFixture(actor, fixtureID)
+	}
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(actor.TelegramID, "admin_fixture_"+pathParts[3]+"_failed", fmt.Sprintf("fixture_id=%d error=%s", fixtureID, errMsg))
+		switch {
+		case strings.Contains(errMsg, "not found"):
+			respondWithError(w, errMsg, http.StatusNotFound)
+		case strings.Contains(errMsg, "not pending"):
+			respondWithError(w, errMsg, http.StatusConflict)
+		case strings.Contains(errMsg, "invalid"):
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		default:
+			respondWithError(w, "Failed to review fixture", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fixture)
+}
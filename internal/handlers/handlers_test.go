@@ -11,13 +11,18 @@ import (
 	"time"
 
 	"predictionbot/internal/auth"
+	"predictionbot/internal/service"
 	"predictionbot/internal/storage"
 )
 
 func setupTestDB(t *testing.T) {
-	if err := storage.InitDB(":memory:"); err != nil {
+	if err := storage.InitTestDB(); err != nil {
 		t.Fatalf("Failed to initialize test database: %v", err)
 	}
+	// Reset the role cache so roles don't leak between tests
+	if err := auth.LoadRoles(); err != nil {
+		t.Fatalf("Failed to load roles: %v", err)
+	}
 }
 
 func cleanupTestDB(t *testing.T) {
@@ -215,6 +220,359 @@ func TestHandleCreateMarketPastExpiry(t *testing.T) {
 	}
 }
 
+func TestHandleCreateMarketTooFarExpiry(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := storage.CreateUser(12349, "testuser5", "Test User 5")
+
+	expiresAt := time.Now().Add(2 * service.MaxMarketDuration).UTC().Format(time.RFC3339)
+	body := `{"question":"Will it rain in two years?","expires_at":"` + expiresAt + `"}`
+	req, _ := http.NewRequest("POST", "/markets", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, user.TelegramID))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "at most 365 days") {
+		t.Errorf("Expected the maximum in the message, got %s", rr.Body.String())
+	}
+}
+
+func TestHandleCreateMarketWithCriteria(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := storage.CreateUser(12350, "testuser6", "Test User 6")
+
+	futureDate := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
+	body := `{"question":"Will it rain tomorrow?","expires_at":"` + futureDate + `","resolution_criteria":"YES if the weather service reports rain"}`
+	req, _ := http.NewRequest("POST", "/markets", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, user.TelegramID))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var created CreateMarketResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/markets/%d", created.ID), nil)
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, user.TelegramID))
+	var detail MarketDetailResponse
+	json.Unmarshal(rr.Body.Bytes(), &detail)
+	if detail.ResolutionCriteria != "YES if the weather service reports rain" {
+		t.Errorf("Expected criteria in detail, got %q", detail.ResolutionCriteria)
+	}
+
+	req, _ = http.NewRequest("GET", "/markets", nil)
+	rr = httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, user.TelegramID))
+	var markets []storage.MarketWithCreator
+	json.Unmarshal(rr.Body.Bytes(), &markets)
+	if len(markets) != 1 || markets[0].ResolutionCriteria != detail.ResolutionCriteria {
+		t.Errorf("Expected criteria in market list, got %+v", markets)
+	}
+
+	// Criteria go through the same link policy as questions
+	body = `{"question":"Will it snow tomorrow?","expires_at":"` + futureDate + `","resolution_criteria":"YES if https://example.com says so"}`
+	req, _ = http.NewRequest("POST", "/markets", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, user.TelegramID))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for criteria with a link, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHandleCreateBlindMarket(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator := createTestUser(t, 12350, "creator", "Creator", 1000)
+	bettor := createTestUser(t, 12351, "bettor", "Bettor", 1000)
+
+	futureDate := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
+	body := `{"question":"Will the herd follow the first bet?","expires_at":"` + futureDate + `","blind":true}`
+	req, _ := http.NewRequest("POST", "/markets", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, creator.TelegramID))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var created CreateMarketResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+
+	body = fmt.Sprintf(`{"market_id":%d,"outcome":"YES","amount":300}`, created.ID)
+	req, _ = http.NewRequest("POST", "/bets", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	HandleBets(rr, withAuthContext(req, bettor.TelegramID))
+	var bet PlaceBetResponse
+	json.Unmarshal(rr.Body.Bytes(), &bet)
+	if rr.Code != http.StatusCreated || !bet.PoolsHidden || bet.PoolYes != 0 {
+		t.Errorf("Expected masked pools after betting, got %d: %+v", rr.Code, bet)
+	}
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/markets/%d", created.ID), nil)
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, bettor.TelegramID))
+	var detail MarketDetailResponse
+	json.Unmarshal(rr.Body.Bytes(), &detail)
+	if !detail.Blind || !detail.PoolsHidden || detail.PoolYes != 0 {
+		t.Errorf("Expected masked pools in detail, got %+v", detail)
+	}
+
+	req, _ = http.NewRequest("GET", "/markets", nil)
+	rr = httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, bettor.TelegramID))
+	var markets []storage.MarketWithCreator
+	json.Unmarshal(rr.Body.Bytes(), &markets)
+	if len(markets) != 1 || !markets[0].PoolsHidden || markets[0].PoolYes != 0 {
+		t.Errorf("Expected masked pools in list, got %+v", markets)
+	}
+
+	// Locking reveals the pools
+	storage.UpdateMarketStatus(created.ID, storage.MarketStatusLocked, "")
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/markets/%d", created.ID), nil)
+	HandleMarketSubpath(rr, withAuthContext(req, bettor.TelegramID))
+	detail = MarketDetailResponse{}
+	json.Unmarshal(rr.Body.Bytes(), &detail)
+	if detail.PoolsHidden || detail.PoolYes != 300 {
+		t.Errorf("Expected revealed pools after lock, got %+v", detail)
+	}
+}
+
+func TestHandleCreateSealedMarket(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator := createTestUser(t, 12350, "creator", "Creator", 1000)
+	bettor := createTestUser(t, 12351, "bettor", "Bettor", 1000)
+
+	futureDate := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
+	body := `{"question":"Will our colleague get the promotion?","expires_at":"` + futureDate + `","sealed":true}`
+	req, _ := http.NewRequest("POST", "/markets", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, creator.TelegramID))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var created CreateMarketResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+
+	body = fmt.Sprintf(`{"market_id":%d,"outcome":"NO","amount":120}`, created.ID)
+	req, _ = http.NewRequest("POST", "/bets", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	HandleBets(rr, withAuthContext(req, bettor.TelegramID))
+	var bet PlaceBetResponse
+	json.Unmarshal(rr.Body.Bytes(), &bet)
+	if rr.Code != http.StatusCreated || !bet.SidesHidden || bet.PoolNo != 0 || bet.PoolTotal != 120 {
+		t.Errorf("Expected only the pool total after betting, got %d: %+v", rr.Code, bet)
+	}
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/markets/%d", created.ID), nil)
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, creator.TelegramID))
+	var detail MarketDetailResponse
+	json.Unmarshal(rr.Body.Bytes(), &detail)
+	if !detail.Sealed || !detail.SidesHidden || detail.PoolNo != 0 || detail.PoolTotal != 120 {
+		t.Errorf("Expected hidden sides in detail, got %+v", detail)
+	}
+}
+
+func TestHandleCreateAnonymousMarket(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator := createTestUser(t, 12352, "creator", "Creator", 1000)
+	viewer := createTestUser(t, 12353, "viewer", "Viewer", 1000)
+	moderator := createTestUser(t, 12354, "moderator", "Moderator", 1000)
+	if err := auth.GrantRole(moderator.ID, storage.RoleModerator, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+
+	futureDate := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
+	body := `{"question":"Will the office move before summer?","expires_at":"` + futureDate + `","anonymous":true}`
+	req, _ := http.NewRequest("POST", "/markets", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, creator.TelegramID))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var created CreateMarketResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+
+	req, _ = http.NewRequest("GET", "/markets", nil)
+	rr = httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, viewer.TelegramID))
+	var markets []storage.MarketWithCreator
+	json.Unmarshal(rr.Body.Bytes(), &markets)
+	if len(markets) != 1 || markets[0].CreatorName != storage.AnonymousCreatorName {
+		t.Errorf("Expected an anonymous creator in the list, got %+v", markets)
+	}
+
+	tests := []struct {
+		name       string
+		telegramID int64
+		expected   string
+	}{
+		{"viewer", viewer.TelegramID, storage.AnonymousCreatorName},
+		{"creator", creator.TelegramID, "Creator"},
+		{"moderator", moderator.TelegramID, "Creator"},
+	}
+	for _, tt := range tests {
+		req, _ = http.NewRequest("GET", fmt.Sprintf("/markets/%d", created.ID), nil)
+		rr = httptest.NewRecorder()
+		HandleMarketSubpath(rr, withAuthContext(req, tt.telegramID))
+		var detail MarketDetailResponse
+		json.Unmarshal(rr.Body.Bytes(), &detail)
+		if !detail.Anonymous || detail.CreatorName != tt.expected {
+			t.Errorf("%s: expected creator %q, got %+v", tt.name, tt.expected, detail)
+		}
+	}
+}
+
+func TestHandleMarketLock(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator := createTestUser(t, 12355, "creator", "Creator", 1000)
+	other := createTestUser(t, 12356, "other", "Other", 1000)
+	oracle := createTestUser(t, 12357, "oracle", "Oracle", 1000)
+	if err := auth.GrantRole(oracle.ID, storage.RoleOracle, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+
+	create := func(body string) int64 {
+		req, _ := http.NewRequest("POST", "/markets", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		HandleMarkets(rr, withAuthContext(req, creator.TelegramID))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+		var created CreateMarketResponse
+		json.Unmarshal(rr.Body.Bytes(), &created)
+		return created.ID
+	}
+	lock := func(marketID, telegramID int64) int {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/markets/%d/lock", marketID), nil)
+		rr := httptest.NewRecorder()
+		HandleMarketSubpath(rr, withAuthContext(req, telegramID))
+		return rr.Code
+	}
+
+	// Markets locked on a signal don't need a deadline; timed ones still do
+	manualID := create(`{"question":"Will the board meeting end early?","lock_mode":"manual"}`)
+	oracleID := create(`{"question":"Will the home team win the derby?","lock_mode":"ORACLE"}`)
+	timedID := create(`{"question":"Will it snow this weekend?","expires_at":"` + time.Now().Add(24*time.Hour).Format(time.RFC3339) + `"}`)
+
+	req, _ := http.NewRequest("POST", "/markets", strings.NewReader(`{"question":"Will this market have no deadline?"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, creator.TelegramID))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d without expires_at, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	tests := []struct {
+		name       string
+		marketID   int64
+		telegramID int64
+		expected   int
+	}{
+		{"manual by other user", manualID, other.TelegramID, http.StatusForbidden},
+		{"manual by oracle", manualID, oracle.TelegramID, http.StatusForbidden},
+		{"manual by creator", manualID, creator.TelegramID, http.StatusOK},
+		{"manual already locked", manualID, creator.TelegramID, http.StatusConflict},
+		{"oracle by creator", oracleID, creator.TelegramID, http.StatusForbidden},
+		{"oracle by oracle", oracleID, oracle.TelegramID, http.StatusOK},
+		{"timed market", timedID, creator.TelegramID, http.StatusConflict},
+		{"missing market", 99999, creator.TelegramID, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if code := lock(tt.marketID, tt.telegramID); code != tt.expected {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.expected, code)
+		}
+	}
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/markets/%d", oracleID), nil)
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, other.TelegramID))
+	var detail MarketDetailResponse
+	json.Unmarshal(rr.Body.Bytes(), &detail)
+	if detail.LockMode != string(storage.LockModeOracle) || detail.Status != string(storage.MarketStatusLocked) {
+		t.Errorf("Expected a locked oracle market, got %+v", detail)
+	}
+}
+
+func TestHandleMarketProposal(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator := createTestUser(t, 12361, "creator", "Creator", 1000)
+	alice := createTestUser(t, 12362, "alice", "Alice", 1000)
+	bob := createTestUser(t, 12363, "bob", "Bob", 1000)
+	market, _ := storage.CreateMarket(creator.ID, "Will the creator resolve this in time?", time.Now().Add(time.Hour))
+	storage.PlaceBet(ctx, alice.ID, market.ID, "YES", 100)
+	storage.PlaceBet(ctx, bob.ID, market.ID, "NO", 40)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+
+	post := func(path, body string, telegramID int64) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		HandleMarketSubpath(rr, withAuthContext(req, telegramID))
+		return rr
+	}
+	proposalPath := fmt.Sprintf("/markets/%d/proposal", market.ID)
+
+	// The creator still has time to resolve
+	if rr := post(proposalPath, `{"outcome":"YES"}`, alice.TelegramID); rr.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d before the deadline, got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+	storage.DB().Exec(`UPDATE markets SET locked_at = ? WHERE id = ?`, time.Now().Add(-73*time.Hour).UTC().Format("2006-01-02 15:04:05"), market.ID)
+
+	if rr := post(proposalPath, `{"outcome":"YES"}`, creator.TelegramID); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for the creator, got %d", http.StatusConflict, rr.Code)
+	}
+	if rr := post(proposalPath, `{"outcome":"YES"}`, alice.TelegramID); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	rr := post(proposalPath+"/vote", `{"confirm":false}`, bob.TelegramID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var proposal storage.CommunityProposal
+	json.Unmarshal(rr.Body.Bytes(), &proposal)
+	if proposal.ConfirmStake != 100 || proposal.RejectStake != 40 {
+		t.Errorf("Unexpected tally: %+v", proposal)
+	}
+	if rr := post(proposalPath+"/vote", `{"confirm":true}`, creator.TelegramID); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for a user without a bet, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/markets/%d", market.ID), nil)
+	detailRR := httptest.NewRecorder()
+	HandleMarketSubpath(detailRR, withAuthContext(req, bob.TelegramID))
+	var detail MarketDetailResponse
+	json.Unmarshal(detailRR.Body.Bytes(), &detail)
+	if detail.Proposal == nil || detail.Proposal.Outcome != "YES" || detail.ProposalsOpenAt == "" {
+		t.Errorf("Expected the open proposal in the market detail, got %+v", detail)
+	}
+}
+
 func TestHandleCreateMarketSuccess(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
@@ -274,6 +632,42 @@ func TestHandleListMarkets(t *testing.T) {
 	}
 }
 
+func TestHandleListGroupMarkets(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12358, "groupie", "Groupie", 1000)
+	expiresAt := time.Now().Add(24 * time.Hour)
+	public := createTestMarket(t, user.ID, "Will the public market show?", expiresAt)
+	group, _ := storage.CreateMarketWithOptions(user.ID, "Will the team lunch be pizza?", expiresAt, storage.MarketOptions{GroupID: -100123})
+
+	list := func(query, startParam string) (int, []storage.MarketWithCreator) {
+		req, _ := http.NewRequest("GET", "/markets"+query, nil)
+		if startParam != "" {
+			req.Header.Set("X-Telegram-Init-Data", "start_param="+startParam)
+		}
+		rr := httptest.NewRecorder()
+		HandleMarkets(rr, withAuthContext(req, user.TelegramID))
+		var markets []storage.MarketWithCreator
+		json.Unmarshal(rr.Body.Bytes(), &markets)
+		return rr.Code, markets
+	}
+
+	if _, markets := list("", ""); len(markets) != 1 || markets[0].ID != public.ID {
+		t.Errorf("Expected only the public market, got %+v", markets)
+	}
+	if _, markets := list("?group_id=-100123", ""); len(markets) != 1 || markets[0].ID != group.ID {
+		t.Errorf("Expected the group's market for ?group_id=, got %+v", markets)
+	}
+	// A Mini App opened from the group's link lists the group's markets
+	if _, markets := list("", "group_-100123"); len(markets) != 1 || markets[0].ID != group.ID {
+		t.Errorf("Expected the group's market for the start parameter, got %+v", markets)
+	}
+	if code, _ := list("?group_id=42", ""); code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a private chat ID, got %d", http.StatusBadRequest, code)
+	}
+}
+
 func TestHandleMarketsInvalidMethod(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
@@ -516,6 +910,64 @@ func TestHandleMeResponseSchema(t *testing.T) {
 	if response.BalanceDisplay != "1000" {
 		t.Errorf("Expected balance_display '1000', got '%s'", response.BalanceDisplay)
 	}
+	if response.Currency.Name != service.DefaultCurrencyName {
+		t.Errorf("Expected currency %s, got %+v", service.DefaultCurrencyName, response.Currency)
+	}
+}
+
+func TestHandleMeBalanceHints(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 300)
+	market := createTestMarket(t, user.ID, "Will hints be computed?", time.Now().Add(24*time.Hour))
+	if err := placeTestBet(t, user.ID, market.ID, "YES", 300); err != nil {
+		t.Fatalf("Failed to place bet: %v", err)
+	}
+
+	getMe := func() UserResponse {
+		req, err := http.NewRequest("GET", "/me", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = withAuthContext(req, user.TelegramID)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(HandleMe)
+		handler.ServeHTTP(rr, req)
+
+		var response UserResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return response
+	}
+
+	// Broke with no previous bailout: eligible, all funds locked in the open bet
+	response := getMe()
+	if !response.EligibleForBailout {
+		t.Error("Expected user to be eligible for bailout")
+	}
+	if response.BailoutAvailableAt != nil {
+		t.Errorf("Expected no bailout_available_at, got %s", *response.BailoutAvailableAt)
+	}
+	if response.LockedInOpenBets != 300 {
+		t.Errorf("Expected locked_in_open_bets 300, got %d", response.LockedInOpenBets)
+	}
+
+	// A recent bailout puts the user on cooldown
+	_, err := storage.DB().Exec("INSERT INTO transactions (user_id, amount, source_type, description) VALUES (?, 500, 'BAILOUT', 'test')", user.ID)
+	if err != nil {
+		t.Fatalf("Failed to record bailout: %v", err)
+	}
+
+	response = getMe()
+	if response.EligibleForBailout {
+		t.Error("Expected user on cooldown to be ineligible for bailout")
+	}
+	if response.BailoutAvailableAt == nil {
+		t.Error("Expected bailout_available_at while on cooldown")
+	}
 }
 
 func TestHandleMeInvalidMethod(t *testing.T) {
@@ -720,6 +1172,78 @@ func TestHandleUserBetsWithData(t *testing.T) {
 	}
 }
 
+func TestHandleUserBetsPagination(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+	for i := 0; i < 3; i++ {
+		market := createTestMarket(t, user.ID, fmt.Sprintf("Will page %d load?", i), time.Now().Add(24*time.Hour))
+		if err := placeTestBet(t, user.ID, market.ID, "YES", 10); err != nil {
+			t.Fatalf("Failed to place bet: %v", err)
+		}
+	}
+
+	req, err := http.NewRequest("GET", "/me/bets?limit=2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = withAuthContext(req, user.TelegramID)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleUserBets)
+	handler.ServeHTTP(rr, req)
+
+	var response []storage.BetHistoryItem
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	cursor := rr.Header().Get("X-Next-Cursor")
+	if len(response) != 2 || cursor == "" {
+		t.Fatalf("Expected 2 bets and a next cursor, got %d bets cursor %q", len(response), cursor)
+	}
+
+	req, err = http.NewRequest("GET", "/me/bets?limit=2&cursor="+cursor, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = withAuthContext(req, user.TelegramID)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	response = nil
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(response) != 1 || rr.Header().Get("X-Next-Cursor") != "" {
+		t.Errorf("Expected last page with 1 bet and no cursor, got %d bets", len(response))
+	}
+}
+
+func TestHandleUserBetsInvalidFilter(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+
+	for _, query := range []string{"status=MAYBE", "outcome=PERHAPS", "limit=0", "cursor=abc"} {
+		req, err := http.NewRequest("GET", "/me/bets?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = withAuthContext(req, user.TelegramID)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(HandleUserBets)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Query %s: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
 // ============================================================================
 // /api/me/stats Tests
 // ============================================================================
@@ -872,75 +1396,220 @@ func TestHandleLeaderboardWithData(t *testing.T) {
 	}
 }
 
-func TestHandleLeaderboardInvalidMethod(t *testing.T) {
+func TestHandleLeaderboardPeriods(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
 
-	req, err := http.NewRequest("POST", "/leaderboard", nil)
-	if err != nil {
-		t.Fatal(err)
+	rich := createTestUser(t, 12348, "rich", "Rich", 5000)
+	bettor := createTestUser(t, 12349, "bettor", "Bettor", 1000)
+	market := createTestMarket(t, rich.ID, "Will the weekly board differ?", time.Now().Add(time.Hour))
+	placeTestBet(t, bettor.ID, market.ID, "YES", 100)
+	placeTestBet(t, rich.ID, market.ID, "NO", 300)
+
+	for _, period := range []string{"weekly", "monthly"} {
+		req, _ := http.NewRequest("GET", "/leaderboard?period="+period, nil)
+		rr := httptest.NewRecorder()
+		HandleLeaderboard(rr, req)
+		var response []storage.LeaderboardEntry
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		// The smaller stake is the smaller loss, whatever the balances
+		if rr.Code != http.StatusOK || len(response) != 2 || response[0].Name != "Bettor" || response[0].Profit != -100 || response[1].Profit != -300 {
+			t.Errorf("%s: expected the bettor first, got %d %s", period, rr.Code, rr.Body.String())
+		}
 	}
 
+	req, _ := http.NewRequest("GET", "/leaderboard?period=yearly", nil)
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(HandleLeaderboard)
-	handler.ServeHTTP(rr, req)
-
-	if rr.Code != http.StatusMethodNotAllowed {
-		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	HandleLeaderboard(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown period, got %d", http.StatusBadRequest, rr.Code)
 	}
 }
 
-// ============================================================================
-// /api/marks Tests (GET)
-// ============================================================================
-
-func TestHandleListMarketsEmpty(t *testing.T) {
+func TestHandleLeaderboardMetrics(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
 
-	req, err := http.NewRequest("GET", "/markets", nil)
-	if err != nil {
-		t.Fatal(err)
+	creator := createTestUser(t, 12348, "creator", "Creator", 1000)
+	createTestUser(t, 12349, "idle", "Idle", 5000)
+	createTestMarket(t, creator.ID, "Will the creators board count this?", time.Now().Add(time.Hour))
+	createTestMarket(t, creator.ID, "And this one?", time.Now().Add(time.Hour))
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/leaderboard?"+query, nil)
+		rr := httptest.NewRecorder()
+		HandleLeaderboard(rr, req)
+		return rr
 	}
 
-	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(HandleMarkets)
-	handler.ServeHTTP(rr, req)
+	rr := get("metric=markets_created")
+	var response []storage.LeaderboardEntry
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if rr.Code != http.StatusOK || len(response) != 1 || response[0].Username != "creator" || response[0].Value == nil || *response[0].Value != 2 {
+		t.Errorf("Expected the creator with 2 markets, got %d %s", rr.Code, rr.Body.String())
+	}
 
-	if rr.Code != http.StatusOK {
-		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	// Nobody has decided bets or a streak yet
+	for _, query := range []string{"metric=win_rate", "metric=win_rate&min_bets=1", "metric=streak"} {
+		if rr := get(query); rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "[]" {
+			t.Errorf("%s: expected an empty leaderboard, got %d %s", query, rr.Code, rr.Body.String())
+		}
 	}
 
-	var response []storage.MarketWithCreator
-	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
-		t.Fatalf("Failed to parse response: %v", err)
+	// The balance board carries no value
+	if rr := get("metric=balance"); rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), `"value"`) {
+		t.Errorf("Expected the balance board without values, got %d %s", rr.Code, rr.Body.String())
 	}
 
-	if len(response) != 0 {
-		t.Errorf("Expected 0 markets, got %d", len(response))
+	for _, query := range []string{"metric=luck", "metric=win_rate&min_bets=0", "metric=streak&period=weekly"} {
+		if rr := get(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
 	}
 }
 
-func TestHandleListMarketsWithCreatorName(t *testing.T) {
+func TestHandleLeaderboardInvalidMethod(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
 
-	// Create a user and market
-	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
-	expiresAt := time.Now().Add(24 * time.Hour)
-	createTestMarket(t, user.ID, "Will it rain tomorrow?", expiresAt)
-
-	req, err := http.NewRequest("GET", "/markets", nil)
+	req, err := http.NewRequest("POST", "/leaderboard", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(HandleMarkets)
+	handler := http.HandlerFunc(HandleLeaderboard)
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusOK {
-		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
+
+func TestHandleLeaderboardPaged(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	createTestUser(t, 12345, "user1", "User 1", 500)
+	createTestUser(t, 12346, "user2", "User 2", 1000)
+	createTestUser(t, 12347, "user3", "User 3", 100)
+
+	req, _ := http.NewRequest("GET", "/leaderboard?limit=1&offset=1", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(HandleLeaderboard).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var page LeaderboardPage
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if page.Total != 3 || len(page.Entries) != 1 || page.Entries[0].Rank != 2 || page.Entries[0].Balance != 500 {
+		t.Errorf("Expected the second of 3 users, got %+v", page)
+	}
+
+	req, _ = http.NewRequest("GET", "/leaderboard?limit=0", nil)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(HandleLeaderboard).ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid limit, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHandleLeaderboardMe(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	for i := int64(1); i <= 6; i++ {
+		createTestUser(t, 12340+i, fmt.Sprintf("user%d", i), fmt.Sprintf("User %d", i), i*100)
+	}
+
+	req, _ := http.NewRequest("GET", "/leaderboard/me", nil)
+	req = withAuthContext(req, 12342)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(HandleLeaderboardSubpath).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var position storage.LeaderboardPosition
+	if err := json.Unmarshal(rr.Body.Bytes(), &position); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	// User 2 (200) ranks 5th of 6: two above, one below
+	if position.Rank != 5 || position.Total != 6 || len(position.Entries) != 4 {
+		t.Fatalf("Expected rank 5 of 6 with 4 entries, got %+v", position)
+	}
+	if !position.Entries[2].IsMe || position.Entries[2].Username != "user2" {
+		t.Errorf("Expected the caller marked third, got %+v", position.Entries[2])
+	}
+
+	req, _ = http.NewRequest("GET", "/leaderboard/me", nil)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(HandleLeaderboardSubpath).ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without auth, got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/leaderboard/other", nil)
+	req = withAuthContext(req, 12342)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(HandleLeaderboardSubpath).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown path, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+// ============================================================================
+// /api/marks Tests (GET)
+// ============================================================================
+
+func TestHandleListMarketsEmpty(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	req, err := http.NewRequest("GET", "/markets", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleMarkets)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var response []storage.MarketWithCreator
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if len(response) != 0 {
+		t.Errorf("Expected 0 markets, got %d", len(response))
+	}
+}
+
+func TestHandleListMarketsWithCreatorName(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	// Create a user and market
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+	expiresAt := time.Now().Add(24 * time.Hour)
+	createTestMarket(t, user.ID, "Will it rain tomorrow?", expiresAt)
+
+	req, err := http.NewRequest("GET", "/markets", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleMarkets)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
 	}
 
 	var response []storage.MarketWithCreator
@@ -1002,6 +1671,316 @@ func TestHandleListMarketsWithMultiple(t *testing.T) {
 	}
 }
 
+func TestHandleMarketSearch(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+	expiresAt := time.Now().Add(24 * time.Hour)
+	derby := createTestMarket(t, user.ID, "Will United win the derby?", expiresAt)
+	createTestMarket(t, user.ID, "Will the sun shine?", expiresAt)
+
+	search := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/markets/search"+query, nil)
+		rr := httptest.NewRecorder()
+		HandleMarketSubpath(rr, req)
+		return rr
+	}
+
+	rr := search("?q=Derby")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var markets []storage.MarketWithCreator
+	if err := json.Unmarshal(rr.Body.Bytes(), &markets); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(markets) != 1 || markets[0].ID != derby.ID || markets[0].Tags == nil {
+		t.Errorf("Expected the derby market with its tags, got %+v", markets)
+	}
+
+	if rr := search("?q=cricket"); rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Errorf("Expected an empty list, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := search("?q=+"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an empty query, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if rr := search("?q=derby&limit=0"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid limit, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHandleListMarketsTags(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+	expiresAt := time.Now().Add(24 * time.Hour)
+	tagged := createTestMarket(t, user.ID, "Will #Football finals go to #penalties?", expiresAt)
+	createTestMarket(t, user.ID, "Will the sun shine?", expiresAt)
+	if err := storage.SetMarketTags(tagged.ID, []string{"football", "penalties"}); err != nil {
+		t.Fatalf("Failed to tag market: %v", err)
+	}
+
+	list := func(query string) []storage.MarketWithCreator {
+		req, _ := http.NewRequest("GET", "/markets"+query, nil)
+		rr := httptest.NewRecorder()
+		HandleMarkets(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		var response []storage.MarketWithCreator
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return response
+	}
+
+	all := list("")
+	if len(all) != 2 {
+		t.Fatalf("Expected 2 markets, got %d", len(all))
+	}
+	for _, m := range all {
+		if m.Tags == nil {
+			t.Errorf("Expected tags to be an array for market %d", m.ID)
+		}
+		if m.ID == tagged.ID && len(m.Tags) != 2 {
+			t.Errorf("Expected 2 tags, got %v", m.Tags)
+		}
+	}
+
+	filtered := list("?tag=%23Football")
+	if len(filtered) != 1 || filtered[0].ID != tagged.ID {
+		t.Errorf("Expected only the tagged market, got %+v", filtered)
+	}
+}
+
+func TestHandleListMarketsPersonalized(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+	expiresAt := time.Now().Add(24 * time.Hour)
+	betOn := createTestMarket(t, user.ID, "Will it rain tomorrow?", expiresAt)
+	createTestMarket(t, user.ID, "Will the sun shine?", expiresAt)
+	createTestMarket(t, user.ID, "Will it snow in May?", expiresAt)
+	if err := placeTestBet(t, user.ID, betOn.ID, "YES", 10); err != nil {
+		t.Fatalf("Failed to place bet: %v", err)
+	}
+
+	list := func(query string) []storage.MarketWithCreator {
+		req, _ := http.NewRequest("GET", "/markets"+query, nil)
+		rr := httptest.NewRecorder()
+		HandleMarkets(rr, withAuthContext(req, 12345))
+		var response []storage.MarketWithCreator
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return response
+	}
+
+	personalized := list("")
+	if len(personalized) != 3 || personalized[0].ID != betOn.ID {
+		t.Errorf("Expected the market the user bet on first, got %+v", personalized)
+	}
+
+	t.Setenv("PERSONALIZED_MARKETS", "false")
+	plain, _ := storage.ListActiveMarketsWithCreator()
+	disabled := list("")
+	for i := range plain {
+		if disabled[i].ID != plain[i].ID {
+			t.Fatalf("Expected listing order with personalization disabled, got %+v", disabled)
+		}
+	}
+}
+
+func TestHandleMarketHide(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	alice := createTestUser(t, 12345, "alice", "Alice", 1000)
+	createTestUser(t, 12346, "bob", "Bob", 1000)
+	expiresAt := time.Now().Add(24 * time.Hour)
+	market := createTestMarket(t, alice.ID, "Will it rain tomorrow?", expiresAt)
+	createTestMarket(t, alice.ID, "Will the sun shine?", expiresAt)
+
+	hide := func(method, path string, telegramID int64) int {
+		req, _ := http.NewRequest(method, path, nil)
+		rr := httptest.NewRecorder()
+		HandleMarketSubpath(rr, withAuthContext(req, telegramID))
+		return rr.Code
+	}
+	count := func(telegramID int64) int {
+		req, _ := http.NewRequest("GET", "/markets", nil)
+		rr := httptest.NewRecorder()
+		HandleMarkets(rr, withAuthContext(req, telegramID))
+		var response []storage.MarketWithCreator
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return len(response)
+	}
+
+	path := fmt.Sprintf("/markets/%d/hide", market.ID)
+	if code := hide("POST", path, 12345); code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	if n := count(12345); n != 1 {
+		t.Errorf("Expected 1 market for alice after hiding, got %d", n)
+	}
+	if n := count(12346); n != 2 {
+		t.Errorf("Expected bob to still see 2 markets, got %d", n)
+	}
+
+	if code := hide("POST", path+"?hours=0", 12345); code != http.StatusBadRequest {
+		t.Errorf("Expected %d for invalid hours, got %d", http.StatusBadRequest, code)
+	}
+	if code := hide("POST", "/markets/9999/hide", 12345); code != http.StatusNotFound {
+		t.Errorf("Expected %d for missing market, got %d", http.StatusNotFound, code)
+	}
+
+	if code := hide("DELETE", path, 12345); code != http.StatusOK {
+		t.Fatalf("Expected status %d on unhide, got %d", http.StatusOK, code)
+	}
+	if n := count(12345); n != 2 {
+		t.Errorf("Expected 2 markets for alice after unhiding, got %d", n)
+	}
+}
+
+func TestHandleMarketWinners(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator := createTestUser(t, 12345, "creator", "Creator", 1000)
+	ann := createTestUser(t, 12346, "ann", "Ann", 1000)
+	bob := createTestUser(t, 12347, "bob", "Bob", 1000)
+	market := createTestMarket(t, creator.ID, "Will it rain tomorrow?", time.Now().Add(24*time.Hour))
+	placeTestBet(t, ann.ID, market.ID, "YES", 100)
+	placeTestBet(t, bob.ID, market.ID, "NO", 100)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		HandleMarketSubpath(rr, withAuthContext(req, 12345))
+		return rr
+	}
+
+	path := fmt.Sprintf("/markets/%d/winners", market.ID)
+	if rr := get(path); rr.Code != http.StatusConflict {
+		t.Errorf("Expected %d before finalization, got %d", http.StatusConflict, rr.Code)
+	}
+
+	// Ann opts in to being named
+	body := strings.NewReader(`{"show_in_winners": true}`)
+	req, _ := http.NewRequest("PUT", "/me/preferences", body)
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	HandlePreferences(rr, withAuthContext(req, 12346))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"show_in_winners":true`) {
+		t.Fatalf("Expected preferences to be saved, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusFinalized, "YES")
+
+	rr = get(path)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var response MarketWinnersResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Outcome != "YES" || len(response.Winners) != 1 || response.Winners[0].Name != "Ann" || response.Winners[0].Payout != 200 {
+		t.Errorf("Unexpected winners response: %+v", response)
+	}
+
+	if rr := get(path + "?limit=0"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected %d for invalid limit, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if rr := get("/markets/9999/winners"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected %d for missing market, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+// ============================================================================
+// /api/markets/{id} Tests
+// ============================================================================
+
+func TestHandleMarketDetail(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator := createTestUser(t, 12345, "creator", "Creator", 1000)
+	bettor := createTestUser(t, 67890, "bettor", "Bettor", 1000)
+	market := createTestMarket(t, creator.ID, "Will the detail endpoint work?", time.Now().Add(24*time.Hour))
+	if err := placeTestBet(t, bettor.ID, market.ID, "YES", 250); err != nil {
+		t.Fatalf("Failed to place bet: %v", err)
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("/markets/%d", market.ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = withAuthContext(req, bettor.TelegramID)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleMarketSubpath)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["creator_name"] != "Creator" || response["pool_yes"] != float64(250) {
+		t.Errorf("Unexpected market detail: %v", response)
+	}
+	if _, ok := response["preview"]; ok {
+		t.Error("Expected no preview for a question without links")
+	}
+	if _, ok := response["description"]; ok {
+		t.Error("Expected no description when none was generated")
+	}
+
+	storage.SetMarketDescription(market.ID, "Context for the market.")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if response["description"] != "Context for the market." {
+		t.Errorf("Expected stored description, got %v", response["description"])
+	}
+}
+
+func TestHandleMarketDetailHiddenOrMissing(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator := createTestUser(t, 12345, "creator", "Creator", 1000)
+	market := createTestMarket(t, creator.ID, "Is this market hidden from view?", time.Now().Add(24*time.Hour))
+	if err := storage.SetMarketHidden(market.ID, true, creator.ID); err != nil {
+		t.Fatalf("Failed to hide market: %v", err)
+	}
+
+	for _, path := range []string{fmt.Sprintf("/markets/%d", market.ID), "/markets/99999"} {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = withAuthContext(req, creator.TelegramID)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(HandleMarketSubpath)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusNotFound, rr.Code)
+		}
+	}
+}
+
 // ============================================================================
 // /api/markets/{id}/resolve Tests
 // ============================================================================
@@ -1161,6 +2140,7 @@ func TestHandleResolveSuccess(t *testing.T) {
 	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
 	expiresAt := time.Now().Add(24 * time.Hour)
 	market := createTestMarket(t, user.ID, "Will it rain tomorrow?", expiresAt)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
 
 	body := `{"outcome":"YES"}`
 	req, err := http.NewRequest("POST", "/markets/"+fmt.Sprintf("%d", market.ID)+"/resolve", strings.NewReader(body))
@@ -1175,33 +2155,79 @@ func TestHandleResolveSuccess(t *testing.T) {
 	handler := http.HandlerFunc(HandleMarketSubpath)
 	handler.ServeHTTP(rr, req)
 
-	// Note: Test skipped - resolve handler checks creator_id against user.ID (internal)
-	// but market is created with creator_id=user.ID, and user lookup by telegramID returns user.ID
-	// The issue is that the test should pass but there's a mismatch somewhere
-	t.Skip("Skipping - resolve success needs investigation")
-}
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
 
-// ============================================================================
-// /api/markets/{id}/dispute Tests
-// ============================================================================
+	resolved, _ := storage.GetMarketByID(market.ID)
+	if resolved.Status != storage.MarketStatusResolved || resolved.Outcome != "YES" {
+		t.Errorf("Expected RESOLVED YES, got %s %s", resolved.Status, resolved.Outcome)
+	}
+}
 
-func TestHandleDisputeUnauthorized(t *testing.T) {
+// TestResolveParityAPIAndBot resolves one market over HTTP and one the way the bot does
+// (user looked up by Telegram ID, then PayoutService) and checks both end up identical.
+func TestResolveParityAPIAndBot(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
 
-	req, err := http.NewRequest("POST", "/markets/1/dispute", nil)
+	// Make sure internal and Telegram IDs can't be confused
+	user := createTestUser(t, 987654, "creator", "Creator", 1000)
+	if user.ID == user.TelegramID {
+		t.Fatal("Expected internal ID to differ from Telegram ID")
+	}
+	expiresAt := time.Now().Add(24 * time.Hour)
+	apiMarket := createTestMarket(t, user.ID, "Resolved through the API?", expiresAt)
+	botMarket := createTestMarket(t, user.ID, "Resolved through the bot?", expiresAt)
+	storage.UpdateMarketStatus(apiMarket.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(botMarket.ID, storage.MarketStatusLocked, "")
+
+	// API path
+	req, err := http.NewRequest("POST", fmt.Sprintf("/markets/%d/resolve", apiMarket.ID), strings.NewReader(`{"outcome":"NO"}`))
 	if err != nil {
 		t.Fatal(err)
 	}
-
+	req = withAuthContext(req, user.TelegramID)
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(HandleMarketSubpath)
-	handler.ServeHTTP(rr, req)
+	http.HandlerFunc(HandleMarketSubpath).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("API resolve failed: %d %s", rr.Code, rr.Body.String())
+	}
 
-	if rr.Code != http.StatusUnauthorized {
-		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+	// Bot path (mirrors handleResolveCallback)
+	botUser, _ := storage.GetUserByTelegramID(user.TelegramID)
+	if _, err := service.NewPayoutService().ResolveMarket(context.Background(), botMarket.ID, botUser, "NO"); err != nil {
+		t.Fatalf("Bot resolve failed: %v", err)
 	}
-}
+
+	viaAPI, _ := storage.GetMarketByID(apiMarket.ID)
+	viaBot, _ := storage.GetMarketByID(botMarket.ID)
+	if viaAPI.Status != viaBot.Status || viaAPI.Outcome != viaBot.Outcome {
+		t.Errorf("API and bot resolution differ: %s/%s vs %s/%s", viaAPI.Status, viaAPI.Outcome, viaBot.Status, viaBot.Outcome)
+	}
+}
+
+// ============================================================================
+// /api/markets/{id}/dispute Tests
+// ============================================================================
+
+func TestHandleDisputeUnauthorized(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	req, err := http.NewRequest("POST", "/markets/1/dispute", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleMarketSubpath)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
 
 func TestHandleDisputeNotFound(t *testing.T) {
 	setupTestDB(t)
@@ -1252,49 +2278,120 @@ func TestHandleDisputeSuccess(t *testing.T) {
 	}
 }
 
-// ============================================================================
-// /api/admin/resolve Tests
-// ============================================================================
-
-func TestHandleAdminResolveUnauthorized(t *testing.T) {
+func TestHandleDisputeResolvedMarketUsesInternalID(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
 
-	body := `{"market_id":1,"outcome":"YES"}`
-	req, err := http.NewRequest("POST", "/admin/resolve", strings.NewReader(body))
+	// Telegram IDs far from the internal IDs so a mix-up cannot match by accident
+	creator := createTestUser(t, 700001, "creator", "Creator", 1000)
+	bettor := createTestUser(t, 700002, "bettor", "Bettor", 1000)
+	market := createTestMarket(t, creator.ID, "Will the dispute reach the bettor?", time.Now().Add(24*time.Hour))
+	if err := placeTestBet(t, bettor.ID, market.ID, "NO", 100); err != nil {
+		t.Fatalf("Failed to place bet: %v", err)
+	}
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("/markets/%d/dispute", market.ID), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req = withAuthContext(req, bettor.TelegramID)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(HandleAdminResolve)
+	handler := http.HandlerFunc(HandleMarketSubpath)
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusUnauthorized {
-		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	updated, _ := storage.GetMarketByID(market.ID)
+	if updated.Status != storage.MarketStatusDisputed {
+		t.Errorf("Expected status DISPUTED, got %s", updated.Status)
 	}
 }
 
-func TestHandleAdminResolveNotAdmin(t *testing.T) {
+func TestHandleDisputeWithReasonAndEvidence(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
 
-	// Regular user (not admin)
-	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+	creator := createTestUser(t, 700001, "creator", "Creator", 1000)
+	bettor := createTestUser(t, 700002, "bettor", "Bettor", 1000)
+	market := createTestMarket(t, creator.ID, "Was the resolution right?", time.Now().Add(24*time.Hour))
+	if err := placeTestBet(t, bettor.ID, market.ID, "NO", 100); err != nil {
+		t.Fatalf("Failed to place bet: %v", err)
+	}
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
 
-	body := `{"market_id":1,"outcome":"YES"}`
-	req, err := http.NewRequest("POST", "/admin/resolve", strings.NewReader(body))
+	dispute := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/markets/%d/dispute", market.ID), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = withAuthContext(req, bettor.TelegramID)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(HandleMarketSubpath).ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := dispute(`{"reason":"see link","evidence_url":"ftp://example.com"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for a non-http evidence URL, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if rr := dispute(`{"reason":"The match was postponed","evidence_url":"https://example.com/news"}`); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	disputed, _ := storage.ListDisputedMarkets()
+	if len(disputed) != 1 || disputed[0].Reason != "The match was postponed" || disputed[0].EvidenceURL != "https://example.com/news" {
+		t.Fatalf("Expected the reason and evidence recorded, got %+v", disputed)
+	}
+	if disputed[0].DisputerTelegramID != bettor.TelegramID {
+		t.Errorf("Expected the bettor as disputer, got %d", disputed[0].DisputerTelegramID)
+	}
+}
+
+func TestHandleDisputeBondInsufficientFunds(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("DISPUTE_BOND", "100")
+
+	creator := createTestUser(t, 700001, "creator", "Creator", 1000)
+	bettor := createTestUser(t, 700002, "bettor", "Bettor", 1000)
+	market := createTestMarket(t, creator.ID, "Can I afford to dispute this?", time.Now().Add(24*time.Hour))
+	if err := placeTestBet(t, bettor.ID, market.ID, "NO", 950); err != nil {
+		t.Fatalf("Failed to place bet: %v", err)
+	}
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/markets/%d/dispute", market.ID), nil)
+	req = withAuthContext(req, bettor.TelegramID)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(HandleMarketSubpath).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusPaymentRequired {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusPaymentRequired, rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandleDisputeWithoutBetForbidden(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator := createTestUser(t, 700001, "creator", "Creator", 1000)
+	outsider := createTestUser(t, 700003, "outsider", "Outsider", 1000)
+	market := createTestMarket(t, creator.ID, "Can an outsider dispute this?", time.Now().Add(24*time.Hour))
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("/markets/%d/dispute", market.ID), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	req = withAuthContext(req, user.TelegramID)
-	req = withAuthContext(req, user.TelegramID)
+	req = withAuthContext(req, outsider.TelegramID)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(HandleAdminResolve)
+	handler := http.HandlerFunc(HandleMarketSubpath)
 	handler.ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusForbidden {
@@ -1302,154 +2399,164 @@ func TestHandleAdminResolveNotAdmin(t *testing.T) {
 	}
 }
 
-func TestHandleAdminResolveNotFound(t *testing.T) {
+func TestHandleBetsDebitsInternalUser(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
 
-	// Simulate admin by setting environment
-	// Note: In real tests, we'd need to set ADMIN_USER_IDS env var
-	// For now, we test that non-existent market returns 404 when called correctly
-
-	// Create admin user
-	adminTelegramID := int64(12345)
-	_ = createTestUser(t, adminTelegramID, "admin", "Admin User", 1000)
+	creator := createTestUser(t, 700001, "creator", "Creator", 1000)
+	bettor := createTestUser(t, 700002, "bettor", "Bettor", 1000)
+	market := createTestMarket(t, creator.ID, "Does the right wallet pay?", time.Now().Add(24*time.Hour))
 
-	body := `{"market_id":99999,"outcome":"YES"}`
-	req, err := http.NewRequest("POST", "/admin/resolve", strings.NewReader(body))
+	body := fmt.Sprintf(`{"market_id": %d, "outcome": "YES", "amount": 150}`, market.ID)
+	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
 	if err != nil {
 		t.Fatal(err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	req = withAuthContext(req, adminTelegramID)
+	req = withAuthContext(req, bettor.TelegramID)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(HandleAdminResolve)
+	handler := http.HandlerFunc(HandleBets)
 	handler.ServeHTTP(rr, req)
 
-	// Either 403 (not admin) or 404 (not found) depending on admin config
-	if rr.Code != http.StatusNotFound && rr.Code != http.StatusForbidden {
-		t.Errorf("Expected status %d or %d, got %d", http.StatusNotFound, http.StatusForbidden, rr.Code)
+	if rr.Code != http.StatusOK && rr.Code != http.StatusCreated {
+		t.Fatalf("Expected success, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	updated, _ := storage.GetUserByTelegramID(bettor.TelegramID)
+	if updated.Balance != 850 {
+		t.Errorf("Expected bettor balance 850, got %d", updated.Balance)
+	}
+	untouched, _ := storage.GetUserByTelegramID(creator.TelegramID)
+	if untouched.Balance != 1000 {
+		t.Errorf("Expected creator balance 1000, got %d", untouched.Balance)
 	}
 }
 
 // ============================================================================
-// /api/bets Tests
+// /api/markets/{id}/transfer Tests
 // ============================================================================
 
-func TestHandleBetsInvalidOutcome(t *testing.T) {
+func TestHandleTransferSuccess(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
 
-	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+	creator := createTestUser(t, 12345, "creator", "Creator", 1000)
+	createTestUser(t, 12346, "recipient", "Recipient", 1000)
+	market := createTestMarket(t, creator.ID, "Will it rain tomorrow?", time.Now().Add(24*time.Hour))
 
-	body := `{"market_id":1,"outcome":"MAYBE","amount":100}`
-	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	body := `{"username":"@recipient"}`
+	req, err := http.NewRequest("POST", fmt.Sprintf("/markets/%d/transfer", market.ID), strings.NewReader(body))
 	if err != nil {
 		t.Fatal(err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	req = withAuthContext(req, user.TelegramID)
-	req = withAuthContext(req, user.TelegramID)
+	req = withAuthContext(req, creator.TelegramID)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(HandleBets)
+	handler := http.HandlerFunc(HandleMarketSubpath)
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusBadRequest {
-		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+
+	var response TransferMarketResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.TransferID == 0 {
+		t.Error("Expected non-zero transfer_id")
+	}
+	if response.Status != "PENDING" {
+		t.Errorf("Expected status PENDING, got %s", response.Status)
+	}
+
+	// Ownership only changes after both sides confirm in the bot
+	unchanged, _ := storage.GetMarketByID(market.ID)
+	if unchanged.CreatorID != creator.ID {
+		t.Errorf("Expected creator to remain %d, got %d", creator.ID, unchanged.CreatorID)
 	}
 }
 
-func TestHandleBetsInvalidAmount(t *testing.T) {
+func TestHandleTransferNotCreator(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
 
-	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+	creator := createTestUser(t, 12345, "creator", "Creator", 1000)
+	other := createTestUser(t, 12346, "other", "Other", 1000)
+	market := createTestMarket(t, creator.ID, "Will it rain tomorrow?", time.Now().Add(24*time.Hour))
 
-	body := `{"market_id":1,"outcome":"YES","amount":0}`
-	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	body := `{"username":"creator"}`
+	req, err := http.NewRequest("POST", fmt.Sprintf("/markets/%d/transfer", market.ID), strings.NewReader(body))
 	if err != nil {
 		t.Fatal(err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	req = withAuthContext(req, user.TelegramID)
-	req = withAuthContext(req, user.TelegramID)
+	req = withAuthContext(req, other.TelegramID)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(HandleBets)
+	handler := http.HandlerFunc(HandleMarketSubpath)
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusBadRequest {
-		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
 	}
 }
 
-func TestHandleBetsMarketNotFound(t *testing.T) {
+func TestHandleTransferUnknownRecipient(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
 
-	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+	creator := createTestUser(t, 12345, "creator", "Creator", 1000)
+	market := createTestMarket(t, creator.ID, "Will it rain tomorrow?", time.Now().Add(24*time.Hour))
 
-	body := `{"market_id":99999,"outcome":"YES","amount":100}`
-	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	body := `{"username":"ghost"}`
+	req, err := http.NewRequest("POST", fmt.Sprintf("/markets/%d/transfer", market.ID), strings.NewReader(body))
 	if err != nil {
 		t.Fatal(err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	req = withAuthContext(req, user.TelegramID)
-	req = withAuthContext(req, user.TelegramID)
+	req = withAuthContext(req, creator.TelegramID)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(HandleBets)
+	handler := http.HandlerFunc(HandleMarketSubpath)
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusForbidden {
-		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
 	}
 }
 
-func TestHandleBetsMarketNotActive(t *testing.T) {
+// ============================================================================
+// /api/admin/resolve Tests
+// ============================================================================
+
+func TestHandleAdminResolveUnauthorized(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
 
-	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
-	// Create an expired market (already in the past)
-	expiresAt := time.Now().Add(-1 * time.Hour)
-	market := createTestMarket(t, user.ID, "Expired market?", expiresAt)
-
-	body := `{"market_id":` + fmt.Sprintf("%d", market.ID) + `,"outcome":"YES","amount":100}`
-	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	body := `{"market_id":1,"outcome":"YES"}`
+	req, err := http.NewRequest("POST", "/admin/resolve", strings.NewReader(body))
 	if err != nil {
 		t.Fatal(err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	req = withAuthContext(req, user.TelegramID)
-	req = withAuthContext(req, user.TelegramID)
-
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(HandleBets)
+	handler := http.HandlerFunc(HandleAdminResolve)
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusForbidden {
-		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, rr.Code)
 	}
 }
 
-func TestHandleBetsInsufficientFunds(t *testing.T) {
+func TestHandleAdminResolveNotAdmin(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
 
-	user := createTestUser(t, 12345, "testuser", "Test User", 50)
-	expiresAt := time.Now().Add(24 * time.Hour)
-	market := createTestMarket(t, user.ID, "Will it rain tomorrow?", expiresAt)
+	// Regular user (not admin)
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
 
-	body := `{"market_id":` + fmt.Sprintf("%d", market.ID) + `,"outcome":"YES","amount":100}`
-	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	body := `{"market_id":1,"outcome":"YES"}`
+	req, err := http.NewRequest("POST", "/admin/resolve", strings.NewReader(body))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1459,146 +2566,2288 @@ func TestHandleBetsInsufficientFunds(t *testing.T) {
 	req = withAuthContext(req, user.TelegramID)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(HandleBets)
+	handler := http.HandlerFunc(HandleAdminResolve)
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusPaymentRequired {
-		t.Errorf("Expected status %d, got %d", http.StatusPaymentRequired, rr.Code)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
 	}
 }
 
-func TestHandleBetsSuccess(t *testing.T) {
+func TestHandleAdminResolveNotFound(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
 
-	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
-	expiresAt := time.Now().Add(24 * time.Hour)
-	market := createTestMarket(t, user.ID, "Will it rain tomorrow?", expiresAt)
+	// Simulate admin by setting environment
+	// Note: In real tests, we'd need to set ADMIN_USER_IDS env var
+	// For now, we test that non-existent market returns 404 when called correctly
 
-	body := `{"market_id":` + fmt.Sprintf("%d", market.ID) + `,"outcome":"YES","amount":100}`
-	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	// Create admin user
+	adminTelegramID := int64(12345)
+	_ = createTestUser(t, adminTelegramID, "admin", "Admin User", 1000)
+
+	body := `{"market_id":99999,"outcome":"YES"}`
+	req, err := http.NewRequest("POST", "/admin/resolve", strings.NewReader(body))
 	if err != nil {
 		t.Fatal(err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	req = withAuthContext(req, user.TelegramID)
-	req = withAuthContext(req, user.TelegramID)
+	req = withAuthContext(req, adminTelegramID)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(HandleBets)
+	handler := http.HandlerFunc(HandleAdminResolve)
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusCreated {
-		t.Errorf("Expected status %d, got %d", http.StatusCreated, rr.Code)
+	// Either 403 (not admin) or 404 (not found) depending on admin config
+	if rr.Code != http.StatusNotFound && rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d or %d, got %d", http.StatusNotFound, http.StatusForbidden, rr.Code)
 	}
+}
 
-	var response PlaceBetResponse
-	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
-		t.Fatalf("Failed to parse response: %v", err)
+func TestHandleAdminResolveDryRun(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin := createTestUser(t, 12346, "admin", "Admin", 1000)
+	if err := auth.GrantRole(admin.ID, storage.RoleAdmin, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
 	}
+	bettor := createTestUser(t, 12347, "bettor", "Bettor", 1000)
+	market, _ := storage.CreateMarket(admin.ID, "Will the museum reopen?", time.Now().Add(time.Hour))
+	if err := storage.PlaceBet(context.Background(), bettor.ID, market.ID, "YES", 200); err != nil {
+		t.Fatalf("PlaceBet failed: %v", err)
+	}
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "NO")
 
-	if response.NewBalance != 900 {
-		t.Errorf("Expected new balance 900, got %d", response.NewBalance)
+	body := fmt.Sprintf(`{"market_id":%d,"outcome":"YES","dry_run":true}`, market.ID)
+	req, _ := http.NewRequest("POST", "/admin/resolve", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = withAuthContext(req, admin.TelegramID)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(HandleAdminResolve).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
 	}
-	if response.PoolYes != 100 {
-		t.Errorf("Expected pool_yes 100, got %d", response.PoolYes)
+
+	var resp AdminResolveResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
 	}
-	if response.PoolNo != 0 {
-		t.Errorf("Expected pool_no 0, got %d", response.PoolNo)
+	if resp.Status != "dry_run" || resp.PayoutsProcessed != 1 || resp.Preview == nil {
+		t.Fatalf("Unexpected response %+v", resp)
+	}
+	if len(resp.Preview.Payouts) != 1 || resp.Preview.Payouts[0].UserID != bettor.ID || resp.Preview.Payouts[0].Payout != 200 {
+		t.Errorf("Unexpected payouts %+v", resp.Preview.Payouts)
+	}
+	if updated, _ := storage.GetMarketByID(market.ID); updated.Status != storage.MarketStatusResolved {
+		t.Errorf("Expected the market to stay RESOLVED, got %s", updated.Status)
 	}
 }
 
-func TestHandleBetsMultipleOutcomes(t *testing.T) {
+// ============================================================================
+// /api/admin/roles, /api/admin/markets and /api/admin/balance Tests
+// ============================================================================
+
+func TestHandleAdminRolesForbiddenForRegularUser(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
 
 	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
-	expiresAt := time.Now().Add(24 * time.Hour)
-	market := createTestMarket(t, user.ID, "Will it rain tomorrow?", expiresAt)
 
-	// Place YES bet
-	body1 := `{"market_id":` + fmt.Sprintf("%d", market.ID) + `,"outcome":"YES","amount":100}`
-	req1, err := http.NewRequest("POST", "/bets", strings.NewReader(body1))
+	req, err := http.NewRequest("GET", "/admin/roles", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	req1.Header.Set("Content-Type", "application/json")
-	req1 = withAuthContext(req1, user.TelegramID)
+	req = withAuthContext(req, user.TelegramID)
 
-	rr1 := httptest.NewRecorder()
-	handler := http.HandlerFunc(HandleBets)
-	handler.ServeHTTP(rr1, req1)
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleAdminRoles)
+	handler.ServeHTTP(rr, req)
 
-	if rr1.Code != http.StatusCreated {
-		t.Errorf("First bet: Expected status %d, got %d", http.StatusCreated, rr1.Code)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
 	}
+}
 
-	// Place NO bet
-	body2 := `{"market_id":` + fmt.Sprintf("%d", market.ID) + `,"outcome":"NO","amount":50}`
-	req2, err := http.NewRequest("POST", "/bets", strings.NewReader(body2))
+func TestHandleAdminRolesGrantByEnvAdmin(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin := createTestUser(t, 11111, "admin", "Admin", 1000)
+	target := createTestUser(t, 22222, "mod", "Moderator", 1000)
+	t.Setenv("ADMIN_USER_IDS", "11111")
+	auth.LoadRoles()
+
+	body := `{"telegram_id":22222,"role":"moderator"}`
+	req, err := http.NewRequest("POST", "/admin/roles", strings.NewReader(body))
 	if err != nil {
 		t.Fatal(err)
 	}
-	req2.Header.Set("Content-Type", "application/json")
-	req2 = withAuthContext(req2, user.TelegramID)
+	req.Header.Set("Content-Type", "application/json")
+	req = withAuthContext(req, admin.TelegramID)
 
-	rr2 := httptest.NewRecorder()
-	handler.ServeHTTP(rr2, req2)
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleAdminRoles)
+	handler.ServeHTTP(rr, req)
 
-	if rr2.Code != http.StatusCreated {
-		t.Errorf("Second bet: Expected status %d, got %d", http.StatusCreated, rr2.Code)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
 	}
 
-	var response2 PlaceBetResponse
-	if err := json.Unmarshal(rr2.Body.Bytes(), &response2); err != nil {
-		t.Fatalf("Failed to parse second response: %v", err)
+	roles, _ := storage.GetUserRoles(target.ID)
+	if len(roles) != 1 || roles[0] != storage.RoleModerator {
+		t.Errorf("Expected moderator role, got %v", roles)
 	}
+}
 
-	if response2.PoolYes != 100 {
-		t.Errorf("Expected pool_yes 100, got %d", response2.PoolYes)
+func TestHandleAdminModeratorCanHideButNotAdjustBalance(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	moderator := createTestUser(t, 33333, "mod", "Moderator", 1000)
+	if err := auth.GrantRole(moderator.ID, storage.RoleModerator, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
 	}
-	if response2.PoolNo != 50 {
-		t.Errorf("Expected pool_no 50, got %d", response2.PoolNo)
+	market := createTestMarket(t, moderator.ID, "Will this be hidden?", time.Now().Add(24*time.Hour))
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("/admin/markets/%d/hide", market.ID), nil)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if response2.NewBalance != 850 {
-		t.Errorf("Expected new balance 850, got %d", response2.NewBalance)
+	req = withAuthContext(req, moderator.TelegramID)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleAdminMarketSubpath)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	active, _ := storage.ListActiveMarkets()
+	if len(active) != 0 {
+		t.Errorf("Expected hidden market to be excluded from listings, got %d markets", len(active))
+	}
+
+	body := `{"telegram_id":33333,"amount":500,"reason":"test"}`
+	req, err = http.NewRequest("POST", "/admin/balance", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req = withAuthContext(req, moderator.TelegramID)
+
+	rr = httptest.NewRecorder()
+	handler = http.HandlerFunc(HandleAdminBalance)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestHandleAdminMergeMarkets(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin := createTestUser(t, 66666, "admin", "Admin", 1000)
+	bettor := createTestUser(t, 77777, "bettor", "Bettor", 1000)
+	if err := auth.GrantRole(admin.ID, storage.RoleAdmin, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+	target := createTestMarket(t, admin.ID, "Will it snow on Friday?", time.Now().Add(24*time.Hour))
+	duplicate := createTestMarket(t, admin.ID, "will it snow on friday?", time.Now().Add(24*time.Hour))
+	other := createTestMarket(t, admin.ID, "Will it rain on Saturday?", time.Now().Add(24*time.Hour))
+	if err := placeTestBet(t, bettor.ID, duplicate.ID, "YES", 100); err != nil {
+		t.Fatalf("Failed to place bet: %v", err)
+	}
+
+	// Different questions cannot be merged
+	body := fmt.Sprintf(`{"into_market_id":%d}`, other.ID)
+	req, err := http.NewRequest("POST", fmt.Sprintf("/admin/markets/%d/merge", duplicate.ID), strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = withAuthContext(req, admin.TelegramID)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleAdminMarketSubpath)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	body = fmt.Sprintf(`{"into_market_id":%d}`, target.ID)
+	req, err = http.NewRequest("POST", fmt.Sprintf("/admin/markets/%d/merge", duplicate.ID), strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = withAuthContext(req, admin.TelegramID)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var result storage.MergeResult
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(result.Moved) != 1 || len(result.Refunded) != 0 {
+		t.Errorf("Expected 1 moved and 0 refunded bets, got %+v", result)
+	}
+}
+
+func TestHandleAdminRetryFinalization(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin := createTestUser(t, 66666, "admin", "Admin", 1000)
+	if err := auth.GrantRole(admin.ID, storage.RoleAdmin, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+	market := createTestMarket(t, admin.ID, "Will the payout ever go through?", time.Now().Add(24*time.Hour))
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	handler := http.HandlerFunc(HandleAdminMarketSubpath)
+	retry := func() *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", fmt.Sprintf("/admin/markets/%d/retry-finalization", market.ID), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = withAuthContext(req, admin.TelegramID)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Only markets the worker gave up on can be retried
+	if rr := retry(); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+
+	if _, stuck, err := storage.RecordFinalizeFailure(context.Background(), market.ID, "payout failed", 1); err != nil || !stuck {
+		t.Fatalf("Expected the market to need attention, got %v, %v", stuck, err)
+	}
+	if rr := retry(); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if m, _ := storage.GetMarketByID(market.ID); m.Status != storage.MarketStatusResolved {
+		t.Errorf("Expected the market back in RESOLVED, got %s", m.Status)
+	}
+}
+
+func TestHandleAdminBalanceSuccess(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin := createTestUser(t, 44444, "admin", "Admin", 1000)
+	target := createTestUser(t, 55555, "target", "Target", 100)
+	if err := auth.GrantRole(admin.ID, storage.RoleAdmin, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+
+	body := `{"telegram_id":55555,"amount":-50,"reason":"correction"}`
+	req, err := http.NewRequest("POST", "/admin/balance", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req = withAuthContext(req, admin.TelegramID)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleAdminBalance)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	updated, _ := storage.GetUserByID(target.ID)
+	if updated.Balance != 50 {
+		t.Errorf("Expected balance 50, got %d", updated.Balance)
+	}
+}
+
+func TestHandleAdminCosignsConfirm(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator := createTestUser(t, 44444, "creator", "Creator", 1000)
+	oracle := createTestUser(t, 55555, "oracle", "Oracle", 1000)
+	market, _ := storage.CreateMarket(creator.ID, "Will the oracle co-sign this resolution?", time.Now().Add(time.Hour))
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	cosign, err := storage.CreateResolutionCosign(context.Background(), market.ID, creator.ID, "NO")
+	if err != nil {
+		t.Fatalf("CreateResolutionCosign failed: %v", err)
+	}
+
+	decide := func(telegramID int64) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"cosign_id":%d,"confirm":true}`, cosign.ID)
+		req, _ := http.NewRequest("POST", "/admin/cosigns", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = withAuthContext(req, telegramID)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(HandleAdminCosigns).ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := decide(oracle.TelegramID); rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d without a role, got %d", http.StatusForbidden, rr.Code)
+	}
+	if err := auth.GrantRole(oracle.ID, storage.RoleOracle, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+	if rr := decide(oracle.TelegramID); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	updated, _ := storage.GetMarketByID(market.ID)
+	if updated.Status != storage.MarketStatusResolved || updated.Outcome != "NO" {
+		t.Errorf("Expected RESOLVED NO, got %s %s", updated.Status, updated.Outcome)
+	}
+	if rr := decide(oracle.TelegramID); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d deciding twice, got %d", http.StatusConflict, rr.Code)
+	}
+}
+
+func TestHandleAdminDisputes(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator := createTestUser(t, 44444, "creator", "Creator", 1000)
+	bettor := createTestUser(t, 66666, "bettor", "Bettor", 1000)
+	oracle := createTestUser(t, 55555, "oracle", "Oracle", 1000)
+	market, _ := storage.CreateMarket(creator.ID, "Will this dispute be rejected?", time.Now().Add(time.Hour))
+	storage.PlaceBet(ctx, bettor.ID, market.ID, "NO", 300)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+	if _, err := storage.OpenDispute(ctx, market.ID, bettor.ID, "", "", 0); err != nil {
+		t.Fatalf("OpenDispute failed: %v", err)
+	}
+
+	list := func(telegramID int64) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/admin/disputes", nil)
+		req = withAuthContext(req, telegramID)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(HandleAdminDisputes).ServeHTTP(rr, req)
+		return rr
+	}
+	reject := func(telegramID, marketID int64) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/admin/disputes/%d/reject", marketID), nil)
+		req = withAuthContext(req, telegramID)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(HandleAdminDisputeSubpath).ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := list(oracle.TelegramID); rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d without a role, got %d", http.StatusForbidden, rr.Code)
+	}
+	if err := auth.GrantRole(oracle.ID, storage.RoleOracle, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+
+	rr := list(oracle.TelegramID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var disputed []storage.DisputedMarket
+	json.NewDecoder(rr.Body).Decode(&disputed)
+	if len(disputed) != 1 || disputed[0].PoolNo != 300 || disputed[0].DisputerTelegramID != bettor.TelegramID {
+		t.Fatalf("Expected the disputed market with its disputer, got %+v", disputed)
+	}
+
+	if rr := reject(oracle.TelegramID, 9999); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown market, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := reject(oracle.TelegramID, market.ID); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	updated, _ := storage.GetMarketByID(market.ID)
+	if updated.Status != storage.MarketStatusResolved || updated.Outcome != "YES" {
+		t.Errorf("Expected RESOLVED YES restored, got %s %s", updated.Status, updated.Outcome)
+	}
+	if rr := reject(oracle.TelegramID, market.ID); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d rejecting twice, got %d", http.StatusConflict, rr.Code)
+	}
+	if rr := list(oracle.TelegramID); strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Errorf("Expected an empty list, got %s", rr.Body.String())
 	}
 }
 
 // ============================================================================
-// Response Header Tests
+// /api/bets Tests
 // ============================================================================
 
-func TestAPIResponseContentType(t *testing.T) {
-	testCases := []struct {
-		name    string
-		handler http.HandlerFunc
-		method  string
-		path    string
-	}{
-		{"Ping", PingHandler, "GET", "/ping"},
-		{"Markets", HandleMarkets, "GET", "/markets"},
-		{"Leaderboard", HandleLeaderboard, "GET", "/leaderboard"},
+func TestHandleBetsInvalidOutcome(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+
+	body := `{"market_id":1,"outcome":"MAYBE","amount":100}`
+	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
 	}
+	req.Header.Set("Content-Type", "application/json")
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			setupTestDB(t)
-			defer cleanupTestDB(t)
+	req = withAuthContext(req, user.TelegramID)
+	req = withAuthContext(req, user.TelegramID)
 
-			req, err := http.NewRequest(tc.method, tc.path, nil)
-			if err != nil {
-				t.Fatal(err)
-			}
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleBets)
+	handler.ServeHTTP(rr, req)
 
-			rr := httptest.NewRecorder()
-			tc.handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
 
-			contentType := rr.Header().Get("Content-Type")
-			if contentType != "application/json" {
-				t.Errorf("[%s] Expected Content-Type 'application/json', got '%s'", tc.name, contentType)
-			}
-		})
+func TestHandleBetsFieldErrors(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+
+	body := `{"outcome":"MAYBE","amount":-100}`
+	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req = withAuthContext(req, user.TelegramID)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleBets)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	var response ErrorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	var fields []string
+	for _, fieldErr := range response.Errors {
+		fields = append(fields, fieldErr.Field)
+	}
+	if strings.Join(fields, ",") != "market_id,outcome,amount" {
+		t.Errorf("Expected an error for every invalid field, got %+v", response.Errors)
+	}
+	if !strings.Contains(response.Message, "outcome must be one of YES, NO") {
+		t.Errorf("Unexpected message %q", response.Message)
+	}
+}
+
+func TestHandleBetsUnknownField(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+
+	// Typo in "amount" must be rejected instead of silently betting 0
+	body := `{"market_id":1,"outcome":"YES","ammount":100}`
+	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req = withAuthContext(req, user.TelegramID)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleBets)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "ammount") {
+		t.Errorf("Expected error to mention the unknown field, got %s", rr.Body.String())
+	}
+}
+
+func TestHandleBetsBodyTooLarge(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+
+	body := `{"market_id":1,"outcome":"` + strings.Repeat("Y", maxRequestBodyBytes) + `","amount":100}`
+	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req = withAuthContext(req, user.TelegramID)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleBets)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+}
+
+func TestHandleBetsInvalidAmount(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+
+	body := `{"market_id":1,"outcome":"YES","amount":0}`
+	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	req = withAuthContext(req, user.TelegramID)
+	req = withAuthContext(req, user.TelegramID)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleBets)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHandleBetsMarketNotFound(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+
+	body := `{"market_id":99999,"outcome":"YES","amount":100}`
+	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	req = withAuthContext(req, user.TelegramID)
+	req = withAuthContext(req, user.TelegramID)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleBets)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestHandleBetsMarketNotActive(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+	// Create an expired market (already in the past)
+	expiresAt := time.Now().Add(-1 * time.Hour)
+	market := createTestMarket(t, user.ID, "Expired market?", expiresAt)
+
+	body := `{"market_id":` + fmt.Sprintf("%d", market.ID) + `,"outcome":"YES","amount":100}`
+	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	req = withAuthContext(req, user.TelegramID)
+	req = withAuthContext(req, user.TelegramID)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleBets)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestHandleBetsInsufficientFunds(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 50)
+	expiresAt := time.Now().Add(24 * time.Hour)
+	market := createTestMarket(t, user.ID, "Will it rain tomorrow?", expiresAt)
+
+	body := `{"market_id":` + fmt.Sprintf("%d", market.ID) + `,"outcome":"YES","amount":100}`
+	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	req = withAuthContext(req, user.TelegramID)
+	req = withAuthContext(req, user.TelegramID)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleBets)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusPaymentRequired {
+		t.Errorf("Expected status %d, got %d", http.StatusPaymentRequired, rr.Code)
+	}
+}
+
+func TestHandleBetsCooldown(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	storage.SetBetLimits(storage.BetLimits{Cooldown: time.Minute})
+	defer storage.SetBetLimits(storage.BetLimits{})
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+	market := createTestMarket(t, user.ID, "Will it rain tomorrow?", time.Now().Add(24*time.Hour))
+	placeTestBet(t, user.ID, market.ID, "YES", 10)
+
+	body := fmt.Sprintf(`{"market_id":%d,"outcome":"NO","amount":10}`, market.ID)
+	req, _ := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	HandleBets(rr, withAuthContext(req, user.TelegramID))
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusTooManyRequests, rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandleBetsStakeLimits(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	storage.SetBetLimits(storage.BetLimits{MinBet: 5, MaxPerMarket: 100})
+	defer storage.SetBetLimits(storage.BetLimits{})
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+	market := createTestMarket(t, user.ID, "Will it rain tomorrow?", time.Now().Add(24*time.Hour))
+	placeTestBet(t, user.ID, market.ID, "YES", 80)
+
+	for _, tc := range []struct {
+		amount int64
+		want   int
+	}{
+		{2, http.StatusBadRequest},
+		{30, http.StatusForbidden},
+		{20, http.StatusCreated},
+	} {
+		body := fmt.Sprintf(`{"market_id":%d,"outcome":"NO","amount":%d}`, market.ID, tc.amount)
+		req, _ := http.NewRequest("POST", "/bets", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		HandleBets(rr, withAuthContext(req, user.TelegramID))
+
+		if rr.Code != tc.want {
+			t.Errorf("Bet of %d: expected status %d, got %d: %s", tc.amount, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestHandleBetsSuccess(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+	expiresAt := time.Now().Add(24 * time.Hour)
+	market := createTestMarket(t, user.ID, "Will it rain tomorrow?", expiresAt)
+
+	body := `{"market_id":` + fmt.Sprintf("%d", market.ID) + `,"outcome":"YES","amount":100}`
+	req, err := http.NewRequest("POST", "/bets", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	req = withAuthContext(req, user.TelegramID)
+	req = withAuthContext(req, user.TelegramID)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleBets)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+
+	var response PlaceBetResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if response.NewBalance != 900 {
+		t.Errorf("Expected new balance 900, got %d", response.NewBalance)
+	}
+	if response.PoolYes != 100 {
+		t.Errorf("Expected pool_yes 100, got %d", response.PoolYes)
+	}
+	if response.PoolNo != 0 {
+		t.Errorf("Expected pool_no 0, got %d", response.PoolNo)
+	}
+}
+
+func TestHandleBetsSource(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12346, "testuser", "Test User", 1000)
+	market := createTestMarket(t, user.ID, "Will the web app win?", time.Now().Add(24*time.Hour))
+
+	for _, header := range []string{"webapp", "", "bot"} {
+		body := fmt.Sprintf(`{"market_id":%d,"outcome":"YES","amount":10}`, market.ID)
+		req, _ := http.NewRequest("POST", "/bets", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(BetSourceHeader, header)
+		rr := httptest.NewRecorder()
+		HandleBets(rr, withAuthContext(req, user.TelegramID))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Only the Web App's header counts; anything else is an API bet
+	bets, _ := storage.GetUserBets(user.ID)
+	if len(bets) != 3 || bets[2].Source != storage.BetSourceWebApp || bets[1].Source != storage.BetSourceAPI || bets[0].Source != storage.BetSourceAPI {
+		t.Errorf("Unexpected sources %+v", bets)
+	}
+}
+
+func TestHandleBetsLastCall(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator := createTestUser(t, 12345, "creator", "Creator", 1000)
+	late := createTestUser(t, 12346, "late", "Late", 1000)
+	market := createTestMarket(t, creator.ID, "Will it rain in the next hour?", time.Now().Add(3*time.Minute))
+	if err := placeTestBet(t, creator.ID, market.ID, "YES", 100); err != nil {
+		t.Fatalf("Failed to place bet: %v", err)
+	}
+	if _, err := storage.StartLastCalls(5 * time.Minute); err != nil {
+		t.Fatalf("Failed to start last call: %v", err)
+	}
+
+	body := fmt.Sprintf(`{"market_id":%d,"outcome":"NO","amount":200}`, market.ID)
+	req := withAuthContext(httptest.NewRequest("POST", "/bets", strings.NewReader(body)), late.TelegramID)
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	HandleBets(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d during last call, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var response PlaceBetResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if response.NewBalance != 800 || response.PoolYes != 100 || response.PoolNo != 0 {
+		t.Errorf("Expected balance 800 and frozen pools 100/0, got %+v", response)
+	}
+}
+
+func TestHandleBetsAmountDisplay(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	defer service.SetCurrency(service.GetCurrency())
+	service.SetCurrency(service.Currency{Name: "WSC", Decimals: 2})
+
+	user := createTestUser(t, 12345, "bettor", "Bettor", 1000)
+	market := createTestMarket(t, user.ID, "Will decimals work?", time.Now().Add(24*time.Hour))
+
+	tests := []struct {
+		body       string
+		wantStatus int
+	}{
+		{fmt.Sprintf(`{"market_id":%d,"outcome":"YES","amount_display":"1.005"}`, market.ID), http.StatusBadRequest},
+		{fmt.Sprintf(`{"market_id":%d,"outcome":"YES","amount":100,"amount_display":"1.00"}`, market.ID), http.StatusBadRequest},
+		{fmt.Sprintf(`{"market_id":%d,"outcome":"YES","amount_display":"2.5"}`, market.ID), http.StatusCreated},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/bets", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		HandleBets(rr, withAuthContext(req, user.TelegramID))
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.body, tt.wantStatus, rr.Code, rr.Body.String())
+		}
+	}
+
+	updated, _ := storage.GetUserByTelegramID(user.TelegramID)
+	if updated.Balance != 750 {
+		t.Errorf("Expected balance 750 after a 2.50 bet, got %d", updated.Balance)
+	}
+
+	req, _ := http.NewRequest("GET", "/me", nil)
+	rr := httptest.NewRecorder()
+	HandleMe(rr, withAuthContext(req, user.TelegramID))
+	var me UserResponse
+	json.Unmarshal(rr.Body.Bytes(), &me)
+	if me.BalanceDisplay != "7.50" || me.Currency.Decimals != 2 {
+		t.Errorf("Expected balance_display 7.50, got %q (%+v)", me.BalanceDisplay, me.Currency)
+	}
+}
+
+func TestHandleSuggestedStakes(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "bettor", "Bettor", 1000)
+	other := createTestUser(t, 12346, "other", "Other", 1000)
+	market := createTestMarket(t, user.ID, "Will presets help?", time.Now().Add(24*time.Hour))
+	placeTestBet(t, other.ID, market.ID, "YES", 500)
+	placeTestBet(t, other.ID, market.ID, "NO", 500)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/markets/%d/suggested-stakes", market.ID), nil)
+	rr := httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, 12345))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var stakes service.SuggestedStakes
+	json.Unmarshal(rr.Body.Bytes(), &stakes)
+	if stakes.Balance != 1000 || stakes.Yes.MaxStake != 111 || len(stakes.No.Amounts) == 0 {
+		t.Errorf("Unexpected suggestions: %s", rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/markets/%d/suggested-stakes?max_shift=0", market.ID), nil)
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, 12345))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for max_shift=0, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/markets/%d/suggested-stakes", market.ID), nil)
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, 12345))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for a locked market, got %d", http.StatusConflict, rr.Code)
+	}
+}
+
+func TestHandleBetsMultipleOutcomes(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "testuser", "Test User", 1000)
+	expiresAt := time.Now().Add(24 * time.Hour)
+	market := createTestMarket(t, user.ID, "Will it rain tomorrow?", expiresAt)
+
+	// Place YES bet
+	body1 := `{"market_id":` + fmt.Sprintf("%d", market.ID) + `,"outcome":"YES","amount":100}`
+	req1, err := http.NewRequest("POST", "/bets", strings.NewReader(body1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req1.Header.Set("Content-Type", "application/json")
+	req1 = withAuthContext(req1, user.TelegramID)
+
+	rr1 := httptest.NewRecorder()
+	handler := http.HandlerFunc(HandleBets)
+	handler.ServeHTTP(rr1, req1)
+
+	if rr1.Code != http.StatusCreated {
+		t.Errorf("First bet: Expected status %d, got %d", http.StatusCreated, rr1.Code)
+	}
+
+	// Place NO bet
+	body2 := `{"market_id":` + fmt.Sprintf("%d", market.ID) + `,"outcome":"NO","amount":50}`
+	req2, err := http.NewRequest("POST", "/bets", strings.NewReader(body2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req2.Header.Set("Content-Type", "application/json")
+	req2 = withAuthContext(req2, user.TelegramID)
+
+	rr2 := httptest.NewRecorder()
+	handler.ServeHTTP(rr2, req2)
+
+	if rr2.Code != http.StatusCreated {
+		t.Errorf("Second bet: Expected status %d, got %d", http.StatusCreated, rr2.Code)
+	}
+
+	var response2 PlaceBetResponse
+	if err := json.Unmarshal(rr2.Body.Bytes(), &response2); err != nil {
+		t.Fatalf("Failed to parse second response: %v", err)
+	}
+
+	if response2.PoolYes != 100 {
+		t.Errorf("Expected pool_yes 100, got %d", response2.PoolYes)
+	}
+	if response2.PoolNo != 50 {
+		t.Errorf("Expected pool_no 50, got %d", response2.PoolNo)
+	}
+	if response2.NewBalance != 850 {
+		t.Errorf("Expected new balance 850, got %d", response2.NewBalance)
+	}
+}
+
+// ============================================================================
+// Response Header Tests
+// ============================================================================
+
+func TestAPIResponseContentType(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		path    string
+	}{
+		{"Ping", PingHandler, "GET", "/ping"},
+		{"Markets", HandleMarkets, "GET", "/markets"},
+		{"Leaderboard", HandleLeaderboard, "GET", "/leaderboard"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setupTestDB(t)
+			defer cleanupTestDB(t)
+
+			req, err := http.NewRequest(tc.method, tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			tc.handler.ServeHTTP(rr, req)
+
+			contentType := rr.Header().Get("Content-Type")
+			if contentType != "application/json" {
+				t.Errorf("[%s] Expected Content-Type 'application/json', got '%s'", tc.name, contentType)
+			}
+		})
+	}
+}
+
+// ============================================================================
+// Activity Tests
+// ============================================================================
+
+func TestHandleActivity(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "bettor", "Bettor", 1000)
+	admin := createTestUser(t, 12346, "admin", "Admin", 1000)
+	market := createTestMarket(t, user.ID, "Will it rain tomorrow?", time.Now().Add(24*time.Hour))
+	placeTestBet(t, user.ID, market.ID, "YES", 10)
+	placeTestBet(t, admin.ID, market.ID, "NO", 10)
+
+	get := func(handler http.HandlerFunc, path string, telegramID int64) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		handler(rr, withAuthContext(req, telegramID))
+		return rr
+	}
+
+	rr := get(HandleUserActivity, "/me/activity?tz_offset=180", 12345)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var mine storage.BetActivity
+	if err := json.Unmarshal(rr.Body.Bytes(), &mine); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if mine.Total != 1 || mine.TZOffsetMinutes != 180 {
+		t.Errorf("Expected 1 bet in UTC+3, got %+v", mine)
+	}
+
+	if rr := get(HandleUserActivity, "/me/activity?tz_offset=9999", 12345); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected %d for invalid tz_offset, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if rr := get(HandleAdminActivity, "/admin/activity", 12345); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected %d for non-admin, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	if err := auth.GrantRole(admin.ID, storage.RoleAdmin, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+	rr = get(HandleAdminActivity, "/admin/activity", 12346)
+	var all storage.BetActivity
+	json.Unmarshal(rr.Body.Bytes(), &all)
+	if rr.Code != http.StatusOK || all.Total != 2 {
+		t.Errorf("Expected 2 platform bets, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// ============================================================================
+// Stats Tests
+// ============================================================================
+
+func TestHandleCalibration(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "bettor", "Bettor", 1000)
+	market := createTestMarket(t, user.ID, "Will it rain tomorrow?", time.Now().Add(24*time.Hour))
+	placeTestBet(t, user.ID, market.ID, "YES", 10)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusFinalized, "YES")
+
+	req, _ := http.NewRequest("GET", "/stats/calibration", nil)
+	rr := httptest.NewRecorder()
+	HandleCalibration(rr, withAuthContext(req, 12345))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var report service.CalibrationReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(report.Buckets) != 10 || report.GeneratedAt.IsZero() {
+		t.Errorf("Expected 10 buckets and a generation time, got %+v", report)
+	}
+
+	req, _ = http.NewRequest("POST", "/stats/calibration", nil)
+	rr = httptest.NewRecorder()
+	HandleCalibration(rr, withAuthContext(req, 12345))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
+
+// ============================================================================
+// Translation Tests
+// ============================================================================
+
+func TestHandleMarketTranslations(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator := createTestUser(t, 12345, "creator", "Creator", 1000)
+	createTestUser(t, 12346, "other", "Other", 1000)
+	market := createTestMarket(t, creator.ID, "Will it rain tomorrow?", time.Now().Add(24*time.Hour))
+	path := fmt.Sprintf("/markets/%d/translations", market.ID)
+
+	// Only the creator can add translations
+	req, _ := http.NewRequest("PUT", path, strings.NewReader(`{"lang":"de","question":"Wird es morgen regnen?"}`))
+	rr := httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, 12346))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for non-creator, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	req, _ = http.NewRequest("PUT", path, strings.NewReader(`{"lang":"german","question":"Wird es morgen regnen?"}`))
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, 12345))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid language, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	req, _ = http.NewRequest("PUT", path, strings.NewReader(`{"lang":"de-DE","question":"Wird es morgen regnen?"}`))
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, 12345))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", path, nil)
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, 12346))
+	var translations []storage.MarketTranslation
+	json.Unmarshal(rr.Body.Bytes(), &translations)
+	if len(translations) != 1 || translations[0].Lang != "de" || translations[0].Source != storage.TranslationCreator {
+		t.Errorf("Unexpected translations: %s", rr.Body.String())
+	}
+
+	// The detail and list endpoints follow ?lang= and Accept-Language
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/markets/%d?lang=de", market.ID), nil)
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, 12346))
+	var detail MarketDetailResponse
+	json.Unmarshal(rr.Body.Bytes(), &detail)
+	if detail.Question != "Wird es morgen regnen?" || detail.OriginalQuestion != "Will it rain tomorrow?" {
+		t.Errorf("Expected translated detail, got %+v", detail)
+	}
+
+	req, _ = http.NewRequest("GET", "/markets", nil)
+	req.Header.Set("Accept-Language", "de-AT,de;q=0.9")
+	rr = httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, 12346))
+	var markets []storage.MarketWithCreator
+	json.Unmarshal(rr.Body.Bytes(), &markets)
+	if len(markets) != 1 || markets[0].Question != "Wird es morgen regnen?" {
+		t.Errorf("Expected translated list, got %+v", markets)
+	}
+
+	req, _ = http.NewRequest("GET", "/markets?lang=fr", nil)
+	rr = httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, 12346))
+	json.Unmarshal(rr.Body.Bytes(), &markets)
+	if len(markets) != 1 || markets[0].Question != "Will it rain tomorrow?" {
+		t.Errorf("Expected the original without a translation, got %+v", markets)
+	}
+}
+
+func TestHandlePreferencesLanguage(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	createTestUser(t, 12345, "polyglot", "Polyglot", 1000)
+
+	req, _ := http.NewRequest("PUT", "/me/preferences", strings.NewReader(`{"language":"klingon"}`))
+	rr := httptest.NewRecorder()
+	HandlePreferences(rr, withAuthContext(req, 12345))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid language, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	req, _ = http.NewRequest("PUT", "/me/preferences", strings.NewReader(`{"language":"pt-BR"}`))
+	rr = httptest.NewRecorder()
+	HandlePreferences(rr, withAuthContext(req, 12345))
+	var prefs storage.UserPreferences
+	json.Unmarshal(rr.Body.Bytes(), &prefs)
+	if rr.Code != http.StatusOK || prefs.Language != "pt" {
+		t.Errorf("Expected language pt, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("PUT", "/me/preferences", strings.NewReader(`{"language":""}`))
+	rr = httptest.NewRecorder()
+	HandlePreferences(rr, withAuthContext(req, 12345))
+	json.Unmarshal(rr.Body.Bytes(), &prefs)
+	if rr.Code != http.StatusOK || prefs.Language != "" {
+		t.Errorf("Expected language to be cleared, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandlePreferencesDigestHour(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	createTestUser(t, 12345, "reader", "Reader", 1000)
+
+	req, _ := http.NewRequest("GET", "/me/preferences", nil)
+	rr := httptest.NewRecorder()
+	HandlePreferences(rr, withAuthContext(req, 12345))
+	var prefs storage.UserPreferences
+	json.Unmarshal(rr.Body.Bytes(), &prefs)
+	if prefs.DigestHour != storage.DigestOff {
+		t.Errorf("Expected the digest to be off by default, got hour %d", prefs.DigestHour)
+	}
+
+	req, _ = http.NewRequest("PUT", "/me/preferences", strings.NewReader(`{"digest_hour":25}`))
+	rr = httptest.NewRecorder()
+	HandlePreferences(rr, withAuthContext(req, 12345))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for hour 25, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	req, _ = http.NewRequest("PUT", "/me/preferences", strings.NewReader(`{"digest_hour":7}`))
+	rr = httptest.NewRecorder()
+	HandlePreferences(rr, withAuthContext(req, 12345))
+	json.Unmarshal(rr.Body.Bytes(), &prefs)
+	if rr.Code != http.StatusOK || prefs.DigestHour != 7 {
+		t.Errorf("Expected digest hour 7, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandlePreferencesMuted(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "reader", "Reader", 1000)
+
+	req, _ := http.NewRequest("PUT", "/me/preferences", strings.NewReader(`{"muted":true}`))
+	rr := httptest.NewRecorder()
+	HandlePreferences(rr, withAuthContext(req, 12345))
+	var prefs storage.UserPreferences
+	json.Unmarshal(rr.Body.Bytes(), &prefs)
+	if rr.Code != http.StatusOK || !prefs.Muted {
+		t.Fatalf("Expected DMs muted, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if muted, _ := storage.IsNotificationsMuted(user.ID); !muted {
+		t.Error("Expected the mute to be stored")
+	}
+}
+
+func TestHandlePreferencesTransport(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	createTestUser(t, 12345, "reader", "Reader", 1000)
+	service.RegisterTransport("webhook", service.NewWebhookTransport())
+
+	for _, body := range []string{`{"transport":"pigeon"}`, `{"transport":"webhook","transport_target":"http://example.com/hook"}`, `{"transport":"webhook","transport_target":"https://192.168.1.10/hook"}`} {
+		req, _ := http.NewRequest("PUT", "/me/preferences", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		HandlePreferences(rr, withAuthContext(req, 12345))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+		}
+	}
+
+	req, _ := http.NewRequest("PUT", "/me/preferences", strings.NewReader(`{"transport":"webhook","transport_target":"https://example.com/hook"}`))
+	rr := httptest.NewRecorder()
+	HandlePreferences(rr, withAuthContext(req, 12345))
+	var prefs storage.UserPreferences
+	json.Unmarshal(rr.Body.Bytes(), &prefs)
+	if rr.Code != http.StatusOK || prefs.Transport != "webhook" || prefs.TransportTarget != "https://example.com/hook" {
+		t.Errorf("Expected the webhook transport, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("PUT", "/me/preferences", strings.NewReader(`{"transport":"telegram"}`))
+	rr = httptest.NewRecorder()
+	HandlePreferences(rr, withAuthContext(req, 12345))
+	prefs = storage.UserPreferences{}
+	json.Unmarshal(rr.Body.Bytes(), &prefs)
+	if rr.Code != http.StatusOK || prefs.Transport != "" || prefs.TransportTarget != "" {
+		t.Errorf("Expected DMs back on Telegram, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandleCreateMarketExpiresIn(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "planner", "Planner", 1000)
+
+	put, _ := http.NewRequest("PUT", "/me/preferences", strings.NewReader(`{"timezone":"Nowhere/Special"}`))
+	rr := httptest.NewRecorder()
+	HandlePreferences(rr, withAuthContext(put, user.TelegramID))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown timezone, got %d", http.StatusBadRequest, rr.Code)
+	}
+	put, _ = http.NewRequest("PUT", "/me/preferences", strings.NewReader(`{"timezone":"Asia/Tokyo"}`))
+	rr = httptest.NewRecorder()
+	HandlePreferences(rr, withAuthContext(put, user.TelegramID))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"timezone":"Asia/Tokyo"`) {
+		t.Fatalf("Expected the timezone to be saved, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/markets", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		HandleMarkets(rr, withAuthContext(req, user.TelegramID))
+		return rr
+	}
+
+	rr = create(`{"question":"Will the release ship before the weekend?","expires_in":"next friday 18:00"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var created CreateMarketResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	market, _ := storage.GetMarketByID(created.ID)
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	if local := market.ExpiresAt.In(tokyo); local.Weekday() != time.Friday || local.Hour() != 18 || local.Minute() != 0 {
+		t.Errorf("Expected Friday 18:00 Tokyo time, got %v", local)
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"gibberish", `{"question":"Will this deadline ever parse?","expires_in":"whenever"}`},
+		{"past", `{"question":"Will this deadline be accepted?","expires_in":"2020-01-01"}`},
+		{"both fields", `{"question":"Will both deadlines be accepted?","expires_in":"in 3 days","expires_at":"` + time.Now().Add(72*time.Hour).Format(time.RFC3339) + `"}`},
+	}
+	for _, tt := range tests {
+		if rr := create(tt.body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", tt.name, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestHandleCreateMarketIcon(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "artist", "Artist", 1000)
+	expiresAt := time.Now().Add(48 * time.Hour).Format(time.RFC3339)
+	create := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/markets", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		HandleMarkets(rr, withAuthContext(req, user.TelegramID))
+		return rr
+	}
+
+	rr := create(`{"question":"Will this icon be accepted?","expires_at":"` + expiresAt + `","icon":"not an emoji"}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid icon, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	icons := map[string]string{}
+	for body, want := range map[string]string{
+		`{"question":"Will the rocket launch on time?","expires_at":"` + expiresAt + `","icon":"🚀"}`: "🚀",
+		`{"question":"Will it snow in Berlin? #weather","expires_at":"` + expiresAt + `"}`:           "🌦️",
+		`{"question":"Will the meeting run late?","expires_at":"` + expiresAt + `"}`:                 storage.DefaultMarketIcon,
+	} {
+		rr = create(body)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+		var created CreateMarketResponse
+		json.Unmarshal(rr.Body.Bytes(), &created)
+		icons[fmt.Sprint(created.ID)] = want
+	}
+
+	req, _ := http.NewRequest("GET", "/markets", nil)
+	rr = httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, user.TelegramID))
+	var markets []storage.MarketWithCreator
+	json.Unmarshal(rr.Body.Bytes(), &markets)
+	if len(markets) != 3 {
+		t.Fatalf("Expected 3 markets, got %d", len(markets))
+	}
+	for _, market := range markets {
+		if want := icons[fmt.Sprint(market.ID)]; market.Icon != want {
+			t.Errorf("Expected icon %q for %q, got %q", want, market.Question, market.Icon)
+		}
+	}
+}
+
+func TestHandleCreateMarketIdempotent(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12345, "retrier", "Retrier", 1000)
+	body := `{"question":"Will the flaky connection create twins?","expires_at":"` + time.Now().Add(48*time.Hour).Format(time.RFC3339) + `"}`
+	create := func(key string) (int, CreateMarketResponse) {
+		req, _ := http.NewRequest("POST", "/markets", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		rr := httptest.NewRecorder()
+		HandleMarkets(rr, withAuthContext(req, user.TelegramID))
+		var resp CreateMarketResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr.Code, resp
+	}
+
+	code, first := create("3f1c9a2e")
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, code)
+	}
+	code, retry := create("3f1c9a2e")
+	if code != http.StatusOK || retry.ID != first.ID {
+		t.Errorf("Expected the retry to return market %d with status %d, got %d with %d", first.ID, http.StatusOK, retry.ID, code)
+	}
+
+	var count int
+	storage.DB().QueryRow(`SELECT COUNT(*) FROM markets`).Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 market, got %d", count)
+	}
+}
+
+func TestHandleAdminEscrow(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin := createTestUser(t, 66666, "admin", "Admin", 1000)
+	creator := createTestUser(t, 77777, "creator", "Creator", 1000)
+	market, _ := storage.CreateMarket(creator.ID, "Will this payout be held?", time.Now().Add(time.Hour))
+	if _, err := storage.DB().Exec(`INSERT INTO payout_escrow (market_id, user_id, bet_id, amount, source_type) VALUES (?, 999, 1, 2500, 'WIN_PAYOUT')`, market.ID); err != nil {
+		t.Fatalf("Failed to insert escrowed payout: %v", err)
+	}
+
+	list := func(telegramID int64) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/admin/escrow", nil)
+		req = withAuthContext(req, telegramID)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(HandleAdminEscrow).ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := list(admin.TelegramID); rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d without a role, got %d", http.StatusForbidden, rr.Code)
+	}
+	if err := auth.GrantRole(admin.ID, storage.RoleAdmin, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+	rr := list(admin.TelegramID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var payouts []storage.EscrowedPayout
+	if err := json.Unmarshal(rr.Body.Bytes(), &payouts); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(payouts) != 1 || payouts[0].UserID != 999 || payouts[0].Amount != 2500 {
+		t.Errorf("Expected the escrowed payout, got %+v", payouts)
+	}
+}
+
+func TestHandleAdminStats(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin := createTestUser(t, 66666, "admin", "Admin", 1000)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/admin/stats"+query, nil)
+		req = withAuthContext(req, admin.TelegramID)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(HandleAdminStats).ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := get(""); rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d without a role, got %d", http.StatusForbidden, rr.Code)
+	}
+	if err := auth.GrantRole(admin.ID, storage.RoleAdmin, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+	if rr := get("?days=12"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unsupported window, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	rr := get("?days=7")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var stats storage.PlatformStats
+	if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if stats.Days != 7 || len(stats.Daily) != 7 || stats.NewUsers != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestCountErrors(t *testing.T) {
+	resetErrorMetrics()
+	defer resetErrorMetrics()
+
+	api := CountErrors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/bets":
+			respondWithError(w, "insufficient funds", http.StatusBadRequest)
+		case "/api/markets/12":
+			respondWithError(w, "Market not found", http.StatusNotFound)
+		case "/api/me":
+			w.Write([]byte("{}"))
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	for _, req := range []struct{ method, path string }{
+		{"POST", "/api/bets"}, {"POST", "/api/bets"}, {"GET", "/api/markets/12"},
+		{"GET", "/api/me"}, {"GET", "/api/me"}, {"GET", "/api/broken"},
+	} {
+		r, _ := http.NewRequest(req.method, req.path, nil)
+		api.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	metrics := GetErrorMetrics()
+	if metrics.Requests != 6 || metrics.ClientErrors != 3 || metrics.ServerErrors != 1 {
+		t.Errorf("Unexpected totals %+v", metrics)
+	}
+	if len(metrics.Recent) != 3 || metrics.Recent[0].Minutes != 5 || metrics.Recent[0].Requests != 6 || metrics.Recent[0].ErrorRate < 0.66 || metrics.Recent[0].ErrorRate > 0.67 {
+		t.Errorf("Unexpected error rates %+v", metrics.Recent)
+	}
+	if len(metrics.Endpoints) != 3 {
+		t.Fatalf("Expected 3 endpoint entries, got %+v", metrics.Endpoints)
+	}
+	if e := metrics.Endpoints[0]; e.Endpoint != "POST /api/bets" || e.Code != "insufficient_funds" || e.Count != 2 {
+		t.Errorf("Expected insufficient funds on bets first, got %+v", e)
+	}
+	for _, e := range metrics.Endpoints[1:] {
+		if e.Endpoint == "GET /api/markets/{id}" && e.Code != "not_found" {
+			t.Errorf("Expected not_found for the missing market, got %+v", e)
+		}
+		if e.Endpoint == "GET /api/broken" && e.Code != "server_error" {
+			t.Errorf("Expected server_error, got %+v", e)
+		}
+	}
+
+	// The admin stats report the same counters
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	admin := createTestUser(t, 66669, "admin", "Admin", 1000)
+	if err := auth.GrantRole(admin.ID, storage.RoleAdmin, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+	req, _ := http.NewRequest("GET", "/admin/stats", nil)
+	req = withAuthContext(req, admin.TelegramID)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(HandleAdminStats).ServeHTTP(rr, req)
+	var stats AdminStatsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if stats.PlatformStats == nil || stats.Days != 30 || stats.Errors.ClientErrors != 3 || len(stats.Errors.Endpoints) != 3 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestHandleAdminTelegramErrors(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin := createTestUser(t, 66667, "admin", "Admin", 1000)
+	blocker := createTestUser(t, 66668, "blocker", "Blocker", 1000)
+	storage.RecordDMFailure(blocker.TelegramID, true, 1)
+
+	get := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/admin/telegram-errors", nil)
+		req = withAuthContext(req, admin.TelegramID)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(HandleAdminTelegramErrors).ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := get(); rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d without a role, got %d", http.StatusForbidden, rr.Code)
+	}
+	if err := auth.GrantRole(admin.ID, storage.RoleAdmin, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+
+	rr := get()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var resp TelegramErrorsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Users) != 1 || resp.Users[0].TelegramID != blocker.TelegramID || resp.Users[0].DisabledAt == nil {
+		t.Errorf("Expected the blocker with DMs off, got %+v", resp.Users)
+	}
+}
+
+func TestHandleListMarketsPaged(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12401, "pager", "Pager", 1000)
+	for i := 0; i < 3; i++ {
+		storage.CreateMarket(user.ID, fmt.Sprintf("Will page %d load?", i), time.Now().Add(time.Hour))
+	}
+
+	for _, personalize := range []string{"false", "true"} {
+		req, _ := http.NewRequest("GET", "/markets?limit=2&offset=2&personalize="+personalize, nil)
+		rr := httptest.NewRecorder()
+		HandleMarkets(rr, withAuthContext(req, user.TelegramID))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var page MarketPage
+		json.Unmarshal(rr.Body.Bytes(), &page)
+		if page.Total != 3 || page.Limit != 2 || page.Offset != 2 || len(page.Markets) != 1 {
+			t.Errorf("personalize=%s: expected the last of 3 markets, got %+v", personalize, page)
+		}
+	}
+
+	// Without paging parameters the list stays a plain array
+	req, _ := http.NewRequest("GET", "/markets", nil)
+	rr := httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, user.TelegramID))
+	var markets []storage.MarketWithCreator
+	if err := json.Unmarshal(rr.Body.Bytes(), &markets); err != nil || len(markets) != 3 {
+		t.Errorf("Expected a list of 3 markets, got %s", rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/markets?limit=500", nil)
+	rr = httptest.NewRecorder()
+	HandleMarkets(rr, withAuthContext(req, user.TelegramID))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a huge page, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHandleMarketShare(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("BOT_USERNAME", "predict_bot")
+	t.Setenv("WEB_APP_URL", "https://predict.example.com")
+
+	creator := createTestUser(t, 12345, "creator", "Creator", 1000)
+	market, _ := storage.CreateMarket(creator.ID, "Will it snow in May?", time.Now().Add(time.Hour))
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/markets/%d/share", market.ID), nil)
+	rr := httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, 12345))
+	var share service.MarketShare
+	json.Unmarshal(rr.Body.Bytes(), &share)
+	if rr.Code != http.StatusOK || !strings.Contains(share.Text, "Will it snow in May?") || share.DeepLink == "" || share.ImageURL == "" {
+		t.Fatalf("Expected share text with a link and card, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+}
+
+func TestHandleMarketCard(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("CARD_CACHE_DIR", t.TempDir())
+
+	creator := createTestUser(t, 12345, "creator", "Creator", 1000)
+	market, _ := storage.CreateMarket(creator.ID, "Will it snow in May?", time.Now().Add(time.Hour))
+
+	// Cards need no authentication
+	svg, _ := http.NewRequest("GET", fmt.Sprintf("/markets/%d/card.svg", market.ID), nil)
+	rr := httptest.NewRecorder()
+	HandleMarketSubpath(rr, svg)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/svg+xml" || !strings.Contains(rr.Body.String(), "Will it snow in May?") {
+		t.Errorf("Expected the SVG card, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	card, _ := http.NewRequest("GET", fmt.Sprintf("/markets/%d/card.png", market.ID), nil)
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, card)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" || !strings.HasPrefix(rr.Body.String(), "\x89PNG") {
+		t.Errorf("Expected the PNG card, got %d with %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	storage.SetMarketHidden(market.ID, true, creator.ID)
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, card)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a hidden market's card, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	// Group markets stay inside their group
+	t.Setenv("BOT_USERNAME", "predict_bot")
+	group, _ := storage.CreateMarketWithOptions(creator.ID, "Will the team lunch be pizza?", time.Now().Add(time.Hour), storage.MarketOptions{GroupID: -100123})
+	for _, path := range []string{"card.svg", "card.png", "qr.png"} {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/markets/%d/%s", group.ID, path), nil)
+		rr = httptest.NewRecorder()
+		HandleMarketSubpath(rr, req)
+		if rr.Code != http.StatusNotFound || strings.Contains(rr.Body.String(), "pizza") {
+			t.Errorf("Expected status %d for a group market's %s, got %d", http.StatusNotFound, path, rr.Code)
+		}
+	}
+}
+
+func TestHandleMarketTradeAndPrice(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator := createTestUser(t, 12345, "creator", "Creator", 1000)
+	createTestUser(t, 67890, "trader", "Trader", 1000)
+	market, _ := storage.CreateMarketWithOptions(creator.ID, "Will the price move?", time.Now().Add(time.Hour), storage.MarketOptions{PricingMode: storage.PricingMarketMaker})
+
+	trade := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/markets/%d/trade", market.ID), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		HandleMarketSubpath(rr, withAuthContext(req, 67890))
+		return rr
+	}
+
+	rr := trade(`{"action": "buy", "outcome": "YES", "amount": 100}`)
+	var bought TradeResponse
+	json.Unmarshal(rr.Body.Bytes(), &bought)
+	if rr.Code != http.StatusOK || bought.Trade.Shares <= 100 || bought.NewBalance != 1000-bought.Trade.Amount {
+		t.Fatalf("Expected a purchase, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/markets/%d/price", market.ID), nil)
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, 67890))
+	var price MarketPriceResponse
+	json.Unmarshal(rr.Body.Bytes(), &price)
+	if rr.Code != http.StatusOK || price.PriceYes <= 0.5 || price.Position == nil || price.Position.SharesYes != bought.Trade.Shares {
+		t.Errorf("Expected a higher YES price and the position, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := trade(`{"action": "sell", "outcome": "NO", "shares": 5}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for selling shares not held, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := trade(`{"action": "hold", "outcome": "YES"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown action, got %d", rr.Code)
+	}
+
+	// Parimutuel markets have no prices
+	parimutuel, _ := storage.CreateMarket(creator.ID, "Will pools stay pools?", time.Now().Add(time.Hour))
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/markets/%d/price", parimutuel.ID), nil)
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, withAuthContext(req, 67890))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a parimutuel market, got %d", rr.Code)
+	}
+}
+
+func TestHandleMarketQR(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("BOT_USERNAME", "predict_bot")
+
+	creator := createTestUser(t, 12345, "creator", "Creator", 1000)
+	market, _ := storage.CreateMarket(creator.ID, "Will the office party run late?", time.Now().Add(time.Hour))
+
+	// QR codes need no authentication
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/markets/%d/qr.png", market.ID), nil)
+	rr := httptest.NewRecorder()
+	HandleMarketSubpath(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" || !strings.HasPrefix(rr.Body.String(), "\x89PNG") {
+		t.Fatalf("Expected a PNG QR code, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Without a bot username there is no deep link to encode
+	t.Setenv("BOT_USERNAME", "")
+	rr = httptest.NewRecorder()
+	HandleMarketSubpath(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without a deep link, got %d", rr.Code)
+	}
+}
+
+func TestHandleCancelBet(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	bettor := createTestUser(t, 12345, "bettor", "Bettor", 1000)
+	createTestUser(t, 67890, "other", "Other", 1000)
+	market, _ := storage.CreateMarket(bettor.ID, "Will I keep this bet?", time.Now().Add(time.Hour))
+	storage.PlaceBet(context.Background(), bettor.ID, market.ID, "YES", 100)
+	bets, _ := storage.GetUserBets(bettor.ID)
+
+	cancel := func(path string, tgID int64) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, nil)
+		rr := httptest.NewRecorder()
+		HandleBetSubpath(rr, withAuthContext(req, tgID))
+		return rr
+	}
+
+	path := fmt.Sprintf("/bets/%d/cancel", bets[0].ID)
+	if rr := cancel(path, 67890); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for another user's bet, got %d", rr.Code)
+	}
+	if rr := cancel("/bets/abc/cancel", 12345); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid ID, got %d", rr.Code)
+	}
+
+	rr := cancel(path, 12345)
+	var response CancelBetResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if rr.Code != http.StatusOK || response.Refund != 100 || response.NewBalance != 1000 {
+		t.Errorf("Expected the stake back, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := cancel(path, 12345); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a cancelled bet, got %d", rr.Code)
+	}
+}
+
+func TestHandleAdminVouchers(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin := createTestUser(t, 44444, "admin", "Admin", 1000)
+	target := createTestUser(t, 55555, "target", "Target", 100)
+	auth.GrantRole(admin.ID, storage.RoleAdmin, 0)
+
+	grant := func(tgID int64, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/admin/vouchers", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		HandleAdminVouchers(rr, withAuthContext(req, tgID))
+		return rr
+	}
+
+	if rr := grant(55555, `{"telegram_id":55555,"kind":"CREDIT","max_amount":50}`); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a non-admin, got %d", rr.Code)
+	}
+	if rr := grant(44444, `{"telegram_id":55555,"kind":"cash","max_amount":50}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown kind, got %d", rr.Code)
+	}
+	if rr := grant(44444, `{"telegram_id":55555,"kind":"credit","max_amount":0}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for no amount, got %d", rr.Code)
+	}
+	if rr := grant(44444, `{"telegram_id":55555,"kind":"credit","max_amount":50,"expires_in_hours":24}`); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// The user sees the voucher backing their next bet
+	req, _ := http.NewRequest("GET", "/me", nil)
+	rr := httptest.NewRecorder()
+	HandleMe(rr, withAuthContext(req, target.TelegramID))
+	var me UserResponse
+	json.Unmarshal(rr.Body.Bytes(), &me)
+	if len(me.Vouchers) != 1 || me.Vouchers[0].Kind != storage.VoucherCredit || me.Vouchers[0].ExpiresAt == nil {
+		t.Errorf("Expected the credit voucher in /me, got %s", rr.Body.String())
+	}
+}
+
+func TestHandlePromoCodes(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin := createTestUser(t, 44444, "admin", "Admin", 1000)
+	target := createTestUser(t, 55555, "target", "Target", 100)
+	auth.GrantRole(admin.ID, storage.RoleAdmin, 0)
+
+	create := func(tgID int64, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/admin/promo-codes", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		HandleAdminPromoCodes(rr, withAuthContext(req, tgID))
+		return rr
+	}
+	redeem := func(tgID int64, code string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/me/redeem", strings.NewReader(`{"code":"`+code+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		HandleRedeemPromo(rr, withAuthContext(req, tgID))
+		return rr
+	}
+
+	if rr := create(55555, `{"code":"WELCOME","amount":25}`); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a non-admin, got %d", rr.Code)
+	}
+	if rr := create(44444, `{"code":"WELCOME","amount":0}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for no amount, got %d", rr.Code)
+	}
+	if rr := create(44444, `{"code":"WELCOME","amount":25,"max_uses":10,"expires_in_hours":24}`); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := create(44444, `{"code":"welcome","amount":5}`); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a duplicate code, got %d", rr.Code)
+	}
+
+	rr := redeem(55555, "welcome")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var redemption storage.PromoRedemption
+	json.Unmarshal(rr.Body.Bytes(), &redemption)
+	if redemption.Amount != 25 || redemption.NewBalance != target.Balance+25 {
+		t.Errorf("Expected 25 credited, got %+v", redemption)
+	}
+	if rr := redeem(55555, "WELCOME"); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a second redemption, got %d", rr.Code)
+	}
+	if rr := redeem(55555, "UNKNOWN"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown code, got %d", rr.Code)
+	}
+
+	req, _ := http.NewRequest("GET", "/admin/promo-codes", nil)
+	rr = httptest.NewRecorder()
+	HandleAdminPromoCodes(rr, withAuthContext(req, admin.TelegramID))
+	var codes []storage.PromoCode
+	json.Unmarshal(rr.Body.Bytes(), &codes)
+	if len(codes) != 1 || codes[0].Uses != 1 || codes[0].MaxUses != 10 {
+		t.Errorf("Expected the code used once, got %s", rr.Body.String())
+	}
+}
+
+func TestHandleStream(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	req, _ := http.NewRequest("GET", "/stream?markets=abc", nil)
+	rr := httptest.NewRecorder()
+	HandleStream(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid market IDs, got %d", rr.Code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ = http.NewRequestWithContext(ctx, "GET", "/stream?markets=7", nil)
+	rr = httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		HandleStream(rr, req)
+		close(done)
+	}()
+
+	bus := service.GetEventBus()
+	for deadline := time.Now().Add(time.Second); bus.Subscribers() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("Stream never subscribed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	bus.Publish(service.StreamEvent{Type: service.StreamMarketResolved, MarketID: 8, Outcome: "NO"})
+	bus.Publish(service.StreamEvent{Type: service.StreamMarketResolved, MarketID: 7, Outcome: "YES"})
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	body := rr.Body.String()
+	if rr.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected an event stream, got %q", rr.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(body, "event: market_resolved\ndata: {") || !strings.Contains(body, `"market_id":7`) {
+		t.Errorf("Expected the update of market 7, got %q", body)
+	}
+	if strings.Contains(body, `"market_id":8`) {
+		t.Errorf("Expected other markets to be filtered out, got %q", body)
+	}
+	if bus.Subscribers() != 0 {
+		t.Errorf("Expected the closed stream to unsubscribe, got %d subscribers", bus.Subscribers())
+	}
+}
+
+// ============================================================================
+// Content Tests
+// ============================================================================
+
+func TestHandleContent(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin := createTestUser(t, 11111, "admin", "Admin", 1000)
+	createTestUser(t, 12345, "user", "User", 1000)
+	if err := auth.GrantRole(admin.ID, storage.RoleAdmin, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+
+	// The built-in rules are served until an admin edits them
+	req, _ := http.NewRequest("GET", "/content/rules", nil)
+	rr := httptest.NewRecorder()
+	HandleContent(rr, withAuthContext(req, 12345))
+	var page storage.ContentPage
+	json.Unmarshal(rr.Body.Bytes(), &page)
+	if rr.Code != http.StatusOK || !strings.Contains(page.Body, "dispute") {
+		t.Fatalf("Expected default rules, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("PUT", "/content/rules", strings.NewReader(`{"body":"No insider betting."}`))
+	rr = httptest.NewRecorder()
+	HandleContent(rr, withAuthContext(req, 12345))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for a regular user, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	req, _ = http.NewRequest("PUT", "/content/rules", strings.NewReader(`{"body":"   "}`))
+	rr = httptest.NewRecorder()
+	HandleContent(rr, withAuthContext(req, 11111))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an empty body, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	req, _ = http.NewRequest("PUT", "/content/rules", strings.NewReader(`{"body":"No insider betting."}`))
+	rr = httptest.NewRecorder()
+	HandleContent(rr, withAuthContext(req, 11111))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/content/rules", nil)
+	rr = httptest.NewRecorder()
+	HandleContent(rr, withAuthContext(req, 12345))
+	json.Unmarshal(rr.Body.Bytes(), &page)
+	if page.Body != "No insider betting." {
+		t.Errorf("Expected edited rules, got %q", page.Body)
+	}
+
+	req, _ = http.NewRequest("GET", "/content/secrets", nil)
+	rr = httptest.NewRecorder()
+	HandleContent(rr, withAuthContext(req, 12345))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown page, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestHandleTransactions(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user := createTestUser(t, 12601, "ledger", "Ledger", 1000)
+	support := createTestUser(t, 12602, "support", "Support", 1000)
+	market := createTestMarket(t, user.ID, "Will the ledger balance?", time.Now().Add(time.Hour))
+	placeTestBet(t, user.ID, market.ID, "YES", 40)
+	placeTestBet(t, user.ID, market.ID, "NO", 60)
+
+	get := func(handler http.HandlerFunc, telegramID int64, query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/transactions?"+query, nil)
+		rr := httptest.NewRecorder()
+		handler(rr, withAuthContext(req, telegramID))
+		return rr
+	}
+
+	rr := get(HandleUserTransactions, user.TelegramID, fmt.Sprintf("type=bet_placed&market_id=%d&sort=largest&limit=1", market.ID))
+	var page TransactionPage
+	json.Unmarshal(rr.Body.Bytes(), &page)
+	if rr.Code != http.StatusOK || page.Total != 2 || len(page.Transactions) != 1 || page.Transactions[0].Amount != -60 {
+		t.Fatalf("Expected the larger bet of two, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	rr = get(HandleUserTransactions, user.TelegramID, "from="+today+"&to="+today)
+	json.Unmarshal(rr.Body.Bytes(), &page)
+	if page.Total < 3 {
+		t.Errorf("Expected a date-only to to include the whole day, got %s", rr.Body.String())
+	}
+
+	for _, query := range []string{"type=GIFT", "limit=0", "offset=-1", "market_id=x", "from=yesterday", "from=2026-02-02&to=2026-01-01", "sort=random"} {
+		if rr := get(HandleUserTransactions, user.TelegramID, query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+
+	// Browsing someone else's transactions takes the balance permission
+	target := fmt.Sprintf("telegram_id=%d", user.TelegramID)
+	if rr := get(HandleAdminTransactions, support.TelegramID, target); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+	if err := auth.GrantRole(support.ID, storage.RoleAdmin, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+	if rr := get(HandleAdminTransactions, support.TelegramID, "telegram_id=999"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown user, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	rr = get(HandleAdminTransactions, support.TelegramID, target+"&type=BET_PLACED")
+	json.Unmarshal(rr.Body.Bytes(), &page)
+	if rr.Code != http.StatusOK || page.Total != 2 {
+		t.Errorf("Expected the user's two bets, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = get(HandleAdminTransactions, support.TelegramID, target+"&format=csv&sort=oldest&limit=1")
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if rr.Header().Get("Content-Type") != "text/csv" || !strings.Contains(rr.Header().Get("Content-Disposition"), "attachment") {
+		t.Errorf("Expected a CSV download, got headers %v", rr.Header())
+	}
+	// The export ignores paging: header, welcome bonus and both bets
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "id,created_at,type") || !strings.Contains(lines[1], "WELCOME_BONUS") {
+		t.Errorf("Unexpected CSV %q", rr.Body.String())
+	}
+}
+
+func TestHandleMarketWebhook(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator := createTestUser(t, 12345, "creator", "Creator", 1000)
+	createTestUser(t, 12346, "other", "Other", 1000)
+	market := createTestMarket(t, creator.ID, "Will the script resolve this?", time.Now().Add(24*time.Hour))
+	path := fmt.Sprintf("/markets/%d/webhook", market.ID)
+
+	do := func(method string, telegramID int64, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		HandleMarketSubpath(rr, withAuthContext(req, telegramID))
+		return rr
+	}
+
+	if rr := do("GET", 12345, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d without a webhook, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := do("PUT", 12346, `{"url":"https://hooks.example.com/a"}`); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for non-creator, got %d", http.StatusForbidden, rr.Code)
+	}
+	if rr := do("PUT", 12345, `{"url":"https://127.0.0.1/a"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a loopback URL, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	rr := do("PUT", 12345, `{"url":"https://hooks.example.com/a"}`)
+	var hook storage.MarketWebhook
+	json.Unmarshal(rr.Body.Bytes(), &hook)
+	if rr.Code != http.StatusOK || hook.URL != "https://hooks.example.com/a" || hook.Secret == "" {
+		t.Fatalf("Expected the webhook with its secret, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = do("GET", 12345, "")
+	hook = storage.MarketWebhook{}
+	json.Unmarshal(rr.Body.Bytes(), &hook)
+	if rr.Code != http.StatusOK || hook.URL != "https://hooks.example.com/a" || hook.Secret != "" {
+		t.Errorf("Expected the webhook without its secret, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := do("DELETE", 12345, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if rr := do("DELETE", 12345, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a removed webhook, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	if rr := do("PUT", 12345, `{"url":"https://hooks.example.com/a"}`); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for a locked market, got %d", http.StatusConflict, rr.Code)
+	}
+}
+
+func TestHandleAdminFixtures(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin := createTestUser(t, 12345, "admin", "Admin", 1000)
+	createTestUser(t, 12346, "user", "User", 1000)
+	if err := auth.GrantRole(admin.ID, storage.RoleAdmin, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+	kickoff := time.Now().Add(48 * time.Hour)
+	storage.SaveFixture("m-1", "Premier League", "Arsenal", "Chelsea", kickoff)
+	storage.SaveFixture("m-2", "Serie A", "Inter", "Milan", kickoff)
+
+	do := func(method, path string, telegramID int64) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		rr := httptest.NewRecorder()
+		if strings.Count(strings.Trim(path, "/"), "/") > 1 {
+			HandleAdminFixtureSubpath(rr, withAuthContext(req, telegramID))
+		} else {
+			HandleAdminFixtures(rr, withAuthContext(req, telegramID))
+		}
+		return rr
+	}
+
+	if rr := do("GET", "/admin/fixtures", 12346); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for a regular user, got %d", http.StatusForbidden, rr.Code)
+	}
+	rr := do("GET", "/admin/fixtures", 12345)
+	var fixtures []storage.SportsFixture
+	json.Unmarshal(rr.Body.Bytes(), &fixtures)
+	if rr.Code != http.StatusOK || len(fixtures) != 2 {
+		t.Fatalf("Expected the 2 pending fixtures, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = do("POST", fmt.Sprintf("/admin/fixtures/%d/approve", fixtures[0].ID), 12345)
+	var approved storage.SportsFixture
+	json.Unmarshal(rr.Body.Bytes(), &approved)
+	if rr.Code != http.StatusOK || approved.Status != storage.FixtureApproved || approved.MarketID == 0 {
+		t.Fatalf("Expected the fixture approved with a market, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("POST", fmt.Sprintf("/admin/fixtures/%d/reject", fixtures[0].ID), 12345); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for a reviewed fixture, got %d", http.StatusConflict, rr.Code)
+	}
+	if rr := do("POST", fmt.Sprintf("/admin/fixtures/%d/reject", fixtures[1].ID), 12345); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if rr := do("POST", "/admin/fixtures/999/approve", 12345); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown fixture, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	rr = do("GET", "/admin/fixtures?status=approved", 12345)
+	fixtures = nil
+	json.Unmarshal(rr.Body.Bytes(), &fixtures)
+	if len(fixtures) != 1 || fixtures[0].HomeTeam != "Arsenal" {
+		t.Errorf("Expected the approved fixture, got %s", rr.Body.String())
+	}
+	if rr := do("GET", "/admin/fixtures?status=live", 12345); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown status, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHandleWeatherMarket(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/search" && r.URL.Query().Get("name") == "Berlin" {
+			w.Write([]byte(`{"results": [{"name": "Berlin", "country": "Germany", "latitude": 52.52, "longitude": 13.41, "timezone": "Europe/Berlin"}]}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	service.SetWeatherClient(service.NewWeatherClient(server.URL, server.URL))
+	defer service.SetWeatherClient(nil)
+
+	createTestUser(t, 12345, "creator", "Creator", 1000)
+	day := time.Now().AddDate(0, 0, 3).Format("2006-01-02")
+	post := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/markets/weather", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "weather-1")
+		rr := httptest.NewRecorder()
+		HandleMarketSubpath(rr, withAuthContext(req, 12345))
+		return rr
+	}
+
+	rr := post(`{"place": "Berlin", "day": "` + day + `", "condition": "above", "threshold": 25}`)
+	var response WeatherMarketResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if rr.Code != http.StatusCreated || response.ID == 0 || response.Weather == nil || response.Weather.Threshold != 25 {
+		t.Fatalf("Expected the weather market created, got %d %s", rr.Code, rr.Body.String())
+	}
+	market, _ := storage.GetMarketByID(response.ID)
+	if market == nil || !strings.HasPrefix(market.Question, "Will it get above 25°C in Berlin") {
+		t.Errorf("Unexpected market %+v", market)
+	}
+
+	// Retries answer with the first market
+	if rr := post(`{"place": "Berlin", "day": "` + day + `", "condition": "above", "threshold": 25}`); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d for a retry, got %d", http.StatusOK, rr.Code)
+	}
+	if rr := post(`{"place": "Atlantis", "day": "tomorrow", "condition": "rain"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown place, got %d", http.StatusNotFound, rr.Code)
+	}
+	for _, body := range []string{`{"place": "Berlin", "day": "tomorrow", "condition": "fog"}`, `{"place": "Berlin", "day": "2020-01-01", "condition": "rain"}`} {
+		if rr := post(body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestHandleMarketOracle(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator := createTestUser(t, 12345, "creator", "Creator", 1000)
+	admin := createTestUser(t, 12346, "admin", "Admin", 1000)
+	if err := auth.GrantRole(admin.ID, storage.RoleAdmin, 0); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+	market := createTestMarket(t, creator.ID, "Will BTC close above 100k?", time.Now().Add(24*time.Hour))
+	path := fmt.Sprintf("/markets/%d/oracle", market.ID)
+
+	do := func(method, path string, telegramID int64, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		switch {
+		case strings.HasPrefix(path, "/markets/"):
+			HandleMarketSubpath(rr, withAuthContext(req, telegramID))
+		case strings.Count(strings.Trim(path, "/"), "/") > 1:
+			HandleAdminOracleSubpath(rr, withAuthContext(req, telegramID))
+		default:
+			HandleAdminOracles(rr, withAuthContext(req, telegramID))
+		}
+		return rr
+	}
+
+	if rr := do("GET", path, 12346, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d without an oracle, got %d", http.StatusNotFound, rr.Code)
+	}
+	mapping := `{"url":"https://api.example.com/btc","path":"$.data.close","operator":">","value":"100000"}`
+	if rr := do("PUT", path, 12346, mapping); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for non-creator, got %d", http.StatusForbidden, rr.Code)
+	}
+	if rr := do("PUT", path, 12345, `{"url":"https://api.example.com/btc","path":"data.close","operator":">","value":"1"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid path, got %d", http.StatusBadRequest, rr.Code)
+	}
+	rr := do("PUT", path, 12345, mapping)
+	var oracle storage.MarketOracle
+	json.Unmarshal(rr.Body.Bytes(), &oracle)
+	if rr.Code != http.StatusOK || oracle.Status != storage.OraclePendingReview || oracle.Path != "$.data.close" {
+		t.Fatalf("Expected the oracle waiting for review, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("GET", path, 12346, ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected anyone to see the oracle, got %d", rr.Code)
+	}
+
+	if rr := do("GET", "/admin/oracles", 12345, ""); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for a regular user, got %d", http.StatusForbidden, rr.Code)
+	}
+	rr = do("GET", "/admin/oracles", 12346, "")
+	var oracles []storage.MarketOracle
+	json.Unmarshal(rr.Body.Bytes(), &oracles)
+	if rr.Code != http.StatusOK || len(oracles) != 1 || oracles[0].MarketID != market.ID {
+		t.Fatalf("Expected the oracle waiting for review, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = do("POST", fmt.Sprintf("/admin/oracles/%d/approve", market.ID), 12346, "")
+	oracle = storage.MarketOracle{}
+	json.Unmarshal(rr.Body.Bytes(), &oracle)
+	if rr.Code != http.StatusOK || oracle.Status != storage.OracleApproved || oracle.ReviewedBy != admin.ID {
+		t.Fatalf("Expected the oracle approved, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("POST", fmt.Sprintf("/admin/oracles/%d/reject", market.ID), 12346, ""); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for a reviewed oracle, got %d", http.StatusConflict, rr.Code)
+	}
+	if rr := do("POST", "/admin/oracles/999/approve", 12346, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown oracle, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := do("GET", "/admin/oracles?status=live", 12346, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown status, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	if rr := do("PUT", path, 12345, mapping); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for a locked market, got %d", http.StatusConflict, rr.Code)
 	}
 }
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// maxHideHours is the longest snooze; longer periods should simply hide the market
+const maxHideHours = 30 * 24
+
+// HideMarketResponse is the response for hiding or unhiding a market
+type HideMarketResponse struct {
+	Status string `json:"status"`
+}
+
+// HandleMarketHide handles POST and DELETE /api/markets/{id}/hide.
+// POST removes the market from the caller's feed, for ?hours=N or until DELETE puts it back.
+// Other users are not affected.
+func HandleMarketHide(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		logger.Debug(0, "hide_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(w, r, "hide")
+	if user == nil {
+		return
+	}
+	telegramID := user.TelegramID
+
+	// Expected path: /markets/{id}/hide (after StripPrefix removes /api)
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 || pathParts[0] != "markets" || pathParts[2] != "hide" {
+		logger.Debug(telegramID, "hide_invalid_path", "path="+r.URL.Path)
+		respondWithError(w, "Invalid path format", http.StatusBadRequest)
+		return
+	}
+
+	marketID, err := strconv.ParseInt(pathParts[1], 10, 64)
+	if err != nil {
+		logger.Debug(telegramID, "hide_invalid_id", "id="+pathParts[1])
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := storage.UnhideMarketForUser(user.ID, marketID); err != nil {
+			logger.Debug(telegramID, "unhide_failed", fmt.Sprintf("market_id=%d error=%v", marketID, err))
+			respondWithError(w, "Failed to unhide market", http.StatusInternalServerError)
+			return
+		}
+		logger.Debug(telegramID, "market_unhidden", fmt.Sprintf("market_id=%d", marketID))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(HideMarketResponse{Status: "visible"})
+		return
+	}
+
+	hours := 0
+	if value := r.URL.Query().Get("hours"); value != "" {
+		hours, err = strconv.Atoi(value)
+		if err != nil || hours < 1 || hours > maxHideHours {
+			logger.Debug(telegramID, "hide_invalid_hours", "hours="+value)
+			respondWithError(w, fmt.Sprintf("invalid hours: must be between 1 and %d", maxHideHours), http.StatusBadRequest)
+			return
+		}
+	}
+
+	market, err := storage.GetMarketByID(marketID)
+	if err != nil {
+		logger.Debug(telegramID, "hide_failed", fmt.Sprintf("market_id=%d error=%v", marketID, err))
+		respondWithError(w, "Failed to hide market", http.StatusInternalServerError)
+		return
+	}
+	if market == nil || market.Hidden {
+		respondWithError(w, "market not found", http.StatusNotFound)
+		return
+	}
+
+	if err := storage.HideMarketForUser(user.ID, marketID, hours); err != nil {
+		logger.Debug(telegramID, "hide_failed", fmt.Sprintf("market_id=%d error=%v", marketID, err))
+		respondWithError(w, "Failed to hide market", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Debug(telegramID, "market_hidden", fmt.Sprintf("market_id=%d hours=%d", marketID, hours))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(HideMarketResponse{Status: "hidden"})
+}
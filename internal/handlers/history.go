@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"predictionbot/internal/auth"
 	"predictionbot/internal/logger"
@@ -11,6 +13,7 @@ import (
 )
 
 // HandleUserBets handles the GET /api/me/bets endpoint
+// Supports ?cursor=, ?limit=, ?status= and ?outcome=; the next page cursor is returned in X-Next-Cursor.
 func HandleUserBets(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		logger.Debug(0, "user_bets_invalid_method", "method="+r.Method)
@@ -35,20 +38,81 @@ func HandleUserBets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user's bets using internal user ID
-	bets, err := storage.GetUserBets(user.ID)
+	filter, err := parseBetHistoryFilter(r)
+	if err != nil {
+		logger.Debug(telegramID, "user_bets_invalid_query", "error="+err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get a page of the user's bets using internal user ID
+	bets, nextCursor, err := storage.GetUserBetsPage(user.ID, filter)
 	if err != nil {
 		logger.Debug(telegramID, "user_bets_error", "error="+err.Error())
 		http.Error(w, "Failed to get user bets", http.StatusInternalServerError)
 		return
 	}
+	if bets == nil {
+		bets = []storage.BetHistoryItem{}
+	}
 
-	logger.Debug(telegramID, "user_bets_success", fmt.Sprintf("count=%d", len(bets)))
+	logger.Debug(telegramID, "user_bets_success", fmt.Sprintf("count=%d cursor=%d next_cursor=%d", len(bets), filter.Cursor, nextCursor))
 	w.Header().Set("Content-Type", "application/json")
+	if nextCursor > 0 {
+		// Pass back as ?cursor= to fetch the next page
+		w.Header().Set("X-Next-Cursor", strconv.FormatInt(nextCursor, 10))
+	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(bets)
 }
 
+const (
+	// defaultBetHistoryLimit is the page size for /api/me/bets when no limit is given
+	defaultBetHistoryLimit = 50
+	// maxBetHistoryLimit is the largest page size accepted for /api/me/bets
+	maxBetHistoryLimit = 200
+)
+
+// parseBetHistoryFilter reads cursor, limit, status and outcome from the query string
+func parseBetHistoryFilter(r *http.Request) (storage.BetHistoryFilter, error) {
+	query := r.URL.Query()
+	filter := storage.BetHistoryFilter{Limit: defaultBetHistoryLimit}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		value, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || value < 0 {
+			return filter, fmt.Errorf("invalid cursor")
+		}
+		filter.Cursor = value
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > maxBetHistoryLimit {
+			return filter, fmt.Errorf("invalid limit: must be between 1 and %d", maxBetHistoryLimit)
+		}
+		filter.Limit = value
+	}
+
+	if status := strings.ToUpper(query.Get("status")); status != "" {
+		switch storage.BetStatus(status) {
+		case storage.BetStatusPending, storage.BetStatusWon, storage.BetStatusLost, storage.BetStatusRefunded:
+			filter.Status = storage.BetStatus(status)
+		default:
+			return filter, fmt.Errorf("invalid status: must be PENDING, WON, LOST or REFUNDED")
+		}
+	}
+
+	if outcome := strings.ToUpper(query.Get("outcome")); outcome != "" {
+		if outcome != "YES" && outcome != "NO" {
+			return filter, fmt.Errorf("invalid outcome: must be YES or NO")
+		}
+		filter.Outcome = outcome
+	}
+
+	return filter, nil
+}
+
 // HandleUserStats handles the GET /api/me/stats endpoint
 func HandleUserStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -87,3 +151,61 @@ func HandleUserStats(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(stats)
 }
+
+// Telegram users live anywhere from UTC-12 to UTC+14
+const (
+	minTZOffsetMinutes = -12 * 60
+	maxTZOffsetMinutes = 14 * 60
+)
+
+// parseTZOffset reads ?tz_offset= (minutes east of UTC, e.g. 180 for Moscow), defaulting to UTC
+func parseTZOffset(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("tz_offset")
+	if value == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < minTZOffsetMinutes || offset > maxTZOffsetMinutes {
+		return 0, fmt.Errorf("invalid tz_offset: must be minutes between %d and %d", minTZOffsetMinutes, maxTZOffsetMinutes)
+	}
+	return offset, nil
+}
+
+// writeActivity responds with bet activity for userID (internal ID, 0 for the whole platform)
+func writeActivity(w http.ResponseWriter, r *http.Request, telegramID, userID int64, action string) {
+	offset, err := parseTZOffset(r)
+	if err != nil {
+		logger.Debug(telegramID, action+"_invalid_tz", "tz_offset="+r.URL.Query().Get("tz_offset"))
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	activity, err := storage.GetBetActivity(userID, offset)
+	if err != nil {
+		logger.Debug(telegramID, action+"_error", "error="+err.Error())
+		respondWithError(w, "Failed to get activity", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Debug(telegramID, action+"_success", fmt.Sprintf("total=%d tz_offset=%d", activity.Total, offset))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(activity)
+}
+
+// HandleUserActivity handles GET /api/me/activity?tz_offset=
+// It returns the caller's bet counts by weekday (0 = Sunday) and hour for an activity heatmap.
+func HandleUserActivity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "user_activity_invalid_method", "method="+r.Method)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(w, r, "user_activity")
+	if user == nil {
+		return
+	}
+
+	writeActivity(w, r, user.TelegramID, user.ID, "user_activity")
+}
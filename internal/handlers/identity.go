@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// currentUser resolves the authenticated Telegram user from the request context to the
+// internal user record. The auth context only carries the Telegram ID; storage and service
+// calls expect the internal user ID, so handlers must pass user.ID (or the user itself) on.
+// On failure it writes the error response (401 or 404) and returns nil.
+func currentUser(w http.ResponseWriter, r *http.Request, action string) *storage.User {
+	telegramID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		logger.Debug(0, action+"_unauthorized", "path="+r.URL.Path)
+		respondWithError(w, "Unauthorized: user not in context", http.StatusUnauthorized)
+		return nil
+	}
+
+	user, err := storage.GetUserByTelegramID(telegramID)
+	if err != nil || user == nil {
+		logger.Debug(telegramID, action+"_user_not_found", "error=user lookup failed")
+		respondWithError(w, "User not found", http.StatusNotFound)
+		return nil
+	}
+
+	return user
+}
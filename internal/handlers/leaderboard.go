@@ -4,12 +4,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
 	"predictionbot/internal/storage"
 )
 
-// HandleLeaderboard handles GET /api/leaderboard
+// leaderboardNeighbours is how many users above and below the caller /api/leaderboard/me shows
+const leaderboardNeighbours = 2
+
+// LeaderboardPage is the response for GET /api/leaderboard when limit or offset is given.
+// Total counts every ranked user, not just this page.
+type LeaderboardPage struct {
+	Entries []storage.LeaderboardEntry `json:"entries"`
+	Total   int                        `json:"total"`
+	Limit   int                        `json:"limit"`
+	Offset  int                        `json:"offset"`
+}
+
+// HandleLeaderboard handles GET /api/leaderboard, the top 20 users by balance.
+// ?period=weekly or ?period=monthly ranks by profit this week or month instead (see
+// storage.LeaderboardPeriod); ?period=all_time is the default.
+// ?metric=win_rate, streak or markets_created ranks all-time by that metric instead, with each
+// entry's value; win rate only ranks users with ?min_bets= decided bets (default 5).
+// With ?limit= and/or ?offset= (same rules as /api/markets) the answer is a LeaderboardPage.
 func HandleLeaderboard(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		logger.Debug(0, "leaderboard_invalid_method", "method="+r.Method+" path="+r.URL.Path)
@@ -17,16 +38,104 @@ func HandleLeaderboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get top 20 users by balance
-	leaderboard, err := storage.GetTopUsers(20)
+	limit, offset, paged, err := parseMarketsPage(r)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	metric := storage.LeaderboardMetric(r.URL.Query().Get("metric"))
+	if metric == "" {
+		metric = storage.MetricBalance
+	}
+	if !metric.Valid() {
+		respondWithError(w, "Invalid metric: must be balance, win_rate, streak or markets_created", http.StatusBadRequest)
+		return
+	}
+	minBets := storage.DefaultWinRateMinBets
+	if raw := r.URL.Query().Get("min_bets"); raw != "" {
+		minBets, err = strconv.Atoi(raw)
+		if err != nil || minBets < 1 {
+			respondWithError(w, "Invalid min_bets: must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var leaderboard []storage.LeaderboardEntry
+	var total int
+	period := storage.LeaderboardPeriod(r.URL.Query().Get("period"))
+	switch {
+	case metric != storage.MetricBalance && period != "" && period != storage.LeaderboardAllTime:
+		respondWithError(w, "Invalid period: only the balance metric ranks by week or month", http.StatusBadRequest)
+		return
+	case metric != storage.MetricBalance:
+		period = storage.LeaderboardAllTime
+		leaderboard, total, err = storage.GetMetricLeaderboardPage(metric, minBets, limit, offset)
+	case period == "" || period == storage.LeaderboardAllTime:
+		period = storage.LeaderboardAllTime
+		leaderboard, total, err = storage.GetLeaderboardPage(limit, offset)
+	case period == storage.LeaderboardWeekly || period == storage.LeaderboardMonthly:
+		leaderboard, total, err = storage.GetProfitLeaderboardPage(period.Since(time.Now()), limit, offset)
+	default:
+		respondWithError(w, "Invalid period: must be weekly, monthly or all_time", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		logger.Debug(0, "leaderboard_error", "error="+err.Error())
 		respondWithError(w, "Failed to fetch leaderboard", http.StatusInternalServerError)
 		return
 	}
 
-	logger.Debug(0, "leaderboard_success", fmt.Sprintf("count=%d", len(leaderboard)))
+	for i := range leaderboard {
+		leaderboard[i].BalanceDisplay = service.FormatMoney(leaderboard[i].Balance)
+	}
+	if leaderboard == nil {
+		leaderboard = []storage.LeaderboardEntry{}
+	}
+
+	logger.Debug(0, "leaderboard_success", fmt.Sprintf("period=%s metric=%s count=%d offset=%d", period, metric, len(leaderboard), offset))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	if paged {
+		json.NewEncoder(w).Encode(LeaderboardPage{Entries: leaderboard, Total: total, Limit: limit, Offset: offset})
+		return
+	}
 	json.NewEncoder(w).Encode(leaderboard)
 }
+
+// HandleLeaderboardSubpath handles GET /api/leaderboard/me, the caller's rank with the two users
+// above and below them (see storage.LeaderboardPosition)
+func HandleLeaderboardSubpath(w http.ResponseWriter, r *http.Request) {
+	if strings.Trim(r.URL.Path, "/") != "leaderboard/me" {
+		respondWithError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "leaderboard_me_invalid_method", "method="+r.Method)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := currentUser(w, r, "leaderboard_me")
+	if user == nil {
+		return
+	}
+
+	position, err := storage.GetLeaderboardPosition(user.ID, leaderboardNeighbours)
+	if err != nil {
+		logger.Debug(user.TelegramID, "leaderboard_me_error", "error="+err.Error())
+		respondWithError(w, "Failed to fetch leaderboard", http.StatusInternalServerError)
+		return
+	}
+	if position == nil {
+		respondWithError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	for i := range position.Entries {
+		position.Entries[i].BalanceDisplay = service.FormatMoney(position.Entries[i].Balance)
+	}
+
+	logger.Debug(user.TelegramID, "leaderboard_me_success", fmt.Sprintf("rank=%d total=%d", position.Rank, position.Total))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(position)
+}
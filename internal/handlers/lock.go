@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+)
+
+// LockMarketResponse is the response for locking a market
+type LockMarketResponse struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+}
+
+// HandleMarketLock handles POST /api/markets/{id}/lock, the signal that closes a market
+// created with lock_mode MANUAL (sent by its creator) or ORACLE (sent by an oracle).
+func HandleMarketLock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Debug(0, "market_lock_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(w, r, "market_lock")
+	if user == nil {
+		return
+	}
+	telegramID := user.TelegramID
+
+	// Expected path: /markets/{id}/lock (after StripPrefix removes /api)
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 || pathParts[0] != "markets" || pathParts[2] != "lock" {
+		logger.Debug(telegramID, "market_lock_invalid_path", "path="+r.URL.Path)
+		respondWithError(w, "Invalid path format", http.StatusBadRequest)
+		return
+	}
+	marketID, err := strconv.ParseInt(pathParts[1], 10, 64)
+	if err != nil {
+		logger.Debug(telegramID, "market_lock_invalid_id", "id="+pathParts[1])
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	oracle := auth.HasPermission(telegramID, auth.PermissionResolveDisputes)
+	market, err := service.NewMarketService().LockMarket(user, marketID, oracle)
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(telegramID, "market_lock_failed", fmt.Sprintf("market_id=%d error=%s", marketID, errMsg))
+		switch {
+		case strings.Contains(errMsg, "not found"):
+			respondWithError(w, errMsg, http.StatusNotFound)
+		case strings.Contains(errMsg, "only the market creator"), strings.Contains(errMsg, "only an oracle"):
+			respondWithError(w, errMsg, http.StatusForbidden)
+		case strings.Contains(errMsg, "not active"), strings.Contains(errMsg, "cannot be locked early"):
+			respondWithError(w, errMsg, http.StatusConflict)
+		default:
+			respondWithError(w, "Failed to lock market", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(LockMarketResponse{
+		ID:     market.ID,
+		Status: string(market.Status),
+	})
+}
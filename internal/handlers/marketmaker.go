@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+	"predictionbot/internal/storage"
+)
+
+// MarketPriceResponse is the response for GET /api/markets/{id}/price. Position is the
+// signed-in user's holding, if any.
+type MarketPriceResponse struct {
+	service.MarketQuote
+	Position *storage.ShareHolding `json:"position,omitempty"`
+}
+
+// TradeRequest is the request body for POST /api/markets/{id}/trade. A buy spends up to Amount
+// on whole shares of Outcome; a sell sells Shares of Outcome back to the market maker.
+type TradeRequest struct {
+	Action  string `json:"action"`
+	Outcome string `json:"outcome"`
+	Amount  int64  `json:"amount,omitempty"`
+	Shares  int64  `json:"shares,omitempty"`
+}
+
+// TradeResponse is the response for a completed trade
+type TradeResponse struct {
+	service.TradeResult
+	NewBalance int64 `json:"new_balance"`
+}
+
+// HandleMarketPrice handles GET /api/markets/{id}/price, the live prices of a market priced by
+// a market maker. Parimutuel markets have no prices and answer 404.
+func HandleMarketPrice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "market_price_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _ := auth.GetUserIDFromContext(r.Context())
+	marketID, ok := marketMakerPathID(w, r, userID, "price")
+	if !ok {
+		return
+	}
+
+	market, err := storage.GetMarketByID(marketID)
+	if err != nil {
+		logger.Debug(userID, "market_price_error", "error="+err.Error())
+		respondWithError(w, "Failed to fetch market", http.StatusInternalServerError)
+		return
+	}
+	if market == nil || market.Hidden {
+		respondWithError(w, "market not found", http.StatusNotFound)
+		return
+	}
+	mm, err := storage.GetMarketMaker(marketID)
+	if err != nil {
+		logger.Debug(userID, "market_price_error", "error="+err.Error())
+		respondWithError(w, "Failed to fetch prices", http.StatusInternalServerError)
+		return
+	}
+	if mm == nil {
+		respondWithError(w, "market maker not found: market uses parimutuel betting", http.StatusNotFound)
+		return
+	}
+
+	response := MarketPriceResponse{MarketQuote: service.QuoteMarket(mm)}
+	if userID != 0 {
+		if user, err := storage.GetUserByTelegramID(userID); err == nil && user != nil {
+			if holding, err := storage.GetShareHolding(marketID, user.ID); err == nil && (holding.SharesYes != 0 || holding.SharesNo != 0 || holding.Spent != 0) {
+				response.Position = &holding
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleMarketTrade handles POST /api/markets/{id}/trade, buying or selling shares of a market
+// priced by a market maker
+func HandleMarketTrade(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Debug(0, "market_trade_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(w, r, "market_trade")
+	if user == nil {
+		return
+	}
+	telegramID := user.TelegramID
+
+	marketID, ok := marketMakerPathID(w, r, telegramID, "trade")
+	if !ok {
+		return
+	}
+
+	var req TradeRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		logger.Debug(telegramID, "market_trade_invalid_body", "error="+err.Error())
+		respondWithBodyError(w, err)
+		return
+	}
+	logger.Debug(telegramID, "market_trade_attempt", fmt.Sprintf("market_id=%d action=%s outcome=%s amount=%d shares=%d", marketID, req.Action, req.Outcome, req.Amount, req.Shares))
+
+	var result *service.TradeResult
+	var err error
+	mms := service.NewMarketMakerService()
+	switch strings.ToLower(req.Action) {
+	case "buy":
+		result, err = mms.Buy(r.Context(), user.ID, marketID, req.Outcome, req.Amount)
+	case "sell":
+		result, err = mms.Sell(r.Context(), user.ID, marketID, req.Outcome, req.Shares)
+	default:
+		respondWithError(w, "Invalid action: must be 'buy' or 'sell'", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(telegramID, "market_trade_failed", fmt.Sprintf("market_id=%d error=%s", marketID, errMsg))
+		switch {
+		case storage.IsBusyError(err):
+			w.Header().Set("Retry-After", "1")
+			respondWithError(w, "Database busy, please retry", http.StatusServiceUnavailable)
+		case strings.Contains(errMsg, "insufficient funds"):
+			respondWithError(w, errMsg, http.StatusPaymentRequired)
+		case strings.Contains(errMsg, "not found"):
+			respondWithError(w, errMsg, http.StatusNotFound)
+		case strings.Contains(errMsg, "not active"), strings.Contains(errMsg, "expired"):
+			respondWithError(w, errMsg, http.StatusForbidden)
+		case strings.Contains(errMsg, "invalid"), strings.Contains(errMsg, "insufficient shares"):
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		default:
+			respondWithError(w, "Failed to trade shares", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := TradeResponse{TradeResult: *result}
+	if updated, err := storage.GetUserByID(user.ID); err == nil && updated != nil {
+		response.NewBalance = updated.Balance
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// marketMakerPathID parses the market ID from /markets/{id}/{action}, responding with an error
+// when the path is malformed
+func marketMakerPathID(w http.ResponseWriter, r *http.Request, userID int64, action string) (int64, bool) {
+	// Expected path: /markets/{id}/{action} (after StripPrefix removes /api)
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 || pathParts[0] != "markets" || pathParts[2] != action {
+		logger.Debug(userID, "market_"+action+"_invalid_path", "path="+r.URL.Path)
+		respondWithError(w, "Invalid path format", http.StatusBadRequest)
+		return 0, false
+	}
+	marketID, err := strconv.ParseInt(pathParts[1], 10, 64)
+	if err != nil {
+		logger.Debug(userID, "market_"+action+"_invalid_id", "id="+pathParts[1])
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return marketID, true
+}
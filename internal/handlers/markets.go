@@ -2,9 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -13,12 +14,34 @@ import (
 	"predictionbot/internal/logger"
 	"predictionbot/internal/service"
 	"predictionbot/internal/storage"
+	"predictionbot/internal/validation"
 )
 
-// CreateMarketRequest is the request body for creating a market
+// CreateMarketRequest is the request body for creating a market. ResolutionCriteria is optional.
+// Blind markets hide their pools until they lock; sealed markets hide which side each stake
+// is on until they are finalized. Anonymous markets hide their creator outside of moderation.
+// LockMode MANUAL or ORACLE markets lock on a signal (POST /api/markets/{id}/lock) and may omit
+// ExpiresAt, which then defaults to the event lock limit. ExpiresIn is an alternative to
+// ExpiresAt that takes a human deadline such as "friday 18:00" or "in 3 days", read in the
+// user's timezone (see service.ParseDeadline). Icon is an optional emoji shown before the
+// question; without one it is derived from the question's hashtags. IdempotencyKey (or the
+// Idempotency-Key header) is a client-generated token that makes retries safe. PricingMode LMSR
+// prices the market with an automated market maker instead of parimutuel pools: bettors buy
+// and sell shares (POST /api/markets/{id}/trade) at live prices, and Liquidity optionally sets
+// how far each trade moves them.
 type CreateMarketRequest struct {
-	Question  string `json:"question"`
-	ExpiresAt string `json:"expires_at"`
+	Question           string `json:"question" validate:"required"`
+	ExpiresAt          string `json:"expires_at"`
+	ExpiresIn          string `json:"expires_in,omitempty"`
+	ResolutionCriteria string `json:"resolution_criteria,omitempty"`
+	Blind              bool   `json:"blind,omitempty"`
+	Sealed             bool   `json:"sealed,omitempty"`
+	Anonymous          bool   `json:"anonymous,omitempty"`
+	LockMode           string `json:"lock_mode,omitempty"`
+	Icon               string `json:"icon,omitempty"`
+	IdempotencyKey     string `json:"idempotency_key,omitempty"`
+	PricingMode        string `json:"pricing_mode,omitempty"`
+	Liquidity          int64  `json:"liquidity,omitempty" validate:"min=0"`
 }
 
 // CreateMarketResponse is the response for creating a market
@@ -30,6 +53,8 @@ type CreateMarketResponse struct {
 // ErrorResponse is the standard error response format
 type ErrorResponse struct {
 	Message string `json:"message"`
+	// Errors lists the invalid fields of a request that failed validation
+	Errors []validation.FieldError `json:"errors,omitempty"`
 }
 
 // HandleMarkets routes between GET and POST for /api/markets
@@ -47,102 +72,91 @@ func HandleMarkets(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleCreateMarket handles POST /api/markets
+// handleCreateMarket handles POST /api/markets. A repeated creation answers 200 with the existing market.
 func handleCreateMarket(w http.ResponseWriter, r *http.Request) {
-	// Get user ID from context
 	ctx := r.Context()
-	telegramID, ok := auth.GetUserIDFromContext(ctx)
-	if !ok {
-		logger.Debug(0, "markets_create_unauthorized", "path="+r.URL.Path)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(ErrorResponse{Message: "Unauthorized: user not in context"})
-		return
-	}
-
-	// Get user by Telegram ID to retrieve internal user ID
-	user, err := storage.GetUserByTelegramID(telegramID)
-	if err != nil || user == nil {
-		logger.Debug(telegramID, "markets_create_user_not_found", "error=user lookup failed")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(ErrorResponse{Message: "User not found"})
+	user := currentUser(w, r, "markets_create")
+	if user == nil {
 		return
 	}
+	telegramID := user.TelegramID
 
-	// Decode request body
+	// Decode and validate request body
 	var req CreateMarketRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		logger.Debug(telegramID, "markets_create_invalid_body", "error="+err.Error())
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Message: "Invalid request body"})
+	if !decodeAndValidate(w, r, &req, telegramID, "markets_create") {
 		return
 	}
 
-	// Validate question length (10-140 chars)
-	if len(req.Question) < 10 || len(req.Question) > 140 {
-		logger.Debug(telegramID, "markets_create_validation_failed", "question_length_invalid")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Message: "Question must be between 10 and 140 characters"})
+	lockMode, err := storage.ParseLockMode(req.LockMode)
+	if err != nil {
+		logger.Debug(telegramID, "markets_create_invalid_lock_mode", "lock_mode="+req.LockMode)
+		respondWithError(w, "Invalid lock_mode: must be DEADLINE, MANUAL or ORACLE", http.StatusBadRequest)
 		return
 	}
 
-	// Parse expires_at
-	expiresAt, err := time.Parse(time.RFC3339, req.ExpiresAt)
+	pricingMode, err := storage.ParsePricingMode(req.PricingMode)
 	if err != nil {
-		logger.Debug(telegramID, "markets_create_invalid_expiry", "expires_at="+req.ExpiresAt+" error="+err.Error())
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Message: "Invalid expires_at format. Use RFC3339 format (e.g., 2024-01-01T00:00:00Z)"})
+		logger.Debug(telegramID, "markets_create_invalid_pricing_mode", "pricing_mode="+req.PricingMode)
+		respondWithError(w, "Invalid pricing_mode: must be PARIMUTUEL or LMSR", http.StatusBadRequest)
 		return
 	}
 
-	// Validate that expires_at is at least 1 hour in the future
-	minExpiry := time.Now().Add(1 * time.Hour)
-	if expiresAt.Before(minExpiry) {
-		logger.Debug(telegramID, "markets_create_expiry_too_early", "expires_at="+req.ExpiresAt+" min_expiry="+minExpiry.Format(time.RFC3339))
+	// Parse expires_at or expires_in; markets locked on a signal may leave both out
+	var expiresAt time.Time
+	if req.ExpiresIn != "" {
+		if req.ExpiresAt != "" {
+			respondWithError(w, "Use either expires_at or expires_in, not both", http.StatusBadRequest)
+			return
+		}
+		expiresAt, err = service.ParseDeadline(req.ExpiresIn, time.Now(), service.UserLocation(user.ID))
+		if err != nil {
+			logger.Debug(telegramID, "markets_create_invalid_expiry", "expires_in="+req.ExpiresIn+" error="+err.Error())
+			if errors.Is(err, service.ErrDeadlinePast) {
+				respondWithError(w, err.Error(), http.StatusBadRequest)
+			} else {
+				respondWithError(w, "Invalid expires_in: use e.g. \"friday 18:00\", \"in 3 days\" or \"end of month\"", http.StatusBadRequest)
+			}
+			return
+		}
+	} else if req.ExpiresAt != "" || lockMode == storage.LockModeDeadline {
+		expiresAt, err = time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			logger.Debug(telegramID, "markets_create_invalid_expiry", "expires_at="+req.ExpiresAt+" error="+err.Error())
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Message: "Invalid expires_at format. Use RFC3339 format (e.g., 2024-01-01T00:00:00Z)"})
+			return
+		}
+	}
+
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	}
+
+	// Sanitize the question, validate and create the market (shared with the bot /create command)
+	marketService := service.NewMarketService()
+	market, err := marketService.CreateMarket(ctx, user, req.Question, expiresAt, req.ResolutionCriteria, storage.MarketOptions{Blind: req.Blind, Sealed: req.Sealed, Anonymous: req.Anonymous, LockMode: lockMode, Icon: req.Icon, IdempotencyKey: req.IdempotencyKey, PricingMode: pricingMode, Liquidity: req.Liquidity})
+	var duplicate *service.DuplicateMarketError
+	if errors.As(err, &duplicate) {
+		// A retry: answer with the market the first request created
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Message: "Expiration must be at least 1 hour from now"})
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(CreateMarketResponse{ID: duplicate.Market.ID, Status: string(duplicate.Market.Status)})
 		return
 	}
-
-	// Create the market using internal user ID
-	market, err := storage.CreateMarket(user.ID, req.Question, expiresAt)
 	if err != nil {
-		questionPreview := req.Question
-		if len(questionPreview) > 50 {
-			questionPreview = questionPreview[:50]
+		errMsg := err.Error()
+		logger.Debug(telegramID, "markets_create_failed", "error="+errMsg)
+		if strings.Contains(errMsg, "invalid") {
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		} else if strings.Contains(errMsg, "insufficient funds") {
+			respondWithError(w, errMsg, http.StatusPaymentRequired)
+		} else {
+			respondWithError(w, "Failed to create market", http.StatusInternalServerError)
 		}
-		logger.Debug(telegramID, "markets_create_failed", "question="+questionPreview+" error="+err.Error())
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{Message: "Failed to create market"})
 		return
 	}
 
-	// Broadcast new market to public channel
-	go func() {
-		notificationService := service.GetNotificationService()
-		if notificationService != nil {
-			// Use creator name from already-fetched user
-			creatorName := "Anonymous"
-			if user.Username != "" {
-				creatorName = "@" + user.Username
-			} else {
-				creatorName = user.FirstName
-			}
-			notificationService.PublishNewMarket(market, creatorName)
-		}
-	}()
-
-	questionPreview := req.Question
-	if len(questionPreview) > 50 {
-		questionPreview = questionPreview[:50]
-	}
-	logger.Debug(telegramID, "market_created", fmt.Sprintf("market_id=%d question=%s expires_at=%s", market.ID, questionPreview, expiresAt.Format(time.RFC3339)))
 	response := CreateMarketResponse{
 		ID:     market.ID,
 		Status: string(market.Status),
@@ -152,13 +166,110 @@ func handleCreateMarket(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// handleListMarkets handles GET /api/markets
+const (
+	// defaultMarketsPageSize is the page size for /api/markets when only offset is given
+	defaultMarketsPageSize = 20
+	// maxMarketsPageSize is the largest page size accepted for /api/markets
+	maxMarketsPageSize = 100
+)
+
+// MarketPage is the response for GET /api/markets when limit or offset is given.
+// Total counts every market matching the filters, not just this page.
+type MarketPage struct {
+	Markets []storage.MarketWithCreator `json:"markets"`
+	Total   int                         `json:"total"`
+	Limit   int                         `json:"limit"`
+	Offset  int                         `json:"offset"`
+}
+
+// parseMarketsPage reads ?limit= and ?offset=; paged is false when neither is given
+func parseMarketsPage(r *http.Request) (limit, offset int, paged bool, err error) {
+	query := r.URL.Query()
+	limit = defaultMarketsPageSize
+	if value := query.Get("limit"); value != "" {
+		paged = true
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxMarketsPageSize {
+			return 0, 0, false, fmt.Errorf("invalid limit: must be between 1 and %d", maxMarketsPageSize)
+		}
+	}
+	if value := query.Get("offset"); value != "" {
+		paged = true
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, false, fmt.Errorf("invalid offset: must not be negative")
+		}
+	}
+	return limit, offset, paged, nil
+}
+
+// marketGroup returns the group chat whose markets to list: ?group_id=, or the group_<chat id>
+// start parameter of a Mini App opened from a group's link. 0 lists the public markets.
+func marketGroup(r *http.Request) (int64, error) {
+	if value := r.URL.Query().Get("group_id"); value != "" {
+		groupID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || groupID >= 0 {
+			return 0, fmt.Errorf("invalid group_id: must be a group chat ID")
+		}
+		return groupID, nil
+	}
+	// The auth middleware has checked the init data's signature, start parameter included
+	initData, err := url.ParseQuery(r.Header.Get("X-Telegram-Init-Data"))
+	if err != nil {
+		return 0, nil
+	}
+	groupID, _ := service.ParseGroupStartParam(initData.Get("start_param"))
+	return groupID, nil
+}
+
+// handleListMarkets handles GET /api/markets, optionally filtered with ?tag=.
+// Markets of a group chat are listed instead of the public ones for ?group_id= or a Mini App
+// opened from the group (see marketGroup).
+// Signed-in users get the markets they care about first (see service.PersonalizeMarkets).
+// Questions are translated into ?lang=, the viewer's saved language or Accept-Language.
+// With ?limit= and/or ?offset= the answer is a MarketPage instead of a plain list.
 func handleListMarkets(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context (optional - markets are public but we log it for tracking)
 	ctx := r.Context()
 	userID, ok := auth.GetUserIDFromContext(ctx)
 
-	markets, err := storage.ListActiveMarketsWithCreator()
+	limit, offset, paged, err := parseMarketsPage(r)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	groupID, err := marketGroup(r)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Registered users don't see the markets they hid from their feed
+	var viewer *storage.User
+	var viewerID int64
+	if ok {
+		viewer, _ = storage.GetUserByTelegramID(userID)
+	}
+	if viewer != nil {
+		viewerID = viewer.ID
+	}
+
+	tagFilter := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(r.URL.Query().Get("tag")), "#"))
+	// Rank markets for the signed-in user; ?personalize=false keeps the newest-first order
+	personalize := viewer != nil && service.PersonalizationEnabled() && r.URL.Query().Get("personalize") != "false"
+
+	// Ranking needs every market, so only newest-first pages are cut in SQL
+	var markets []storage.MarketWithCreator
+	total := 0
+	if paged && !personalize {
+		markets, total, err = storage.ListActiveMarketsPaged(viewerID, groupID, tagFilter, limit, offset)
+	} else if groupID != 0 {
+		markets, err = storage.ListGroupMarkets(groupID, viewerID)
+	} else if viewer != nil {
+		markets, err = storage.ListActiveMarketsForUser(viewer.ID)
+	} else {
+		markets, err = storage.ListActiveMarketsWithCreator()
+	}
 	if err != nil {
 		if ok {
 			logger.Debug(userID, "markets_list_error", "error="+err.Error())
@@ -171,31 +282,81 @@ func handleListMarkets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get pool totals for each market
+	marketIDs := make([]int64, len(markets))
+	for i := range markets {
+		marketIDs[i] = markets[i].ID
+	}
+	tagsByMarket, err := storage.GetTagsForMarkets(marketIDs)
+	if err != nil {
+		logger.Debug(userID, "markets_list_error", "error="+err.Error())
+		respondWithError(w, "Failed to fetch markets", http.StatusInternalServerError)
+		return
+	}
+
+	// Get pool totals and tags for each market, keeping only markets with the requested tag
+	filtered := markets[:0]
 	for i := range markets {
-		poolYes, poolNo, _ := storage.GetPoolTotals(markets[i].ID)
-		markets[i].PoolYes = poolYes
-		markets[i].PoolNo = poolNo
+		markets[i].Tags = tagsByMarket[markets[i].ID]
+		if markets[i].Tags == nil {
+			markets[i].Tags = []string{}
+		}
+		if tagFilter != "" && !containsTag(markets[i].Tags, tagFilter) {
+			continue
+		}
+		filtered = append(filtered, markets[i])
 	}
+	markets = filtered
+
+	if personalize {
+		if err := service.PersonalizeMarkets(viewer.ID, markets); err != nil {
+			logger.Debug(userID, "markets_personalize_error", "error="+err.Error())
+		}
+		if paged {
+			total = len(markets)
+			markets = markets[min(offset, total):min(offset+limit, total)]
+		}
+	}
+
+	// Show questions in the viewer's language where a translation is cached
+	service.GetTranslationService().Questions(markets, requestLanguage(r, viewer))
 
 	if ok {
-		logger.Debug(userID, "markets_list_success", fmt.Sprintf("count=%d", len(markets)))
+		logger.Debug(userID, "markets_list_success", fmt.Sprintf("count=%d group_id=%d", len(markets), groupID))
 	} else {
 		logger.Debug(0, "markets_list_success", fmt.Sprintf("count=%d", len(markets)))
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	if paged {
+		if markets == nil {
+			markets = []storage.MarketWithCreator{}
+		}
+		json.NewEncoder(w).Encode(MarketPage{Markets: markets, Total: total, Limit: limit, Offset: offset})
+		return
+	}
 	json.NewEncoder(w).Encode(markets)
 }
 
+// containsTag reports whether tags includes tag
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
 // ResolveMarketRequest is the request body for resolving a market
 type ResolveMarketRequest struct {
 	Outcome string `json:"outcome"`
 }
 
-// ResolveMarketResponse is the response for resolving a market
+// ResolveMarketResponse is the response for resolving a market. Big markets answer with
+// status "pending_cosign" and the co-signature request a second signer has to confirm.
 type ResolveMarketResponse struct {
-	Status string `json:"status"`
+	Status   string `json:"status"`
+	CosignID int64  `json:"cosign_id,omitempty"`
 }
 
 // HandleMarketResolve handles POST /api/markets/{id}/resolve
@@ -206,14 +367,12 @@ func HandleMarketResolve(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user ID from context
 	ctx := r.Context()
-	userID, ok := auth.GetUserIDFromContext(ctx)
-	if !ok {
-		logger.Debug(0, "resolve_unauthorized", "path="+r.URL.Path)
-		respondWithError(w, "Unauthorized: user not in context", http.StatusUnauthorized)
+	user := currentUser(w, r, "resolve")
+	if user == nil {
 		return
 	}
+	userID := user.TelegramID
 
 	// Parse market ID from URL path
 	// Expected path: /api/markets/{id}/resolve (after StripPrefix removes /api)
@@ -234,9 +393,9 @@ func HandleMarketResolve(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var req ResolveMarketRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSONBody(w, r, &req); err != nil {
 		logger.Debug(userID, "resolve_invalid_body", "error="+err.Error())
-		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		respondWithBodyError(w, err)
 		return
 	}
 
@@ -249,7 +408,7 @@ func HandleMarketResolve(w http.ResponseWriter, r *http.Request) {
 
 	// Resolve the market using the payout service
 	payoutService := service.NewPayoutService()
-	err = payoutService.ResolveMarket(ctx, marketID, userID, req.Outcome)
+	cosign, err := payoutService.ResolveMarket(ctx, marketID, user, req.Outcome)
 	if err != nil {
 		errMsg := err.Error()
 		logger.Debug(userID, "resolve_failed", fmt.Sprintf("market_id=%d error=%s", marketID, errMsg))
@@ -271,14 +430,20 @@ func HandleMarketResolve(w http.ResponseWriter, r *http.Request) {
 	response := ResolveMarketResponse{
 		Status: "resolved",
 	}
+	if cosign != nil {
+		response.Status = "pending_cosign"
+		response.CosignID = cosign.ID
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
-// RaiseDisputeRequest is the request body for raising a dispute
+// RaiseDisputeRequest is the optional request body for raising a dispute
 type RaiseDisputeRequest struct {
-	MarketID int64 `json:"market_id"`
+	MarketID    int64  `json:"market_id"`
+	Reason      string `json:"reason"`
+	EvidenceURL string `json:"evidence_url"`
 }
 
 // RaiseDisputeResponse is the response for raising a dispute
@@ -286,7 +451,8 @@ type RaiseDisputeResponse struct {
 	Status string `json:"status"`
 }
 
-// HandleDispute handles POST /api/markets/{id}/dispute
+// HandleDispute handles POST /api/markets/{id}/dispute. The body may give a reason and an
+// evidence URL (see RaiseDisputeRequest).
 func HandleDispute(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		logger.Debug(0, "dispute_invalid_method", "method="+r.Method+" path="+r.URL.Path)
@@ -294,14 +460,12 @@ func HandleDispute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user ID from context
 	ctx := r.Context()
-	userID, ok := auth.GetUserIDFromContext(ctx)
-	if !ok {
-		logger.Debug(0, "dispute_unauthorized", "path="+r.URL.Path)
-		respondWithError(w, "Unauthorized: user not in context", http.StatusUnauthorized)
+	user := currentUser(w, r, "dispute")
+	if user == nil {
 		return
 	}
+	userID := user.TelegramID
 
 	// Parse market ID from URL path
 	// Expected path: /api/markets/{id}/dispute (after StripPrefix removes /api)
@@ -319,14 +483,29 @@ func HandleDispute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var req RaiseDisputeRequest
+	if r.Body != nil && r.ContentLength != 0 {
+		if err := decodeJSONBody(w, r, &req); err != nil {
+			logger.Debug(userID, "dispute_invalid_body", "error="+err.Error())
+			respondWithBodyError(w, err)
+			return
+		}
+	}
+
 	// Raise dispute using the payout service
 	payoutService := service.NewPayoutService()
-	err = payoutService.RaiseDispute(ctx, marketID, userID)
+	err = payoutService.RaiseDispute(ctx, marketID, user, req.Reason, req.EvidenceURL)
 	if err != nil {
 		errMsg := err.Error()
 		logger.Debug(userID, "dispute_failed", fmt.Sprintf("market_id=%d error=%s", marketID, errMsg))
-		if strings.Contains(errMsg, "not found") {
+		if strings.HasPrefix(errMsg, "invalid") {
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		} else if strings.Contains(errMsg, "not found") {
 			respondWithError(w, errMsg, http.StatusNotFound)
+		} else if strings.Contains(errMsg, "must have placed a bet") {
+			respondWithError(w, errMsg, http.StatusForbidden)
+		} else if strings.Contains(errMsg, "insufficient funds") {
+			respondWithError(w, errMsg, http.StatusPaymentRequired)
 		} else if strings.Contains(errMsg, "cannot be disputed") {
 			respondWithError(w, errMsg, http.StatusConflict)
 		} else {
@@ -346,17 +525,23 @@ func HandleDispute(w http.ResponseWriter, r *http.Request) {
 
 // AdminResolveRequest is the request body for admin force resolve
 type AdminResolveRequest struct {
-	MarketID int64  `json:"market_id"`
-	Outcome  string `json:"outcome"`
+	MarketID int64  `json:"market_id" validate:"required,min=1"`
+	Outcome  string `json:"outcome" validate:"required,oneof=YES NO"`
+	// DryRun computes the payouts without finalizing the market
+	DryRun bool `json:"dry_run"`
 }
 
 // AdminResolveResponse is the response for admin force resolve
 type AdminResolveResponse struct {
 	Status           string `json:"status"`
 	PayoutsProcessed int    `json:"payouts_processed"`
+	// Preview is the full payout distribution of a dry run
+	Preview *service.FinalizationPreview `json:"preview,omitempty"`
 }
 
 // HandleAdminResolve handles POST /api/admin/resolve
+// With dry_run set nothing is committed: the response previews the payouts the outcome would
+// produce, so an override can be checked before it is applied.
 func HandleAdminResolve(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		logger.Debug(0, "admin_resolve_invalid_method", "method="+r.Method+" path="+r.URL.Path)
@@ -373,23 +558,14 @@ func HandleAdminResolve(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse request body
+	// Parse and validate request body
 	var req AdminResolveRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		logger.Debug(userID, "admin_resolve_invalid_body", "error="+err.Error())
-		respondWithError(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// Validate outcome
-	if req.Outcome != "YES" && req.Outcome != "NO" {
-		logger.Debug(userID, "admin_resolve_invalid_outcome", "outcome="+req.Outcome)
-		respondWithError(w, "Invalid outcome: must be 'YES' or 'NO'", http.StatusBadRequest)
+	if !decodeAndValidate(w, r, &req, userID, "admin_resolve") {
 		return
 	}
 
-	// Check if user is admin
-	if !isAdmin(userID) {
+	// Check if user may resolve disputes (admins and oracles)
+	if !auth.HasPermission(userID, auth.PermissionResolveDisputes) {
 		logger.Debug(userID, "admin_resolve_not_admin", "user is not an admin")
 		respondWithError(w, "Forbidden: admin access required", http.StatusForbidden)
 		return
@@ -397,7 +573,17 @@ func HandleAdminResolve(w http.ResponseWriter, r *http.Request) {
 
 	// Finalize the market using the payout service with force outcome
 	payoutService := service.NewPayoutService()
-	payoutsProcessed, err := payoutService.FinalizeMarket(ctx, req.MarketID, req.Outcome)
+	var preview *service.FinalizationPreview
+	var payoutsProcessed int
+	var err error
+	if req.DryRun {
+		preview, err = payoutService.PreviewFinalization(ctx, req.MarketID, req.Outcome)
+		if err == nil {
+			payoutsProcessed = preview.PayoutsProcessed
+		}
+	} else {
+		payoutsProcessed, err = payoutService.FinalizeMarket(ctx, req.MarketID, req.Outcome)
+	}
 	if err != nil {
 		errMsg := err.Error()
 		logger.Debug(userID, "admin_resolve_failed", fmt.Sprintf("market_id=%d error=%s", req.MarketID, errMsg))
@@ -413,18 +599,193 @@ func HandleAdminResolve(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logger.Debug(userID, "admin_resolve_success", fmt.Sprintf("market_id=%d outcome=%s payouts=%d", req.MarketID, req.Outcome, payoutsProcessed))
+	logger.Debug(userID, "admin_resolve_success", fmt.Sprintf("market_id=%d outcome=%s payouts=%d dry_run=%t", req.MarketID, req.Outcome, payoutsProcessed, req.DryRun))
 	response := AdminResolveResponse{
 		Status:           "finalized",
 		PayoutsProcessed: payoutsProcessed,
 	}
+	if req.DryRun {
+		response.Status = "dry_run"
+		response.Preview = preview
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// MarketDetailResponse is the response for GET /api/markets/{id}
+type MarketDetailResponse struct {
+	ID                 int64                      `json:"id"`
+	Icon               string                     `json:"icon"`
+	Question           string                     `json:"question"`
+	OriginalQuestion   string                     `json:"original_question,omitempty"`
+	Status             string                     `json:"status"`
+	LockMode           string                     `json:"lock_mode"`
+	PricingMode        string                     `json:"pricing_mode"`
+	Outcome            string                     `json:"outcome,omitempty"`
+	CreatorName        string                     `json:"creator_name"`
+	ExpiresAt          string                     `json:"expires_at"`
+	PoolYes            int64                      `json:"pool_yes"`
+	PoolNo             int64                      `json:"pool_no"`
+	Blind              bool                       `json:"blind,omitempty"`
+	PoolsHidden        bool                       `json:"pools_hidden,omitempty"`
+	Sealed             bool                       `json:"sealed,omitempty"`
+	SidesHidden        bool                       `json:"sides_hidden,omitempty"`
+	Anonymous          bool                       `json:"anonymous,omitempty"`
+	PoolTotal          int64                      `json:"pool_total,omitempty"`
+	Tags               []string                   `json:"tags"`
+	Description        string                     `json:"description,omitempty"`
+	ResolutionCriteria string                     `json:"resolution_criteria,omitempty"`
+	Preview            *service.LinkPreview       `json:"preview,omitempty"`
+	ProposalsOpenAt    string                     `json:"proposals_open_at,omitempty"`
+	Proposal           *storage.CommunityProposal `json:"proposal,omitempty"`
+}
+
+// HandleMarketDetail handles GET /api/markets/{id}
+// The preview is only present when the question links to an allowlisted site,
+// the description only when generated descriptions are enabled (see service.DescriptionService).
+// The question is translated like in the list; original_question then holds the creator's wording.
+// Blind markets report zero pools with pools_hidden set until they lock; sealed markets
+// report only pool_total with sides_hidden set until they are finalized.
+// Anonymous markets name their creator only to the creator and to moderators.
+// Locked markets report when bettors may propose an outcome in place of the creator
+// (proposals_open_at) and the proposal being voted on, if any.
+func HandleMarketDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "market_detail_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _ := auth.GetUserIDFromContext(r.Context())
+
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	marketID, err := strconv.ParseInt(pathParts[len(pathParts)-1], 10, 64)
+	if err != nil {
+		logger.Debug(userID, "market_detail_invalid_id", "path="+r.URL.Path)
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	market, err := storage.GetMarketByID(marketID)
+	if err != nil {
+		logger.Debug(userID, "market_detail_error", "error="+err.Error())
+		respondWithError(w, "Failed to fetch market", http.StatusInternalServerError)
+		return
+	}
+	if market == nil || market.Hidden {
+		respondWithError(w, "market not found", http.StatusNotFound)
+		return
+	}
+
+	pools, err := storage.GetPublicPools(marketID)
+	if err != nil {
+		logger.Debug(userID, "market_detail_error", "error="+err.Error())
+		respondWithError(w, "Failed to fetch market", http.StatusInternalServerError)
+		return
+	}
+
+	creator, _ := storage.GetUserByID(market.CreatorID)
+	creatorName := market.PublicCreatorName(creator)
+	if market.Anonymous && userID != 0 && creator != nil && creator.FirstName != "" &&
+		(creator.TelegramID == userID || auth.HasPermission(userID, auth.PermissionHideMarkets)) {
+		// Moderators need to know who is behind an anonymous market
+		creatorName = creator.FirstName
+	}
+
+	tags, err := storage.GetMarketTags(marketID)
+	if err != nil {
+		logger.Debug(userID, "market_detail_error", "error="+err.Error())
+		respondWithError(w, "Failed to fetch market", http.StatusInternalServerError)
+		return
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+
+	description, err := storage.GetMarketDescription(marketID)
+	if err != nil {
+		logger.Debug(userID, "market_detail_error", "error="+err.Error())
+		respondWithError(w, "Failed to fetch market", http.StatusInternalServerError)
+		return
+	}
+
+	criteria, err := storage.GetMarketResolutionCriteria(marketID)
+	if err != nil {
+		logger.Debug(userID, "market_detail_error", "error="+err.Error())
+		respondWithError(w, "Failed to fetch market", http.StatusInternalServerError)
+		return
+	}
+
+	// Translate the question for the viewer, keeping the original alongside it
+	var viewer *storage.User
+	if userID != 0 {
+		viewer, _ = storage.GetUserByTelegramID(userID)
+	}
+	question := service.GetTranslationService().Question(r.Context(), marketID, market.Question, requestLanguage(r, viewer))
+	originalQuestion := ""
+	if question != market.Question {
+		originalQuestion = market.Question
+	}
+
+	response := MarketDetailResponse{
+		ID:                 market.ID,
+		Icon:               market.Icon,
+		Question:           question,
+		OriginalQuestion:   originalQuestion,
+		Status:             string(market.Status),
+		LockMode:           string(market.LockMode),
+		PricingMode:        string(market.PricingMode),
+		Outcome:            market.Outcome,
+		CreatorName:        creatorName,
+		ExpiresAt:          market.ExpiresAt.Format(time.RFC3339),
+		PoolYes:            pools.Yes,
+		PoolNo:             pools.No,
+		Blind:              market.Blind,
+		PoolsHidden:        pools.PoolsHidden,
+		Sealed:             market.Sealed,
+		SidesHidden:        pools.SidesHidden,
+		Anonymous:          market.Anonymous,
+		Tags:               tags,
+		Description:        description,
+		ResolutionCriteria: criteria,
+		Preview:            service.GetPreviewService().Lookup(r.Context(), market.Question),
+	}
+	if pools.SidesHidden {
+		response.PoolTotal = pools.Total
+	}
+	if market.Status == storage.MarketStatusLocked {
+		if absence := service.CreatorAbsence(); absence > 0 {
+			if lockedAt, err := storage.GetMarketLockedAt(marketID); err == nil {
+				response.ProposalsOpenAt = lockedAt.Add(absence).Format(time.RFC3339)
+			}
+		}
+		response.Proposal, _ = storage.GetOpenCommunityProposal(marketID)
+	}
+
+	logger.Debug(userID, "market_detail_success", fmt.Sprintf("market_id=%d", marketID))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
-// HandleMarketSubpath routes /api/markets/{id}/resolve and /api/markets/{id}/dispute
+// HandleMarketSubpath routes /api/markets/search, /api/markets/weather, /api/markets/{id}/resolve, /dispute, /transfer, /hide, /winners, /translations, /suggested-stakes, /price, /trade, /webhook and /oracle
 func HandleMarketSubpath(w http.ResponseWriter, r *http.Request) {
+	if strings.Trim(r.URL.Path, "/") == "markets/search" {
+		HandleMarketSearch(w, r)
+		return
+	}
+	if strings.Trim(r.URL.Path, "/") == "markets/weather" {
+		HandleWeatherMarket(w, r)
+		return
+	}
+
+	// GET /markets/{id} returns the market detail
+	if parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/"); len(parts) == 2 && parts[0] == "markets" {
+		HandleMarketDetail(w, r)
+		return
+	}
+
 	// Check if path ends with /resolve or /dispute
 	if strings.HasSuffix(r.URL.Path, "/resolve") {
 		HandleMarketResolve(w, r)
@@ -434,42 +795,69 @@ func HandleMarketSubpath(w http.ResponseWriter, r *http.Request) {
 		HandleDispute(w, r)
 		return
 	}
+	if strings.HasSuffix(r.URL.Path, "/transfer") {
+		HandleMarketTransfer(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/hide") {
+		HandleMarketHide(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/winners") {
+		HandleMarketWinners(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/translations") {
+		HandleMarketTranslations(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/suggested-stakes") {
+		HandleSuggestedStakes(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/lock") {
+		HandleMarketLock(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/proposal") {
+		HandleMarketProposal(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/proposal/vote") {
+		HandleProposalVote(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/share") {
+		HandleMarketShare(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/card.png") || strings.HasSuffix(r.URL.Path, "/card.svg") {
+		HandleMarketCard(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/qr.png") {
+		HandleMarketQR(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/price") {
+		HandleMarketPrice(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/trade") {
+		HandleMarketTrade(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/webhook") {
+		HandleMarketWebhook(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/oracle") {
+		HandleMarketOracle(w, r)
+		return
+	}
 	// If neither, return 404
 	logger.Debug(0, "market_subpath_not_found", "path="+r.URL.Path)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusNotFound)
 	json.NewEncoder(w).Encode(ErrorResponse{Message: "Not found"})
 }
-
-// isAdmin checks if a user is an admin based on ADMIN_USER_IDS environment variable
-func isAdmin(telegramID int64) bool {
-	adminIDs := getAdminIDs()
-	for _, id := range adminIDs {
-		if id == telegramID {
-			return true
-		}
-	}
-	return false
-}
-
-// getAdminIDs returns the list of admin user IDs from environment variables
-func getAdminIDs() []int64 {
-	adminIDsEnv := os.Getenv("ADMIN_USER_IDS")
-	if adminIDsEnv == "" {
-		return nil
-	}
-
-	var adminIDs []int64
-	parts := strings.Split(adminIDsEnv, ",")
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
-		}
-		var id int64
-		if _, err := fmt.Sscanf(part, "%d", &id); err == nil {
-			adminIDs = append(adminIDs, id)
-		}
-	}
-	return adminIDs
-}
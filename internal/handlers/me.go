@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"predictionbot/internal/auth"
 	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
 	"predictionbot/internal/storage"
 )
 
@@ -19,6 +21,14 @@ type UserResponse struct {
 	FirstName      string `json:"first_name"`
 	Balance        int64  `json:"balance"`
 	BalanceDisplay string `json:"balance_display"`
+	// Currency labels balance_display and every other amount in the Web App
+	Currency service.Currency `json:"currency"`
+	// Hints so the Web App can pick the right call-to-action without extra requests
+	EligibleForBailout bool    `json:"eligible_for_bailout"`
+	BailoutAvailableAt *string `json:"bailout_available_at,omitempty"`
+	LockedInOpenBets   int64   `json:"locked_in_open_bets"`
+	// Vouchers are the user's unredeemed vouchers, the one backing their next bet first
+	Vouchers []storage.Voucher `json:"vouchers,omitempty"`
 }
 
 // HandleMe handles the GET /api/me endpoint
@@ -51,8 +61,8 @@ func HandleMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Format balance as integer
-	balanceDisplay := fmt.Sprintf("%d", user.Balance)
+	// Format balance with the currency's decimals
+	balanceDisplay := service.FormatMoney(user.Balance)
 
 	response := UserResponse{
 		ID:             user.ID,
@@ -61,9 +71,42 @@ func HandleMe(w http.ResponseWriter, r *http.Request) {
 		FirstName:      user.FirstName,
 		Balance:        user.Balance,
 		BalanceDisplay: balanceDisplay,
+		Currency:       service.GetCurrency(),
 	}
 
-	logger.Debug(telegramID, "me_success", fmt.Sprintf("telegram_id=%d balance=%d", user.TelegramID, user.Balance))
+	// Bailout hints: eligible when broke and off cooldown; available_at is set while on cooldown
+	lastBailout, hasBailout, err := storage.GetLastBailout(user.ID)
+	if err != nil {
+		logger.Debug(telegramID, "me_error", "error="+err.Error())
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return
+	}
+	onCooldown := false
+	if hasBailout {
+		nextAvailable := lastBailout.Add(storage.BailoutCooldown)
+		if time.Now().Before(nextAvailable) {
+			onCooldown = true
+			availableAt := nextAvailable.UTC().Format(time.RFC3339)
+			response.BailoutAvailableAt = &availableAt
+		}
+	}
+	response.EligibleForBailout = user.Balance < storage.BailoutBalanceThreshold && !onCooldown
+
+	response.LockedInOpenBets, err = storage.GetLockedInOpenBets(user.ID)
+	if err != nil {
+		logger.Debug(telegramID, "me_error", "error="+err.Error())
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return
+	}
+
+	response.Vouchers, err = storage.ListAvailableVouchers(user.ID)
+	if err != nil {
+		logger.Debug(telegramID, "me_error", "error="+err.Error())
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Debug(telegramID, "me_success", fmt.Sprintf("telegram_id=%d balance=%d eligible_for_bailout=%t locked=%d", user.TelegramID, user.Balance, response.EligibleForBailout, response.LockedInOpenBets))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
@@ -172,3 +215,150 @@ func HandleBailout(w http.ResponseWriter, r *http.Request) {
 		NewBalance: newBalance,
 	})
 }
+
+// PreferencesRequest is the request body for PUT /api/me/preferences.
+// Omitted fields keep their current value.
+type PreferencesRequest struct {
+	ShowInWinners       *bool `json:"show_in_winners"`
+	StreakNotifications *bool `json:"streak_notifications"`
+	BetReceipts         *bool `json:"bet_receipts"`
+	LockSummaries       *bool `json:"lock_summaries"`
+	OddsSwings          *bool `json:"odds_swings"`
+	// Muted silences every DM except payouts and refunds, like /mute
+	Muted *bool `json:"muted"`
+	// Language is a language code such as "de"; "" goes back to the default
+	Language *string `json:"language"`
+	// Timezone is an IANA timezone such as "Europe/Berlin"; "" goes back to UTC
+	Timezone *string `json:"timezone"`
+	// DigestHour is the local hour (0-23) of the daily digest DM; -1 turns it off
+	DigestHour *int `json:"digest_hour"`
+	// Transport names a registered DM transport such as "matrix"; "" or "telegram" goes back to Telegram
+	Transport *string `json:"transport"`
+	// TransportTarget is the address on that transport, e.g. a Matrix room ID or a webhook URL
+	TransportTarget *string `json:"transport_target"`
+}
+
+// HandlePreferences handles GET and PUT /api/me/preferences.
+// show_in_winners opts the user in to being named in winner lists and results posts;
+// streak_notifications controls the win streak DMs; bet_receipts the DM confirming each bet;
+// lock_summaries the DM with the user's position when a market locks; odds_swings the DM when a
+// market they bet on moves; muted silences every DM but payouts and refunds;
+// language picks the language questions are shown in;
+// timezone is used to read deadlines such as "friday 18:00"; digest_hour schedules the daily digest;
+// transport and transport_target send the user's DMs through another registered transport.
+func HandlePreferences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		logger.Debug(0, "preferences_invalid_method", "method="+r.Method)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(w, r, "preferences")
+	if user == nil {
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var req PreferencesRequest
+		if err := decodeJSONBody(w, r, &req); err != nil {
+			logger.Debug(user.TelegramID, "preferences_invalid_body", "error="+err.Error())
+			respondWithBodyError(w, err)
+			return
+		}
+		if req.ShowInWinners == nil && req.StreakNotifications == nil && req.BetReceipts == nil && req.LockSummaries == nil && req.OddsSwings == nil && req.Muted == nil && req.Language == nil && req.Timezone == nil && req.DigestHour == nil && req.Transport == nil {
+			respondWithError(w, "no preferences to update", http.StatusBadRequest)
+			return
+		}
+		var language string
+		if req.Language != nil && strings.TrimSpace(*req.Language) != "" {
+			if language = service.NormalizeLanguage(*req.Language); language == "" {
+				respondWithError(w, "invalid language", http.StatusBadRequest)
+				return
+			}
+		}
+		var timezone string
+		if req.Timezone != nil {
+			loc, err := service.LoadTimezone(*req.Timezone)
+			if err != nil {
+				respondWithError(w, "invalid timezone", http.StatusBadRequest)
+				return
+			}
+			if strings.TrimSpace(*req.Timezone) != "" {
+				timezone = loc.String()
+			}
+		}
+		if req.DigestHour != nil && (*req.DigestHour < storage.DigestOff || *req.DigestHour > 23) {
+			respondWithError(w, "invalid digest hour: must be 0-23, or -1 to turn it off", http.StatusBadRequest)
+			return
+		}
+		var transport, target string
+		if req.Transport != nil {
+			if transport = strings.ToLower(strings.TrimSpace(*req.Transport)); transport == "telegram" {
+				transport = ""
+			}
+			if transport != "" {
+				registered, ok := service.LookupTransport(transport)
+				if !ok {
+					respondWithError(w, "invalid transport: available are "+strings.Join(append([]string{"telegram"}, service.TransportNames()...), ", "), http.StatusBadRequest)
+					return
+				}
+				if req.TransportTarget != nil {
+					target = strings.TrimSpace(*req.TransportTarget)
+				}
+				if err := registered.ValidateTarget(target); err != nil {
+					respondWithError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+		}
+
+		var err error
+		if req.ShowInWinners != nil {
+			err = storage.SetShowInWinners(user.ID, *req.ShowInWinners)
+		}
+		if err == nil && req.StreakNotifications != nil {
+			err = storage.SetStreakNotifications(user.ID, *req.StreakNotifications)
+		}
+		if err == nil && req.BetReceipts != nil {
+			err = storage.SetBetReceipts(user.ID, *req.BetReceipts)
+		}
+		if err == nil && req.LockSummaries != nil {
+			err = storage.SetLockSummaries(user.ID, *req.LockSummaries)
+		}
+		if err == nil && req.OddsSwings != nil {
+			err = storage.SetOddsSwingNotifications(user.ID, *req.OddsSwings)
+		}
+		if err == nil && req.Muted != nil {
+			err = storage.SetNotificationsMuted(user.ID, *req.Muted)
+		}
+		if err == nil && req.Language != nil {
+			err = storage.SetUserLanguage(user.ID, language)
+		}
+		if err == nil && req.Timezone != nil {
+			err = storage.SetUserTimezone(user.ID, timezone)
+		}
+		if err == nil && req.DigestHour != nil {
+			err = storage.SetDigestHour(user.ID, *req.DigestHour)
+		}
+		if err == nil && req.Transport != nil {
+			err = storage.SetNotificationTransport(user.ID, transport, target)
+		}
+		if err != nil {
+			logger.Debug(user.TelegramID, "preferences_error", "error="+err.Error())
+			respondWithError(w, "Failed to update preferences", http.StatusInternalServerError)
+			return
+		}
+		logger.Debug(user.TelegramID, "preferences_updated", "")
+	}
+
+	prefs, err := storage.GetUserPreferences(user.ID)
+	if err != nil {
+		logger.Debug(user.TelegramID, "preferences_error", "error="+err.Error())
+		respondWithError(w, "Failed to get preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(prefs)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+	"predictionbot/internal/storage"
+)
+
+// maxListedOracles caps GET /api/admin/oracles
+const maxListedOracles = 100
+
+// HandleMarketOracle handles GET and PUT /api/markets/{id}/oracle. PUT lets the market's
+// creator set its HTTP-JSON oracle, which waits for an admin's review; GET shows anyone the
+// oracle and, once it resolved the market, the value it read and the payload it read it from.
+func HandleMarketOracle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		logger.Debug(0, "market_oracle_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract market ID from path: /markets/{id}/oracle
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 {
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+	marketID, err := strconv.ParseInt(pathParts[1], 10, 64)
+	if err != nil {
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	user := currentUser(w, r, "market_oracle")
+	if user == nil {
+		return
+	}
+
+	var oracle *storage.MarketOracle
+	if r.Method == http.MethodGet {
+		oracle, err = service.GetMarketOracle(marketID)
+	} else {
+		var req service.OracleMapping
+		if err := decodeJSONBody(w, r, &req); err != nil {
+			logger.Debug(user.TelegramID, "market_oracle_invalid_body", "error="+err.Error())
+			respondWithBodyError(w, err)
+			return
+		}
+		oracle, err = service.SetMarketOracle(user, marketID, req)
+	}
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(user.TelegramID, "market_oracle_failed", fmt.Sprintf("market_id=%d method=%s error=%s", marketID, r.Method, errMsg))
+		switch {
+		case strings.Contains(errMsg, "not found"):
+			respondWithError(w, errMsg, http.StatusNotFound)
+		case strings.Contains(errMsg, "only the market creator"):
+			respondWithError(w, errMsg, http.StatusForbidden)
+		case strings.Contains(errMsg, "not open"):
+			respondWithError(w, errMsg, http.StatusConflict)
+		case strings.Contains(errMsg, "invalid"):
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		default:
+			respondWithError(w, "Failed to update oracle", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(oracle)
+}
+
+// HandleAdminOracles handles GET /api/admin/oracles, the HTTP-JSON oracles in ?status=
+// (default PENDING_REVIEW, the ones waiting for review), oldest first
+func HandleAdminOracles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "admin_oracles_invalid_method", "method="+r.Method)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	actor := requirePermission(w, r, auth.PermissionReviewOracles, "admin_oracles")
+	if actor == nil {
+		return
+	}
+
+	status := storage.OraclePendingReview
+	if raw := r.URL.Query().Get("status"); raw != "" {
+		status = storage.OracleStatus(strings.ToUpper(raw))
+	}
+	if !status.Valid() {
+		respondWithError(w, "Invalid status: must be PENDING_REVIEW, APPROVED, REJECTED, RESOLVED or FAILED", http.StatusBadRequest)
+		return
+	}
+
+	oracles, err := storage.ListMarketOracles(status, maxListedOracles)
+	if err != nil {
+		logger.Debug(actor.TelegramID, "admin_oracles_list_failed", "error="+err.Error())
+		respondWithError(w, "Failed to list oracles", http.StatusInternalServerError)
+		return
+	}
+	if oracles == nil {
+		oracles = []storage.MarketOracle{}
+	}
+
+	logger.Debug(actor.TelegramID, "admin_oracles_listed", fmt.Sprintf("status=%s count=%d", status, len(oracles)))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(oracles)
+}
+
+// HandleAdminOracleSubpath handles POST /api/admin/oracles/{market_id}/approve and /reject, and
+// POST /api/admin/oracles/{market_id}/preview, which fetches the oracle's document now and
+// returns what it would resolve with, without resolving anything
+func HandleAdminOracleSubpath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Debug(0, "admin_oracle_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Expected path: /admin/oracles/{market_id}/{action} (after StripPrefix removes /api)
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 4 || pathParts[0] != "admin" || pathParts[1] != "oracles" ||
+		(pathParts[3] != "approve" && pathParts[3] != "reject" && pathParts[3] != "preview") {
+		logger.Debug(0, "admin_oracle_invalid_path", "path="+r.URL.Path)
+		respondWithError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	marketID, err := strconv.ParseInt(pathParts[2], 10, 64)
+	if err != nil {
+		logger.Debug(0, "admin_oracle_invalid_id", "id="+pathParts[2])
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	actor := requirePermission(w, r, auth.PermissionReviewOracles, "admin_oracle")
+	if actor == nil {
+		return
+	}
+
+	var result interface{}
+	switch pathParts[3] {
+	case "approve":
+		result, err = service.ApproveMarketOracle(actor, marketID)
+	case "reject":
+		result, err = service.RejectMarketOracle(actor, marketID)
+	case "preview":
+		result, err = service.PreviewMarketOracle(r.Context(), actor, marketID)
+	}
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(actor.TelegramID, "admin_oracle_"+pathParts[3]+"_failed", fmt.Sprintf("market_id=%d error=%s", marketID, errMsg))
+		switch {
+		case strings.Contains(errMsg, "not found"):
+			respondWithError(w, errMsg, http.StatusNotFound)
+		case strings.Contains(errMsg, "not pending"):
+			respondWithError(w, errMsg, http.StatusConflict)
+		case strings.Contains(errMsg, "invalid"):
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		case pathParts[3] == "preview":
+			// The document could not be fetched or read: show the admin why
+			respondWithError(w, errMsg, http.StatusBadGateway)
+		default:
+			respondWithError(w, "Failed to review oracle", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// RedeemPromoRequest is the request body for POST /api/me/redeem
+type RedeemPromoRequest struct {
+	Code string `json:"code"`
+}
+
+// CreatePromoCodeRequest is the request body for POST /api/admin/promo-codes. MaxUses 0 allows
+// any number of users; ExpiresInHours 0 never expires.
+type CreatePromoCodeRequest struct {
+	Code           string `json:"code"`
+	Amount         int64  `json:"amount"`
+	MaxUses        int64  `json:"max_uses,omitempty"`
+	ExpiresInHours int    `json:"expires_in_hours,omitempty"`
+}
+
+// HandleRedeemPromo handles POST /api/me/redeem, crediting a promo code's amount to the user
+func HandleRedeemPromo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Debug(0, "promo_redeem_invalid_method", "method="+r.Method)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(w, r, "promo_redeem")
+	if user == nil {
+		return
+	}
+	telegramID := user.TelegramID
+
+	var req RedeemPromoRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		logger.Debug(telegramID, "promo_redeem_invalid_body", "error="+err.Error())
+		respondWithBodyError(w, err)
+		return
+	}
+	if strings.TrimSpace(req.Code) == "" {
+		respondWithError(w, "Missing promo code", http.StatusBadRequest)
+		return
+	}
+
+	redemption, err := storage.RedeemPromoCode(r.Context(), user.ID, req.Code)
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(telegramID, "promo_redeem_failed", "code="+storage.NormalizePromoCode(req.Code)+" error="+errMsg)
+		switch {
+		case storage.IsBusyError(err):
+			w.Header().Set("Retry-After", "1")
+			respondWithError(w, "Database busy, please retry", http.StatusServiceUnavailable)
+		case strings.Contains(errMsg, "not found"):
+			respondWithError(w, errMsg, http.StatusNotFound)
+		case strings.Contains(errMsg, "already redeemed"):
+			respondWithError(w, errMsg, http.StatusConflict)
+		case strings.Contains(errMsg, "expired"), strings.Contains(errMsg, "used up"):
+			respondWithError(w, errMsg, http.StatusGone)
+		default:
+			respondWithError(w, "Failed to redeem promo code", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logger.Debug(telegramID, "promo_redeemed", fmt.Sprintf("code=%s amount=%d", redemption.Code, redemption.Amount))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(redemption)
+}
+
+// HandleAdminPromoCodes handles GET /api/admin/promo-codes, listing every promo code, and POST,
+// creating one
+func HandleAdminPromoCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		logger.Debug(0, "admin_promo_codes_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	actor := requirePermission(w, r, auth.PermissionAdjustBalances, "admin_promo_codes")
+	if actor == nil {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		codes, err := storage.ListPromoCodes()
+		if err != nil {
+			logger.Debug(actor.TelegramID, "admin_promo_codes_error", "error="+err.Error())
+			respondWithError(w, "Failed to list promo codes", http.StatusInternalServerError)
+			return
+		}
+		if codes == nil {
+			codes = []storage.PromoCode{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(codes)
+		return
+	}
+
+	var req CreatePromoCodeRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		logger.Debug(actor.TelegramID, "admin_promo_codes_invalid_body", "error="+err.Error())
+		respondWithBodyError(w, err)
+		return
+	}
+	if req.ExpiresInHours < 0 {
+		respondWithError(w, "Invalid expiry: expires_in_hours must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	var expiresAt time.Time
+	if req.ExpiresInHours > 0 {
+		expiresAt = time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+	}
+	code, err := storage.CreatePromoCode(req.Code, req.Amount, req.MaxUses, expiresAt, actor.ID)
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(actor.TelegramID, "admin_promo_codes_failed", "code="+req.Code+" error="+errMsg)
+		switch {
+		case strings.Contains(errMsg, "invalid"):
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		case strings.Contains(errMsg, "already exists"):
+			respondWithError(w, errMsg, http.StatusConflict)
+		default:
+			respondWithError(w, "Failed to create promo code", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logger.Debug(actor.TelegramID, "admin_promo_code_created", fmt.Sprintf("code=%s amount=%d max_uses=%d", code.Code, code.Amount, code.MaxUses))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(code)
+}
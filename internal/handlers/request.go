@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/validation"
+)
+
+// maxRequestBodyBytes caps the size of JSON request bodies
+const maxRequestBodyBytes = 64 << 10 // 64 KB
+
+// decodeJSONBody strictly decodes a size-limited JSON request body into dst.
+// Unknown fields (e.g. a typo like "ammount") and trailing data are rejected.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		return err
+	}
+
+	// The body must contain exactly one JSON value
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("request body must contain a single JSON object")
+	}
+	return nil
+}
+
+// respondWithBodyError writes the error response for a body that failed decodeJSONBody
+func respondWithBodyError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondWithError(w, fmt.Sprintf("Request body too large: limit is %d bytes", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	respondWithError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+}
+
+// decodeAndValidate decodes a request body with decodeJSONBody and checks the constraints in its
+// validate tags. A bad body is answered here, invalid fields with one entry each in "errors",
+// and logged as {action}_invalid_body; it then returns false.
+func decodeAndValidate(w http.ResponseWriter, r *http.Request, dst interface{}, telegramID int64, action string) bool {
+	err := decodeJSONBody(w, r, dst)
+	if err == nil {
+		err = validation.Struct(dst)
+	}
+	if err == nil {
+		return true
+	}
+
+	logger.Debug(telegramID, action+"_invalid_body", "error="+err.Error())
+	var fieldErrs validation.Errors
+	if !errors.As(err, &fieldErrs) {
+		respondWithBodyError(w, err)
+		return false
+	}
+
+	messages := make([]string, len(fieldErrs))
+	for i, fieldErr := range fieldErrs {
+		messages[i] = fieldErr.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(ErrorResponse{Message: "Invalid request: " + strings.Join(messages, "; "), Errors: fieldErrs})
+	return false
+}
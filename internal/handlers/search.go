@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+	"predictionbot/internal/storage"
+)
+
+// HandleMarketSearch handles GET /api/markets/search?q=, listing the markets whose question or
+// resolution criteria contain every word of q, open markets first. ?limit= caps the results
+// (default 20). Questions are translated like in the market list.
+func HandleMarketSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "markets_search_invalid_method", "method="+r.Method)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Search is public; signed-in users get questions in their language
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	var viewer *storage.User
+	if ok {
+		viewer, _ = storage.GetUserByTelegramID(userID)
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		logger.Debug(userID, "markets_search_invalid_query", "q is empty")
+		respondWithError(w, "Missing search query: use ?q=", http.StatusBadRequest)
+		return
+	}
+	limit := defaultMarketsPageSize
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxMarketsPageSize {
+			respondWithError(w, fmt.Sprintf("invalid limit: must be between 1 and %d", maxMarketsPageSize), http.StatusBadRequest)
+			return
+		}
+	}
+
+	markets, err := storage.SearchMarkets(query, limit)
+	if err != nil {
+		logger.Debug(userID, "markets_search_error", "error="+err.Error())
+		respondWithError(w, "Failed to search markets", http.StatusInternalServerError)
+		return
+	}
+
+	marketIDs := make([]int64, len(markets))
+	for i := range markets {
+		marketIDs[i] = markets[i].ID
+	}
+	tagsByMarket, err := storage.GetTagsForMarkets(marketIDs)
+	if err != nil {
+		logger.Debug(userID, "markets_search_error", "error="+err.Error())
+		respondWithError(w, "Failed to search markets", http.StatusInternalServerError)
+		return
+	}
+	for i := range markets {
+		markets[i].Tags = tagsByMarket[markets[i].ID]
+		if markets[i].Tags == nil {
+			markets[i].Tags = []string{}
+		}
+	}
+	if markets == nil {
+		markets = []storage.MarketWithCreator{}
+	}
+	service.GetTranslationService().Questions(markets, requestLanguage(r, viewer))
+
+	logger.Debug(userID, "markets_search_success", fmt.Sprintf("q=%q count=%d", query, len(markets)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(markets)
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+	"predictionbot/internal/storage"
+)
+
+// HandleMarketShare handles GET /api/markets/{id}/share: the market as plain text, Telegram
+// Markdown and a deep link into the Web App, plus the URL of its image card.
+// The question is translated for the viewer like in the market detail.
+func HandleMarketShare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "share_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _ := auth.GetUserIDFromContext(r.Context())
+
+	// Expected path: /markets/{id}/share (after StripPrefix removes /api)
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 || pathParts[0] != "markets" || pathParts[2] != "share" {
+		logger.Debug(userID, "share_invalid_path", "path="+r.URL.Path)
+		respondWithError(w, "Invalid path format", http.StatusBadRequest)
+		return
+	}
+	marketID, err := strconv.ParseInt(pathParts[1], 10, 64)
+	if err != nil {
+		logger.Debug(userID, "share_invalid_id", "id="+pathParts[1])
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	market, pools, ok := shareableMarket(w, userID, marketID)
+	if !ok {
+		return
+	}
+
+	var viewer *storage.User
+	if userID != 0 {
+		viewer, _ = storage.GetUserByTelegramID(userID)
+	}
+	question := service.GetTranslationService().Question(r.Context(), marketID, market.Question, requestLanguage(r, viewer))
+
+	logger.Debug(userID, "share_success", fmt.Sprintf("market_id=%d", marketID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(service.BuildMarketShare(market, question, pools))
+}
+
+// HandleMarketCard handles GET /api/markets/{id}/card.png and /card.svg, the market's image card
+// for link previews and photo posts. Cards are served without authentication (see
+// auth.Middleware) and only show what anyone sees in the feed: the original question and the
+// public pools. PNG cards are cached on disk until the odds change.
+func HandleMarketCard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Expected path: /markets/{id}/card.png or /markets/{id}/card.svg (after StripPrefix removes /api)
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 || pathParts[0] != "markets" || (pathParts[2] != "card.png" && pathParts[2] != "card.svg") {
+		respondWithError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	marketID, err := strconv.ParseInt(pathParts[1], 10, 64)
+	if err != nil {
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	market, pools, ok := shareableMarket(w, 0, marketID)
+	if !ok {
+		return
+	}
+
+	var card []byte
+	contentType := "image/svg+xml"
+	if pathParts[2] == "card.png" {
+		contentType = "image/png"
+		if card, err = service.GetCardCache().PNG(market, market.Question, pools); err != nil {
+			logger.Debug(0, "card_error", fmt.Sprintf("market_id=%d error=%v", marketID, err))
+			respondWithError(w, "Failed to render card", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		card = service.RenderMarketCard(market, market.Question, pools)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	w.WriteHeader(http.StatusOK)
+	w.Write(card)
+}
+
+// qrModuleSize is the size in pixels of one module of a market's QR code, big enough to print
+const qrModuleSize = 10
+
+// HandleMarketQR handles GET /api/markets/{id}/qr.png, a QR code of the market's deep link for
+// posting at offline events. Like cards, QR codes are served without authentication. Without
+// a known bot username there is no deep link, and so no QR code.
+func HandleMarketQR(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Expected path: /markets/{id}/qr.png (after StripPrefix removes /api)
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 || pathParts[0] != "markets" || pathParts[2] != "qr.png" {
+		respondWithError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	marketID, err := strconv.ParseInt(pathParts[1], 10, 64)
+	if err != nil {
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, _, ok := shareableMarket(w, 0, marketID); !ok {
+		return
+	}
+	link := service.MarketDeepLink(marketID)
+	if link == "" {
+		respondWithError(w, "deep link not found: the bot username is not configured", http.StatusNotFound)
+		return
+	}
+
+	qr, err := service.EncodeQR(link)
+	var image []byte
+	if err == nil {
+		image, err = qr.PNG(qrModuleSize)
+	}
+	if err != nil {
+		logger.Debug(0, "qr_error", fmt.Sprintf("market_id=%d error=%v", marketID, err))
+		respondWithError(w, "Failed to render QR code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	w.Write(image)
+}
+
+// shareableMarket loads a market and its public pools for sharing, responding with an error
+// (hidden and group markets are not found) when it cannot be shared
+func shareableMarket(w http.ResponseWriter, userID, marketID int64) (*storage.Market, storage.PublicPools, bool) {
+	market, err := storage.GetMarketByID(marketID)
+	if err != nil {
+		logger.Debug(userID, "share_error", "error="+err.Error())
+		respondWithError(w, "Failed to fetch market", http.StatusInternalServerError)
+		return nil, storage.PublicPools{}, false
+	}
+	// Cards and QR codes are served without authentication, so a group market would leak its
+	// question and pools outside the group
+	if market == nil || market.Hidden || market.GroupID != 0 {
+		respondWithError(w, "market not found", http.StatusNotFound)
+		return nil, storage.PublicPools{}, false
+	}
+
+	pools, err := storage.GetPublicPools(marketID)
+	if err != nil {
+		logger.Debug(userID, "share_error", "error="+err.Error())
+		respondWithError(w, "Failed to fetch market", http.StatusInternalServerError)
+		return nil, storage.PublicPools{}, false
+	}
+	return market, pools, true
+}
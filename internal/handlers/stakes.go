@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+	"predictionbot/internal/storage"
+)
+
+// HandleSuggestedStakes handles GET /api/markets/{id}/suggested-stakes?max_shift=N.
+// It suggests bet amounts for the caller's balance that move the odds by at most
+// max_shift percentage points (default 5).
+func HandleSuggestedStakes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "stakes_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(w, r, "stakes")
+	if user == nil {
+		return
+	}
+
+	// Expected path: /markets/{id}/suggested-stakes (after StripPrefix removes /api)
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 || pathParts[0] != "markets" || pathParts[2] != "suggested-stakes" {
+		logger.Debug(user.TelegramID, "stakes_invalid_path", "path="+r.URL.Path)
+		respondWithError(w, "Invalid path format", http.StatusBadRequest)
+		return
+	}
+	marketID, err := strconv.ParseInt(pathParts[1], 10, 64)
+	if err != nil {
+		logger.Debug(user.TelegramID, "stakes_invalid_id", "id="+pathParts[1])
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	maxShift := service.DefaultStakeMaxShift
+	if value := r.URL.Query().Get("max_shift"); value != "" {
+		maxShift, err = strconv.ParseFloat(value, 64)
+		if err != nil || maxShift <= 0 || maxShift > 100 {
+			respondWithError(w, "invalid max_shift: must be between 0 and 100", http.StatusBadRequest)
+			return
+		}
+	}
+
+	market, err := storage.GetMarketByID(marketID)
+	if err != nil {
+		logger.Debug(user.TelegramID, "stakes_error", "error="+err.Error())
+		respondWithError(w, "Failed to suggest stakes", http.StatusInternalServerError)
+		return
+	}
+	if market == nil || market.Hidden {
+		respondWithError(w, "market not found", http.StatusNotFound)
+		return
+	}
+	open := market.Status == storage.MarketStatusActive || market.Status == storage.MarketStatusLastCall
+	if !open || !time.Now().Before(market.ExpiresAt) {
+		respondWithError(w, "market is not open for betting", http.StatusConflict)
+		return
+	}
+
+	// Suggestions follow the pools bettors can see: frozen during the last call, and
+	// hidden (so only the balance counts) for blind and sealed markets
+	pools, err := storage.GetPublicPools(marketID)
+	if err != nil {
+		logger.Debug(user.TelegramID, "stakes_error", "error="+err.Error())
+		respondWithError(w, "Failed to suggest stakes", http.StatusInternalServerError)
+		return
+	}
+
+	stakes := service.SuggestStakes(marketID, user.Balance, pools.Yes, pools.No, maxShift)
+	logger.Debug(user.TelegramID, "stakes_suggested", fmt.Sprintf("market_id=%d yes_max=%d no_max=%d", marketID, stakes.Yes.MaxStake, stakes.No.MaxStake))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stakes)
+}
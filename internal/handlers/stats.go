@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+)
+
+// HandleCalibration handles GET /api/stats/calibration
+// The report is computed at most once a day, see service.CalibrationCacheTTL.
+func HandleCalibration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "calibration_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _ := auth.GetUserIDFromContext(r.Context())
+
+	report, err := service.GetStatsService().Calibration(r.Context())
+	if err != nil {
+		logger.Debug(userID, "calibration_error", "error="+err.Error())
+		respondWithError(w, "Failed to compute calibration", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Debug(userID, "calibration_success", fmt.Sprintf("markets=%d generated_at=%s", report.Markets, report.GeneratedAt.Format(time.RFC3339)))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+)
+
+// streamHeartbeat is how often an idle stream sends a comment so proxies keep it open
+const streamHeartbeat = 25 * time.Second
+
+// HandleStream handles GET /api/stream, a Server-Sent Events stream of live market updates
+// (see service.StreamEvent): bet_placed, bet_cancelled, market_locked, market_resolved and
+// market_finalized. ?markets=1,2 only sends updates of those markets.
+func HandleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "stream_invalid_method", "method="+r.Method)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := auth.GetUserIDFromContext(r.Context())
+
+	var only map[int64]bool
+	if value := r.URL.Query().Get("markets"); value != "" {
+		only = make(map[int64]bool)
+		for _, part := range strings.Split(value, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil || id <= 0 {
+				respondWithError(w, "Invalid markets: use comma-separated market IDs", http.StatusBadRequest)
+				return
+			}
+			only[id] = true
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := service.GetEventBus().Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Ask nginx-style proxies not to buffer the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	logger.Debug(userID, "stream_opened", fmt.Sprintf("markets=%d", len(only)))
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			logger.Debug(userID, "stream_closed", "")
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case event, open := <-events:
+			if !open {
+				return
+			}
+			if only != nil && !only[event.MarketID] {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
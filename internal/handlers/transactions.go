@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+const (
+	// defaultTransactionsPageSize is the page size for transaction history when no limit is given
+	defaultTransactionsPageSize = 50
+	// maxTransactionsPageSize is the largest page size accepted for transaction history
+	maxTransactionsPageSize = 200
+)
+
+// TransactionPage is a page of a user's transactions
+type TransactionPage struct {
+	Transactions []storage.Transaction `json:"transactions"`
+	Total        int                   `json:"total"`
+	Limit        int                   `json:"limit"`
+	Offset       int                   `json:"offset"`
+}
+
+// parseTransactionFilter reads limit, offset, type, market_id, from, to and sort from the query string.
+// from and to are RFC 3339 times or YYYY-MM-DD dates (UTC); a to date includes the whole day.
+func parseTransactionFilter(r *http.Request) (storage.TransactionFilter, error) {
+	query := r.URL.Query()
+	filter := storage.TransactionFilter{Limit: defaultTransactionsPageSize, Sort: storage.TransactionSortNewest}
+
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > maxTransactionsPageSize {
+			return filter, fmt.Errorf("invalid limit: must be between 1 and %d", maxTransactionsPageSize)
+		}
+		filter.Limit = value
+	}
+
+	if offset := query.Get("offset"); offset != "" {
+		value, err := strconv.Atoi(offset)
+		if err != nil || value < 0 {
+			return filter, fmt.Errorf("invalid offset: must not be negative")
+		}
+		filter.Offset = value
+	}
+
+	if sourceType := strings.ToUpper(query.Get("type")); sourceType != "" {
+		known := false
+		for _, t := range storage.TransactionTypes {
+			known = known || t == sourceType
+		}
+		if !known {
+			return filter, fmt.Errorf("invalid type: must be one of %s", strings.Join(storage.TransactionTypes, ", "))
+		}
+		filter.Type = sourceType
+	}
+
+	if marketID := query.Get("market_id"); marketID != "" {
+		value, err := strconv.ParseInt(marketID, 10, 64)
+		if err != nil || value < 1 {
+			return filter, fmt.Errorf("invalid market_id")
+		}
+		filter.MarketID = value
+	}
+
+	var err error
+	if filter.From, _, err = parseTransactionTime(query.Get("from")); err != nil {
+		return filter, fmt.Errorf("invalid from: %w", err)
+	}
+	var dateOnly bool
+	if filter.To, dateOnly, err = parseTransactionTime(query.Get("to")); err != nil {
+		return filter, fmt.Errorf("invalid to: %w", err)
+	}
+	if dateOnly {
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, fmt.Errorf("invalid range: from must be before to")
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		switch storage.TransactionSort(sort) {
+		case storage.TransactionSortNewest, storage.TransactionSortOldest, storage.TransactionSortLargest:
+			filter.Sort = storage.TransactionSort(sort)
+		default:
+			return filter, fmt.Errorf("invalid sort: must be newest, oldest or largest")
+		}
+	}
+
+	return filter, nil
+}
+
+// parseTransactionTime reads an RFC 3339 time or a YYYY-MM-DD date; "" is the zero time
+func parseTransactionTime(value string) (t time.Time, dateOnly bool, err error) {
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("must be YYYY-MM-DD or RFC 3339")
+	}
+	return t, false, nil
+}
+
+// writeTransactions responds with a filtered page of userID's (internal ID) transactions
+func writeTransactions(w http.ResponseWriter, r *http.Request, telegramID, userID int64, filter storage.TransactionFilter, action string) {
+	transactions, total, err := storage.ListUserTransactions(userID, filter)
+	if err != nil {
+		logger.Debug(telegramID, action+"_error", "error="+err.Error())
+		respondWithError(w, "Failed to get transactions", http.StatusInternalServerError)
+		return
+	}
+	if transactions == nil {
+		transactions = []storage.Transaction{}
+	}
+
+	logger.Debug(telegramID, action+"_success", fmt.Sprintf("user_id=%d count=%d total=%d", userID, len(transactions), total))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TransactionPage{
+		Transactions: transactions,
+		Total:        total,
+		Limit:        filter.Limit,
+		Offset:       filter.Offset,
+	})
+}
+
+// HandleUserTransactions handles GET /api/me/transactions
+// Supports ?limit=, ?offset=, ?type=, ?market_id=, ?from=, ?to= and ?sort= (newest, oldest or largest).
+func HandleUserTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "user_transactions_invalid_method", "method="+r.Method)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(w, r, "user_transactions")
+	if user == nil {
+		return
+	}
+
+	filter, err := parseTransactionFilter(r)
+	if err != nil {
+		logger.Debug(user.TelegramID, "user_transactions_invalid_query", "error="+err.Error())
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeTransactions(w, r, user.TelegramID, user.ID, filter, "user_transactions")
+}
+
+// HandleAdminTransactions handles GET /api/admin/transactions?telegram_id=
+// It takes the filters of /api/me/transactions; with ?format=csv every matching transaction is
+// downloaded as a CSV file instead of a page.
+func HandleAdminTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "admin_transactions_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	actor := requirePermission(w, r, auth.PermissionAdjustBalances, "admin_transactions")
+	if actor == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	targetTelegramID, err := strconv.ParseInt(query.Get("telegram_id"), 10, 64)
+	if err != nil {
+		respondWithError(w, "Invalid telegram_id", http.StatusBadRequest)
+		return
+	}
+	target, err := storage.GetUserByTelegramID(targetTelegramID)
+	if err != nil || target == nil {
+		logger.Debug(actor.TelegramID, "admin_transactions_target_not_found", fmt.Sprintf("telegram_id=%d", targetTelegramID))
+		respondWithError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	filter, err := parseTransactionFilter(r)
+	if err != nil {
+		logger.Debug(actor.TelegramID, "admin_transactions_invalid_query", "error="+err.Error())
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch query.Get("format") {
+	case "", "json":
+		writeTransactions(w, r, actor.TelegramID, target.ID, filter, "admin_transactions")
+	case "csv":
+		filter.Limit, filter.Offset = 0, 0
+		writeTransactionsCSV(w, actor.TelegramID, target, filter)
+	default:
+		respondWithError(w, "Invalid format: must be json or csv", http.StatusBadRequest)
+	}
+}
+
+// writeTransactionsCSV responds with every transaction of target matching filter as a CSV download
+func writeTransactionsCSV(w http.ResponseWriter, telegramID int64, target *storage.User, filter storage.TransactionFilter) {
+	transactions, _, err := storage.ListUserTransactions(target.ID, filter)
+	if err != nil {
+		logger.Debug(telegramID, "admin_transactions_csv_error", "error="+err.Error())
+		respondWithError(w, "Failed to get transactions", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Debug(telegramID, "admin_transactions_csv", fmt.Sprintf("telegram_id=%d count=%d", target.TelegramID, len(transactions)))
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="transactions-%d.csv"`, target.TelegramID))
+	w.WriteHeader(http.StatusOK)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "created_at", "type", "amount", "market_id", "description"})
+	for _, t := range transactions {
+		marketID := ""
+		if t.MarketID != 0 {
+			marketID = strconv.FormatInt(t.MarketID, 10)
+		}
+		out.Write([]string{
+			strconv.FormatInt(t.ID, 10),
+			t.CreatedAt.UTC().Format(time.RFC3339),
+			t.SourceType,
+			strconv.FormatInt(t.Amount, 10),
+			marketID,
+			t.Description,
+		})
+	}
+	out.Flush()
+}
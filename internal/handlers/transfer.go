@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+)
+
+// TransferMarketRequest is the request body for transferring a market
+type TransferMarketRequest struct {
+	Username string `json:"username"`
+}
+
+// TransferMarketResponse is the response for a transfer request
+type TransferMarketResponse struct {
+	TransferID int64  `json:"transfer_id"`
+	Status     string `json:"status"`
+}
+
+// HandleMarketTransfer handles POST /api/markets/{id}/transfer
+// The transfer only takes effect after the creator confirms and the recipient accepts in the bot.
+func HandleMarketTransfer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Debug(0, "transfer_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	user := currentUser(w, r, "transfer")
+	if user == nil {
+		return
+	}
+	telegramID := user.TelegramID
+
+	// Parse market ID from URL path
+	// Expected path: /markets/{id}/transfer (after StripPrefix removes /api)
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 || pathParts[0] != "markets" || pathParts[2] != "transfer" {
+		logger.Debug(telegramID, "transfer_invalid_path", "path="+r.URL.Path)
+		respondWithError(w, "Invalid path format", http.StatusBadRequest)
+		return
+	}
+
+	marketID, err := strconv.ParseInt(pathParts[1], 10, 64)
+	if err != nil {
+		logger.Debug(telegramID, "transfer_invalid_id", "id="+pathParts[1])
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse request body
+	var req TransferMarketRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		logger.Debug(telegramID, "transfer_invalid_body", "error="+err.Error())
+		respondWithBodyError(w, err)
+		return
+	}
+
+	transferService := service.NewTransferService()
+	transfer, err := transferService.RequestTransfer(ctx, marketID, user.ID, req.Username)
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(telegramID, "transfer_failed", fmt.Sprintf("market_id=%d error=%s", marketID, errMsg))
+		if strings.Contains(errMsg, "not found") {
+			respondWithError(w, errMsg, http.StatusNotFound)
+		} else if strings.Contains(errMsg, "only the market creator") {
+			respondWithError(w, errMsg, http.StatusForbidden)
+		} else if strings.Contains(errMsg, "cannot be transferred") || strings.Contains(errMsg, "already pending") {
+			respondWithError(w, errMsg, http.StatusConflict)
+		} else if strings.Contains(errMsg, "invalid") {
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		} else {
+			respondWithError(w, "Failed to transfer market", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logger.Debug(telegramID, "transfer_requested", fmt.Sprintf("market_id=%d transfer_id=%d to_user_id=%d", marketID, transfer.ID, transfer.ToUserID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(TransferMarketResponse{
+		TransferID: transfer.ID,
+		Status:     string(transfer.Status),
+	})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+	"predictionbot/internal/storage"
+)
+
+// TranslationRequest is the request body for PUT /api/markets/{id}/translations
+type TranslationRequest struct {
+	Lang     string `json:"lang"`
+	Question string `json:"question"`
+}
+
+// requestLanguage picks the language to show questions in: ?lang=, then the viewer's saved
+// preference, then Accept-Language. "" means the question as written.
+func requestLanguage(r *http.Request, viewer *storage.User) string {
+	if lang := service.NormalizeLanguage(r.URL.Query().Get("lang")); lang != "" {
+		return lang
+	}
+	if viewer != nil {
+		if lang, err := storage.GetUserLanguage(viewer.ID); err == nil && lang != "" {
+			return lang
+		}
+	}
+	return service.LanguageFromAcceptHeader(r.Header.Get("Accept-Language"))
+}
+
+// HandleMarketTranslations handles GET and PUT /api/markets/{id}/translations.
+// GET lists the stored translations; PUT lets the creator add or replace one.
+func HandleMarketTranslations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		logger.Debug(0, "translations_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract market ID from path: /markets/{id}/translations
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 {
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+	marketID, err := strconv.ParseInt(pathParts[1], 10, 64)
+	if err != nil {
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		userID, _ := auth.GetUserIDFromContext(r.Context())
+		market, err := storage.GetMarketByID(marketID)
+		if err != nil {
+			logger.Debug(userID, "translations_error", "error="+err.Error())
+			respondWithError(w, "Failed to fetch translations", http.StatusInternalServerError)
+			return
+		}
+		if market == nil || market.Hidden {
+			respondWithError(w, "market not found", http.StatusNotFound)
+			return
+		}
+		translations, err := storage.GetMarketTranslations(marketID)
+		if err != nil {
+			logger.Debug(userID, "translations_error", "error="+err.Error())
+			respondWithError(w, "Failed to fetch translations", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(translations)
+		return
+	}
+
+	user := currentUser(w, r, "translations")
+	if user == nil {
+		return
+	}
+
+	var req TranslationRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		logger.Debug(user.TelegramID, "translations_invalid_body", "error="+err.Error())
+		respondWithBodyError(w, err)
+		return
+	}
+
+	translation, err := service.NewMarketService().SetTranslation(user, marketID, req.Lang, req.Question)
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(user.TelegramID, "translations_failed", fmt.Sprintf("market_id=%d error=%s", marketID, errMsg))
+		switch {
+		case strings.Contains(errMsg, "not found"):
+			respondWithError(w, errMsg, http.StatusNotFound)
+		case strings.Contains(errMsg, "only the market creator"):
+			respondWithError(w, errMsg, http.StatusForbidden)
+		case strings.Contains(errMsg, "not active"):
+			respondWithError(w, errMsg, http.StatusConflict)
+		case strings.Contains(errMsg, "invalid"):
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		default:
+			respondWithError(w, "Failed to save translation", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(translation)
+}
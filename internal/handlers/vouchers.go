@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// GrantVoucherRequest is the request body for POST /api/admin/vouchers. Kind is INSURANCE (the
+// stake back if the bet loses) or CREDIT (the stake paid); ExpiresInHours 0 never expires.
+type GrantVoucherRequest struct {
+	TelegramID     int64  `json:"telegram_id"`
+	Kind           string `json:"kind"`
+	MaxAmount      int64  `json:"max_amount"`
+	ExpiresInHours int    `json:"expires_in_hours,omitempty"`
+}
+
+// HandleAdminVouchers handles POST /api/admin/vouchers, granting a user a voucher for their next
+// bet, e.g. a promo credit
+func HandleAdminVouchers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Debug(0, "admin_vouchers_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	actor := requirePermission(w, r, auth.PermissionAdjustBalances, "admin_vouchers")
+	if actor == nil {
+		return
+	}
+
+	var req GrantVoucherRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		logger.Debug(actor.TelegramID, "admin_vouchers_invalid_body", "error="+err.Error())
+		respondWithBodyError(w, err)
+		return
+	}
+
+	kind, err := storage.ParseVoucherKind(req.Kind)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresInHours < 0 {
+		respondWithError(w, "Invalid expiry: expires_in_hours must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	target, err := storage.GetUserByTelegramID(req.TelegramID)
+	if err != nil || target == nil {
+		logger.Debug(actor.TelegramID, "admin_vouchers_target_not_found", fmt.Sprintf("telegram_id=%d", req.TelegramID))
+		respondWithError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	var expiresAt time.Time
+	if req.ExpiresInHours > 0 {
+		expiresAt = time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+	}
+	voucher, err := storage.GrantVoucher(target.ID, kind, req.MaxAmount, expiresAt, actor.ID)
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(actor.TelegramID, "admin_vouchers_failed", fmt.Sprintf("telegram_id=%d error=%s", req.TelegramID, errMsg))
+		if strings.Contains(errMsg, "invalid") {
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		} else {
+			respondWithError(w, "Failed to grant voucher", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logger.Debug(actor.TelegramID, "admin_voucher_granted", fmt.Sprintf("telegram_id=%d voucher_id=%d kind=%s max_amount=%d", req.TelegramID, voucher.ID, voucher.Kind, voucher.MaxAmount))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(voucher)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+	"predictionbot/internal/storage"
+)
+
+// WeatherMarketRequest is the request body for POST /api/markets/weather
+type WeatherMarketRequest struct {
+	Place string `json:"place" validate:"required,max=100"`
+	// Day is "tomorrow" or YYYY-MM-DD in the place's timezone
+	Day       string `json:"day" validate:"required"`
+	Condition string `json:"condition" validate:"required,oneof=rain snow above below"`
+	// Threshold is the temperature in °C for above and below
+	Threshold float64 `json:"threshold,omitempty"`
+}
+
+// WeatherMarketResponse is the answer to POST /api/markets/weather
+type WeatherMarketResponse struct {
+	ID      int64                  `json:"id"`
+	Status  string                 `json:"status"`
+	Weather *storage.WeatherMarket `json:"weather,omitempty"`
+}
+
+// HandleWeatherMarket handles POST /api/markets/weather, which creates a market from the
+// weather template for the weather oracle to resolve. A repeated creation answers 200 with the
+// existing market.
+func HandleWeatherMarket(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Debug(0, "weather_market_invalid_method", "method="+r.Method)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !service.WeatherOracleEnabled() {
+		respondWithError(w, "Weather markets are not enabled", http.StatusNotFound)
+		return
+	}
+	user := currentUser(w, r, "weather_market")
+	if user == nil {
+		return
+	}
+
+	var req WeatherMarketRequest
+	if !decodeAndValidate(w, r, &req, user.TelegramID, "weather_market") {
+		return
+	}
+
+	weather, err := service.CreateWeatherMarket(r.Context(), user, service.WeatherTemplate{
+		Place:     req.Place,
+		Day:       req.Day,
+		Condition: storage.WeatherCondition(req.Condition),
+		Threshold: req.Threshold,
+		Key:       r.Header.Get("Idempotency-Key"),
+	})
+	var duplicate *service.DuplicateMarketError
+	if errors.As(err, &duplicate) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(WeatherMarketResponse{ID: duplicate.Market.ID, Status: string(duplicate.Market.Status)})
+		return
+	}
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(user.TelegramID, "weather_market_failed", "error="+errMsg)
+		switch {
+		case strings.Contains(errMsg, "not found"):
+			respondWithError(w, errMsg, http.StatusNotFound)
+		case strings.Contains(errMsg, "invalid"):
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		case strings.Contains(errMsg, "failed to look up place"):
+			respondWithError(w, "Weather service unavailable, please try again", http.StatusBadGateway)
+		default:
+			respondWithError(w, "Failed to create market", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(WeatherMarketResponse{ID: weather.MarketID, Status: string(storage.MarketStatusActive), Weather: weather})
+}
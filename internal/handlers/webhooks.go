@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/service"
+	"predictionbot/internal/storage"
+)
+
+// WebhookRequest is the request body for PUT /api/markets/{id}/webhook
+type WebhookRequest struct {
+	URL string `json:"url"`
+}
+
+// HandleMarketWebhook handles GET, PUT and DELETE /api/markets/{id}/webhook for the market's
+// creator. PUT sets the callback URL and returns the signing secret, which is only shown then;
+// GET returns the webhook and its last delivery without the secret; DELETE removes it.
+func HandleMarketWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		logger.Debug(0, "market_webhook_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract market ID from path: /markets/{id}/webhook
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 {
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+	marketID, err := strconv.ParseInt(pathParts[1], 10, 64)
+	if err != nil {
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	user := currentUser(w, r, "market_webhook")
+	if user == nil {
+		return
+	}
+
+	var hook *storage.MarketWebhook
+	status := http.StatusOK
+	switch r.Method {
+	case http.MethodGet:
+		hook, err = service.GetMarketWebhook(user, marketID)
+		if err == nil && hook == nil {
+			err = fmt.Errorf("webhook not found")
+		}
+	case http.MethodPut:
+		var req WebhookRequest
+		if err := decodeJSONBody(w, r, &req); err != nil {
+			logger.Debug(user.TelegramID, "market_webhook_invalid_body", "error="+err.Error())
+			respondWithBodyError(w, err)
+			return
+		}
+		hook, err = service.RegisterMarketWebhook(user, marketID, req.URL)
+	case http.MethodDelete:
+		err = service.DeleteMarketWebhook(user, marketID)
+		status = http.StatusNoContent
+	}
+	if err != nil {
+		errMsg := err.Error()
+		logger.Debug(user.TelegramID, "market_webhook_failed", fmt.Sprintf("market_id=%d method=%s error=%s", marketID, r.Method, errMsg))
+		switch {
+		case strings.Contains(errMsg, "not found"):
+			respondWithError(w, errMsg, http.StatusNotFound)
+		case strings.Contains(errMsg, "only the market creator"):
+			respondWithError(w, errMsg, http.StatusForbidden)
+		case strings.Contains(errMsg, "not open"):
+			respondWithError(w, errMsg, http.StatusConflict)
+		case strings.Contains(errMsg, "invalid"):
+			respondWithError(w, errMsg, http.StatusBadRequest)
+		default:
+			respondWithError(w, "Failed to update webhook", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(hook)
+}
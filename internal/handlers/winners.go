@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/auth"
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+const (
+	defaultWinnersLimit = 10
+	maxWinnersLimit     = 50
+)
+
+// MarketWinnersResponse is the response for GET /api/markets/{id}/winners
+type MarketWinnersResponse struct {
+	MarketID int64                  `json:"market_id"`
+	Outcome  string                 `json:"outcome"`
+	Winners  []storage.MarketWinner `json:"winners"`
+}
+
+// HandleMarketWinners handles GET /api/markets/{id}/winners?limit=N.
+// Winners are only named when they opted in through /api/me/preferences.
+func HandleMarketWinners(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Debug(0, "winners_invalid_method", "method="+r.Method+" path="+r.URL.Path)
+		respondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _ := auth.GetUserIDFromContext(r.Context())
+
+	// Expected path: /markets/{id}/winners (after StripPrefix removes /api)
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 || pathParts[0] != "markets" || pathParts[2] != "winners" {
+		logger.Debug(userID, "winners_invalid_path", "path="+r.URL.Path)
+		respondWithError(w, "Invalid path format", http.StatusBadRequest)
+		return
+	}
+	marketID, err := strconv.ParseInt(pathParts[1], 10, 64)
+	if err != nil {
+		logger.Debug(userID, "winners_invalid_id", "id="+pathParts[1])
+		respondWithError(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultWinnersLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxWinnersLimit {
+			respondWithError(w, fmt.Sprintf("invalid limit: must be between 1 and %d", maxWinnersLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	market, err := storage.GetMarketByID(marketID)
+	if err != nil {
+		logger.Debug(userID, "winners_error", "error="+err.Error())
+		respondWithError(w, "Failed to fetch winners", http.StatusInternalServerError)
+		return
+	}
+	if market == nil || market.Hidden {
+		respondWithError(w, "market not found", http.StatusNotFound)
+		return
+	}
+	if market.Status != storage.MarketStatusFinalized {
+		respondWithError(w, "market is not finalized yet", http.StatusConflict)
+		return
+	}
+
+	winners, err := storage.GetTopWinners(marketID, limit)
+	if err != nil {
+		logger.Debug(userID, "winners_error", "error="+err.Error())
+		respondWithError(w, "Failed to fetch winners", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Debug(userID, "winners_success", fmt.Sprintf("market_id=%d count=%d", marketID, len(winners)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MarketWinnersResponse{
+		MarketID: marketID,
+		Outcome:  market.Outcome,
+		Winners:  winners,
+	})
+}
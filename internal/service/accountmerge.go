@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// AccountMergeService moves a user's old account into the account they use now, for users who
+// changed Telegram accounts. An admin starts the merge, the bot DMs a confirmation code to each
+// account, and the admin completes the merge with both codes once the user passed them on.
+type AccountMergeService struct {
+	notifier Notifier
+}
+
+// NewAccountMergeService creates a merge service that notifies through the global notification service
+func NewAccountMergeService() *AccountMergeService {
+	return &AccountMergeService{}
+}
+
+// NewAccountMergeServiceWithNotifier creates a merge service that sends events to the given notifier
+func NewAccountMergeServiceWithNotifier(notifier Notifier) *AccountMergeService {
+	return &AccountMergeService{notifier: notifier}
+}
+
+// events returns where notification events go: the injected notifier, else the global one
+func (s *AccountMergeService) events() Notifier {
+	if s.notifier != nil {
+		return s.notifier
+	}
+	return defaultNotifier()
+}
+
+// RequestMerge opens a merge of the account of fromTelegramID into the account of toTelegramID
+// and sends each account its confirmation code
+func (s *AccountMergeService) RequestMerge(ctx context.Context, admin *storage.User, fromTelegramID, toTelegramID int64) (*storage.AccountMerge, error) {
+	from, err := storage.GetUserByTelegramID(fromTelegramID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get old account: %w", err)
+	}
+	if from == nil {
+		return nil, fmt.Errorf("old account not found")
+	}
+	to, err := storage.GetUserByTelegramID(toTelegramID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get new account: %w", err)
+	}
+	if to == nil {
+		return nil, fmt.Errorf("new account not found: the user must start the bot first")
+	}
+
+	fromCode, err := mergeCode()
+	if err != nil {
+		return nil, err
+	}
+	toCode, err := mergeCode()
+	if err != nil {
+		return nil, err
+	}
+
+	merge, err := storage.CreateAccountMerge(ctx, from.ID, to.ID, admin.ID, fromCode, toCode)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug(admin.TelegramID, "account_merge_requested", fmt.Sprintf("merge_id=%d from_user_id=%d to_user_id=%d", merge.ID, from.ID, to.ID))
+
+	notifier := s.events()
+	notifier.Emit(AccountMergeCode{UserID: from.ID, MergeID: merge.ID, Code: fromCode, OldAccount: true})
+	notifier.Emit(AccountMergeCode{UserID: to.ID, MergeID: merge.ID, Code: toCode})
+	return merge, nil
+}
+
+// CompleteMerge moves the old account into the new one once the admin has both codes
+func (s *AccountMergeService) CompleteMerge(ctx context.Context, admin *storage.User, mergeID int64, fromCode, toCode string) (*storage.AccountMergeResult, error) {
+	result, err := storage.CompleteAccountMerge(ctx, mergeID, fromCode, toCode, admin.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug(admin.TelegramID, "account_merge_completed", fmt.Sprintf("merge_id=%d from_user_id=%d to_user_id=%d balance=%d bets=%d transactions=%d markets=%d",
+		mergeID, result.FromUserID, result.ToUserID, result.Balance, result.Bets, result.Transactions, result.Markets))
+	return result, nil
+}
+
+// CancelMerge withdraws a pending merge, e.g. when it was opened for the wrong accounts
+func (s *AccountMergeService) CancelMerge(ctx context.Context, admin *storage.User, mergeID int64) error {
+	if err := storage.CancelAccountMerge(ctx, mergeID, admin.ID); err != nil {
+		return err
+	}
+	logger.Debug(admin.TelegramID, "account_merge_cancelled", fmt.Sprintf("merge_id=%d", mergeID))
+	return nil
+}
+
+// mergeCode generates a random six-digit confirmation code
+func mergeCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate confirmation code: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
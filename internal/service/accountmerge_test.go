@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"predictionbot/internal/storage"
+)
+
+func TestAccountMergeCodes(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	admin, _ := storage.CreateUser(12001, "admin", "Admin")
+	old, _ := storage.CreateUser(12002, "old", "Old")
+	current, _ := storage.CreateUser(12003, "new", "New")
+
+	recorder := NewRecordingNotifier()
+	service := NewAccountMergeServiceWithNotifier(recorder)
+	merge, err := service.RequestMerge(ctx, admin, old.TelegramID, current.TelegramID)
+	if err != nil {
+		t.Fatalf("RequestMerge failed: %v", err)
+	}
+
+	codes := make(map[int64]AccountMergeCode)
+	for _, event := range recorder.Events() {
+		if code, ok := event.(AccountMergeCode); ok && code.MergeID == merge.ID {
+			codes[code.UserID] = code
+		}
+	}
+	if len(codes) != 2 || !codes[old.ID].OldAccount || codes[current.ID].OldAccount || len(codes[old.ID].Code) != 6 {
+		t.Fatalf("Expected a code for each account, got %+v", codes)
+	}
+
+	result, err := service.CompleteMerge(ctx, admin, merge.ID, codes[old.ID].Code, codes[current.ID].Code)
+	if err != nil {
+		t.Fatalf("CompleteMerge failed: %v", err)
+	}
+	if result.NewBalance != 2*storage.WelcomeBonusAmount {
+		t.Errorf("Expected both welcome bonuses on the new account, got %d", result.NewBalance)
+	}
+
+	if _, err := service.RequestMerge(ctx, admin, 99999, current.TelegramID); err == nil {
+		t.Error("Expected an unknown old account to be rejected")
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+// Default bet throttling, overridable through the environment
+const (
+	// DefaultBetCooldown is the wait between two bets by one user on one market
+	DefaultBetCooldown = 10 * time.Second
+	// DefaultDailyBetCap is how many bets a user may place in 24 hours
+	DefaultDailyBetCap = 200
+	// DefaultMinBet is the smallest stake
+	DefaultMinBet = 1
+	// DefaultMaxBetPerMarket is the most a user may stake on one market; 0 is no cap
+	DefaultMaxBetPerMarket = 0
+)
+
+// LoadBetLimits reads BET_COOLDOWN (a Go duration such as "10s"), DAILY_BET_CAP, MIN_BET and
+// MAX_BET_PER_MARKET, falling back to the defaults for missing or invalid values. Zero turns a
+// limit off.
+func LoadBetLimits() storage.BetLimits {
+	limits := storage.BetLimits{
+		Cooldown:     DefaultBetCooldown,
+		DailyCap:     DefaultDailyBetCap,
+		MinBet:       DefaultMinBet,
+		MaxPerMarket: DefaultMaxBetPerMarket,
+	}
+	if v, err := time.ParseDuration(os.Getenv("BET_COOLDOWN")); err == nil && v >= 0 {
+		limits.Cooldown = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("DAILY_BET_CAP")); err == nil && v >= 0 {
+		limits.DailyCap = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("MIN_BET"), 10, 64); err == nil && v >= 0 {
+		limits.MinBet = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("MAX_BET_PER_MARKET"), 10, 64); err == nil && v >= 0 {
+		limits.MaxPerMarket = v
+	}
+	return limits
+}
+
+// Default bet cancellation policy, overridable through the environment
+const (
+	// DefaultBetCancelWindow is how long after placing a bet it may be cancelled
+	DefaultBetCancelWindow = 15 * time.Minute
+	// DefaultBetCancelFeePercent is the share of the stake kept when a bet is cancelled
+	DefaultBetCancelFeePercent = 0
+)
+
+// LoadBetCancelPolicy reads BET_CANCEL_WINDOW (a Go duration such as "15m", 0 for until the
+// market stops taking bets) and BET_CANCEL_FEE_PERCENT (0 to 100), falling back to the defaults
+// for missing or invalid values
+func LoadBetCancelPolicy() storage.BetCancelPolicy {
+	policy := storage.BetCancelPolicy{
+		Window:     DefaultBetCancelWindow,
+		FeePercent: DefaultBetCancelFeePercent,
+	}
+	if v, err := time.ParseDuration(os.Getenv("BET_CANCEL_WINDOW")); err == nil && v >= 0 {
+		policy.Window = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("BET_CANCEL_FEE_PERCENT")); err == nil && v >= 0 && v <= 100 {
+		policy.FeePercent = v
+	}
+	return policy
+}
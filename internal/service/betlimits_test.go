@@ -0,0 +1,59 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadBetLimits(t *testing.T) {
+	t.Setenv("BET_COOLDOWN", "")
+	t.Setenv("DAILY_BET_CAP", "")
+	if limits := LoadBetLimits(); limits.Cooldown != DefaultBetCooldown || limits.DailyCap != DefaultDailyBetCap {
+		t.Errorf("Expected defaults, got %+v", limits)
+	}
+
+	t.Setenv("BET_COOLDOWN", "30s")
+	t.Setenv("DAILY_BET_CAP", "0")
+	if limits := LoadBetLimits(); limits.Cooldown != 30*time.Second || limits.DailyCap != 0 {
+		t.Errorf("Expected 30s cooldown and no cap, got %+v", limits)
+	}
+
+	t.Setenv("BET_COOLDOWN", "soon")
+	if limits := LoadBetLimits(); limits.Cooldown != DefaultBetCooldown {
+		t.Errorf("Expected default cooldown for invalid value, got %s", limits.Cooldown)
+	}
+
+	t.Setenv("MIN_BET", "")
+	t.Setenv("MAX_BET_PER_MARKET", "")
+	if limits := LoadBetLimits(); limits.MinBet != DefaultMinBet || limits.MaxPerMarket != DefaultMaxBetPerMarket {
+		t.Errorf("Expected default stake limits, got %+v", limits)
+	}
+	t.Setenv("MIN_BET", "10")
+	t.Setenv("MAX_BET_PER_MARKET", "500")
+	if limits := LoadBetLimits(); limits.MinBet != 10 || limits.MaxPerMarket != 500 {
+		t.Errorf("Expected a 10 minimum and a 500 cap, got %+v", limits)
+	}
+	t.Setenv("MAX_BET_PER_MARKET", "-1")
+	if limits := LoadBetLimits(); limits.MaxPerMarket != DefaultMaxBetPerMarket {
+		t.Errorf("Expected the default cap for a negative value, got %d", limits.MaxPerMarket)
+	}
+}
+
+func TestLoadBetCancelPolicy(t *testing.T) {
+	t.Setenv("BET_CANCEL_WINDOW", "")
+	t.Setenv("BET_CANCEL_FEE_PERCENT", "")
+	if policy := LoadBetCancelPolicy(); policy.Window != DefaultBetCancelWindow || policy.FeePercent != DefaultBetCancelFeePercent {
+		t.Errorf("Expected defaults, got %+v", policy)
+	}
+
+	t.Setenv("BET_CANCEL_WINDOW", "0")
+	t.Setenv("BET_CANCEL_FEE_PERCENT", "5")
+	if policy := LoadBetCancelPolicy(); policy.Window != 0 || policy.FeePercent != 5 {
+		t.Errorf("Expected no window and a 5%% fee, got %+v", policy)
+	}
+
+	t.Setenv("BET_CANCEL_FEE_PERCENT", "150")
+	if policy := LoadBetCancelPolicy(); policy.FeePercent != DefaultBetCancelFeePercent {
+		t.Errorf("Expected the default fee for a fee above 100%%, got %d", policy.FeePercent)
+	}
+}
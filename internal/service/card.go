@@ -0,0 +1,384 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// Cards are 1200x630, the size link previews expect
+const (
+	cardWidth  = 1200
+	cardHeight = 630
+	cardMargin = 80
+	// cardMaxLines bounds how many lines of the question fit on a card
+	cardMaxLines = 4
+	// cardSVGLineLength and cardPNGLineLength are the characters per question line
+	cardSVGLineLength = 34
+	cardPNGLineLength = 28
+)
+
+// marketCard is what a market's image card shows
+type marketCard struct {
+	Icon     string
+	Lines    []string
+	Status   string
+	Deadline string
+	// YesPercent is the YES share of the pool, -1 when the odds are not shown
+	YesPercent int64
+}
+
+// newMarketCard lays out the card for a market, wrapping the question at lineLength characters
+func newMarketCard(market *storage.Market, question string, pools storage.PublicPools, lineLength int) marketCard {
+	card := marketCard{
+		Icon:       market.Icon,
+		Lines:      wrapCardText(question, lineLength),
+		Status:     shareStatusLine(market, pools),
+		Deadline:   cardDeadline(market),
+		YesPercent: -1,
+	}
+	if !pools.PoolsHidden && !pools.SidesHidden && pools.Yes+pools.No > 0 {
+		card.YesPercent = pools.Yes * 100 / (pools.Yes + pools.No)
+	}
+	return card
+}
+
+// cardDeadline tells when betting closes, or that it has
+func cardDeadline(market *storage.Market) string {
+	switch market.Status {
+	case storage.MarketStatusActive, storage.MarketStatusLastCall:
+		deadline := market.ExpiresAt.UTC().Format("Jan 2, 2006 15:04 UTC")
+		if market.LockMode == storage.LockModeManual || market.LockMode == storage.LockModeOracle {
+			return "Closes by " + deadline
+		}
+		return "Closes " + deadline
+	case storage.MarketStatusFinalized:
+		return "Finalized"
+	default:
+		return "Betting closed"
+	}
+}
+
+// key identifies what the card shows; it changes with the odds, so cached cards go stale with them
+func (c marketCard) key() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%q|%q|%q|%q|%d", c.Icon, c.Lines, c.Status, c.Deadline, c.YesPercent)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// wrapCardText breaks the question into card lines, ending with … when it does not fit
+func wrapCardText(text string, lineLength int) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(text) {
+		if line != "" && len([]rune(line))+1+len([]rune(word)) > lineLength {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	if len(lines) > cardMaxLines {
+		lines = lines[:cardMaxLines]
+		last := []rune(lines[cardMaxLines-1])
+		if len(last) >= lineLength {
+			last = last[:lineLength-1]
+		}
+		lines[cardMaxLines-1] = string(last) + "…"
+	}
+	return lines
+}
+
+// RenderMarketCard draws the market as an SVG card: icon, question, odds bar, status and deadline
+func RenderMarketCard(market *storage.Market, question string, pools storage.PublicPools) []byte {
+	card := newMarketCard(market, question, pools, cardSVGLineLength)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, cardWidth, cardHeight, cardWidth, cardHeight)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#17212b"/>`, cardWidth, cardHeight)
+	svgText(&buf, cardMargin, 150, `font-size="80"`, card.Icon)
+	for i, line := range card.Lines {
+		svgText(&buf, cardMargin, 250+i*70, `font-size="56" font-weight="bold" fill="#ffffff"`, line)
+	}
+	if card.YesPercent >= 0 {
+		barWidth := cardWidth - 2*cardMargin
+		yesWidth := int(int64(barWidth) * card.YesPercent / 100)
+		fmt.Fprintf(&buf, `<rect x="%d" y="500" width="%d" height="24" fill="#4caf50"/>`, cardMargin, yesWidth)
+		fmt.Fprintf(&buf, `<rect x="%d" y="500" width="%d" height="24" fill="#e53935"/>`, cardMargin+yesWidth, barWidth-yesWidth)
+	}
+	svgText(&buf, cardMargin, 580, `font-size="36" fill="#6ab2f2"`, card.Status)
+	svgText(&buf, cardWidth-cardMargin, 580, `font-size="28" fill="#8a96a3" text-anchor="end"`, card.Deadline)
+	buf.WriteString(`</svg>`)
+	return buf.Bytes()
+}
+
+// svgText writes one escaped text element
+func svgText(buf *bytes.Buffer, x, y int, attrs, text string) {
+	fmt.Fprintf(buf, `<text x="%d" y="%d" font-family="sans-serif" %s>`, x, y, attrs)
+	xml.EscapeText(buf, []byte(text))
+	buf.WriteString(`</text>`)
+}
+
+// Colors of the PNG card, matching the SVG one
+var (
+	cardBackground = color.RGBA{0x17, 0x21, 0x2b, 0xff}
+	cardWhite      = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	cardBlue       = color.RGBA{0x6a, 0xb2, 0xf2, 0xff}
+	cardGrey       = color.RGBA{0x8a, 0x96, 0xa3, 0xff}
+	cardGreen      = color.RGBA{0x4c, 0xaf, 0x50, 0xff}
+	cardRed        = color.RGBA{0xe5, 0x39, 0x35, 0xff}
+)
+
+// RenderMarketCardPNG draws the market as a PNG card for clients that do not show SVG, such as
+// Telegram link previews. It uses a built-in ASCII font: the icon is left out and letters of
+// other scripts are drawn as boxes.
+func RenderMarketCardPNG(market *storage.Market, question string, pools storage.PublicPools) ([]byte, error) {
+	return newMarketCard(market, question, pools, cardPNGLineLength).png()
+}
+
+// png draws the card
+func (c marketCard) png() ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{cardBackground}, image.Point{}, draw.Src)
+
+	for i, line := range c.Lines {
+		drawCardText(img, cardMargin, 90+i*72, 6, cardWhite, line)
+	}
+	if c.YesPercent >= 0 {
+		barWidth := cardWidth - 2*cardMargin
+		yesWidth := int(int64(barWidth) * c.YesPercent / 100)
+		draw.Draw(img, image.Rect(cardMargin, 420, cardMargin+yesWidth, 450), &image.Uniform{cardGreen}, image.Point{}, draw.Src)
+		draw.Draw(img, image.Rect(cardMargin+yesWidth, 420, cardMargin+barWidth, 450), &image.Uniform{cardRed}, image.Point{}, draw.Src)
+	}
+	drawCardText(img, cardMargin, 490, 4, cardBlue, c.Status)
+	drawCardText(img, cardMargin, 550, 3, cardGrey, c.Deadline)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode card: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// cardTextReplacer spells out the punctuation the card text uses in ASCII
+var cardTextReplacer = strings.NewReplacer("·", "-", "…", "...", "‘", "'", "’", "'", "“", `"`, "”", `"`, "–", "-", "—", "-")
+
+// drawCardText draws text with the 5x7 font, each font pixel scale pixels wide, top left at x,y
+func drawCardText(img *image.RGBA, x, y, scale int, c color.Color, text string) {
+	fill := &image.Uniform{c}
+	for _, r := range cardTextReplacer.Replace(text) {
+		if unicode.In(r, unicode.So, unicode.Mn) || r == '\u200d' {
+			// Emoji and their modifiers have no glyph and no sensible fallback
+			continue
+		}
+		glyph := cardMissingGlyph
+		if r >= ' ' && r <= '~' {
+			glyph = cardFont[r-' ']
+		}
+		for col, bits := range glyph {
+			for row := 0; row < 7; row++ {
+				if bits&(1<<row) != 0 {
+					px, py := x+col*scale, y+row*scale
+					draw.Draw(img, image.Rect(px, py, px+scale, py+scale), fill, image.Point{}, draw.Src)
+				}
+			}
+		}
+		x += 6 * scale
+	}
+}
+
+// cardMissingGlyph is drawn for characters the font does not have
+var cardMissingGlyph = [5]byte{0x7f, 0x41, 0x41, 0x41, 0x7f}
+
+// cardFont is a 5x7 font for printable ASCII: five columns per glyph, bit 0 the top row
+var cardFont = [95][5]byte{
+	{0x00, 0x00, 0x00, 0x00, 0x00}, // space
+	{0x00, 0x00, 0x5f, 0x00, 0x00}, // !
+	{0x00, 0x07, 0x00, 0x07, 0x00}, // "
+	{0x14, 0x7f, 0x14, 0x7f, 0x14}, // #
+	{0x24, 0x2a, 0x7f, 0x2a, 0x12}, // $
+	{0x23, 0x13, 0x08, 0x64, 0x62}, // %
+	{0x36, 0x49, 0x55, 0x22, 0x50}, // &
+	{0x00, 0x05, 0x03, 0x00, 0x00}, // '
+	{0x00, 0x1c, 0x22, 0x41, 0x00}, // (
+	{0x00, 0x41, 0x22, 0x1c, 0x00}, // )
+	{0x14, 0x08, 0x3e, 0x08, 0x14}, // *
+	{0x08, 0x08, 0x3e, 0x08, 0x08}, // +
+	{0x00, 0x50, 0x30, 0x00, 0x00}, // ,
+	{0x08, 0x08, 0x08, 0x08, 0x08}, // -
+	{0x00, 0x60, 0x60, 0x00, 0x00}, // .
+	{0x20, 0x10, 0x08, 0x04, 0x02}, // /
+	{0x3e, 0x51, 0x49, 0x45, 0x3e}, // 0
+	{0x00, 0x42, 0x7f, 0x40, 0x00}, // 1
+	{0x42, 0x61, 0x51, 0x49, 0x46}, // 2
+	{0x21, 0x41, 0x45, 0x4b, 0x31}, // 3
+	{0x18, 0x14, 0x12, 0x7f, 0x10}, // 4
+	{0x27, 0x45, 0x45, 0x45, 0x39}, // 5
+	{0x3c, 0x4a, 0x49, 0x49, 0x30}, // 6
+	{0x01, 0x71, 0x09, 0x05, 0x03}, // 7
+	{0x36, 0x49, 0x49, 0x49, 0x36}, // 8
+	{0x06, 0x49, 0x49, 0x29, 0x1e}, // 9
+	{0x00, 0x36, 0x36, 0x00, 0x00}, // :
+	{0x00, 0x56, 0x36, 0x00, 0x00}, // ;
+	{0x08, 0x14, 0x22, 0x41, 0x00}, // <
+	{0x14, 0x14, 0x14, 0x14, 0x14}, // =
+	{0x00, 0x41, 0x22, 0x14, 0x08}, // >
+	{0x02, 0x01, 0x51, 0x09, 0x06}, // ?
+	{0x32, 0x49, 0x79, 0x41, 0x3e}, // @
+	{0x7e, 0x11, 0x11, 0x11, 0x7e}, // A
+	{0x7f, 0x49, 0x49, 0x49, 0x36}, // B
+	{0x3e, 0x41, 0x41, 0x41, 0x22}, // C
+	{0x7f, 0x41, 0x41, 0x22, 0x1c}, // D
+	{0x7f, 0x49, 0x49, 0x49, 0x41}, // E
+	{0x7f, 0x09, 0x09, 0x09, 0x01}, // F
+	{0x3e, 0x41, 0x49, 0x49, 0x7a}, // G
+	{0x7f, 0x08, 0x08, 0x08, 0x7f}, // H
+	{0x00, 0x41, 0x7f, 0x41, 0x00}, // I
+	{0x20, 0x40, 0x41, 0x3f, 0x01}, // J
+	{0x7f, 0x08, 0x14, 0x22, 0x41}, // K
+	{0x7f, 0x40, 0x40, 0x40, 0x40}, // L
+	{0x7f, 0x02, 0x0c, 0x02, 0x7f}, // M
+	{0x7f, 0x04, 0x08, 0x10, 0x7f}, // N
+	{0x3e, 0x41, 0x41, 0x41, 0x3e}, // O
+	{0x7f, 0x09, 0x09, 0x09, 0x06}, // P
+	{0x3e, 0x41, 0x51, 0x21, 0x5e}, // Q
+	{0x7f, 0x09, 0x19, 0x29, 0x46}, // R
+	{0x46, 0x49, 0x49, 0x49, 0x31}, // S
+	{0x01, 0x01, 0x7f, 0x01, 0x01}, // T
+	{0x3f, 0x40, 0x40, 0x40, 0x3f}, // U
+	{0x1f, 0x20, 0x40, 0x20, 0x1f}, // V
+	{0x3f, 0x40, 0x38, 0x40, 0x3f}, // W
+	{0x63, 0x14, 0x08, 0x14, 0x63}, // X
+	{0x07, 0x08, 0x70, 0x08, 0x07}, // Y
+	{0x61, 0x51, 0x49, 0x45, 0x43}, // Z
+	{0x00, 0x7f, 0x41, 0x41, 0x00}, // [
+	{0x02, 0x04, 0x08, 0x10, 0x20}, // backslash
+	{0x00, 0x41, 0x41, 0x7f, 0x00}, // ]
+	{0x04, 0x02, 0x01, 0x02, 0x04}, // ^
+	{0x40, 0x40, 0x40, 0x40, 0x40}, // _
+	{0x00, 0x01, 0x02, 0x04, 0x00}, // `
+	{0x20, 0x54, 0x54, 0x54, 0x78}, // a
+	{0x7f, 0x48, 0x44, 0x44, 0x38}, // b
+	{0x38, 0x44, 0x44, 0x44, 0x20}, // c
+	{0x38, 0x44, 0x44, 0x48, 0x7f}, // d
+	{0x38, 0x54, 0x54, 0x54, 0x18}, // e
+	{0x08, 0x7e, 0x09, 0x01, 0x02}, // f
+	{0x0c, 0x52, 0x52, 0x52, 0x3e}, // g
+	{0x7f, 0x08, 0x04, 0x04, 0x78}, // h
+	{0x00, 0x44, 0x7d, 0x40, 0x00}, // i
+	{0x20, 0x40, 0x44, 0x3d, 0x00}, // j
+	{0x7f, 0x10, 0x28, 0x44, 0x00}, // k
+	{0x00, 0x41, 0x7f, 0x40, 0x00}, // l
+	{0x7c, 0x04, 0x18, 0x04, 0x78}, // m
+	{0x7c, 0x08, 0x04, 0x04, 0x78}, // n
+	{0x38, 0x44, 0x44, 0x44, 0x38}, // o
+	{0x7c, 0x14, 0x14, 0x14, 0x08}, // p
+	{0x08, 0x14, 0x14, 0x18, 0x7c}, // q
+	{0x7c, 0x08, 0x04, 0x04, 0x08}, // r
+	{0x48, 0x54, 0x54, 0x54, 0x20}, // s
+	{0x04, 0x3f, 0x44, 0x40, 0x20}, // t
+	{0x3c, 0x40, 0x40, 0x20, 0x7c}, // u
+	{0x1c, 0x20, 0x40, 0x20, 0x1c}, // v
+	{0x3c, 0x40, 0x30, 0x40, 0x3c}, // w
+	{0x44, 0x28, 0x10, 0x28, 0x44}, // x
+	{0x0c, 0x50, 0x50, 0x50, 0x3c}, // y
+	{0x44, 0x64, 0x54, 0x4c, 0x44}, // z
+	{0x00, 0x08, 0x36, 0x41, 0x00}, // {
+	{0x00, 0x00, 0x7f, 0x00, 0x00}, // |
+	{0x00, 0x41, 0x36, 0x08, 0x00}, // }
+	{0x08, 0x04, 0x08, 0x10, 0x08}, // ~
+}
+
+// CardCache keeps rendered PNG cards on disk. A card is stored under its market and what it
+// shows, so a bet that moves the odds makes the next request render a fresh card and the
+// stale one is removed.
+type CardCache struct {
+	dir string
+	mu  sync.Mutex
+}
+
+var (
+	globalCardCache *CardCache
+	cardCacheOnce   sync.Once
+)
+
+// GetCardCache returns the shared card cache in CARD_CACHE_DIR, by default a directory in the
+// system's temporary directory
+func GetCardCache() *CardCache {
+	cardCacheOnce.Do(func() {
+		dir := strings.TrimSpace(os.Getenv("CARD_CACHE_DIR"))
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "predictionbot-cards")
+		}
+		globalCardCache = NewCardCache(dir)
+	})
+	return globalCardCache
+}
+
+// NewCardCache creates a card cache in dir, which is created on first use
+func NewCardCache(dir string) *CardCache {
+	return &CardCache{dir: dir}
+}
+
+// PNG returns the market's PNG card, rendering and storing it unless the current one is cached.
+// A card that cannot be stored is still returned.
+func (c *CardCache) PNG(market *storage.Market, question string, pools storage.PublicPools) ([]byte, error) {
+	card := newMarketCard(market, question, pools, cardPNGLineLength)
+	path := filepath.Join(c.dir, fmt.Sprintf("%d-%s.png", market.ID, card.key()))
+	if data, err := os.ReadFile(path); err == nil {
+		return data, nil
+	}
+
+	data, err := card.png()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.store(market.ID, path, data); err != nil {
+		logger.Debug(0, "card_cache_failed", fmt.Sprintf("market_id=%d error=%v", market.ID, err))
+	}
+	return data, nil
+}
+
+// store writes a card and removes the market's other, stale cards
+func (c *CardCache) store(marketID int64, path string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	stale, _ := filepath.Glob(filepath.Join(c.dir, fmt.Sprintf("%d-*.png", marketID)))
+	for _, old := range stale {
+		if old != path {
+			os.Remove(old)
+		}
+	}
+	return nil
+}
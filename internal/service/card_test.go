@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestRenderMarketCard(t *testing.T) {
+	market := &storage.Market{ID: 7, Icon: "🎯", Status: storage.MarketStatusFinalized, Outcome: "NO"}
+	question := "Will <b>this</b> & that " + strings.Repeat("happen again and again ", 10)
+	card := RenderMarketCard(market, question, storage.PublicPools{Yes: 100, No: 300})
+
+	var parsed struct {
+		Texts []string `xml:"text"`
+		Rects []struct {
+			Width string `xml:"width,attr"`
+		} `xml:"rect"`
+	}
+	if err := xml.Unmarshal(card, &parsed); err != nil {
+		t.Fatalf("Expected a well-formed SVG, got %v: %s", err, card)
+	}
+	// Icon, question lines, status and deadline
+	if len(parsed.Texts) != 3+cardMaxLines {
+		t.Fatalf("Expected %d text elements, got %q", 3+cardMaxLines, parsed.Texts)
+	}
+	if !strings.HasPrefix(parsed.Texts[1], "Will <b>this</b> & that") || !strings.HasSuffix(parsed.Texts[cardMaxLines], "…") {
+		t.Errorf("Unexpected question lines %q", parsed.Texts[1:cardMaxLines+1])
+	}
+	if parsed.Texts[cardMaxLines+1] != "Resolved NO" || parsed.Texts[cardMaxLines+2] != "Finalized" {
+		t.Errorf("Expected the outcome on a finalized card, got %q", parsed.Texts[cardMaxLines+1:])
+	}
+	// Background and the two halves of the odds bar, YES a quarter of it
+	if len(parsed.Rects) != 3 || parsed.Rects[1].Width != "260" {
+		t.Errorf("Expected a 25%% YES bar, got %+v", parsed.Rects)
+	}
+}
+
+func TestRenderMarketCardPNG(t *testing.T) {
+	market := &storage.Market{ID: 7, Icon: "🎯", Status: storage.MarketStatusActive, ExpiresAt: time.Now().Add(time.Hour)}
+	data, err := RenderMarketCardPNG(market, "Will the 5x7 font draw Привет?", storage.PublicPools{Yes: 1, No: 1})
+	if err != nil {
+		t.Fatalf("Failed to render card: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected a PNG, got %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != cardWidth || b.Dy() != cardHeight {
+		t.Errorf("Expected a %dx%d card, got %v", cardWidth, cardHeight, b)
+	}
+	// The first glyph pixel of the question is white
+	if r, g, b, _ := img.At(cardMargin+1, 90+1).RGBA(); r>>8 != 0xff || g>>8 != 0xff || b>>8 != 0xff {
+		t.Errorf("Expected the question drawn in white, got %d,%d,%d", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestCardCacheInvalidatesOnOddsChange(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCardCache(dir)
+	market := &storage.Market{ID: 9, Question: "Cached?", Status: storage.MarketStatusActive, ExpiresAt: time.Now().Add(time.Hour)}
+
+	first, err := cache.PNG(market, market.Question, storage.PublicPools{Yes: 10, No: 10})
+	if err != nil {
+		t.Fatalf("Failed to render card: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "9-*.png"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one cached card, got %v", files)
+	}
+	if again, _ := cache.PNG(market, market.Question, storage.PublicPools{Yes: 10, No: 10}); !bytes.Equal(first, again) {
+		t.Error("Expected the cached card for unchanged odds")
+	}
+
+	if _, err := cache.PNG(market, market.Question, storage.PublicPools{Yes: 30, No: 10}); err != nil {
+		t.Fatalf("Failed to render card: %v", err)
+	}
+	updated, _ := filepath.Glob(filepath.Join(dir, "9-*.png"))
+	if len(updated) != 1 || updated[0] == files[0] {
+		t.Errorf("Expected the stale card to be replaced, got %v", updated)
+	}
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed", files[0])
+	}
+}
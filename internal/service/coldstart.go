@@ -0,0 +1,79 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// DefaultColdStartDelay is how long a market may go without a single bet before its creator
+// is nudged to share it
+const DefaultColdStartDelay = 6 * time.Hour
+
+// LoadColdStartDelay reads COLD_START_HOURS, falling back to the default for missing or invalid
+// values. 0 turns the nudges off.
+func LoadColdStartDelay() time.Duration {
+	if hours, err := strconv.Atoi(os.Getenv("COLD_START_HOURS")); err == nil && hours >= 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return DefaultColdStartDelay
+}
+
+// ColdStartGroupNudges reads COLD_START_GROUP_NUDGE: when set, markets created in a group chat
+// are also nudged in that group
+func ColdStartGroupNudges() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("COLD_START_GROUP_NUDGE"))
+	return enabled
+}
+
+// ShareURL returns the Telegram link that opens the share dialog for a market's deep link,
+// "" when the deep link is unknown
+func (s MarketShare) ShareURL() string {
+	if s.DeepLink == "" {
+		return ""
+	}
+	return "https://t.me/share/url?url=" + url.QueryEscape(s.DeepLink) + "&text=" + url.QueryEscape(s.Text)
+}
+
+// ColdStartNudgeText formats the creator's nudge for a market nobody has bet on after waited
+func ColdStartNudgeText(market *storage.Market, question string, share MarketShare, waited time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📣 Nobody has bet on market #%d yet\n\n📝 %s\n\n", market.ID, withIcon(market.Icon, truncateString(question, 50)))
+	fmt.Fprintf(&b, "It has been open for %s. Markets pick up once a few people see them: share it with a group or a friend, or place the first bet yourself.", formatDuration(waited))
+	if share.QRURL != "" {
+		fmt.Fprintf(&b, "\n\n🖨️ QR code for posters: %s", share.QRURL)
+	}
+	return b.String()
+}
+
+// ColdStartGroupText formats the nudge posted to the group a market was created in
+func ColdStartGroupText(market *storage.Market, share MarketShare) string {
+	return fmt.Sprintf("👀 Market #%d is still waiting for its first bet. Who's in?\n\n%s", market.ID, share.Text)
+}
+
+// SendColdStartNudges emits a ColdStartNudge for every open market that has gone delay without
+// a bet, and a ColdStartGroupNudge for group markets when groups is set. Each market is nudged
+// once. It returns how many markets were nudged; delay <= 0 nudges none.
+func SendColdStartNudges(notifier Notifier, now time.Time, delay time.Duration, groups bool) int {
+	if delay <= 0 {
+		return 0
+	}
+	markets, err := storage.ClaimColdStartMarkets(now.Add(-delay), now)
+	if err != nil {
+		logger.Debug(0, "cold_start_query_failed", fmt.Sprintf("error=%s", err.Error()))
+	}
+
+	for _, market := range markets {
+		notifier.Emit(ColdStartNudge{Market: market, Waited: delay})
+		if groups && market.GroupID != 0 {
+			notifier.Emit(ColdStartGroupNudge{Market: market})
+		}
+	}
+	return len(markets)
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestSendColdStartNudges(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator, _ := storage.CreateUser(7601, "creator", "Creator")
+	public, _ := storage.CreateMarket(creator.ID, "Will the library reopen?", time.Now().Add(48*time.Hour))
+	group, _ := storage.CreateMarketWithOptions(creator.ID, "Will the team ship on Friday?", time.Now().Add(48*time.Hour), storage.MarketOptions{GroupID: -1001})
+
+	recorder := NewRecordingNotifier()
+	now := time.Now()
+	if nudged := SendColdStartNudges(recorder, now, DefaultColdStartDelay, true); nudged != 0 {
+		t.Errorf("Expected fresh markets not to be nudged, nudged %d", nudged)
+	}
+	if nudged := SendColdStartNudges(recorder, now.Add(7*time.Hour), 0, true); nudged != 0 {
+		t.Errorf("Expected no nudges when turned off, nudged %d", nudged)
+	}
+	if nudged := SendColdStartNudges(recorder, now.Add(7*time.Hour), DefaultColdStartDelay, true); nudged != 2 {
+		t.Fatalf("Expected both markets to be nudged, nudged %d", nudged)
+	}
+	if nudged := SendColdStartNudges(recorder, now.Add(8*time.Hour), DefaultColdStartDelay, true); nudged != 0 {
+		t.Errorf("Expected each market to be nudged once, nudged %d more", nudged)
+	}
+
+	events := recorder.Events()
+	if len(events) != 3 {
+		t.Fatalf("Expected 2 creator nudges and 1 group nudge, got %+v", events)
+	}
+	if e, ok := events[0].(ColdStartNudge); !ok || e.Market.ID != public.ID || e.Waited != DefaultColdStartDelay {
+		t.Errorf("Expected a nudge for market %d, got %+v", public.ID, events[0])
+	}
+	if e, ok := events[2].(ColdStartGroupNudge); !ok || e.Market.ID != group.ID {
+		t.Errorf("Expected a group nudge for market %d, got %+v", group.ID, events[2])
+	}
+}
+
+func TestColdStartNudgeText(t *testing.T) {
+	market := &storage.Market{ID: 12, Question: "Will it snow?", Icon: "❄️"}
+	share := MarketShare{MarketID: 12, Text: "❄️ Will it snow?\nNo bets yet\nBet on it: https://t.me/bot?startapp=market_12", DeepLink: "https://t.me/bot?startapp=market_12"}
+
+	text := ColdStartNudgeText(market, market.Question, share, 6*time.Hour)
+	if !strings.Contains(text, "#12") || !strings.Contains(text, "Will it snow?") {
+		t.Errorf("Unexpected nudge text %q", text)
+	}
+	if got := share.ShareURL(); !strings.HasPrefix(got, "https://t.me/share/url?url=https%3A%2F%2Ft.me%2Fbot%3Fstartapp%3Dmarket_12&text=") {
+		t.Errorf("Unexpected share URL %q", got)
+	}
+	if (MarketShare{}).ShareURL() != "" {
+		t.Error("Expected no share URL without a deep link")
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// DefaultCreatorAbsence is how long a locked market waits for its creator before bettors may
+// propose an outcome themselves
+const DefaultCreatorAbsence = 72 * time.Hour
+
+// DefaultProposalVoteWindow is how long bettors vote on a proposed outcome
+const DefaultProposalVoteWindow = 24 * time.Hour
+
+// CreatorAbsence reads COMMUNITY_RESOLUTION_HOURS. COMMUNITY_RESOLUTION_HOURS=0 turns community
+// proposals off, reported as a zero duration.
+func CreatorAbsence() time.Duration {
+	if hours, err := strconv.Atoi(os.Getenv("COMMUNITY_RESOLUTION_HOURS")); err == nil && hours >= 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return DefaultCreatorAbsence
+}
+
+// ProposalVoteWindow reads COMMUNITY_VOTE_HOURS
+func ProposalVoteWindow() time.Duration {
+	if hours, err := strconv.Atoi(os.Getenv("COMMUNITY_VOTE_HOURS")); err == nil && hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return DefaultProposalVoteWindow
+}
+
+// ProposeOutcome lets a bettor propose the outcome of a market whose creator has not resolved it
+// within CreatorAbsence of it locking. The other bettors are asked to vote on it.
+func (s *PayoutService) ProposeOutcome(ctx context.Context, marketID int64, proposer *storage.User, outcome string) (*storage.CommunityProposal, error) {
+	if proposer == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if outcome != "YES" && outcome != "NO" {
+		return nil, fmt.Errorf("invalid outcome: must be 'YES' or 'NO'")
+	}
+	absence := CreatorAbsence()
+	if absence == 0 {
+		return nil, fmt.Errorf("outcome cannot be proposed: community resolution is disabled")
+	}
+
+	proposal, err := storage.CreateCommunityProposal(ctx, marketID, proposer.ID, outcome, absence, ProposalVoteWindow())
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug(proposer.TelegramID, "market_outcome_proposed", fmt.Sprintf("market_id=%d proposal_id=%d outcome=%s", marketID, proposal.ID, outcome))
+
+	emitter := s.events()
+	go func() {
+		market, err := storage.GetMarketByID(marketID)
+		if err == nil && market != nil {
+			emitter.Emit(ProposalOpened{Proposal: proposal, Question: market.Question})
+		}
+	}()
+
+	return proposal, nil
+}
+
+// VoteOnProposal confirms or rejects a proposed outcome with the voter's stake in the market
+func (s *PayoutService) VoteOnProposal(ctx context.Context, proposalID int64, voter *storage.User, confirm bool) (*storage.CommunityProposal, error) {
+	if voter == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	proposal, err := storage.VoteOnCommunityProposal(ctx, proposalID, voter.ID, confirm)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug(voter.TelegramID, "market_outcome_vote", fmt.Sprintf("market_id=%d proposal_id=%d confirm=%t", proposal.MarketID, proposal.ID, confirm))
+	return proposal, nil
+}
+
+// CloseProposals tallies every proposal whose vote has ended. Accepted outcomes resolve the market
+// and are announced like a creator's resolution; the rest are escalated to the admins.
+func (s *PayoutService) CloseProposals(ctx context.Context) {
+	ids, err := storage.ListDueCommunityProposals()
+	if err != nil {
+		logger.Debug(0, "community_proposals_query_failed", fmt.Sprintf("error=%s", err.Error()))
+		return
+	}
+
+	for _, id := range ids {
+		proposal, err := storage.CloseCommunityProposal(ctx, id)
+		if err != nil {
+			logger.Debug(0, "community_proposal_close_failed", fmt.Sprintf("proposal_id=%d error=%s", id, err.Error()))
+			continue
+		}
+
+		logger.Debug(0, "community_proposal_closed", fmt.Sprintf("market_id=%d proposal_id=%d status=%s confirm_stake=%d reject_stake=%d",
+			proposal.MarketID, proposal.ID, proposal.Status, proposal.ConfirmStake, proposal.RejectStake))
+
+		switch proposal.Status {
+		case storage.ProposalStatusAccepted:
+			s.publishResolution(proposal.MarketID, proposal.Outcome)
+		case storage.ProposalStatusEscalated:
+			market, err := storage.GetMarketByID(proposal.MarketID)
+			if err == nil && market != nil {
+				s.events().Emit(ProposalEscalated{Proposal: proposal, Question: market.Question})
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestCreatorAbsenceConfig(t *testing.T) {
+	t.Setenv("COMMUNITY_RESOLUTION_HOURS", "")
+	if got := CreatorAbsence(); got != DefaultCreatorAbsence {
+		t.Errorf("Expected default %v, got %v", DefaultCreatorAbsence, got)
+	}
+	t.Setenv("COMMUNITY_RESOLUTION_HOURS", "0")
+	if got := CreatorAbsence(); got != 0 {
+		t.Errorf("Expected 0 to disable proposals, got %v", got)
+	}
+	t.Setenv("COMMUNITY_VOTE_HOURS", "6")
+	if got := ProposalVoteWindow(); got != 6*time.Hour {
+		t.Errorf("Expected 6h vote window, got %v", got)
+	}
+}
+
+func TestProposeOutcomeDisabled(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("COMMUNITY_RESOLUTION_HOURS", "0")
+
+	bettor, _ := storage.CreateUser(7001, "bettor", "Bettor")
+	if _, err := NewPayoutService().ProposeOutcome(context.Background(), 1, bettor, "YES"); err == nil {
+		t.Error("Expected an error while community resolution is disabled")
+	}
+}
+
+func TestCloseProposals(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	recorder := NewRecordingNotifier()
+	payoutService := NewPayoutServiceWithNotifier(recorder)
+
+	creator, _ := storage.CreateUser(7101, "creator", "Creator")
+	alice, _ := storage.CreateUser(7102, "alice", "Alice")
+	bob, _ := storage.CreateUser(7103, "bob", "Bob")
+
+	accepted, _ := storage.CreateMarket(creator.ID, "Will the bettors settle this one?", time.Now().Add(time.Hour))
+	escalated, _ := storage.CreateMarket(creator.ID, "Will the admins settle this one?", time.Now().Add(time.Hour))
+	for _, market := range []*storage.Market{accepted, escalated} {
+		_ = storage.PlaceBet(ctx, alice.ID, market.ID, "YES", 100)
+		_ = storage.PlaceBet(ctx, bob.ID, market.ID, "NO", 100)
+	}
+	storage.DB().Exec(`UPDATE markets SET status = 'LOCKED', locked_at = ?`, time.Now().Add(-80*time.Hour).UTC().Format("2006-01-02 15:04:05"))
+
+	first, err := payoutService.ProposeOutcome(ctx, accepted.ID, alice, "YES")
+	if err != nil {
+		t.Fatalf("ProposeOutcome failed: %v", err)
+	}
+	second, _ := payoutService.ProposeOutcome(ctx, escalated.ID, alice, "YES")
+	if _, err := payoutService.VoteOnProposal(ctx, first.ID, bob, true); err != nil {
+		t.Fatalf("VoteOnProposal failed: %v", err)
+	}
+	payoutService.VoteOnProposal(ctx, second.ID, bob, false)
+	if events := recorder.WaitFor(2, time.Second); len(events) != 2 || events[0].Kind() != "proposal_opened" {
+		t.Fatalf("Expected two proposal_opened events, got %+v", events)
+	}
+
+	storage.DB().Exec(`UPDATE community_proposals SET closes_at = ?`, time.Now().Add(-time.Minute).UTC().Format("2006-01-02 15:04:05"))
+	payoutService.CloseProposals(ctx)
+
+	if m, _ := storage.GetMarketByID(accepted.ID); m.Status != storage.MarketStatusResolved || m.Outcome != "YES" {
+		t.Errorf("Expected the confirmed outcome to resolve the market, got %s %s", m.Status, m.Outcome)
+	}
+	if m, _ := storage.GetMarketByID(escalated.ID); m.Status != storage.MarketStatusDisputed {
+		t.Errorf("Expected the contested market to be DISPUTED, got %s", m.Status)
+	}
+
+	kinds := map[string]bool{}
+	for _, event := range recorder.WaitFor(4, time.Second) {
+		kinds[event.Kind()] = true
+	}
+	if !kinds["resolution_published"] || !kinds["proposal_escalated"] {
+		t.Errorf("Expected resolution_published and proposal_escalated events, got %v", kinds)
+	}
+}
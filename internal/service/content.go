@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"predictionbot/internal/storage"
+)
+
+// MaxContentLength keeps a page within a single Telegram message
+const MaxContentLength = 4000
+
+// Content keys that can be served and edited
+const (
+	ContentRules = "rules"
+	ContentFAQ   = "faq"
+)
+
+// defaultContent is shown until an admin edits a page
+func defaultContent(key string) string {
+	currency := GetCurrency().Name
+	switch key {
+	case ContentRules:
+		return fmt.Sprintf(`House rules
+
+1. Markets must ask a clear YES/NO question with a deadline. Add resolution criteria so everyone knows how it will be decided.
+2. The creator resolves their market after it closes. There are no fees: winners split the whole pool in proportion to their stakes.
+3. Anyone who bet can dispute a resolution within %s. Disputed markets are frozen until an admin or oracle decides the outcome, and that decision is final.
+4. If your balance drops below %s you can claim a %s bailout once every %s.
+5. Moderators may hide markets that are spam, abusive or cannot be resolved.`,
+			formatDuration(DefaultDisputeDelay), FormatAmount(storage.BailoutBalanceThreshold), FormatAmount(storage.BailoutAmount), formatDuration(storage.BailoutCooldown))
+	case ContentFAQ:
+		return fmt.Sprintf(`Frequently asked questions
+
+What is %[1]s? The play currency used for betting. It has no real-world value.
+How are odds set? By the pool: the YES chance is the share of %[1]s bet on YES.
+Where do I see my bets? In the web app profile or with /mybets.`, currency)
+	}
+	return ""
+}
+
+// IsContentKey reports whether key names an editable page
+func IsContentKey(key string) bool {
+	return key == ContentRules || key == ContentFAQ
+}
+
+// GetContent returns the page for key, falling back to the built-in default until it is edited
+func GetContent(key string) (*storage.ContentPage, error) {
+	if !IsContentKey(key) {
+		return nil, fmt.Errorf("content not found")
+	}
+	page, err := storage.GetContent(key)
+	if err != nil {
+		return nil, err
+	}
+	if page == nil {
+		page = &storage.ContentPage{Key: key, Body: defaultContent(key)}
+	}
+	return page, nil
+}
+
+// SetContent validates and stores a new version of a page. actor is the editing admin.
+func SetContent(ctx context.Context, actor *storage.User, key, body string) (*storage.ContentPage, error) {
+	if !IsContentKey(key) {
+		return nil, fmt.Errorf("content not found")
+	}
+	body = strings.TrimSpace(strings.ReplaceAll(body, "\r\n", "\n"))
+	if body == "" {
+		return nil, fmt.Errorf("invalid content: body is empty")
+	}
+	if !utf8.ValidString(body) {
+		return nil, fmt.Errorf("invalid content: body is not valid UTF-8")
+	}
+	if n := utf8.RuneCountInString(body); n > MaxContentLength {
+		return nil, fmt.Errorf("invalid content: body is %d characters, at most %d allowed", n, MaxContentLength)
+	}
+
+	if err := storage.SetContent(ctx, key, body, actor.ID); err != nil {
+		return nil, err
+	}
+	return storage.GetContent(key)
+}
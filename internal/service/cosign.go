@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// DefaultCosignMinPool is the pool size (WSC) from which a resolution needs a second signer
+const DefaultCosignMinPool = 10000
+
+// LoadCosignMinPool reads COSIGN_MIN_POOL, falling back to the default for missing or invalid
+// values. COSIGN_MIN_POOL=0 turns co-signing off.
+func LoadCosignMinPool() int64 {
+	if v, err := strconv.ParseInt(os.Getenv("COSIGN_MIN_POOL"), 10, 64); err == nil && v >= 0 {
+		return v
+	}
+	return DefaultCosignMinPool
+}
+
+// requestCosign parks the creator's outcome until a co-signer confirms it and asks the co-signers
+func (s *PayoutService) requestCosign(ctx context.Context, marketID int64, creator *storage.User, outcome string, totalPool int64) (*storage.ResolutionCosign, error) {
+	cosign, err := storage.CreateResolutionCosign(ctx, marketID, creator.ID, outcome)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug(creator.TelegramID, "market_resolution_cosign_requested", fmt.Sprintf("market_id=%d cosign_id=%d outcome=%s pool=%d", marketID, cosign.ID, outcome, totalPool))
+
+	emitter := s.events()
+	go func() {
+		market, err := storage.GetMarketByID(marketID)
+		if err == nil && market != nil {
+			emitter.Emit(CosignRequested{Cosign: cosign, Question: market.Question, TotalPool: totalPool})
+		}
+	}()
+
+	return cosign, nil
+}
+
+// DecideCosign confirms or rejects a resolution waiting for a co-signer. cosigner may co-sign
+// when they are an admin or an oracle, which the caller checks. Confirming resolves the market
+// and starts its dispute window; rejecting sends it back to the creator.
+func (s *PayoutService) DecideCosign(ctx context.Context, cosignID int64, cosigner *storage.User, allowed, confirm bool) (*storage.ResolutionCosign, error) {
+	if cosigner == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if !allowed {
+		return nil, fmt.Errorf("only an admin or oracle can co-sign resolutions")
+	}
+
+	cosign, err := storage.DecideResolutionCosign(ctx, cosignID, cosigner.ID, confirm)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug(cosigner.TelegramID, "market_resolution_cosign_decided", fmt.Sprintf("market_id=%d cosign_id=%d status=%s", cosign.MarketID, cosign.ID, cosign.Status))
+
+	if confirm {
+		s.publishResolution(cosign.MarketID, cosign.Outcome)
+	}
+
+	emitter := s.events()
+	go func() {
+		market, err := storage.GetMarketByID(cosign.MarketID)
+		if err == nil && market != nil {
+			emitter.Emit(CosignDecided{Cosign: cosign, Market: market})
+		}
+	}()
+
+	return cosign, nil
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CreateDraftTTL is how long an unfinished /create conversation is kept after its last step
+const CreateDraftTTL = 30 * time.Minute
+
+// CreateStep is what a /create conversation waits for next
+type CreateStep string
+
+const (
+	// CreateStepQuestion waits for the question, optionally followed by "| criteria"
+	CreateStepQuestion CreateStep = "question"
+	// CreateStepDeadline waits for a deadline, from a button or typed
+	CreateStepDeadline CreateStep = "deadline"
+	// CreateStepCategory waits for a category button
+	CreateStepCategory CreateStep = "category"
+	// CreateStepConfirm waits for the creator to confirm or cancel
+	CreateStepConfirm CreateStep = "confirm"
+)
+
+// DeadlinePreset is a deadline offered as a button; Input is what ParseDeadline reads
+type DeadlinePreset struct {
+	Label string
+	Input string
+}
+
+// CreateDeadlinePresets are the deadline buttons of /create
+var CreateDeadlinePresets = []DeadlinePreset{
+	{Label: "1 day", Input: "24h"},
+	{Label: "3 days", Input: "in 3 days"},
+	{Label: "1 week", Input: "in 7 days"},
+	{Label: "End of month", Input: "end of month"},
+}
+
+// CreateCategories are the category buttons of /create. A category is added to the question as
+// a hashtag, which also gives the market its icon.
+var CreateCategories = []string{"sports", "crypto", "politics", "tech", "weather", "movies"}
+
+// CategoryLabel is a category's button text: its icon and name
+func CategoryLabel(category string) string {
+	name := strings.ToUpper(category[:1]) + category[1:]
+	if icon, ok := categoryIcons[category]; ok {
+		return icon + " " + name
+	}
+	return name
+}
+
+// AddCategoryTag appends a category's hashtag to a question, unless the question already has it
+func AddCategoryTag(question, category string) string {
+	if category == "" {
+		return question
+	}
+	for _, tag := range ParseHashtags(question) {
+		if tag == category {
+			return question
+		}
+	}
+	return question + " #" + category
+}
+
+// MarketDraft is a market being put together in a /create conversation
+type MarketDraft struct {
+	Step      CreateStep
+	Question  string
+	Criteria  string
+	ExpiresAt time.Time
+	// Category is "" when the creator chose none
+	Category string
+	// Key makes creating the market idempotent when the confirmation is delivered twice
+	Key       string
+	updatedAt time.Time
+}
+
+// FullQuestion is the question the market is created with, the category's hashtag included
+func (d MarketDraft) FullQuestion() string {
+	return AddCategoryTag(d.Question, d.Category)
+}
+
+// CreateFlow keeps the /create conversations in progress, one per user (Telegram ID). They live
+// in memory only; a restart drops them.
+type CreateFlow struct {
+	mu     sync.Mutex
+	drafts map[int64]*MarketDraft
+	now    func() time.Time
+}
+
+// NewCreateFlow returns an empty CreateFlow
+func NewCreateFlow() *CreateFlow {
+	return &CreateFlow{drafts: make(map[int64]*MarketDraft), now: time.Now}
+}
+
+// Start begins a conversation for a user, replacing the one they had
+func (f *CreateFlow) Start(telegramID int64, key string) MarketDraft {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	draft := &MarketDraft{Step: CreateStepQuestion, Key: key, updatedAt: f.now()}
+	f.drafts[telegramID] = draft
+	return *draft
+}
+
+// Get returns a user's conversation, and false when they have none or it expired
+func (f *CreateFlow) Get(telegramID int64) (MarketDraft, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	draft := f.draft(telegramID)
+	if draft == nil {
+		return MarketDraft{}, false
+	}
+	return *draft, true
+}
+
+// Cancel drops a user's conversation and reports whether there was one
+func (f *CreateFlow) Cancel(telegramID int64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	found := f.draft(telegramID) != nil
+	delete(f.drafts, telegramID)
+	return found
+}
+
+// SetQuestion records the question, and the resolution criteria after a "|", and asks for the deadline
+func (f *CreateFlow) SetQuestion(telegramID int64, text string) (MarketDraft, error) {
+	question, criteria, _ := strings.Cut(text, "|")
+	question, err := SanitizeQuestion(question)
+	if err != nil {
+		return MarketDraft{}, err
+	}
+	criteria, err = SanitizeCriteria(criteria)
+	if err != nil {
+		return MarketDraft{}, err
+	}
+
+	return f.advance(telegramID, CreateStepQuestion, func(d *MarketDraft) error {
+		d.Question, d.Criteria = question, criteria
+		d.Step = CreateStepDeadline
+		return nil
+	})
+}
+
+// SetDeadline records the deadline, read with ParseDeadline in loc, and asks for the category
+func (f *CreateFlow) SetDeadline(telegramID int64, input string, loc *time.Location) (MarketDraft, error) {
+	return f.advance(telegramID, CreateStepDeadline, func(d *MarketDraft) error {
+		expiresAt, err := ParseDeadline(input, f.now(), loc)
+		if err != nil {
+			return err
+		}
+		d.ExpiresAt = expiresAt
+		d.Step = CreateStepCategory
+		return nil
+	})
+}
+
+// SetCategory records one of CreateCategories, or "" for none, and asks for confirmation
+func (f *CreateFlow) SetCategory(telegramID int64, category string) (MarketDraft, error) {
+	return f.advance(telegramID, CreateStepCategory, func(d *MarketDraft) error {
+		known := category == ""
+		for _, c := range CreateCategories {
+			known = known || c == category
+		}
+		if !known {
+			return fmt.Errorf("invalid category: %s", category)
+		}
+		if _, err := SanitizeQuestion(AddCategoryTag(d.Question, category)); err != nil {
+			return fmt.Errorf("invalid category: the question is too long to add #%s", category)
+		}
+		d.Category = category
+		d.Step = CreateStepConfirm
+		return nil
+	})
+}
+
+// Finish ends a confirmed conversation and returns the draft to create the market from
+func (f *CreateFlow) Finish(telegramID int64) (MarketDraft, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	draft := f.draft(telegramID)
+	if draft == nil {
+		return MarketDraft{}, fmt.Errorf("no market draft: start again with /create")
+	}
+	if draft.Step != CreateStepConfirm {
+		return MarketDraft{}, fmt.Errorf("market draft is not complete")
+	}
+	delete(f.drafts, telegramID)
+	return *draft, nil
+}
+
+// advance applies a step to a user's conversation if it is waiting for that step
+func (f *CreateFlow) advance(telegramID int64, step CreateStep, apply func(*MarketDraft) error) (MarketDraft, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	draft := f.draft(telegramID)
+	if draft == nil {
+		return MarketDraft{}, fmt.Errorf("no market draft: start again with /create")
+	}
+	if draft.Step != step {
+		return MarketDraft{}, fmt.Errorf("market draft is not waiting for the %s", step)
+	}
+	if err := apply(draft); err != nil {
+		return MarketDraft{}, err
+	}
+	draft.updatedAt = f.now()
+	return *draft, nil
+}
+
+// draft returns a user's live conversation, dropping it when it expired; f.mu must be held
+func (f *CreateFlow) draft(telegramID int64) *MarketDraft {
+	draft := f.drafts[telegramID]
+	if draft != nil && f.now().Sub(draft.updatedAt) > CreateDraftTTL {
+		delete(f.drafts, telegramID)
+		return nil
+	}
+	return draft
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCreateFlow(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	flow := NewCreateFlow()
+	flow.now = func() time.Time { return now }
+
+	if _, err := flow.SetQuestion(1, "Will it snow in Berlin?"); err == nil {
+		t.Error("Expected an error without a conversation")
+	}
+
+	flow.Start(1, "tg-1-10")
+	if _, err := flow.SetDeadline(1, "24h", time.UTC); err == nil {
+		t.Error("Expected the deadline to wait for the question")
+	}
+	if _, err := flow.SetQuestion(1, "?"); err == nil {
+		t.Error("Expected a too short question to be rejected")
+	}
+	draft, err := flow.SetQuestion(1, "Will it snow in Berlin? | YES if the DWD reports snowfall")
+	if err != nil {
+		t.Fatalf("SetQuestion failed: %v", err)
+	}
+	if draft.Step != CreateStepDeadline || draft.Question != "Will it snow in Berlin?" || draft.Criteria != "YES if the DWD reports snowfall" {
+		t.Errorf("Unexpected draft %+v", draft)
+	}
+
+	if _, err := flow.SetDeadline(1, "yesterday", time.UTC); err == nil {
+		t.Error("Expected an invalid deadline to be rejected")
+	}
+	draft, err = flow.SetDeadline(1, CreateDeadlinePresets[0].Input, time.UTC)
+	if err != nil || !draft.ExpiresAt.Equal(now.Add(24*time.Hour)) {
+		t.Fatalf("Expected a deadline in 24h, got %v, %v", draft.ExpiresAt, err)
+	}
+
+	if _, err := flow.SetCategory(1, "gardening"); err == nil {
+		t.Error("Expected an unknown category to be rejected")
+	}
+	if _, err := flow.Finish(1); err == nil {
+		t.Error("Expected an incomplete draft not to finish")
+	}
+	if _, err := flow.SetCategory(1, "weather"); err != nil {
+		t.Fatalf("SetCategory failed: %v", err)
+	}
+
+	draft, err = flow.Finish(1)
+	if err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	if draft.FullQuestion() != "Will it snow in Berlin? #weather" || draft.Key != "tg-1-10" {
+		t.Errorf("Unexpected draft %+v", draft)
+	}
+	if _, ok := flow.Get(1); ok {
+		t.Error("Expected the conversation to end")
+	}
+}
+
+func TestCreateFlowExpires(t *testing.T) {
+	now := time.Now()
+	flow := NewCreateFlow()
+	flow.now = func() time.Time { return now }
+
+	flow.Start(2, "")
+	now = now.Add(CreateDraftTTL + time.Minute)
+	if _, ok := flow.Get(2); ok {
+		t.Error("Expected an idle conversation to expire")
+	}
+	if flow.Cancel(2) {
+		t.Error("Expected nothing to cancel")
+	}
+}
+
+func TestAddCategoryTag(t *testing.T) {
+	if got := AddCategoryTag("Will #bitcoin hit 100k?", "crypto"); got != "Will #bitcoin hit 100k? #crypto" {
+		t.Errorf("Unexpected question %q", got)
+	}
+	if got := AddCategoryTag("Will #Crypto recover?", "crypto"); got != "Will #Crypto recover?" {
+		t.Errorf("Expected the tag not to be added twice, got %q", got)
+	}
+	if got := AddCategoryTag("Will it rain?", ""); got != "Will it rain?" {
+		t.Errorf("Expected no tag without a category, got %q", got)
+	}
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"os"
+	"strconv"
+)
+
+// MaxCreatorRewardBps caps CREATOR_REWARD_BPS at 10%
+const MaxCreatorRewardBps = 1000
+
+// LoadCreatorRewardBps reads CREATOR_REWARD_BPS, the share of the pool in basis points (1/100 of
+// a percent) paid to a market's creator when it pays out winners. Missing, negative or invalid
+// values mean no reward, and values above MaxCreatorRewardBps are capped.
+func LoadCreatorRewardBps() int64 {
+	bps, err := strconv.ParseInt(os.Getenv("CREATOR_REWARD_BPS"), 10, 64)
+	if err != nil || bps < 0 {
+		return 0
+	}
+	return min(bps, MaxCreatorRewardBps)
+}
+
+// CreatorReward is the creator's share of a parimutuel pool, rounded down. Like the house fee
+// it only comes out of the losing side's stakes, and fee is taken first.
+func CreatorReward(totalPool, winningPool, fee, bps int64) int64 {
+	if bps <= 0 || winningPool <= 0 || totalPool-fee <= winningPool {
+		return 0
+	}
+	return min(totalPool*bps/10000, totalPool-fee-winningPool)
+}
+
+// payoutPool is what a parimutuel pool pays its winners after the house fee and the creator
+// reward
+func payoutPool(totalPool, winningPool int64) (pool, fee, reward int64) {
+	fee = RakeFee(totalPool, winningPool, LoadRakeBps())
+	reward = CreatorReward(totalPool, winningPool, fee, LoadCreatorRewardBps())
+	return totalPool - fee - reward, fee, reward
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestCreatorReward(t *testing.T) {
+	for _, tc := range []struct {
+		total, winning, fee, bps, want int64
+	}{
+		{400, 100, 0, 200, 8},
+		{400, 100, 20, 200, 8},
+		{400, 100, 0, 0, 0},
+		{1000, 990, 5, 200, 5}, // capped at what the losing side left after the fee
+		{400, 400, 0, 200, 0},
+		{400, 0, 0, 200, 0},
+	} {
+		if got := CreatorReward(tc.total, tc.winning, tc.fee, tc.bps); got != tc.want {
+			t.Errorf("CreatorReward(%d, %d, %d, %d) = %d, want %d", tc.total, tc.winning, tc.fee, tc.bps, got, tc.want)
+		}
+	}
+
+	t.Setenv("CREATOR_REWARD_BPS", "99999")
+	if got := LoadCreatorRewardBps(); got != MaxCreatorRewardBps {
+		t.Errorf("Expected the reward capped at %d, got %d", MaxCreatorRewardBps, got)
+	}
+}
+
+func TestFinalizeMarketPaysCreatorReward(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("RAKE_BPS", "500")
+	t.Setenv("CREATOR_REWARD_BPS", "250")
+
+	ctx := context.Background()
+	recorder := NewRecordingNotifier()
+	payoutService := NewPayoutServiceWithNotifier(recorder)
+	creator, _ := storage.CreateUser(44471, "creator", "Creator")
+	yes, _ := storage.CreateUser(44472, "yes", "Yes")
+	no, _ := storage.CreateUser(44473, "no", "No")
+	market, _ := storage.CreateMarket(creator.ID, "Will the creator be rewarded?", time.Now().Add(time.Hour))
+	storage.PlaceBet(ctx, yes.ID, market.ID, "YES", 100)
+	storage.PlaceBet(ctx, no.ID, market.ID, "NO", 300)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	if _, err := payoutService.FinalizeMarket(ctx, market.ID, ""); err != nil {
+		t.Fatalf("FinalizeMarket failed: %v", err)
+	}
+	// The house takes 20 and the creator 10 of the 400 pool; the winner gets the other 370
+	if winner, _ := storage.GetUserByID(yes.ID); winner.Balance != 1270 {
+		t.Errorf("Expected the winner paid 370 (1270 in total), got %d", winner.Balance)
+	}
+	if c, _ := storage.GetUserByID(creator.ID); c.Balance != 1010 {
+		t.Errorf("Expected the creator paid 10 (1010 in total), got %d", c.Balance)
+	}
+	rewards, _, err := storage.ListUserTransactions(creator.ID, storage.TransactionFilter{Type: "CREATOR_REWARD"})
+	if err != nil || len(rewards) != 1 || rewards[0].Amount != 10 || rewards[0].MarketID != market.ID {
+		t.Errorf("Expected one CREATOR_REWARD transaction of 10, got %+v, %v", rewards, err)
+	}
+
+	var notice *CreatorRewardNotice
+	for _, e := range recorder.WaitFor(3, time.Second) {
+		if n, ok := e.(CreatorRewardNotice); ok {
+			notice = &n
+		}
+	}
+	if notice == nil || notice.UserID != creator.ID || notice.Amount != 10 || notice.TotalPool != 400 || notice.NewBalance != 1010 {
+		t.Errorf("Expected the creator to be told about the reward, got %+v", notice)
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultCurrencyName is the play currency's name when CURRENCY_NAME is not set
+	DefaultCurrencyName = "WSC"
+	// MaxCurrencyDecimals bounds CURRENCY_DECIMALS
+	MaxCurrencyDecimals = 4
+)
+
+// Currency is how amounts are labelled in the API, the bot and channel posts.
+// Amounts are stored as integers in minor units; Decimals is how many of their
+// digits are shown after the decimal point (2 renders 1050 as 10.50).
+type Currency struct {
+	Name     string `json:"name"`
+	Emoji    string `json:"emoji,omitempty"`
+	Decimals int    `json:"decimals"`
+}
+
+var (
+	currencyMu sync.RWMutex
+	currency   = Currency{Name: DefaultCurrencyName}
+)
+
+// LoadCurrency reads CURRENCY_NAME (default WSC), the optional CURRENCY_EMOJI and
+// CURRENCY_DECIMALS (default 0, whole units)
+func LoadCurrency() Currency {
+	c := Currency{
+		Name:  strings.TrimSpace(os.Getenv("CURRENCY_NAME")),
+		Emoji: strings.TrimSpace(os.Getenv("CURRENCY_EMOJI")),
+	}
+	if c.Name == "" {
+		c.Name = DefaultCurrencyName
+	}
+	if v, err := strconv.Atoi(os.Getenv("CURRENCY_DECIMALS")); err == nil && v >= 0 && v <= MaxCurrencyDecimals {
+		c.Decimals = v
+	}
+	return c
+}
+
+// SetCurrency configures the currency used by FormatAmount
+func SetCurrency(c Currency) {
+	currencyMu.Lock()
+	defer currencyMu.Unlock()
+	currency = c
+}
+
+// GetCurrency returns the configured currency
+func GetCurrency() Currency {
+	currencyMu.RLock()
+	defer currencyMu.RUnlock()
+	return currency
+}
+
+// FormatAmount labels an amount with the currency, e.g. "500 WSC" or "🪙 5.00 WSC"
+func FormatAmount(amount int64) string {
+	c := GetCurrency()
+	if c.Emoji != "" {
+		return fmt.Sprintf("%s %s %s", c.Emoji, FormatMoney(amount), c.Name)
+	}
+	return fmt.Sprintf("%s %s", FormatMoney(amount), c.Name)
+}
+
+// FormatMoney renders an amount in minor units with the configured decimals, e.g. 1050 as "10.50"
+func FormatMoney(amount int64) string {
+	decimals := GetCurrency().Decimals
+	if decimals == 0 {
+		return strconv.FormatInt(amount, 10)
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+	digits := strconv.FormatUint(absAmount(amount), 10)
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	split := len(digits) - decimals
+	return sign + digits[:split] + "." + digits[split:]
+}
+
+// ParseMoney parses an amount as shown by FormatMoney ("10.5", "10.50" or "10") into minor units.
+// More fractional digits than the currency has are rejected rather than rounded.
+func ParseMoney(value string) (int64, error) {
+	decimals := GetCurrency().Decimals
+	value = strings.TrimSpace(value)
+
+	whole, fraction, hasPoint := strings.Cut(value, ".")
+	if whole == "" && (!hasPoint || fraction == "") {
+		return 0, fmt.Errorf("invalid amount: %q is not a number", value)
+	}
+	if len(fraction) > decimals {
+		return 0, fmt.Errorf("invalid amount: at most %d decimal places allowed", decimals)
+	}
+	digits := whole + fraction + strings.Repeat("0", decimals-len(fraction))
+	if strings.Trim(digits, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid amount: %q is not a number", value)
+	}
+
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %q is out of range", value)
+	}
+	return amount, nil
+}
+
+// absAmount returns |amount| without overflowing on math.MinInt64
+func absAmount(amount int64) uint64 {
+	if amount < 0 {
+		return uint64(-(amount + 1)) + 1
+	}
+	return uint64(amount)
+}
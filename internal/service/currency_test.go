@@ -0,0 +1,71 @@
+package service
+
+import "testing"
+
+func TestFormatAmount(t *testing.T) {
+	defer SetCurrency(GetCurrency())
+
+	if got := FormatAmount(500); got != "500 WSC" {
+		t.Errorf("Expected default currency, got %q", got)
+	}
+
+	t.Setenv("CURRENCY_NAME", " Stars ")
+	t.Setenv("CURRENCY_EMOJI", "⭐")
+	SetCurrency(LoadCurrency())
+	if got := FormatAmount(-20); got != "⭐ -20 Stars" {
+		t.Errorf("Expected configured currency, got %q", got)
+	}
+
+	t.Setenv("CURRENCY_NAME", "")
+	t.Setenv("CURRENCY_EMOJI", "")
+	if c := LoadCurrency(); c.Name != DefaultCurrencyName || c.Emoji != "" {
+		t.Errorf("Expected default currency without configuration, got %+v", c)
+	}
+}
+
+func TestMoneyDecimals(t *testing.T) {
+	defer SetCurrency(GetCurrency())
+	SetCurrency(Currency{Name: "WSC", Decimals: 2})
+
+	formats := map[int64]string{1050: "10.50", 5: "0.05", 0: "0.00", -1050: "-10.50", 100000: "1000.00"}
+	for amount, want := range formats {
+		if got := FormatMoney(amount); got != want {
+			t.Errorf("FormatMoney(%d) = %q, want %q", amount, got, want)
+		}
+	}
+	if got := FormatAmount(1050); got != "10.50 WSC" {
+		t.Errorf("Expected decimal amount, got %q", got)
+	}
+
+	parses := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"10.50", 1050, false},
+		{"10.5", 1050, false},
+		{" 10 ", 1000, false},
+		{".05", 5, false},
+		{"10.505", 0, true},
+		{"-1", 0, true},
+		{"1e3", 0, true},
+		{".", 0, true},
+		{"", 0, true},
+		{"99999999999999999999", 0, true},
+	}
+	for _, tt := range parses {
+		got, err := ParseMoney(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d (err=%v), want %d (error %v)", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	// Whole units reject any fraction
+	SetCurrency(Currency{Name: "WSC"})
+	if _, err := ParseMoney("1.5"); err == nil {
+		t.Error("Expected error for a fraction without decimals")
+	}
+	if got, err := ParseMoney("15"); err != nil || got != 15 {
+		t.Errorf("Expected 15, got %d (err=%v)", got, err)
+	}
+}
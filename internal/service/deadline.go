@@ -0,0 +1,351 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+// ErrDeadlinePast is returned for deadlines that parse but are not in the future
+var ErrDeadlinePast = errors.New("invalid deadline: it is in the past")
+
+// maxDeadlineWords is the longest deadline SplitDeadline looks for, e.g. "next friday at 6 pm"
+const maxDeadlineWords = 5
+
+var (
+	clockPattern    = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+	compactPattern  = regexp.MustCompile(`^(\d+)(min|m|h|d|w)$`)
+	isoDatePattern  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	dayOfMonthRegex = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)?$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"january": time.January, "jan": time.January,
+	"february": time.February, "feb": time.February,
+	"march": time.March, "mar": time.March,
+	"april": time.April, "apr": time.April,
+	"may":  time.May,
+	"june": time.June, "jun": time.June,
+	"july": time.July, "jul": time.July,
+	"august": time.August, "aug": time.August,
+	"september": time.September, "sep": time.September, "sept": time.September,
+	"october": time.October, "oct": time.October,
+	"november": time.November, "nov": time.November,
+	"december": time.December, "dec": time.December,
+}
+
+// LoadTimezone resolves an IANA timezone name such as "Europe/Berlin"; "" means UTC
+func LoadTimezone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || strings.EqualFold(name, "local") {
+		return nil, fmt.Errorf("invalid timezone: %s", name)
+	}
+	return loc, nil
+}
+
+// UserLocation returns the saved timezone of a user (internal ID), UTC when unset or unknown
+func UserLocation(userID int64) *time.Location {
+	name, err := storage.GetUserTimezone(userID)
+	if err != nil {
+		return time.UTC
+	}
+	loc, err := LoadTimezone(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ParseDeadline reads a human deadline relative to now, interpreting wall-clock times in loc.
+// It understands RFC3339, Go durations ("48h"), relative times ("in 3 days", "2w"),
+// "today"/"tomorrow"/weekdays ("friday 18:00", "next fri at 6pm"), dates ("2026-11-01",
+// "nov 1", "1 november 2027 9am"), a bare time ("18:00", the next time the clock shows it) and
+// "end of day/week/month/year". A day without a time means the end of that day (23:59).
+// Weekdays are the next such day; a bare weekday includes today while its time is still ahead.
+func ParseDeadline(input string, now time.Time, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return time.Time{}, fmt.Errorf("invalid deadline: empty")
+	}
+
+	if t, err := time.Parse(time.RFC3339, input); err == nil {
+		return checkFuture(t, now)
+	}
+	if d, err := time.ParseDuration(input); err == nil {
+		if d <= 0 {
+			return time.Time{}, ErrDeadlinePast
+		}
+		return now.Add(d), nil
+	}
+
+	words := strings.Fields(strings.ToLower(strings.TrimRight(input, ".,;:")))
+	if t, ok := parseRelative(words, now); ok {
+		return t, nil
+	}
+	if t, ok := parseEndOf(words, now); ok {
+		return checkFuture(t, now)
+	}
+
+	// Split off a trailing time: "18:00", "6pm" or "6 pm", optionally after "at"
+	hour, minute, hasClock := 23, 59, false
+	if n := len(words); n > 0 {
+		if h, m, ok := parseClock(words[n-1]); ok {
+			hour, minute, hasClock = h, m, true
+			words = words[:n-1]
+		} else if n > 1 && (words[n-1] == "am" || words[n-1] == "pm") {
+			if h, m, ok := parseClock(words[n-2] + words[n-1]); ok {
+				hour, minute, hasClock = h, m, true
+				words = words[:n-2]
+			}
+		}
+	}
+	if hasClock && len(words) > 0 && words[len(words)-1] == "at" {
+		words = words[:len(words)-1]
+	}
+
+	at := func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+	}
+
+	if len(words) == 0 {
+		if !hasClock {
+			return time.Time{}, fmt.Errorf("invalid deadline: %q", input)
+		}
+		t := at(now)
+		if !t.After(now) {
+			t = at(now.AddDate(0, 0, 1))
+		}
+		return t, nil
+	}
+
+	next := false
+	if words[0] == "next" || words[0] == "this" {
+		next = words[0] == "next"
+		words = words[1:]
+	}
+
+	switch {
+	case len(words) == 1 && !next && (words[0] == "today" || words[0] == "tonight"):
+		return checkFuture(at(now), now)
+	case len(words) == 1 && !next && words[0] == "tomorrow":
+		return checkFuture(at(now.AddDate(0, 0, 1)), now)
+	case len(words) == 1:
+		if weekday, ok := weekdays[words[0]]; ok {
+			days := (int(weekday) - int(now.Weekday()) + 7) % 7
+			t := at(now.AddDate(0, 0, days))
+			if days == 0 && (next || !t.After(now)) {
+				t = at(now.AddDate(0, 0, 7))
+			}
+			return t, nil
+		}
+	}
+	if next {
+		return time.Time{}, fmt.Errorf("invalid deadline: %q", input)
+	}
+
+	if day, ok := parseDate(words, now, loc); ok {
+		return checkFuture(at(day), now)
+	}
+	return time.Time{}, fmt.Errorf("invalid deadline: %q", input)
+}
+
+// SplitDeadline finds the longest deadline at the start of text and returns it with the rest,
+// e.g. "friday 18:00 Will it rain?" gives Friday 18:00 and "Will it rain?"
+func SplitDeadline(text string, now time.Time, loc *time.Location) (time.Time, string, error) {
+	words := strings.Fields(text)
+	for n := min(maxDeadlineWords, len(words)-1); n >= 1; n-- {
+		t, err := ParseDeadline(strings.Join(words[:n], " "), now, loc)
+		if errors.Is(err, ErrDeadlinePast) {
+			return time.Time{}, "", err
+		}
+		if err == nil {
+			return t, strings.Join(words[n:], " "), nil
+		}
+	}
+	return time.Time{}, "", fmt.Errorf("invalid deadline: could not find one at the start of %q", text)
+}
+
+func checkFuture(t, now time.Time) (time.Time, error) {
+	if !t.After(now) {
+		return time.Time{}, ErrDeadlinePast
+	}
+	return t, nil
+}
+
+// parseRelative handles "in 3 days", "in an hour", "2 weeks" and "3d"
+func parseRelative(words []string, now time.Time) (time.Time, bool) {
+	if len(words) > 0 && words[0] == "in" {
+		words = words[1:]
+	}
+	var amount int
+	var unit string
+	switch len(words) {
+	case 1:
+		m := compactPattern.FindStringSubmatch(words[0])
+		if m == nil {
+			return time.Time{}, false
+		}
+		amount, _ = strconv.Atoi(m[1])
+		unit = m[2]
+	case 2:
+		if words[0] == "a" || words[0] == "an" {
+			amount = 1
+		} else if n, err := strconv.Atoi(words[0]); err == nil {
+			amount = n
+		} else {
+			return time.Time{}, false
+		}
+		unit = strings.TrimSuffix(words[1], "s")
+	default:
+		return time.Time{}, false
+	}
+	if amount <= 0 {
+		return time.Time{}, false
+	}
+
+	switch unit {
+	case "m", "min", "minute":
+		return now.Add(time.Duration(amount) * time.Minute), true
+	case "h", "hr", "hour":
+		return now.Add(time.Duration(amount) * time.Hour), true
+	case "d", "day":
+		return now.AddDate(0, 0, amount), true
+	case "w", "week":
+		return now.AddDate(0, 0, 7*amount), true
+	case "month":
+		return now.AddDate(0, amount, 0), true
+	}
+	return time.Time{}, false
+}
+
+// parseEndOf handles "end of day/today/week/month/year" and "eod"/"eow"/"eom"/"eoy".
+// Weeks end on Sunday.
+func parseEndOf(words []string, now time.Time) (time.Time, bool) {
+	var period string
+	switch {
+	case len(words) == 1 && len(words[0]) == 3 && strings.HasPrefix(words[0], "eo"):
+		period = map[byte]string{'d': "day", 'w': "week", 'm': "month", 'y': "year"}[words[0][2]]
+	case len(words) == 3 && words[0] == "end" && words[1] == "of":
+		period = words[2]
+	case len(words) == 4 && words[0] == "end" && words[1] == "of" && (words[2] == "the" || words[2] == "this"):
+		period = words[3]
+	}
+
+	endOfDay := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 0, 0, t.Location())
+	}
+	switch period {
+	case "day", "today":
+		return endOfDay(now), true
+	case "week":
+		return endOfDay(now.AddDate(0, 0, (7-int(now.Weekday()))%7)), true
+	case "month":
+		firstOfNext := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+		return endOfDay(firstOfNext.AddDate(0, 0, -1)), true
+	case "year":
+		return time.Date(now.Year(), time.December, 31, 23, 59, 0, 0, now.Location()), true
+	}
+	return time.Time{}, false
+}
+
+// parseClock reads "18:00", "6pm", "6:30pm", "noon" or "midnight"; a bare number is not a time
+func parseClock(word string) (int, int, bool) {
+	if word == "noon" {
+		return 12, 0, true
+	}
+	if word == "midnight" {
+		return 23, 59, true
+	}
+	m := clockPattern.FindStringSubmatch(word)
+	if m == nil || (m[2] == "" && m[3] == "") {
+		return 0, 0, false
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	switch m[3] {
+	case "am":
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour %= 12
+	case "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour = hour%12 + 12
+	}
+	if hour > 23 || minute > 59 {
+		return 0, 0, false
+	}
+	return hour, minute, true
+}
+
+// parseDate reads "2026-11-01", "nov 1", "1 nov" and either with a trailing year.
+// Without a year the next such date is meant.
+func parseDate(words []string, now time.Time, loc *time.Location) (time.Time, bool) {
+	if len(words) == 1 && isoDatePattern.MatchString(words[0]) {
+		t, err := time.ParseInLocation("2006-01-02", words[0], loc)
+		return t, err == nil
+	}
+	if len(words) < 2 || len(words) > 3 {
+		return time.Time{}, false
+	}
+
+	month, monthOK := months[words[0]]
+	dayWord := words[1]
+	if !monthOK {
+		month, monthOK = months[words[1]]
+		dayWord = words[0]
+	}
+	m := dayOfMonthRegex.FindStringSubmatch(dayWord)
+	if !monthOK || m == nil {
+		return time.Time{}, false
+	}
+	day, _ := strconv.Atoi(m[1])
+
+	year := now.Year()
+	explicitYear := len(words) == 3
+	if explicitYear {
+		y, err := strconv.Atoi(words[2])
+		if err != nil || y < now.Year() {
+			return time.Time{}, false
+		}
+		year = y
+	}
+
+	t := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	if t.Month() != month || t.Day() != day {
+		return time.Time{}, false
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if !explicitYear && t.Before(today) {
+		t = t.AddDate(1, 0, 0)
+	}
+	return t, true
+}
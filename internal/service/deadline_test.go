@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseDeadline(t *testing.T) {
+	berlin, err := LoadTimezone("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("LoadTimezone failed: %v", err)
+	}
+	// Wednesday morning
+	now := time.Date(2026, time.October, 14, 10, 0, 0, 0, berlin)
+	on := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, berlin)
+	}
+
+	tests := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"48h", now.Add(48 * time.Hour)},
+		{"in 3 days", on(time.October, 17, 10, 0)},
+		{"in an hour", now.Add(time.Hour)},
+		{"2w", on(time.October, 28, 10, 0)},
+		{"friday 18:00", on(time.October, 16, 18, 0)},
+		{"Fri at 6 pm", on(time.October, 16, 18, 0)},
+		{"wednesday 9am", on(time.October, 21, 9, 0)},
+		{"wednesday 18:00", on(time.October, 14, 18, 0)},
+		{"next wednesday", on(time.October, 21, 23, 59)},
+		{"tomorrow", on(time.October, 15, 23, 59)},
+		{"today 18:30", on(time.October, 14, 18, 30)},
+		{"9:30", on(time.October, 15, 9, 30)},
+		{"end of month", on(time.October, 31, 23, 59)},
+		{"end of the week", on(time.October, 18, 23, 59)},
+		{"eoy", on(time.December, 31, 23, 59)},
+		{"nov 30", on(time.November, 30, 23, 59)},
+		{"1st november 12pm", on(time.November, 1, 12, 0)},
+		{"2026-11-01 18:00", on(time.November, 1, 18, 0)},
+		{"oct 1", time.Date(2027, time.October, 1, 23, 59, 0, 0, berlin)},
+		{"2026-12-24T18:00:00Z", time.Date(2026, time.December, 24, 18, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseDeadline(tt.input, now, berlin)
+		if err != nil {
+			t.Errorf("ParseDeadline(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if !got.Equal(tt.expected) {
+			t.Errorf("ParseDeadline(%q) = %v, want %v", tt.input, got, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"", "soon", "next tomorrow", "13pm", "feb 30", "in -2 days", "18"} {
+		if got, err := ParseDeadline(input, now, berlin); err == nil {
+			t.Errorf("ParseDeadline(%q) = %v, expected an error", input, got)
+		}
+	}
+	for _, input := range []string{"today 9:00", "2026-10-01", "-1h"} {
+		if _, err := ParseDeadline(input, now, berlin); !errors.Is(err, ErrDeadlinePast) {
+			t.Errorf("ParseDeadline(%q) = %v, expected ErrDeadlinePast", input, err)
+		}
+	}
+}
+
+func TestSplitDeadline(t *testing.T) {
+	now := time.Date(2026, time.October, 14, 10, 0, 0, 0, time.UTC)
+
+	deadline, rest, err := SplitDeadline("next fri at 6 pm Will it rain? | YES if it rains", now, time.UTC)
+	if err != nil {
+		t.Fatalf("SplitDeadline failed: %v", err)
+	}
+	if !deadline.Equal(time.Date(2026, time.October, 16, 18, 0, 0, 0, time.UTC)) || rest != "Will it rain? | YES if it rains" {
+		t.Errorf("Unexpected split: %v %q", deadline, rest)
+	}
+
+	if _, rest, err := SplitDeadline("72h Will the vote pass?", now, time.UTC); err != nil || rest != "Will the vote pass?" {
+		t.Errorf("Expected durations to keep working, got %q (err=%v)", rest, err)
+	}
+	if _, _, err := SplitDeadline("today 9:00 Will it be sunny?", now, time.UTC); !errors.Is(err, ErrDeadlinePast) {
+		t.Errorf("Expected ErrDeadlinePast instead of a shorter match, got %v", err)
+	}
+	if _, _, err := SplitDeadline("Will it snow?", now, time.UTC); err == nil {
+		t.Error("Expected an error without a deadline")
+	}
+}
+
+func TestLoadTimezone(t *testing.T) {
+	if loc, err := LoadTimezone(""); err != nil || loc != time.UTC {
+		t.Errorf("Expected UTC for an empty name, got %v (err=%v)", loc, err)
+	}
+	for _, name := range []string{"Mars/Olympus", "Local"} {
+		if _, err := LoadTimezone(name); err == nil {
+			t.Errorf("Expected an error for %q", name)
+		}
+	}
+}
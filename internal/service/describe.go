@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// describeMaxField caps the description and criteria lengths
+	describeMaxField = 400
+	// DefaultDescribeModel is used when MARKET_DESCRIBE_MODEL is not set
+	DefaultDescribeModel = "gpt-4o-mini"
+)
+
+// describePrompt asks for a neutral summary and resolution criteria as JSON
+const describePrompt = `You write descriptions for a prediction market. Given the market question, reply with a JSON object with two string fields:
+"description": one or two neutral sentences of context, without predicting the outcome;
+"resolution_criteria": one sentence saying exactly when the market resolves YES, naming the kind of source to check.
+Reply with the JSON object only.`
+
+// MarketDescription is the generated context for a new market
+type MarketDescription struct {
+	Description        string `json:"description"`
+	ResolutionCriteria string `json:"resolution_criteria"`
+}
+
+// Text is the description as stored and shown to users
+func (d *MarketDescription) Text() string {
+	if d == nil {
+		return ""
+	}
+	if d.ResolutionCriteria == "" {
+		return d.Description
+	}
+	if d.Description == "" {
+		return "Suggested resolution criteria: " + d.ResolutionCriteria
+	}
+	return d.Description + "\n\nSuggested resolution criteria: " + d.ResolutionCriteria
+}
+
+// DescriptionService generates market descriptions through an OpenAI-compatible chat completions API.
+// Without MARKET_DESCRIBE_API_URL it is disabled and never makes a request.
+type DescriptionService struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string
+	model    string
+}
+
+var (
+	globalDescriptionService *DescriptionService
+	descriptionServiceOnce   sync.Once
+)
+
+// GetDescriptionService returns the shared description service configured from
+// MARKET_DESCRIBE_API_URL, MARKET_DESCRIBE_API_KEY and MARKET_DESCRIBE_MODEL
+func GetDescriptionService() *DescriptionService {
+	descriptionServiceOnce.Do(func() {
+		globalDescriptionService = NewDescriptionService(
+			strings.TrimSpace(os.Getenv("MARKET_DESCRIBE_API_URL")),
+			strings.TrimSpace(os.Getenv("MARKET_DESCRIBE_API_KEY")),
+			strings.TrimSpace(os.Getenv("MARKET_DESCRIBE_MODEL")),
+		)
+	})
+	return globalDescriptionService
+}
+
+// NewDescriptionService creates a description service for the given chat completions endpoint.
+// An empty endpoint disables it; an empty model uses DefaultDescribeModel.
+func NewDescriptionService(endpoint, apiKey, model string) *DescriptionService {
+	if model == "" {
+		model = DefaultDescribeModel
+	}
+	return &DescriptionService{
+		client:   &http.Client{Timeout: llmTimeout},
+		endpoint: endpoint,
+		apiKey:   apiKey,
+		model:    model,
+	}
+}
+
+// Enabled reports whether descriptions are generated at all
+func (s *DescriptionService) Enabled() bool {
+	return s != nil && s.endpoint != ""
+}
+
+// Describe generates a description for a market question. It returns nil, nil when disabled.
+func (s *DescriptionService) Describe(ctx context.Context, question string) (*MarketDescription, error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+
+	content, err := chatCompletion(ctx, s.client, s.endpoint, s.apiKey, s.model, describePrompt, question, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Some models wrap JSON in a code fence despite being asked not to
+	content = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(content, "```json"), "```"), "```")
+
+	var description MarketDescription
+	if err := json.Unmarshal([]byte(content), &description); err != nil {
+		return nil, fmt.Errorf("failed to decode description: %w", err)
+	}
+	description.Description = truncateString(SanitizeText(description.Description), describeMaxField)
+	description.ResolutionCriteria = truncateString(SanitizeText(description.ResolutionCriteria), describeMaxField)
+	if description.Description == "" && description.ResolutionCriteria == "" {
+		return nil, fmt.Errorf("empty description")
+	}
+	return &description, nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+// completionServer answers chat completion requests with content
+func completionServer(t *testing.T, content string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("Expected bearer token, got %q", r.Header.Get("Authorization"))
+		}
+		var req struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != DefaultDescribeModel || len(req.Messages) != 2 {
+			t.Errorf("Unexpected request %+v", req)
+		}
+		body, _ := json.Marshal(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": content}}},
+		})
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, string(body))
+	}))
+}
+
+func TestDescribe(t *testing.T) {
+	server := completionServer(t, "```json\n{\"description\":\"The final is on Sunday.\",\"resolution_criteria\":\"Resolves YES if the official league site lists them as champions.\"}\n```")
+	defer server.Close()
+
+	s := NewDescriptionService(server.URL, "test-key", "")
+	description, err := s.Describe(context.Background(), "Will the home team win the final?")
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+	want := "The final is on Sunday.\n\nSuggested resolution criteria: Resolves YES if the official league site lists them as champions."
+	if description.Text() != want {
+		t.Errorf("Unexpected text %q", description.Text())
+	}
+
+	if d, err := NewDescriptionService("", "", "").Describe(context.Background(), "Anything?"); d != nil || err != nil {
+		t.Errorf("Expected disabled service to do nothing, got %+v (err=%v)", d, err)
+	}
+}
+
+func TestDescribeRejectsBadContent(t *testing.T) {
+	server := completionServer(t, "I think the home team will win.")
+	defer server.Close()
+
+	if _, err := NewDescriptionService(server.URL, "test-key", "").Describe(context.Background(), "Will they win?"); err == nil {
+		t.Error("Expected error for a non-JSON answer")
+	}
+}
+
+func TestCreateMarketStoresDescription(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	server := completionServer(t, `{"description":"Neutral context.","resolution_criteria":""}`)
+	defer server.Close()
+
+	creator, _ := storage.CreateUser(5001, "creator", "Creator")
+	s := &MarketService{
+		durations: MarketDurations{Min: time.Hour},
+		describer: NewDescriptionService(server.URL, "test-key", ""),
+	}
+	market, err := s.CreateMarket(context.Background(), creator, "Will the description be stored?", time.Now().Add(2*time.Hour), "", storage.MarketOptions{})
+	if err != nil {
+		t.Fatalf("CreateMarket failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		description, _ := storage.GetMarketDescription(market.ID)
+		if description == "Neutral context." {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected stored description, got %q", description)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
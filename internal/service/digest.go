@@ -0,0 +1,175 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// digestSectionLimit caps the lines of each digest section
+const digestSectionLimit = 5
+
+// Digest is a user's daily summary: what closes today, where their stakes are, which of
+// their markets wait for a resolution and how they moved on the leaderboard
+type Digest struct {
+	// Date is the user's local time the digest was built at
+	Date               time.Time
+	ClosingToday       []storage.Market
+	Positions          []storage.ActiveBetItem
+	AwaitingResolution []storage.MarketCreatorInfo
+	Rank               int
+	// PreviousRank is the rank shown in the previous digest, 0 for the first one
+	PreviousRank int
+}
+
+// RankChange is how many places the user climbed since the previous digest (negative if they fell)
+func (d *Digest) RankChange() int {
+	if d.PreviousRank == 0 {
+		return 0
+	}
+	return d.PreviousRank - d.Rank
+}
+
+// BuildDigest assembles a user's digest for their local day at now and remembers their rank,
+// so the next digest can show how it changed
+func BuildDigest(user *storage.User, now time.Time) (*Digest, error) {
+	if user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	local := now.In(UserLocation(user.ID))
+	endOfDay := time.Date(local.Year(), local.Month(), local.Day(), 23, 59, 59, 0, local.Location())
+	digest := &Digest{Date: local}
+
+	markets, err := storage.ListActiveMarkets()
+	if err != nil {
+		return nil, err
+	}
+	for _, market := range markets {
+		if !market.ExpiresAt.Before(now) && !market.ExpiresAt.After(endOfDay) {
+			digest.ClosingToday = append(digest.ClosingToday, market)
+		}
+	}
+
+	if digest.Positions, err = storage.GetUserActiveBets(user.ID); err != nil {
+		return nil, err
+	}
+
+	created, err := storage.GetMarketsByCreator(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, market := range created {
+		if market.Status == string(storage.MarketStatusLocked) {
+			digest.AwaitingResolution = append(digest.AwaitingResolution, market)
+		}
+	}
+
+	if digest.PreviousRank, err = storage.GetDigestRank(user.ID); err != nil {
+		return nil, err
+	}
+	if digest.Rank, err = storage.GetUserRank(user.ID); err != nil {
+		return nil, err
+	}
+	if err := storage.RecordDigestRank(user.ID, digest.Rank); err != nil {
+		return nil, err
+	}
+
+	return digest, nil
+}
+
+// Text formats the digest as a plain-text message
+func (d *Digest) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📰 Your digest for %s\n", d.Date.Format("Mon, Jan 2"))
+
+	b.WriteString("\n⏰ Closing today\n")
+	if len(d.ClosingToday) == 0 {
+		b.WriteString("Nothing closes today.\n")
+	}
+	for i, market := range d.ClosingToday {
+		if i == digestSectionLimit {
+			fmt.Fprintf(&b, "...and %d more\n", len(d.ClosingToday)-i)
+			break
+		}
+		fmt.Fprintf(&b, "• #%d %s at %s\n", market.ID, withIcon(market.Icon, truncateString(market.Question, 50)), market.ExpiresAt.In(d.Date.Location()).Format("15:04"))
+	}
+
+	if len(d.Positions) > 0 {
+		b.WriteString("\n🎯 Your open positions\n")
+		for i, bet := range d.Positions {
+			if i == digestSectionLimit {
+				fmt.Fprintf(&b, "...and %d more (/mybets)\n", len(d.Positions)-i)
+				break
+			}
+			fmt.Fprintf(&b, "• #%d %s: %s %s\n", bet.MarketID, withIcon(bet.Icon, truncateString(bet.Question, 50)), bet.OutcomeChosen, formatBalance(bet.Amount))
+		}
+	}
+
+	if len(d.AwaitingResolution) > 0 {
+		b.WriteString("\n⚖️ Waiting for you to /resolve\n")
+		for i, market := range d.AwaitingResolution {
+			if i == digestSectionLimit {
+				fmt.Fprintf(&b, "...and %d more\n", len(d.AwaitingResolution)-i)
+				break
+			}
+			fmt.Fprintf(&b, "• #%d %s\n", market.ID, withIcon(market.Icon, truncateString(market.Question, 50)))
+		}
+	}
+
+	fmt.Fprintf(&b, "\n🏆 Leaderboard: #%d", d.Rank)
+	switch change := d.RankChange(); {
+	case change > 0:
+		fmt.Fprintf(&b, " (▲%d since your last digest)", change)
+	case change < 0:
+		fmt.Fprintf(&b, " (▼%d since your last digest)", -change)
+	case d.PreviousRank > 0:
+		b.WriteString(" (unchanged)")
+	}
+	return b.String()
+}
+
+// SendDueDigests emits the digest of every user whose digest hour has come in their timezone
+// and who has not had today's digest yet. It returns how many were sent.
+func SendDueDigests(notifier Notifier, now time.Time) int {
+	subscribers, err := storage.ListDigestSubscribers()
+	if err != nil {
+		logger.Debug(0, "digest_query_failed", fmt.Sprintf("error=%s", err.Error()))
+		return 0
+	}
+
+	sent := 0
+	for _, sub := range subscribers {
+		loc, err := LoadTimezone(sub.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		local := now.In(loc)
+		today := local.Format("2006-01-02")
+		if local.Hour() < sub.Hour || sub.SentOn == today {
+			continue
+		}
+
+		// Mark it first so a failing user is not retried every minute
+		if err := storage.MarkDigestSent(sub.UserID, today); err != nil {
+			logger.Debug(0, "digest_failed", fmt.Sprintf("user_id=%d error=%s", sub.UserID, err.Error()))
+			continue
+		}
+		user, err := storage.GetUserByID(sub.UserID)
+		if err != nil || user == nil {
+			continue
+		}
+		digest, err := BuildDigest(user, now)
+		if err != nil {
+			logger.Debug(user.TelegramID, "digest_failed", fmt.Sprintf("error=%s", err.Error()))
+			continue
+		}
+
+		notifier.Emit(DailyDigest{UserID: user.ID, Digest: digest})
+		sent++
+	}
+	return sent
+}
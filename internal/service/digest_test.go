@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestBuildDigest(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+	now := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 12, 0, 0, 0, time.UTC)
+
+	creator, _ := storage.CreateUser(7201, "creator", "Creator")
+	bettor, _ := storage.CreateUser(7202, "bettor", "Bettor")
+
+	closing, _ := storage.CreateMarket(creator.ID, "Will this close today?", now.Add(2*time.Hour))
+	later, _ := storage.CreateMarket(creator.ID, "Will this close tomorrow?", now.Add(30*time.Hour))
+	locked, _ := storage.CreateMarket(creator.ID, "Is this waiting for its creator?", now.Add(time.Hour))
+	_ = storage.PlaceBet(ctx, bettor.ID, later.ID, "NO", 50)
+	storage.DB().Exec(`UPDATE markets SET status = 'LOCKED' WHERE id = ?`, locked.ID)
+	storage.DB().Exec(`UPDATE users SET balance = 10 WHERE id = ?`, creator.ID)
+
+	digest, err := BuildDigest(bettor, now)
+	if err != nil {
+		t.Fatalf("BuildDigest failed: %v", err)
+	}
+	if len(digest.ClosingToday) != 1 || digest.ClosingToday[0].ID != closing.ID {
+		t.Errorf("Expected only market %d to close today, got %+v", closing.ID, digest.ClosingToday)
+	}
+	if len(digest.Positions) != 1 || digest.Positions[0].MarketID != later.ID {
+		t.Errorf("Expected the bet on market %d, got %+v", later.ID, digest.Positions)
+	}
+	if len(digest.AwaitingResolution) != 0 {
+		t.Errorf("The bettor has nothing to resolve, got %+v", digest.AwaitingResolution)
+	}
+	if digest.Rank != 1 || digest.PreviousRank != 0 || digest.RankChange() != 0 {
+		t.Errorf("Expected first digest at rank 1, got rank %d previous %d", digest.Rank, digest.PreviousRank)
+	}
+
+	digest, err = BuildDigest(creator, now)
+	if err != nil {
+		t.Fatalf("BuildDigest failed: %v", err)
+	}
+	if len(digest.AwaitingResolution) != 1 || digest.AwaitingResolution[0].ID != locked.ID {
+		t.Errorf("Expected market %d to wait for its creator, got %+v", locked.ID, digest.AwaitingResolution)
+	}
+	if !strings.Contains(digest.Text(), "/resolve") {
+		t.Errorf("Expected the digest to point to /resolve, got %q", digest.Text())
+	}
+
+	// The creator overtakes the bettor before the next digest
+	storage.DB().Exec(`UPDATE users SET balance = 5000 WHERE id = ?`, creator.ID)
+	digest, _ = BuildDigest(bettor, now)
+	if digest.PreviousRank != 1 || digest.Rank != 2 || digest.RankChange() != -1 {
+		t.Errorf("Expected the bettor to drop from 1 to 2, got %d to %d", digest.PreviousRank, digest.Rank)
+	}
+	if !strings.Contains(digest.Text(), "▼1") {
+		t.Errorf("Expected the drop in the text, got %q", digest.Text())
+	}
+}
+
+func TestSendDueDigests(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := storage.CreateUser(7301, "reader", "Reader")
+	_, _ = storage.CreateUser(7302, "quiet", "Quiet")
+	_ = storage.SetUserTimezone(user.ID, "Asia/Tokyo")
+	if err := storage.SetDigestHour(user.ID, 8); err != nil {
+		t.Fatalf("SetDigestHour failed: %v", err)
+	}
+
+	recorder := NewRecordingNotifier()
+	// 07:30 in Tokyo
+	if sent := SendDueDigests(recorder, time.Date(2026, 3, 2, 22, 30, 0, 0, time.UTC)); sent != 0 {
+		t.Errorf("Expected no digest before 8:00 Tokyo time, sent %d", sent)
+	}
+	// 08:05 in Tokyo
+	if sent := SendDueDigests(recorder, time.Date(2026, 3, 2, 23, 5, 0, 0, time.UTC)); sent != 1 {
+		t.Fatalf("Expected one digest at 8:05 Tokyo time, sent %d", sent)
+	}
+	if sent := SendDueDigests(recorder, time.Date(2026, 3, 2, 23, 6, 0, 0, time.UTC)); sent != 0 {
+		t.Errorf("Expected the digest once a day, sent %d more", sent)
+	}
+
+	events := recorder.Events()
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+	if e, ok := events[0].(DailyDigest); !ok || e.UserID != user.ID || e.Digest == nil {
+		t.Errorf("Expected a digest for user %d, got %+v", user.ID, events[0])
+	}
+}
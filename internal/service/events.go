@@ -0,0 +1,403 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// NotificationEvent is a typed notification emitted by services.
+// Each message type has its own struct so emitters and the NotificationService
+// cannot drift apart on positional argument lists.
+type NotificationEvent interface {
+	// Kind returns a stable name for the event, used for logging and tests
+	Kind() string
+}
+
+// DeadlineReached tells a market creator their market expired and was locked
+type DeadlineReached struct {
+	Market *storage.Market
+}
+
+// ColdStartNudge tells a market creator nobody has bet on their market after Waited, with ways
+// to share it
+type ColdStartNudge struct {
+	Market *storage.Market
+	Waited time.Duration
+}
+
+// ColdStartGroupNudge reminds the group chat a market was created in that it has no bets yet
+type ColdStartGroupNudge struct {
+	Market *storage.Market
+}
+
+// MarketLocked sends every bettor on a just-locked market a summary of their position
+type MarketLocked struct {
+	Market *storage.Market
+}
+
+// ResolutionPublished announces a creator's resolution on the public channel
+type ResolutionPublished struct {
+	MarketID  int64
+	Question  string
+	Outcome   string
+	TotalPool int64
+}
+
+// DisputePublished announces a dispute on the public channel, with the disputer's reason and
+// evidence but not who they are
+type DisputePublished struct {
+	MarketID    int64
+	Question    string
+	Outcome     string
+	Reason      string
+	EvidenceURL string
+}
+
+// DisputeAlert tells the admin a dispute was raised. DisputedBy is the internal user ID.
+type DisputeAlert struct {
+	MarketID     int64
+	Question     string
+	DisputedBy   int64
+	DisputerName string
+	Reason       string
+	EvidenceURL  string
+}
+
+// DisputeCreatorNotice tells the market creator their resolution was disputed
+type DisputeCreatorNotice struct {
+	Market  *storage.Market
+	Outcome string
+}
+
+// FinalizationPublished announces payouts on the public channel
+type FinalizationPublished struct {
+	MarketID     int64
+	Question     string
+	Outcome      string
+	WinnersCount int
+	TotalPayout  int64
+	WasDisputed  bool
+	// Fee is what the house took from the pool, 0 without RAKE_BPS
+	Fee int64
+	// TopWinners are the biggest winners, named only if they opted in
+	TopWinners []storage.MarketWinner
+}
+
+// UpsetPublished announces a notable outcome on the public channel. Winners stay anonymous.
+type UpsetPublished struct {
+	MarketID     int64
+	Question     string
+	Outcome      string
+	Reason       UpsetReason
+	Multiplier   float64
+	WinnersCount int
+	BettorsCount int
+}
+
+// WhaleAlert announces a big bet on the public channel. The bettor stays anonymous.
+// YesBefore and YesAfter are the implied YES probabilities in percent.
+type WhaleAlert struct {
+	MarketID  int64
+	Question  string
+	Outcome   string
+	Amount    int64
+	YesBefore float64
+	YesAfter  float64
+	TotalPool int64
+}
+
+// LastCall announces on the public channel that a market is about to lock.
+// Pools are the public pools, frozen until the market locks.
+type LastCall struct {
+	Market *storage.Market
+	Pools  storage.PublicPools
+}
+
+// CosignRequested asks the admins and oracles to co-sign the resolution of a big market
+type CosignRequested struct {
+	Cosign    *storage.ResolutionCosign
+	Question  string
+	TotalPool int64
+}
+
+// CosignDecided tells the market creator whether their resolution was co-signed
+type CosignDecided struct {
+	Cosign *storage.ResolutionCosign
+	Market *storage.Market
+}
+
+// ProposalOpened asks a market's bettors to vote on an outcome proposed in the creator's absence
+type ProposalOpened struct {
+	Proposal *storage.CommunityProposal
+	Question string
+}
+
+// ProposalEscalated tells the admins and oracles that bettors could not settle a proposed outcome
+type ProposalEscalated struct {
+	Proposal *storage.CommunityProposal
+	Question string
+}
+
+// PayoutsEscrowed tells the admins that finalizing a market held payouts for missing accounts
+type PayoutsEscrowed struct {
+	MarketID int64
+	Question string
+	Payouts  []storage.EscrowedPayout
+}
+
+// BetReceipt confirms a bet to the bettor (internal user ID). Pools are the ones shown to users
+// after the bet, so blind and sealed markets keep their secrets.
+type BetReceipt struct {
+	UserID     int64
+	MarketID   int64
+	Question   string
+	Icon       string
+	Outcome    string
+	Amount     int64
+	NewBalance int64
+	Pools      storage.PublicPools
+}
+
+// WinNotice tells a bettor (internal user ID) they won
+type WinNotice struct {
+	UserID     int64
+	MarketID   int64
+	Question   string
+	BetAmount  int64
+	Outcome    string
+	Payout     int64
+	NewBalance int64
+}
+
+// RefundNotice tells a bettor (internal user ID) their stake was returned
+type RefundNotice struct {
+	UserID     int64
+	MarketID   int64
+	Question   string
+	Amount     int64
+	NewBalance int64
+}
+
+// CreatorRewardNotice tells a market creator (internal user ID) they were paid Amount, their
+// share of the market's TotalPool
+type CreatorRewardNotice struct {
+	UserID     int64
+	MarketID   int64
+	Question   string
+	Amount     int64
+	TotalPool  int64
+	NewBalance int64
+}
+
+// LossNotice tells a bettor (internal user ID) their bet did not win
+type LossNotice struct {
+	UserID   int64
+	MarketID int64
+	Question string
+	Amount   int64
+	// Refunded is what a first-bet insurance voucher paid back of the stake
+	Refunded int64
+}
+
+// StreakNotice tells a bettor (internal user ID) their win streak hit a milestone (Streak)
+// or that a win streak of Ended markets just ended
+type StreakNotice struct {
+	UserID   int64
+	MarketID int64
+	Question string
+	Streak   int
+	Ended    int
+}
+
+// OddsSwing tells a bettor (internal user ID) the implied YES probability (in percent) of a
+// market they bet on moved from YesBefore to YesAfter. Yes and No are their stakes.
+type OddsSwing struct {
+	UserID    int64
+	MarketID  int64
+	Question  string
+	Yes       int64
+	No        int64
+	YesBefore float64
+	YesAfter  float64
+}
+
+// DailyDigest sends a user (internal user ID) the daily digest they asked for
+type DailyDigest struct {
+	UserID int64
+	Digest *Digest
+}
+
+// GroupDigestPosted posts the morning digest to a group chat that asked for it
+type GroupDigestPosted struct {
+	ChatID int64
+	Digest *GroupDigest
+}
+
+// AccountMergeCode sends one side of an account merge (internal user ID) its confirmation code.
+// OldAccount marks the account being merged away.
+type AccountMergeCode struct {
+	UserID     int64
+	MergeID    int64
+	Code       string
+	OldAccount bool
+}
+
+// WorkerFailed reports a failed market worker task (MarketID is 0 when it is not about one market).
+// It is only delivered to admin alert integrations such as Slack; the worker logs it itself.
+type WorkerFailed struct {
+	Task     string
+	MarketID int64
+	Error    string
+}
+
+// FinalizationStuck tells the admin the worker gave up auto-finalizing a market after Failures
+// attempts and moved it to NEEDS_ATTENTION. Error is the last failure.
+type FinalizationStuck struct {
+	MarketID int64
+	Question string
+	Failures int
+	Error    string
+}
+
+func (DeadlineReached) Kind() string       { return "deadline_reached" }
+func (ColdStartNudge) Kind() string        { return "cold_start_nudge" }
+func (ColdStartGroupNudge) Kind() string   { return "cold_start_group_nudge" }
+func (MarketLocked) Kind() string          { return "market_locked" }
+func (ResolutionPublished) Kind() string   { return "resolution_published" }
+func (DisputePublished) Kind() string      { return "dispute_published" }
+func (DisputeAlert) Kind() string          { return "dispute_alert" }
+func (DisputeCreatorNotice) Kind() string  { return "dispute_creator_notice" }
+func (FinalizationPublished) Kind() string { return "finalization_published" }
+func (UpsetPublished) Kind() string        { return "upset_published" }
+func (WhaleAlert) Kind() string            { return "whale_alert" }
+func (LastCall) Kind() string              { return "last_call" }
+func (CosignRequested) Kind() string       { return "cosign_requested" }
+func (CosignDecided) Kind() string         { return "cosign_decided" }
+func (ProposalOpened) Kind() string        { return "proposal_opened" }
+func (ProposalEscalated) Kind() string     { return "proposal_escalated" }
+func (PayoutsEscrowed) Kind() string       { return "payouts_escrowed" }
+func (BetReceipt) Kind() string            { return "bet_receipt" }
+func (WinNotice) Kind() string             { return "win_notice" }
+func (RefundNotice) Kind() string          { return "refund_notice" }
+func (CreatorRewardNotice) Kind() string   { return "creator_reward_notice" }
+func (LossNotice) Kind() string            { return "loss_notice" }
+func (StreakNotice) Kind() string          { return "streak_notice" }
+func (OddsSwing) Kind() string             { return "odds_swing" }
+func (DailyDigest) Kind() string           { return "daily_digest" }
+func (GroupDigestPosted) Kind() string     { return "group_digest_posted" }
+func (AccountMergeCode) Kind() string      { return "account_merge_code" }
+func (WorkerFailed) Kind() string          { return "worker_failed" }
+func (FinalizationStuck) Kind() string     { return "finalization_stuck" }
+
+// groupMarketPost reports whether an event is a public channel post about a market scoped to a
+// group chat. Like their announcement, those posts stay out of the channel.
+func groupMarketPost(event NotificationEvent) bool {
+	var marketID int64
+	switch e := event.(type) {
+	case ResolutionPublished:
+		marketID = e.MarketID
+	case DisputePublished:
+		marketID = e.MarketID
+	case FinalizationPublished:
+		marketID = e.MarketID
+	case UpsetPublished:
+		marketID = e.MarketID
+	case WhaleAlert:
+		marketID = e.MarketID
+	case LastCall:
+		return e.Market != nil && e.Market.GroupID != 0
+	default:
+		return false
+	}
+	market, err := storage.GetMarketByID(marketID)
+	return err == nil && market != nil && market.GroupID != 0
+}
+
+// Emit delivers an event through the matching Telegram message, unless it is a DM its recipient
+// muted or a channel post about a group market. Questions are translated first: channel posts
+// into CHANNEL_LANGUAGE, DMs into the user's language.
+func (s *NotificationService) Emit(event NotificationEvent) {
+	if isMutedDM(event) {
+		return
+	}
+	if groupMarketPost(event) {
+		logger.Debug(0, "broadcast_skipped", fmt.Sprintf("kind=%s reason=group_market", event.Kind()))
+		return
+	}
+	switch e := event.(type) {
+	case DeadlineReached:
+		s.NotifyMarketCreatorDeadline(e.Market)
+	case ColdStartNudge:
+		s.SendColdStartNudge(e.Market, e.Waited)
+	case ColdStartGroupNudge:
+		s.PublishColdStartGroupNudge(e.Market)
+	case MarketLocked:
+		s.NotifyBettorsMarketLocked(e.Market)
+	case ResolutionPublished:
+		s.PublishResolution(e.MarketID, s.channelQuestion(e.MarketID, e.Question), e.Outcome, e.TotalPool)
+	case DisputePublished:
+		s.PublishDispute(e.MarketID, s.channelQuestion(e.MarketID, e.Question), e.Outcome, e.Reason, e.EvidenceURL)
+	case DisputeAlert:
+		s.SendDisputeAlert(e)
+	case DisputeCreatorNotice:
+		s.NotifyDisputeToCreator(e.Market, e.Outcome)
+	case FinalizationPublished:
+		s.PublishFinalization(e.MarketID, s.channelQuestion(e.MarketID, e.Question), e.Outcome, e.WinnersCount, e.TotalPayout, e.Fee, e.WasDisputed, e.TopWinners)
+	case UpsetPublished:
+		s.PublishUpset(e.MarketID, s.channelQuestion(e.MarketID, e.Question), e.Outcome, e.Reason, e.Multiplier, e.WinnersCount, e.BettorsCount)
+	case WhaleAlert:
+		s.PublishWhaleAlert(e.MarketID, s.channelQuestion(e.MarketID, e.Question), e.Outcome, e.Amount, e.YesBefore, e.YesAfter, e.TotalPool)
+	case LastCall:
+		s.PublishLastCall(e.Market.ID, s.channelQuestion(e.Market.ID, e.Market.Question), e.Market.ExpiresAt, e.Pools)
+	case CosignRequested:
+		s.SendCosignRequest(e.Cosign, e.Question, e.TotalPool)
+	case CosignDecided:
+		s.SendCosignDecision(e.Cosign, e.Market)
+	case ProposalOpened:
+		s.SendProposalVoteRequest(e.Proposal, e.Question)
+	case ProposalEscalated:
+		s.SendProposalEscalation(e.Proposal, e.Question)
+	case PayoutsEscrowed:
+		s.SendEscrowReport(e.MarketID, e.Question, e.Payouts)
+	case BetReceipt:
+		e.Question = userQuestion(e.UserID, e.MarketID, e.Question)
+		s.SendBetReceipt(e)
+	case WinNotice:
+		s.SendWinNotification(e.UserID, e.MarketID, userQuestion(e.UserID, e.MarketID, e.Question), e.BetAmount, e.Outcome, e.Payout, e.NewBalance)
+	case RefundNotice:
+		s.SendRefundNotification(e.UserID, e.MarketID, userQuestion(e.UserID, e.MarketID, e.Question), e.Amount, e.NewBalance)
+	case CreatorRewardNotice:
+		s.SendCreatorRewardNotification(e.UserID, e.MarketID, userQuestion(e.UserID, e.MarketID, e.Question), e.Amount, e.TotalPool, e.NewBalance)
+	case LossNotice:
+		s.SendLossNotification(e.UserID, e.MarketID, userQuestion(e.UserID, e.MarketID, e.Question), e.Amount, e.Refunded)
+	case StreakNotice:
+		s.SendStreakNotification(e.UserID, e.MarketID, userQuestion(e.UserID, e.MarketID, e.Question), e.Streak, e.Ended)
+	case OddsSwing:
+		e.Question = userQuestion(e.UserID, e.MarketID, e.Question)
+		s.SendOddsSwing(e)
+	case DailyDigest:
+		s.SendDigest(e.UserID, e.Digest)
+	case GroupDigestPosted:
+		s.PublishGroupDigest(e.ChatID, e.Digest)
+	case AccountMergeCode:
+		s.SendAccountMergeCode(e)
+	case FinalizationStuck:
+		s.SendFinalizationStuckAlert(e)
+	case WorkerFailed:
+		// Admin integrations only; Telegram admins are not paged for worker errors
+	default:
+		log.Printf("Unhandled notification event %s", describeEvent(event))
+	}
+}
+
+// describeEvent formats an event for logs, tolerating nil
+func describeEvent(event NotificationEvent) string {
+	if event == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%s %+v", event.Kind(), event)
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+// Compile-time checks: every event satisfies NotificationEvent and the
+// NotificationService can receive them all through one interface.
+var (
+	_ NotificationEvent = DeadlineReached{}
+	_ NotificationEvent = ColdStartNudge{}
+	_ NotificationEvent = ColdStartGroupNudge{}
+	_ NotificationEvent = MarketLocked{}
+	_ NotificationEvent = ResolutionPublished{}
+	_ NotificationEvent = DisputePublished{}
+	_ NotificationEvent = DisputeAlert{}
+	_ NotificationEvent = DisputeCreatorNotice{}
+	_ NotificationEvent = FinalizationPublished{}
+	_ NotificationEvent = UpsetPublished{}
+	_ NotificationEvent = WhaleAlert{}
+	_ NotificationEvent = LastCall{}
+	_ NotificationEvent = CosignRequested{}
+	_ NotificationEvent = CosignDecided{}
+	_ NotificationEvent = ProposalOpened{}
+	_ NotificationEvent = ProposalEscalated{}
+	_ NotificationEvent = PayoutsEscrowed{}
+	_ NotificationEvent = BetReceipt{}
+	_ NotificationEvent = WinNotice{}
+	_ NotificationEvent = RefundNotice{}
+	_ NotificationEvent = CreatorRewardNotice{}
+	_ NotificationEvent = LossNotice{}
+	_ NotificationEvent = StreakNotice{}
+	_ NotificationEvent = OddsSwing{}
+	_ NotificationEvent = DailyDigest{}
+	_ NotificationEvent = GroupDigestPosted{}
+	_ NotificationEvent = AccountMergeCode{}
+	_ NotificationEvent = WorkerFailed{}
+	_ NotificationEvent = FinalizationStuck{}
+
+	_ Notifier = (*NotificationService)(nil)
+	_ Notifier = NoopNotifier{}
+	_ Notifier = (*RecordingNotifier)(nil)
+)
+
+func TestNotificationEventKindsUnique(t *testing.T) {
+	events := []NotificationEvent{
+		DeadlineReached{},
+		ColdStartNudge{},
+		ColdStartGroupNudge{},
+		MarketLocked{},
+		ResolutionPublished{},
+		DisputePublished{},
+		DisputeAlert{},
+		DisputeCreatorNotice{},
+		FinalizationPublished{},
+		UpsetPublished{},
+		WhaleAlert{},
+		LastCall{},
+		CosignRequested{},
+		CosignDecided{},
+		ProposalOpened{},
+		ProposalEscalated{},
+		PayoutsEscrowed{},
+		BetReceipt{},
+		WinNotice{},
+		RefundNotice{},
+		CreatorRewardNotice{},
+		LossNotice{},
+		StreakNotice{},
+		OddsSwing{},
+		DailyDigest{},
+		GroupDigestPosted{},
+		AccountMergeCode{},
+		WorkerFailed{},
+		FinalizationStuck{},
+	}
+
+	seen := make(map[string]bool)
+	for _, e := range events {
+		kind := e.Kind()
+		if kind == "" {
+			t.Errorf("Event %T has an empty kind", e)
+		}
+		if seen[kind] {
+			t.Errorf("Duplicate event kind %q", kind)
+		}
+		seen[kind] = true
+	}
+}
+
+func TestEmitSkipsUnknownRecipients(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	// No bot is configured: these must return before sending anything
+	s := &NotificationService{}
+	s.Emit(nil)
+	s.Emit(WinNotice{UserID: 9999, MarketID: 1, Question: "Unknown user?", BetAmount: 10, Outcome: "YES", Payout: 20})
+	s.Emit(RefundNotice{UserID: 9999, MarketID: 1, Question: "Unknown user?", Amount: 10})
+	s.Emit(LossNotice{UserID: 9999, MarketID: 1, Question: "Unknown user?", Amount: 10})
+	s.Emit(BetReceipt{UserID: 9999, MarketID: 1, Question: "Unknown user?", Outcome: "YES", Amount: 10})
+	s.Emit(DisputeAlert{MarketID: 1, Question: "No admin configured?", DisputedBy: 1})
+	s.Emit(ResolutionPublished{MarketID: 1, Question: "No channel configured?", Outcome: "YES"})
+	s.Emit(DisputeCreatorNotice{})
+	s.Emit(MarketLocked{})
+	s.Emit(CosignRequested{})
+	s.Emit(CosignDecided{})
+	s.Emit(ProposalOpened{})
+	s.Emit(ProposalEscalated{})
+	s.Emit(PayoutsEscrowed{})
+	s.Emit(DailyDigest{})
+	s.Emit(GroupDigestPosted{})
+	s.Emit(AccountMergeCode{UserID: 9999, MergeID: 1, Code: "123456"})
+	s.Emit(WorkerFailed{Task: "finalize", MarketID: 1, Error: "boom"})
+	s.Emit(FinalizationStuck{MarketID: 1, Question: "No admin configured?", Failures: 3, Error: "boom"})
+}
+
+func TestGroupMarketPostsStayOutOfChannel(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator, _ := storage.CreateUser(7701, "creator", "Creator")
+	public, _ := storage.CreateMarket(creator.ID, "Will the channel hear about it?", time.Now().Add(time.Hour))
+	group, _ := storage.CreateMarketWithOptions(creator.ID, "Will the group keep it to itself?", time.Now().Add(time.Hour), storage.MarketOptions{GroupID: -1001})
+
+	for _, tt := range []struct {
+		public, group NotificationEvent
+	}{
+		{ResolutionPublished{MarketID: public.ID}, ResolutionPublished{MarketID: group.ID}},
+		{DisputePublished{MarketID: public.ID}, DisputePublished{MarketID: group.ID}},
+		{FinalizationPublished{MarketID: public.ID}, FinalizationPublished{MarketID: group.ID}},
+		{UpsetPublished{MarketID: public.ID}, UpsetPublished{MarketID: group.ID}},
+		{WhaleAlert{MarketID: public.ID}, WhaleAlert{MarketID: group.ID}},
+		{LastCall{Market: public}, LastCall{Market: group}},
+	} {
+		if groupMarketPost(tt.public) {
+			t.Errorf("Expected %s for a public market to be posted", tt.public.Kind())
+		}
+		if !groupMarketPost(tt.group) {
+			t.Errorf("Expected %s for a group market to stay out of the channel", tt.group.Kind())
+		}
+	}
+	if groupMarketPost(WinNotice{MarketID: group.ID}) {
+		t.Error("Expected DMs about group markets to be delivered")
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// groupDigestWinners is how many of yesterday's winners a group digest names
+const groupDigestWinners = 3
+
+// GroupDigest is the morning post of a group chat: new markets, what closes today,
+// yesterday's resolutions and their biggest winners
+type GroupDigest struct {
+	// Date is the group's local time the digest was built at
+	Date         time.Time
+	NewMarkets   []storage.Market
+	ClosingToday []storage.Market
+	Resolved     []storage.Market
+	TopWinners   []GroupDigestWinner
+}
+
+// GroupDigestWinner is one of the biggest winners of yesterday's markets
+type GroupDigestWinner struct {
+	storage.MarketWinner
+	MarketID int64
+	Question string
+}
+
+// Empty reports whether there is nothing to post
+func (d *GroupDigest) Empty() bool {
+	return len(d.NewMarkets) == 0 && len(d.ClosingToday) == 0 && len(d.Resolved) == 0
+}
+
+// BuildGroupDigest assembles the digest for the local day of now in loc. New markets are those
+// opened in the last 24 hours; resolutions are those of the previous local day.
+func BuildGroupDigest(now time.Time, loc *time.Location) (*GroupDigest, error) {
+	local := now.In(loc)
+	startOfToday := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	startOfYesterday := startOfToday.AddDate(0, 0, -1)
+	endOfDay := startOfToday.AddDate(0, 0, 1)
+	digest := &GroupDigest{Date: local}
+
+	markets, err := storage.ListActiveMarkets()
+	if err != nil {
+		return nil, err
+	}
+	for _, market := range markets {
+		if market.CreatedAt.After(now.Add(-24 * time.Hour)) {
+			digest.NewMarkets = append(digest.NewMarkets, market)
+		}
+		if !market.ExpiresAt.Before(now) && market.ExpiresAt.Before(endOfDay) {
+			digest.ClosingToday = append(digest.ClosingToday, market)
+		}
+	}
+
+	resolved, err := storage.ListMarketsResolvedSince(time.Since(startOfYesterday))
+	if err != nil {
+		return nil, err
+	}
+	for _, market := range resolved {
+		if market.ResolvedAt.Before(startOfYesterday) || !market.ResolvedAt.Before(startOfToday) {
+			continue
+		}
+		digest.Resolved = append(digest.Resolved, market)
+
+		winners, err := storage.GetTopWinners(market.ID, groupDigestWinners)
+		if err != nil {
+			logger.Debug(0, "group_digest_winners_failed", fmt.Sprintf("market_id=%d error=%s", market.ID, err.Error()))
+			continue
+		}
+		for _, winner := range winners {
+			digest.TopWinners = append(digest.TopWinners, GroupDigestWinner{MarketWinner: winner, MarketID: market.ID, Question: market.Question})
+		}
+	}
+	sort.SliceStable(digest.TopWinners, func(i, j int) bool {
+		return digest.TopWinners[i].Profit > digest.TopWinners[j].Profit
+	})
+	if len(digest.TopWinners) > groupDigestWinners {
+		digest.TopWinners = digest.TopWinners[:groupDigestWinners]
+	}
+
+	return digest, nil
+}
+
+// Text formats the digest as a plain-text group message
+func (d *GroupDigest) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "☀️ Good morning! Markets for %s\n", d.Date.Format("Mon, Jan 2"))
+
+	section := func(title string, markets []storage.Market, line func(storage.Market) string) {
+		if len(markets) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s\n", title)
+		for i, market := range markets {
+			if i == digestSectionLimit {
+				fmt.Fprintf(&b, "...and %d more\n", len(markets)-i)
+				break
+			}
+			b.WriteString(line(market) + "\n")
+		}
+	}
+	section("🆕 New markets", d.NewMarkets, func(m storage.Market) string {
+		return fmt.Sprintf("• #%d %s", m.ID, withIcon(m.Icon, truncateString(m.Question, 60)))
+	})
+	section("⏰ Closing today", d.ClosingToday, func(m storage.Market) string {
+		return fmt.Sprintf("• #%d %s at %s", m.ID, withIcon(m.Icon, truncateString(m.Question, 60)), m.ExpiresAt.In(d.Date.Location()).Format("15:04"))
+	})
+	section("✅ Resolved yesterday", d.Resolved, func(m storage.Market) string {
+		return fmt.Sprintf("• #%d %s: %s", m.ID, withIcon(m.Icon, truncateString(m.Question, 60)), m.Outcome)
+	})
+
+	if len(d.TopWinners) > 0 {
+		b.WriteString("\n🏆 Biggest winners\n")
+		for _, w := range d.TopWinners {
+			name := w.Name
+			if name == "" {
+				name = "Anonymous"
+			}
+			fmt.Fprintf(&b, "• %s won %s on #%d\n", name, formatBalance(w.Payout), w.MarketID)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// PostDueGroupDigests emits the digest of every group whose digest hour has come in its timezone
+// and that has not had today's digest yet. Days with nothing to report are skipped.
+// It returns how many digests were posted.
+func PostDueGroupDigests(notifier Notifier, now time.Time) int {
+	groups, err := storage.ListGroupDigests()
+	if err != nil {
+		logger.Debug(0, "group_digest_query_failed", fmt.Sprintf("error=%s", err.Error()))
+		return 0
+	}
+
+	posted := 0
+	for _, group := range groups {
+		loc, err := LoadTimezone(group.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		local := now.In(loc)
+		today := local.Format("2006-01-02")
+		if local.Hour() < group.Hour || group.SentOn == today {
+			continue
+		}
+
+		if err := storage.MarkGroupDigestSent(group.ChatID, today); err != nil {
+			logger.Debug(0, "group_digest_failed", fmt.Sprintf("chat_id=%d error=%s", group.ChatID, err.Error()))
+			continue
+		}
+		digest, err := BuildGroupDigest(now, loc)
+		if err != nil {
+			logger.Debug(0, "group_digest_failed", fmt.Sprintf("chat_id=%d error=%s", group.ChatID, err.Error()))
+			continue
+		}
+		if digest.Empty() {
+			continue
+		}
+
+		notifier.Emit(GroupDigestPosted{ChatID: group.ChatID, Digest: digest})
+		posted++
+	}
+	return posted
+}
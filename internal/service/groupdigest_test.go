@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestPostDueGroupDigests(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	recorder := NewRecordingNotifier()
+	_ = storage.EnableGroupDigest(-1001, 0, "UTC", 42)
+
+	// Nothing happened: no post, but the day counts as done
+	if posted := PostDueGroupDigests(recorder, time.Now()); posted != 0 {
+		t.Errorf("Expected no post without news, got %d", posted)
+	}
+	_ = storage.EnableGroupDigest(-1002, 0, "UTC", 42)
+
+	creator, _ := storage.CreateUser(7401, "creator", "Creator")
+	alice, _ := storage.CreateUser(7402, "alice", "Alice")
+	bob, _ := storage.CreateUser(7403, "bob", "Bob")
+	_ = storage.SetShowInWinners(alice.ID, true)
+
+	settled, _ := storage.CreateMarket(creator.ID, "Was this settled yesterday?", time.Now().Add(time.Hour))
+	_ = storage.PlaceBet(ctx, alice.ID, settled.ID, "YES", 100)
+	_ = storage.PlaceBet(ctx, bob.ID, settled.ID, "NO", 100)
+	storage.DB().Exec(`UPDATE markets SET status = 'FINALIZED', outcome = 'YES', resolved_at = ? WHERE id = ?`, time.Now().UTC().Truncate(24*time.Hour).Add(-12*time.Hour).Format("2006-01-02 15:04:05"), settled.ID)
+	fresh, _ := storage.CreateMarket(creator.ID, "Is this market new?", time.Now().Add(48*time.Hour))
+
+	if posted := PostDueGroupDigests(recorder, time.Now()); posted != 1 {
+		t.Fatalf("Expected one post for the group that has not had today's digest, got %d", posted)
+	}
+	if posted := PostDueGroupDigests(recorder, time.Now()); posted != 0 {
+		t.Errorf("Expected one post a day, got %d more", posted)
+	}
+
+	events := recorder.Events()
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+	e, ok := events[0].(GroupDigestPosted)
+	if !ok || e.ChatID != -1002 || e.Digest == nil {
+		t.Fatalf("Expected a digest for group -1002, got %+v", events[0])
+	}
+	if len(e.Digest.NewMarkets) != 1 || e.Digest.NewMarkets[0].ID != fresh.ID {
+		t.Errorf("Expected market %d as new, got %+v", fresh.ID, e.Digest.NewMarkets)
+	}
+	if len(e.Digest.Resolved) != 1 || e.Digest.Resolved[0].ID != settled.ID {
+		t.Errorf("Expected market %d resolved yesterday, got %+v", settled.ID, e.Digest.Resolved)
+	}
+	if len(e.Digest.TopWinners) != 1 || e.Digest.TopWinners[0].Name != "Alice" || e.Digest.TopWinners[0].Payout != 200 {
+		t.Errorf("Expected Alice to win 200, got %+v", e.Digest.TopWinners)
+	}
+	if text := e.Digest.Text(); !strings.Contains(text, "Alice won") || !strings.Contains(text, "Is this market new?") {
+		t.Errorf("Unexpected digest text %q", text)
+	}
+}
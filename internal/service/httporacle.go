@@ -0,0 +1,518 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+const (
+	// httpOraclePollInterval is how often the HTTP oracle worker looks for locked markets
+	httpOraclePollInterval = 5 * time.Minute
+	// httpOracleTimeout bounds a single oracle fetch
+	httpOracleTimeout = 15 * time.Second
+	// maxOracleResponseBytes caps the JSON document an oracle reads, which is kept as evidence
+	maxOracleResponseBytes = 64 << 10
+	// maxOracleAttempts is how many failed fetches an oracle gets before it gives up
+	maxOracleAttempts = 5
+	// maxOraclePathLength and maxOracleValueLength cap the mapping
+	maxOraclePathLength  = 200
+	maxOracleValueLength = 100
+	// maxOracleRedirects caps the redirects followed by a fetch
+	maxOracleRedirects = 3
+)
+
+// OracleOperators are the comparisons an oracle can make. == and != compare numbers, strings,
+// booleans or null; the others compare numbers, including numbers sent as strings.
+var OracleOperators = []string{"==", "!=", ">", ">=", "<", "<="}
+
+// OracleMapping is how an HTTP-JSON oracle turns a JSON document into an outcome: YES when the
+// value at Path in the document at URL compares to Value with Operator, NO otherwise. Path is a
+// JSONPath subset: $ followed by .name, ["name"] and [index] steps, e.g. $.data[0].close.
+type OracleMapping struct {
+	URL      string `json:"url"`
+	Path     string `json:"path"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+// OracleReading is what an oracle found in the JSON document it fetched
+type OracleReading struct {
+	// Observed is the value at the mapping's path, as text
+	Observed string `json:"observed"`
+	Outcome  string `json:"outcome"`
+	// Evidence is the raw document
+	Evidence  string    `json:"evidence"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// ValidateOracleMapping checks a mapping and returns it cleaned up
+func ValidateOracleMapping(m OracleMapping) (OracleMapping, error) {
+	oracleURL, err := validatePublicURL(m.URL, "oracle URL")
+	if err != nil {
+		return OracleMapping{}, err
+	}
+	m.URL = oracleURL
+
+	m.Path = strings.TrimSpace(m.Path)
+	if len(m.Path) > maxOraclePathLength {
+		return OracleMapping{}, fmt.Errorf("invalid oracle path: at most %d characters", maxOraclePathLength)
+	}
+	if _, err := parseJSONPath(m.Path); err != nil {
+		return OracleMapping{}, err
+	}
+
+	m.Operator = strings.TrimSpace(m.Operator)
+	known := false
+	for _, op := range OracleOperators {
+		known = known || op == m.Operator
+	}
+	if !known {
+		return OracleMapping{}, fmt.Errorf("invalid oracle operator: must be one of %s", strings.Join(OracleOperators, " "))
+	}
+
+	m.Value = strings.TrimSpace(m.Value)
+	if m.Value == "" || len(m.Value) > maxOracleValueLength {
+		return OracleMapping{}, fmt.Errorf("invalid oracle value: 1 to %d characters", maxOracleValueLength)
+	}
+	if m.Operator != "==" && m.Operator != "!=" {
+		if _, err := strconv.ParseFloat(m.Value, 64); err != nil {
+			return OracleMapping{}, fmt.Errorf("invalid oracle value: %s needs a number", m.Operator)
+		}
+	}
+	return m, nil
+}
+
+// parseJSONPath splits a path into its steps: strings for object keys and ints for array indexes
+func parseJSONPath(path string) ([]interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid oracle path: must start with $")
+	}
+	var steps []interface{}
+	rest := path[1:]
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("invalid oracle path: empty key in %s", path)
+			}
+			steps = append(steps, name)
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, `["`) || strings.HasPrefix(rest, `['`):
+			quote := rest[1:2]
+			end := strings.Index(rest[2:], quote+"]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid oracle path: unclosed key in %s", path)
+			}
+			steps = append(steps, rest[2:2+end])
+			rest = rest[2+end+2:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid oracle path: unclosed index in %s", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid oracle path: bad index in %s", path)
+			}
+			steps = append(steps, index)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid oracle path: unexpected %q in %s", rest[0], path)
+		}
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("invalid oracle path: must select a value below $")
+	}
+	return steps, nil
+}
+
+// lookupJSONPath returns the value at path in a document decoded with UseNumber
+func lookupJSONPath(doc interface{}, path string) (interface{}, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	value := doc
+	for _, step := range steps {
+		switch step := step.(type) {
+		case string:
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("oracle path %s not found in the response", path)
+			}
+			if value, ok = object[step]; !ok {
+				return nil, fmt.Errorf("oracle path %s not found in the response", path)
+			}
+		case int:
+			array, ok := value.([]interface{})
+			if !ok || step >= len(array) {
+				return nil, fmt.Errorf("oracle path %s not found in the response", path)
+			}
+			value = array[step]
+		}
+	}
+	return value, nil
+}
+
+// OracleOutcome compares the value found at the mapping's path with the mapping's value and
+// returns the value as text and YES or NO
+func OracleOutcome(m OracleMapping, found interface{}) (string, string, error) {
+	var observed string
+	var holds bool
+	switch v := found.(type) {
+	case json.Number:
+		observed = v.String()
+		ok, err := compareOracleNumbers(observed, m.Operator, m.Value)
+		if err != nil {
+			return "", "", err
+		}
+		holds = ok
+	case string:
+		observed = v
+		if m.Operator == "==" || m.Operator == "!=" {
+			holds = (v == m.Value) == (m.Operator == "==")
+			break
+		}
+		ok, err := compareOracleNumbers(v, m.Operator, m.Value)
+		if err != nil {
+			return "", "", err
+		}
+		holds = ok
+	case bool, nil:
+		observed = "null"
+		if v != nil {
+			observed = strconv.FormatBool(v.(bool))
+		}
+		if m.Operator != "==" && m.Operator != "!=" {
+			return "", "", fmt.Errorf("oracle value %s cannot be compared with %s", observed, m.Operator)
+		}
+		holds = (observed == m.Value) == (m.Operator == "==")
+	default:
+		return "", "", fmt.Errorf("oracle path %s selects an object or array, not a value", m.Path)
+	}
+
+	if holds {
+		return observed, "YES", nil
+	}
+	return observed, "NO", nil
+}
+
+// compareOracleNumbers applies op to two numbers given as text
+func compareOracleNumbers(observed, op, value string) (bool, error) {
+	a, err := strconv.ParseFloat(strings.TrimSpace(observed), 64)
+	if err != nil {
+		return false, fmt.Errorf("oracle value %q is not a number", observed)
+	}
+	b, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid oracle value: %q is not a number", value)
+	}
+	switch op {
+	case "==":
+		return a == b, nil
+	case "!=":
+		return a != b, nil
+	case ">":
+		return a > b, nil
+	case ">=":
+		return a >= b, nil
+	case "<":
+		return a < b, nil
+	case "<=":
+		return a <= b, nil
+	}
+	return false, fmt.Errorf("invalid oracle operator: %s", op)
+}
+
+// HTTPOracleClient fetches the JSON documents of HTTP-JSON oracles
+type HTTPOracleClient struct {
+	client *http.Client
+	// checkURL vets every URL fetched, redirects included
+	checkURL func(string) (string, error)
+	now      func() time.Time
+}
+
+// NewHTTPOracleClient creates a client that only fetches public https URLs. Its dialer refuses
+// private addresses too, so an approved hostname later pointed at one is not fetched.
+func NewHTTPOracleClient() *HTTPOracleClient {
+	return newHTTPOracleClient(newPublicHTTPClient(httpOracleTimeout, "oracle URL"), func(raw string) (string, error) {
+		return validatePublicURL(raw, "oracle URL")
+	})
+}
+
+// newHTTPOracleClient creates a client with its own URL check, e.g. one letting tests reach a
+// local server
+func newHTTPOracleClient(client *http.Client, checkURL func(string) (string, error)) *HTTPOracleClient {
+	c := &HTTPOracleClient{client: client, checkURL: checkURL, now: time.Now}
+	c.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxOracleRedirects {
+			return fmt.Errorf("too many redirects")
+		}
+		_, err := c.checkURL(req.URL.String())
+		return err
+	}
+	return c
+}
+
+var (
+	httpOracleMu     sync.Mutex
+	httpOracleClient *HTTPOracleClient
+)
+
+// GetHTTPOracleClient returns the shared HTTP oracle client
+func GetHTTPOracleClient() *HTTPOracleClient {
+	httpOracleMu.Lock()
+	defer httpOracleMu.Unlock()
+	if httpOracleClient == nil {
+		httpOracleClient = NewHTTPOracleClient()
+	}
+	return httpOracleClient
+}
+
+// SetHTTPOracleClient replaces the HTTP oracle client; nil goes back to the default
+func SetHTTPOracleClient(c *HTTPOracleClient) {
+	httpOracleMu.Lock()
+	defer httpOracleMu.Unlock()
+	httpOracleClient = c
+}
+
+// Read fetches an oracle's JSON document and evaluates its mapping
+func (c *HTTPOracleClient) Read(ctx context.Context, m OracleMapping) (*OracleReading, error) {
+	oracleURL, err := c.checkURL(m.URL)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, oracleURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid oracle URL: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	fetchedAt := c.now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oracle fetch failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("oracle fetch failed: status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxOracleResponseBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("oracle fetch failed: %w", err)
+	}
+	if len(body) > maxOracleResponseBytes {
+		return nil, fmt.Errorf("oracle response is larger than %d bytes", maxOracleResponseBytes)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("oracle response is not JSON: %w", err)
+	}
+	found, err := lookupJSONPath(doc, m.Path)
+	if err != nil {
+		return nil, err
+	}
+	observed, outcome, err := OracleOutcome(m, found)
+	if err != nil {
+		return nil, err
+	}
+	return &OracleReading{Observed: observed, Outcome: outcome, Evidence: string(body), FetchedAt: fetchedAt}, nil
+}
+
+// mappingOf returns the mapping of a stored oracle
+func mappingOf(o *storage.MarketOracle) OracleMapping {
+	return OracleMapping{URL: o.URL, Path: o.Path, Operator: o.Operator, Value: o.Value}
+}
+
+// SetMarketOracle gives a market the actor created an HTTP-JSON oracle, replacing the one it
+// had. The mapping only resolves the market once an admin approved it. Oracles can be set
+// until the market locks.
+func SetMarketOracle(actor *storage.User, marketID int64, m OracleMapping) (*storage.MarketOracle, error) {
+	market, err := storage.GetMarketByID(marketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+	if market == nil || market.Hidden {
+		return nil, fmt.Errorf("market not found")
+	}
+	if market.CreatorID != actor.ID {
+		return nil, fmt.Errorf("only the market creator can set its oracle")
+	}
+	if market.Status != storage.MarketStatusActive && market.Status != storage.MarketStatusLastCall {
+		return nil, fmt.Errorf("market is not open: oracles can only be set before it locks")
+	}
+	m, err = ValidateOracleMapping(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storage.SetMarketOracle(marketID, m.URL, m.Path, m.Operator, m.Value); err != nil {
+		return nil, err
+	}
+	logger.Debug(actor.TelegramID, "market_oracle_set", fmt.Sprintf("market_id=%d host=%s path=%s operator=%s", marketID, hostOf(m.URL), m.Path, m.Operator))
+	return storage.GetMarketOracle(marketID)
+}
+
+// GetMarketOracle returns the oracle of a visible market, with its evidence once it resolved
+func GetMarketOracle(marketID int64) (*storage.MarketOracle, error) {
+	market, err := storage.GetMarketByID(marketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+	if market == nil || market.Hidden {
+		return nil, fmt.Errorf("market not found")
+	}
+	oracle, err := storage.GetMarketOracle(marketID)
+	if err != nil {
+		return nil, err
+	}
+	if oracle == nil {
+		return nil, fmt.Errorf("oracle not found")
+	}
+	return oracle, nil
+}
+
+// ApproveMarketOracle lets an oracle waiting for review resolve its market
+func ApproveMarketOracle(admin *storage.User, marketID int64) (*storage.MarketOracle, error) {
+	if err := storage.ApproveMarketOracle(marketID, admin.ID); err != nil {
+		return nil, reviewOracleError(marketID, err)
+	}
+	logger.Debug(admin.TelegramID, "market_oracle_approved", fmt.Sprintf("market_id=%d", marketID))
+	return storage.GetMarketOracle(marketID)
+}
+
+// RejectMarketOracle turns an oracle waiting for review down; its market is resolved by hand
+func RejectMarketOracle(admin *storage.User, marketID int64) (*storage.MarketOracle, error) {
+	if err := storage.RejectMarketOracle(marketID, admin.ID); err != nil {
+		return nil, reviewOracleError(marketID, err)
+	}
+	logger.Debug(admin.TelegramID, "market_oracle_rejected", fmt.Sprintf("market_id=%d", marketID))
+	return storage.GetMarketOracle(marketID)
+}
+
+// reviewOracleError tells a missing oracle apart from one that is not waiting for review
+func reviewOracleError(marketID int64, err error) error {
+	if oracle, getErr := storage.GetMarketOracle(marketID); getErr == nil && oracle == nil {
+		return fmt.Errorf("oracle not found")
+	}
+	return err
+}
+
+// PreviewMarketOracle fetches an oracle's document now and returns what it would resolve with,
+// without resolving anything, so admins can check a mapping before approving it
+func PreviewMarketOracle(ctx context.Context, admin *storage.User, marketID int64) (*OracleReading, error) {
+	oracle, err := storage.GetMarketOracle(marketID)
+	if err != nil {
+		return nil, err
+	}
+	if oracle == nil {
+		return nil, fmt.Errorf("oracle not found")
+	}
+	reading, err := GetHTTPOracleClient().Read(ctx, mappingOf(oracle))
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug(admin.TelegramID, "market_oracle_previewed", fmt.Sprintf("market_id=%d observed=%s outcome=%s", marketID, reading.Observed, reading.Outcome))
+	return reading, nil
+}
+
+// HTTPOracleWorker resolves markets with an approved HTTP-JSON oracle once they lock
+type HTTPOracleWorker struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewHTTPOracleWorker creates an HTTP oracle worker
+func NewHTTPOracleWorker() *HTTPOracleWorker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &HTTPOracleWorker{ctx: ctx, cancel: cancel}
+}
+
+// Start resolves due markets right away and then every httpOraclePollInterval
+func (w *HTTPOracleWorker) Start() {
+	logger.Debug(0, "http_oracle_worker_started", fmt.Sprintf("interval=%v", httpOraclePollInterval))
+	ticker := time.NewTicker(httpOraclePollInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			if err := w.ResolveDue(w.ctx); err != nil {
+				logger.Debug(0, "http_oracle_worker_error", "error="+err.Error())
+			}
+			select {
+			case <-ticker.C:
+			case <-w.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the worker
+func (w *HTTPOracleWorker) Stop() {
+	w.cancel()
+	logger.Debug(0, "http_oracle_worker_stopped", "")
+}
+
+// ResolveDue resolves the locked markets with an approved oracle. A failed fetch is tried again
+// on the next run, until the oracle gives up after maxOracleAttempts failures.
+func (w *HTTPOracleWorker) ResolveDue(ctx context.Context) error {
+	due, err := storage.ListDueMarketOracles()
+	if err != nil {
+		return err
+	}
+
+	for i := range due {
+		oracle := &due[i]
+		if err := w.resolve(ctx, oracle); err != nil {
+			logger.Debug(0, "http_oracle_resolve_failed", fmt.Sprintf("market_id=%d error=%v", oracle.MarketID, err))
+			if err := storage.RecordMarketOracleFailure(oracle.MarketID, err.Error(), maxOracleAttempts); err != nil {
+				logger.Debug(0, "http_oracle_record_failed", fmt.Sprintf("market_id=%d error=%v", oracle.MarketID, err))
+			}
+		}
+	}
+	return nil
+}
+
+// resolve fetches one due oracle and resolves its market as the creator, so the dispute window
+// and cosigning apply as for any resolution
+func (w *HTTPOracleWorker) resolve(ctx context.Context, oracle *storage.MarketOracle) error {
+	market, err := storage.GetMarketByID(oracle.MarketID)
+	if err != nil {
+		return err
+	}
+	if market == nil {
+		return fmt.Errorf("market not found")
+	}
+
+	reading, err := GetHTTPOracleClient().Read(ctx, mappingOf(oracle))
+	if err != nil {
+		return err
+	}
+	creator, err := storage.GetUserByID(market.CreatorID)
+	if err != nil {
+		return err
+	}
+	if _, err := NewPayoutService().ResolveMarket(ctx, market.ID, creator, reading.Outcome); err != nil {
+		return err
+	}
+	logger.Debug(0, "http_oracle_market_resolved", fmt.Sprintf("market_id=%d observed=%s outcome=%s", market.ID, reading.Observed, reading.Outcome))
+	return storage.ResolveMarketOracle(oracle.MarketID, reading.FetchedAt, reading.Observed, reading.Outcome, reading.Evidence)
+}
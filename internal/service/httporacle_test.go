@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+// newOracleServer serves *body with *status and points the HTTP oracle client at it, letting
+// it reach the local server
+func newOracleServer(t *testing.T, status *int, body *string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(*status)
+		w.Write([]byte(*body))
+	}))
+	SetHTTPOracleClient(newHTTPOracleClient(server.Client(), func(raw string) (string, error) { return raw, nil }))
+	t.Cleanup(func() {
+		server.Close()
+		SetHTTPOracleClient(nil)
+	})
+	return server
+}
+
+func TestValidateOracleMapping(t *testing.T) {
+	valid := OracleMapping{URL: " https://api.example.com/btc ", Path: "$.data[0]['close']", Operator: ">=", Value: " 100000 "}
+	m, err := ValidateOracleMapping(valid)
+	if err != nil || m.URL != "https://api.example.com/btc" || m.Value != "100000" {
+		t.Fatalf("Unexpected mapping %+v, %v", m, err)
+	}
+
+	for _, bad := range []OracleMapping{
+		{URL: "http://api.example.com/btc", Path: "$.price", Operator: ">", Value: "1"},
+		{URL: "https://10.0.0.1/btc", Path: "$.price", Operator: ">", Value: "1"},
+		{URL: "https://api.example.com/btc", Path: "price", Operator: ">", Value: "1"},
+		{URL: "https://api.example.com/btc", Path: "$", Operator: ">", Value: "1"},
+		{URL: "https://api.example.com/btc", Path: "$.a..b", Operator: ">", Value: "1"},
+		{URL: "https://api.example.com/btc", Path: "$.a[x]", Operator: ">", Value: "1"},
+		{URL: "https://api.example.com/btc", Path: "$.price", Operator: "~", Value: "1"},
+		{URL: "https://api.example.com/btc", Path: "$.price", Operator: ">", Value: "high"},
+		{URL: "https://api.example.com/btc", Path: "$.price", Operator: "==", Value: ""},
+	} {
+		if _, err := ValidateOracleMapping(bad); err == nil {
+			t.Errorf("Expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestOracleOutcome(t *testing.T) {
+	var doc interface{}
+	decoder := json.NewDecoder(strings.NewReader(`{"data": [{"close": 101000.5, "close_text": "99000", "live": false, "note": null, "name": "BTC"}]}`))
+	decoder.UseNumber()
+	decoder.Decode(&doc)
+
+	for _, tc := range []struct {
+		path, op, value string
+		want            string
+	}{
+		{"$.data[0].close", ">", "100000", "YES"},
+		{"$.data[0].close", "<=", "100000", "NO"},
+		{"$.data[0].close_text", ">=", "100000", "NO"},
+		{"$.data[0].live", "==", "false", "YES"},
+		{"$.data[0].note", "!=", "null", "NO"},
+		{`$.data[0]["name"]`, "==", "BTC", "YES"},
+	} {
+		found, err := lookupJSONPath(doc, tc.path)
+		if err != nil {
+			t.Fatalf("%s: lookup failed: %v", tc.path, err)
+		}
+		if _, got, err := OracleOutcome(OracleMapping{Path: tc.path, Operator: tc.op, Value: tc.value}, found); err != nil || got != tc.want {
+			t.Errorf("%s %s %s: expected %s, got %s (%v)", tc.path, tc.op, tc.value, tc.want, got, err)
+		}
+	}
+
+	if _, err := lookupJSONPath(doc, "$.data[1].close"); err == nil {
+		t.Error("Expected a missing index to fail")
+	}
+	found, _ := lookupJSONPath(doc, "$.data[0].name")
+	if _, _, err := OracleOutcome(OracleMapping{Path: "$.data[0].name", Operator: ">", Value: "1"}, found); err == nil {
+		t.Error("Expected a text value not to be compared as a number")
+	}
+	found, _ = lookupJSONPath(doc, "$.data")
+	if _, _, err := OracleOutcome(OracleMapping{Path: "$.data", Operator: "==", Value: "1"}, found); err == nil {
+		t.Error("Expected an array not to be compared")
+	}
+}
+
+func TestHTTPOracleWorker(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	status, body := http.StatusServiceUnavailable, `{}`
+	server := newOracleServer(t, &status, &body)
+	creator, _ := storage.CreateUser(7401, "creator", "Creator")
+	admin, _ := storage.CreateUser(7402, "admin", "Admin")
+	market, _ := storage.CreateMarket(creator.ID, "Will BTC close above 100k?", time.Now().Add(time.Hour))
+	storage.SetMarketOracle(market.ID, server.URL+"/btc", "$.data.close", ">", "100000")
+
+	worker := NewHTTPOracleWorker()
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+
+	// An oracle waiting for review does not resolve anything
+	worker.ResolveDue(context.Background())
+	if m, _ := storage.GetMarketByID(market.ID); m.Status != storage.MarketStatusLocked {
+		t.Fatalf("Expected the market to stay locked, got %s", m.Status)
+	}
+	if _, err := ApproveMarketOracle(admin, market.ID); err != nil {
+		t.Fatalf("ApproveMarketOracle failed: %v", err)
+	}
+	if _, err := RejectMarketOracle(admin, market.ID+100); err == nil || err.Error() != "oracle not found" {
+		t.Errorf("Expected a missing oracle, got %v", err)
+	}
+
+	// A failed fetch is counted and tried again
+	worker.ResolveDue(context.Background())
+	if o, _ := storage.GetMarketOracle(market.ID); o.Status != storage.OracleApproved || o.Attempts != 1 || o.LastError == "" {
+		t.Errorf("Expected a counted failure, got %+v", o)
+	}
+
+	status, body = http.StatusOK, `{"data": {"close": 101250.5}}`
+	reading, err := PreviewMarketOracle(context.Background(), admin, market.ID)
+	if err != nil || reading.Outcome != "YES" || reading.Observed != "101250.5" {
+		t.Fatalf("Unexpected preview %+v, %v", reading, err)
+	}
+	if m, _ := storage.GetMarketByID(market.ID); m.Status != storage.MarketStatusLocked {
+		t.Errorf("Expected a preview not to resolve the market, got %s", m.Status)
+	}
+
+	worker.ResolveDue(context.Background())
+	m, _ := storage.GetMarketByID(market.ID)
+	o, _ := storage.GetMarketOracle(market.ID)
+	if m.Status != storage.MarketStatusResolved || m.Outcome != "YES" {
+		t.Errorf("Expected the market resolved YES, got %s/%s", m.Status, m.Outcome)
+	}
+	if o.Status != storage.OracleResolved || o.Observed != "101250.5" || o.Evidence != body || o.FetchedAt == nil {
+		t.Errorf("Expected the evidence to be kept, got %+v", o)
+	}
+}
+
+func TestHTTPOracleClientRefusesPrivateHosts(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(`{"data": {"close": 1}}`))
+	}))
+	defer server.Close()
+
+	client := NewHTTPOracleClient()
+	// As if the hostname had passed review and was later pointed at loopback
+	client.checkURL = func(raw string) (string, error) { return raw, nil }
+	oracleURL := strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/btc"
+	_, err := client.Read(context.Background(), OracleMapping{URL: oracleURL, Path: "$.data.close", Operator: ">", Value: "0"})
+	if err == nil || !strings.Contains(err.Error(), "not public") {
+		t.Errorf("Expected the fetch to be refused, got %v", err)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("Expected no request to reach the loopback server, got %d", calls.Load())
+	}
+}
+
+func TestSetMarketOracle(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator, _ := storage.CreateUser(7411, "creator", "Creator")
+	other, _ := storage.CreateUser(7412, "other", "Other")
+	market, _ := storage.CreateMarket(creator.ID, "Will the launch happen?", time.Now().Add(time.Hour))
+	mapping := OracleMapping{URL: "https://api.example.com/launch", Path: "$.status", Operator: "==", Value: "success"}
+
+	if _, err := SetMarketOracle(other, market.ID, mapping); err == nil {
+		t.Error("Expected only the creator to set the oracle")
+	}
+	oracle, err := SetMarketOracle(creator, market.ID, mapping)
+	if err != nil || oracle.Status != storage.OraclePendingReview || oracle.Value != "success" {
+		t.Fatalf("Unexpected oracle %+v, %v", oracle, err)
+	}
+	if got, err := GetMarketOracle(market.ID); err != nil || got.URL != mapping.URL {
+		t.Errorf("Unexpected oracle %+v, %v", got, err)
+	}
+
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	if _, err := SetMarketOracle(creator, market.ID, mapping); err == nil {
+		t.Error("Expected a locked market's oracle not to change")
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"predictionbot/internal/storage"
+)
+
+// maxIconRunes bounds a chosen icon; flags, skin tones and ZWJ sequences take several runes
+const maxIconRunes = 8
+
+// categoryIcons maps hashtags to the icon a market gets when its creator did not pick one
+var categoryIcons = map[string]string{
+	"sports":     "⚽",
+	"football":   "⚽",
+	"soccer":     "⚽",
+	"basketball": "🏀",
+	"nba":        "🏀",
+	"tennis":     "🎾",
+	"f1":         "🏎️",
+	"esports":    "🎮",
+	"gaming":     "🎮",
+	"crypto":     "🪙",
+	"bitcoin":    "🪙",
+	"btc":        "🪙",
+	"eth":        "🪙",
+	"stocks":     "📈",
+	"finance":    "📈",
+	"economy":    "📈",
+	"politics":   "🗳️",
+	"election":   "🗳️",
+	"elections":  "🗳️",
+	"weather":    "🌦️",
+	"climate":    "🌍",
+	"tech":       "💻",
+	"ai":         "🤖",
+	"science":    "🔬",
+	"space":      "🚀",
+	"movies":     "🎬",
+	"music":      "🎵",
+	"tv":         "📺",
+	"food":       "🍕",
+	"travel":     "✈️",
+	"health":     "🩺",
+	"work":       "💼",
+}
+
+// NormalizeIcon validates an icon chosen by a creator: a single emoji, without letters, digits
+// or spaces. An empty icon is returned as is.
+func NormalizeIcon(icon string) (string, error) {
+	icon = strings.TrimSpace(icon)
+	if icon == "" {
+		return "", nil
+	}
+	if utf8.RuneCountInString(icon) > maxIconRunes {
+		return "", fmt.Errorf("invalid icon: must be a single emoji")
+	}
+	// Keycap emoji such as 1️⃣ are a digit followed by the combining keycap
+	keycap := strings.ContainsRune(icon, '\u20e3')
+	hasSymbol := keycap
+	for _, r := range icon {
+		if unicode.IsDigit(r) && keycap {
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return "", fmt.Errorf("invalid icon: must be a single emoji")
+		}
+		if unicode.IsSymbol(r) || unicode.Is(unicode.Regional_Indicator, r) {
+			hasSymbol = true
+		}
+	}
+	if !hasSymbol {
+		return "", fmt.Errorf("invalid icon: must be a single emoji")
+	}
+	return icon, nil
+}
+
+// DeriveIcon picks the icon of the first hashtag of a question with a known category,
+// or DefaultMarketIcon
+func DeriveIcon(question string) string {
+	for _, tag := range ParseHashtags(question) {
+		if icon, ok := categoryIcons[tag]; ok {
+			return icon
+		}
+	}
+	return storage.DefaultMarketIcon
+}
+
+// withIcon puts a market's icon in front of its question
+func withIcon(icon, question string) string {
+	if icon == "" {
+		return question
+	}
+	return icon + " " + question
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+
+	"predictionbot/internal/storage"
+)
+
+func TestNormalizeIcon(t *testing.T) {
+	tests := []struct {
+		icon    string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{" ⚽ ", "⚽", false},
+		{"🏳️‍🌈", "🏳️‍🌈", false},
+		{"🇩🇪", "🇩🇪", false},
+		{"👍🏽", "👍🏽", false},
+		{"1️⃣", "1️⃣", false},
+		{"A", "", true},
+		{"7", "", true},
+		{"⚽ goal", "", true},
+		{"🎯🎯🎯🎯🎯🎯🎯🎯🎯", "", true},
+		{"-", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeIcon(tt.icon)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeIcon(%q) = %q, %v; want %q (error %t)", tt.icon, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDeriveIcon(t *testing.T) {
+	tests := []struct {
+		question string
+		want     string
+	}{
+		{"Will it snow in Berlin? #weather", "🌦️"},
+		{"Will #Bitcoin hit 100k? #finance", "🪙"},
+		{"Will the #derby end in a draw? #football", "⚽"},
+		{"Will the meeting run late?", storage.DefaultMarketIcon},
+		{"Will #something obscure happen?", storage.DefaultMarketIcon},
+	}
+	for _, tt := range tests {
+		if got := DeriveIcon(tt.question); got != tt.want {
+			t.Errorf("DeriveIcon(%q) = %q, want %q", tt.question, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// llmTimeout bounds a single chat completion request
+	llmTimeout = 20 * time.Second
+	// llmMaxBody is how much of a chat completion response is read
+	llmMaxBody = 64 << 10
+)
+
+// chatCompletion sends one system and one user message to an OpenAI-compatible
+// chat completions endpoint and returns the reply. jsonReply asks for a JSON object.
+func chatCompletion(ctx context.Context, client *http.Client, endpoint, apiKey, model, system, user string, jsonReply bool) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmTimeout)
+	defer cancel()
+
+	request := map[string]interface{}{
+		"model": model,
+		"messages": []map[string]string{
+			{"role": "system", "content": system},
+			{"role": "user", "content": user},
+		},
+		"temperature": 0.2,
+	}
+	if jsonReply {
+		request["response_format"] = map[string]string{"type": "json_object"}
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, llmMaxBody))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &completion); err != nil {
+		return "", fmt.Errorf("failed to decode completion: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return "", fmt.Errorf("completion has no choices")
+	}
+	return strings.TrimSpace(completion.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+// Lock summaries go out in batches so a popular market stays under Telegram's rate limit
+const (
+	lockSummaryBatchSize  = 25
+	lockSummaryBatchPause = time.Second
+)
+
+// LockSummaryText formats a bettor's summary of a market that locked at lockedAt: their position,
+// the final pools and what happens next
+func LockSummaryText(market *storage.Market, position storage.MarketPosition, pools storage.PublicPools, lockedAt time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔒 Market #%d is closed for bets\n\n📝 %s\n\n", market.ID, withIcon(market.Icon, truncateString(market.Question, 50)))
+
+	b.WriteString("Your position:")
+	if position.Yes > 0 {
+		fmt.Fprintf(&b, " YES %s", formatBalance(position.Yes))
+	}
+	if position.No > 0 {
+		fmt.Fprintf(&b, " NO %s", formatBalance(position.No))
+	}
+	b.WriteString("\n")
+
+	if pools.SidesHidden {
+		fmt.Fprintf(&b, "Final pool: %s (sides stay sealed until payouts)\n", formatBalance(pools.Total))
+	} else {
+		fmt.Fprintf(&b, "Final pools: YES %s / NO %s\n", formatBalance(pools.Yes), formatBalance(pools.No))
+	}
+
+	b.WriteString("\nWhat happens next: the creator resolves the market")
+	if absence := CreatorAbsence(); absence > 0 {
+		fmt.Fprintf(&b, " (if they haven't by %s, bettors can /propose the outcome)", lockedAt.Add(absence).UTC().Format("2006-01-02 15:04 UTC"))
+	}
+	fmt.Fprintf(&b, ". The outcome can then be disputed for %s before payouts.", formatDuration(DisputeDelay()))
+	return b.String()
+}
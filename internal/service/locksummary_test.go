@@ -0,0 +1,30 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestLockSummaryText(t *testing.T) {
+	t.Setenv("COMMUNITY_RESOLUTION_HOURS", "48")
+	t.Setenv("DISPUTE_DELAY_MINUTES", "")
+
+	market := &storage.Market{ID: 7, Question: "Will the bridge open on time?", Icon: "🌉"}
+	position := storage.MarketPosition{Yes: 300}
+	lockedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	text := LockSummaryText(market, position, storage.PublicPools{Yes: 1000, No: 500, Total: 1500}, lockedAt)
+	for _, want := range []string{"#7", "🌉 Will the bridge open on time?", "Your position: YES " + formatBalance(300), "Final pools: YES " + formatBalance(1000), "2026-03-03 12:00 UTC", "disputed for 1 day"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in summary:\n%s", want, text)
+		}
+	}
+
+	sealed := LockSummaryText(market, position, storage.PublicPools{Total: 1500, SidesHidden: true}, lockedAt)
+	if strings.Contains(sealed, "Final pools") || !strings.Contains(sealed, "Final pool: "+formatBalance(1500)) {
+		t.Errorf("Expected a sealed market to show only its total:\n%s", sealed)
+	}
+}
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// Default market duration limits, overridable through the environment
+const (
+	// MinMarketDuration is how far in the future a new market must expire
+	MinMarketDuration = 1 * time.Hour
+	// MaxMarketDuration is the longest a new market may stay open
+	MaxMarketDuration = 365 * 24 * time.Hour
+)
+
+// DuplicateMarketWindow is how long the same question from the same creator counts as a retry
+const DuplicateMarketWindow = time.Minute
+
+// maxIdempotencyKeyLength bounds client-generated idempotency keys (a UUID has 36 characters)
+const maxIdempotencyKeyLength = 64
+
+// DuplicateMarketError is returned when a creation repeats an earlier one, e.g. a double
+// submit on a flaky connection. Market is the market the first request created.
+type DuplicateMarketError struct {
+	Market *storage.Market
+}
+
+func (e *DuplicateMarketError) Error() string {
+	return fmt.Sprintf("market already created: #%d", e.Market.ID)
+}
+
+// MarketDurations holds the deadline rules for new markets
+type MarketDurations struct {
+	Min time.Duration
+	Max time.Duration
+	// Align rounds deadlines up to a multiple of this duration; zero keeps them as given
+	Align time.Duration
+}
+
+// LoadMarketDurations reads MARKET_MIN_DURATION, MARKET_MAX_DURATION and MARKET_DEADLINE_ALIGN
+// (Go durations such as "1h" or "8760h"), falling back to the defaults for missing or invalid values.
+// MARKET_MAX_DURATION=0 removes the upper limit.
+func LoadMarketDurations() MarketDurations {
+	d := MarketDurations{Min: MinMarketDuration, Max: MaxMarketDuration}
+	if v, err := time.ParseDuration(os.Getenv("MARKET_MIN_DURATION")); err == nil && v >= 0 {
+		d.Min = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("MARKET_MAX_DURATION")); err == nil && v >= 0 {
+		d.Max = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("MARKET_DEADLINE_ALIGN")); err == nil && v >= 0 {
+		d.Align = v
+	}
+	return d
+}
+
+// Deadline aligns expiresAt and checks it against the limits, relative to now
+func (d MarketDurations) Deadline(expiresAt, now time.Time) (time.Time, error) {
+	if d.Align > 0 {
+		if aligned := expiresAt.Truncate(d.Align); !aligned.Equal(expiresAt) {
+			expiresAt = aligned.Add(d.Align)
+		}
+	}
+	if expiresAt.Before(now.Add(d.Min)) {
+		return time.Time{}, fmt.Errorf("invalid expiration: must be at least %s from now", formatDuration(d.Min))
+	}
+	if d.Max > 0 && expiresAt.After(now.Add(d.Max)) {
+		return time.Time{}, fmt.Errorf("invalid expiration: must be at most %s from now", formatDuration(d.Max))
+	}
+	return expiresAt, nil
+}
+
+// formatDuration writes whole days and hours in words and anything else as Go does
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return pluralize(int(d/(24*time.Hour)), "day")
+	case d >= time.Hour && d%time.Hour == 0:
+		return pluralize(int(d/time.Hour), "hour")
+	default:
+		return d.String()
+	}
+}
+
+func pluralize(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// MarketService handles market creation shared by the API and the bot
+type MarketService struct {
+	durations MarketDurations
+	describer *DescriptionService
+}
+
+// NewMarketService creates a new market service with the duration limits and
+// description settings from the environment
+func NewMarketService() *MarketService {
+	return &MarketService{durations: LoadMarketDurations(), describer: GetDescriptionService()}
+}
+
+// checkDuplicate returns a *DuplicateMarketError if the creator already created this market
+func (s *MarketService) checkDuplicate(creator *storage.User, question, key string) error {
+	existing, err := storage.FindDuplicateMarket(creator.ID, question, key, DuplicateMarketWindow)
+	if err != nil {
+		return fmt.Errorf("failed to create market: %w", err)
+	}
+	if existing == nil {
+		return nil
+	}
+	logger.Debug(creator.TelegramID, "market_create_duplicate", fmt.Sprintf("market_id=%d key=%s", existing.ID, key))
+	return &DuplicateMarketError{Market: existing}
+}
+
+// CreateMarket validates and sanitizes the question and optional resolution criteria,
+// creates the market and announces it in the public channel. creator is the market creator.
+// expiresAt may be zero for manually or oracle-locked markets; they then stay open for at most
+// EventLockMaxDuration. Repeating a creation (same opts.IdempotencyKey, or the same question
+// within DuplicateMarketWindow) returns a *DuplicateMarketError instead of a second market.
+func (s *MarketService) CreateMarket(ctx context.Context, creator *storage.User, question string, expiresAt time.Time, criteria string, opts storage.MarketOptions) (*storage.Market, error) {
+	question, err := SanitizeQuestion(question)
+	if err != nil {
+		return nil, err
+	}
+
+	criteria, err = SanitizeCriteria(criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.IdempotencyKey = strings.TrimSpace(opts.IdempotencyKey)
+	if len(opts.IdempotencyKey) > maxIdempotencyKeyLength {
+		return nil, fmt.Errorf("invalid idempotency key: at most %d characters", maxIdempotencyKeyLength)
+	}
+	if err := s.checkDuplicate(creator, question, opts.IdempotencyKey); err != nil {
+		return nil, err
+	}
+
+	opts.Icon, err = NormalizeIcon(opts.Icon)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Icon == "" {
+		opts.Icon = DeriveIcon(question)
+	}
+
+	if opts.LockMode == "" {
+		opts.LockMode = storage.LockModeDeadline
+	}
+	if expiresAt.IsZero() {
+		if opts.LockMode == storage.LockModeDeadline {
+			return nil, fmt.Errorf("invalid expiration: a deadline is required unless the market is locked manually or by an oracle")
+		}
+		expiresAt = time.Now().Add(EventLockMaxDuration())
+	} else {
+		expiresAt, err = s.durations.Deadline(expiresAt, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	market, err := storage.CreateMarketWithOptions(creator.ID, question, expiresAt, opts)
+	if err != nil {
+		// A concurrent request with the same key may have won the race
+		if dupErr := s.checkDuplicate(creator, question, opts.IdempotencyKey); dupErr != nil {
+			return nil, dupErr
+		}
+		return nil, fmt.Errorf("failed to create market: %w", err)
+	}
+
+	if criteria != "" {
+		// The market already exists, so a failure is logged rather than reported as a failed creation
+		if err := storage.SetMarketResolutionCriteria(market.ID, criteria); err != nil {
+			logger.Debug(creator.TelegramID, "market_criteria_error", fmt.Sprintf("market_id=%d error=%v", market.ID, err))
+		}
+	}
+
+	if tags := ParseHashtags(question); len(tags) > 0 {
+		// Tags only help discovery, so a failure here does not undo the market
+		if err := storage.SetMarketTags(market.ID, tags); err != nil {
+			logger.Debug(creator.TelegramID, "market_tags_error", fmt.Sprintf("market_id=%d error=%v", market.ID, err))
+		}
+	}
+
+	logger.Debug(creator.TelegramID, "market_created", fmt.Sprintf("market_id=%d question=%s expires_at=%s blind=%t sealed=%t anonymous=%t lock_mode=%s pricing_mode=%s", market.ID, truncateString(question, 50), expiresAt.Format(time.RFC3339), opts.Blind, opts.Sealed, opts.Anonymous, opts.LockMode, market.PricingMode))
+
+	// Broadcast new market to public channel, with a preview of an allowlisted link if there is one.
+	// Group markets stay in their group. Generating the optional description can take a while,
+	// so it happens in the background first.
+	notifService := GetNotificationService()
+	if notifService != nil || s.describer.Enabled() {
+		go func() {
+			description := s.describe(creator, market.ID, market.Question)
+			if notifService == nil || market.GroupID != 0 {
+				return
+			}
+			preview := GetPreviewService().Lookup(context.Background(), market.Question)
+			announced := *market
+			announced.Question = notifService.channelQuestion(market.ID, market.Question)
+			creatorName := displayName(creator)
+			if market.Anonymous {
+				creatorName = storage.AnonymousCreatorName
+			}
+			notifService.PublishNewMarket(&announced, creatorName, preview, description)
+		}()
+	}
+
+	return market, nil
+}
+
+// describe generates and stores a market description, returning "" when disabled or on failure
+func (s *MarketService) describe(creator *storage.User, marketID int64, question string) string {
+	description, err := s.describer.Describe(context.Background(), question)
+	if err != nil {
+		logger.Debug(creator.TelegramID, "market_describe_error", fmt.Sprintf("market_id=%d error=%v", marketID, err))
+		return ""
+	}
+	if description == nil {
+		return ""
+	}
+	text := description.Text()
+	if err := storage.SetMarketDescription(marketID, text); err != nil {
+		logger.Debug(creator.TelegramID, "market_describe_error", fmt.Sprintf("market_id=%d error=%v", marketID, err))
+	}
+	return text
+}
+
+// LockMarket locks a market that waits for a lock signal: its creator locks a manual market,
+// an oracle locks an oracle market. oracle reports whether actor may send oracle signals.
+// The creator is told to resolve a market an oracle locked, and its bettors get a summary of their positions.
+func (s *MarketService) LockMarket(actor *storage.User, marketID int64, oracle bool) (*storage.Market, error) {
+	market, err := storage.GetMarketByID(marketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+	if market == nil || market.Hidden {
+		return nil, fmt.Errorf("market not found")
+	}
+
+	switch market.LockMode {
+	case storage.LockModeManual:
+		if market.CreatorID != actor.ID {
+			return nil, fmt.Errorf("only the market creator can lock this market")
+		}
+	case storage.LockModeOracle:
+		if !oracle {
+			return nil, fmt.Errorf("only an oracle can lock this market")
+		}
+	default:
+		return nil, fmt.Errorf("market locks at its deadline and cannot be locked early")
+	}
+
+	locked, err := storage.LockMarket(marketID)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, fmt.Errorf("market is not active: status is %s", market.Status)
+	}
+	market.Status = storage.MarketStatusLocked
+
+	logger.Debug(actor.TelegramID, "market_locked", fmt.Sprintf("market_id=%d lock_mode=%s", marketID, market.LockMode))
+
+	notifier := defaultNotifier()
+	go func() {
+		if market.CreatorID != actor.ID {
+			notifier.Emit(DeadlineReached{Market: market})
+		}
+		notifier.Emit(MarketLocked{Market: market})
+	}()
+	return market, nil
+}
+
+// SetTranslation stores the creator's translation of a market question. Only the creator may
+// translate, and only while the market is active, so wording can't shift under settled bets.
+func (s *MarketService) SetTranslation(actor *storage.User, marketID int64, lang, question string) (*storage.MarketTranslation, error) {
+	market, err := storage.GetMarketByID(marketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+	if market == nil || market.Hidden {
+		return nil, fmt.Errorf("market not found")
+	}
+	if market.CreatorID != actor.ID {
+		return nil, fmt.Errorf("only the market creator can add translations")
+	}
+	if market.Status != storage.MarketStatusActive && market.Status != storage.MarketStatusLastCall {
+		return nil, fmt.Errorf("market is not active: status is %s", market.Status)
+	}
+
+	lang, question, err = SanitizeTranslation(lang, question)
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.SetMarketTranslation(marketID, lang, question, storage.TranslationCreator); err != nil {
+		return nil, err
+	}
+
+	logger.Debug(actor.TelegramID, "market_translated", fmt.Sprintf("market_id=%d lang=%s", marketID, lang))
+	return &storage.MarketTranslation{Lang: lang, Question: question, Source: storage.TranslationCreator}, nil
+}
+
+// MergeMarkets merges a duplicate market (sourceID) into the surviving market (targetID).
+// Both markets must ask the same question. Affected bettors are notified after the merge.
+// actorID is the internal ID of the admin performing the merge.
+func (s *MarketService) MergeMarkets(ctx context.Context, sourceID, targetID, actorID int64) (*storage.MergeResult, error) {
+	source, err := storage.GetMarketByID(sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+	target, err := storage.GetMarketByID(targetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+	if source == nil || target == nil {
+		return nil, fmt.Errorf("market not found")
+	}
+
+	if !strings.EqualFold(SanitizeText(source.Question), SanitizeText(target.Question)) {
+		return nil, fmt.Errorf("invalid merge: markets #%d and #%d ask different questions", sourceID, targetID)
+	}
+
+	result, err := storage.MergeMarkets(ctx, sourceID, targetID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug(actorID, "markets_merged", fmt.Sprintf("source_id=%d target_id=%d moved=%d refunded=%d", sourceID, targetID, len(result.Moved), len(result.Refunded)))
+
+	if notifService := GetNotificationService(); notifService != nil {
+		go func() {
+			// One message per bettor, summing all of their affected bets
+			moved := make(map[int64]int64)
+			refunded := make(map[int64]int64)
+			for _, b := range result.Moved {
+				moved[b.UserID] += b.Amount
+			}
+			for _, b := range result.Refunded {
+				refunded[b.UserID] += b.Amount
+			}
+
+			notified := make(map[int64]bool)
+			for _, b := range append(result.Moved, result.Refunded...) {
+				if notified[b.UserID] {
+					continue
+				}
+				notified[b.UserID] = true
+				notifService.SendMergeNotification(b.UserID, source, target, moved[b.UserID], refunded[b.UserID])
+			}
+		}()
+	}
+
+	return result, nil
+}
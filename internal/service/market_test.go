@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestMarketDurationsDeadline(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	d := MarketDurations{Min: time.Hour, Max: 30 * 24 * time.Hour, Align: 5 * time.Minute}
+
+	tests := []struct {
+		name    string
+		in      time.Time
+		want    time.Time
+		wantErr string
+	}{
+		{name: "aligned already", in: now.Add(2 * time.Hour), want: now.Add(2 * time.Hour)},
+		{name: "rounded up", in: now.Add(2*time.Hour + 61*time.Second), want: now.Add(2*time.Hour + 5*time.Minute)},
+		{name: "rounding keeps the minimum", in: now.Add(59 * time.Minute), want: now.Add(time.Hour)},
+		{name: "too soon", in: now.Add(30 * time.Minute), wantErr: "at least 1 hour"},
+		{name: "too far", in: now.Add(31 * 24 * time.Hour), wantErr: "at most 30 days"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.Deadline(tt.in, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), "invalid") {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("Deadline() = %s, %v; want %s", got, err, tt.want)
+			}
+		})
+	}
+
+	// Without a maximum any far deadline is accepted
+	if _, err := (MarketDurations{Min: time.Hour}).Deadline(now.Add(10*365*24*time.Hour), now); err != nil {
+		t.Errorf("Expected no upper limit, got %v", err)
+	}
+}
+
+func TestLoadMarketDurations(t *testing.T) {
+	t.Setenv("MARKET_MIN_DURATION", "30m")
+	t.Setenv("MARKET_MAX_DURATION", "0")
+	t.Setenv("MARKET_DEADLINE_ALIGN", "five")
+
+	d := LoadMarketDurations()
+	if d.Min != 30*time.Minute || d.Max != 0 || d.Align != 0 {
+		t.Errorf("Unexpected durations %+v", d)
+	}
+}
+
+func TestCreateMarketDuplicate(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	s := NewMarketService()
+	creator, _ := storage.CreateUser(7501, "creator", "Creator")
+	other, _ := storage.CreateUser(7502, "other", "Other")
+	expiresAt := time.Now().Add(48 * time.Hour)
+
+	first, err := s.CreateMarket(ctx, creator, "Will the retry create a twin?", expiresAt, "", storage.MarketOptions{IdempotencyKey: "key-1"})
+	if err != nil {
+		t.Fatalf("CreateMarket failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		creator  *storage.User
+		question string
+		key      string
+		wantDup  bool
+	}{
+		{"same key, edited question", creator, "Will the edited retry create a twin?", "key-1", true},
+		{"same question within the window", creator, "Will the retry create a twin?", "", true},
+		{"same question, new key", creator, "Will the retry create a twin?", "key-2", true},
+		{"same key, other creator", other, "Will the retry create a twin?", "key-1", false},
+		{"new question, new key", creator, "Will a new question create a market?", "key-3", false},
+	}
+	for _, tt := range tests {
+		market, err := s.CreateMarket(ctx, tt.creator, tt.question, expiresAt, "", storage.MarketOptions{IdempotencyKey: tt.key})
+		var duplicate *DuplicateMarketError
+		if got := errors.As(err, &duplicate); got != tt.wantDup {
+			t.Errorf("%s: expected duplicate %t, got market %v, error %v", tt.name, tt.wantDup, market, err)
+			continue
+		}
+		if tt.wantDup && duplicate.Market.ID != first.ID {
+			t.Errorf("%s: expected market %d, got %d", tt.name, first.ID, duplicate.Market.ID)
+		}
+	}
+
+	if _, err := s.CreateMarket(ctx, creator, "Will this key be too long?", expiresAt, "", storage.MarketOptions{IdempotencyKey: strings.Repeat("k", 65)}); err == nil {
+		t.Error("Expected an error for a 65-character key")
+	}
+}
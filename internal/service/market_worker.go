@@ -15,52 +15,128 @@ import (
 // DefaultDisputeDelay is the default time to wait before auto-finalizing a resolved market
 const DefaultDisputeDelay = 24 * time.Hour
 
+// DefaultLastCallWindow is how long before expiry a market enters LAST_CALL
+const DefaultLastCallWindow = 5 * time.Minute
+
+// DefaultEventLockMaxDuration is the longest a manually or oracle-locked market stays open
+const DefaultEventLockMaxDuration = 30 * 24 * time.Hour
+
+// EventLockMaxDuration reads EVENT_LOCK_MAX_DAYS, the safety limit after which the worker locks
+// markets still waiting for a manual or oracle lock
+func EventLockMaxDuration() time.Duration {
+	if days, err := strconv.Atoi(os.Getenv("EVENT_LOCK_MAX_DAYS")); err == nil && days > 0 {
+		return time.Duration(days) * 24 * time.Hour
+	}
+	return DefaultEventLockMaxDuration
+}
+
+// DisputeDelay reads DISPUTE_DELAY_MINUTES, how long a resolved market stays open to disputes
+// before it is finalized
+func DisputeDelay() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("DISPUTE_DELAY_MINUTES")); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return DefaultDisputeDelay
+}
+
+// DefaultFinalizeFailureLimit is how many times the worker tries to finalize a market before it
+// gives up and moves the market to NEEDS_ATTENTION
+const DefaultFinalizeFailureLimit = 3
+
+// LoadFinalizeFailureLimit reads FINALIZE_FAILURE_LIMIT; 0 keeps retrying forever
+func LoadFinalizeFailureLimit() int {
+	if limit, err := strconv.Atoi(os.Getenv("FINALIZE_FAILURE_LIMIT")); err == nil && limit >= 0 {
+		return limit
+	}
+	return DefaultFinalizeFailureLimit
+}
+
 // MarketWorker handles background tasks for markets
 type MarketWorker struct {
-	ctx                 context.Context
-	cancel              context.CancelFunc
-	ticker              *time.Ticker
-	disputeDelay        time.Duration
-	notificationService *NotificationService
+	ctx          context.Context
+	cancel       context.CancelFunc
+	ticker       *time.Ticker
+	disputeDelay time.Duration
+	lastCall     time.Duration
+	eventLockMax time.Duration
+	// finalizeFailureLimit is how many failed finalizations put a market in NEEDS_ATTENTION
+	finalizeFailureLimit int
+	// coldStart is how long a market may go without bets before its creator is nudged
+	coldStart time.Duration
+	// coldStartGroups also nudges the group chat of group markets
+	coldStartGroups bool
+	// oddsSwing is how many points of implied YES probability trigger a bettor's odds swing DM
+	oddsSwing float64
+	notifier  Notifier
 }
 
-// NewMarketWorker creates a new market worker
-func NewMarketWorker() *MarketWorker {
+// NewMarketWorker creates a new market worker that sends deadline and payout events to notifier.
+// A nil notifier falls back to a no-op.
+func NewMarketWorker(notifier Notifier) *MarketWorker {
+	if notifier == nil {
+		notifier = NoopNotifier{}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Get configurable dispute delay from environment (for testing, can be set to 1 minute)
-	disputeDelayStr := os.Getenv("DISPUTE_DELAY_MINUTES")
-	disputeDelay := DefaultDisputeDelay
-	if disputeDelayStr != "" {
-		if minutes, err := strconv.Atoi(disputeDelayStr); err == nil && minutes > 0 {
-			disputeDelay = time.Duration(minutes) * time.Minute
-			logger.Debug(0, "market_worker_config", fmt.Sprintf("dispute_delay=%d minutes", minutes))
+	disputeDelay := DisputeDelay()
+	if disputeDelay != DefaultDisputeDelay {
+		logger.Debug(0, "market_worker_config", fmt.Sprintf("dispute_delay=%d minutes", int(disputeDelay/time.Minute)))
+	}
+
+	// Last call window before expiry; 0 disables the LAST_CALL state
+	lastCall := DefaultLastCallWindow
+	if lastCallStr := os.Getenv("LAST_CALL_MINUTES"); lastCallStr != "" {
+		if minutes, err := strconv.Atoi(lastCallStr); err == nil && minutes >= 0 {
+			lastCall = time.Duration(minutes) * time.Minute
+			logger.Debug(0, "market_worker_config", fmt.Sprintf("last_call=%d minutes", minutes))
 		}
 	}
 
 	return &MarketWorker{
-		ctx:          ctx,
-		cancel:       cancel,
-		ticker:       time.NewTicker(1 * time.Minute),
-		disputeDelay: disputeDelay,
+		ctx:                  ctx,
+		cancel:               cancel,
+		ticker:               time.NewTicker(1 * time.Minute),
+		disputeDelay:         disputeDelay,
+		lastCall:             lastCall,
+		eventLockMax:         EventLockMaxDuration(),
+		finalizeFailureLimit: LoadFinalizeFailureLimit(),
+		coldStart:            LoadColdStartDelay(),
+		coldStartGroups:      ColdStartGroupNudges(),
+		oddsSwing:            LoadOddsSwingPoints(),
+		notifier:             notifier,
 	}
 }
 
 // Start begins the background worker
 func (w *MarketWorker) Start() {
-	logger.Debug(0, "market_worker_started", fmt.Sprintf("interval=1m dispute_delay=%v", w.disputeDelay))
+	logger.Debug(0, "market_worker_started", fmt.Sprintf("interval=1m dispute_delay=%v last_call=%v event_lock_max=%v finalize_failure_limit=%d cold_start=%v odds_swing=%.1f", w.disputeDelay, w.lastCall, w.eventLockMax, w.finalizeFailureLimit, w.coldStart, w.oddsSwing))
 
 	// Run immediately on start
+	w.startLastCalls()
 	w.lockExpiredMarkets()
+	w.closeProposals()
 	w.autoFinalizeResolvedMarkets()
+	w.sendDigests()
+	w.snapshotBalances()
+	w.checkOddsSwings()
+	w.nudgeColdStartMarkets()
 
 	// Then run on ticker
 	go func() {
 		for {
 			select {
 			case <-w.ticker.C:
+				w.startLastCalls()
 				w.lockExpiredMarkets()
+				w.closeProposals()
 				w.autoFinalizeResolvedMarkets()
+				w.sendDigests()
+				w.snapshotBalances()
+				w.checkOddsSwings()
+				w.nudgeColdStartMarkets()
+				w.nudgeColdStartMarkets()
 			case <-w.ctx.Done():
 				logger.Debug(0, "market_worker_stopped", "")
 				return
@@ -75,12 +151,48 @@ func (w *MarketWorker) Stop() {
 	w.cancel()
 }
 
-// SetNotificationService sets the notification service for payout notifications
-func (w *MarketWorker) SetNotificationService(ns *NotificationService) {
-	w.notificationService = ns
+// fail logs a failed worker task and raises it to the admin alert integrations
+func (w *MarketWorker) fail(task string, marketID int64, err error) {
+	if marketID != 0 {
+		logger.Debug(0, "market_worker_"+task+"_failed", fmt.Sprintf("market_id=%d error=%s", marketID, err.Error()))
+	} else {
+		logger.Debug(0, "market_worker_"+task+"_failed", fmt.Sprintf("error=%s", err.Error()))
+	}
+	w.notifier.Emit(WorkerFailed{Task: task, MarketID: marketID, Error: err.Error()})
+}
+
+// startLastCalls moves markets about to expire into LAST_CALL and announces the cutoff.
+// Bets are still accepted, but the public pools and odds stay frozen until the market locks.
+func (w *MarketWorker) startLastCalls() {
+	if w.lastCall <= 0 {
+		return
+	}
+
+	markets, err := storage.StartLastCalls(w.lastCall)
+	if err != nil {
+		w.fail("last_call", 0, err)
+	}
+	if len(markets) == 0 {
+		return
+	}
+
+	logger.Debug(0, "market_worker_last_calls", fmt.Sprintf("count=%d", len(markets)))
+
+	for _, market := range markets {
+		if market.Hidden {
+			continue
+		}
+		pools, err := storage.GetPublicPools(market.ID)
+		if err != nil {
+			w.fail("last_call", market.ID, err)
+			continue
+		}
+		w.notifier.Emit(LastCall{Market: market, Pools: pools})
+	}
 }
 
-// lockExpiredMarkets finds and locks all expired active markets
+// lockExpiredMarkets finds and locks all expired active markets, including those in their last call
+// and manually or oracle-locked markets that outlived the event lock limit
 func (w *MarketWorker) lockExpiredMarkets() {
 	db := storage.DB()
 	if db == nil {
@@ -91,7 +203,7 @@ func (w *MarketWorker) lockExpiredMarkets() {
 	// First, get the locked markets with their details before updating
 	lockedMarkets, err := w.getExpiredMarkets()
 	if err != nil {
-		logger.Debug(0, "market_worker_query_failed", fmt.Sprintf("error=%s", err.Error()))
+		w.fail("query", 0, err)
 		return
 	}
 
@@ -99,45 +211,32 @@ func (w *MarketWorker) lockExpiredMarkets() {
 		return // No markets to lock
 	}
 
-	// Update markets to LOCKED status
-	marketIDs := make([]int64, len(lockedMarkets))
-	for i, m := range lockedMarkets {
-		marketIDs[i] = m.ID
-	}
-
-	placeholders := "?"
-	for i := 1; i < len(marketIDs); i++ {
-		placeholders += ", ?"
-	}
-
-	query := fmt.Sprintf(`
-		UPDATE markets
-		SET status = 'LOCKED'
-		WHERE id IN (%s)
-	`, placeholders)
-
-	args := make([]interface{}, len(marketIDs))
-	for i, id := range marketIDs {
-		args[i] = id
-	}
-
-	_, err = db.ExecContext(w.ctx, query, args...)
-	if err != nil {
-		logger.Debug(0, "market_worker_lock_failed", fmt.Sprintf("error=%s", err.Error()))
-		return
+	// Lock them one by one; a market locked or merged in the meantime is skipped
+	locked := lockedMarkets[:0]
+	for _, m := range lockedMarkets {
+		ok, err := storage.LockMarket(m.ID)
+		if err != nil {
+			w.fail("lock", m.ID, err)
+			continue
+		}
+		if ok {
+			m.Status = storage.MarketStatusLocked
+			locked = append(locked, m)
+		}
 	}
+	lockedMarkets = locked
 
 	logger.Debug(0, "market_worker_locked_markets", fmt.Sprintf("count=%d", len(lockedMarkets)))
 
-	// Send deadline notifications to market creators
-	if w.notificationService != nil {
-		for _, market := range lockedMarkets {
-			w.notificationService.NotifyMarketCreatorDeadline(market)
-		}
+	// Send deadline notifications to market creators and position summaries to bettors
+	for _, market := range lockedMarkets {
+		w.notifier.Emit(DeadlineReached{Market: market})
+		w.notifier.Emit(MarketLocked{Market: market})
 	}
 }
 
-// getExpiredMarkets returns markets that have expired but are still active
+// getExpiredMarkets returns markets that have expired (or, when they wait for a lock signal,
+// have been open longer than the event lock limit) but are still open for bets
 func (w *MarketWorker) getExpiredMarkets() ([]*storage.Market, error) {
 	db := storage.DB()
 	if db == nil {
@@ -147,9 +246,10 @@ func (w *MarketWorker) getExpiredMarkets() ([]*storage.Market, error) {
 	rows, err := db.QueryContext(w.ctx, `
 		SELECT id, creator_id, question, image_url, status, outcome, resolved_at, expires_at, created_at
 		FROM markets
-		WHERE status = 'ACTIVE'
-		AND expires_at < CURRENT_TIMESTAMP
-	`)
+		WHERE status IN ('ACTIVE', 'LAST_CALL')
+		AND (expires_at < CURRENT_TIMESTAMP
+		     OR (lock_mode != 'DEADLINE' AND created_at < ?))
+	`, time.Now().Add(-w.eventLockMax).UTC().Format("2006-01-02 15:04:05"))
 	if err != nil {
 		return nil, err
 	}
@@ -196,7 +296,72 @@ func (w *MarketWorker) getExpiredMarkets() ([]*storage.Market, error) {
 	return markets, nil
 }
 
-// autoFinalizeResolvedMarkets finds resolved markets past the dispute period and finalizes them
+// closeProposals settles community-proposed outcomes whose vote has ended
+func (w *MarketWorker) closeProposals() {
+	if storage.DB() == nil {
+		logger.Debug(0, "market_worker_no_db", "")
+		return
+	}
+	NewPayoutServiceWithNotifier(w.notifier).CloseProposals(w.ctx)
+}
+
+// sendDigests sends the personal and group digests that are due
+func (w *MarketWorker) sendDigests() {
+	if storage.DB() == nil {
+		logger.Debug(0, "market_worker_no_db", "")
+		return
+	}
+	now := time.Now()
+	if sent := SendDueDigests(w.notifier, now); sent > 0 {
+		logger.Debug(0, "market_worker_digests", fmt.Sprintf("count=%d", sent))
+	}
+	if posted := PostDueGroupDigests(w.notifier, now); posted > 0 {
+		logger.Debug(0, "market_worker_group_digests", fmt.Sprintf("count=%d", posted))
+	}
+}
+
+// snapshotBalances records every user's balance and rank once per UTC day, so the leaderboard
+// can show how users moved since yesterday
+func (w *MarketWorker) snapshotBalances() {
+	if storage.DB() == nil {
+		logger.Debug(0, "market_worker_no_db", "")
+		return
+	}
+	count, err := storage.TakeBalanceSnapshot(time.Now())
+	if err != nil {
+		w.fail("balance_snapshot", 0, err)
+		return
+	}
+	if count > 0 {
+		logger.Debug(0, "market_worker_balance_snapshot", fmt.Sprintf("users=%d", count))
+	}
+}
+
+// checkOddsSwings snapshots the pools of open markets and tells bettors whose market's odds
+// moved far enough since their bet
+func (w *MarketWorker) checkOddsSwings() {
+	if storage.DB() == nil {
+		logger.Debug(0, "market_worker_no_db", "")
+		return
+	}
+	if sent := SendOddsSwings(w.notifier, time.Now(), w.oddsSwing); sent > 0 {
+		logger.Debug(0, "market_worker_odds_swings", fmt.Sprintf("count=%d", sent))
+	}
+}
+
+// nudgeColdStartMarkets tells the creators of markets nobody has bet on yet to share them
+func (w *MarketWorker) nudgeColdStartMarkets() {
+	if storage.DB() == nil {
+		logger.Debug(0, "market_worker_no_db", "")
+		return
+	}
+	if nudged := SendColdStartNudges(w.notifier, time.Now(), w.coldStart, w.coldStartGroups); nudged > 0 {
+		logger.Debug(0, "market_worker_cold_start_nudges", fmt.Sprintf("count=%d", nudged))
+	}
+}
+
+// autoFinalizeResolvedMarkets finds resolved markets past the dispute period and finalizes them.
+// Failures are counted per market; a market that keeps failing is left for the admin.
 func (w *MarketWorker) autoFinalizeResolvedMarkets() {
 	db := storage.DB()
 	if db == nil {
@@ -207,7 +372,7 @@ func (w *MarketWorker) autoFinalizeResolvedMarkets() {
 	// Get markets that are resolved and past the dispute period
 	marketIDs, err := storage.GetMarketsPendingFinalization(w.disputeDelay)
 	if err != nil {
-		logger.Debug(0, "market_worker_pending_query_failed", fmt.Sprintf("error=%s", err.Error()))
+		w.fail("pending_query", 0, err)
 		return
 	}
 
@@ -217,18 +382,36 @@ func (w *MarketWorker) autoFinalizeResolvedMarkets() {
 
 	logger.Debug(0, "market_worker_auto_finalize", fmt.Sprintf("count=%d", len(marketIDs)))
 
-	payoutService := NewPayoutService()
-	if w.notificationService != nil {
-		payoutService.SetNotificationService(w.notificationService)
-	}
+	payoutService := NewPayoutServiceWithNotifier(w.notifier)
 
 	// Finalize each market
 	for _, marketID := range marketIDs {
 		payoutsProcessed, err := payoutService.FinalizeMarket(w.ctx, marketID, "")
 		if err != nil {
-			logger.Debug(0, "market_worker_finalize_failed", fmt.Sprintf("market_id=%d error=%s", marketID, err.Error()))
+			w.fail("finalize", marketID, err)
+			w.recordFinalizeFailure(marketID, err)
 			continue
 		}
 		logger.Debug(0, "market_worker_finalized", fmt.Sprintf("market_id=%d payouts=%d", marketID, payoutsProcessed))
 	}
 }
+
+// recordFinalizeFailure counts a failed finalization and alerts the admin once the market is
+// moved to NEEDS_ATTENTION
+func (w *MarketWorker) recordFinalizeFailure(marketID int64, finalizeErr error) {
+	failures, stuck, err := storage.RecordFinalizeFailure(w.ctx, marketID, finalizeErr.Error(), w.finalizeFailureLimit)
+	if err != nil {
+		w.fail("finalize_failure_count", marketID, err)
+		return
+	}
+	if !stuck {
+		return
+	}
+
+	logger.Debug(0, "market_worker_finalization_stuck", fmt.Sprintf("market_id=%d failures=%d", marketID, failures))
+	alert := FinalizationStuck{MarketID: marketID, Failures: failures, Error: finalizeErr.Error()}
+	if market, err := storage.GetMarketByID(marketID); err == nil && market != nil {
+		alert.Question = market.Question
+	}
+	w.notifier.Emit(alert)
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// Market maker markets are priced with Hanson's logarithmic market scoring rule (LMSR). With
+// liquidity b and q_yes, q_no shares outstanding the market maker's cost function is
+//
+//	C(q_yes, q_no) = b · ln(e^(q_yes/b) + e^(q_no/b))
+//
+// and a trade costs the change in C. The price of a YES share, e^(q_yes/b) / (e^(q_yes/b) +
+// e^(q_no/b)), is the market's probability of YES; YES and NO prices always add up to 1.
+// Shares are whole and each winning share pays out 1, so buyers pay the cost rounded up and
+// sellers receive it rounded down: the market maker never pays out fractions it didn't take in.
+
+// priceEpsilon absorbs floating point noise when rounding trade costs to whole amounts
+const priceEpsilon = 1e-9
+
+// lmsrCost is the market maker's cost function, computed stably with log-sum-exp
+func lmsrCost(b, qYes, qNo float64) float64 {
+	m := math.Max(qYes, qNo)
+	return m + b*math.Log(math.Exp((qYes-m)/b)+math.Exp((qNo-m)/b))
+}
+
+// MarketPrices returns the current price of a YES and a NO share, each between 0 and 1
+func MarketPrices(mm *storage.MarketMaker) (yes, no float64) {
+	b := float64(mm.Liquidity)
+	yes = 1 / (1 + math.Exp(float64(mm.SharesNo-mm.SharesYes)/b))
+	return yes, 1 - yes
+}
+
+// costAfter returns the market maker's cost before and after shares (negative to sell) of outcome change hands
+func costAfter(mm *storage.MarketMaker, outcome string, shares int64) (before, after float64) {
+	b := float64(mm.Liquidity)
+	qYes, qNo := float64(mm.SharesYes), float64(mm.SharesNo)
+	before = lmsrCost(b, qYes, qNo)
+	if outcome == string(storage.OutcomeYes) {
+		qYes += float64(shares)
+	} else {
+		qNo += float64(shares)
+	}
+	return before, lmsrCost(b, qYes, qNo)
+}
+
+// BuyCost returns what buying shares of outcome costs at the current prices
+func BuyCost(mm *storage.MarketMaker, outcome string, shares int64) int64 {
+	before, after := costAfter(mm, outcome, shares)
+	return int64(math.Ceil(after - before - priceEpsilon))
+}
+
+// SellProceeds returns what selling shares of outcome pays at the current prices
+func SellProceeds(mm *storage.MarketMaker, outcome string, shares int64) int64 {
+	before, after := costAfter(mm, outcome, -shares)
+	return int64(math.Floor(before - after + priceEpsilon))
+}
+
+// SharesForAmount returns the most shares of outcome that amount buys and what they cost
+func SharesForAmount(mm *storage.MarketMaker, outcome string, amount int64) (shares, cost int64) {
+	if amount <= 0 {
+		return 0, 0
+	}
+	b := float64(mm.Liquidity)
+	q, other := float64(mm.SharesYes), float64(mm.SharesNo)
+	if outcome != string(storage.OutcomeYes) {
+		q, other = other, q
+	}
+
+	// Solve C(q + s, other) = C(q, other) + amount for s:
+	// s = b · ln(e^(target/b) − e^(other/b)) − q, with target > other always
+	target := lmsrCost(b, q, other) + float64(amount)
+	exact := target + b*math.Log1p(-math.Exp((other-target)/b)) - q
+	shares = int64(math.Floor(exact + priceEpsilon))
+
+	// Rounding the cost up can put the last share out of reach
+	for shares > 0 {
+		if cost = BuyCost(mm, outcome, shares); cost <= amount {
+			return shares, cost
+		}
+		shares--
+	}
+	return 0, 0
+}
+
+// MarketQuote is a market maker market's live prices
+type MarketQuote struct {
+	MarketID  int64   `json:"market_id"`
+	Liquidity int64   `json:"liquidity"`
+	PriceYes  float64 `json:"price_yes"`
+	PriceNo   float64 `json:"price_no"`
+	SharesYes int64   `json:"shares_yes"`
+	SharesNo  int64   `json:"shares_no"`
+}
+
+// QuoteMarket returns the live prices of a market maker
+func QuoteMarket(mm *storage.MarketMaker) MarketQuote {
+	yes, no := MarketPrices(mm)
+	return MarketQuote{
+		MarketID:  mm.MarketID,
+		Liquidity: mm.Liquidity,
+		PriceYes:  yes,
+		PriceNo:   no,
+		SharesYes: mm.SharesYes,
+		SharesNo:  mm.SharesNo,
+	}
+}
+
+// TradeResult is a completed trade and the prices it left behind
+type TradeResult struct {
+	Trade storage.ShareTrade `json:"trade"`
+	Quote MarketQuote        `json:"quote"`
+}
+
+// MarketMakerService buys and sells shares on market maker markets
+type MarketMakerService struct{}
+
+// NewMarketMakerService creates a new market maker service
+func NewMarketMakerService() *MarketMakerService {
+	return &MarketMakerService{}
+}
+
+// Buy spends up to amount on shares of outcome for a user (internal ID). Only whole shares are
+// bought, so the price paid may be a little less than amount.
+func (s *MarketMakerService) Buy(ctx context.Context, userID, marketID int64, outcome string, amount int64) (*TradeResult, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("invalid amount: must be greater than 0")
+	}
+	return s.trade(ctx, userID, marketID, outcome, func(mm *storage.MarketMaker) (int64, int64, error) {
+		shares, cost := SharesForAmount(mm, outcome, amount)
+		if shares == 0 {
+			return 0, 0, fmt.Errorf("invalid amount: %s does not buy a whole share", formatBalance(amount))
+		}
+		return shares, cost, nil
+	})
+}
+
+// Sell sells shares of outcome the user (internal ID) holds back to the market maker
+func (s *MarketMakerService) Sell(ctx context.Context, userID, marketID int64, outcome string, shares int64) (*TradeResult, error) {
+	if shares <= 0 {
+		return nil, fmt.Errorf("invalid shares: must be greater than 0")
+	}
+	return s.trade(ctx, userID, marketID, outcome, func(mm *storage.MarketMaker) (int64, int64, error) {
+		return -shares, -SellProceeds(mm, outcome, shares), nil
+	})
+}
+
+// trade prices a trade against the market maker's current state and records it in one
+// transaction, so concurrent trades can't both buy at the same price. price returns the
+// signed shares and amount of the trade.
+func (s *MarketMakerService) trade(ctx context.Context, userID, marketID int64, outcome string, price func(*storage.MarketMaker) (int64, int64, error)) (*TradeResult, error) {
+	if outcome != string(storage.OutcomeYes) && outcome != string(storage.OutcomeNo) {
+		return nil, fmt.Errorf("invalid outcome: must be 'YES' or 'NO'")
+	}
+
+	db := storage.DB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	mm, err := storage.GetTradableMarketMakerTx(ctx, tx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	shares, amount, err := price(mm)
+	if err != nil {
+		return nil, err
+	}
+
+	trade, err := storage.RecordShareTradeTx(ctx, tx, storage.ShareTrade{MarketID: marketID, UserID: userID, Outcome: outcome, Shares: shares, Amount: amount})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if outcome == string(storage.OutcomeYes) {
+		mm.SharesYes += shares
+	} else {
+		mm.SharesNo += shares
+	}
+	result := &TradeResult{Trade: *trade, Quote: QuoteMarket(mm)}
+	logger.Debug(userID, "shares_traded", fmt.Sprintf("market_id=%d outcome=%s shares=%d amount=%d price_yes=%.4f", marketID, outcome, shares, amount, result.Quote.PriceYes))
+	return result, nil
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestMarketMakerPricing(t *testing.T) {
+	mm := &storage.MarketMaker{Liquidity: 100}
+
+	if yes, no := MarketPrices(mm); yes != 0.5 || no != 0.5 {
+		t.Errorf("Expected even prices on a new market, got %v / %v", yes, no)
+	}
+
+	// Buying 100 YES from even costs b · ln((e + 1) / 2) ≈ 62.01, rounded up
+	if cost := BuyCost(mm, "YES", 100); cost != 63 {
+		t.Errorf("Expected 100 shares to cost 63, got %d", cost)
+	}
+
+	// 101 shares cost ≈ 62.74, while 102 would cost 64
+	shares, cost := SharesForAmount(mm, "YES", 63)
+	if shares != 101 || cost != 63 {
+		t.Errorf("Expected 63 to buy 101 shares, got %d for %d", shares, cost)
+	}
+	if shares, _ := SharesForAmount(mm, "NO", 1000); shares <= 1000 {
+		t.Errorf("Expected shares below 1 each to buy more shares than their cost, got %d", shares)
+	}
+	if shares, _ := SharesForAmount(mm, "YES", 0); shares != 0 {
+		t.Errorf("Expected nothing for nothing, got %d shares", shares)
+	}
+
+	// Prices follow the outstanding shares and always add up to 1
+	mm.SharesYes = 100
+	yes, no := MarketPrices(mm)
+	if math.Abs(yes-0.7311) > 0.0001 || math.Abs(yes+no-1) > 1e-12 {
+		t.Errorf("Expected YES at 0.73 after 100 YES shares, got %v / %v", yes, no)
+	}
+
+	// Selling straight back refunds no more than was paid
+	if proceeds := SellProceeds(mm, "YES", 100); proceeds != 62 {
+		t.Errorf("Expected selling 100 shares to pay 62, got %d", proceeds)
+	}
+
+	// Large outstanding shares don't overflow the cost function
+	huge := &storage.MarketMaker{Liquidity: 10, SharesYes: 1_000_000}
+	if cost := BuyCost(huge, "YES", 10); cost != 10 {
+		t.Errorf("Expected near-certain shares to cost 1 each, got %d for 10", cost)
+	}
+}
+
+func TestMarketMakerTrading(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	s := NewMarketMakerService()
+
+	creator, _ := storage.CreateUser(11111, "creator", "Creator")
+	trader, _ := storage.CreateUser(22222, "trader", "Trader")
+	market, err := storage.CreateMarketWithOptions(creator.ID, "Will the market maker keep quoting?", time.Now().Add(time.Hour), storage.MarketOptions{PricingMode: storage.PricingMarketMaker})
+	if err != nil {
+		t.Fatalf("Failed to create market: %v", err)
+	}
+
+	bought, err := s.Buy(ctx, trader.ID, market.ID, "YES", 63)
+	if err != nil {
+		t.Fatalf("Buy failed: %v", err)
+	}
+	if bought.Trade.Shares != 101 || bought.Trade.Amount != 63 || bought.Quote.PriceYes <= 0.5 {
+		t.Errorf("Expected 101 shares for 63 and a higher YES price, got %+v", bought)
+	}
+
+	if _, err := s.Sell(ctx, trader.ID, market.ID, "YES", 102); err == nil || !strings.Contains(err.Error(), "insufficient shares") {
+		t.Errorf("Expected selling more than held to fail, got %v", err)
+	}
+	if _, err := s.Buy(ctx, trader.ID, market.ID, "NO", 5000); err == nil || !strings.Contains(err.Error(), "insufficient funds") {
+		t.Errorf("Expected buying beyond the balance to fail, got %v", err)
+	}
+
+	sold, err := s.Sell(ctx, trader.ID, market.ID, "YES", 40)
+	if err != nil {
+		t.Fatalf("Sell failed: %v", err)
+	}
+	if sold.Trade.Shares != -40 || sold.Trade.Amount >= 0 || sold.Quote.SharesYes != 61 {
+		t.Errorf("Expected 40 shares sold, got %+v", sold)
+	}
+
+	holding, _ := storage.GetShareHolding(market.ID, trader.ID)
+	user, _ := storage.GetUserByID(trader.ID)
+	if holding.SharesYes != 61 || user.Balance != storage.WelcomeBonusAmount-holding.Spent {
+		t.Errorf("Expected 61 shares paid for from the balance, got %+v and balance %d", holding, user.Balance)
+	}
+
+	// Parimutuel markets take bets, market maker markets don't
+	if err := storage.PlaceBet(ctx, trader.ID, market.ID, "YES", 10); err == nil {
+		t.Error("Expected a bet on a market maker market to be refused")
+	}
+	parimutuel, _ := storage.CreateMarket(creator.ID, "Will pools still work?", time.Now().Add(time.Hour))
+	if _, err := s.Buy(ctx, trader.ID, parimutuel.ID, "YES", 10); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Errorf("Expected buying shares on a parimutuel market to be refused, got %v", err)
+	}
+
+	if _, err := storage.CreateMarketWithOptions(creator.ID, "Will the blind maker work?", time.Now().Add(time.Hour), storage.MarketOptions{PricingMode: storage.PricingMarketMaker, Blind: true}); err == nil {
+		t.Error("Expected a blind market maker market to be refused")
+	}
+}
+
+func TestFinalizeMarketMakerMarket(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	recorder := NewRecordingNotifier()
+	payoutService := NewPayoutServiceWithNotifier(recorder)
+	s := NewMarketMakerService()
+
+	creator, _ := storage.CreateUser(11111, "creator", "Creator")
+	yes, _ := storage.CreateUser(22222, "yes", "Yes")
+	no, _ := storage.CreateUser(33333, "no", "No")
+	market, _ := storage.CreateMarketWithOptions(creator.ID, "Will the shares pay out?", time.Now().Add(time.Hour), storage.MarketOptions{PricingMode: storage.PricingMarketMaker})
+
+	bought, err := s.Buy(ctx, yes.ID, market.ID, "YES", 100)
+	if err != nil {
+		t.Fatalf("Buy failed: %v", err)
+	}
+	if _, err := s.Buy(ctx, no.ID, market.ID, "NO", 50); err != nil {
+		t.Fatalf("Buy failed: %v", err)
+	}
+
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	payouts, err := payoutService.FinalizeMarket(ctx, market.ID, "")
+	if err != nil {
+		t.Fatalf("FinalizeMarket failed: %v", err)
+	}
+	if payouts != 1 {
+		t.Errorf("Expected 1 payout, got %d", payouts)
+	}
+
+	winner, _ := storage.GetUserByID(yes.ID)
+	if want := storage.WelcomeBonusAmount - bought.Trade.Amount + bought.Trade.Shares; winner.Balance != want {
+		t.Errorf("Expected each winning share to pay 1 (balance %d), got %d", want, winner.Balance)
+	}
+	loser, _ := storage.GetUserByID(no.ID)
+	if loser.Balance >= storage.WelcomeBonusAmount {
+		t.Errorf("Expected the NO shares to expire worthless, got balance %d", loser.Balance)
+	}
+
+	var won, lost bool
+	for _, event := range recorder.WaitFor(3, time.Second) {
+		switch e := event.(type) {
+		case WinNotice:
+			won = e.UserID == yes.ID && e.Payout == bought.Trade.Shares
+		case LossNotice:
+			lost = e.UserID == no.ID
+		case RefundNotice:
+			t.Errorf("Expected no refunds, got %+v", e)
+		}
+	}
+	if !won || !lost {
+		t.Errorf("Expected a win and a loss notice, got win=%t loss=%t", won, lost)
+	}
+}
+
+func TestMarketMakerCreatesNoMoney(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	payoutService := NewPayoutService()
+	s := NewMarketMakerService()
+	totalBalance := func() int64 {
+		var total int64
+		storage.DB().QueryRow(`SELECT SUM(balance) FROM users`).Scan(&total)
+		return total
+	}
+
+	creator, _ := storage.CreateUser(11112, "creator", "Creator")
+	other, _ := storage.CreateUser(22223, "other", "Other")
+	opts := storage.MarketOptions{PricingMode: storage.PricingMarketMaker, Liquidity: storage.MaxLiquidity}
+	if _, err := storage.CreateMarketWithOptions(creator.ID, "Can I afford the subsidy?", time.Now().Add(time.Hour), opts); err == nil || !strings.Contains(err.Error(), "insufficient funds") {
+		t.Fatalf("Expected the subsidy to need funds, got %v", err)
+	}
+
+	storage.DB().Exec(`UPDATE users SET balance = 20000 WHERE id = ?`, creator.ID)
+	before := totalBalance()
+	market, err := storage.CreateMarketWithOptions(creator.ID, "Will the creator mint money?", time.Now().Add(time.Hour), opts)
+	if err != nil {
+		t.Fatalf("CreateMarketWithOptions failed: %v", err)
+	}
+	subsidy := storage.MarketMakerSubsidy(storage.MaxLiquidity)
+	if funded, _ := storage.GetUserByID(creator.ID); funded.Balance != 20000-subsidy {
+		t.Errorf("Expected the creator to pay the %d subsidy, got balance %d", subsidy, funded.Balance)
+	}
+
+	// The creator buys cheap YES shares on their own deep market and resolves YES
+	bought, err := s.Buy(ctx, creator.ID, market.ID, "YES", 1000)
+	if err != nil {
+		t.Fatalf("Buy failed: %v", err)
+	}
+	if bought.Trade.Shares <= 1000 {
+		t.Fatalf("Expected the subsidy to make YES cheap, got %d shares for 1000", bought.Trade.Shares)
+	}
+	if _, err := s.Buy(ctx, other.ID, market.ID, "NO", 200); err != nil {
+		t.Fatalf("Buy failed: %v", err)
+	}
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+	if _, err := payoutService.FinalizeMarket(ctx, market.ID, ""); err != nil {
+		t.Fatalf("FinalizeMarket failed: %v", err)
+	}
+
+	if after := totalBalance(); after != before {
+		t.Errorf("Expected no money to be created, total balance went from %d to %d", before, after)
+	}
+	creatorAfter, _ := storage.GetUserByID(creator.ID)
+	otherAfter, _ := storage.GetUserByID(other.ID)
+	if creatorAfter.Balance-20000 != storage.WelcomeBonusAmount-otherAfter.Balance {
+		t.Errorf("Expected the creator to gain only what the other trader lost, got %d and %d", creatorAfter.Balance-20000, storage.WelcomeBonusAmount-otherAfter.Balance)
+	}
+}
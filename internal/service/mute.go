@@ -0,0 +1,61 @@
+package service
+
+import (
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// dmMuted reports whether a user (internal ID) muted their DMs with /mute
+func dmMuted(userID int64) bool {
+	muted, err := storage.IsNotificationsMuted(userID)
+	if err != nil {
+		logger.Debug(userID, "notification_error", "failed to read mute setting: "+err.Error())
+		return false
+	}
+	return muted
+}
+
+// mutedRecipient returns the user (internal ID) a DM event is for when it can be muted. Payouts,
+// refunds, moved bets and account merge codes always go out; so do channel posts, admin alerts
+// and DMs to several users, whose recipients are filtered when they are listed.
+func mutedRecipient(event NotificationEvent) (int64, bool) {
+	switch e := event.(type) {
+	case LossNotice:
+		return e.UserID, true
+	case StreakNotice:
+		return e.UserID, true
+	case BetReceipt:
+		return e.UserID, true
+	case OddsSwing:
+		return e.UserID, true
+	case DailyDigest:
+		return e.UserID, true
+	case DeadlineReached:
+		if e.Market != nil {
+			return e.Market.CreatorID, true
+		}
+	case ColdStartNudge:
+		if e.Market != nil {
+			return e.Market.CreatorID, true
+		}
+	case DisputeCreatorNotice:
+		if e.Market != nil {
+			return e.Market.CreatorID, true
+		}
+	case CosignDecided:
+		if e.Cosign != nil {
+			return e.Cosign.ProposedBy, true
+		}
+	}
+	return 0, false
+}
+
+// isMutedDM reports whether an event is a DM its recipient muted, and logs the ones it drops
+func isMutedDM(event NotificationEvent) bool {
+	userID, ok := mutedRecipient(event)
+	if !ok || !dmMuted(userID) {
+		return false
+	}
+	logger.Debug(userID, "notification_muted", "kind="+event.Kind())
+	return true
+}
@@ -1,12 +1,15 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"predictionbot/internal/logger"
 	"predictionbot/internal/storage"
@@ -33,6 +36,8 @@ type NotificationService struct {
 	mu        sync.Mutex
 	adminID   int64
 	channelID string
+	// channelLanguage is the language of channel posts, "" for the language questions are written in
+	channelLanguage string
 }
 
 // NewNotificationService creates a new notification service
@@ -56,19 +61,115 @@ func NewNotificationService() (*NotificationService, error) {
 		adminID, _ = strconv.ParseInt(adminIDStr, 10, 64)
 	}
 
-	// Get channel ID from environment
+	// Get channel ID and language from environment
 	channelID := os.Getenv("CHANNEL_ID")
+	channelLanguage := NormalizeLanguage(os.Getenv("CHANNEL_LANGUAGE"))
 
 	return &NotificationService{
-		bot:       b,
-		adminID:   adminID,
-		channelID: channelID,
+		bot:             b,
+		adminID:         adminID,
+		channelID:       channelID,
+		channelLanguage: channelLanguage,
 	}, nil
 }
 
-// formatBalance formats balance as WSC
+// channelQuestion returns a question in the channel's language, led by the market's icon
+func (s *NotificationService) channelQuestion(marketID int64, question string) string {
+	icon, _ := storage.GetMarketIcon(marketID)
+	return withIcon(icon, GetTranslationService().Question(context.Background(), marketID, question, s.channelLanguage))
+}
+
+// userQuestion returns a question in the preferred language of a user (internal ID)
+func userQuestion(userID, marketID int64, question string) string {
+	lang, err := storage.GetUserLanguage(userID)
+	if err != nil || lang == "" {
+		return question
+	}
+	return GetTranslationService().Question(context.Background(), marketID, question, lang)
+}
+
+// formatBalance labels a balance with the configured currency
 func formatBalance(balance int64) string {
-	return fmt.Sprintf("%d WSC", balance)
+	return FormatAmount(balance)
+}
+
+// NotifyBettorsMarketLocked sends every bettor on a just-locked market who wants it a summary of
+// their position, in batches of lockSummaryBatchSize
+func (s *NotificationService) NotifyBettorsMarketLocked(market *storage.Market) {
+	if market == nil {
+		return
+	}
+
+	positions, err := storage.ListLockSummaryPositions(market.ID)
+	if err != nil {
+		logger.Debug(0, "notification_error", fmt.Sprintf("failed to list positions for market %d: %v", market.ID, err))
+		return
+	}
+	if len(positions) == 0 {
+		return
+	}
+	pools, err := storage.GetPublicPools(market.ID)
+	if err != nil {
+		logger.Debug(0, "notification_error", fmt.Sprintf("failed to get pools for market %d: %v", market.ID, err))
+		return
+	}
+
+	lockedAt := time.Now()
+	for start := 0; start < len(positions); start += lockSummaryBatchSize {
+		if start > 0 {
+			time.Sleep(lockSummaryBatchPause)
+		}
+		end := start + lockSummaryBatchSize
+		if end > len(positions) {
+			end = len(positions)
+		}
+
+		s.mu.Lock()
+		for _, position := range positions[start:end] {
+			translated := *market
+			translated.Question = userQuestion(position.UserID, market.ID, market.Question)
+			message := LockSummaryText(&translated, position, pools, lockedAt)
+			if _, err := s.sendDM(position.TelegramID, message); err != nil {
+				logger.Debug(position.UserID, "notification_error", fmt.Sprintf("failed to send lock summary: %v", err))
+			}
+		}
+		s.mu.Unlock()
+	}
+	logger.Debug(0, "lock_summaries_sent", fmt.Sprintf("market_id=%d count=%d", market.ID, len(positions)))
+}
+
+// SendBetReceipt confirms a bet to the bettor with the odds and potential payout after it
+func (s *NotificationService) SendBetReceipt(receipt BetReceipt) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := storage.GetUserByID(receipt.UserID)
+	if err != nil || user == nil {
+		logger.Debug(receipt.UserID, "notification_error", "failed to get user for bet receipt")
+		return
+	}
+
+	var odds string
+	switch {
+	case receipt.Pools.PoolsHidden:
+		odds = "Odds: hidden until the market locks"
+	case receipt.Pools.SidesHidden:
+		odds = fmt.Sprintf("Total pool: %s\nOdds: hidden until the market is finalized", formatBalance(receipt.Pools.Total))
+	default:
+		odds = fmt.Sprintf("Odds now: YES %.0f%% / NO %.0f%%\nPotential payout: %s", receipt.ImpliedYes(), 100-receipt.ImpliedYes(), formatBalance(receipt.PotentialPayout()))
+	}
+
+	message := fmt.Sprintf("🧾 Bet placed on market #%d\n\n📝 %s\n\nYour bet: %s on %s\n%s\nNew Balance: %s",
+		receipt.MarketID,
+		withIcon(receipt.Icon, truncateString(receipt.Question, 50)),
+		formatBalance(receipt.Amount),
+		receipt.Outcome,
+		odds,
+		formatBalance(receipt.NewBalance))
+
+	if _, err := s.sendDM(user.TelegramID, message); err != nil {
+		logger.Debug(receipt.UserID, "notification_error", fmt.Sprintf("failed to send bet receipt: %v", err))
+	}
 }
 
 // SendWinNotification sends a notification to a user when they win
@@ -94,7 +195,7 @@ func (s *NotificationService) SendWinNotification(userID int64, marketID int64,
 		formatBalance(profit),
 		formatBalance(newBalance))
 
-	_, err = s.bot.Send(&telebot.User{ID: user.TelegramID}, message)
+	_, err = s.sendDM(user.TelegramID, message)
 	if err != nil {
 		logger.Debug(userID, "notification_error", fmt.Sprintf("failed to send win notification: %v", err))
 		log.Printf("Failed to send win notification to user %d: %v", user.TelegramID, err)
@@ -120,7 +221,7 @@ func (s *NotificationService) SendRefundNotification(userID int64, marketID int6
 		truncateString(question, 50),
 		formatBalance(newBalance))
 
-	_, err = s.bot.Send(&telebot.User{ID: user.TelegramID}, message)
+	_, err = s.sendDM(user.TelegramID, message)
 	if err != nil {
 		logger.Debug(userID, "notification_error", fmt.Sprintf("failed to send refund notification: %v", err))
 		log.Printf("Failed to send refund notification to user %d: %v", user.TelegramID, err)
@@ -128,7 +229,8 @@ func (s *NotificationService) SendRefundNotification(userID int64, marketID int6
 }
 
 // SendDisputeAlert sends an alert to the admin when a dispute is raised
-func (s *NotificationService) SendDisputeAlert(marketID int64, question string, disputeUserID int64) {
+func (s *NotificationService) SendDisputeAlert(alert DisputeAlert) {
+	marketID, disputeUserID := alert.MarketID, alert.DisputedBy
 	if s.adminID == 0 {
 		log.Printf("Admin ID not set, skipping dispute alert for market #%d", marketID)
 		return
@@ -137,12 +239,23 @@ func (s *NotificationService) SendDisputeAlert(marketID int64, question string,
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	message := fmt.Sprintf("⚠️ Dispute Raised!\n\nMarket ID: #%d\nQuestion: %s\nDisputed by user ID: %d\n\nUse /resolve_disputes to review and resolve.",
+	disputer := fmt.Sprintf("user ID %d", disputeUserID)
+	if alert.DisputerName != "" {
+		disputer = fmt.Sprintf("%s (user ID %d)", alert.DisputerName, disputeUserID)
+	}
+	message := fmt.Sprintf("⚠️ Dispute Raised!\n\nMarket ID: #%d\nQuestion: %s\nDisputed by: %s",
 		marketID,
-		truncateString(question, 100),
-		disputeUserID)
+		truncateString(alert.Question, 100),
+		disputer)
+	if alert.Reason != "" {
+		message += "\nReason: " + alert.Reason
+	}
+	if alert.EvidenceURL != "" {
+		message += "\nEvidence: " + alert.EvidenceURL
+	}
+	message += "\n\nUse /resolve_disputes to review and resolve."
 
-	_, err := s.bot.Send(&telebot.User{ID: s.adminID}, message)
+	_, err := s.sendDM(s.adminID, message)
 	if err != nil {
 		logger.Debug(disputeUserID, "notification_error", fmt.Sprintf("failed to send dispute alert: %v", err))
 		log.Printf("Failed to send dispute alert to admin %d: %v", s.adminID, err)
@@ -151,8 +264,36 @@ func (s *NotificationService) SendDisputeAlert(marketID int64, question string,
 	}
 }
 
-// SendLossNotification sends a notification to a user when they lose
-func (s *NotificationService) SendLossNotification(userID int64, marketID int64, question string, amount int64) {
+// SendFinalizationStuckAlert tells the admin a market's auto-finalization kept failing and
+// needs a look before it is retried
+func (s *NotificationService) SendFinalizationStuckAlert(alert FinalizationStuck) {
+	if s.adminID == 0 {
+		log.Printf("Admin ID not set, skipping finalization alert for market #%d", alert.MarketID)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message := fmt.Sprintf("🚨 Finalization Stuck!\n\nMarket ID: #%d\nQuestion: %s\nFailed attempts: %d\nLast error: %s\n\n"+
+		"The market is now NEEDS_ATTENTION and won't be retried. Fix the cause, then POST /api/admin/markets/%d/retry-finalization.",
+		alert.MarketID,
+		truncateString(alert.Question, 100),
+		alert.Failures,
+		truncateString(alert.Error, 300),
+		alert.MarketID)
+
+	if _, err := s.sendDM(s.adminID, message); err != nil {
+		logger.Debug(0, "notification_error", fmt.Sprintf("failed to send finalization alert: %v", err))
+		log.Printf("Failed to send finalization alert to admin %d: %v", s.adminID, err)
+	} else {
+		logger.Debug(0, "finalization_stuck_alert_sent", fmt.Sprintf("market_id=%d", alert.MarketID))
+	}
+}
+
+// SendLossNotification sends a notification to a user when they lose, mentioning what their
+// voucher refunded if anything
+func (s *NotificationService) SendLossNotification(userID int64, marketID int64, question string, amount int64, refunded int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -166,13 +307,191 @@ func (s *NotificationService) SendLossNotification(userID int64, marketID int64,
 		formatBalance(amount),
 		marketID,
 		truncateString(question, 50))
+	if refunded > 0 {
+		message += fmt.Sprintf(" 🎟️ Your voucher refunded %s.", formatBalance(refunded))
+	}
 
-	_, err = s.bot.Send(&telebot.User{ID: user.TelegramID}, message)
+	_, err = s.sendDM(user.TelegramID, message)
 	if err != nil {
 		logger.Debug(userID, "notification_error", fmt.Sprintf("failed to send loss notification: %v", err))
 	}
 }
 
+// SendColdStartNudge DMs a market's creator that nobody has bet on it after waited, with
+// buttons to share it and to open it
+func (s *NotificationService) SendColdStartNudge(market *storage.Market, waited time.Duration) {
+	if market == nil {
+		return
+	}
+	user, err := storage.GetUserByID(market.CreatorID)
+	if err != nil || user == nil {
+		logger.Debug(market.CreatorID, "notification_error", "failed to get user for cold start nudge")
+		return
+	}
+
+	question := userQuestion(market.CreatorID, market.ID, market.Question)
+	pools, _ := storage.GetPublicPools(market.ID)
+	share := BuildMarketShare(market, question, pools)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var opts []interface{}
+	if share.DeepLink != "" {
+		opts = append(opts, &telebot.ReplyMarkup{
+			InlineKeyboard: [][]telebot.InlineButton{{
+				{Text: "📤 Share", URL: share.ShareURL()},
+				{Text: "🎯 Open market", URL: share.DeepLink},
+			}},
+		})
+	}
+	if _, err := s.sendDM(user.TelegramID, ColdStartNudgeText(market, question, share, waited), opts...); err != nil {
+		logger.Debug(market.CreatorID, "notification_error", fmt.Sprintf("failed to send cold start nudge: %v", err))
+	} else {
+		logger.Debug(market.CreatorID, "cold_start_nudge_sent", fmt.Sprintf("market_id=%d", market.ID))
+	}
+}
+
+// PublishColdStartGroupNudge posts to a group market's chat that the market has no bets yet
+func (s *NotificationService) PublishColdStartGroupNudge(market *storage.Market) {
+	if market == nil || market.GroupID == 0 {
+		return
+	}
+	pools, _ := storage.GetPublicPools(market.ID)
+	share := BuildMarketShare(market, market.Question, pools)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var opts []interface{}
+	if share.DeepLink != "" {
+		opts = append(opts, &telebot.ReplyMarkup{
+			InlineKeyboard: [][]telebot.InlineButton{{{Text: "🎯 Bet on it", URL: share.DeepLink}}},
+		})
+	}
+	if _, err := s.send(&telebot.Chat{ID: market.GroupID}, ColdStartGroupText(market, share), opts...); err != nil {
+		log.Printf("Failed to post cold start nudge for market %d to group %d: %v", market.ID, market.GroupID, err)
+	}
+}
+
+// SendOddsSwing DMs a bettor that a market's odds moved since their bet, with a button back to
+// the market to hedge or add when the bot's link is known
+func (s *NotificationService) SendOddsSwing(e OddsSwing) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := storage.GetUserByID(e.UserID)
+	if err != nil || user == nil {
+		logger.Debug(e.UserID, "notification_error", "failed to get user for odds swing notification")
+		return
+	}
+
+	var opts []interface{}
+	if link := MarketDeepLink(e.MarketID); link != "" {
+		opts = append(opts, &telebot.ReplyMarkup{
+			InlineKeyboard: [][]telebot.InlineButton{{{Text: "🎯 Hedge or add", URL: link}}},
+		})
+	}
+	if _, err := s.sendDM(user.TelegramID, OddsSwingText(e), opts...); err != nil {
+		logger.Debug(e.UserID, "notification_error", fmt.Sprintf("failed to send odds swing notification: %v", err))
+	}
+}
+
+// SendCreatorRewardNotification tells a market's creator what they earned from its pool
+func (s *NotificationService) SendCreatorRewardNotification(userID int64, marketID int64, question string, amount int64, totalPool int64, newBalance int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := storage.GetUserByID(userID)
+	if err != nil || user == nil {
+		logger.Debug(userID, "notification_error", "failed to get user for creator reward notification")
+		return
+	}
+
+	message := fmt.Sprintf("🎨 Creator reward: you earned %s from the %s pool of your market '#%d %s'. Thanks for creating it!\n\nNew Balance: %s",
+		formatBalance(amount),
+		formatBalance(totalPool),
+		marketID,
+		truncateString(question, 50),
+		formatBalance(newBalance))
+
+	if _, err := s.sendDM(user.TelegramID, message); err != nil {
+		logger.Debug(userID, "notification_error", fmt.Sprintf("failed to send creator reward notification: %v", err))
+	}
+}
+
+// SendStreakNotification sends a DM when a user's win streak reaches a milestone or ends
+func (s *NotificationService) SendStreakNotification(userID int64, marketID int64, question string, streak int, ended int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := storage.GetUserByID(userID)
+	if err != nil || user == nil {
+		logger.Debug(userID, "notification_error", "failed to get user for streak notification")
+		return
+	}
+
+	var message string
+	if ended > 0 {
+		message = fmt.Sprintf("💔 Your %d-market win streak ended with '#%d %s'. Time to start a new one!",
+			ended,
+			marketID,
+			truncateString(question, 50))
+	} else {
+		message = fmt.Sprintf("🔥 %d in a row! Market '#%d %s' extended your win streak to %d markets.",
+			streak,
+			marketID,
+			truncateString(question, 50),
+			streak)
+	}
+
+	_, err = s.sendDM(user.TelegramID, message)
+	if err != nil {
+		logger.Debug(userID, "notification_error", fmt.Sprintf("failed to send streak notification: %v", err))
+	}
+}
+
+// SendDigest sends a user their daily digest
+func (s *NotificationService) SendDigest(userID int64, digest *Digest) {
+	if digest == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := storage.GetUserByID(userID)
+	if err != nil || user == nil {
+		logger.Debug(userID, "notification_error", "failed to get user for digest")
+		return
+	}
+
+	_, err = s.sendDM(user.TelegramID, digest.Text())
+	if err != nil {
+		logger.Debug(userID, "notification_error", fmt.Sprintf("failed to send digest: %v", err))
+	}
+}
+
+// PublishGroupDigest posts the morning digest to a group chat. Groups that removed the bot
+// are unsubscribed.
+func (s *NotificationService) PublishGroupDigest(chatID int64, digest *GroupDigest) {
+	if digest == nil || chatID == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.send(&telebot.Chat{ID: chatID}, digest.Text())
+	if err == nil {
+		return
+	}
+	log.Printf("Failed to post group digest to %d: %v", chatID, err)
+	if errors.Is(err, telebot.ErrKickedFromGroup) || errors.Is(err, telebot.ErrKickedFromSuperGroup) || errors.Is(err, telebot.ErrChatNotFound) {
+		if err := storage.DisableGroupDigest(chatID); err != nil {
+			log.Printf("Failed to unsubscribe group %d: %v", chatID, err)
+		}
+	}
+}
+
 // NotifyMarketCreatorDeadline sends a DM to the market creator when their market expires
 func (s *NotificationService) NotifyMarketCreatorDeadline(market *storage.Market) {
 	if market == nil {
@@ -204,7 +523,7 @@ func (s *NotificationService) NotifyMarketCreatorDeadline(market *storage.Market
 		market.ID,
 		market.ID)
 
-	_, err = s.bot.Send(&telebot.User{ID: user.TelegramID}, message, &telebot.SendOptions{
+	_, err = s.sendDM(user.TelegramID, message, &telebot.SendOptions{
 		ParseMode: telebot.ModeMarkdown,
 	})
 	if err != nil {
@@ -215,12 +534,13 @@ func (s *NotificationService) NotifyMarketCreatorDeadline(market *storage.Market
 	}
 }
 
-// truncateString truncates a string to maxLen and adds ellipsis if needed
+// truncateString truncates a string to maxLen characters and adds ellipsis if needed
 func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
 		return s
 	}
-	return strings.TrimSpace(s[:maxLen-3]) + "..."
+	return strings.TrimSpace(string(runes[:maxLen-3])) + "..."
 }
 
 // GetBot returns the underlying telebot instance (for bot commands)
@@ -228,10 +548,19 @@ func (s *NotificationService) GetBot() *telebot.Bot {
 	return s.bot
 }
 
+// BotUsername returns the bot's Telegram username, "" when it is not known
+func (s *NotificationService) BotUsername() string {
+	if s.bot == nil || s.bot.Me == nil {
+		return ""
+	}
+	return s.bot.Me.Username
+}
+
 // --- Broadcaster Methods for Public News Channel ---
 
-// PublishNewMarket broadcasts a new market to the public channel
-func (s *NotificationService) PublishNewMarket(market *storage.Market, creatorName string) {
+// PublishNewMarket broadcasts a new market to the public channel.
+// preview and description are optional; with a thumbnail the announcement is sent as a photo.
+func (s *NotificationService) PublishNewMarket(market *storage.Market, creatorName string, preview *LinkPreview, description string) {
 	if s.channelID == "" {
 		// Channel not configured, skip broadcasting
 		return
@@ -248,17 +577,47 @@ func (s *NotificationService) PublishNewMarket(market *storage.Market, creatorNa
 		escapeMarkdown(market.Question),
 		escapeMarkdown(creatorName),
 		expiresAt)
+	if market.Blind {
+		message += "\n\n🙈 Blind market: pools and odds stay hidden until it closes."
+	}
+	if market.Sealed {
+		message += "\n\n🤐 Sealed market: nobody sees which side a bet is on until payouts."
+	}
+	message += criteriaLine(market.ID)
+	if description != "" {
+		message += fmt.Sprintf("\n\n📝 %s", escapeMarkdown(truncateString(description, 600)))
+	}
+	if preview != nil && preview.Title != "" {
+		message += fmt.Sprintf("\n\n🔗 %s", escapeMarkdown(truncateString(preview.Title, 100)))
+	}
+	message += hashtagLine(market.Question)
 
 	// Send to channel
 	recipient := s.getChannelRecipient()
-	_, err := s.bot.Send(recipient, message, &telebot.SendOptions{
+	withPhoto := preview != nil && preview.ImageURL != ""
+	var what interface{} = message
+	if withPhoto {
+		what = &telebot.Photo{File: telebot.FromURL(preview.ImageURL), Caption: message}
+	}
+	sent, err := s.send(recipient, what, &telebot.SendOptions{
 		ParseMode: telebot.ModeMarkdown,
 	})
+	if err != nil && withPhoto {
+		// Telegram could not fetch the thumbnail; the text alone is still worth sending
+		sent, err = s.send(recipient, message, &telebot.SendOptions{
+			ParseMode: telebot.ModeMarkdown,
+		})
+	}
 	if err != nil {
 		logger.Debug(0, "broadcast_error", fmt.Sprintf("failed to publish new market: %v", err))
 		log.Printf("Failed to publish new market to channel %s: %v", s.channelID, err)
-	} else {
-		logger.Debug(0, "broadcast_new_market", fmt.Sprintf("market_id=%d", market.ID))
+		return
+	}
+
+	logger.Debug(0, "broadcast_new_market", fmt.Sprintf("market_id=%d message_id=%d", market.ID, sent.ID))
+	// Remember the post so the results can point back to its comment thread
+	if err := storage.SetMarketChannelMessage(market.ID, sent.ID); err != nil {
+		logger.Debug(0, "broadcast_error", fmt.Sprintf("market_id=%d error=%v", market.ID, err))
 	}
 }
 
@@ -288,12 +647,14 @@ func (s *NotificationService) PublishResolution(marketID int64, question string,
 		outcomeEmoji,
 		outcome,
 		formatBalance(totalPool))
+	message += criteriaLine(marketID)
+	message += hashtagLine(question)
 
 	logger.Debug(0, "broadcast_message_prepared", fmt.Sprintf("length=%d", len(message)))
 
 	// Send to channel
 	recipient := s.getChannelRecipient()
-	_, err := s.bot.Send(recipient, message, &telebot.SendOptions{
+	_, err := s.send(recipient, message, &telebot.SendOptions{
 		ParseMode: telebot.ModeMarkdown,
 	})
 	if err != nil {
@@ -313,6 +674,49 @@ func (s *NotificationService) getChannelRecipient() telebot.Recipient {
 	return &telebot.Chat{ID: parseChannelID(s.channelID)}
 }
 
+// criteriaLine is the resolution criteria section of a channel post, or "" when the market has none
+func criteriaLine(marketID int64) string {
+	criteria, err := storage.GetMarketResolutionCriteria(marketID)
+	if err != nil || criteria == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n\n📋 Resolution criteria: %s", escapeMarkdown(truncateString(criteria, 300)))
+}
+
+// channelPostURL links to a post in the configured channel, where Telegram shows its comment thread.
+// Returns "" for chat IDs that cannot be linked to.
+func (s *NotificationService) channelPostURL(messageID int) string {
+	if strings.HasPrefix(s.channelID, "@") {
+		return fmt.Sprintf("https://t.me/%s/%d", strings.TrimPrefix(s.channelID, "@"), messageID)
+	}
+	// Private channels are linked by their ID without the -100 prefix
+	if internalID := strings.TrimPrefix(s.channelID, "-100"); internalID != s.channelID && internalID != "" {
+		return fmt.Sprintf("https://t.me/c/%s/%d", internalID, messageID)
+	}
+	return ""
+}
+
+// topWinnersText lists the biggest winners of a market for the results post
+func topWinnersText(winners []storage.MarketWinner) string {
+	if len(winners) == 0 {
+		return ""
+	}
+	medals := []string{"🥇", "🥈", "🥉"}
+	text := "\n\n🎉 *Top winners*"
+	for i, w := range winners {
+		medal := "🏅"
+		if i < len(medals) {
+			medal = medals[i]
+		}
+		name := "Anonymous"
+		if w.Name != "" {
+			name = escapeMarkdown(truncateString(w.Name, 32))
+		}
+		text += fmt.Sprintf("\n%s %s: %s", medal, name, formatBalance(w.Payout))
+	}
+	return text
+}
+
 // parseChannelID parses a channel ID string (supports numeric IDs)
 func parseChannelID(channelID string) int64 {
 	id, err := strconv.ParseInt(channelID, 10, 64)
@@ -323,7 +727,7 @@ func parseChannelID(channelID string) int64 {
 }
 
 // PublishDispute broadcasts a dispute notification to the public channel
-func (s *NotificationService) PublishDispute(marketID int64, question string, outcome string) {
+func (s *NotificationService) PublishDispute(marketID int64, question string, outcome string, reason string, evidenceURL string) {
 	if s.channelID == "" {
 		logger.Debug(0, "broadcast_skipped", "CHANNEL_ID not configured")
 		return
@@ -337,9 +741,17 @@ func (s *NotificationService) PublishDispute(marketID int64, question string, ou
 	message := fmt.Sprintf("⚠️ *Dispute Raised*\n\n*#%d* %s\n\nA user has disputed the resolution of this market\\.\n\n💰 Payouts are frozen pending admin review\\.\nThe admin will review and make a final decision\\.",
 		marketID,
 		escapeMarkdown(truncateString(question, 80)))
+	if reason != "" {
+		message += "\n\n💬 " + escapeMarkdown(truncateString(reason, 200))
+	}
+	if evidenceURL != "" {
+		message += "\n🔗 Evidence: " + escapeMarkdown(evidenceURL)
+	}
+	message += criteriaLine(marketID)
+	message += hashtagLine(question)
 
 	recipient := s.getChannelRecipient()
-	_, err := s.bot.Send(recipient, message, &telebot.SendOptions{
+	_, err := s.send(recipient, message, &telebot.SendOptions{
 		ParseMode: telebot.ModeMarkdown,
 	})
 	if err != nil {
@@ -351,8 +763,10 @@ func (s *NotificationService) PublishDispute(marketID int64, question string, ou
 	}
 }
 
-// PublishFinalization broadcasts market finalization and payout distribution
-func (s *NotificationService) PublishFinalization(marketID int64, question string, outcome string, winnersCount int, totalPayout int64, wasDisputed bool) {
+// PublishFinalization broadcasts market finalization and payout distribution.
+// topWinners are celebrated by name when they opted in, and the post links to the
+// comment thread of the market's announcement when there is one.
+func (s *NotificationService) PublishFinalization(marketID int64, question string, outcome string, winnersCount int, totalPayout int64, fee int64, wasDisputed bool, topWinners []storage.MarketWinner) {
 	if s.channelID == "" {
 		logger.Debug(0, "broadcast_skipped", "CHANNEL_ID not configured")
 		return
@@ -373,17 +787,30 @@ func (s *NotificationService) PublishFinalization(marketID int64, question strin
 		statusText = "\n\\(Reviewed and confirmed by admin\\)"
 	}
 
-	message := fmt.Sprintf("💰 *Payouts Distributed*\n\n*#%d* %s\n\n%s Final Outcome: *%s*%s\n💸 %d winners received payouts\n🏆 Total distributed: %s\n\nCongratulations to all winners\\!",
+	feeText := ""
+	if fee > 0 {
+		feeText = "\n🏦 House fee: " + formatBalance(fee)
+	}
+
+	message := fmt.Sprintf("💰 *Payouts Distributed*\n\n*#%d* %s\n\n%s Final Outcome: *%s*%s\n💸 %d winners received payouts\n🏆 Total distributed: %s%s\n\nCongratulations to all winners\\!",
 		marketID,
 		escapeMarkdown(truncateString(question, 80)),
 		outcomeEmoji,
 		outcome,
 		statusText,
 		winnersCount,
-		formatBalance(totalPayout))
+		formatBalance(totalPayout),
+		feeText)
+	message += topWinnersText(topWinners)
+	if messageID, err := storage.GetMarketChannelMessage(marketID); err == nil && messageID != 0 {
+		if url := s.channelPostURL(messageID); url != "" {
+			message += fmt.Sprintf("\n\n💬 [Discuss the result in the comments](%s)", url)
+		}
+	}
+	message += hashtagLine(question)
 
 	recipient := s.getChannelRecipient()
-	_, err := s.bot.Send(recipient, message, &telebot.SendOptions{
+	_, err := s.send(recipient, message, &telebot.SendOptions{
 		ParseMode: telebot.ModeMarkdown,
 	})
 	if err != nil {
@@ -395,6 +822,121 @@ func (s *NotificationService) PublishFinalization(marketID int64, question strin
 	}
 }
 
+// PublishUpset broadcasts an "Upset!" post for a finalized market with a notable outcome
+func (s *NotificationService) PublishUpset(marketID int64, question string, outcome string, reason UpsetReason, multiplier float64, winnersCount int, bettorsCount int) {
+	if s.channelID == "" {
+		logger.Debug(0, "broadcast_skipped", "CHANNEL_ID not configured")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	headline := fmt.Sprintf("Winners multiplied their stake by *%.1fx*\\!", multiplier)
+	if reason == UpsetUnderdog {
+		headline = fmt.Sprintf("Only %d of %d bettors saw it coming\\!", winnersCount, bettorsCount)
+	}
+
+	message := fmt.Sprintf("😱 *Upset\\!*\n\n*#%d* %s\n\n%s\n\n🎯 Outcome: *%s*\n📈 Payout multiplier: %.1fx\n🏅 %d winners",
+		marketID,
+		escapeMarkdown(truncateString(question, 80)),
+		headline,
+		outcome,
+		multiplier,
+		winnersCount)
+	message += hashtagLine(question)
+
+	recipient := s.getChannelRecipient()
+	_, err := s.send(recipient, message, &telebot.SendOptions{
+		ParseMode: telebot.ModeMarkdown,
+	})
+	if err != nil {
+		logger.Debug(0, "broadcast_error", fmt.Sprintf("channel=%s error=%v", s.channelID, err))
+		log.Printf("Failed to publish upset to channel %s: %v", s.channelID, err)
+	} else {
+		logger.Debug(0, "broadcast_upset", fmt.Sprintf("market_id=%d reason=%s multiplier=%.2f channel=%s", marketID, reason, multiplier, s.channelID))
+	}
+}
+
+// PublishWhaleAlert broadcasts a big bet to the public channel, linking the market's announcement post
+func (s *NotificationService) PublishWhaleAlert(marketID int64, question string, outcome string, amount int64, yesBefore, yesAfter float64, totalPool int64) {
+	if s.channelID == "" {
+		logger.Debug(0, "broadcast_skipped", "CHANNEL_ID not configured")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message := fmt.Sprintf("🐋 *Whale alert\\!*\n\n*#%d* %s\n\n💰 %s on *%s*\n📊 YES chance: %.0f%% → %.0f%%\n🏦 Pool: %s",
+		marketID,
+		escapeMarkdown(truncateString(question, 80)),
+		formatBalance(amount),
+		outcome,
+		yesBefore,
+		yesAfter,
+		formatBalance(totalPool))
+	if messageID, err := storage.GetMarketChannelMessage(marketID); err == nil && messageID != 0 {
+		if url := s.channelPostURL(messageID); url != "" {
+			message += fmt.Sprintf("\n\n🎯 [Think they're wrong? Bet the other side](%s)", url)
+		}
+	}
+	message += hashtagLine(question)
+
+	recipient := s.getChannelRecipient()
+	_, err := s.send(recipient, message, &telebot.SendOptions{
+		ParseMode: telebot.ModeMarkdown,
+	})
+	if err != nil {
+		logger.Debug(0, "broadcast_error", fmt.Sprintf("channel=%s error=%v", s.channelID, err))
+		log.Printf("Failed to publish whale alert to channel %s: %v", s.channelID, err)
+	} else {
+		logger.Debug(0, "broadcast_whale_alert", fmt.Sprintf("market_id=%d amount=%d yes_before=%.1f yes_after=%.1f channel=%s", marketID, amount, yesBefore, yesAfter, s.channelID))
+	}
+}
+
+// PublishLastCall announces that a market is about to lock, with the odds frozen until then.
+// Blind markets don't reveal their pools, sealed markets only their total.
+func (s *NotificationService) PublishLastCall(marketID int64, question string, expiresAt time.Time, pools storage.PublicPools) {
+	if s.channelID == "" {
+		logger.Debug(0, "broadcast_skipped", "CHANNEL_ID not configured")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message := fmt.Sprintf("⏳ *Last call\\!*\n\n*#%d* %s\n\n🔒 Closes at %s",
+		marketID,
+		escapeMarkdown(truncateString(question, 80)),
+		expiresAt.Format("2006-01-02 15:04"))
+	if pools.PoolsHidden {
+		message += "\n🙈 Pools are revealed when it closes"
+	} else {
+		message += fmt.Sprintf("\n🏦 Pool: %s", formatBalance(pools.Total))
+		if pools.Total > 0 && !pools.SidesHidden {
+			message += fmt.Sprintf("\n📊 YES chance: %.0f%% (frozen until close)", impliedYes(pools.Yes, pools.No))
+		}
+	}
+	if messageID, err := storage.GetMarketChannelMessage(marketID); err == nil && messageID != 0 {
+		if url := s.channelPostURL(messageID); url != "" {
+			message += fmt.Sprintf("\n\n🎯 [Get your bet in](%s)", url)
+		}
+	}
+	message += hashtagLine(question)
+
+	recipient := s.getChannelRecipient()
+	_, err := s.send(recipient, message, &telebot.SendOptions{
+		ParseMode: telebot.ModeMarkdown,
+	})
+	if err != nil {
+		logger.Debug(0, "broadcast_error", fmt.Sprintf("channel=%s error=%v", s.channelID, err))
+		log.Printf("Failed to publish last call to channel %s: %v", s.channelID, err)
+	} else {
+		logger.Debug(0, "broadcast_last_call", fmt.Sprintf("market_id=%d pool=%d channel=%s", marketID, pools.Total, s.channelID))
+	}
+}
+
 // NotifyDisputeToCreator sends a notification to market creator that their market was disputed
 func (s *NotificationService) NotifyDisputeToCreator(market *storage.Market, outcome string) {
 	if market == nil {
@@ -415,7 +957,7 @@ func (s *NotificationService) NotifyDisputeToCreator(market *storage.Market, out
 		truncateString(market.Question, 50),
 		outcome)
 
-	_, err = s.bot.Send(&telebot.User{ID: user.TelegramID}, message, &telebot.SendOptions{
+	_, err = s.sendDM(user.TelegramID, message, &telebot.SendOptions{
 		ParseMode: telebot.ModeMarkdown,
 	})
 	if err != nil {
@@ -425,6 +967,137 @@ func (s *NotificationService) NotifyDisputeToCreator(market *storage.Market, out
 	}
 }
 
+// SendTransferConfirmation asks the market creator to confirm a transfer they requested
+func (s *NotificationService) SendTransferConfirmation(transfer *storage.MarketTransfer, market *storage.Market, recipient *storage.User) {
+	creator, err := storage.GetUserByID(transfer.FromUserID)
+	if err != nil || creator == nil || creator.TelegramID == 0 {
+		logger.Debug(transfer.FromUserID, "notification_error", "failed to get creator for transfer confirmation")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message := fmt.Sprintf("🔁 Transfer market #%d '%s' to %s?\n\nThey will get resolution rights and deadline reminders for this market once they accept.",
+		market.ID,
+		truncateString(market.Question, 50),
+		displayName(recipient))
+
+	keyboard := &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{{
+			{Text: "✅ Confirm", Data: fmt.Sprintf("transfer_confirm_%d", transfer.ID)},
+			{Text: "✖️ Cancel", Data: fmt.Sprintf("transfer_cancel_%d", transfer.ID)},
+		}},
+	}
+
+	_, err = s.sendDM(creator.TelegramID, message, keyboard)
+	if err != nil {
+		logger.Debug(transfer.FromUserID, "notification_error", fmt.Sprintf("failed to send transfer confirmation: %v", err))
+	} else {
+		logger.Debug(transfer.FromUserID, "transfer_confirmation_sent", fmt.Sprintf("transfer_id=%d market_id=%d", transfer.ID, market.ID))
+	}
+}
+
+// SendTransferOffer asks the recipient to accept ownership of a market
+func (s *NotificationService) SendTransferOffer(transfer *storage.MarketTransfer, market *storage.Market, creator *storage.User) {
+	recipient, err := storage.GetUserByID(transfer.ToUserID)
+	if err != nil || recipient == nil || recipient.TelegramID == 0 {
+		logger.Debug(transfer.ToUserID, "notification_error", "failed to get recipient for transfer offer")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message := fmt.Sprintf("🔁 %s wants to hand over market #%d to you\n\n📝 %s\n⏰ Ends: %s\n\nIf you accept, you will be responsible for resolving it.",
+		displayName(creator),
+		market.ID,
+		truncateString(market.Question, 80),
+		market.ExpiresAt.Format("2006-01-02 15:04"))
+
+	keyboard := &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{{
+			{Text: "✅ Accept", Data: fmt.Sprintf("transfer_accept_%d", transfer.ID)},
+			{Text: "✖️ Decline", Data: fmt.Sprintf("transfer_decline_%d", transfer.ID)},
+		}},
+	}
+
+	_, err = s.sendDM(recipient.TelegramID, message, keyboard)
+	if err != nil {
+		logger.Debug(transfer.ToUserID, "notification_error", fmt.Sprintf("failed to send transfer offer: %v", err))
+	} else {
+		logger.Debug(transfer.ToUserID, "transfer_offer_sent", fmt.Sprintf("transfer_id=%d market_id=%d", transfer.ID, market.ID))
+	}
+}
+
+// SendTransferResult tells the previous creator whether the recipient accepted the market
+func (s *NotificationService) SendTransferResult(transfer *storage.MarketTransfer, market *storage.Market, accepted bool) {
+	creator, err := storage.GetUserByID(transfer.FromUserID)
+	if err != nil || creator == nil || creator.TelegramID == 0 {
+		logger.Debug(transfer.FromUserID, "notification_error", "failed to get creator for transfer result")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message := fmt.Sprintf("✖️ Your transfer of market #%d '%s' was declined. You remain its creator.",
+		market.ID,
+		truncateString(market.Question, 50))
+	if accepted {
+		message = fmt.Sprintf("✅ Market #%d '%s' has been transferred. You are no longer its creator.",
+			market.ID,
+			truncateString(market.Question, 50))
+	}
+
+	_, err = s.sendDM(creator.TelegramID, message)
+	if err != nil {
+		logger.Debug(transfer.FromUserID, "notification_error", fmt.Sprintf("failed to send transfer result: %v", err))
+	}
+}
+
+// SendMergeNotification tells a bettor that a duplicate market they bet on was merged.
+// movedAmount was carried over to the surviving market, refundedAmount went back to their balance.
+func (s *NotificationService) SendMergeNotification(userID int64, source, target *storage.Market, movedAmount, refundedAmount int64) {
+	user, err := storage.GetUserByID(userID)
+	if err != nil || user == nil {
+		logger.Debug(userID, "notification_error", "failed to get user for merge notification")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message := fmt.Sprintf("🔀 Market #%d '%s' was a duplicate and has been merged into market #%d.",
+		source.ID,
+		truncateString(source.Question, 50),
+		target.ID)
+	if movedAmount > 0 {
+		message += fmt.Sprintf("\n\n➡️ Your bets of %s now count on market #%d.", formatBalance(movedAmount), target.ID)
+	}
+	if refundedAmount > 0 {
+		message += fmt.Sprintf("\n\n💰 %s was refunded for bets placed after market #%d's deadline.", formatBalance(refundedAmount), target.ID)
+	}
+
+	_, err = s.sendDM(user.TelegramID, message)
+	if err != nil {
+		logger.Debug(userID, "notification_error", fmt.Sprintf("failed to send merge notification: %v", err))
+	} else {
+		logger.Debug(userID, "merge_notification_sent", fmt.Sprintf("source_id=%d target_id=%d", source.ID, target.ID))
+	}
+}
+
+// displayName returns @username if available, otherwise the first name
+func displayName(user *storage.User) string {
+	if user == nil {
+		return "Anonymous"
+	}
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+	return user.FirstName
+}
+
 // escapeMarkdown escapes special characters for Telegram Markdown mode
 func escapeMarkdown(s string) string {
 	escaped := s
@@ -446,3 +1119,230 @@ func escapeMarkdown(s string) string {
 	escaped = strings.ReplaceAll(escaped, "!", `\!`)
 	return escaped
 }
+
+// cosignerTelegramIDs returns the Telegram IDs of everyone who may co-sign a resolution
+// (admins, oracles and ADMIN_TELEGRAM_ID), leaving out exclude
+func (s *NotificationService) cosignerTelegramIDs(exclude int64) []int64 {
+	seen := map[int64]bool{exclude: true, 0: true}
+	var ids []int64
+	if !seen[s.adminID] {
+		seen[s.adminID] = true
+		ids = append(ids, s.adminID)
+	}
+	assignments, err := storage.ListRoleAssignments()
+	if err != nil {
+		logger.Debug(0, "notification_error", fmt.Sprintf("failed to list co-signers: %v", err))
+		return ids
+	}
+	for _, a := range assignments {
+		if (a.Role == storage.RoleAdmin || a.Role == storage.RoleOracle) && !seen[a.TelegramID] {
+			seen[a.TelegramID] = true
+			ids = append(ids, a.TelegramID)
+		}
+	}
+	return ids
+}
+
+// SendCosignRequest asks every possible co-signer to confirm or reject a big market's resolution.
+// The first answer wins; later taps report that the request is no longer pending.
+func (s *NotificationService) SendCosignRequest(cosign *storage.ResolutionCosign, question string, totalPool int64) {
+	if cosign == nil {
+		return
+	}
+
+	var proposerTelegramID int64
+	proposer, err := storage.GetUserByID(cosign.ProposedBy)
+	if err == nil && proposer != nil {
+		proposerTelegramID = proposer.TelegramID
+	}
+	recipients := s.cosignerTelegramIDs(proposerTelegramID)
+	if len(recipients) == 0 {
+		log.Printf("No co-signers configured, resolution of market #%d is waiting", cosign.MarketID)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message := fmt.Sprintf("✍️ Co-signature needed\n\nMarket #%d: %s\nPool: %s\n\n%s resolved it as %s. The dispute window starts once you confirm.",
+		cosign.MarketID,
+		truncateString(question, 100),
+		formatBalance(totalPool),
+		displayName(proposer),
+		cosign.Outcome)
+
+	keyboard := &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{{
+			{Text: "✅ Confirm " + cosign.Outcome, Data: fmt.Sprintf("cosign_confirm_%d", cosign.ID)},
+			{Text: "✖️ Reject", Data: fmt.Sprintf("cosign_reject_%d", cosign.ID)},
+		}},
+	}
+
+	for _, telegramID := range recipients {
+		if _, err := s.sendDM(telegramID, message, keyboard); err != nil {
+			logger.Debug(telegramID, "notification_error", fmt.Sprintf("failed to send co-signature request: %v", err))
+		} else {
+			logger.Debug(telegramID, "cosign_request_sent", fmt.Sprintf("cosign_id=%d market_id=%d", cosign.ID, cosign.MarketID))
+		}
+	}
+}
+
+// SendCosignDecision tells the market creator whether their resolution was co-signed
+func (s *NotificationService) SendCosignDecision(cosign *storage.ResolutionCosign, market *storage.Market) {
+	if cosign == nil || market == nil {
+		return
+	}
+	creator, err := storage.GetUserByID(cosign.ProposedBy)
+	if err != nil || creator == nil || creator.TelegramID == 0 {
+		logger.Debug(cosign.ProposedBy, "notification_error", "failed to get creator for co-signature decision")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message := fmt.Sprintf("✅ Your resolution of market #%d '%s' as %s was co-signed. Payouts follow after the dispute period.",
+		market.ID,
+		truncateString(market.Question, 50),
+		cosign.Outcome)
+	if cosign.Status == storage.CosignStatusRejected {
+		message = fmt.Sprintf("✖️ Your resolution of market #%d '%s' as %s was not co-signed. Please check the outcome and resolve it again.",
+			market.ID,
+			truncateString(market.Question, 50),
+			cosign.Outcome)
+	}
+
+	_, err = s.sendDM(creator.TelegramID, message)
+	if err != nil {
+		logger.Debug(cosign.ProposedBy, "notification_error", fmt.Sprintf("failed to send co-signature decision: %v", err))
+	}
+}
+
+// SendProposalVoteRequest asks every bettor who has not voted yet to confirm or reject an
+// outcome proposed while the market's creator was absent
+func (s *NotificationService) SendProposalVoteRequest(proposal *storage.CommunityProposal, question string) {
+	if proposal == nil {
+		return
+	}
+
+	recipients, err := storage.ListProposalVoterTelegramIDs(proposal.ID)
+	if err != nil {
+		logger.Debug(0, "notification_error", fmt.Sprintf("failed to list voters for proposal %d: %v", proposal.ID, err))
+		return
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message := fmt.Sprintf("🗳️ Vote on an outcome\n\nThe creator of market #%d '%s' has not resolved it, so a bettor proposed %s.\n\nYour vote is weighted by your stake. Voting closes %s.",
+		proposal.MarketID,
+		truncateString(question, 100),
+		proposal.Outcome,
+		proposal.ClosesAt.UTC().Format("2006-01-02 15:04 UTC"))
+
+	keyboard := &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{{
+			{Text: "✅ Confirm " + proposal.Outcome, Data: fmt.Sprintf("proposal_confirm_%d", proposal.ID)},
+			{Text: "✖️ Reject", Data: fmt.Sprintf("proposal_reject_%d", proposal.ID)},
+		}},
+	}
+
+	for _, telegramID := range recipients {
+		if _, err := s.sendDM(telegramID, message, keyboard); err != nil {
+			logger.Debug(telegramID, "notification_error", fmt.Sprintf("failed to send proposal vote request: %v", err))
+		}
+	}
+}
+
+// SendProposalEscalation tells the admins and oracles that a market needs their decision
+// because bettors did not confirm the proposed outcome
+func (s *NotificationService) SendProposalEscalation(proposal *storage.CommunityProposal, question string) {
+	if proposal == nil {
+		return
+	}
+
+	recipients := s.cosignerTelegramIDs(0)
+	if len(recipients) == 0 {
+		log.Printf("No admins configured, escalated market #%d is waiting", proposal.MarketID)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message := fmt.Sprintf("⚠️ Outcome vote escalated\n\nMarket ID: #%d\nQuestion: %s\nProposed outcome: %s\nConfirmed: %s by %d bettors\nRejected: %s\n\nThe creator never resolved it. Use /resolve_disputes to decide.",
+		proposal.MarketID,
+		truncateString(question, 100),
+		proposal.Outcome,
+		formatBalance(proposal.ConfirmStake),
+		proposal.Confirmers,
+		formatBalance(proposal.RejectStake))
+
+	for _, telegramID := range recipients {
+		if _, err := s.sendDM(telegramID, message); err != nil {
+			logger.Debug(telegramID, "notification_error", fmt.Sprintf("failed to send proposal escalation: %v", err))
+		}
+	}
+}
+
+// SendEscrowReport tells the admins which payouts of a finalized market were held in escrow
+// because the bettors' accounts no longer exist
+func (s *NotificationService) SendEscrowReport(marketID int64, question string, payouts []storage.EscrowedPayout) {
+	if len(payouts) == 0 {
+		return
+	}
+
+	recipients := s.cosignerTelegramIDs(0)
+	if len(recipients) == 0 {
+		log.Printf("No admins configured, %d payouts of market #%d are held in escrow", len(payouts), marketID)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := int64(0)
+	for _, p := range payouts {
+		total += p.Amount
+	}
+	message := fmt.Sprintf("🔒 Payouts held in escrow\n\nMarket ID: #%d\nQuestion: %s\nHeld: %s for %d bets whose accounts no longer exist\n\nSee GET /api/admin/escrow for details.",
+		marketID,
+		truncateString(question, 100),
+		formatBalance(total),
+		len(payouts))
+
+	for _, telegramID := range recipients {
+		if _, err := s.sendDM(telegramID, message); err != nil {
+			logger.Debug(telegramID, "notification_error", fmt.Sprintf("failed to send escrow report: %v", err))
+		}
+	}
+}
+
+// SendAccountMergeCode DMs one side of an account merge its confirmation code. The user passes
+// the codes of both accounts on to the admin to prove they own both.
+func (s *NotificationService) SendAccountMergeCode(notice AccountMergeCode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := storage.GetUserByID(notice.UserID)
+	if err != nil || user == nil {
+		logger.Debug(notice.UserID, "notification_error", "failed to get user for account merge code")
+		return
+	}
+
+	direction := "from your old account into this one"
+	if notice.OldAccount {
+		direction = "from this account into your new one"
+	}
+	message := fmt.Sprintf("🔗 Account merge #%d\n\nAn admin is merging your balance, bets and markets %s.\n\nConfirmation code: %s\n\nSend this code to the admin only if you asked for the merge. It expires in 24 hours.",
+		notice.MergeID,
+		direction,
+		notice.Code)
+
+	if _, err := s.sendDM(user.TelegramID, message); err != nil {
+		logger.Debug(notice.UserID, "notification_error", fmt.Sprintf("failed to send account merge code: %v", err))
+	}
+}
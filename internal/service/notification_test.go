@@ -1,7 +1,10 @@
 package service
 
 import (
+	"strings"
 	"testing"
+
+	"predictionbot/internal/storage"
 )
 
 func TestTruncateString(t *testing.T) {
@@ -122,3 +125,35 @@ func TestFormatBalance(t *testing.T) {
 		})
 	}
 }
+
+func TestChannelPostURL(t *testing.T) {
+	tests := []struct {
+		channelID string
+		expected  string
+	}{
+		{"@predictions", "https://t.me/predictions/42"},
+		{"-1001234567890", "https://t.me/c/1234567890/42"},
+		{"12345", ""},
+	}
+
+	for _, tt := range tests {
+		s := &NotificationService{channelID: tt.channelID}
+		if got := s.channelPostURL(42); got != tt.expected {
+			t.Errorf("channelPostURL for %q = %q, want %q", tt.channelID, got, tt.expected)
+		}
+	}
+}
+
+func TestTopWinnersText(t *testing.T) {
+	if text := topWinnersText(nil); text != "" {
+		t.Errorf("Expected no text without winners, got %q", text)
+	}
+
+	text := topWinnersText([]storage.MarketWinner{
+		{Rank: 1, Name: "Ann", Payout: 500},
+		{Rank: 2, Payout: 200},
+	})
+	if !strings.Contains(text, "🥇 Ann: 500") || !strings.Contains(text, "🥈 Anonymous: 200") {
+		t.Errorf("Unexpected winners text %q", text)
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// Notifier delivers notification events. *NotificationService sends them via Telegram;
+// NoopNotifier and RecordingNotifier are for running without a bot and for tests.
+type Notifier interface {
+	Emit(event NotificationEvent)
+}
+
+// defaultNotifier returns the global notification service, or a no-op when none is configured,
+// together with the Slack alerts when SLACK_WEBHOOK_URL is set
+func defaultNotifier() Notifier {
+	var notifier Notifier = NoopNotifier{}
+	if ns := GetNotificationService(); ns != nil {
+		notifier = ns
+	}
+	return WithIntegrations(notifier)
+}
+
+// WithIntegrations adds the per-user DM transports, the admin alert integrations, the live
+// updates of /api/stream and the creators' market webhooks to a notifier
+func WithIntegrations(notifier Notifier) Notifier {
+	return MultiNotifier{WithAdminAlerts(WithUserTransports(notifier)), GetEventBus(), GetMarketWebhookNotifier()}
+}
+
+// WithAdminAlerts adds the configured admin alert integrations (Slack) to a notifier
+func WithAdminAlerts(notifier Notifier) Notifier {
+	if slack := GetSlackNotifier(); slack.Enabled() {
+		return MultiNotifier{notifier, slack}
+	}
+	return notifier
+}
+
+// NoopNotifier discards every event
+type NoopNotifier struct{}
+
+// Emit discards the event
+func (NoopNotifier) Emit(NotificationEvent) {}
+
+// MultiNotifier delivers every event to each of its notifiers in order
+type MultiNotifier []Notifier
+
+// Emit forwards the event to every notifier
+func (m MultiNotifier) Emit(event NotificationEvent) {
+	for _, n := range m {
+		n.Emit(event)
+	}
+}
+
+// RecordingNotifier keeps every emitted event so tests can assert on them
+type RecordingNotifier struct {
+	mu     sync.Mutex
+	events []NotificationEvent
+}
+
+// NewRecordingNotifier creates an empty recording notifier
+func NewRecordingNotifier() *RecordingNotifier {
+	return &RecordingNotifier{}
+}
+
+// Emit records the event
+func (r *RecordingNotifier) Emit(event NotificationEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// Events returns a copy of the recorded events in emission order
+func (r *RecordingNotifier) Events() []NotificationEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := make([]NotificationEvent, len(r.events))
+	copy(events, r.events)
+	return events
+}
+
+// WaitFor waits until at least n events were recorded or the timeout passes.
+// Services emit from goroutines after committing, so tests need to wait.
+func (r *RecordingNotifier) WaitFor(n int, timeout time.Duration) []NotificationEvent {
+	deadline := time.Now().Add(timeout)
+	for {
+		events := r.Events()
+		if len(events) >= n || time.Now().After(deadline) {
+			return events
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
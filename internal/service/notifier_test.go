@@ -0,0 +1,306 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestFinalizeMarketEmitsNotifications(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	recorder := NewRecordingNotifier()
+	payoutService := NewPayoutServiceWithNotifier(recorder)
+
+	creator, _ := storage.CreateUser(99999, "creator", "Creator")
+	winner, _ := storage.CreateUser(1001, "winner", "Winner")
+	loser, _ := storage.CreateUser(1002, "loser", "Loser")
+	market, _ := storage.CreateMarket(creator.ID, "Will the notifier record this?", time.Now().Add(time.Hour))
+	_ = storage.PlaceBet(ctx, winner.ID, market.ID, "YES", 300)
+	_ = storage.PlaceBet(ctx, loser.ID, market.ID, "NO", 100)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	if _, err := payoutService.FinalizeMarket(ctx, market.ID, ""); err != nil {
+		t.Fatalf("FinalizeMarket failed: %v", err)
+	}
+
+	events := recorder.WaitFor(3, time.Second)
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d: %+v", len(events), events)
+	}
+
+	finalization, ok := events[0].(FinalizationPublished)
+	if !ok {
+		t.Fatalf("Expected FinalizationPublished first, got %T", events[0])
+	}
+	if finalization.WinnersCount != 1 || finalization.TotalPayout != 400 || finalization.Outcome != "YES" {
+		t.Errorf("Unexpected finalization event: %+v", finalization)
+	}
+	if len(finalization.TopWinners) != 1 || finalization.TopWinners[0].Payout != 400 || finalization.TopWinners[0].Name != "" {
+		t.Errorf("Expected one anonymous top winner, got %+v", finalization.TopWinners)
+	}
+
+	var win *WinNotice
+	var loss *LossNotice
+	for _, e := range events[1:] {
+		switch ev := e.(type) {
+		case WinNotice:
+			win = &ev
+		case LossNotice:
+			loss = &ev
+		}
+	}
+	if win == nil || win.UserID != winner.ID || win.Payout != 400 || win.BetAmount != 300 {
+		t.Errorf("Unexpected win notice: %+v", win)
+	}
+	if loss == nil || loss.UserID != loser.ID || loss.Amount != 100 {
+		t.Errorf("Unexpected loss notice: %+v", loss)
+	}
+}
+
+func TestRaiseDisputeEmitsNotifications(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	recorder := NewRecordingNotifier()
+	payoutService := NewPayoutServiceWithNotifier(recorder)
+
+	creator, _ := storage.CreateUser(99999, "creator", "Creator")
+	bettor, _ := storage.CreateUser(1001, "bettor", "Bettor")
+	market, _ := storage.CreateMarket(creator.ID, "Will the dispute be recorded?", time.Now().Add(time.Hour))
+	_ = storage.PlaceBet(ctx, bettor.ID, market.ID, "NO", 100)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	if err := payoutService.RaiseDispute(ctx, market.ID, bettor, "  The source says NO ", "https://example.com/result"); err != nil {
+		t.Fatalf("RaiseDispute failed: %v", err)
+	}
+
+	events := recorder.WaitFor(3, time.Second)
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d: %+v", len(events), events)
+	}
+	expected := []string{"dispute_published", "dispute_alert", "dispute_creator_notice"}
+	for i, kind := range expected {
+		if events[i].Kind() != kind {
+			t.Errorf("Expected event %d to be %s, got %s", i, kind, events[i].Kind())
+		}
+	}
+	if alert, ok := events[1].(DisputeAlert); !ok || alert.DisputedBy != bettor.ID || alert.DisputerName != "Bettor" {
+		t.Errorf("Expected dispute alert for user %d, got %+v", bettor.ID, events[1])
+	}
+	published, ok := events[0].(DisputePublished)
+	if !ok || published.Reason != "The source says NO" || published.EvidenceURL != "https://example.com/result" {
+		t.Errorf("Expected the reason and evidence in the broadcast, got %+v", events[0])
+	}
+}
+
+func TestMarketWorkerEmitsDeadlineReached(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	recorder := NewRecordingNotifier()
+	worker := NewMarketWorker(recorder)
+	defer worker.Stop()
+
+	creator, _ := storage.CreateUser(99999, "creator", "Creator")
+	market, _ := storage.CreateMarket(creator.ID, "Has this market expired yet?", time.Now().Add(-time.Minute))
+
+	worker.lockExpiredMarkets()
+
+	events := recorder.Events()
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	deadline, ok := events[0].(DeadlineReached)
+	if !ok || deadline.Market == nil || deadline.Market.ID != market.ID {
+		t.Errorf("Expected DeadlineReached for market %d, got %+v", market.ID, events[0])
+	}
+	if locked, ok := events[1].(MarketLocked); !ok || locked.Market == nil || locked.Market.ID != market.ID {
+		t.Errorf("Expected MarketLocked for market %d, got %+v", market.ID, events[1])
+	}
+}
+
+func TestMarketWorkerLastCall(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	recorder := NewRecordingNotifier()
+	worker := NewMarketWorker(recorder)
+	defer worker.Stop()
+
+	ctx := context.Background()
+	creator, _ := storage.CreateUser(99999, "creator", "Creator")
+	bettor, _ := storage.CreateUser(1001, "bettor", "Bettor")
+	market, _ := storage.CreateMarket(creator.ID, "Is this market about to close?", time.Now().Add(2*time.Minute))
+	_ = storage.PlaceBet(ctx, bettor.ID, market.ID, "YES", 50)
+
+	worker.startLastCalls()
+	worker.lockExpiredMarkets()
+
+	events := recorder.Events()
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+	lastCall, ok := events[0].(LastCall)
+	if !ok || lastCall.Market == nil || lastCall.Market.ID != market.ID || lastCall.Pools.Yes != 50 {
+		t.Errorf("Expected LastCall for market %d, got %+v", market.ID, events[0])
+	}
+
+	// Once it expires the last call market locks like any other
+	storage.DB().Exec(`UPDATE markets SET expires_at = ? WHERE id = ?`, time.Now().Add(-time.Minute), market.ID)
+	worker.lockExpiredMarkets()
+	if locked, _ := storage.GetMarketByID(market.ID); locked.Status != storage.MarketStatusLocked {
+		t.Errorf("Expected market to be locked, got %s", locked.Status)
+	}
+
+	worker.lastCall = 0
+	storage.CreateMarket(creator.ID, "Does a disabled last call skip this one?", time.Now().Add(2*time.Minute))
+	worker.startLastCalls()
+	if events := recorder.Events(); len(events) != 3 {
+		t.Errorf("Expected only the lock events to follow, got %d events", len(events))
+	}
+}
+
+func TestMarketWorkerEventLockFallback(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	recorder := NewRecordingNotifier()
+	worker := NewMarketWorker(recorder)
+	defer worker.Stop()
+
+	creator, _ := storage.CreateUser(99999, "creator", "Creator")
+	manual, _ := storage.CreateMarketWithOptions(creator.ID, "Will the match finish before midnight?", time.Now().Add(time.Hour), storage.MarketOptions{LockMode: storage.LockModeManual})
+	timed, _ := storage.CreateMarket(creator.ID, "Will the timed market stay open?", time.Now().Add(time.Hour))
+
+	// Both markets were opened long ago, but only the manual one is past the event lock limit
+	storage.DB().Exec(`UPDATE markets SET created_at = ?`, time.Now().AddDate(0, 0, -2).UTC().Format("2006-01-02 15:04:05"))
+	worker.eventLockMax = 24 * time.Hour
+	worker.lockExpiredMarkets()
+
+	if m, _ := storage.GetMarketByID(manual.ID); m.Status != storage.MarketStatusLocked {
+		t.Errorf("Expected the manual market to be locked by the fallback, got %s", m.Status)
+	}
+	if m, _ := storage.GetMarketByID(timed.ID); m.Status != storage.MarketStatusActive {
+		t.Errorf("Expected the timed market to stay active, got %s", m.Status)
+	}
+	events := recorder.Events()
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if deadline, ok := events[0].(DeadlineReached); !ok || deadline.Market.ID != manual.ID {
+		t.Errorf("Expected DeadlineReached for market %d, got %+v", manual.ID, events[0])
+	}
+}
+
+func TestFinalizeMarketEmitsStreakNotice(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	recorder := NewRecordingNotifier()
+	payoutService := NewPayoutServiceWithNotifier(recorder)
+
+	creator, _ := storage.CreateUser(99999, "creator", "Creator")
+	streaker, _ := storage.CreateUser(1001, "streaker", "Streaker")
+	quiet, _ := storage.CreateUser(1002, "quiet", "Quiet")
+	loser, _ := storage.CreateUser(1003, "loser", "Loser")
+	storage.SetStreakNotifications(quiet.ID, false)
+
+	// Both winners already won two markets in a row
+	for i := 0; i < 2; i++ {
+		market, _ := storage.CreateMarket(creator.ID, "Warm-up market for the streak?", time.Now().Add(time.Hour))
+		_ = storage.PlaceBet(ctx, streaker.ID, market.ID, "YES", 10)
+		_ = storage.PlaceBet(ctx, quiet.ID, market.ID, "YES", 10)
+		_ = storage.PlaceBet(ctx, loser.ID, market.ID, "NO", 10)
+		storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+		storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+		if _, err := payoutService.FinalizeMarket(ctx, market.ID, ""); err != nil {
+			t.Fatalf("FinalizeMarket failed: %v", err)
+		}
+	}
+
+	market, _ := storage.CreateMarket(creator.ID, "Will the streak reach three?", time.Now().Add(time.Hour))
+	_ = storage.PlaceBet(ctx, streaker.ID, market.ID, "YES", 10)
+	_ = storage.PlaceBet(ctx, quiet.ID, market.ID, "YES", 10)
+	_ = storage.PlaceBet(ctx, loser.ID, market.ID, "NO", 10)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+	if _, err := payoutService.FinalizeMarket(ctx, market.ID, ""); err != nil {
+		t.Fatalf("FinalizeMarket failed: %v", err)
+	}
+
+	// 3 markets x (finalization + 3 bettor notices) + 1 streak notice
+	var notices []StreakNotice
+	for _, e := range recorder.WaitFor(13, time.Second) {
+		if ev, ok := e.(StreakNotice); ok {
+			notices = append(notices, ev)
+		}
+	}
+	if len(notices) != 1 {
+		t.Fatalf("Expected 1 streak notice, got %+v", notices)
+	}
+	if notices[0].UserID != streaker.ID || notices[0].Streak != 3 || notices[0].MarketID != market.ID {
+		t.Errorf("Unexpected streak notice: %+v", notices[0])
+	}
+
+	stats, _ := storage.GetUserStats(loser.ID)
+	if stats.CurrentStreak != -3 || stats.BestStreak != 0 {
+		t.Errorf("Expected a 3-market losing streak, got current=%d best=%d", stats.CurrentStreak, stats.BestStreak)
+	}
+}
+
+func TestMarketWorkerGivesUpFinalizing(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	recorder := NewRecordingNotifier()
+	worker := NewMarketWorker(recorder)
+	defer worker.Stop()
+	worker.disputeDelay = time.Minute
+	worker.finalizeFailureLimit = 2
+
+	creator, _ := storage.CreateUser(99999, "creator", "Creator")
+	market, _ := storage.CreateMarket(creator.ID, "Will the payout ever go through?", time.Now().Add(time.Hour))
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+	storage.DB().Exec(`UPDATE markets SET resolved_at = ? WHERE id = ?`, time.Now().Add(-time.Hour).UTC().Format("2006-01-02 15:04:05"), market.ID)
+
+	// Every finalization fails while the bets can't be read
+	storage.DB().Exec(`ALTER TABLE bets RENAME TO bets_unreadable`)
+	defer storage.DB().Exec(`ALTER TABLE bets_unreadable RENAME TO bets`)
+
+	worker.autoFinalizeResolvedMarkets()
+	if m, _ := storage.GetMarketByID(market.ID); m.Status != storage.MarketStatusResolved {
+		t.Fatalf("Expected the first failure to be retried, got %s", m.Status)
+	}
+	worker.autoFinalizeResolvedMarkets()
+	if m, _ := storage.GetMarketByID(market.ID); m.Status != storage.MarketStatusNeedsAttention {
+		t.Fatalf("Expected the market to need attention, got %s", m.Status)
+	}
+	worker.autoFinalizeResolvedMarkets()
+
+	var failed, stuck int
+	for _, event := range recorder.Events() {
+		switch e := event.(type) {
+		case WorkerFailed:
+			failed++
+		case FinalizationStuck:
+			stuck++
+			if e.MarketID != market.ID || e.Failures != 2 || e.Question != market.Question || !strings.Contains(e.Error, "failed to get bets") {
+				t.Errorf("Unexpected alert %+v", e)
+			}
+		}
+	}
+	if failed != 2 || stuck != 1 {
+		t.Errorf("Expected 2 failures and 1 alert, got %d and %d", failed, stuck)
+	}
+}
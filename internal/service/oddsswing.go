@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// DefaultOddsSwingPoints is how many percentage points of implied YES probability a market must
+// move since a user's bet before they are told
+const DefaultOddsSwingPoints = 15.0
+
+// LoadOddsSwingPoints reads ODDS_SWING_POINTS, falling back to the default for missing or
+// invalid values. 0 turns odds swing alerts off.
+func LoadOddsSwingPoints() float64 {
+	if v, err := strconv.ParseFloat(os.Getenv("ODDS_SWING_POINTS"), 64); err == nil && v >= 0 && v <= 100 {
+		return v
+	}
+	return DefaultOddsSwingPoints
+}
+
+// OddsSwingText formats a bettor's alert that a market's odds moved since their bet
+func OddsSwingText(e OddsSwing) string {
+	var b strings.Builder
+	direction := "📈"
+	if e.YesAfter < e.YesBefore {
+		direction = "📉"
+	}
+	fmt.Fprintf(&b, "%s The odds moved on market #%d\n\n📝 %s\n\n", direction, e.MarketID, truncateString(e.Question, 50))
+	fmt.Fprintf(&b, "📊 YES chance: %.0f%% → %.0f%%\n", e.YesBefore, e.YesAfter)
+
+	b.WriteString("Your position:")
+	if e.Yes > 0 {
+		fmt.Fprintf(&b, " YES %s", formatBalance(e.Yes))
+	}
+	if e.No > 0 {
+		fmt.Fprintf(&b, " NO %s", formatBalance(e.No))
+	}
+	b.WriteString("\n\nHedge on the other side or add to your bet while the market is open.")
+	return b.String()
+}
+
+// SendOddsSwings snapshots the public pools of open markets and emits an OddsSwing to every
+// bettor whose market moved at least points since their latest bet, or since their last alert.
+// It returns how many alerts were sent; points <= 0 sends none.
+func SendOddsSwings(notifier Notifier, now time.Time, points float64) int {
+	if points <= 0 {
+		return 0
+	}
+	if _, err := storage.TakePoolSnapshots(now); err != nil {
+		logger.Debug(0, "odds_swing_snapshot_failed", fmt.Sprintf("error=%s", err.Error()))
+		return 0
+	}
+	positions, err := storage.ListOddsSwingPositions()
+	if err != nil {
+		logger.Debug(0, "odds_swing_query_failed", fmt.Sprintf("error=%s", err.Error()))
+		return 0
+	}
+
+	sent := 0
+	for _, p := range positions {
+		if math.Abs(p.CurrentYes-p.BaselineYes) < points {
+			continue
+		}
+		// Record it first so a failing user is not alerted every minute
+		if err := storage.RecordOddsAlert(p.UserID, p.MarketID, p.CurrentYes, now); err != nil {
+			logger.Debug(p.TelegramID, "odds_swing_failed", fmt.Sprintf("market_id=%d error=%s", p.MarketID, err.Error()))
+			continue
+		}
+		notifier.Emit(OddsSwing{
+			UserID:    p.UserID,
+			MarketID:  p.MarketID,
+			Question:  p.Question,
+			Yes:       p.Yes,
+			No:        p.No,
+			YesBefore: p.BaselineYes,
+			YesAfter:  p.CurrentYes,
+		})
+		sent++
+	}
+	return sent
+}
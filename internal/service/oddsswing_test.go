@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestSendOddsSwings(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := storage.CreateUser(7501, "creator", "Creator")
+	alice, _ := storage.CreateUser(7502, "alice", "Alice")
+	carol, _ := storage.CreateUser(7503, "carol", "Carol")
+	bob, _ := storage.CreateUser(7504, "bob", "Bob")
+	market, _ := storage.CreateMarket(creator.ID, "Will the ferry run on Sunday?", time.Now().Add(time.Hour))
+
+	storage.PlaceBet(ctx, alice.ID, market.ID, "YES", 100)
+	storage.PlaceBet(ctx, carol.ID, market.ID, "YES", 50)
+	storage.SetNotificationsMuted(carol.ID, true)
+	now := time.Now().UTC()
+	storage.DB().Exec(`UPDATE bets SET placed_at = ?`, now.Add(-10*time.Minute).Format("2006-01-02 15:04:05"))
+
+	recorder := NewRecordingNotifier()
+	if sent := SendOddsSwings(recorder, now.Add(-5*time.Minute), DefaultOddsSwingPoints); sent != 0 {
+		t.Errorf("Expected no alert before the odds moved, sent %d", sent)
+	}
+
+	// YES drops from 100% to 25%
+	storage.PlaceBet(ctx, bob.ID, market.ID, "NO", 450)
+	if sent := SendOddsSwings(recorder, now.Add(time.Minute), 0); sent != 0 {
+		t.Errorf("Expected no alert when turned off, sent %d", sent)
+	}
+	if sent := SendOddsSwings(recorder, now.Add(2*time.Minute), DefaultOddsSwingPoints); sent != 1 {
+		t.Fatalf("Expected one alert, sent %d", sent)
+	}
+	if sent := SendOddsSwings(recorder, now.Add(3*time.Minute), DefaultOddsSwingPoints); sent != 0 {
+		t.Errorf("Expected the alert once per swing, sent %d more", sent)
+	}
+
+	events := recorder.Events()
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+	e, ok := events[0].(OddsSwing)
+	if !ok || e.UserID != alice.ID || e.Yes != 100 || e.YesBefore != 100 || e.YesAfter != 25 {
+		t.Fatalf("Expected a swing for alice, got %+v", events[0])
+	}
+	if text := OddsSwingText(e); !strings.Contains(text, "100% → 25%") || !strings.Contains(text, "YES 100") {
+		t.Errorf("Unexpected alert text %q", text)
+	}
+}
+
+func TestLoadOddsSwingPoints(t *testing.T) {
+	t.Setenv("ODDS_SWING_POINTS", "")
+	if got := LoadOddsSwingPoints(); got != DefaultOddsSwingPoints {
+		t.Errorf("Expected the default, got %v", got)
+	}
+	t.Setenv("ODDS_SWING_POINTS", "0")
+	if got := LoadOddsSwingPoints(); got != 0 {
+		t.Errorf("Expected alerts off, got %v", got)
+	}
+	t.Setenv("ODDS_SWING_POINTS", "150")
+	if got := LoadOddsSwingPoints(); got != DefaultOddsSwingPoints {
+		t.Errorf("Expected an invalid value to be ignored, got %v", got)
+	}
+}
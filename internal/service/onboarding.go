@@ -0,0 +1,179 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"predictionbot/internal/storage"
+)
+
+// OnboardingButton is one inline button of an onboarding message. It carries exactly one of
+// callback Data, a URL, or WebApp to open the Web App.
+type OnboardingButton struct {
+	Text   string
+	Data   string
+	URL    string
+	WebApp bool
+}
+
+// OnboardingMessage is what the bot sends at an onboarding step, one row per button line
+type OnboardingMessage struct {
+	Text    string
+	Buttons [][]OnboardingButton
+}
+
+const (
+	// OnboardingNextData is the callback data of the button that moves to the next step
+	OnboardingNextData = "onboarding_next"
+	// OnboardingSkipData is the callback data of the button that ends onboarding early
+	OnboardingSkipData = "onboarding_skip"
+)
+
+// AnnouncementsChannelURL returns the link to the announcements channel: CHANNEL_URL if set (for
+// private channels, an invite link), else the public link of a CHANNEL_ID given as @username.
+// It is "" when the channel has no link, and onboarding then skips the channel step.
+func AnnouncementsChannelURL() string {
+	if link := strings.TrimSpace(os.Getenv("CHANNEL_URL")); link != "" {
+		return link
+	}
+	if channel := strings.TrimSpace(os.Getenv("CHANNEL_ID")); strings.HasPrefix(channel, "@") {
+		return "https://t.me/" + strings.TrimPrefix(channel, "@")
+	}
+	return ""
+}
+
+// NextOnboardingStep returns the step after step
+func NextOnboardingStep(step storage.OnboardingStep) storage.OnboardingStep {
+	switch step {
+	case storage.OnboardingCurrency:
+		return storage.OnboardingDemo
+	case storage.OnboardingDemo:
+		if AnnouncementsChannelURL() != "" {
+			return storage.OnboardingChannel
+		}
+		return storage.OnboardingDone
+	default:
+		return storage.OnboardingDone
+	}
+}
+
+// AdvanceOnboarding moves a user (internal ID) to the next onboarding step, or straight to the
+// end when skip is set, and returns the step they are at afterwards. Users who are not being
+// onboarded stay where they are.
+func AdvanceOnboarding(userID int64, skip bool) (storage.OnboardingStep, error) {
+	step, err := storage.GetOnboardingStep(userID)
+	if err != nil {
+		return step, err
+	}
+	if step == storage.OnboardingNone || step == storage.OnboardingDone {
+		return step, nil
+	}
+
+	next := storage.OnboardingDone
+	if !skip {
+		next = NextOnboardingStep(step)
+	}
+	advanced, err := storage.AdvanceOnboarding(userID, step, next)
+	if err != nil {
+		return step, err
+	}
+	if !advanced {
+		// Another tap moved the user on in the meantime
+		return storage.GetOnboardingStep(userID)
+	}
+	return next, nil
+}
+
+// PickDemoMarket returns the open parimutuel market with the biggest public pool to show a new
+// user, nil when there is none. Blind and sealed markets are skipped: the demo shows the YES/NO
+// split, which they hide.
+func PickDemoMarket() (*storage.MarketWithCreator, error) {
+	markets, err := storage.ListActiveMarketsWithCreator()
+	if err != nil {
+		return nil, err
+	}
+	var demo *storage.MarketWithCreator
+	for i := range markets {
+		m := &markets[i]
+		if m.PoolsHidden || m.SidesHidden || m.PricingMode == string(storage.PricingMarketMaker) {
+			continue
+		}
+		if demo == nil || m.PoolYes+m.PoolNo > demo.PoolYes+demo.PoolNo {
+			demo = m
+		}
+	}
+	return demo, nil
+}
+
+// OnboardingPrompt renders the message of an onboarding step for a user
+func OnboardingPrompt(user *storage.User, step storage.OnboardingStep) OnboardingMessage {
+	next := OnboardingButton{Text: "Next ▶️", Data: OnboardingNextData}
+	openApp := OnboardingButton{Text: "🎯 Open Prediction Market", WebApp: true}
+
+	switch step {
+	case storage.OnboardingCurrency:
+		name := GetCurrency().Name
+		return OnboardingMessage{
+			Text: fmt.Sprintf("Welcome to the Prediction Market! 🎉\n\nHi, %s! Here's a quick tour.\n\n"+
+				"💰 You start with %s. %s is the play money of this bot: it has no cash value. "+
+				"You bet it on the YES or NO side of a question, and when the market resolves the winners "+
+				"split the losers' pool in proportion to their stakes.",
+				user.FirstName, formatBalance(user.Balance), name),
+			Buttons: [][]OnboardingButton{{next, {Text: "Skip", Data: OnboardingSkipData}}},
+		}
+
+	case storage.OnboardingDemo:
+		demo, err := PickDemoMarket()
+		if err != nil || demo == nil {
+			return OnboardingMessage{
+				Text:    "🎯 No markets are open right now. Create the first one with /create, or open the app to look around." + firstBetVoucherText(user.ID),
+				Buttons: [][]OnboardingButton{{openApp}, {next}},
+			}
+		}
+		status := "No bets yet, be the first!"
+		if total := demo.PoolYes + demo.PoolNo; total > 0 {
+			yes := demo.PoolYes * 100 / total
+			status = fmt.Sprintf("YES %d%% · NO %d%% · Pool %s", yes, 100-yes, formatBalance(total))
+		}
+		try := openApp
+		if link := MarketDeepLink(demo.ID); link != "" {
+			try = OnboardingButton{Text: "🎯 Bet on it", URL: link}
+		}
+		return OnboardingMessage{
+			Text: fmt.Sprintf("🎯 Try it on a live market:\n\n%s\n%s\n\nOpen it, pick YES or NO and place your first bet.%s",
+				withIcon(demo.Icon, userQuestion(user.ID, demo.ID, demo.Question)), status, firstBetVoucherText(user.ID)),
+			Buttons: [][]OnboardingButton{{try}, {next}},
+		}
+
+	case storage.OnboardingChannel:
+		return OnboardingMessage{
+			Text: "📣 New markets and results are posted in our announcements channel. Join it to hear about them first.",
+			Buttons: [][]OnboardingButton{
+				{{Text: "📣 Join the channel", URL: AnnouncementsChannelURL()}},
+				{{Text: "Done ✅", Data: OnboardingNextData}},
+			},
+		}
+
+	default:
+		return OnboardingMessage{
+			Text: fmt.Sprintf("✅ You're all set, %s! You have %s.\n\nOpen the app any time, or type /help to see every command.",
+				user.FirstName, formatBalance(user.Balance)),
+			Buttons: [][]OnboardingButton{{openApp}},
+		}
+	}
+}
+
+// firstBetVoucherText describes the insurance voucher backing a user's (internal ID) next bet,
+// "" when there is none
+func firstBetVoucherText(userID int64) string {
+	vouchers, err := storage.ListAvailableVouchers(userID)
+	if err != nil || len(vouchers) == 0 || vouchers[0].Kind != storage.VoucherInsurance {
+		return ""
+	}
+	text := fmt.Sprintf("\n\n🎟️ It's risk-free: if your first bet loses, you get up to %s back", formatBalance(vouchers[0].MaxAmount))
+	if expires := vouchers[0].ExpiresAt; expires != nil {
+		text += " when you bet by " + expires.UTC().Format("Jan 2")
+	}
+	return text + "."
+}
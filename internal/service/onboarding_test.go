@@ -0,0 +1,98 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestOnboardingFlow(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("CHANNEL_URL", "")
+	t.Setenv("CHANNEL_ID", "@predictions")
+	t.Setenv("BOT_USERNAME", "predict_bot")
+
+	creator, _ := storage.CreateUser(11111, "creator", "Creator")
+	user, _ := storage.CreateUser(22222, "newbie", "Newbie")
+	storage.CreateMarket(creator.ID, "Will nobody bet on this?", time.Now().Add(time.Hour))
+	popular, _ := storage.CreateMarket(creator.ID, "Will this be the demo?", time.Now().Add(time.Hour))
+	storage.PlaceBet(t.Context(), creator.ID, popular.ID, "YES", 50)
+	storage.StartOnboarding(user.ID)
+	GrantFirstBetVoucher(user.ID)
+
+	prompt := OnboardingPrompt(user, storage.OnboardingCurrency)
+	if !strings.Contains(prompt.Text, "1000 WSC") || prompt.Buttons[0][0].Data != OnboardingNextData {
+		t.Errorf("Expected the currency step to explain the welcome bonus, got %+v", prompt)
+	}
+
+	step, err := AdvanceOnboarding(user.ID, false)
+	if err != nil || step != storage.OnboardingDemo {
+		t.Fatalf("Expected the demo step, got %q (%v)", step, err)
+	}
+	prompt = OnboardingPrompt(user, step)
+	if !strings.Contains(prompt.Text, "Will this be the demo?") || !strings.Contains(prompt.Text, "risk-free") || prompt.Buttons[0][0].URL != MarketDeepLink(popular.ID) {
+		t.Errorf("Expected the busiest market as the demo, got %+v", prompt)
+	}
+
+	step, _ = AdvanceOnboarding(user.ID, false)
+	if step != storage.OnboardingChannel {
+		t.Fatalf("Expected the channel step, got %q", step)
+	}
+	if prompt = OnboardingPrompt(user, step); prompt.Buttons[0][0].URL != "https://t.me/predictions" {
+		t.Errorf("Expected a link to the channel, got %+v", prompt)
+	}
+
+	if step, _ = AdvanceOnboarding(user.ID, false); step != storage.OnboardingDone {
+		t.Errorf("Expected onboarding to be done, got %q", step)
+	}
+	if step, _ = AdvanceOnboarding(user.ID, false); step != storage.OnboardingDone {
+		t.Errorf("Expected a finished onboarding to stay done, got %q", step)
+	}
+}
+
+func TestOnboardingSkip(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("CHANNEL_URL", "")
+	t.Setenv("CHANNEL_ID", "-1001234567890")
+
+	user, _ := storage.CreateUser(22222, "newbie", "Newbie")
+	if step, _ := AdvanceOnboarding(user.ID, false); step != storage.OnboardingNone {
+		t.Errorf("Expected users who were never onboarded to be left alone, got %q", step)
+	}
+
+	storage.StartOnboarding(user.ID)
+	storage.AdvanceOnboarding(user.ID, storage.OnboardingCurrency, storage.OnboardingDemo)
+
+	// Without a channel link the demo is the last step, and there are no markets to show
+	if next := NextOnboardingStep(storage.OnboardingDemo); next != storage.OnboardingDone {
+		t.Errorf("Expected the channel step to be skipped without a link, got %q", next)
+	}
+	if prompt := OnboardingPrompt(user, storage.OnboardingDemo); !prompt.Buttons[0][0].WebApp {
+		t.Errorf("Expected the app button without a demo market, got %+v", prompt)
+	}
+
+	if step, _ := AdvanceOnboarding(user.ID, true); step != storage.OnboardingDone {
+		t.Errorf("Expected skipping to end onboarding, got %q", step)
+	}
+}
+
+func TestPickDemoMarketSkipsSealed(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator, _ := storage.CreateUser(11111, "creator", "Creator")
+	open, _ := storage.CreateMarket(creator.ID, "Will the open market be shown?", time.Now().Add(time.Hour))
+	// A sealed market reports no YES/NO pools, so its bets would be pitched as "No bets yet".
+	// It is listed first, being newer.
+	sealed, _ := storage.CreateMarketWithOptions(creator.ID, "Will the sealed market be skipped?", time.Now().Add(time.Hour), storage.MarketOptions{Sealed: true})
+	storage.PlaceBet(t.Context(), creator.ID, sealed.ID, "YES", 200)
+
+	demo, err := PickDemoMarket()
+	if err != nil || demo == nil || demo.ID != open.ID {
+		t.Errorf("Expected market %d as the demo, got %+v (%v)", open.ID, demo, err)
+	}
+}
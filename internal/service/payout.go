@@ -4,38 +4,76 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"predictionbot/internal/logger"
 	"predictionbot/internal/storage"
 )
 
+// TopWinnersAnnounced is how many winners the results post celebrates
+const TopWinnersAnnounced = 3
+
+// StreakMilestones are the win streak lengths that trigger a celebratory DM
+var StreakMilestones = []int{3, 5, 10}
+
+// isStreakMilestone reports whether a win streak just reached a milestone
+func isStreakMilestone(streak int) bool {
+	for _, m := range StreakMilestones {
+		if streak == m {
+			return true
+		}
+	}
+	return false
+}
+
 // PayoutService handles market resolution and payouts
 type PayoutService struct {
-	notificationService *NotificationService
+	notifier Notifier
 }
 
-// NewPayoutService creates a new payout service
+// NewPayoutService creates a payout service that notifies through the global notification service
 func NewPayoutService() *PayoutService {
 	return &PayoutService{}
 }
 
-// SetNotificationService sets the notification service for sending Telegram messages
-func (s *PayoutService) SetNotificationService(ns *NotificationService) {
-	s.notificationService = ns
+// NewPayoutServiceWithNotifier creates a payout service that sends events to the given notifier
+func NewPayoutServiceWithNotifier(notifier Notifier) *PayoutService {
+	return &PayoutService{notifier: notifier}
+}
+
+// events returns where notification events go: the injected notifier, else the global one
+func (s *PayoutService) events() Notifier {
+	if s.notifier != nil {
+		return s.notifier
+	}
+	return defaultNotifier()
 }
 
 // ResolveMarket resolves a market (Creator Action)
 // This sets the market status to RESOLVED and stores the outcome
 // Money is NOT distributed yet - it waits for the dispute period
-func (s *PayoutService) ResolveMarket(ctx context.Context, marketID, creatorID int64, outcome string) error {
+// creator is the resolved user record, so API and bot callers compare the same internal ID.
+// Markets with a pool of at least COSIGN_MIN_POOL stay LOCKED until a co-signer confirms the
+// outcome; the pending co-signature is returned then, nil otherwise.
+func (s *PayoutService) ResolveMarket(ctx context.Context, marketID int64, creator *storage.User, outcome string) (*storage.ResolutionCosign, error) {
+	if creator == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	creatorID := creator.ID
+
 	// Validate outcome
 	if outcome != "YES" && outcome != "NO" {
-		return fmt.Errorf("invalid outcome: must be 'YES' or 'NO'")
+		return nil, fmt.Errorf("invalid outcome: must be 'YES' or 'NO'")
 	}
 
 	db := storage.DB()
 	if db == nil {
-		return fmt.Errorf("database not initialized")
+		return nil, fmt.Errorf("database not initialized")
 	}
 
 	// Validate that the market exists and the user is the creator
@@ -47,53 +85,118 @@ func (s *PayoutService) ResolveMarket(ctx context.Context, marketID, creatorID i
 		WHERE id = ?
 	`, marketID).Scan(&actualCreatorID, &currentStatus)
 	if err == sql.ErrNoRows {
-		return fmt.Errorf("market not found")
+		return nil, fmt.Errorf("market not found")
 	}
 	if err != nil {
-		return fmt.Errorf("failed to get market: %w", err)
+		return nil, fmt.Errorf("failed to get market: %w", err)
 	}
 
 	// Only creator can resolve
 	if actualCreatorID != creatorID {
-		return fmt.Errorf("only the market creator can resolve this market")
+		return nil, fmt.Errorf("only the market creator can resolve this market")
 	}
 
 	// Market must be LOCKED
 	if currentStatus != string(storage.MarketStatusLocked) {
-		return fmt.Errorf("market cannot be resolved: status is %s", currentStatus)
+		return nil, fmt.Errorf("market cannot be resolved: status is %s", currentStatus)
+	}
+
+	// Big markets need a second signer before the dispute window starts
+	if minPool := LoadCosignMinPool(); minPool > 0 {
+		poolYes, poolNo, err := storage.GetPoolTotals(marketID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pool totals: %w", err)
+		}
+		if poolYes+poolNo >= minPool {
+			return s.requestCosign(ctx, marketID, creator, outcome, poolYes+poolNo)
+		}
 	}
 
 	// Update market status to RESOLVED with outcome
 	err = storage.UpdateMarketStatus(marketID, storage.MarketStatusResolved, outcome)
 	if err != nil {
-		return fmt.Errorf("failed to resolve market: %w", err)
+		return nil, fmt.Errorf("failed to resolve market: %w", err)
 	}
 
 	logger.Debug(creatorID, "market_resolved", fmt.Sprintf("market_id=%d outcome=%s", marketID, outcome))
 
 	// Broadcast resolution to public channel
+	s.publishResolution(marketID, outcome)
+	return nil, nil
+}
+
+// publishResolution broadcasts a resolution to the public channel
+func (s *PayoutService) publishResolution(marketID int64, outcome string) {
 	go func() {
-		notificationService := GetNotificationService()
-		if notificationService != nil {
-			// Get market details for broadcasting
-			var question string
-			var poolYes, poolNo int64
-			db := storage.DB()
-			if db != nil {
-				_ = db.QueryRowContext(ctx, `SELECT question FROM markets WHERE id = ?`, marketID).Scan(&question)
-				poolYes, poolNo, _ = storage.GetPoolTotals(marketID)
-			}
-			totalPool := poolYes + poolNo
-			notificationService.PublishResolution(marketID, question, outcome, totalPool)
+		emitter := s.events()
+		// Get market details for broadcasting
+		var question string
+		var poolYes, poolNo int64
+		db := storage.DB()
+		if db != nil {
+			// The request context may already be cancelled once the handler has returned
+			_ = db.QueryRowContext(context.Background(), `SELECT question FROM markets WHERE id = ?`, marketID).Scan(&question)
+			poolYes, poolNo, _ = storage.GetPoolTotals(marketID)
 		}
+		totalPool := poolYes + poolNo
+		emitter.Emit(ResolutionPublished{
+			MarketID:  marketID,
+			Question:  question,
+			Outcome:   outcome,
+			TotalPool: totalPool,
+		})
 	}()
+}
 
-	return nil
+// MaxDisputeReasonLength is the longest reason a dispute may give, in characters
+const MaxDisputeReasonLength = 500
+
+// DefaultDisputeBond is the bond (WSC) a user stakes to dispute a resolution
+const DefaultDisputeBond = 100
+
+// LoadDisputeBond reads DISPUTE_BOND, falling back to the default for missing or invalid values.
+// DISPUTE_BOND=0 lets users dispute for free.
+func LoadDisputeBond() int64 {
+	if v, err := strconv.ParseInt(os.Getenv("DISPUTE_BOND"), 10, 64); err == nil && v >= 0 {
+		return v
+	}
+	return DefaultDisputeBond
+}
+
+// normalizeDisputeEvidence trims a dispute's reason and evidence URL and checks them: the reason
+// is at most MaxDisputeReasonLength characters and the URL, when given, is an http(s) link
+func normalizeDisputeEvidence(reason, evidenceURL string) (string, string, error) {
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > MaxDisputeReasonLength {
+		return "", "", fmt.Errorf("invalid reason: must be at most %d characters", MaxDisputeReasonLength)
+	}
+	evidenceURL = strings.TrimSpace(evidenceURL)
+	if evidenceURL != "" {
+		u, err := url.Parse(evidenceURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", "", fmt.Errorf("invalid evidence URL: must be an http or https link")
+		}
+	}
+	return reason, evidenceURL, nil
 }
 
 // RaiseDispute raises a dispute on a resolved market (User Action)
 // This sets the market status to DISPUTED and stops auto-finalization
-func (s *PayoutService) RaiseDispute(ctx context.Context, marketID, userID int64) error {
+// disputer is the resolved user record; its internal ID is checked against bets.user_id.
+// reason and evidenceURL are optional and reach the admin alert and the channel broadcast.
+// The disputer stakes LoadDisputeBond(), which FinalizeMarket refunds if the resolution is
+// overturned and adds to the pool otherwise.
+func (s *PayoutService) RaiseDispute(ctx context.Context, marketID int64, disputer *storage.User, reason, evidenceURL string) error {
+	if disputer == nil {
+		return fmt.Errorf("user not found")
+	}
+	userID := disputer.ID
+
+	reason, evidenceURL, err := normalizeDisputeEvidence(reason, evidenceURL)
+	if err != nil {
+		return err
+	}
+
 	db := storage.DB()
 	if db == nil {
 		return fmt.Errorf("database not initialized")
@@ -103,7 +206,7 @@ func (s *PayoutService) RaiseDispute(ctx context.Context, marketID, userID int64
 	var currentStatus string
 	var question string
 	var outcomeNullable sql.NullString
-	err := db.QueryRowContext(ctx, `
+	err = db.QueryRowContext(ctx, `
 		SELECT status, question, outcome
 		FROM markets
 		WHERE id = ?
@@ -137,82 +240,160 @@ func (s *PayoutService) RaiseDispute(ctx context.Context, marketID, userID int64
 		return fmt.Errorf("you must have placed a bet on this market to dispute it")
 	}
 
-	// Update market status to DISPUTED
-	err = storage.UpdateMarketStatus(marketID, storage.MarketStatusDisputed, "")
-	if err != nil {
+	// Update market status to DISPUTED and record who disputed it
+	bond := LoadDisputeBond()
+	if _, err := storage.OpenDispute(ctx, marketID, userID, reason, evidenceURL, bond); err != nil {
 		return fmt.Errorf("failed to dispute market: %w", err)
 	}
 
-	logger.Debug(userID, "market_disputed", fmt.Sprintf("market_id=%d outcome=%s", marketID, outcome))
+	logger.Debug(userID, "market_disputed", fmt.Sprintf("market_id=%d outcome=%s bond=%d", marketID, outcome, bond))
 
-	// Get notification service
-	notifService := GetNotificationService()
-	if notifService != nil {
-		// Send all notifications in goroutines
-		go func() {
-			// 1. Broadcast to public channel
-			notifService.PublishDispute(marketID, question, outcome)
+	// Send all notifications in a goroutine
+	emitter := s.events()
+	go func() {
+		// 1. Broadcast to public channel
+		emitter.Emit(DisputePublished{MarketID: marketID, Question: question, Outcome: outcome, Reason: reason, EvidenceURL: evidenceURL})
+
+		// 2. Send alert to admin
+		emitter.Emit(DisputeAlert{
+			MarketID:     marketID,
+			Question:     question,
+			DisputedBy:   userID,
+			DisputerName: disputer.FirstName,
+			Reason:       reason,
+			EvidenceURL:  evidenceURL,
+		})
+
+		// 3. Notify market creator
+		market, err := storage.GetMarketByID(marketID)
+		if err == nil && market != nil {
+			emitter.Emit(DisputeCreatorNotice{Market: market, Outcome: outcome})
+		}
 
-			// 2. Send alert to admin
-			notifService.SendDisputeAlert(marketID, question, userID)
+		logger.Debug(userID, "dispute_notifications_sent", fmt.Sprintf("market_id=%d", marketID))
+	}()
 
-			// 3. Notify market creator
-			market, err := storage.GetMarketByID(marketID)
-			if err == nil && market != nil {
-				notifService.NotifyDisputeToCreator(market, outcome)
-			}
+	return nil
+}
 
-			logger.Debug(userID, "dispute_notifications_sent", fmt.Sprintf("market_id=%d", marketID))
-		}()
+// escrowIfUnclaimable holds a payout in escrow when the bettor's account no longer exists and
+// returns it, or returns nil when the payout can be credited as usual
+func escrowIfUnclaimable(ctx context.Context, tx *sql.Tx, marketID, betID, userID, amount int64, sourceType string) (*storage.EscrowedPayout, error) {
+	exists, err := storage.UserExistsTx(ctx, tx, userID)
+	if err != nil || exists {
+		return nil, err
+	}
+	held := storage.EscrowedPayout{MarketID: marketID, UserID: userID, BetID: betID, Amount: amount, SourceType: sourceType}
+	if err := storage.EscrowPayoutTx(ctx, tx, held); err != nil {
+		return nil, err
 	}
+	return &held, nil
+}
 
-	return nil
+// FinalizationPreview is what finalizing a market would pay out, from a dry run that commits nothing
+type FinalizationPreview struct {
+	MarketID int64  `json:"market_id"`
+	Status   string `json:"status"`
+	// StoredOutcome is the outcome the market was resolved with, Outcome the one previewed
+	StoredOutcome string `json:"stored_outcome"`
+	Outcome       string `json:"outcome"`
+	TotalPool     int64  `json:"total_pool"`
+	WinningPool   int64  `json:"winning_pool"`
+	// Refunded is set when nobody bet on the outcome and every stake would be returned
+	Refunded      bool  `json:"refunded"`
+	Fee           int64 `json:"fee"`
+	CreatorReward int64 `json:"creator_reward"`
+	// SubsidyRefund is what a market maker market's creator would get back of the subsidy
+	SubsidyRefund    int64 `json:"subsidy_refund"`
+	TotalPayout      int64 `json:"total_payout"`
+	PayoutsProcessed int   `json:"payouts_processed"`
+	// Payouts are the results per bet, or per holder on market maker markets
+	Payouts []PreviewPayout `json:"payouts"`
+	// Escrowed are the payouts that would be held for accounts that no longer exist
+	Escrowed []storage.EscrowedPayout `json:"escrowed"`
+}
+
+// PreviewPayout is one bettor's result in a FinalizationPreview
+type PreviewPayout struct {
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username,omitempty"`
+	FirstName string `json:"first_name,omitempty"`
+	Outcome   string `json:"outcome"`
+	// Result is "win", "refund" or "loss"
+	Result    string `json:"result"`
+	BetAmount int64  `json:"bet_amount"`
+	// Payout is what the user would be credited: winnings, a refund, or what a voucher pays
+	// back of a lost bet
+	Payout int64 `json:"payout"`
 }
 
-// FinalizeMarket finalizes a market and distributes payouts
+// FinalizeMarket finalizes a market and distributes payouts. Payouts owed to accounts that no
+// longer exist are held in escrow and reported to the admins.
 // This can be called by:
 // - Admin (with forceOutcome) to resolve disputed markets
 // - System (auto-finalization) to resolve markets after dispute period
 func (s *PayoutService) FinalizeMarket(ctx context.Context, marketID int64, forceOutcome string) (int, error) {
+	preview, err := s.finalize(ctx, marketID, forceOutcome, false)
+	if err != nil {
+		return 0, err
+	}
+	return preview.PayoutsProcessed, nil
+}
+
+// PreviewFinalization runs FinalizeMarket without committing it, so admins can see the payouts
+// an outcome would produce before overriding the resolution. Nothing is paid, escrowed or sent.
+func (s *PayoutService) PreviewFinalization(ctx context.Context, marketID int64, forceOutcome string) (*FinalizationPreview, error) {
+	return s.finalize(ctx, marketID, forceOutcome, true)
+}
+
+// finalize settles a market in one transaction, rolled back instead of committed on a dry run
+func (s *PayoutService) finalize(ctx context.Context, marketID int64, forceOutcome string, dryRun bool) (*FinalizationPreview, error) {
 	db := storage.DB()
 	if db == nil {
-		return 0, fmt.Errorf("database not initialized")
+		return nil, fmt.Errorf("database not initialized")
 	}
 
 	// Get market details
 	var marketStatus string
 	var storedOutcome string
 	var question string
+	var creatorID int64
 	err := db.QueryRowContext(ctx, `
-		SELECT status, outcome, question
+		SELECT status, outcome, question, creator_id
 		FROM markets
 		WHERE id = ?
-	`, marketID).Scan(&marketStatus, &storedOutcome, &question)
+	`, marketID).Scan(&marketStatus, &storedOutcome, &question, &creatorID)
 	if err == sql.ErrNoRows {
-		return 0, fmt.Errorf("market not found")
+		return nil, fmt.Errorf("market not found")
 	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to get market: %w", err)
+		return nil, fmt.Errorf("failed to get market: %w", err)
 	}
 
 	// Market must be RESOLVED or DISPUTED
 	if marketStatus != string(storage.MarketStatusResolved) && marketStatus != string(storage.MarketStatusDisputed) {
-		return 0, fmt.Errorf("market cannot be finalized: status is %s", marketStatus)
+		return nil, fmt.Errorf("market cannot be finalized: status is %s", marketStatus)
 	}
 
 	// Use forceOutcome if provided (admin case), otherwise use stored outcome
 	outcome := storedOutcome
 	if forceOutcome != "" {
 		if forceOutcome != "YES" && forceOutcome != "NO" {
-			return 0, fmt.Errorf("invalid outcome: must be 'YES' or 'NO'")
+			return nil, fmt.Errorf("invalid outcome: must be 'YES' or 'NO'")
 		}
 		outcome = forceOutcome
 	}
 
+	// Market maker markets pay out their shares instead of splitting pools
+	maker, err := storage.GetMarketMaker(marketID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Begin transaction with serializable isolation
 	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
 	if err != nil {
-		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
@@ -223,7 +404,7 @@ func (s *PayoutService) FinalizeMarket(ctx context.Context, marketID int64, forc
 		WHERE market_id = ?
 	`, marketID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get bets: %w", err)
+		return nil, fmt.Errorf("failed to get bets: %w", err)
 	}
 	defer rows.Close()
 
@@ -242,7 +423,7 @@ func (s *PayoutService) FinalizeMarket(ctx context.Context, marketID int64, forc
 		var b bet
 		err := rows.Scan(&b.ID, &b.UserID, &b.Outcome, &b.Amount)
 		if err != nil {
-			return 0, fmt.Errorf("failed to scan bet: %w", err)
+			return nil, fmt.Errorf("failed to scan bet: %w", err)
 		}
 		bets = append(bets, b)
 		totalPool += b.Amount
@@ -252,10 +433,10 @@ func (s *PayoutService) FinalizeMarket(ctx context.Context, marketID int64, forc
 	}
 
 	if err := rows.Err(); err != nil {
-		return 0, fmt.Errorf("error iterating bets: %w", err)
+		return nil, fmt.Errorf("error iterating bets: %w", err)
 	}
 
-	logger.Debug(0, "market_finalization_started", fmt.Sprintf("market_id=%d outcome=%s total_pool=%d winning_pool=%d", marketID, outcome, totalPool, winningPool))
+	logger.Debug(0, "market_finalization_started", fmt.Sprintf("market_id=%d outcome=%s total_pool=%d winning_pool=%d dry_run=%t", marketID, outcome, totalPool, winningPool, dryRun))
 
 	type payoutInfo struct {
 		userID    int64
@@ -263,52 +444,250 @@ func (s *PayoutService) FinalizeMarket(ctx context.Context, marketID int64, forc
 		betAmount int64
 		outcome   string
 		isWin     bool
+		// voucherRefund is what a voucher paid back of a lost bet
+		voucherRefund int64
 	}
 
 	var payoutsToNotify []payoutInfo
+	// Payouts owed to accounts that no longer exist are held for the admins instead
+	var escrowed []storage.EscrowedPayout
 	payoutsProcessed := 0
 
-	// Edge case: Nobody bet on the winning outcome (WinningPool == 0)
-	// Refund everyone who bet
-	if winningPool == 0 {
+	// Nobody bet on the winning outcome: refund everyone who bet
+	refunded := maker == nil && winningPool == 0
+	// pool is what a parimutuel pool's winners share, after fee, the house's cut, and reward,
+	// the creator's
+	var pool, fee, reward int64
+	// subsidyRefund is what a market maker market's creator gets back of the subsidy
+	var subsidyRefund int64
+
+	// Dispute bonds go back when the disputed resolution was overturned, and join the pool of
+	// its winners otherwise. Without such a pool (refunds, market maker markets) they go back too.
+	bonds, err := storage.ListHeldDisputeBondsTx(ctx, tx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	for _, bond := range bonds {
+		if bond.Outcome == outcome && maker == nil && !refunded {
+			if err := storage.SetDisputeBondStatusTx(ctx, tx, bond.DisputeID, storage.DisputeBondForfeited); err != nil {
+				return nil, err
+			}
+			totalPool += bond.Amount
+			if !dryRun {
+				logger.Debug(bond.UserID, "dispute_bond_forfeited", fmt.Sprintf("market_id=%d dispute_id=%d bond=%d", marketID, bond.DisputeID, bond.Amount))
+			}
+			continue
+		}
+
+		if err := storage.SetDisputeBondStatusTx(ctx, tx, bond.DisputeID, storage.DisputeBondRefunded); err != nil {
+			return nil, err
+		}
+		held, err := escrowIfUnclaimable(ctx, tx, marketID, 0, bond.UserID, bond.Amount, "DISPUTE_BOND_REFUND")
+		if err != nil {
+			return nil, err
+		}
+		if held != nil {
+			escrowed = append(escrowed, *held)
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET balance = balance + ? WHERE id = ?`, bond.Amount, bond.UserID); err != nil {
+			return nil, fmt.Errorf("failed to refund dispute bond to user %d: %w", bond.UserID, err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+			VALUES (?, ?, 'DISPUTE_BOND_REFUND', ?, ?)
+		`, bond.UserID, bond.Amount, fmt.Sprintf("Dispute bond returned for market #%d", marketID), marketID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to log dispute bond refund: %w", err)
+		}
+		if !dryRun {
+			logger.Debug(bond.UserID, "dispute_bond_refunded", fmt.Sprintf("market_id=%d dispute_id=%d bond=%d", marketID, bond.DisputeID, bond.Amount))
+		}
+	}
+
+	if maker != nil {
+		// Each winning share pays out 1
+		holdings, err := storage.ListShareHoldingsTx(ctx, tx, marketID)
+		if err != nil {
+			return nil, err
+		}
+		// takings is what the market maker took in net of sales, paidOut what it owes
+		var takings, paidOut int64
+		for _, h := range holdings {
+			shares := h.SharesYes
+			if outcome == string(storage.OutcomeNo) {
+				shares = h.SharesNo
+			}
+			takings += h.Spent
+			paidOut += shares
+			spent := max(h.Spent, 0)
+			if shares == 0 {
+				exists, err := storage.UserExistsTx(ctx, tx, h.UserID)
+				if err != nil {
+					return nil, err
+				}
+				if exists && spent > 0 {
+					payoutsToNotify = append(payoutsToNotify, payoutInfo{userID: h.UserID, amount: spent, betAmount: spent, outcome: outcome, isWin: false})
+				}
+				continue
+			}
+
+			held, err := escrowIfUnclaimable(ctx, tx, marketID, 0, h.UserID, shares, "SHARES_PAYOUT")
+			if err != nil {
+				return nil, err
+			}
+			if held != nil {
+				escrowed = append(escrowed, *held)
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, `UPDATE users SET balance = balance + ? WHERE id = ?`, shares, h.UserID); err != nil {
+				return nil, fmt.Errorf("failed to update user %d balance: %w", h.UserID, err)
+			}
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+				VALUES (?, ?, 'SHARES_PAYOUT', ?, ?)
+			`, h.UserID, shares, fmt.Sprintf("Payout for %d %s shares on market #%d (cost: %d)", shares, outcome, marketID, h.Spent), marketID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to log payout transaction: %w", err)
+			}
+
+			payoutsProcessed++
+			payoutsToNotify = append(payoutsToNotify, payoutInfo{userID: h.UserID, amount: shares, betAmount: spent, outcome: outcome, isWin: true})
+			if !dryRun {
+				logger.Debug(h.UserID, "shares_paid_out", fmt.Sprintf("market_id=%d shares=%d spent=%d", marketID, shares, h.Spent))
+			}
+		}
+
+		// The creator paid the market maker's worst-case loss up front and gets back what it
+		// did not lose. Rounding trades in the market maker's favour keeps this from going negative.
+		if maker.Subsidy > 0 {
+			subsidyRefund = max(maker.Subsidy+takings-paidOut, 0)
+		}
+		if subsidyRefund > 0 {
+			held, err := escrowIfUnclaimable(ctx, tx, marketID, 0, creatorID, subsidyRefund, "MARKET_MAKER_REFUND")
+			if err != nil {
+				return nil, err
+			}
+			if held != nil {
+				escrowed = append(escrowed, *held)
+			} else {
+				if _, err := tx.ExecContext(ctx, `UPDATE users SET balance = balance + ? WHERE id = ?`, subsidyRefund, creatorID); err != nil {
+					return nil, fmt.Errorf("failed to refund market maker subsidy to user %d: %w", creatorID, err)
+				}
+				_, err = tx.ExecContext(ctx, `
+					INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+					VALUES (?, ?, 'MARKET_MAKER_REFUND', ?, ?)
+				`, creatorID, subsidyRefund, fmt.Sprintf("Market maker subsidy returned for market #%d (subsidy: %d)", marketID, maker.Subsidy), marketID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to log subsidy refund: %w", err)
+				}
+			}
+			if !dryRun {
+				logger.Debug(creatorID, "market_maker_subsidy_refunded", fmt.Sprintf("market_id=%d subsidy=%d refund=%d", marketID, maker.Subsidy, subsidyRefund))
+			}
+		}
+	} else if refunded {
 		logger.Debug(0, "market_finalization_no_winners", fmt.Sprintf("market_id=%d refunding_all", marketID))
 
 		for _, b := range bets {
+			// Vouchers are given back with the stake, less what a promo credit paid
+			credit, err := storage.ReleaseVoucherTx(ctx, tx, b.ID)
+			if err != nil {
+				return nil, err
+			}
+			refund := b.Amount - credit
+			if refund <= 0 {
+				continue
+			}
+
+			held, err := escrowIfUnclaimable(ctx, tx, marketID, b.ID, b.UserID, refund, "REFUND")
+			if err != nil {
+				return nil, err
+			}
+			if held != nil {
+				escrowed = append(escrowed, *held)
+				continue
+			}
+
 			// Refund the bet amount
 			_, err = tx.ExecContext(ctx, `
 				UPDATE users
 				SET balance = balance + ?
 				WHERE id = ?
-			`, b.Amount, b.UserID)
+			`, refund, b.UserID)
 			if err != nil {
-				return 0, fmt.Errorf("failed to refund user %d: %w", b.UserID, err)
+				return nil, fmt.Errorf("failed to refund user %d: %w", b.UserID, err)
 			}
 
 			// Log refund transaction
 			_, err = tx.ExecContext(ctx, `
-				INSERT INTO transactions (user_id, amount, source_type, description)
-				VALUES (?, ?, 'REFUND', ?)
-			`, b.UserID, b.Amount, fmt.Sprintf("Refund for bet #%d on market #%d (no winning bets)", b.ID, marketID))
+				INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+				VALUES (?, ?, 'REFUND', ?, ?)
+			`, b.UserID, refund, fmt.Sprintf("Refund for bet #%d on market #%d (no winning bets)", b.ID, marketID), marketID)
 			if err != nil {
-				return 0, fmt.Errorf("failed to log refund transaction: %w", err)
+				return nil, fmt.Errorf("failed to log refund transaction: %w", err)
 			}
 
 			payoutsProcessed++
 			payoutsToNotify = append(payoutsToNotify, payoutInfo{
 				userID:    b.UserID,
-				amount:    b.Amount,
+				amount:    refund,
 				betAmount: b.Amount,
 				outcome:   b.Outcome,
 				isWin:     false,
 			})
 		}
 	} else {
+		// The house fee and the creator reward come off the top before the pool is split
+		pool, fee, reward = payoutPool(totalPool, winningPool)
+		if fee > 0 {
+			if err := storage.CreditHouseFeeTx(ctx, tx, marketID, fee, fmt.Sprintf("House fee on market #%d (pool: %d)", marketID, totalPool)); err != nil {
+				return nil, err
+			}
+			if !dryRun {
+				logger.Debug(0, "house_fee_collected", fmt.Sprintf("market_id=%d total_pool=%d fee=%d", marketID, totalPool, fee))
+			}
+		}
+		if reward > 0 {
+			held, err := escrowIfUnclaimable(ctx, tx, marketID, 0, creatorID, reward, "CREATOR_REWARD")
+			if err != nil {
+				return nil, err
+			}
+			if held != nil {
+				escrowed = append(escrowed, *held)
+			} else {
+				if _, err := tx.ExecContext(ctx, `UPDATE users SET balance = balance + ? WHERE id = ?`, reward, creatorID); err != nil {
+					return nil, fmt.Errorf("failed to pay creator reward to user %d: %w", creatorID, err)
+				}
+				_, err = tx.ExecContext(ctx, `
+					INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+					VALUES (?, ?, 'CREATOR_REWARD', ?, ?)
+				`, creatorID, reward, fmt.Sprintf("Creator reward for market #%d (pool: %d)", marketID, totalPool), marketID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to log creator reward: %w", err)
+				}
+				if !dryRun {
+					logger.Debug(creatorID, "creator_reward_paid", fmt.Sprintf("market_id=%d total_pool=%d reward=%d", marketID, totalPool, reward))
+				}
+			}
+		}
+
 		// Calculate and distribute winnings using parimutuel formula
-		// Payout = (UserBet * TotalPool) / WinningPool
+		// Payout = (UserBet * Pool) / WinningPool
 		for _, b := range bets {
 			if b.Outcome == outcome {
 				// Calculate payout using integer arithmetic
-				payout := (b.Amount * totalPool) / winningPool
+				payout := (b.Amount * pool) / winningPool
+
+				held, err := escrowIfUnclaimable(ctx, tx, marketID, b.ID, b.UserID, payout, "WIN_PAYOUT")
+				if err != nil {
+					return nil, err
+				}
+				if held != nil {
+					escrowed = append(escrowed, *held)
+					continue
+				}
 
 				// Update user balance
 				_, err = tx.ExecContext(ctx, `
@@ -317,17 +696,17 @@ func (s *PayoutService) FinalizeMarket(ctx context.Context, marketID int64, forc
 					WHERE id = ?
 				`, payout, b.UserID)
 				if err != nil {
-					return 0, fmt.Errorf("failed to update user %d balance: %w", b.UserID, err)
+					return nil, fmt.Errorf("failed to update user %d balance: %w", b.UserID, err)
 				}
 
 				// Log win payout transaction
 				netProfit := payout - b.Amount
 				_, err = tx.ExecContext(ctx, `
-					INSERT INTO transactions (user_id, amount, source_type, description)
-					VALUES (?, ?, 'WIN_PAYOUT', ?)
-				`, b.UserID, payout, fmt.Sprintf("Win payout for bet #%d on market #%d (bet: %d, payout: %d, profit: %d)", b.ID, marketID, b.Amount, payout, netProfit))
+					INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+					VALUES (?, ?, 'WIN_PAYOUT', ?, ?)
+				`, b.UserID, payout, fmt.Sprintf("Win payout for bet #%d on market #%d (bet: %d, payout: %d, profit: %d)", b.ID, marketID, b.Amount, payout, netProfit), marketID)
 				if err != nil {
-					return 0, fmt.Errorf("failed to log win transaction: %w", err)
+					return nil, fmt.Errorf("failed to log win transaction: %w", err)
 				}
 
 				payoutsProcessed++
@@ -338,74 +717,288 @@ func (s *PayoutService) FinalizeMarket(ctx context.Context, marketID int64, forc
 					outcome:   b.Outcome,
 					isWin:     true,
 				})
-				logger.Debug(b.UserID, "payout_processed", fmt.Sprintf("bet_id=%d market_id=%d bet_amount=%d payout=%d profit=%d", b.ID, marketID, b.Amount, payout, netProfit))
+				if !dryRun {
+					logger.Debug(b.UserID, "payout_processed", fmt.Sprintf("bet_id=%d market_id=%d bet_amount=%d payout=%d profit=%d", b.ID, marketID, b.Amount, payout, netProfit))
+				}
 			} else {
+				exists, err := storage.UserExistsTx(ctx, tx, b.UserID)
+				if err != nil {
+					return nil, err
+				}
+				if !exists {
+					continue
+				}
+
+				// First-bet insurance pays the stake back
+				voucherRefund, err := storage.RefundVoucherBetTx(ctx, tx, b.ID)
+				if err != nil {
+					return nil, err
+				}
+
 				// Loss - still track for notification
 				payoutsToNotify = append(payoutsToNotify, payoutInfo{
-					userID:    b.UserID,
-					amount:    b.Amount,
-					betAmount: b.Amount,
-					outcome:   b.Outcome,
-					isWin:     false,
+					userID:        b.UserID,
+					amount:        b.Amount,
+					betAmount:     b.Amount,
+					outcome:       b.Outcome,
+					isWin:         false,
+					voucherRefund: voucherRefund,
 				})
 			}
 		}
 	}
 
+	// The vouchers of winning bets, and of lost bets without insurance, are used up
+	if err := storage.SettleMarketVouchersTx(ctx, tx, marketID); err != nil {
+		return nil, err
+	}
+
+	// Update win/loss streaks. Refunded markets don't count, and a user's result is
+	// their net profit on the market, so hedged bets that lost money count as a loss.
+	var streaks []storage.StreakUpdate
+	if !refunded {
+		profits := make(map[int64]int64)
+		var order []int64
+		for _, p := range payoutsToNotify {
+			if _, seen := profits[p.userID]; !seen {
+				order = append(order, p.userID)
+			}
+			if p.isWin {
+				profits[p.userID] += p.amount - p.betAmount
+			} else {
+				profits[p.userID] -= p.betAmount - p.voucherRefund
+			}
+		}
+		for _, userID := range order {
+			if profits[userID] == 0 {
+				continue
+			}
+			update, err := storage.UpdateStreakTx(ctx, tx, userID, profits[userID] > 0)
+			if err != nil {
+				return nil, err
+			}
+			streaks = append(streaks, update)
+		}
+	}
+
+	// Open disputes end with the market
+	if err := storage.SettleDisputesTx(ctx, tx, marketID); err != nil {
+		return nil, err
+	}
+
 	// Update market status to FINALIZED with outcome and resolved_at
-	_, err = tx.ExecContext(ctx, `
-		UPDATE markets
-		SET status = 'FINALIZED', outcome = ?, resolved_at = CURRENT_TIMESTAMP
-		WHERE id = ?
-	`, outcome, marketID)
-	if err != nil {
-		return 0, fmt.Errorf("failed to finalize market: %w", err)
+	change := storage.MarketStatusChange{
+		To:   storage.MarketStatusFinalized,
+		Set:  "outcome = ?, resolved_at = CURRENT_TIMESTAMP",
+		Args: []interface{}{outcome},
+	}
+	if _, err := storage.UpdateMarketStatusTx(ctx, tx, marketID, change); err != nil {
+		return nil, fmt.Errorf("failed to finalize market: %w", err)
+	}
+
+	preview := &FinalizationPreview{
+		MarketID:         marketID,
+		Status:           marketStatus,
+		StoredOutcome:    storedOutcome,
+		Outcome:          outcome,
+		TotalPool:        totalPool,
+		WinningPool:      winningPool,
+		Refunded:         refunded,
+		Fee:              fee,
+		CreatorReward:    reward,
+		SubsidyRefund:    subsidyRefund,
+		PayoutsProcessed: payoutsProcessed,
+		Payouts:          make([]PreviewPayout, 0, len(payoutsToNotify)),
+		Escrowed:         make([]storage.EscrowedPayout, 0, len(escrowed)),
+	}
+	if dryRun {
+		// Roll back before looking up names: the database may allow one connection only
+		if err := tx.Rollback(); err != nil {
+			return nil, fmt.Errorf("failed to roll back dry run: %w", err)
+		}
+		for _, p := range payoutsToNotify {
+			payout := PreviewPayout{UserID: p.userID, Outcome: p.outcome, BetAmount: p.betAmount}
+			switch {
+			case p.isWin:
+				payout.Result, payout.Payout = "win", p.amount
+			case refunded:
+				payout.Result, payout.Payout = "refund", p.amount
+			default:
+				payout.Result, payout.Payout = "loss", p.voucherRefund
+			}
+			preview.TotalPayout += payout.Payout
+			if user, err := storage.GetUserByID(p.userID); err == nil && user != nil {
+				payout.Username, payout.FirstName = user.Username, user.FirstName
+			}
+			preview.Payouts = append(preview.Payouts, payout)
+		}
+		// The rolled back escrow rows have no IDs
+		for _, held := range escrowed {
+			held.ID = 0
+			preview.Escrowed = append(preview.Escrowed, held)
+		}
+		logger.Debug(0, "market_finalization_previewed", fmt.Sprintf("market_id=%d outcome=%s payouts=%d escrowed=%d", marketID, outcome, payoutsProcessed, len(escrowed)))
+		return preview, nil
 	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
-		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	// Send notifications after commit (outside transaction)
-	notifService := GetNotificationService()
-	if notifService != nil {
-		go func() {
-			// 1. Broadcast finalization to public channel
-			winnersCount := 0
-			totalPayout := int64(0)
-			for _, p := range payoutsToNotify {
-				if p.isWin {
-					winnersCount++
-					totalPayout += p.amount
-				}
+	emitter := s.events()
+	go func() {
+		if len(escrowed) > 0 {
+			for _, held := range escrowed {
+				logger.Debug(0, "payout_escrowed", fmt.Sprintf("market_id=%d bet_id=%d user_id=%d amount=%d type=%s", marketID, held.BetID, held.UserID, held.Amount, held.SourceType))
 			}
-			wasDisputed := (marketStatus == string(storage.MarketStatusDisputed))
-			notifService.PublishFinalization(marketID, question, outcome, winnersCount, totalPayout, wasDisputed)
+			logger.Debug(0, "payouts_escrowed", fmt.Sprintf("market_id=%d count=%d", marketID, len(escrowed)))
+			emitter.Emit(PayoutsEscrowed{MarketID: marketID, Question: question, Payouts: escrowed})
+		}
 
-			// 2. Send individual notifications to users
-			for _, p := range payoutsToNotify {
-				user, err := storage.GetUserByID(p.userID)
-				if err != nil || user == nil {
-					continue
-				}
+		// 1. Broadcast finalization to public channel
+		winnersCount := 0
+		totalPayout := int64(0)
+		for _, p := range payoutsToNotify {
+			if p.isWin {
+				winnersCount++
+				totalPayout += p.amount
+			}
+		}
+		wasDisputed := (marketStatus == string(storage.MarketStatusDisputed))
+		topWinners, err := storage.GetTopWinners(marketID, TopWinnersAnnounced)
+		if err != nil {
+			logger.Debug(0, "top_winners_error", fmt.Sprintf("market_id=%d error=%v", marketID, err))
+		}
+		emitter.Emit(FinalizationPublished{
+			MarketID:     marketID,
+			Question:     question,
+			Outcome:      outcome,
+			WinnersCount: winnersCount,
+			TotalPayout:  totalPayout,
+			WasDisputed:  wasDisputed,
+			Fee:          fee,
+			TopWinners:   topWinners,
+		})
+
+		// Notable outcomes get an extra "Upset!" post
+		winners := make(map[int64]bool)
+		bettors := make(map[int64]bool)
+		for _, p := range payoutsToNotify {
+			bettors[p.userID] = true
+			if p.isWin {
+				winners[p.userID] = true
+			}
+		}
+		// Judged on what the winners actually share, the multiplier the post announces
+		if reason, ok := LoadUpsetConfig().DetectUpset(pool, winningPool, len(winners), len(bettors)); ok {
+			emitter.Emit(UpsetPublished{
+				MarketID:     marketID,
+				Question:     question,
+				Outcome:      outcome,
+				Reason:       reason,
+				Multiplier:   float64(pool) / float64(winningPool),
+				WinnersCount: len(winners),
+				BettorsCount: len(bettors),
+			})
+		}
 
-				if p.isWin {
-					notifService.SendWinNotification(p.userID, marketID, question, p.betAmount, p.outcome, p.amount, user.Balance)
-				} else if winningPool == 0 {
-					// Refund case
-					notifService.SendRefundNotification(p.userID, marketID, question, p.amount, user.Balance)
-				} else {
-					// Loss case
-					notifService.SendLossNotification(p.userID, marketID, question, p.amount)
+		// Deliveries retry, so they must not hold up the DMs below
+		if exporter := GetResultExporter(); exporter.Enabled() {
+			poolYes, poolNo := winningPool, totalPool-winningPool
+			if outcome == "NO" {
+				poolYes, poolNo = poolNo, poolYes
+			}
+			result := MarketResult{
+				MarketID:    marketID,
+				Question:    question,
+				Outcome:     outcome,
+				WasDisputed: wasDisputed,
+				PoolYes:     poolYes,
+				PoolNo:      poolNo,
+				PoolTotal:   totalPool,
+				Bettors:     len(bettors),
+				Winners:     len(winners),
+				TotalPayout: totalPayout,
+				FinalizedAt: time.Now().UTC(),
+			}
+			go func() {
+				if err := exporter.Export(context.Background(), result); err != nil {
+					logger.Debug(0, "result_export_failed", fmt.Sprintf("market_id=%d error=%v", marketID, err))
 				}
+			}()
+		}
+
+		// 2. Send individual notifications to users
+		for _, p := range payoutsToNotify {
+			user, err := storage.GetUserByID(p.userID)
+			if err != nil || user == nil {
+				continue
 			}
 
-			logger.Debug(0, "finalization_notifications_sent", fmt.Sprintf("market_id=%d winners=%d", marketID, winnersCount))
-		}()
-	}
+			if p.isWin {
+				emitter.Emit(WinNotice{
+					UserID:     p.userID,
+					MarketID:   marketID,
+					Question:   question,
+					BetAmount:  p.betAmount,
+					Outcome:    p.outcome,
+					Payout:     p.amount,
+					NewBalance: user.Balance,
+				})
+			} else if refunded {
+				// Refund case
+				emitter.Emit(RefundNotice{
+					UserID:     p.userID,
+					MarketID:   marketID,
+					Question:   question,
+					Amount:     p.amount,
+					NewBalance: user.Balance,
+				})
+			} else {
+				// Loss case
+				emitter.Emit(LossNotice{UserID: p.userID, MarketID: marketID, Question: question, Amount: p.amount, Refunded: p.voucherRefund})
+			}
+		}
+
+		// An escrowed reward has no creator account to tell
+		if reward > 0 {
+			creator, err := storage.GetUserByID(creatorID)
+			if err == nil && creator != nil {
+				emitter.Emit(CreatorRewardNotice{
+					UserID:     creatorID,
+					MarketID:   marketID,
+					Question:   question,
+					Amount:     reward,
+					TotalPool:  totalPool,
+					NewBalance: creator.Balance,
+				})
+			}
+		}
+
+		// 3. Celebrate milestone streaks and commiserate on broken ones, for users who want it
+		for _, streak := range streaks {
+			if !isStreakMilestone(streak.Current) && streak.Ended < StreakMilestones[0] {
+				continue
+			}
+			prefs, err := storage.GetUserPreferences(streak.UserID)
+			if err != nil || !prefs.StreakNotifications {
+				continue
+			}
+			emitter.Emit(StreakNotice{
+				UserID:   streak.UserID,
+				MarketID: marketID,
+				Question: question,
+				Streak:   streak.Current,
+				Ended:    streak.Ended,
+			})
+		}
+
+		logger.Debug(0, "finalization_notifications_sent", fmt.Sprintf("market_id=%d winners=%d", marketID, winnersCount))
+	}()
 
 	logger.Debug(0, "market_finalization_completed", fmt.Sprintf("market_id=%d outcome=%s payouts=%d", marketID, outcome, payoutsProcessed))
 
-	return payoutsProcessed, nil
+	return preview, nil
 }
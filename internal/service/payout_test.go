@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,8 +11,8 @@ import (
 )
 
 func setupTestDB(t *testing.T) {
-	// Use in-memory database for tests
-	if err := storage.InitDB(":memory:"); err != nil {
+	// In-memory SQLite, or PostgreSQL when TEST_DATABASE_URL is set
+	if err := storage.InitTestDB(); err != nil {
 		t.Fatalf("Failed to initialize test database: %v", err)
 	}
 }
@@ -47,7 +48,7 @@ func TestResolveMarket(t *testing.T) {
 	}
 
 	// Test: Resolve market as creator
-	err = payoutService.ResolveMarket(ctx, market.ID, user.ID, "YES")
+	_, err = payoutService.ResolveMarket(ctx, market.ID, user, "YES")
 	if err != nil {
 		t.Fatalf("ResolveMarket failed: %v", err)
 	}
@@ -84,7 +85,7 @@ func TestResolveMarketNotCreator(t *testing.T) {
 	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
 
 	// Test: Try to resolve market as non-creator
-	err := payoutService.ResolveMarket(ctx, market.ID, otherUser.ID, "YES")
+	_, err := payoutService.ResolveMarket(ctx, market.ID, otherUser, "YES")
 	if err == nil {
 		t.Error("Expected error when non-creator tries to resolve market")
 	}
@@ -105,7 +106,7 @@ func TestResolveMarketNotLocked(t *testing.T) {
 	market, _ := storage.CreateMarket(user.ID, "Test market question?", expiresAt)
 
 	// Test: Try to resolve market that's still ACTIVE
-	err := payoutService.ResolveMarket(ctx, market.ID, user.ID, "YES")
+	_, err := payoutService.ResolveMarket(ctx, market.ID, user, "YES")
 	if err == nil {
 		t.Error("Expected error when trying to resolve non-LOCKED market")
 	}
@@ -125,15 +126,15 @@ func TestRaiseDispute(t *testing.T) {
 	expiresAt := time.Now().Add(1 * time.Hour)
 	market, _ := storage.CreateMarket(user.ID, "Test market question?", expiresAt)
 
-	// Place a bet on the market (required to dispute)
-	storage.PlaceBet(ctx, user.ID, market.ID, "YES", 1000)
+	// Place a bet on the market (required to dispute), keeping enough for the bond
+	storage.PlaceBet(ctx, user.ID, market.ID, "YES", 900)
 
 	// Lock and resolve the market
 	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
 	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
 
 	// Test: Raise dispute on resolved market
-	err := payoutService.RaiseDispute(ctx, market.ID, user.ID)
+	err := payoutService.RaiseDispute(ctx, market.ID, user, "", "")
 	if err != nil {
 		t.Fatalf("RaiseDispute failed: %v", err)
 	}
@@ -145,6 +146,123 @@ func TestRaiseDispute(t *testing.T) {
 	}
 }
 
+func TestRaiseDisputeInvalidEvidence(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	payoutService := NewPayoutService()
+	user, _ := storage.CreateUser(44445, "testuser", "Test User")
+	market, _ := storage.CreateMarket(user.ID, "Test market question?", time.Now().Add(time.Hour))
+	storage.PlaceBet(ctx, user.ID, market.ID, "YES", 1000)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	if err := payoutService.RaiseDispute(ctx, market.ID, user, "", "javascript:alert(1)"); err == nil {
+		t.Error("Expected an error for a non-http evidence URL")
+	}
+	if err := payoutService.RaiseDispute(ctx, market.ID, user, strings.Repeat("x", MaxDisputeReasonLength+1), ""); err == nil {
+		t.Error("Expected an error for a reason that is too long")
+	}
+
+	// Nothing was recorded
+	updated, _ := storage.GetMarketByID(market.ID)
+	if updated.Status != storage.MarketStatusResolved {
+		t.Errorf("Expected market to stay RESOLVED, got %s", updated.Status)
+	}
+}
+
+func TestRaiseDisputeBond(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("DISPUTE_BOND", "150")
+
+	ctx := context.Background()
+	payoutService := NewPayoutService()
+	user, _ := storage.CreateUser(44446, "testuser", "Test User")
+	market, _ := storage.CreateMarket(user.ID, "Test market question?", time.Now().Add(time.Hour))
+	storage.PlaceBet(ctx, user.ID, market.ID, "YES", 900)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	// 100 left is not enough for the bond
+	if err := payoutService.RaiseDispute(ctx, market.ID, user, "", ""); err == nil {
+		t.Fatal("Expected an error when the bond cannot be paid")
+	}
+	updated, _ := storage.GetMarketByID(market.ID)
+	if updated.Status != storage.MarketStatusResolved {
+		t.Errorf("Expected market to stay RESOLVED, got %s", updated.Status)
+	}
+
+	t.Setenv("DISPUTE_BOND", "60")
+	if err := payoutService.RaiseDispute(ctx, market.ID, user, "", ""); err != nil {
+		t.Fatalf("RaiseDispute failed: %v", err)
+	}
+	if updated, _ := storage.GetUserByID(user.ID); updated.Balance != 40 {
+		t.Errorf("Expected the bond taken from the balance (40 left), got %d", updated.Balance)
+	}
+}
+
+func TestFinalizeMarketRefundsBondWhenOverturned(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	payoutService := NewPayoutService()
+	yes, _ := storage.CreateUser(44447, "yes", "Yes")
+	no, _ := storage.CreateUser(44448, "no", "No")
+	market, _ := storage.CreateMarket(yes.ID, "Test market question?", time.Now().Add(time.Hour))
+	storage.PlaceBet(ctx, yes.ID, market.ID, "YES", 100)
+	storage.PlaceBet(ctx, no.ID, market.ID, "NO", 100)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+	if err := payoutService.RaiseDispute(ctx, market.ID, no, "", ""); err != nil {
+		t.Fatalf("RaiseDispute failed: %v", err)
+	}
+
+	// The admin overturns the resolution: the bond comes back on top of the winnings
+	if _, err := payoutService.FinalizeMarket(ctx, market.ID, "NO"); err != nil {
+		t.Fatalf("FinalizeMarket failed: %v", err)
+	}
+	if disputer, _ := storage.GetUserByID(no.ID); disputer.Balance != 1100 {
+		t.Errorf("Expected the bond back with the 200 payout (1100 in total), got %d", disputer.Balance)
+	}
+	var status string
+	storage.DB().QueryRow(`SELECT bond_status FROM disputes WHERE market_id = ?`, market.ID).Scan(&status)
+	if status != string(storage.DisputeBondRefunded) {
+		t.Errorf("Expected the bond REFUNDED, got %q", status)
+	}
+}
+
+func TestFinalizeMarketForfeitsBondToWinners(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	payoutService := NewPayoutService()
+	yes, _ := storage.CreateUser(44449, "yes", "Yes")
+	no, _ := storage.CreateUser(44450, "no", "No")
+	market, _ := storage.CreateMarket(yes.ID, "Test market question?", time.Now().Add(time.Hour))
+	storage.PlaceBet(ctx, yes.ID, market.ID, "YES", 100)
+	storage.PlaceBet(ctx, no.ID, market.ID, "NO", 100)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+	if err := payoutService.RaiseDispute(ctx, market.ID, no, "", ""); err != nil {
+		t.Fatalf("RaiseDispute failed: %v", err)
+	}
+
+	// The admin keeps the resolution: the bond is paid out to the YES bettors
+	if _, err := payoutService.FinalizeMarket(ctx, market.ID, "YES"); err != nil {
+		t.Fatalf("FinalizeMarket failed: %v", err)
+	}
+	if winner, _ := storage.GetUserByID(yes.ID); winner.Balance != 1200 {
+		t.Errorf("Expected the winner paid 300 (1200 in total), got %d", winner.Balance)
+	}
+	if disputer, _ := storage.GetUserByID(no.ID); disputer.Balance != 800 {
+		t.Errorf("Expected the disputer to lose the bond (800 left), got %d", disputer.Balance)
+	}
+}
+
 func TestRaiseDisputeNotResolved(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
@@ -160,7 +278,7 @@ func TestRaiseDisputeNotResolved(t *testing.T) {
 	market, _ := storage.CreateMarket(user.ID, "Test market question?", expiresAt)
 
 	// Test: Try to dispute market that's still ACTIVE
-	err := payoutService.RaiseDispute(ctx, market.ID, user.ID)
+	err := payoutService.RaiseDispute(ctx, market.ID, user, "", "")
 	if err == nil {
 		t.Error("Expected error when trying to dispute non-RESOLVED market")
 	}
@@ -257,6 +375,68 @@ func TestFinalizeMarketWithForceOutcome(t *testing.T) {
 	}
 }
 
+func TestPreviewFinalization(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	recorder := NewRecordingNotifier()
+	payoutService := NewPayoutServiceWithNotifier(recorder)
+
+	creator, _ := storage.CreateUser(78001, "creator", "Creator")
+	alice, _ := storage.CreateUser(78002, "alice", "Alice")
+	bob, _ := storage.CreateUser(78003, "bob", "Bob")
+	market, _ := storage.CreateMarket(creator.ID, "Will the bridge open in May?", time.Now().Add(time.Hour))
+	_ = storage.PlaceBet(ctx, alice.ID, market.ID, "YES", 300)
+	_ = storage.PlaceBet(ctx, bob.ID, market.ID, "NO", 100)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "NO")
+
+	// Preview overriding NO with YES
+	preview, err := payoutService.PreviewFinalization(ctx, market.ID, "YES")
+	if err != nil {
+		t.Fatalf("PreviewFinalization failed: %v", err)
+	}
+	if preview.StoredOutcome != "NO" || preview.Outcome != "YES" || preview.TotalPool != 400 || preview.WinningPool != 300 {
+		t.Errorf("Unexpected preview %+v", preview)
+	}
+	if preview.PayoutsProcessed != 1 || preview.TotalPayout != 400 || len(preview.Payouts) != 2 {
+		t.Fatalf("Expected alice to win the pool, got %+v", preview)
+	}
+	if p := preview.Payouts[0]; p.UserID != alice.ID || p.Username != "alice" || p.Result != "win" || p.Payout != 400 {
+		t.Errorf("Unexpected payout %+v", p)
+	}
+	if p := preview.Payouts[1]; p.UserID != bob.ID || p.Result != "loss" || p.BetAmount != 100 || p.Payout != 0 {
+		t.Errorf("Unexpected payout %+v", p)
+	}
+
+	// Nothing was committed or sent
+	updated, _ := storage.GetMarketByID(market.ID)
+	if updated.Status != storage.MarketStatusResolved || updated.Outcome != "NO" {
+		t.Errorf("Expected the market to stay RESOLVED with NO, got %s %s", updated.Status, updated.Outcome)
+	}
+	aliceAfter, _ := storage.GetUserByID(alice.ID)
+	if aliceAfter.Balance != 700 {
+		t.Errorf("Expected alice's balance to be unchanged at 700, got %d", aliceAfter.Balance)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if events := recorder.Events(); len(events) != 0 {
+		t.Errorf("Expected no notifications from a dry run, got %+v", events)
+	}
+
+	// The real finalization pays what was previewed
+	if payouts, err := payoutService.FinalizeMarket(ctx, market.ID, "YES"); err != nil || payouts != 1 {
+		t.Fatalf("FinalizeMarket = %d, %v", payouts, err)
+	}
+	aliceAfter, _ = storage.GetUserByID(alice.ID)
+	if aliceAfter.Balance != 1100 {
+		t.Errorf("Expected alice's balance to be 1100, got %d", aliceAfter.Balance)
+	}
+	if _, err := payoutService.PreviewFinalization(ctx, market.ID, ""); err == nil || !strings.Contains(err.Error(), "cannot be finalized") {
+		t.Errorf("Expected finalized markets not to be previewed, got %v", err)
+	}
+}
+
 func TestFinalizeMarketNoWinnersRefund(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
@@ -297,13 +477,120 @@ func TestFinalizeMarketNoWinnersRefund(t *testing.T) {
 	}
 }
 
+func TestFinalizeMarketEscrowsMissingAccounts(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	if storage.UsingPostgres() {
+		t.Skip("PostgreSQL enforces foreign keys, so an account with bets cannot disappear")
+	}
+
+	ctx := context.Background()
+	recorder := NewRecordingNotifier()
+	payoutService := NewPayoutServiceWithNotifier(recorder)
+
+	creator, _ := storage.CreateUser(333333, "creator", "Creator")
+	winner, _ := storage.CreateUser(444444, "winner", "Winner")
+	gone, _ := storage.CreateUser(555555, "gone", "Gone")
+	loser, _ := storage.CreateUser(666666, "loser", "Loser")
+
+	market, _ := storage.CreateMarket(creator.ID, "Will the account survive?", time.Now().Add(time.Hour))
+	_ = storage.PlaceBet(ctx, winner.ID, market.ID, "YES", 500)
+	_ = storage.PlaceBet(ctx, gone.ID, market.ID, "YES", 500)
+	_ = storage.PlaceBet(ctx, loser.ID, market.ID, "NO", 1000)
+
+	// The account disappears before the market is finalized
+	if _, err := storage.DB().Exec(`DELETE FROM users WHERE id = ?`, gone.ID); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	payouts, err := payoutService.FinalizeMarket(ctx, market.ID, "")
+	if err != nil {
+		t.Fatalf("FinalizeMarket failed: %v", err)
+	}
+	if payouts != 1 {
+		t.Errorf("Expected 1 payout, got %d", payouts)
+	}
+
+	held, err := storage.ListEscrowedPayouts()
+	if err != nil {
+		t.Fatalf("ListEscrowedPayouts failed: %v", err)
+	}
+	if len(held) != 1 || held[0].UserID != gone.ID || held[0].Amount != 1000 || held[0].SourceType != "WIN_PAYOUT" {
+		t.Fatalf("Expected the missing account's 1000 payout in escrow, got %+v", held)
+	}
+
+	var ghostTransactions int
+	storage.DB().QueryRow(`SELECT COUNT(*) FROM transactions WHERE user_id = ? AND source_type = 'WIN_PAYOUT'`, gone.ID).Scan(&ghostTransactions)
+	if ghostTransactions != 0 {
+		t.Errorf("Expected no payout transaction for the missing account, got %d", ghostTransactions)
+	}
+
+	reported := false
+	for _, event := range recorder.WaitFor(3, time.Second) {
+		if e, ok := event.(PayoutsEscrowed); ok {
+			reported = e.MarketID == market.ID && len(e.Payouts) == 1
+		}
+		if e, ok := event.(WinNotice); ok && e.UserID == gone.ID {
+			t.Error("Expected no win notice for the missing account")
+		}
+	}
+	if !reported {
+		t.Error("Expected the escrowed payout to be reported to the admins")
+	}
+}
+
 func TestAutoFinalizationConfig(t *testing.T) {
 	// Test that DISPUTE_DELAY_MINUTES environment variable is respected
 	os.Setenv("DISPUTE_DELAY_MINUTES", "5")
 	defer os.Unsetenv("DISPUTE_DELAY_MINUTES")
 
-	worker := NewMarketWorker()
+	worker := NewMarketWorker(nil)
 	if worker.disputeDelay != 5*time.Minute {
 		t.Errorf("Expected dispute delay of 5 minutes, got %v", worker.disputeDelay)
 	}
 }
+
+func TestResolveMarketRequiresCosign(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("COSIGN_MIN_POOL", "100")
+
+	ctx := context.Background()
+	recorder := NewRecordingNotifier()
+	payoutService := NewPayoutServiceWithNotifier(recorder)
+
+	creator, _ := storage.CreateUser(44444, "creator", "Creator")
+	admin, _ := storage.CreateUser(55555, "admin", "Admin")
+	bettor, _ := storage.CreateUser(66666, "bettor", "Bettor")
+	market, _ := storage.CreateMarket(creator.ID, "Is this pool big enough for a co-signer?", time.Now().Add(time.Hour))
+	_ = storage.PlaceBet(ctx, bettor.ID, market.ID, "YES", 150)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+
+	cosign, err := payoutService.ResolveMarket(ctx, market.ID, creator, "YES")
+	if err != nil {
+		t.Fatalf("ResolveMarket failed: %v", err)
+	}
+	if cosign == nil || cosign.Status != storage.CosignStatusPending {
+		t.Fatalf("Expected a pending co-signature, got %+v", cosign)
+	}
+	if m, _ := storage.GetMarketByID(market.ID); m.Status != storage.MarketStatusLocked {
+		t.Errorf("Expected market to stay LOCKED, got %s", m.Status)
+	}
+	events := recorder.WaitFor(1, time.Second)
+	if len(events) != 1 || events[0].Kind() != "cosign_requested" {
+		t.Fatalf("Expected a cosign_requested event, got %+v", events)
+	}
+
+	if _, err := payoutService.DecideCosign(ctx, cosign.ID, admin, false, true); err == nil {
+		t.Error("Expected an error for a user who may not co-sign")
+	}
+	if _, err := payoutService.DecideCosign(ctx, cosign.ID, admin, true, true); err != nil {
+		t.Fatalf("DecideCosign failed: %v", err)
+	}
+	if m, _ := storage.GetMarketByID(market.ID); m.Status != storage.MarketStatusResolved || m.Outcome != "YES" {
+		t.Errorf("Expected RESOLVED YES, got %s %s", m.Status, m.Outcome)
+	}
+}
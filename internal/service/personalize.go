@@ -0,0 +1,67 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"predictionbot/internal/storage"
+)
+
+const (
+	// betMarketScore ranks markets the user already bet on above everything else
+	betMarketScore = 100
+	// maxTagScore caps how much a single tag can lift a market, so one busy tag does not dominate
+	maxTagScore = 10
+)
+
+// PersonalizationEnabled reports whether the market list is ranked per user.
+// Set PERSONALIZED_MARKETS=false to always list the newest markets first.
+func PersonalizationEnabled() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("PERSONALIZED_MARKETS"))) {
+	case "false", "0", "off", "no":
+		return false
+	default:
+		return true
+	}
+}
+
+// PersonalizeMarkets reorders markets (newest first, tags filled in) for userID (internal ID):
+// markets the user has bet on come first, then markets sharing tags the user often bets on.
+// Markets with the same score keep their original order.
+func PersonalizeMarkets(userID int64, markets []storage.MarketWithCreator) error {
+	if len(markets) < 2 {
+		return nil
+	}
+
+	affinity, err := storage.GetMarketAffinity(userID)
+	if err != nil {
+		return fmt.Errorf("failed to personalize markets: %w", err)
+	}
+
+	scores := make(map[int64]int, len(markets))
+	for _, m := range markets {
+		scores[m.ID] = marketScore(m, affinity)
+	}
+	sort.SliceStable(markets, func(i, j int) bool {
+		return scores[markets[i].ID] > scores[markets[j].ID]
+	})
+	return nil
+}
+
+// marketScore is the personalization score of a single market
+func marketScore(market storage.MarketWithCreator, affinity *storage.MarketAffinity) int {
+	score := 0
+	if affinity.BetMarkets[market.ID] {
+		score += betMarketScore
+	}
+	for _, tag := range market.Tags {
+		tagScore := affinity.TagBets[tag]
+		if tagScore > maxTagScore {
+			tagScore = maxTagScore
+		}
+		score += tagScore
+	}
+	return score
+}
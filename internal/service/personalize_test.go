@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestPersonalizeMarkets(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	user, _ := storage.CreateUser(12345, "fan", "Fan")
+	expiresAt := time.Now().Add(24 * time.Hour)
+
+	old, _ := storage.CreateMarket(user.ID, "Will #football season start on time?", expiresAt)
+	storage.SetMarketTags(old.ID, []string{"football"})
+	if err := storage.PlaceBet(ctx, user.ID, old.ID, "YES", 10); err != nil {
+		t.Fatalf("PlaceBet failed: %v", err)
+	}
+	other, _ := storage.CreateMarket(user.ID, "Will it rain tomorrow?", expiresAt)
+	related, _ := storage.CreateMarket(user.ID, "Will #football finals go to penalties?", expiresAt)
+	storage.SetMarketTags(related.ID, []string{"football"})
+	betOn, _ := storage.CreateMarket(user.ID, "Will the sun shine all week?", expiresAt)
+	if err := storage.PlaceBet(ctx, user.ID, betOn.ID, "NO", 10); err != nil {
+		t.Fatalf("PlaceBet failed: %v", err)
+	}
+
+	// The listing query leaves other and related in no useful order for this user
+	markets := []storage.MarketWithCreator{
+		{ID: other.ID},
+		{ID: related.ID, Tags: []string{"football"}},
+		{ID: betOn.ID},
+	}
+	if err := PersonalizeMarkets(user.ID, markets); err != nil {
+		t.Fatalf("PersonalizeMarkets failed: %v", err)
+	}
+
+	want := []int64{betOn.ID, related.ID, other.ID}
+	for i, id := range want {
+		if markets[i].ID != id {
+			t.Fatalf("Expected order %v, got %+v", want, markets)
+		}
+	}
+}
+
+func TestPersonalizationEnabled(t *testing.T) {
+	t.Setenv("PERSONALIZED_MARKETS", "")
+	if !PersonalizationEnabled() {
+		t.Error("Expected personalization to be on by default")
+	}
+	t.Setenv("PERSONALIZED_MARKETS", "false")
+	if PersonalizationEnabled() {
+		t.Error("Expected PERSONALIZED_MARKETS=false to disable personalization")
+	}
+}
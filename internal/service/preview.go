@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"predictionbot/internal/logger"
+)
+
+const (
+	// previewFetchTimeout bounds a single metadata fetch
+	previewFetchTimeout = 3 * time.Second
+	// previewCacheTTL is how long fetched (or failed) previews are reused
+	previewCacheTTL = 6 * time.Hour
+	// previewMaxBody is how much of a page is read looking for metadata
+	previewMaxBody = 512 << 10
+	// previewMaxField caps title and image URL lengths
+	previewMaxField = 300
+)
+
+// LinkPreview is the Open Graph metadata of the first link in a market question
+type LinkPreview struct {
+	URL      string `json:"url"`
+	Title    string `json:"title,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+}
+
+type previewCacheEntry struct {
+	preview   *LinkPreview
+	fetchedAt time.Time
+}
+
+// PreviewService fetches and caches link previews for allowlisted hosts only.
+// Without LINK_PREVIEW_ALLOWLIST nothing is ever fetched.
+type PreviewService struct {
+	client    *http.Client
+	allowlist []string
+	mu        sync.Mutex
+	cache     map[string]previewCacheEntry
+}
+
+var (
+	globalPreviewService *PreviewService
+	previewServiceOnce   sync.Once
+)
+
+// GetPreviewService returns the shared preview service configured from LINK_PREVIEW_ALLOWLIST
+func GetPreviewService() *PreviewService {
+	previewServiceOnce.Do(func() {
+		globalPreviewService = NewPreviewService(parseAllowlist(os.Getenv("LINK_PREVIEW_ALLOWLIST")))
+	})
+	return globalPreviewService
+}
+
+// NewPreviewService creates a preview service that only fetches from the given hosts (and their subdomains)
+func NewPreviewService(allowlist []string) *PreviewService {
+	s := &PreviewService{
+		allowlist: allowlist,
+		cache:     make(map[string]previewCacheEntry),
+	}
+	s.client = &http.Client{
+		Timeout: previewFetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return fmt.Errorf("too many redirects")
+			}
+			if !s.isAllowed(req.URL) {
+				return fmt.Errorf("redirect to %s is not allowlisted", req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+	return s
+}
+
+// parseAllowlist splits a comma-separated host list
+func parseAllowlist(value string) []string {
+	var hosts []string
+	for _, host := range strings.Split(value, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// isAllowed reports whether u is an http(s) URL on an allowlisted host
+func (s *PreviewService) isAllowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range s.allowlist {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// FirstURL returns the first link in text, with a scheme added if it was missing
+func FirstURL(text string) string {
+	match := urlPattern.FindString(text)
+	if match == "" {
+		return ""
+	}
+	match = strings.TrimRight(match, ".,!?)")
+	if !strings.HasPrefix(strings.ToLower(match), "http://") && !strings.HasPrefix(strings.ToLower(match), "https://") {
+		match = "https://" + match
+	}
+	return match
+}
+
+// Lookup returns the preview for the first allowlisted link in question, or nil.
+// Results, including failures, are cached so a question is fetched at most once per TTL.
+func (s *PreviewService) Lookup(ctx context.Context, question string) *LinkPreview {
+	if s == nil || len(s.allowlist) == 0 {
+		return nil
+	}
+
+	rawURL := FirstURL(question)
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || !s.isAllowed(u) {
+		return nil
+	}
+
+	s.mu.Lock()
+	entry, ok := s.cache[rawURL]
+	s.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < previewCacheTTL {
+		return entry.preview
+	}
+
+	preview, err := s.fetch(ctx, u)
+	if err != nil {
+		logger.Debug(0, "link_preview_failed", fmt.Sprintf("url=%s error=%v", rawURL, err))
+	}
+
+	s.mu.Lock()
+	s.cache[rawURL] = previewCacheEntry{preview: preview, fetchedAt: time.Now()}
+	s.mu.Unlock()
+
+	return preview
+}
+
+// fetch downloads the page and extracts its Open Graph title and image
+func (s *PreviewService) fetch(ctx context.Context, u *url.URL) (*LinkPreview, error) {
+	ctx, cancel := context.WithTimeout(ctx, previewFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "predictionbot-preview/1.0")
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "html") {
+		return nil, fmt.Errorf("unexpected content type %s", ct)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, previewMaxBody))
+	if err != nil {
+		return nil, err
+	}
+
+	preview := parseOpenGraph(string(body))
+	if preview.Title == "" && preview.ImageURL == "" {
+		return nil, fmt.Errorf("no preview metadata")
+	}
+	preview.URL = u.String()
+
+	// Only keep absolute http(s) thumbnails, resolving relative ones against the page
+	if preview.ImageURL != "" {
+		img, err := u.Parse(preview.ImageURL)
+		if err != nil || (img.Scheme != "http" && img.Scheme != "https") {
+			preview.ImageURL = ""
+		} else {
+			preview.ImageURL = img.String()
+		}
+	}
+
+	return preview, nil
+}
+
+var (
+	metaTagPattern  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	metaAttrPattern = regexp.MustCompile(`(?is)([a-z:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	titleTagPattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+// parseOpenGraph extracts og:title / og:image (falling back to twitter: tags and <title>)
+func parseOpenGraph(page string) *LinkPreview {
+	meta := make(map[string]string)
+	for _, tag := range metaTagPattern.FindAllString(page, -1) {
+		attrs := make(map[string]string)
+		for _, m := range metaAttrPattern.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(m[1])] = m[2] + m[3]
+		}
+		key := attrs["property"]
+		if key == "" {
+			key = attrs["name"]
+		}
+		key = strings.ToLower(key)
+		if key != "" && attrs["content"] != "" {
+			if _, exists := meta[key]; !exists {
+				meta[key] = attrs["content"]
+			}
+		}
+	}
+
+	preview := &LinkPreview{
+		Title:    firstNonEmpty(meta["og:title"], meta["twitter:title"]),
+		ImageURL: firstNonEmpty(meta["og:image"], meta["og:image:url"], meta["twitter:image"]),
+	}
+	if preview.Title == "" {
+		if m := titleTagPattern.FindStringSubmatch(page); m != nil {
+			preview.Title = m[1]
+		}
+	}
+
+	preview.Title = truncateString(SanitizeText(html.UnescapeString(preview.Title)), previewMaxField)
+	preview.ImageURL = strings.TrimSpace(html.UnescapeString(preview.ImageURL))
+	if len(preview.ImageURL) > previewMaxField {
+		preview.ImageURL = ""
+	}
+	return preview
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
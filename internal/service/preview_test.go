@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseOpenGraph(t *testing.T) {
+	page := `<html><head>
+		<title>Fallback title</title>
+		<meta property="og:title" content="Final &amp; deciding match">
+		<meta content='/img/cover.png' property='og:image'>
+		<meta name="twitter:title" content="Ignored">
+	</head></html>`
+
+	preview := parseOpenGraph(page)
+	if preview.Title != "Final & deciding match" {
+		t.Errorf("Expected og:title, got %q", preview.Title)
+	}
+	if preview.ImageURL != "/img/cover.png" {
+		t.Errorf("Expected og:image, got %q", preview.ImageURL)
+	}
+
+	fallback := parseOpenGraph(`<title> Only a
+		title </title>`)
+	if fallback.Title != "Only a title" || fallback.ImageURL != "" {
+		t.Errorf("Expected <title> fallback, got %+v", fallback)
+	}
+}
+
+func TestFirstURL(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Will https://example.com/event win?", "https://example.com/event"},
+		{"Does example.com/match, end in a draw?", "https://example.com/match"},
+		{"No links here at all", ""},
+	}
+
+	for _, tt := range tests {
+		if got := FirstURL(tt.input); got != tt.expected {
+			t.Errorf("FirstURL(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestPreviewLookup(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<meta property="og:title" content="Cup final"><meta property="og:image" content="/thumb.jpg">`)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	question := "Will the team in " + server.URL + "/final win?"
+	ctx := context.Background()
+
+	// Not allowlisted: nothing is fetched
+	if preview := NewPreviewService([]string{"example.com"}).Lookup(ctx, question); preview != nil {
+		t.Errorf("Expected no preview for a host outside the allowlist, got %+v", preview)
+	}
+	if preview := NewPreviewService(nil).Lookup(ctx, question); preview != nil {
+		t.Errorf("Expected no preview without an allowlist, got %+v", preview)
+	}
+	if atomic.LoadInt32(&hits) != 0 {
+		t.Fatalf("Expected no requests, got %d", hits)
+	}
+
+	s := NewPreviewService([]string{serverURL.Hostname()})
+	preview := s.Lookup(ctx, question)
+	if preview == nil {
+		t.Fatal("Expected a preview")
+	}
+	if preview.Title != "Cup final" {
+		t.Errorf("Expected title 'Cup final', got %q", preview.Title)
+	}
+	if preview.ImageURL != server.URL+"/thumb.jpg" {
+		t.Errorf("Expected absolute thumbnail, got %q", preview.ImageURL)
+	}
+
+	// Second lookup comes from the cache
+	s.Lookup(ctx, question)
+	if atomic.LoadInt32(&hits) != 1 {
+		t.Errorf("Expected 1 request thanks to caching, got %d", hits)
+	}
+}
+
+func TestPreviewLookupRejectsNonHTML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		fmt.Fprint(w, `<meta property="og:title" content="Binary">`)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	s := NewPreviewService([]string{serverURL.Hostname()})
+	if preview := s.Lookup(context.Background(), "Is "+server.URL+" a page?"); preview != nil {
+		t.Errorf("Expected no preview for non-HTML content, got %+v", preview)
+	}
+}
@@ -0,0 +1,491 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// A small QR code encoder for market deep links (ISO/IEC 18004): byte mode, error correction
+// level M, versions 1 to 10, which holds links of up to 213 bytes. Level M survives about 15%
+// of the code being damaged or covered, plenty for a printed poster.
+
+// qrMaxVersion is the largest supported version, 57×57 modules
+const qrMaxVersion = 10
+
+// qrQuietZone is the light border required around a code, in modules
+const qrQuietZone = 4
+
+// qrBlocks describes how a version's codewords split into Reed-Solomon blocks at level M:
+// ecPerBlock error correction codewords per block, and the data codewords of each block
+type qrBlocks struct {
+	ecPerBlock int
+	data       []int
+}
+
+var qrLevelMBlocks = [qrMaxVersion + 1]qrBlocks{
+	1:  {10, []int{16}},
+	2:  {16, []int{28}},
+	3:  {26, []int{44}},
+	4:  {18, []int{32, 32}},
+	5:  {24, []int{43, 43}},
+	6:  {16, []int{27, 27, 27, 27}},
+	7:  {18, []int{31, 31, 31, 31}},
+	8:  {22, []int{38, 38, 39, 39}},
+	9:  {22, []int{36, 36, 36, 37, 37}},
+	10: {26, []int{43, 43, 43, 43, 44}},
+}
+
+// qrAlignment lists the centre coordinates of each version's alignment patterns
+var qrAlignment = [qrMaxVersion + 1][]int{
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+// QRCode is an encoded QR code; Modules[y][x] is true for a dark module
+type QRCode struct {
+	Version int
+	Size    int
+	Modules [][]bool
+	// function marks finder, timing, alignment and format modules, which data never uses
+	function [][]bool
+}
+
+// EncodeQR encodes text as the smallest QR code that holds it
+func EncodeQR(text string) (*QRCode, error) {
+	data := []byte(text)
+	version := 0
+	for v := 1; v <= qrMaxVersion; v++ {
+		if 4+qrCountBits(v)+8*len(data) <= 8*qrDataCodewords(v) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("invalid QR code content: %d bytes is too long", len(data))
+	}
+
+	q := newQRCode(version)
+	q.drawFunctionPatterns()
+	q.drawCodewords(qrInterleave(version, qrDataBits(version, data)))
+
+	// Use the mask that leaves the fewest patterns that confuse scanners
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormatBits(mask)
+		if penalty := q.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormatBits(best)
+	return q, nil
+}
+
+// qrDataCodewords returns how many data codewords a version holds at level M
+func qrDataCodewords(version int) int {
+	total := 0
+	for _, n := range qrLevelMBlocks[version].data {
+		total += n
+	}
+	return total
+}
+
+// qrCountBits returns the length of the byte mode character count for a version
+func qrCountBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// qrDataBits encodes data in byte mode and pads it to the version's data codewords
+func qrDataBits(version int, data []byte) []byte {
+	var bits []bool
+	appendBits := func(value, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, value>>i&1 == 1)
+		}
+	}
+	appendBits(0b0100, 4)
+	appendBits(len(data), qrCountBits(version))
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+
+	capacity := 8 * qrDataCodewords(version)
+	appendBits(0, min(4, capacity-len(bits)))
+	appendBits(0, (8-len(bits)%8)%8)
+
+	codewords := make([]byte, 0, capacity/8)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for _, bit := range bits[i : i+8] {
+			b <<= 1
+			if bit {
+				b |= 1
+			}
+		}
+		codewords = append(codewords, b)
+	}
+	for pad := byte(0xEC); len(codewords) < capacity/8; pad ^= 0xEC ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+	return codewords
+}
+
+// qrInterleave splits data codewords into blocks, adds each block's error correction and
+// interleaves the blocks
+func qrInterleave(version int, data []byte) []byte {
+	layout := qrLevelMBlocks[version]
+	var blocks, ecBlocks [][]byte
+	for _, n := range layout.data {
+		blocks = append(blocks, data[:n])
+		ecBlocks = append(ecBlocks, reedSolomon(data[:n], layout.ecPerBlock))
+		data = data[n:]
+	}
+
+	var result []byte
+	for i := 0; i < layout.data[len(layout.data)-1]; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < layout.ecPerBlock; i++ {
+		for _, ec := range ecBlocks {
+			result = append(result, ec[i])
+		}
+	}
+	return result
+}
+
+// gfMul multiplies in GF(256) with the QR code polynomial x^8 + x^4 + x^3 + x^2 + 1
+func gfMul(a, b byte) byte {
+	var product byte
+	for ; b > 0; b >>= 1 {
+		if b&1 == 1 {
+			product ^= a
+		}
+		carry := a&0x80 != 0
+		a <<= 1
+		if carry {
+			a ^= 0x1D
+		}
+	}
+	return product
+}
+
+// reedSolomon returns the n error correction codewords for data
+func reedSolomon(data []byte, n int) []byte {
+	// Generator polynomial (x - α^0)(x - α^1)...(x - α^(n-1)), highest coefficient dropped
+	generator := make([]byte, n)
+	generator[n-1] = 1
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			generator[j] = gfMul(generator[j], root)
+			if j+1 < n {
+				generator[j] ^= generator[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+
+	remainder := make([]byte, n)
+	for _, b := range data {
+		factor := b ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[n-1] = 0
+		for i := range remainder {
+			remainder[i] ^= gfMul(generator[i], factor)
+		}
+	}
+	return remainder
+}
+
+func newQRCode(version int) *QRCode {
+	size := 17 + 4*version
+	q := &QRCode{Version: version, Size: size, Modules: make([][]bool, size), function: make([][]bool, size)}
+	for y := range q.Modules {
+		q.Modules[y] = make([]bool, size)
+		q.function[y] = make([]bool, size)
+	}
+	return q
+}
+
+func (q *QRCode) setFunction(x, y int, dark bool) {
+	q.Modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+// drawFunctionPatterns draws the finder, timing and alignment patterns and the version
+// information, and reserves the format information area
+func (q *QRCode) drawFunctionPatterns() {
+	for i := 0; i < q.Size; i++ {
+		q.setFunction(6, i, i%2 == 0)
+		q.setFunction(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns with their light separators
+	for _, c := range [][2]int{{3, 3}, {q.Size - 4, 3}, {3, q.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x < 0 || x >= q.Size || y < 0 || y >= q.Size {
+					continue
+				}
+				dist := max(abs(dx), abs(dy))
+				q.setFunction(x, y, dist != 2 && dist != 4)
+			}
+		}
+	}
+
+	// Alignment patterns, except where they would overlap a finder pattern
+	positions := qrAlignment[q.Version]
+	last := len(positions) - 1
+	for i, cy := range positions {
+		for j, cx := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format information; the real bits are drawn once the mask is chosen
+	q.drawFormatBits(0)
+
+	if q.Version >= 7 {
+		bits := qrVersionBits(q.Version)
+		for i := 0; i < 18; i++ {
+			dark := bits>>i&1 == 1
+			a, b := q.Size-11+i%3, i/3
+			q.setFunction(a, b, dark)
+			q.setFunction(b, a, dark)
+		}
+	}
+}
+
+// qrFormatBits returns the 15 format information bits for level M and a mask
+func qrFormatBits(mask int) int {
+	const levelM = 0b00
+	data := levelM<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// qrVersionBits returns the 18 version information bits of versions 7 and up
+func qrVersionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	return version<<12 | rem
+}
+
+// drawFormatBits draws both copies of the format information, and the dark module
+func (q *QRCode) drawFormatBits(mask int) {
+	bits := qrFormatBits(mask)
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		q.setFunction(8, i, bit(i))
+	}
+	q.setFunction(8, 7, bit(6))
+	q.setFunction(8, 8, bit(7))
+	q.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.setFunction(q.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunction(8, q.Size-15+i, bit(i))
+	}
+	q.setFunction(8, q.Size-8, true)
+}
+
+// drawCodewords places the codewords in the two-module-wide zigzag from the bottom right corner
+func (q *QRCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// Skip the vertical timing pattern
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.Size; vert++ {
+			y := vert
+			if upward {
+				y = q.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if q.function[y][x] || i >= len(codewords)*8 {
+					continue
+				}
+				q.Modules[y][x] = codewords[i>>3]>>(7-i&7)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// qrMasked reports whether a mask pattern flips the module at x, y
+func qrMasked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// applyMask flips the data modules a mask selects; applying it twice undoes it
+func (q *QRCode) applyMask(mask int) {
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if !q.function[y][x] && qrMasked(mask, x, y) {
+				q.Modules[y][x] = !q.Modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the code with the standard's four rules: long runs of one colour, 2×2
+// blocks, patterns that look like finders, and an unbalanced share of dark modules
+func (q *QRCode) penalty() int {
+	at := func(x, y int, vertical bool) bool {
+		if vertical {
+			return q.Modules[x][y]
+		}
+		return q.Modules[y][x]
+	}
+
+	penalty, dark := 0, 0
+	finder := []bool{true, false, true, true, true, false, true}
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < q.Size; y++ {
+			run := 1
+			for x := 1; x <= q.Size; x++ {
+				if x < q.Size && at(x, y, vertical) == at(x-1, y, vertical) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += 3 + run - 5
+				}
+				run = 1
+			}
+
+			for x := 0; x+7 <= q.Size; x++ {
+				matches := true
+				for i, want := range finder {
+					if at(x+i, y, vertical) != want {
+						matches = false
+						break
+					}
+				}
+				if matches && (q.lightRun(x-4, x, y, vertical) || q.lightRun(x+7, x+11, y, vertical)) {
+					penalty += 40
+				}
+			}
+		}
+	}
+
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if q.Modules[y][x] {
+				dark++
+			}
+			if x+1 < q.Size && y+1 < q.Size {
+				c := q.Modules[y][x]
+				if q.Modules[y][x+1] == c && q.Modules[y+1][x] == c && q.Modules[y+1][x+1] == c {
+					penalty += 3
+				}
+			}
+		}
+	}
+	percent := dark * 100 / (q.Size * q.Size)
+	penalty += 10 * (abs(percent-50) / 5)
+	return penalty
+}
+
+// lightRun reports whether modules from up to to (exclusive) in a row or column are all light;
+// the quiet zone outside the code counts as light
+func (q *QRCode) lightRun(from, to, line int, vertical bool) bool {
+	for i := from; i < to; i++ {
+		if i < 0 || i >= q.Size {
+			continue
+		}
+		if (vertical && q.Modules[i][line]) || (!vertical && q.Modules[line][i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// PNG renders the code as a black on white PNG, scale pixels per module, with the quiet zone
+func (q *QRCode) PNG(scale int) ([]byte, error) {
+	side := (q.Size + 2*qrQuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if !q.Modules[y][x] {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				for px := 0; px < scale; px++ {
+					img.SetColorIndex((x+qrQuietZone)*scale+px, (y+qrQuietZone)*scale+py, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
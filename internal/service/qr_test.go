@@ -0,0 +1,217 @@
+package service
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// Version 1-M "HELLO WORLD", the worked example from the QR code specification tutorials
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := reedSolomon(data, 10); !bytes.Equal(got, want) {
+		t.Errorf("Expected error correction %v, got %v", want, got)
+	}
+}
+
+func TestQRFormatAndVersionBits(t *testing.T) {
+	if got := qrFormatBits(0); got != 0b101010000010010 {
+		t.Errorf("Expected the M/0 format bits 101010000010010, got %015b", got)
+	}
+	if got := qrFormatBits(5); got != 0b100000011001110 {
+		t.Errorf("Expected the M/5 format bits 100000011001110, got %015b", got)
+	}
+	if got := qrVersionBits(7); got != 0b000111110010010100 {
+		t.Errorf("Expected the version 7 bits 000111110010010100, got %018b", got)
+	}
+}
+
+func TestEncodeQRRoundTrip(t *testing.T) {
+	for _, text := range []string{
+		"https://t.me/predict_bot?startapp=market_42",
+		"short",
+		strings.Repeat("https://t.me/a_rather_long_bot_name?startapp=market_", 4),
+	} {
+		q, err := EncodeQR(text)
+		if err != nil {
+			t.Fatalf("EncodeQR(%q) failed: %v", text, err)
+		}
+		if q.Size != 17+4*q.Version {
+			t.Errorf("Expected %d modules for version %d, got %d", 17+4*q.Version, q.Version, q.Size)
+		}
+		if got := readQR(t, q); got != text {
+			t.Errorf("Expected %q to read back, got %q", text, got)
+		}
+	}
+
+	// Filling each version to capacity exercises every block layout
+	for version, capacity := range []int{14, 26, 42, 62, 84, 106, 122, 152, 180, 213} {
+		text := strings.Repeat("q", capacity)
+		q, err := EncodeQR(text)
+		if err != nil || q.Version != version+1 {
+			t.Fatalf("Expected %d bytes to fill version %d, got %+v (%v)", capacity, version+1, q, err)
+		}
+		if got := readQR(t, q); got != text {
+			t.Errorf("Expected version %d to read back", q.Version)
+		}
+	}
+
+	if _, err := EncodeQR(strings.Repeat("x", 214)); err == nil {
+		t.Error("Expected content beyond version 10 to be rejected")
+	}
+}
+
+func TestQRCodePNG(t *testing.T) {
+	q, _ := EncodeQR("https://t.me/predict_bot?startapp=market_42")
+	data, err := q.PNG(4)
+	if err != nil {
+		t.Fatalf("PNG failed: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+	side := (q.Size + 2*qrQuietZone) * 4
+	if img.Bounds().Dx() != side || img.Bounds().Dy() != side {
+		t.Errorf("Expected %dx%d pixels, got %v", side, side, img.Bounds())
+	}
+	// The quiet zone is white and the top left finder pattern starts with a black module
+	if r, _, _, _ := img.At(0, 0).RGBA(); r != 0xffff {
+		t.Error("Expected a white quiet zone")
+	}
+	if r, _, _, _ := img.At(qrQuietZone*4, qrQuietZone*4).RGBA(); r != 0 {
+		t.Error("Expected the finder pattern to be black")
+	}
+}
+
+// readQR decodes a code the way a scanner does once it has sampled the modules: it reads the
+// format information, removes the mask, collects the codewords in placement order and
+// parses the byte mode segment from the data blocks
+func readQR(t *testing.T, q *QRCode) string {
+	t.Helper()
+
+	// Every module outside the function patterns carries a codeword bit, except for the
+	// 7 remainder bits of versions 2 to 6
+	layout := qrLevelMBlocks[q.Version]
+	want := 8 * (qrDataCodewords(q.Version) + layout.ecPerBlock*len(layout.data))
+	if q.Version >= 2 && q.Version <= 6 {
+		want += 7
+	}
+	dataModules := 0
+	for _, row := range q.function {
+		for _, function := range row {
+			if !function {
+				dataModules++
+			}
+		}
+	}
+	if dataModules != want {
+		t.Fatalf("Expected %d data modules in version %d, got %d", want, q.Version, dataModules)
+	}
+	format := 0
+	for i := 14; i >= 9; i-- {
+		format = format<<1 | qrBit(q.Modules[8][14-i])
+	}
+	format = format<<1 | qrBit(q.Modules[8][7])
+	format = format<<1 | qrBit(q.Modules[8][8])
+	format = format<<1 | qrBit(q.Modules[7][8])
+	for i := 5; i >= 0; i-- {
+		format = format<<1 | qrBit(q.Modules[i][8])
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if qrFormatBits(m) == format {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("Unreadable format information %015b", format)
+	}
+
+	var bits []int
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.Size; vert++ {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = q.Size - 1 - vert
+			}
+			for x := right; x > right-2; x-- {
+				if q.function[y][x] {
+					continue
+				}
+				dark := q.Modules[y][x]
+				if qrMasked(mask, x, y) {
+					dark = !dark
+				}
+				bits = append(bits, qrBit(dark))
+			}
+		}
+	}
+	var codewords []byte
+	for i := 0; i+8 <= len(bits); i += 8 {
+		var b byte
+		for _, bit := range bits[i : i+8] {
+			b = b<<1 | byte(bit)
+		}
+		codewords = append(codewords, b)
+	}
+
+	// De-interleave the data codewords, then check each block's error correction that follows
+	blocks := make([][]byte, len(layout.data))
+	for i := 0; i < layout.data[len(layout.data)-1]; i++ {
+		for b, n := range layout.data {
+			if i < n {
+				blocks[b] = append(blocks[b], codewords[0])
+				codewords = codewords[1:]
+			}
+		}
+	}
+	var data []byte
+	for b, block := range blocks {
+		ec := reedSolomon(block, layout.ecPerBlock)
+		for i := range ec {
+			if codewords[i*len(blocks)+b] != ec[i] {
+				t.Fatalf("Block %d has the wrong error correction", b)
+			}
+		}
+		data = append(data, block...)
+	}
+
+	if data[0]>>4 != 0b0100 {
+		t.Fatalf("Expected byte mode, got %04b", data[0]>>4)
+	}
+	// Reassemble the bit stream after the mode indicator
+	var stream []int
+	for _, b := range data {
+		for i := 7; i >= 0; i-- {
+			stream = append(stream, int(b>>i&1))
+		}
+	}
+	stream = stream[4:]
+	read := func(n int) int {
+		v := 0
+		for _, bit := range stream[:n] {
+			v = v<<1 | bit
+		}
+		stream = stream[n:]
+		return v
+	}
+	length := read(qrCountBits(q.Version))
+	text := make([]byte, length)
+	for i := range text {
+		text[i] = byte(read(8))
+	}
+	return string(text)
+}
+
+func qrBit(dark bool) int {
+	if dark {
+		return 1
+	}
+	return 0
+}
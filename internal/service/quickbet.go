@@ -0,0 +1,27 @@
+package service
+
+// QuickBetUnits are the amounts, in whole currency units, offered as bet buttons under /list
+var QuickBetUnits = []int64{50, 100, 500}
+
+// QuickBetAmounts returns QuickBetUnits in minor units, following the configured decimals
+func QuickBetAmounts() []int64 {
+	scale := int64(1)
+	for i := 0; i < GetCurrency().Decimals; i++ {
+		scale *= 10
+	}
+	amounts := make([]int64, len(QuickBetUnits))
+	for i, units := range QuickBetUnits {
+		amounts[i] = units * scale
+	}
+	return amounts
+}
+
+// IsQuickBetAmount reports whether amount, in minor units, is one of the bet buttons
+func IsQuickBetAmount(amount int64) bool {
+	for _, a := range QuickBetAmounts() {
+		if a == amount {
+			return true
+		}
+	}
+	return false
+}
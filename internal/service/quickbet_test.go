@@ -0,0 +1,23 @@
+package service
+
+import "testing"
+
+func TestQuickBetAmounts(t *testing.T) {
+	defer SetCurrency(GetCurrency())
+
+	SetCurrency(Currency{Name: "WSC"})
+	if got := QuickBetAmounts(); len(got) != 3 || got[0] != 50 || got[1] != 100 || got[2] != 500 {
+		t.Errorf("Unexpected amounts %v", got)
+	}
+	if !IsQuickBetAmount(100) || IsQuickBetAmount(75) {
+		t.Error("Expected only the preset amounts to be quick bets")
+	}
+
+	SetCurrency(Currency{Name: "WSC", Decimals: 2})
+	if got := QuickBetAmounts(); got[0] != 5000 || got[2] != 50000 {
+		t.Errorf("Expected amounts in minor units, got %v", got)
+	}
+	if IsQuickBetAmount(100) || !IsQuickBetAmount(10000) {
+		t.Error("Expected quick bets to follow the currency's decimals")
+	}
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"os"
+	"strconv"
+)
+
+// MaxRakeBps caps RAKE_BPS at 10%
+const MaxRakeBps = 1000
+
+// LoadRakeBps reads RAKE_BPS, the house fee in basis points (1/100 of a percent) taken from the
+// pool of every market that pays out winners. Missing, negative or invalid values mean no fee,
+// and values above MaxRakeBps are capped.
+func LoadRakeBps() int64 {
+	bps, err := strconv.ParseInt(os.Getenv("RAKE_BPS"), 10, 64)
+	if err != nil || bps < 0 {
+		return 0
+	}
+	return min(bps, MaxRakeBps)
+}
+
+// RakeFee is the house fee on a parimutuel pool, rounded down. It only comes out of the losing
+// side's stakes, so winners always get at least their stake back.
+func RakeFee(totalPool, winningPool, bps int64) int64 {
+	if bps <= 0 || winningPool <= 0 || totalPool <= winningPool {
+		return 0
+	}
+	return min(totalPool*bps/10000, totalPool-winningPool)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestRakeFee(t *testing.T) {
+	for _, tc := range []struct {
+		total, winning, bps, want int64
+	}{
+		{400, 100, 500, 20},
+		{400, 100, 0, 0},
+		{1000, 990, 500, 10}, // capped at the losing side
+		{400, 400, 500, 0},   // nothing lost, nothing taken
+		{400, 0, 500, 0},     // refunds pay no fee
+		{19, 10, 500, 0},     // rounded down
+	} {
+		if got := RakeFee(tc.total, tc.winning, tc.bps); got != tc.want {
+			t.Errorf("RakeFee(%d, %d, %d) = %d, want %d", tc.total, tc.winning, tc.bps, got, tc.want)
+		}
+	}
+}
+
+func TestLoadRakeBps(t *testing.T) {
+	for value, want := range map[string]int64{"": 0, "250": 250, "-5": 0, "abc": 0, "5000": MaxRakeBps} {
+		t.Setenv("RAKE_BPS", value)
+		if got := LoadRakeBps(); got != want {
+			t.Errorf("RAKE_BPS=%q: expected %d, got %d", value, want, got)
+		}
+	}
+}
+
+func TestFinalizeMarketTakesRake(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("RAKE_BPS", "500")
+
+	ctx := context.Background()
+	recorder := NewRecordingNotifier()
+	payoutService := NewPayoutServiceWithNotifier(recorder)
+	yes, _ := storage.CreateUser(44461, "yes", "Yes")
+	no, _ := storage.CreateUser(44462, "no", "No")
+	market, _ := storage.CreateMarket(yes.ID, "Will the rake be taken?", time.Now().Add(time.Hour))
+	storage.PlaceBet(ctx, yes.ID, market.ID, "YES", 100)
+	storage.PlaceBet(ctx, no.ID, market.ID, "NO", 300)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	if _, err := payoutService.FinalizeMarket(ctx, market.ID, ""); err != nil {
+		t.Fatalf("FinalizeMarket failed: %v", err)
+	}
+	// 5% of the 400 pool goes to the house, the winner gets the other 380
+	if winner, _ := storage.GetUserByID(yes.ID); winner.Balance != 1280 {
+		t.Errorf("Expected the winner paid 380 (1280 in total), got %d", winner.Balance)
+	}
+	house, err := storage.GetHouseAccount()
+	if err != nil || house == nil || house.Balance != 20 {
+		t.Fatalf("Expected the house to hold the 20 fee, got %+v, %v", house, err)
+	}
+	var feeMarket int64
+	storage.DB().QueryRow(`SELECT market_id FROM transactions WHERE user_id = ? AND source_type = 'FEE'`, house.ID).Scan(&feeMarket)
+	if feeMarket != market.ID {
+		t.Errorf("Expected a FEE transaction on market %d, got %d", market.ID, feeMarket)
+	}
+	if top, _ := storage.GetTopUsers(10); len(top) != 2 {
+		t.Errorf("Expected the house off the leaderboard, got %+v", top)
+	}
+
+	events := recorder.WaitFor(1, time.Second)
+	var published *FinalizationPublished
+	for _, e := range events {
+		if f, ok := e.(FinalizationPublished); ok {
+			published = &f
+		}
+	}
+	if published == nil || published.Fee != 20 || published.TotalPayout != 380 {
+		t.Errorf("Expected the finalization post to show the fee, got %+v", published)
+	}
+}
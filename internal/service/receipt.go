@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// OddsHidden reports whether the receipt must not show odds: the market is blind or sealed
+func (r BetReceipt) OddsHidden() bool {
+	return r.Pools.PoolsHidden || r.Pools.SidesHidden
+}
+
+// ImpliedYes is the implied YES probability in percent after the bet
+func (r BetReceipt) ImpliedYes() float64 {
+	if r.OddsHidden() || r.Pools.Yes+r.Pools.No == 0 {
+		return 0
+	}
+	return impliedYes(r.Pools.Yes, r.Pools.No)
+}
+
+// PotentialPayout is what the bet would pay if the market closed with the current pools, after
+// the house fee and the creator reward, 0 while the odds are hidden
+func (r BetReceipt) PotentialPayout() int64 {
+	if r.OddsHidden() {
+		return 0
+	}
+	side := r.Pools.Yes
+	if r.Outcome == "NO" {
+		side = r.Pools.No
+	}
+	if side == 0 {
+		return 0
+	}
+	pool, _, _ := payoutPool(r.Pools.Yes+r.Pools.No, side)
+	return r.Amount * pool / side
+}
+
+// QueueBetReceipt emits a BetReceipt for a bet placed in the Web App, unless the user turned
+// receipts off. A nil notifier uses the global notification service. The market and pools are
+// looked up in the background so the bet response is not delayed.
+func QueueBetReceipt(notifier Notifier, userID, marketID int64, outcome string, amount, newBalance int64) {
+	if notifier == nil {
+		notifier = defaultNotifier()
+	}
+
+	go func() {
+		prefs, err := storage.GetUserPreferences(userID)
+		if err != nil || !prefs.BetReceipts {
+			return
+		}
+		market, err := storage.GetMarketByID(marketID)
+		if err != nil || market == nil {
+			logger.Debug(0, "bet_receipt_error", fmt.Sprintf("market_id=%d error=%v", marketID, err))
+			return
+		}
+		pools, err := storage.GetPublicPools(marketID)
+		if err != nil {
+			logger.Debug(0, "bet_receipt_error", fmt.Sprintf("market_id=%d error=%v", marketID, err))
+			return
+		}
+		notifier.Emit(BetReceipt{
+			UserID:     userID,
+			MarketID:   marketID,
+			Question:   market.Question,
+			Icon:       market.Icon,
+			Outcome:    outcome,
+			Amount:     amount,
+			NewBalance: newBalance,
+			Pools:      pools,
+		})
+	}()
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestBetReceiptOdds(t *testing.T) {
+	receipt := BetReceipt{Outcome: "NO", Amount: 100, Pools: storage.PublicPools{Yes: 300, No: 100, Total: 400}}
+	if got := receipt.ImpliedYes(); got != 75 {
+		t.Errorf("Expected 75%% YES, got %.1f", got)
+	}
+	if got := receipt.PotentialPayout(); got != 400 {
+		t.Errorf("Expected a potential payout of 400, got %d", got)
+	}
+
+	receipt.Pools = storage.PublicPools{Total: 400, SidesHidden: true}
+	if !receipt.OddsHidden() || receipt.PotentialPayout() != 0 {
+		t.Error("Expected sealed markets to hide the odds")
+	}
+}
+
+func TestQueueBetReceipt(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := storage.CreateUser(11001, "creator", "Creator")
+	bettor, _ := storage.CreateUser(11002, "bettor", "Bettor")
+	market, _ := storage.CreateMarket(creator.ID, "Will the receipt arrive?", time.Now().Add(time.Hour))
+	if err := storage.PlaceBet(ctx, bettor.ID, market.ID, "YES", 200); err != nil {
+		t.Fatalf("PlaceBet failed: %v", err)
+	}
+
+	recorder := NewRecordingNotifier()
+	QueueBetReceipt(recorder, bettor.ID, market.ID, "YES", 200, 800)
+	events := recorder.WaitFor(1, time.Second)
+	if len(events) != 1 {
+		t.Fatalf("Expected a receipt, got %d events", len(events))
+	}
+	receipt, ok := events[0].(BetReceipt)
+	if !ok || receipt.MarketID != market.ID || receipt.Question != market.Question || receipt.PotentialPayout() != 200 {
+		t.Errorf("Unexpected receipt %+v", events[0])
+	}
+
+	if err := storage.SetBetReceipts(bettor.ID, false); err != nil {
+		t.Fatalf("SetBetReceipts failed: %v", err)
+	}
+	recorder = NewRecordingNotifier()
+	QueueBetReceipt(recorder, bettor.ID, market.ID, "YES", 200, 800)
+	if events := recorder.WaitFor(1, 100*time.Millisecond); len(events) != 0 {
+		t.Errorf("Expected no receipt once turned off, got %d events", len(events))
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"predictionbot/internal/logger"
+)
+
+const (
+	// resultExportTimeout bounds a single webhook delivery
+	resultExportTimeout = 10 * time.Second
+	// resultExportAttempts is how often a delivery is tried before the result is dropped
+	resultExportAttempts = 3
+)
+
+// MarketResult is what the results webhook receives for every finalized market
+type MarketResult struct {
+	MarketID    int64     `json:"market_id"`
+	Question    string    `json:"question"`
+	Outcome     string    `json:"outcome"`
+	WasDisputed bool      `json:"was_disputed"`
+	PoolYes     int64     `json:"pool_yes"`
+	PoolNo      int64     `json:"pool_no"`
+	PoolTotal   int64     `json:"pool_total"`
+	Bettors     int       `json:"bettors"`
+	Winners     int       `json:"winners"`
+	TotalPayout int64     `json:"total_payout"`
+	FinalizedAt time.Time `json:"finalized_at"`
+}
+
+// resultCSVHeader names the columns of a CSV delivery, in the order of csvRecord
+var resultCSVHeader = []string{"market_id", "question", "outcome", "was_disputed", "pool_yes", "pool_no", "pool_total", "bettors", "winners", "total_payout", "finalized_at"}
+
+// csvRecord renders the result as one CSV row
+func (r MarketResult) csvRecord() []string {
+	return []string{
+		strconv.FormatInt(r.MarketID, 10),
+		r.Question,
+		r.Outcome,
+		strconv.FormatBool(r.WasDisputed),
+		strconv.FormatInt(r.PoolYes, 10),
+		strconv.FormatInt(r.PoolNo, 10),
+		strconv.FormatInt(r.PoolTotal, 10),
+		strconv.Itoa(r.Bettors),
+		strconv.Itoa(r.Winners),
+		strconv.FormatInt(r.TotalPayout, 10),
+		r.FinalizedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// ResultExporter posts finalized market results to an external sink such as a spreadsheet.
+// Without RESULTS_WEBHOOK_URL nothing is exported.
+type ResultExporter struct {
+	client   *http.Client
+	endpoint string
+	format   string
+	secret   string
+}
+
+var (
+	globalResultExporter *ResultExporter
+	resultExporterOnce   sync.Once
+)
+
+// GetResultExporter returns the shared exporter configured from RESULTS_WEBHOOK_URL,
+// RESULTS_WEBHOOK_FORMAT and RESULTS_WEBHOOK_SECRET
+func GetResultExporter() *ResultExporter {
+	resultExporterOnce.Do(func() {
+		globalResultExporter = NewResultExporter(
+			strings.TrimSpace(os.Getenv("RESULTS_WEBHOOK_URL")),
+			os.Getenv("RESULTS_WEBHOOK_FORMAT"),
+			os.Getenv("RESULTS_WEBHOOK_SECRET"),
+		)
+	})
+	return globalResultExporter
+}
+
+// NewResultExporter creates an exporter. format is "json" (default) or "csv"; an empty
+// endpoint disables exporting. With a secret, every delivery is signed.
+func NewResultExporter(endpoint, format, secret string) *ResultExporter {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format != "csv" {
+		format = "json"
+	}
+	return &ResultExporter{
+		client:   &http.Client{Timeout: resultExportTimeout},
+		endpoint: endpoint,
+		format:   format,
+		secret:   secret,
+	}
+}
+
+// Enabled reports whether results are exported
+func (e *ResultExporter) Enabled() bool {
+	return e != nil && e.endpoint != ""
+}
+
+// Export delivers one result, retrying failed deliveries with a growing pause
+func (e *ResultExporter) Export(ctx context.Context, result MarketResult) error {
+	if !e.Enabled() {
+		return nil
+	}
+
+	body, contentType, err := e.encode(result)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = e.deliver(ctx, body, contentType)
+		if err == nil || attempt == resultExportAttempts {
+			return err
+		}
+		logger.Debug(0, "result_export_retry", fmt.Sprintf("market_id=%d attempt=%d error=%v", result.MarketID, attempt, err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+	}
+}
+
+// encode renders the result as a JSON object, or as a CSV header and row
+func (e *ResultExporter) encode(result MarketResult) ([]byte, string, error) {
+	if e.format == "csv" {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write(resultCSVHeader)
+		w.Write(result.csvRecord())
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return nil, "", fmt.Errorf("failed to encode result: %w", err)
+		}
+		return buf.Bytes(), "text/csv", nil
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode result: %w", err)
+	}
+	return body, "application/json", nil
+}
+
+// deliver posts the body once. The signature is the hex HMAC-SHA256 of the body.
+func (e *ResultExporter) deliver(ctx context.Context, body []byte, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if e.secret != "" {
+		mac := hmac.New(sha256.New, []byte(e.secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	// Google Apps Script web apps answer a POST with a redirect to the script's output
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
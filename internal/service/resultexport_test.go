@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResultExporter(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	var signatures []string
+	var contentTypes []string
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		signatures = append(signatures, r.Header.Get("X-Signature-256"))
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+	}))
+	defer server.Close()
+
+	result := MarketResult{MarketID: 7, Question: "Will it rain, again?", Outcome: "YES", PoolYes: 300, PoolNo: 100, PoolTotal: 400, Bettors: 3, Winners: 2, TotalPayout: 400, FinalizedAt: time.Now()}
+
+	// The first delivery fails and is retried
+	if err := NewResultExporter(server.URL, "", "s3cret").Export(context.Background(), result); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if err := NewResultExporter(server.URL, "csv", "").Export(context.Background(), result); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("Expected 2 deliveries, got %d", len(bodies))
+	}
+
+	var decoded MarketResult
+	if err := json.Unmarshal([]byte(bodies[0]), &decoded); err != nil || decoded.MarketID != 7 || decoded.PoolTotal != 400 {
+		t.Errorf("Unexpected JSON delivery %q", bodies[0])
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(bodies[0]))
+	if signatures[0] != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("Unexpected signature %q", signatures[0])
+	}
+
+	records, err := csv.NewReader(strings.NewReader(bodies[1])).ReadAll()
+	if err != nil || len(records) != 2 || records[1][1] != result.Question || records[1][6] != "400" {
+		t.Errorf("Unexpected CSV delivery %q", bodies[1])
+	}
+	if contentTypes[1] != "text/csv" || signatures[1] != "" {
+		t.Errorf("Expected an unsigned CSV delivery, got %q signed %q", contentTypes[1], signatures[1])
+	}
+
+	if NewResultExporter("", "json", "").Enabled() {
+		t.Error("Expected no endpoint to disable the exporter")
+	}
+}
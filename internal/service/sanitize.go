@@ -0,0 +1,110 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MinQuestionLength is the minimum question length in characters (runes)
+	MinQuestionLength = 10
+	// MaxQuestionLength is the maximum question length in characters (runes)
+	MaxQuestionLength = 140
+	// MaxCriteriaLength is the maximum resolution criteria length in characters (runes)
+	MaxCriteriaLength = 500
+)
+
+// URLPolicy controls what happens to links in creator-supplied text
+type URLPolicy string
+
+const (
+	// URLPolicyReject refuses text containing links (default)
+	URLPolicyReject URLPolicy = "reject"
+	// URLPolicyStrip removes links from the text
+	URLPolicyStrip URLPolicy = "strip"
+	// URLPolicyAllow keeps links as they are
+	URLPolicyAllow URLPolicy = "allow"
+)
+
+// urlPattern matches explicit URLs, www. hosts, Telegram links and bare domains with common TLDs
+var urlPattern = regexp.MustCompile(`(?i)(?:https?://|www\.|t\.me/)\S+|\b[a-z0-9-]+(?:\.[a-z0-9-]+)*\.(?:com|net|org|io|me|ru|xyz|ly|gg|co|app|link)\b(?:/\S*)?`)
+
+// getURLPolicy returns the URL policy from QUESTION_URL_POLICY, defaulting to reject
+func getURLPolicy() URLPolicy {
+	switch policy := URLPolicy(strings.ToLower(strings.TrimSpace(os.Getenv("QUESTION_URL_POLICY")))); policy {
+	case URLPolicyStrip, URLPolicyAllow:
+		return policy
+	default:
+		return URLPolicyReject
+	}
+}
+
+// SanitizeText strips control and invisible formatting characters and collapses whitespace.
+// Newlines and tabs become single spaces.
+func SanitizeText(s string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.Join(strings.Fields(cleaned), " ")
+}
+
+// SanitizeQuestion cleans a market question and validates it.
+// Used by every market creation path (API and bot) so the rules stay the same.
+func SanitizeQuestion(question string) (string, error) {
+	if !utf8.ValidString(question) {
+		return "", fmt.Errorf("invalid question: text is not valid UTF-8")
+	}
+
+	question = SanitizeText(question)
+
+	if urlPattern.MatchString(question) {
+		switch getURLPolicy() {
+		case URLPolicyReject:
+			return "", fmt.Errorf("invalid question: links are not allowed")
+		case URLPolicyStrip:
+			question = SanitizeText(urlPattern.ReplaceAllString(question, ""))
+		}
+	}
+
+	length := utf8.RuneCountInString(question)
+	if length < MinQuestionLength || length > MaxQuestionLength {
+		return "", fmt.Errorf("invalid question: must be between %d and %d characters", MinQuestionLength, MaxQuestionLength)
+	}
+
+	return question, nil
+}
+
+// SanitizeCriteria cleans optional resolution criteria and validates them with the
+// same link policy as questions. Empty criteria are allowed.
+func SanitizeCriteria(criteria string) (string, error) {
+	if !utf8.ValidString(criteria) {
+		return "", fmt.Errorf("invalid resolution criteria: text is not valid UTF-8")
+	}
+
+	criteria = SanitizeText(criteria)
+
+	if urlPattern.MatchString(criteria) {
+		switch getURLPolicy() {
+		case URLPolicyReject:
+			return "", fmt.Errorf("invalid resolution criteria: links are not allowed")
+		case URLPolicyStrip:
+			criteria = SanitizeText(urlPattern.ReplaceAllString(criteria, ""))
+		}
+	}
+
+	if utf8.RuneCountInString(criteria) > MaxCriteriaLength {
+		return "", fmt.Errorf("invalid resolution criteria: must be at most %d characters", MaxCriteriaLength)
+	}
+
+	return criteria, nil
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeQuestion(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		input     string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "plain question",
+			input:    "Will it rain tomorrow?",
+			expected: "Will it rain tomorrow?",
+		},
+		{
+			name:     "control characters and extra whitespace",
+			input:    "  Will\x00 it\n\n rain\ttomorrow?\u200b ",
+			expected: "Will it rain tomorrow?",
+		},
+		{
+			name:     "multibyte characters counted as runes",
+			input:    strings.Repeat("я", MaxQuestionLength),
+			expected: strings.Repeat("я", MaxQuestionLength),
+		},
+		{
+			name:      "too long in runes",
+			input:     strings.Repeat("я", MaxQuestionLength+1),
+			expectErr: true,
+		},
+		{
+			name:      "too short after stripping",
+			input:     "Rain?\x01\x02\x03\x04\x05\x06",
+			expectErr: true,
+		},
+		{
+			name:      "invalid utf-8",
+			input:     "Will it rain \xff tomorrow?",
+			expectErr: true,
+		},
+		{
+			name:      "link rejected by default",
+			input:     "Will https://example.com go down?",
+			expectErr: true,
+		},
+		{
+			name:     "link stripped",
+			policy:   "strip",
+			input:    "Will the site example.com go down today?",
+			expected: "Will the site go down today?",
+		},
+		{
+			name:     "link allowed",
+			policy:   "allow",
+			input:    "Will t.me/somechannel reach 1k subs?",
+			expected: "Will t.me/somechannel reach 1k subs?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("QUESTION_URL_POLICY", tt.policy)
+			result, err := SanitizeQuestion(tt.input)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("SanitizeQuestion(%q) expected error, got %q", tt.input, result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SanitizeQuestion(%q) unexpected error: %v", tt.input, err)
+			}
+			if result != tt.expected {
+				t.Errorf("SanitizeQuestion(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSanitizeCriteria(t *testing.T) {
+	t.Setenv("QUESTION_URL_POLICY", "")
+
+	if got, err := SanitizeCriteria("  YES if the\nofficial league site reports a win  "); err != nil || got != "YES if the official league site reports a win" {
+		t.Errorf("Unexpected result %q (err=%v)", got, err)
+	}
+	if got, err := SanitizeCriteria(""); err != nil || got != "" {
+		t.Errorf("Expected empty criteria to be allowed, got %q (err=%v)", got, err)
+	}
+	if _, err := SanitizeCriteria("YES if https://example.com says so"); err == nil {
+		t.Error("Expected links to be rejected by default")
+	}
+	if _, err := SanitizeCriteria(strings.Repeat("a", MaxCriteriaLength+1)); err == nil {
+		t.Error("Expected overly long criteria to be rejected")
+	}
+}
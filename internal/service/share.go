@@ -0,0 +1,162 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"predictionbot/internal/storage"
+)
+
+// shareStartPrefix prefixes the market ID in a Web App deep link's start parameter
+const shareStartPrefix = "market_"
+
+// groupStartPrefix prefixes the group chat ID in the start parameter of a group's Web App link
+const groupStartPrefix = "group_"
+
+// MarketShare is the ready-made share text for a market
+type MarketShare struct {
+	MarketID int64  `json:"market_id"`
+	Text     string `json:"text"`
+	Markdown string `json:"markdown"`
+	// DeepLink opens the market in the Web App; empty when the bot's username is unknown
+	DeepLink string `json:"deep_link,omitempty"`
+	// ImageURL is the market's PNG card for link previews; empty without WEB_APP_URL
+	ImageURL string `json:"image_url,omitempty"`
+	// QRURL is a QR code of DeepLink for printing; empty without WEB_APP_URL or a deep link
+	QRURL string `json:"qr_url,omitempty"`
+}
+
+// BuildMarketShare renders the share text for a market. question is the wording to share, e.g.
+// translated for the viewer; pools are the public pools, so blind and sealed markets stay hidden.
+func BuildMarketShare(market *storage.Market, question string, pools storage.PublicPools) MarketShare {
+	share := MarketShare{
+		MarketID: market.ID,
+		DeepLink: MarketDeepLink(market.ID),
+		ImageURL: MarketCardURL(market.ID),
+	}
+	if share.DeepLink != "" {
+		share.QRURL = MarketQRURL(market.ID)
+	}
+
+	title := strings.TrimSpace(market.Icon + " " + question)
+	status := shareStatusLine(market, pools)
+
+	text := []string{title, status}
+	markdown := []string{"*" + escapeMarkdown(title) + "*", escapeMarkdown(status)}
+	if share.DeepLink != "" {
+		text = append(text, "Bet on it: "+share.DeepLink)
+		markdown = append(markdown, "["+escapeMarkdown("Bet on it")+"]("+share.DeepLink+")")
+	}
+	share.Text = strings.Join(text, "\n")
+	share.Markdown = strings.Join(markdown, "\n")
+	return share
+}
+
+// shareStatusLine summarizes the odds, or the outcome of a finalized market
+func shareStatusLine(market *storage.Market, pools storage.PublicPools) string {
+	switch {
+	case market.Status == storage.MarketStatusFinalized && market.Outcome != "":
+		return "Resolved " + market.Outcome
+	case pools.PoolsHidden:
+		return "Blind market: pools are hidden until it closes"
+	case pools.SidesHidden:
+		return "Sealed bets · Pool " + formatBalance(pools.Total)
+	case pools.Yes+pools.No == 0:
+		return "No bets yet"
+	default:
+		yes := pools.Yes * 100 / (pools.Yes + pools.No)
+		return fmt.Sprintf("YES %d%% · NO %d%% · Pool %s", yes, 100-yes, formatBalance(pools.Yes+pools.No))
+	}
+}
+
+// MarketDeepLink returns the t.me link that opens the market in the Web App, "" when the bot's
+// username is unknown (set BOT_USERNAME, or run with a TELEGRAM_BOT_TOKEN)
+func MarketDeepLink(marketID int64) string {
+	username := botUsername()
+	if username == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://t.me/%s?startapp=%s%d", url.PathEscape(username), shareStartPrefix, marketID)
+}
+
+// botUsername returns BOT_USERNAME, or the running bot's username; "" when neither is known
+func botUsername() string {
+	username := strings.TrimPrefix(strings.TrimSpace(os.Getenv("BOT_USERNAME")), "@")
+	if username == "" {
+		if ns := GetNotificationService(); ns != nil {
+			username = ns.BotUsername()
+		}
+	}
+	return username
+}
+
+// GroupDeepLink returns the t.me link that opens the Web App on a group chat's markets, "" when
+// the bot's username is unknown
+func GroupDeepLink(chatID int64) string {
+	username := botUsername()
+	if username == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://t.me/%s?startapp=%s%d", url.PathEscape(username), groupStartPrefix, chatID)
+}
+
+// ParseGroupStartParam reads the group chat ID from a start parameter of the form group_<chat id>.
+// Group chat IDs are negative.
+func ParseGroupStartParam(param string) (int64, bool) {
+	idStr, ok := strings.CutPrefix(strings.TrimSpace(param), groupStartPrefix)
+	if !ok {
+		return 0, false
+	}
+	chatID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || chatID >= 0 {
+		return 0, false
+	}
+	return chatID, true
+}
+
+// ParseMarketStartParam reads the market ID from a start parameter of the form market_<id>, as
+// sent by /start and Web App deep links
+func ParseMarketStartParam(param string) (int64, bool) {
+	idStr, ok := strings.CutPrefix(strings.TrimSpace(param), shareStartPrefix)
+	if !ok {
+		return 0, false
+	}
+	marketID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || marketID <= 0 {
+		return 0, false
+	}
+	return marketID, true
+}
+
+// MarketWebAppURL adds ?market=<id> to the Web App's URL, so the Mini App opens on that market
+func MarketWebAppURL(base string, marketID int64) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+	query := u.Query()
+	query.Set("market", strconv.FormatInt(marketID, 10))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// MarketCardURL returns the public URL of the market's PNG image card, "" without WEB_APP_URL
+func MarketCardURL(marketID int64) string {
+	base := strings.TrimRight(strings.TrimSpace(os.Getenv("WEB_APP_URL")), "/")
+	if base == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/api/markets/%d/card.png", base, marketID)
+}
+
+// MarketQRURL returns the public URL of the QR code of the market's deep link, "" without WEB_APP_URL
+func MarketQRURL(marketID int64) string {
+	base := strings.TrimRight(strings.TrimSpace(os.Getenv("WEB_APP_URL")), "/")
+	if base == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/api/markets/%d/qr.png", base, marketID)
+}
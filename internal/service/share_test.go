@@ -0,0 +1,75 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"predictionbot/internal/storage"
+)
+
+func TestBuildMarketShare(t *testing.T) {
+	t.Setenv("BOT_USERNAME", "@predict_bot")
+	t.Setenv("WEB_APP_URL", "https://predict.example.com/")
+
+	market := &storage.Market{ID: 42, Icon: "⚽", Question: "Will Spurs win (again)?", Status: storage.MarketStatusActive}
+	share := BuildMarketShare(market, market.Question, storage.PublicPools{Yes: 300, No: 100, Total: 400})
+
+	if share.DeepLink != "https://t.me/predict_bot?startapp=market_42" {
+		t.Errorf("Unexpected deep link %q", share.DeepLink)
+	}
+	if share.ImageURL != "https://predict.example.com/api/markets/42/card.png" {
+		t.Errorf("Unexpected image URL %q", share.ImageURL)
+	}
+	if share.QRURL != "https://predict.example.com/api/markets/42/qr.png" {
+		t.Errorf("Unexpected QR code URL %q", share.QRURL)
+	}
+	if !strings.Contains(share.Text, "⚽ Will Spurs win (again)?") || !strings.Contains(share.Text, "YES 75% · NO 25%") || !strings.HasSuffix(share.Text, share.DeepLink) {
+		t.Errorf("Unexpected text %q", share.Text)
+	}
+	if !strings.Contains(share.Markdown, `win \(again\)?`) || !strings.Contains(share.Markdown, "]("+share.DeepLink+")") {
+		t.Errorf("Expected escaped Markdown with a link, got %q", share.Markdown)
+	}
+
+	// Blind pools stay hidden, and without a bot username there is no link
+	t.Setenv("BOT_USERNAME", "")
+	share = BuildMarketShare(market, market.Question, storage.PublicPools{PoolsHidden: true})
+	if share.DeepLink != "" || share.QRURL != "" || strings.Contains(share.Text, "YES") {
+		t.Errorf("Expected hidden pools and no link, got %+v", share)
+	}
+}
+
+func TestParseMarketStartParam(t *testing.T) {
+	if id, ok := ParseMarketStartParam("market_42"); !ok || id != 42 {
+		t.Errorf("Expected market 42, got %d, %v", id, ok)
+	}
+	for _, param := range []string{"", "market_", "market_abc", "market_-3", "ref_42"} {
+		if _, ok := ParseMarketStartParam(param); ok {
+			t.Errorf("Expected %q not to name a market", param)
+		}
+	}
+}
+
+func TestMarketWebAppURL(t *testing.T) {
+	if got := MarketWebAppURL("https://predict.example.com/", 42); got != "https://predict.example.com/?market=42" {
+		t.Errorf("Unexpected URL %q", got)
+	}
+	if got := MarketWebAppURL("https://predict.example.com/app?lang=de", 7); got != "https://predict.example.com/app?lang=de&market=7" {
+		t.Errorf("Expected the existing query to be kept, got %q", got)
+	}
+}
+
+func TestGroupStartParam(t *testing.T) {
+	t.Setenv("BOT_USERNAME", "predict_bot")
+	link := GroupDeepLink(-1001234)
+	if link != "https://t.me/predict_bot?startapp=group_-1001234" {
+		t.Errorf("Unexpected group link %q", link)
+	}
+	if id, ok := ParseGroupStartParam(strings.TrimPrefix(link, "https://t.me/predict_bot?startapp=")); !ok || id != -1001234 {
+		t.Errorf("Expected group -1001234, got %d, %v", id, ok)
+	}
+	for _, param := range []string{"", "group_", "group_42", "group_abc", "market_-1001234"} {
+		if _, ok := ParseGroupStartParam(param); ok {
+			t.Errorf("Expected %q not to name a group", param)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"predictionbot/internal/logger"
+)
+
+const (
+	// slackTimeout bounds a single Slack webhook post
+	slackTimeout = 10 * time.Second
+	// slackRepeatInterval is how long an identical alert is held back, so a worker failing
+	// every minute does not flood the channel
+	slackRepeatInterval = time.Hour
+)
+
+// SlackNotifier posts admin-level alerts (disputes, escalations, escrowed payouts and market
+// worker failures) to a Slack incoming webhook. Every other event is ignored, so user-facing
+// messages never reach Slack.
+type SlackNotifier struct {
+	client     *http.Client
+	webhookURL string
+
+	mu   sync.Mutex
+	sent map[string]time.Time
+}
+
+var (
+	globalSlackNotifier *SlackNotifier
+	slackNotifierOnce   sync.Once
+)
+
+// GetSlackNotifier returns the shared Slack notifier configured from SLACK_WEBHOOK_URL
+func GetSlackNotifier() *SlackNotifier {
+	slackNotifierOnce.Do(func() {
+		globalSlackNotifier = NewSlackNotifier(strings.TrimSpace(os.Getenv("SLACK_WEBHOOK_URL")))
+	})
+	return globalSlackNotifier
+}
+
+// NewSlackNotifier creates a Slack notifier; an empty webhook URL disables it
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{
+		client:     &http.Client{Timeout: slackTimeout},
+		webhookURL: webhookURL,
+		sent:       make(map[string]time.Time),
+	}
+}
+
+// Enabled reports whether alerts are posted to Slack
+func (s *SlackNotifier) Enabled() bool {
+	return s != nil && s.webhookURL != ""
+}
+
+// Emit posts the admin-level events in the background
+func (s *SlackNotifier) Emit(event NotificationEvent) {
+	if !s.Enabled() {
+		return
+	}
+	text := slackAlertText(event)
+	if text == "" || !s.due(text, time.Now()) {
+		return
+	}
+	go func() {
+		if err := s.post(text); err != nil {
+			logger.Debug(0, "slack_alert_failed", fmt.Sprintf("kind=%s error=%v", event.Kind(), err))
+		}
+	}()
+}
+
+// due reports whether an alert may be posted now, remembering it if so
+func (s *SlackNotifier) due(text string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.sent[text]; ok && now.Sub(last) < slackRepeatInterval {
+		return false
+	}
+	for key, at := range s.sent {
+		if now.Sub(at) >= slackRepeatInterval {
+			delete(s.sent, key)
+		}
+	}
+	s.sent[text] = now
+	return true
+}
+
+// post sends one message to the webhook
+func (s *SlackNotifier) post(text string) error {
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// slackAlertText renders an admin-level event for Slack, or "" for events Slack does not get
+func slackAlertText(event NotificationEvent) string {
+	switch e := event.(type) {
+	case DisputeAlert:
+		text := fmt.Sprintf(":warning: Market #%d was disputed: %s", e.MarketID, truncateString(e.Question, 100))
+		if e.Reason != "" {
+			text += "\nReason: " + truncateString(e.Reason, 200)
+		}
+		if e.EvidenceURL != "" {
+			text += "\nEvidence: " + e.EvidenceURL
+		}
+		return text
+	case ProposalEscalated:
+		if e.Proposal == nil {
+			return ""
+		}
+		return fmt.Sprintf(":scales: Community resolution of market #%d was escalated to the admins: %s", e.Proposal.MarketID, truncateString(e.Question, 100))
+	case PayoutsEscrowed:
+		if len(e.Payouts) == 0 {
+			return ""
+		}
+		return fmt.Sprintf(":lock: Finalizing market #%d held %d payouts in escrow for missing accounts: %s", e.MarketID, len(e.Payouts), truncateString(e.Question, 100))
+	case FinalizationStuck:
+		return fmt.Sprintf(":sos: Gave up finalizing market #%d after %d attempts, it needs attention: %s\nLast error: %s", e.MarketID, e.Failures, truncateString(e.Question, 100), truncateString(e.Error, 300))
+	case WorkerFailed:
+		if e.MarketID != 0 {
+			return fmt.Sprintf(":rotating_light: Market worker task %s failed for market #%d: %s", e.Task, e.MarketID, e.Error)
+		}
+		return fmt.Sprintf(":rotating_light: Market worker task %s failed: %s", e.Task, e.Error)
+	default:
+		return ""
+	}
+}
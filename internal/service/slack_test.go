@@ -0,0 +1,61 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSlackNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		texts = append(texts, payload.Text)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	slack := NewSlackNotifier(server.URL)
+	slack.Emit(DisputeAlert{MarketID: 3, Question: "Was it fair?", DisputedBy: 1})
+	slack.Emit(WorkerFailed{Task: "finalize", MarketID: 4, Error: "database is locked"})
+	// Repeats are held back and user-facing events never reach Slack
+	slack.Emit(WorkerFailed{Task: "finalize", MarketID: 4, Error: "database is locked"})
+	slack.Emit(WinNotice{UserID: 1, MarketID: 3, Question: "Was it fair?"})
+	slack.Emit(FinalizationStuck{MarketID: 5, Question: "Will it ever pay?", Failures: 3, Error: "payout failed"})
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(texts)
+		mu.Unlock()
+		if n >= 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(texts) != 3 {
+		t.Fatalf("Expected 3 Slack messages, got %q", texts)
+	}
+	joined := strings.Join(texts, "\n")
+	if !strings.Contains(joined, "Market #3 was disputed") || !strings.Contains(joined, "finalize failed for market #4") ||
+		!strings.Contains(joined, "finalizing market #5 after 3 attempts") {
+		t.Errorf("Unexpected Slack messages %q", texts)
+	}
+
+	if NewSlackNotifier("").Enabled() {
+		t.Error("Expected no webhook URL to disable Slack")
+	}
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+const (
+	// DefaultSportsPollInterval is how often the sports worker reads the fixtures feed
+	DefaultSportsPollInterval = 30 * time.Minute
+	// DefaultSportsHorizon is how far ahead of kickoff fixtures are queued for review
+	DefaultSportsHorizon = 7 * 24 * time.Hour
+	// sportsFeedTimeout bounds a single read of the fixtures feed
+	sportsFeedTimeout = 15 * time.Second
+)
+
+// Fixture statuses as reported by the feed
+const (
+	FeedStatusScheduled = "scheduled"
+	FeedStatusFinished  = "finished"
+	FeedStatusCancelled = "cancelled"
+)
+
+// Fixture is a match as reported by the sports feed. Scores are only read once the match is
+// finished.
+type Fixture struct {
+	ID        string    `json:"id"`
+	League    string    `json:"league"`
+	HomeTeam  string    `json:"home_team"`
+	AwayTeam  string    `json:"away_team"`
+	Kickoff   time.Time `json:"kickoff"`
+	Status    string    `json:"status"`
+	HomeScore *int      `json:"home_score"`
+	AwayScore *int      `json:"away_score"`
+}
+
+// FixtureSource reads the current fixtures from a sports feed
+type FixtureSource interface {
+	Fixtures(ctx context.Context) ([]Fixture, error)
+}
+
+// HTTPFixtureSource reads fixtures from a JSON endpoint answering {"fixtures": [...]}
+type HTTPFixtureSource struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewHTTPFixtureSource creates a feed reader for url; a non-empty apiKey is sent as a bearer token
+func NewHTTPFixtureSource(url, apiKey string) *HTTPFixtureSource {
+	return &HTTPFixtureSource{url: url, apiKey: apiKey, client: &http.Client{Timeout: sportsFeedTimeout}}
+}
+
+// LoadFixtureSource reads SPORTS_API_URL and SPORTS_API_KEY; nil when no feed is configured
+func LoadFixtureSource() FixtureSource {
+	url := strings.TrimSpace(os.Getenv("SPORTS_API_URL"))
+	if url == "" {
+		return nil
+	}
+	return NewHTTPFixtureSource(url, os.Getenv("SPORTS_API_KEY"))
+}
+
+// Fixtures fetches and decodes the feed
+func (s *HTTPFixtureSource) Fixtures(ctx context.Context) ([]Fixture, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var feed struct {
+		Fixtures []Fixture `json:"fixtures"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("failed to decode fixtures: %w", err)
+	}
+	return feed.Fixtures, nil
+}
+
+// FixtureQuestion is the question of a fixture's match market
+func FixtureQuestion(f *storage.SportsFixture) string {
+	return AddCategoryTag(fmt.Sprintf("Will %s beat %s?", f.HomeTeam, f.AwayTeam), "sports")
+}
+
+// FixtureCriteria is the resolution criteria of a fixture's match market
+func FixtureCriteria(f *storage.SportsFixture) string {
+	match := f.KickoffAt.UTC().Format("Jan 2 15:04 UTC")
+	if f.League != "" {
+		match = f.League + ", " + match
+	}
+	return fmt.Sprintf("YES if %s win (%s) by the final score from the fixtures feed. A draw or a %s win is NO.",
+		f.HomeTeam, match, f.AwayTeam)
+}
+
+// FixtureOutcome is the outcome of a match market for a final score: YES only on a home win
+func FixtureOutcome(homeScore, awayScore int) string {
+	if homeScore > awayScore {
+		return string(storage.OutcomeYes)
+	}
+	return string(storage.OutcomeNo)
+}
+
+// ApproveFixture creates the match market of a pending fixture, owned by the approving admin,
+// locking at kickoff. Approving again after a failure reuses the market created the first time.
+func ApproveFixture(ctx context.Context, admin *storage.User, fixtureID int64) (*storage.SportsFixture, error) {
+	fixture, err := storage.GetFixture(fixtureID)
+	if err != nil {
+		return nil, err
+	}
+	if fixture == nil {
+		return nil, fmt.Errorf("fixture not found")
+	}
+	if fixture.Status != storage.FixturePending {
+		return nil, fmt.Errorf("fixture not pending: status is %s", fixture.Status)
+	}
+	if !fixture.KickoffAt.After(time.Now()) {
+		return nil, fmt.Errorf("invalid fixture: kickoff has passed")
+	}
+
+	market, err := NewMarketService().CreateMarket(ctx, admin, FixtureQuestion(fixture), fixture.KickoffAt, FixtureCriteria(fixture),
+		storage.MarketOptions{IdempotencyKey: "fixture-" + strconv.FormatInt(fixture.ID, 10)})
+	var dupErr *DuplicateMarketError
+	if errors.As(err, &dupErr) {
+		market, err = dupErr.Market, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.ApproveFixture(fixture.ID, market.ID, admin.ID); err != nil {
+		return nil, err
+	}
+
+	logger.Debug(admin.TelegramID, "fixture_approved", fmt.Sprintf("fixture_id=%d market_id=%d", fixture.ID, market.ID))
+	return storage.GetFixture(fixture.ID)
+}
+
+// RejectFixture turns a pending fixture down
+func RejectFixture(admin *storage.User, fixtureID int64) (*storage.SportsFixture, error) {
+	fixture, err := storage.GetFixture(fixtureID)
+	if err != nil {
+		return nil, err
+	}
+	if fixture == nil {
+		return nil, fmt.Errorf("fixture not found")
+	}
+	if err := storage.RejectFixture(fixture.ID, admin.ID); err != nil {
+		return nil, err
+	}
+	logger.Debug(admin.TelegramID, "fixture_rejected", fmt.Sprintf("fixture_id=%d", fixture.ID))
+	return storage.GetFixture(fixture.ID)
+}
+
+// SportsWorker reads the fixtures feed on a schedule. Upcoming fixtures are queued for admin
+// review, and the match markets of approved fixtures are resolved once the feed reports the
+// final score.
+type SportsWorker struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	source   FixtureSource
+	interval time.Duration
+	horizon  time.Duration
+	now      func() time.Time
+}
+
+// NewSportsWorker creates a worker for source, with SPORTS_POLL_MINUTES and SPORTS_HORIZON_DAYS
+// from the environment
+func NewSportsWorker(source FixtureSource) *SportsWorker {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &SportsWorker{
+		ctx:      ctx,
+		cancel:   cancel,
+		source:   source,
+		interval: DefaultSportsPollInterval,
+		horizon:  DefaultSportsHorizon,
+		now:      time.Now,
+	}
+	if minutes, err := strconv.Atoi(os.Getenv("SPORTS_POLL_MINUTES")); err == nil && minutes > 0 {
+		w.interval = time.Duration(minutes) * time.Minute
+	}
+	if days, err := strconv.Atoi(os.Getenv("SPORTS_HORIZON_DAYS")); err == nil && days > 0 {
+		w.horizon = time.Duration(days) * 24 * time.Hour
+	}
+	return w
+}
+
+// Start syncs right away and then on every interval
+func (w *SportsWorker) Start() {
+	logger.Debug(0, "sports_worker_started", fmt.Sprintf("interval=%v horizon=%v", w.interval, w.horizon))
+	ticker := time.NewTicker(w.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			if err := w.Sync(w.ctx); err != nil {
+				logger.Debug(0, "sports_worker_error", "error="+err.Error())
+			}
+			select {
+			case <-ticker.C:
+			case <-w.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the worker
+func (w *SportsWorker) Stop() {
+	w.cancel()
+	logger.Debug(0, "sports_worker_stopped", "")
+}
+
+// Sync reads the feed once: it queues new fixtures kicking off within the horizon and resolves
+// or cancels approved fixtures the feed reports as finished or called off
+func (w *SportsWorker) Sync(ctx context.Context) error {
+	fixtures, err := w.source.Fixtures(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	now := w.now()
+	queued := 0
+	for _, f := range fixtures {
+		f.HomeTeam, f.AwayTeam = strings.TrimSpace(f.HomeTeam), strings.TrimSpace(f.AwayTeam)
+		if f.ID == "" || f.HomeTeam == "" || f.AwayTeam == "" || f.Kickoff.IsZero() {
+			continue
+		}
+
+		switch f.Status {
+		case FeedStatusScheduled:
+			if !f.Kickoff.After(now) || f.Kickoff.After(now.Add(w.horizon)) {
+				continue
+			}
+			created, err := storage.SaveFixture(f.ID, strings.TrimSpace(f.League), f.HomeTeam, f.AwayTeam, f.Kickoff)
+			if err != nil {
+				return err
+			}
+			if created {
+				queued++
+			}
+		case FeedStatusFinished, FeedStatusCancelled:
+			stored, err := storage.GetFixtureByExternalID(f.ID)
+			if err != nil {
+				return err
+			}
+			if stored == nil || stored.Status != storage.FixtureApproved {
+				continue
+			}
+			if f.Status == FeedStatusCancelled {
+				err = storage.CancelFixture(stored.ID)
+				logger.Debug(0, "fixture_cancelled", fmt.Sprintf("fixture_id=%d market_id=%d", stored.ID, stored.MarketID))
+			} else if f.HomeScore != nil && f.AwayScore != nil {
+				err = w.resolve(ctx, stored, *f.HomeScore, *f.AwayScore)
+			}
+			if err != nil {
+				logger.Debug(0, "fixture_resolve_failed", fmt.Sprintf("fixture_id=%d error=%v", stored.ID, err))
+			}
+		}
+	}
+
+	logger.Debug(0, "sports_worker_synced", fmt.Sprintf("fixtures=%d queued=%d", len(fixtures), queued))
+	return nil
+}
+
+// resolve resolves a fixture's match market from the final score on behalf of its creator.
+// A market that has not locked yet waits for the next sync; one settled by hand only gets the
+// score recorded.
+func (w *SportsWorker) resolve(ctx context.Context, fixture *storage.SportsFixture, homeScore, awayScore int) error {
+	market, err := storage.GetMarketByID(fixture.MarketID)
+	if err != nil {
+		return err
+	}
+	if market == nil {
+		return fmt.Errorf("market not found")
+	}
+
+	switch market.Status {
+	case storage.MarketStatusActive, storage.MarketStatusLastCall:
+		return nil
+	case storage.MarketStatusLocked:
+		creator, err := storage.GetUserByID(market.CreatorID)
+		if err != nil {
+			return err
+		}
+		outcome := FixtureOutcome(homeScore, awayScore)
+		if _, err := NewPayoutService().ResolveMarket(ctx, market.ID, creator, outcome); err != nil {
+			return err
+		}
+		logger.Debug(0, "fixture_resolved", fmt.Sprintf("fixture_id=%d market_id=%d score=%d-%d outcome=%s", fixture.ID, market.ID, homeScore, awayScore, outcome))
+	}
+	return storage.ResolveFixture(fixture.ID, homeScore, awayScore)
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+// staticFixtures is a FixtureSource serving a fixed feed
+type staticFixtures []Fixture
+
+func (s *staticFixtures) Fixtures(ctx context.Context) ([]Fixture, error) {
+	return *s, nil
+}
+
+func TestSportsWorker(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	kickoff := now.Add(48 * time.Hour)
+	score := func(n int) *int { return &n }
+	feed := &staticFixtures{
+		{ID: "m-1", League: "Premier League", HomeTeam: "Arsenal", AwayTeam: "Chelsea", Kickoff: kickoff, Status: FeedStatusScheduled},
+		// Too far ahead, already started, or incomplete: not queued
+		{ID: "m-2", HomeTeam: "Inter", AwayTeam: "Milan", Kickoff: now.Add(30 * 24 * time.Hour), Status: FeedStatusScheduled},
+		{ID: "m-3", HomeTeam: "Ajax", AwayTeam: "PSV", Kickoff: now.Add(-time.Hour), Status: FeedStatusScheduled},
+		{ID: "m-4", HomeTeam: "Celtic", Kickoff: kickoff, Status: FeedStatusScheduled},
+	}
+	worker := NewSportsWorker(feed)
+	worker.now = func() time.Time { return now }
+
+	if err := worker.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	pending, _ := storage.ListFixtures(storage.FixturePending, 10)
+	if len(pending) != 1 || pending[0].ExternalID != "m-1" {
+		t.Fatalf("Expected only the upcoming complete fixture to be queued, got %+v", pending)
+	}
+
+	admin, _ := storage.CreateUser(7201, "admin", "Admin")
+	fixture, err := ApproveFixture(context.Background(), admin, pending[0].ID)
+	if err != nil {
+		t.Fatalf("ApproveFixture failed: %v", err)
+	}
+	market, _ := storage.GetMarketByID(fixture.MarketID)
+	if fixture.Status != storage.FixtureApproved || market == nil || market.Question != "Will Arsenal beat Chelsea? #sports" ||
+		!market.ExpiresAt.Equal(kickoff) || market.CreatorID != admin.ID {
+		t.Fatalf("Unexpected approval %+v of market %+v", fixture, market)
+	}
+	if _, err := ApproveFixture(context.Background(), admin, fixture.ID); err == nil {
+		t.Error("Expected an approved fixture not to be approved again")
+	}
+
+	// The final score waits for the market to lock
+	(*feed)[0].Status, (*feed)[0].HomeScore, (*feed)[0].AwayScore = FeedStatusFinished, score(1), score(1)
+	worker.Sync(context.Background())
+	if fixture, _ = storage.GetFixture(fixture.ID); fixture.Status != storage.FixtureApproved {
+		t.Errorf("Expected the fixture to wait for the lock, got %s", fixture.Status)
+	}
+
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	worker.Sync(context.Background())
+	market, _ = storage.GetMarketByID(market.ID)
+	fixture, _ = storage.GetFixture(fixture.ID)
+	if market.Status != storage.MarketStatusResolved || market.Outcome != "NO" || fixture.Status != storage.FixtureResolved || *fixture.HomeScore != 1 {
+		t.Errorf("Expected a draw to resolve NO, got market %s/%s and fixture %+v", market.Status, market.Outcome, fixture)
+	}
+}
+
+func TestFixtureOutcome(t *testing.T) {
+	if FixtureOutcome(2, 1) != "YES" || FixtureOutcome(1, 1) != "NO" || FixtureOutcome(0, 3) != "NO" {
+		t.Error("Expected only a home win to resolve YES")
+	}
+}
+
+func TestHTTPFixtureSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"fixtures": [{"id": "m-1", "home_team": "Arsenal", "away_team": "Chelsea",
+			"kickoff": "2026-05-09T15:00:00Z", "status": "finished", "home_score": 2, "away_score": 0}]}`))
+	}))
+	defer server.Close()
+
+	fixtures, err := NewHTTPFixtureSource(server.URL, "key").Fixtures(context.Background())
+	if err != nil || len(fixtures) != 1 || fixtures[0].HomeTeam != "Arsenal" || *fixtures[0].HomeScore != 2 ||
+		!fixtures[0].Kickoff.Equal(time.Date(2026, 5, 9, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected fixtures %+v, %v", fixtures, err)
+	}
+	if _, err := NewHTTPFixtureSource(server.URL, "").Fixtures(context.Background()); err == nil {
+		t.Error("Expected a refused request to fail")
+	}
+}
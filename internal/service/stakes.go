@@ -0,0 +1,93 @@
+package service
+
+import "math"
+
+// DefaultStakeMaxShift is how many percentage points of implied probability a suggested stake may move
+const DefaultStakeMaxShift = 5.0
+
+// stakeFractions are the shares of the largest sensible stake offered as presets
+var stakeFractions = []float64{0.1, 0.25, 0.5, 1}
+
+// StakeSuggestion holds the preset amounts for betting on one outcome
+type StakeSuggestion struct {
+	// MaxStake is the largest stake that keeps the odds move within the shift, capped by the balance
+	MaxStake int64   `json:"max_stake"`
+	Amounts  []int64 `json:"amounts"`
+}
+
+// SuggestedStakes are bet presets for a user on a market
+type SuggestedStakes struct {
+	MarketID int64           `json:"market_id"`
+	Balance  int64           `json:"balance"`
+	PoolYes  int64           `json:"pool_yes"`
+	PoolNo   int64           `json:"pool_no"`
+	MaxShift float64         `json:"max_shift"`
+	Yes      StakeSuggestion `json:"yes"`
+	No       StakeSuggestion `json:"no"`
+}
+
+// MaxStakeForShift returns the largest stake on a side that holds side of a total pool which
+// raises that side's implied probability by at most shift percentage points.
+// ok is false when no stake can exceed the shift, e.g. while the pool is empty.
+func MaxStakeForShift(side, total int64, shift float64) (stake int64, ok bool) {
+	if total <= 0 {
+		return 0, false
+	}
+	target := float64(side)/float64(total) + shift/100
+	if target >= 1 {
+		return 0, false
+	}
+	// Solve (side+x)/(total+x) = target for x
+	x := (target*float64(total) - float64(side)) / (1 - target)
+	if x <= 0 {
+		return 0, true
+	}
+	return int64(math.Floor(x)), true
+}
+
+// SuggestStakes offers preset bet amounts for both outcomes: fractions of the largest stake
+// that the balance allows and that moves the odds by at most maxShift percentage points
+func SuggestStakes(marketID, balance, poolYes, poolNo int64, maxShift float64) SuggestedStakes {
+	return SuggestedStakes{
+		MarketID: marketID,
+		Balance:  balance,
+		PoolYes:  poolYes,
+		PoolNo:   poolNo,
+		MaxShift: maxShift,
+		Yes:      suggestSide(balance, poolYes, poolYes+poolNo, maxShift),
+		No:       suggestSide(balance, poolNo, poolYes+poolNo, maxShift),
+	}
+}
+
+func suggestSide(balance, side, total int64, maxShift float64) StakeSuggestion {
+	limit := balance
+	if limit < 0 {
+		limit = 0
+	}
+	if stake, ok := MaxStakeForShift(side, total, maxShift); ok && stake < limit {
+		limit = stake
+	}
+
+	suggestion := StakeSuggestion{MaxStake: limit, Amounts: []int64{}}
+	for _, fraction := range stakeFractions {
+		amount := roundStake(int64(float64(limit) * fraction))
+		if fraction == 1 {
+			amount = limit
+		}
+		n := len(suggestion.Amounts)
+		if amount < 1 || (n > 0 && suggestion.Amounts[n-1] >= amount) {
+			continue
+		}
+		suggestion.Amounts = append(suggestion.Amounts, amount)
+	}
+	return suggestion
+}
+
+// roundStake rounds an amount down to two significant digits so presets read nicely (1234 -> 1200)
+func roundStake(amount int64) int64 {
+	unit := int64(1)
+	for amount/unit >= 100 {
+		unit *= 10
+	}
+	return amount / unit * unit
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMaxStakeForShift(t *testing.T) {
+	// 50/50 pool of 1000: moving YES from 50% to 55% takes 111 more on YES
+	stake, ok := MaxStakeForShift(500, 1000, 5)
+	if !ok || stake != 111 {
+		t.Fatalf("Expected 111, got %d (ok=%v)", stake, ok)
+	}
+	if after := impliedYes(500+stake, 500); after-50 > 5 {
+		t.Errorf("Stake moves odds by %.2f points", after-50)
+	}
+	if after := impliedYes(500+stake+1, 500); after-50 <= 5 {
+		t.Errorf("Expected one more to exceed the shift, got %.2f points", after-50)
+	}
+
+	if _, ok := MaxStakeForShift(0, 0, 5); ok {
+		t.Error("Expected no limit on an empty pool")
+	}
+	if _, ok := MaxStakeForShift(980, 1000, 5); ok {
+		t.Error("Expected no limit when the side cannot move 5 points")
+	}
+}
+
+func TestSuggestStakes(t *testing.T) {
+	stakes := SuggestStakes(7, 5000, 4000, 6000, DefaultStakeMaxShift)
+	// YES: 40% -> 45% takes 909; NO: 60% -> 65% takes 1428
+	if stakes.Yes.MaxStake != 909 || stakes.No.MaxStake != 1428 {
+		t.Fatalf("Unexpected max stakes: %+v", stakes)
+	}
+	if !reflect.DeepEqual(stakes.Yes.Amounts, []int64{90, 220, 450, 909}) {
+		t.Errorf("Unexpected YES amounts %v", stakes.Yes.Amounts)
+	}
+
+	// A small balance caps every suggestion
+	poor := SuggestStakes(7, 3, 4000, 6000, DefaultStakeMaxShift)
+	if poor.Yes.MaxStake != 3 || !reflect.DeepEqual(poor.Yes.Amounts, []int64{1, 3}) {
+		t.Errorf("Expected balance-capped amounts, got %+v", poor.Yes)
+	}
+
+	// Without bets the balance is the only limit
+	fresh := SuggestStakes(7, 1000, 0, 0, DefaultStakeMaxShift)
+	if fresh.No.MaxStake != 1000 || !reflect.DeepEqual(fresh.No.Amounts, []int64{100, 250, 500, 1000}) {
+		t.Errorf("Unexpected amounts on an empty pool: %+v", fresh.No)
+	}
+
+	broke := SuggestStakes(7, 0, 10, 10, DefaultStakeMaxShift)
+	if broke.Yes.MaxStake != 0 || len(broke.Yes.Amounts) != 0 {
+		t.Errorf("Expected no suggestions without balance, got %+v", broke.Yes)
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+// CalibrationCacheTTL is how long a calibration report is reused; it only moves as markets finalize
+const CalibrationCacheTTL = 24 * time.Hour
+
+// CalibrationReport is the platform calibration chart: for each decile of implied YES
+// probability at lock, the fraction of markets that actually resolved YES
+type CalibrationReport struct {
+	GeneratedAt time.Time                   `json:"generated_at"`
+	Markets     int                         `json:"markets"`
+	Buckets     []storage.CalibrationBucket `json:"buckets"`
+}
+
+// StatsService computes platform statistics and caches the expensive ones
+type StatsService struct {
+	mu          sync.Mutex
+	calibration *CalibrationReport
+	now         func() time.Time
+}
+
+var (
+	globalStatsService *StatsService
+	statsServiceOnce   sync.Once
+)
+
+// GetStatsService returns the shared stats service, so the cache is shared across requests
+func GetStatsService() *StatsService {
+	statsServiceOnce.Do(func() {
+		globalStatsService = NewStatsService()
+	})
+	return globalStatsService
+}
+
+// NewStatsService creates a stats service with an empty cache
+func NewStatsService() *StatsService {
+	return &StatsService{now: time.Now}
+}
+
+// Calibration returns the calibration report, recomputing it at most once per CalibrationCacheTTL
+func (s *StatsService) Calibration(ctx context.Context) (*CalibrationReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.calibration != nil && s.now().Sub(s.calibration.GeneratedAt) < CalibrationCacheTTL {
+		return s.calibration, nil
+	}
+
+	buckets, err := storage.GetCalibration()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CalibrationReport{GeneratedAt: s.now().UTC(), Buckets: buckets}
+	for _, b := range buckets {
+		report.Markets += b.Markets
+	}
+	s.calibration = report
+	return report, nil
+}
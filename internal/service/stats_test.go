@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestStatsServiceCalibrationCache(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := storage.CreateUser(5001, "creator", "Creator")
+	bettor, _ := storage.CreateUser(5002, "bettor", "Bettor")
+
+	settle := func(question string) {
+		market, _ := storage.CreateMarket(creator.ID, question, time.Now().Add(time.Hour))
+		storage.PlaceBet(ctx, bettor.ID, market.ID, "YES", 10)
+		storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+		storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+		storage.UpdateMarketStatus(market.ID, storage.MarketStatusFinalized, "YES")
+	}
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewStatsService()
+	s.now = func() time.Time { return now }
+
+	settle("First?")
+	report, err := s.Calibration(ctx)
+	if err != nil {
+		t.Fatalf("Calibration failed: %v", err)
+	}
+	if report.Markets != 1 || !report.GeneratedAt.Equal(now) {
+		t.Fatalf("Expected 1 market generated now, got %+v", report)
+	}
+
+	// Within the day the cached report is served
+	settle("Second?")
+	now = now.Add(23 * time.Hour)
+	if report, _ := s.Calibration(ctx); report.Markets != 1 {
+		t.Errorf("Expected cached report with 1 market, got %d", report.Markets)
+	}
+
+	now = now.Add(time.Hour)
+	if report, _ := s.Calibration(ctx); report.Markets != 2 {
+		t.Errorf("Expected refreshed report with 2 markets, got %d", report.Markets)
+	}
+}
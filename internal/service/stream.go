@@ -0,0 +1,154 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// Live update types pushed to /api/stream clients
+const (
+	StreamBetPlaced       = "bet_placed"
+	StreamBetCancelled    = "bet_cancelled"
+	StreamMarketLocked    = "market_locked"
+	StreamMarketResolved  = "market_resolved"
+	StreamMarketFinalized = "market_finalized"
+)
+
+// StreamEvent is a live market update. It carries only what the market list shows anyway: the
+// public pools (see storage.GetPublicPools), the status and the outcome, never who bet.
+type StreamEvent struct {
+	Type     string `json:"type"`
+	MarketID int64  `json:"market_id"`
+	Status   string `json:"status,omitempty"`
+	Outcome  string `json:"outcome,omitempty"`
+	// Pools are set on bet_placed, bet_cancelled and market_locked, where they change
+	PoolYes     int64     `json:"pool_yes"`
+	PoolNo      int64     `json:"pool_no"`
+	PoolTotal   int64     `json:"pool_total,omitempty"`
+	PoolsHidden bool      `json:"pools_hidden,omitempty"`
+	SidesHidden bool      `json:"sides_hidden,omitempty"`
+	At          time.Time `json:"at"`
+}
+
+// streamBuffer is how many events a slow subscriber may fall behind before it misses some
+const streamBuffer = 64
+
+// EventBus fans live market updates out to subscribers. It is a Notifier, so the services'
+// existing lock, resolution and finalization events reach it through WithIntegrations.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan StreamEvent]struct{}
+}
+
+// NewEventBus creates an event bus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[chan StreamEvent]struct{})}
+}
+
+var eventBus = NewEventBus()
+
+// GetEventBus returns the process-wide event bus behind /api/stream
+func GetEventBus() *EventBus {
+	return eventBus
+}
+
+// Subscribe returns a channel receiving every event published from now on and a function that
+// unsubscribes and closes it
+func (b *EventBus) Subscribe() (<-chan StreamEvent, func()) {
+	ch := make(chan StreamEvent, streamBuffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Subscribers returns how many clients are listening
+func (b *EventBus) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// Publish sends an event to every subscriber without blocking; subscribers whose buffer is full
+// miss it and catch up on their next poll
+func (b *EventBus) Publish(event StreamEvent) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			logger.Debug(0, "stream_event_dropped", fmt.Sprintf("type=%s market_id=%d", event.Type, event.MarketID))
+		}
+	}
+}
+
+// Emit turns the notification events that change what a market card shows into live updates
+func (b *EventBus) Emit(event NotificationEvent) {
+	switch e := event.(type) {
+	case MarketLocked:
+		if e.Market != nil {
+			b.publishPools(StreamMarketLocked, e.Market.ID, string(storage.MarketStatusLocked))
+		}
+	case ResolutionPublished:
+		b.Publish(StreamEvent{Type: StreamMarketResolved, MarketID: e.MarketID, Status: string(storage.MarketStatusResolved), Outcome: e.Outcome})
+	case FinalizationPublished:
+		b.Publish(StreamEvent{Type: StreamMarketFinalized, MarketID: e.MarketID, Status: string(storage.MarketStatusFinalized), Outcome: e.Outcome})
+	}
+}
+
+// PublishBetPlaced pushes a market's public pools after a bet
+func (b *EventBus) PublishBetPlaced(marketID int64) {
+	b.queuePools(StreamBetPlaced, marketID)
+}
+
+// PublishBetCancelled pushes a market's public pools after a bet was cancelled
+func (b *EventBus) PublishBetCancelled(marketID int64) {
+	b.queuePools(StreamBetCancelled, marketID)
+}
+
+// queuePools publishes the market's pools in the background, and only when someone is listening
+func (b *EventBus) queuePools(eventType string, marketID int64) {
+	if b.Subscribers() == 0 {
+		return
+	}
+	go b.publishPools(eventType, marketID, "")
+}
+
+// publishPools publishes an event carrying the market's public pools
+func (b *EventBus) publishPools(eventType string, marketID int64, status string) {
+	if b.Subscribers() == 0 {
+		return
+	}
+	pools, err := storage.GetPublicPools(marketID)
+	if err != nil {
+		logger.Debug(0, "stream_pools_error", fmt.Sprintf("market_id=%d error=%v", marketID, err))
+		return
+	}
+	b.Publish(StreamEvent{
+		Type:        eventType,
+		MarketID:    marketID,
+		Status:      status,
+		PoolYes:     pools.Yes,
+		PoolNo:      pools.No,
+		PoolTotal:   pools.Total,
+		PoolsHidden: pools.PoolsHidden,
+		SidesHidden: pools.SidesHidden,
+	})
+}
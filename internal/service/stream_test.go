@@ -0,0 +1,63 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestEventBus(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := storage.CreateUser(4401, "streamer", "Streamer")
+	market, _ := storage.CreateMarket(user.ID, "Will the stream stay up?", time.Now().Add(time.Hour))
+
+	bus := NewEventBus()
+	// Nobody listens: nothing is looked up or sent
+	bus.PublishBetPlaced(market.ID)
+
+	events, unsubscribe := bus.Subscribe()
+	if bus.Subscribers() != 1 {
+		t.Fatalf("Expected 1 subscriber, got %d", bus.Subscribers())
+	}
+
+	next := func() StreamEvent {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for an event")
+			return StreamEvent{}
+		}
+	}
+
+	storage.PlaceBet(t.Context(), user.ID, market.ID, "YES", 30)
+	bus.PublishBetPlaced(market.ID)
+	if e := next(); e.Type != StreamBetPlaced || e.MarketID != market.ID || e.PoolYes != 30 || e.At.IsZero() {
+		t.Errorf("Expected the new pools, got %+v", e)
+	}
+
+	// Notification events of the services become live updates; others are ignored
+	bus.Emit(WhaleAlert{MarketID: market.ID})
+	bus.Emit(MarketLocked{Market: market})
+	if e := next(); e.Type != StreamMarketLocked || e.Status != "LOCKED" || e.PoolYes != 30 {
+		t.Errorf("Expected market_locked with the pools, got %+v", e)
+	}
+	bus.Emit(ResolutionPublished{MarketID: market.ID, Outcome: "YES"})
+	if e := next(); e.Type != StreamMarketResolved || e.Outcome != "YES" {
+		t.Errorf("Expected market_resolved, got %+v", e)
+	}
+	bus.Emit(FinalizationPublished{MarketID: market.ID, Outcome: "YES"})
+	if e := next(); e.Type != StreamMarketFinalized || e.Status != "FINALIZED" {
+		t.Errorf("Expected market_finalized, got %+v", e)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if _, open := <-events; open || bus.Subscribers() != 0 {
+		t.Error("Expected unsubscribing to close the channel")
+	}
+	bus.Publish(StreamEvent{Type: StreamBetPlaced})
+}
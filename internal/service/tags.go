@@ -0,0 +1,55 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxMarketTags caps how many hashtags are taken from a single question
+	MaxMarketTags = 5
+	// minTagLength and maxTagLength bound a tag's length in runes, without the #
+	minTagLength = 2
+	maxTagLength = 32
+)
+
+// hashtagPattern matches #word where the # starts the text or follows a non-word character
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_])#([\p{L}\p{N}_]+)`)
+
+// ParseHashtags extracts the hashtags of a market question as lowercase tags, in order of
+// appearance and without duplicates. Fragments inside links (e.g. #section anchors) are ignored,
+// as are purely numeric tags, which are usually market references like #12.
+func ParseHashtags(text string) []string {
+	text = urlPattern.ReplaceAllString(text, " ")
+
+	var tags []string
+	seen := make(map[string]bool)
+	for _, m := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+		tag := strings.ToLower(m[1])
+		length := utf8.RuneCountInString(tag)
+		if length < minTagLength || length > maxTagLength || !strings.ContainsFunc(tag, unicode.IsLetter) || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+		if len(tags) == MaxMarketTags {
+			break
+		}
+	}
+	return tags
+}
+
+// hashtagLine formats the tags of a question for a channel post, or returns "" when there are none.
+// Telegram turns #tag into a search link, which groups related markets in the channel.
+func hashtagLine(question string) string {
+	tags := ParseHashtags(question)
+	if len(tags) == 0 {
+		return ""
+	}
+	for i, tag := range tags {
+		tags[i] = "#" + strings.ReplaceAll(tag, "_", `\_`)
+	}
+	return "\n\n" + strings.Join(tags, " ")
+}
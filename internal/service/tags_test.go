@@ -0,0 +1,41 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHashtags(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"none", "Will it rain tomorrow?", nil},
+		{"lowercased and deduplicated", "Will #Football fans watch #football on #TV?", []string{"football", "tv"}},
+		{"underscores and unicode", "Will #world_cup be held in #Москва?", []string{"world_cup", "москва"}},
+		{"punctuation before tag", "Elections (#politics) this year?", []string{"politics"}},
+		{"market references are not tags", "Is #12 a duplicate of #13?", nil},
+		{"too short", "Will #a happen?", nil},
+		{"inside a word", "Is C#sharp a tag?", nil},
+		{"link fragments ignored", "Read https://example.com/page#section first #news", []string{"news"}},
+		{"capped", "#aa #bb #cc #dd #ee #ff", []string{"aa", "bb", "cc", "dd", "ee"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseHashtags(tt.input); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseHashtags(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHashtagLine(t *testing.T) {
+	if line := hashtagLine("Will #world_cup end in a #draw?"); line != "\n\n#world\\_cup #draw" {
+		t.Errorf("Unexpected hashtag line %q", line)
+	}
+	if line := hashtagLine("No tags here"); line != "" {
+		t.Errorf("Expected empty line, got %q", line)
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+
+	"gopkg.in/telebot.v3"
+)
+
+// SendErrorKind is the category of a failed Telegram send
+type SendErrorKind string
+
+const (
+	// SendBlocked means the user blocked the bot, deleted their account or never started it
+	SendBlocked SendErrorKind = "blocked"
+	// SendChatNotFound means the chat does not exist or the bot cannot see it
+	SendChatNotFound SendErrorKind = "chat_not_found"
+	// SendRateLimited means Telegram asked the bot to slow down
+	SendRateLimited SendErrorKind = "rate_limited"
+	// SendNetwork means Telegram could not be reached
+	SendNetwork SendErrorKind = "network"
+	// SendOther is every other failure
+	SendOther SendErrorKind = "other"
+)
+
+// DefaultDMBlockedLimit is how many DMs in a row a user may refuse by blocking the bot before
+// their DMs are turned off
+const DefaultDMBlockedLimit = 3
+
+// ErrDMsDisabled is returned for DMs to users whose DMs were turned off
+var ErrDMsDisabled = errors.New("DMs are turned off: the user blocked the bot")
+
+// LoadDMBlockedLimit reads DM_BLOCKED_LIMIT, falling back to the default for missing or invalid
+// values. DM_BLOCKED_LIMIT=0 never turns DMs off.
+func LoadDMBlockedLimit() int {
+	if v, err := strconv.Atoi(os.Getenv("DM_BLOCKED_LIMIT")); err == nil && v >= 0 {
+		return v
+	}
+	return DefaultDMBlockedLimit
+}
+
+// ClassifySendError returns the category of an error from the Telegram API
+func ClassifySendError(err error) SendErrorKind {
+	switch {
+	case errors.Is(err, telebot.ErrBlockedByUser), errors.Is(err, telebot.ErrUserIsDeactivated), errors.Is(err, telebot.ErrNotStartedByUser):
+		return SendBlocked
+	case errors.Is(err, telebot.ErrChatNotFound):
+		return SendChatNotFound
+	}
+
+	var flood telebot.FloodError
+	if errors.As(err, &flood) {
+		return SendRateLimited
+	}
+	var apiErr *telebot.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusTooManyRequests {
+		return SendRateLimited
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return SendNetwork
+	}
+	return SendOther
+}
+
+// TelegramSendMetrics are the Telegram send counters since the service started
+type TelegramSendMetrics struct {
+	Sent   int64 `json:"sent"`
+	Failed int64 `json:"failed"`
+	// Failures counts the failed sends by SendErrorKind
+	Failures map[SendErrorKind]int64 `json:"failures"`
+	// Skipped counts the DMs not sent because the user's DMs were turned off
+	Skipped int64 `json:"skipped"`
+	// Disabled counts the users whose DMs were turned off
+	Disabled int64 `json:"disabled"`
+}
+
+var (
+	sendMetricsMu sync.Mutex
+	sendMetrics   = TelegramSendMetrics{Failures: make(map[SendErrorKind]int64)}
+)
+
+// GetTelegramSendMetrics returns a copy of the Telegram send counters
+func GetTelegramSendMetrics() TelegramSendMetrics {
+	sendMetricsMu.Lock()
+	defer sendMetricsMu.Unlock()
+
+	metrics := sendMetrics
+	metrics.Failures = make(map[SendErrorKind]int64, len(sendMetrics.Failures))
+	for kind, n := range sendMetrics.Failures {
+		metrics.Failures[kind] = n
+	}
+	return metrics
+}
+
+// countSend adds a send to the metrics: its failure kind, or "" when it went through
+func countSend(kind SendErrorKind) {
+	sendMetricsMu.Lock()
+	defer sendMetricsMu.Unlock()
+	if kind == "" {
+		sendMetrics.Sent++
+		return
+	}
+	sendMetrics.Failed++
+	sendMetrics.Failures[kind]++
+}
+
+// countSkipped adds a DM that was not sent because the user's DMs are off to the metrics
+func countSkipped() {
+	sendMetricsMu.Lock()
+	defer sendMetricsMu.Unlock()
+	sendMetrics.Skipped++
+}
+
+// countDisabled adds a user whose DMs were just turned off to the metrics
+func countDisabled() {
+	sendMetricsMu.Lock()
+	defer sendMetricsMu.Unlock()
+	sendMetrics.Disabled++
+}
+
+// send sends to a channel or group and counts the result
+func (s *NotificationService) send(to telebot.Recipient, what interface{}, opts ...interface{}) (*telebot.Message, error) {
+	msg, err := s.bot.Send(to, what, opts...)
+	if err != nil {
+		kind := ClassifySendError(err)
+		countSend(kind)
+		logger.Debug(0, "telegram_send_failed", fmt.Sprintf("chat=%s kind=%s", to.Recipient(), kind))
+		return msg, err
+	}
+	countSend("")
+	return msg, nil
+}
+
+// sendDM sends a DM to a user (Telegram ID) unless their DMs were turned off, and records the
+// result against them
+func (s *NotificationService) sendDM(telegramID int64, what interface{}, opts ...interface{}) (*telebot.Message, error) {
+	if disabled, err := storage.IsDMDisabled(telegramID); err != nil {
+		logger.Debug(telegramID, "notification_error", "failed to read DM status: "+err.Error())
+	} else if disabled {
+		countSkipped()
+		return nil, ErrDMsDisabled
+	}
+
+	msg, err := s.bot.Send(&telebot.User{ID: telegramID}, what, opts...)
+	recordDMResult(telegramID, err)
+	return msg, err
+}
+
+// recordDMResult counts a DM to a user (Telegram ID) and keeps their failure counters, turning
+// their DMs off after LoadDMBlockedLimit() refusals in a row
+func recordDMResult(telegramID int64, err error) {
+	if err == nil {
+		countSend("")
+		if err := storage.RecordDMDelivered(telegramID); err != nil {
+			logger.Debug(telegramID, "notification_error", err.Error())
+		}
+		return
+	}
+
+	kind := ClassifySendError(err)
+	countSend(kind)
+	logger.Debug(telegramID, "telegram_send_failed", fmt.Sprintf("kind=%s", kind))
+
+	disabled, dbErr := storage.RecordDMFailure(telegramID, kind == SendBlocked, LoadDMBlockedLimit())
+	if dbErr != nil {
+		logger.Debug(telegramID, "notification_error", dbErr.Error())
+		return
+	}
+	if disabled {
+		countDisabled()
+		logger.Debug(telegramID, "dm_auto_disabled", fmt.Sprintf("blocked_limit=%d", LoadDMBlockedLimit()))
+	}
+}
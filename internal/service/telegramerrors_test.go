@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"predictionbot/internal/storage"
+
+	"gopkg.in/telebot.v3"
+)
+
+func TestClassifySendError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want SendErrorKind
+	}{
+		{telebot.ErrBlockedByUser, SendBlocked},
+		{telebot.ErrUserIsDeactivated, SendBlocked},
+		{telebot.ErrChatNotFound, SendChatNotFound},
+		{telebot.FloodError{RetryAfter: 5}, SendRateLimited},
+		{telebot.NewError(429, "Too Many Requests"), SendRateLimited},
+		{fmt.Errorf("telebot: %w", &url.Error{Op: "Post", URL: "https://api.telegram.org", Err: errors.New("connection reset")}), SendNetwork},
+		{telebot.ErrTooLongMessage, SendOther},
+	}
+	for _, c := range cases {
+		if got := ClassifySendError(c.err); got != c.want {
+			t.Errorf("ClassifySendError(%v) = %s, want %s", c.err, got, c.want)
+		}
+	}
+}
+
+func TestRecordDMResultDisablesBlockedUsers(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("DM_BLOCKED_LIMIT", "2")
+
+	user, _ := storage.CreateUser(45101, "blocker", "Blocker")
+	before := GetTelegramSendMetrics()
+
+	recordDMResult(user.TelegramID, telebot.ErrBlockedByUser)
+	recordDMResult(user.TelegramID, fmt.Errorf("telebot: %w", &url.Error{Op: "Post", Err: errors.New("timeout")}))
+	if off, _ := storage.IsDMDisabled(user.TelegramID); off {
+		t.Fatal("Expected a network error not to count towards the limit")
+	}
+	recordDMResult(user.TelegramID, telebot.ErrBlockedByUser)
+	if off, _ := storage.IsDMDisabled(user.TelegramID); !off {
+		t.Fatal("Expected DMs off after two refusals")
+	}
+
+	after := GetTelegramSendMetrics()
+	if after.Failures[SendBlocked]-before.Failures[SendBlocked] != 2 || after.Failures[SendNetwork]-before.Failures[SendNetwork] != 1 {
+		t.Errorf("Expected 2 blocked and 1 network failure counted, got %+v", after.Failures)
+	}
+	if after.Disabled-before.Disabled != 1 {
+		t.Errorf("Expected 1 user disabled, got %d", after.Disabled-before.Disabled)
+	}
+
+	// DMs to the user are no longer attempted
+	s := &NotificationService{}
+	if _, err := s.sendDM(user.TelegramID, "hello"); !errors.Is(err, ErrDMsDisabled) {
+		t.Errorf("Expected ErrDMsDisabled, got %v", err)
+	}
+}
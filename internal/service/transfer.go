@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// TransferService handles handing market ownership over to another user.
+// A transfer needs three steps: the creator requests it, the creator confirms
+// it from the bot, and the recipient accepts it from the bot.
+type TransferService struct{}
+
+// NewTransferService creates a new transfer service
+func NewTransferService() *TransferService {
+	return &TransferService{}
+}
+
+// RequestTransfer opens a transfer of a market to the user with the given username.
+// creatorID is the internal user ID of the current market creator.
+func (s *TransferService) RequestTransfer(ctx context.Context, marketID, creatorID int64, toUsername string) (*storage.MarketTransfer, error) {
+	toUsername = strings.TrimPrefix(strings.TrimSpace(toUsername), "@")
+	if toUsername == "" {
+		return nil, fmt.Errorf("invalid recipient: username is required")
+	}
+
+	recipient, err := storage.GetUserByUsername(toUsername)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recipient: %w", err)
+	}
+	if recipient == nil {
+		return nil, fmt.Errorf("recipient not found: @%s has not started the bot", toUsername)
+	}
+
+	transfer, err := storage.CreateMarketTransfer(ctx, marketID, creatorID, recipient.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug(creatorID, "market_transfer_requested", fmt.Sprintf("transfer_id=%d market_id=%d to_user_id=%d", transfer.ID, marketID, recipient.ID))
+
+	if notifService := GetNotificationService(); notifService != nil {
+		go func() {
+			market, err := storage.GetMarketByID(marketID)
+			if err == nil && market != nil {
+				notifService.SendTransferConfirmation(transfer, market, recipient)
+			}
+		}()
+	}
+
+	return transfer, nil
+}
+
+// ConfirmTransfer is the creator's confirmation step; it forwards the offer to the recipient
+func (s *TransferService) ConfirmTransfer(ctx context.Context, transferID, userID int64) (*storage.MarketTransfer, error) {
+	transfer, err := s.getTransfer(transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.FromUserID != userID {
+		return nil, fmt.Errorf("only the market creator can confirm this transfer")
+	}
+
+	if err := storage.UpdateMarketTransferStatus(ctx, transferID, userID, storage.TransferStatusPending, storage.TransferStatusConfirmed); err != nil {
+		return nil, err
+	}
+	transfer.Status = storage.TransferStatusConfirmed
+
+	logger.Debug(userID, "market_transfer_confirmed", fmt.Sprintf("transfer_id=%d market_id=%d", transferID, transfer.MarketID))
+
+	if notifService := GetNotificationService(); notifService != nil {
+		go func() {
+			market, err := storage.GetMarketByID(transfer.MarketID)
+			creator, _ := storage.GetUserByID(transfer.FromUserID)
+			if err == nil && market != nil {
+				notifService.SendTransferOffer(transfer, market, creator)
+			}
+		}()
+	}
+
+	return transfer, nil
+}
+
+// CancelTransfer withdraws a transfer before the recipient has answered (creator action)
+func (s *TransferService) CancelTransfer(ctx context.Context, transferID, userID int64) (*storage.MarketTransfer, error) {
+	transfer, err := s.getTransfer(transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.FromUserID != userID {
+		return nil, fmt.Errorf("only the market creator can cancel this transfer")
+	}
+
+	from := transfer.Status
+	if from != storage.TransferStatusPending && from != storage.TransferStatusConfirmed {
+		return nil, fmt.Errorf("transfer cannot be cancelled: status is %s", from)
+	}
+
+	if err := storage.UpdateMarketTransferStatus(ctx, transferID, userID, from, storage.TransferStatusCancelled); err != nil {
+		return nil, err
+	}
+	transfer.Status = storage.TransferStatusCancelled
+
+	logger.Debug(userID, "market_transfer_cancelled", fmt.Sprintf("transfer_id=%d market_id=%d", transferID, transfer.MarketID))
+	return transfer, nil
+}
+
+// AcceptTransfer makes the recipient the new market creator
+func (s *TransferService) AcceptTransfer(ctx context.Context, transferID, userID int64) (*storage.MarketTransfer, error) {
+	transfer, err := s.getTransfer(transferID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storage.CompleteMarketTransfer(ctx, transferID, userID); err != nil {
+		return nil, err
+	}
+	transfer.Status = storage.TransferStatusAccepted
+
+	logger.Debug(userID, "market_transfer_accepted", fmt.Sprintf("transfer_id=%d market_id=%d from_user_id=%d", transferID, transfer.MarketID, transfer.FromUserID))
+
+	s.notifyResult(transfer, true)
+	return transfer, nil
+}
+
+// DeclineTransfer rejects the offer; the market stays with its creator
+func (s *TransferService) DeclineTransfer(ctx context.Context, transferID, userID int64) (*storage.MarketTransfer, error) {
+	transfer, err := s.getTransfer(transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.ToUserID != userID {
+		return nil, fmt.Errorf("only the transfer recipient can decline this transfer")
+	}
+
+	if err := storage.UpdateMarketTransferStatus(ctx, transferID, userID, storage.TransferStatusConfirmed, storage.TransferStatusDeclined); err != nil {
+		return nil, err
+	}
+	transfer.Status = storage.TransferStatusDeclined
+
+	logger.Debug(userID, "market_transfer_declined", fmt.Sprintf("transfer_id=%d market_id=%d", transferID, transfer.MarketID))
+
+	s.notifyResult(transfer, false)
+	return transfer, nil
+}
+
+// getTransfer loads a transfer and converts a missing row into an error
+func (s *TransferService) getTransfer(transferID int64) (*storage.MarketTransfer, error) {
+	transfer, err := storage.GetMarketTransfer(transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer == nil {
+		return nil, fmt.Errorf("transfer not found")
+	}
+	return transfer, nil
+}
+
+// notifyResult tells the previous creator how the recipient answered
+func (s *TransferService) notifyResult(transfer *storage.MarketTransfer, accepted bool) {
+	notifService := GetNotificationService()
+	if notifService == nil {
+		return
+	}
+	go func() {
+		market, err := storage.GetMarketByID(transfer.MarketID)
+		if err == nil && market != nil {
+			notifService.SendTransferResult(transfer, market, accepted)
+		}
+	}()
+}
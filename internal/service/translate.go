@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+const (
+	// DefaultMarketLanguage is the language questions are assumed to be written in
+	DefaultMarketLanguage = "en"
+	// DefaultTranslationModel is used when TRANSLATION_MODEL is not set
+	DefaultTranslationModel = "gpt-4o-mini"
+	// MaxTranslationLength caps translated questions; translations often run longer than the original
+	MaxTranslationLength = 2 * MaxQuestionLength
+)
+
+// translatePrompt asks for the bare translated question
+const translatePrompt = `You translate prediction market questions. Translate the user's question into the language with ISO 639 code %q.
+Keep names, numbers, dates and hashtags unchanged. Reply with the translated question only.`
+
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// NormalizeLanguage reduces a language tag such as "de-AT" or "pt_BR" to its primary subtag.
+// Returns "" for anything that is not a language code.
+func NormalizeLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if !languagePattern.MatchString(tag) {
+		return ""
+	}
+	return tag
+}
+
+// LanguageFromAcceptHeader returns the first usable language of an Accept-Language header
+func LanguageFromAcceptHeader(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		if lang := NormalizeLanguage(tag); lang != "" {
+			return lang
+		}
+	}
+	return ""
+}
+
+// TranslationService serves market questions in other languages. Creator translations are
+// stored as given; without TRANSLATION_API_URL nothing is machine-translated.
+type TranslationService struct {
+	client         *http.Client
+	endpoint       string
+	apiKey         string
+	model          string
+	marketLanguage string
+
+	mu      sync.Mutex
+	pending map[string]bool
+}
+
+var (
+	globalTranslationService *TranslationService
+	translationServiceOnce   sync.Once
+)
+
+// GetTranslationService returns the shared translation service configured from
+// TRANSLATION_API_URL, TRANSLATION_API_KEY, TRANSLATION_MODEL and MARKET_LANGUAGE
+func GetTranslationService() *TranslationService {
+	translationServiceOnce.Do(func() {
+		globalTranslationService = NewTranslationService(
+			strings.TrimSpace(os.Getenv("TRANSLATION_API_URL")),
+			strings.TrimSpace(os.Getenv("TRANSLATION_API_KEY")),
+			strings.TrimSpace(os.Getenv("TRANSLATION_MODEL")),
+			os.Getenv("MARKET_LANGUAGE"),
+		)
+	})
+	return globalTranslationService
+}
+
+// NewTranslationService creates a translation service. An empty endpoint disables machine
+// translation; an invalid market language falls back to DefaultMarketLanguage.
+func NewTranslationService(endpoint, apiKey, model, marketLanguage string) *TranslationService {
+	if model == "" {
+		model = DefaultTranslationModel
+	}
+	lang := NormalizeLanguage(marketLanguage)
+	if lang == "" {
+		lang = DefaultMarketLanguage
+	}
+	return &TranslationService{
+		client:         &http.Client{Timeout: llmTimeout},
+		endpoint:       endpoint,
+		apiKey:         apiKey,
+		model:          model,
+		marketLanguage: lang,
+		pending:        make(map[string]bool),
+	}
+}
+
+// MarketLanguage is the language questions are written in
+func (s *TranslationService) MarketLanguage() string {
+	return s.marketLanguage
+}
+
+// needsTranslation reports whether lang differs from the language questions are written in
+func (s *TranslationService) needsTranslation(lang string) bool {
+	return s != nil && lang != "" && lang != s.marketLanguage
+}
+
+// Question returns the market question in lang: a stored translation if there is one,
+// otherwise a machine translation (stored for next time), otherwise the original.
+func (s *TranslationService) Question(ctx context.Context, marketID int64, question, lang string) string {
+	if !s.needsTranslation(lang) {
+		return question
+	}
+	if translated, err := storage.GetMarketTranslation(marketID, lang); err == nil && translated != "" {
+		return translated
+	}
+	if translated := s.machineTranslate(ctx, marketID, question, lang); translated != "" {
+		return translated
+	}
+	return question
+}
+
+// Questions localizes a market list in place from stored translations only, so listing stays fast.
+// Missing translations are machine-translated in the background for the next request.
+func (s *TranslationService) Questions(markets []storage.MarketWithCreator, lang string) {
+	if !s.needsTranslation(lang) || len(markets) == 0 {
+		return
+	}
+
+	ids := make([]int64, len(markets))
+	for i, m := range markets {
+		ids[i] = m.ID
+	}
+	translated, err := storage.GetTranslationsForMarkets(ids, lang)
+	if err != nil {
+		logger.Debug(0, "translation_error", fmt.Sprintf("lang=%s error=%v", lang, err))
+		return
+	}
+
+	for i := range markets {
+		if question, ok := translated[markets[i].ID]; ok {
+			markets[i].Question = question
+			continue
+		}
+		if s.endpoint != "" {
+			go s.machineTranslate(context.Background(), markets[i].ID, markets[i].Question, lang)
+		}
+	}
+}
+
+// machineTranslate translates and stores a question, returning "" when disabled, already
+// in progress or failed. Concurrent requests for the same market and language are collapsed.
+func (s *TranslationService) machineTranslate(ctx context.Context, marketID int64, question, lang string) string {
+	if s.endpoint == "" {
+		return ""
+	}
+
+	key := fmt.Sprintf("%d:%s", marketID, lang)
+	s.mu.Lock()
+	if s.pending[key] {
+		s.mu.Unlock()
+		return ""
+	}
+	s.pending[key] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, key)
+		s.mu.Unlock()
+	}()
+
+	reply, err := chatCompletion(ctx, s.client, s.endpoint, s.apiKey, s.model, fmt.Sprintf(translatePrompt, lang), question, false)
+	if err != nil {
+		logger.Debug(0, "translation_error", fmt.Sprintf("market_id=%d lang=%s error=%v", marketID, lang, err))
+		return ""
+	}
+	translated := SanitizeText(reply)
+	if translated == "" || utf8.RuneCountInString(translated) > MaxTranslationLength {
+		logger.Debug(0, "translation_error", fmt.Sprintf("market_id=%d lang=%s error=unusable reply", marketID, lang))
+		return ""
+	}
+
+	if err := storage.SetMarketTranslation(marketID, lang, translated, storage.TranslationMachine); err != nil {
+		logger.Debug(0, "translation_error", fmt.Sprintf("market_id=%d lang=%s error=%v", marketID, lang, err))
+	}
+	return translated
+}
+
+// SanitizeTranslation validates a creator-provided translation with the question rules
+// and normalizes its language code
+func SanitizeTranslation(lang, question string) (string, string, error) {
+	normalized := NormalizeLanguage(lang)
+	if normalized == "" {
+		return "", "", fmt.Errorf("invalid language: %q is not a language code", lang)
+	}
+	question, err := SanitizeQuestion(question)
+	if err != nil {
+		return "", "", err
+	}
+	return normalized, question, nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"de", "de"},
+		{" DE-at ", "de"},
+		{"pt_BR", "pt"},
+		{"fil", "fil"},
+		{"", ""},
+		{"*", ""},
+		{"english", ""},
+		{"d3", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeLanguage(tt.input); got != tt.expected {
+			t.Errorf("NormalizeLanguage(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+
+	if got := LanguageFromAcceptHeader("*, fr-CH;q=0.9, en;q=0.8"); got != "fr" {
+		t.Errorf("Expected fr from Accept-Language, got %q", got)
+	}
+	if got := LanguageFromAcceptHeader(""); got != "" {
+		t.Errorf("Expected no language from an empty header, got %q", got)
+	}
+}
+
+func TestTranslateQuestion(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	server := completionServer(t, "Wird es morgen regnen?")
+	defer server.Close()
+
+	creator, _ := storage.CreateUser(5001, "creator", "Creator")
+	market, _ := storage.CreateMarket(creator.ID, "Will it rain tomorrow?", time.Now().Add(24*time.Hour))
+	ctx := context.Background()
+
+	s := NewTranslationService(server.URL, "test-key", "", "")
+	if got := s.Question(ctx, market.ID, market.Question, "en"); got != market.Question {
+		t.Errorf("Expected the original in the market language, got %q", got)
+	}
+	if got := s.Question(ctx, market.ID, market.Question, "de"); got != "Wird es morgen regnen?" {
+		t.Errorf("Expected machine translation, got %q", got)
+	}
+	if stored, _ := storage.GetMarketTranslation(market.ID, "de"); stored != "Wird es morgen regnen?" {
+		t.Errorf("Expected the translation to be cached, got %q", stored)
+	}
+
+	// Without an API only stored translations are served
+	disabled := NewTranslationService("", "", "", "")
+	if got := disabled.Question(ctx, market.ID, market.Question, "de"); got != "Wird es morgen regnen?" {
+		t.Errorf("Expected stored translation, got %q", got)
+	}
+	if got := disabled.Question(ctx, market.ID, market.Question, "fr"); got != market.Question {
+		t.Errorf("Expected the original without a translation, got %q", got)
+	}
+
+	markets := []storage.MarketWithCreator{{ID: market.ID, Question: market.Question}}
+	disabled.Questions(markets, "de")
+	if markets[0].Question != "Wird es morgen regnen?" {
+		t.Errorf("Expected translated list entry, got %q", markets[0].Question)
+	}
+}
+
+func TestSanitizeTranslation(t *testing.T) {
+	lang, question, err := SanitizeTranslation("de-DE", "  Wird es   morgen regnen? ")
+	if err != nil || lang != "de" || question != "Wird es morgen regnen?" {
+		t.Errorf("Unexpected result %q %q (err=%v)", lang, question, err)
+	}
+	if _, _, err := SanitizeTranslation("german", "Wird es regnen?"); err == nil {
+		t.Error("Expected error for an invalid language")
+	}
+	if _, _, err := SanitizeTranslation("de", ""); err == nil {
+		t.Error("Expected error for an empty question")
+	}
+}
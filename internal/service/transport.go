@@ -0,0 +1,267 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// transportTimeout bounds a single delivery through a transport
+const transportTimeout = 10 * time.Second
+
+// UserMessage is a DM rendered as plain text for a transport other than Telegram
+type UserMessage struct {
+	UserID int64  `json:"user_id"`
+	Kind   string `json:"kind"`
+	Text   string `json:"text"`
+}
+
+// Transport delivers users' DMs somewhere other than Telegram. Target is the per-user
+// address stored in the notification settings, e.g. a Matrix room ID or a webhook URL.
+type Transport interface {
+	// ValidateTarget rejects a target before it is saved
+	ValidateTarget(target string) error
+	Send(ctx context.Context, target string, message UserMessage) error
+}
+
+var (
+	transportsMu sync.RWMutex
+	transports   = make(map[string]Transport)
+)
+
+// RegisterTransport makes a transport selectable in the notification settings under name.
+// Registering a name again replaces the transport.
+func RegisterTransport(name string, transport Transport) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	transports[name] = transport
+}
+
+// LookupTransport returns the transport registered under name
+func LookupTransport(name string) (Transport, bool) {
+	transportsMu.RLock()
+	defer transportsMu.RUnlock()
+	transport, ok := transports[name]
+	return transport, ok
+}
+
+// TransportNames lists the registered transports, sorted
+func TransportNames() []string {
+	transportsMu.RLock()
+	defer transportsMu.RUnlock()
+	names := make([]string, 0, len(transports))
+	for name := range transports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterConfiguredTransports registers the built-in transports the environment enables:
+// "matrix" with MATRIX_HOMESERVER_URL and MATRIX_ACCESS_TOKEN, "webhook" with USER_WEBHOOKS=true
+func RegisterConfiguredTransports() {
+	homeserver := strings.TrimSpace(os.Getenv("MATRIX_HOMESERVER_URL"))
+	token := strings.TrimSpace(os.Getenv("MATRIX_ACCESS_TOKEN"))
+	if homeserver != "" && token != "" {
+		RegisterTransport("matrix", NewMatrixTransport(homeserver, token))
+	}
+	if enabled, _ := strconv.ParseBool(os.Getenv("USER_WEBHOOKS")); enabled {
+		RegisterTransport("webhook", NewWebhookTransport())
+	}
+}
+
+// WithUserTransports sends the DMs of users who picked another transport through it instead
+// of the notifier. Events for Telegram users, and DMs the transport failed to deliver, go on
+// to the notifier.
+func WithUserTransports(notifier Notifier) Notifier {
+	return transportRouter{next: notifier}
+}
+
+// transportRouter diverts user DMs to the transport in each user's notification settings
+type transportRouter struct {
+	next Notifier
+}
+
+// Emit delivers user DMs through the user's transport, and everything else through next.
+// DMs the user muted are dropped instead of reaching their transport.
+func (r transportRouter) Emit(event NotificationEvent) {
+	userID, ok := eventRecipient(event)
+	if !ok || len(TransportNames()) == 0 {
+		r.next.Emit(event)
+		return
+	}
+
+	name, target, err := storage.GetNotificationTransport(userID)
+	transport, registered := LookupTransport(name)
+	if err != nil || name == "" || !registered {
+		r.next.Emit(event)
+		return
+	}
+	if isMutedDM(event) {
+		return
+	}
+
+	go func() {
+		message := UserMessage{UserID: userID, Kind: event.Kind(), Text: userMessageText(event)}
+		ctx, cancel := context.WithTimeout(context.Background(), transportTimeout)
+		defer cancel()
+		if err := transport.Send(ctx, target, message); err != nil {
+			logger.Debug(userID, "transport_failed", fmt.Sprintf("transport=%s kind=%s error=%v", name, message.Kind, err))
+			r.next.Emit(event)
+		}
+	}()
+}
+
+// eventRecipient returns the user (internal ID) a DM event is for; other events report false
+func eventRecipient(event NotificationEvent) (int64, bool) {
+	switch e := event.(type) {
+	case WinNotice:
+		return e.UserID, true
+	case RefundNotice:
+		return e.UserID, true
+	case CreatorRewardNotice:
+		return e.UserID, true
+	case LossNotice:
+		return e.UserID, true
+	case StreakNotice:
+		return e.UserID, true
+	case BetReceipt:
+		return e.UserID, true
+	case OddsSwing:
+		return e.UserID, true
+	default:
+		return 0, false
+	}
+}
+
+// userMessageText renders a DM event as plain text, in the recipient's language
+func userMessageText(event NotificationEvent) string {
+	switch e := event.(type) {
+	case WinNotice:
+		return fmt.Sprintf("🏆 You won %s on market #%d: %s (your bet: %s on %s, new balance %s)",
+			formatBalance(e.Payout-e.BetAmount), e.MarketID, userQuestion(e.UserID, e.MarketID, e.Question), formatBalance(e.BetAmount), e.Outcome, formatBalance(e.NewBalance))
+	case RefundNotice:
+		return fmt.Sprintf("↩️ Your %s on market #%d was refunded: %s (new balance %s)",
+			formatBalance(e.Amount), e.MarketID, userQuestion(e.UserID, e.MarketID, e.Question), formatBalance(e.NewBalance))
+	case CreatorRewardNotice:
+		return fmt.Sprintf("🎨 You earned %s for creating market #%d: %s (new balance %s)",
+			formatBalance(e.Amount), e.MarketID, userQuestion(e.UserID, e.MarketID, e.Question), formatBalance(e.NewBalance))
+	case LossNotice:
+		text := fmt.Sprintf("📉 Your %s bet on market #%d did not win: %s",
+			formatBalance(e.Amount), e.MarketID, userQuestion(e.UserID, e.MarketID, e.Question))
+		if e.Refunded > 0 {
+			text += fmt.Sprintf(" (your voucher refunded %s)", formatBalance(e.Refunded))
+		}
+		return text
+	case StreakNotice:
+		if e.Ended > 0 {
+			return fmt.Sprintf("Your %d-market win streak ended on market #%d: %s", e.Ended, e.MarketID, userQuestion(e.UserID, e.MarketID, e.Question))
+		}
+		return fmt.Sprintf("🔥 %d wins in a row after market #%d: %s", e.Streak, e.MarketID, userQuestion(e.UserID, e.MarketID, e.Question))
+	case BetReceipt:
+		return fmt.Sprintf("🧾 Bet placed on market #%d: %s on %s (%s, new balance %s)",
+			e.MarketID, formatBalance(e.Amount), e.Outcome, userQuestion(e.UserID, e.MarketID, e.Question), formatBalance(e.NewBalance))
+	case OddsSwing:
+		return fmt.Sprintf("📊 The YES chance on market #%d moved from %.0f%% to %.0f%% since your bet: %s",
+			e.MarketID, e.YesBefore, e.YesAfter, userQuestion(e.UserID, e.MarketID, e.Question))
+	default:
+		return ""
+	}
+}
+
+// MatrixTransport posts DMs as messages to a Matrix room, using one bot account
+type MatrixTransport struct {
+	client     *http.Client
+	homeserver string
+	token      string
+}
+
+// NewMatrixTransport creates a Matrix transport for the bot account behind token
+func NewMatrixTransport(homeserver, token string) *MatrixTransport {
+	return &MatrixTransport{
+		client:     &http.Client{Timeout: transportTimeout},
+		homeserver: strings.TrimRight(homeserver, "/"),
+		token:      token,
+	}
+}
+
+// ValidateTarget accepts a room ID such as !abc123:matrix.org; the bot account must be in the room
+func (m *MatrixTransport) ValidateTarget(target string) error {
+	if !strings.HasPrefix(target, "!") || !strings.Contains(target, ":") {
+		return fmt.Errorf("invalid Matrix room: use a room ID such as !abc123:matrix.org")
+	}
+	return nil
+}
+
+// Send posts the message text to the room
+func (m *MatrixTransport) Send(ctx context.Context, target string, message UserMessage) error {
+	payload, err := json.Marshal(map[string]string{"msgtype": "m.text", "body": message.Text})
+	if err != nil {
+		return err
+	}
+	txnID := strconv.FormatInt(time.Now().UnixNano(), 10)
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s", m.homeserver, url.PathEscape(target), txnID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.token)
+	return doTransportRequest(m.client, req)
+}
+
+// WebhookTransport POSTs each DM as a JSON UserMessage to a URL of the user's choosing
+type WebhookTransport struct {
+	client *http.Client
+}
+
+// NewWebhookTransport creates a generic webhook transport
+func NewWebhookTransport() *WebhookTransport {
+	return &WebhookTransport{client: newPublicHTTPClient(transportTimeout, "webhook URL")}
+}
+
+// ValidateTarget accepts https URLs on public hosts only, like market webhooks, so DMs are never
+// sent in the clear or into the bot's own network
+func (t *WebhookTransport) ValidateTarget(target string) error {
+	_, err := validatePublicURL(target, "webhook URL")
+	return err
+}
+
+// Send posts the message as JSON
+func (t *WebhookTransport) Send(ctx context.Context, target string, message UserMessage) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doTransportRequest(t.client, req)
+}
+
+// doTransportRequest sends a delivery and treats any non-2xx answer as a failure
+func doTransportRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+// fakeTransport hands every message to a channel, or fails when fail is set
+type fakeTransport struct {
+	sent chan UserMessage
+	fail bool
+}
+
+func (f *fakeTransport) ValidateTarget(target string) error { return nil }
+
+func (f *fakeTransport) Send(ctx context.Context, target string, message UserMessage) error {
+	if f.fail {
+		return fmt.Errorf("unreachable")
+	}
+	f.sent <- message
+	return nil
+}
+
+func TestUserTransportsRouteDMs(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	telegramUser, _ := storage.CreateUser(13001, "tg", "Telegram")
+	fakeUser, _ := storage.CreateUser(13002, "fake", "Fake")
+	brokenUser, _ := storage.CreateUser(13003, "broken", "Broken")
+
+	fake := &fakeTransport{sent: make(chan UserMessage, 1)}
+	RegisterTransport("fake", fake)
+	RegisterTransport("broken", &fakeTransport{fail: true})
+	storage.SetNotificationTransport(fakeUser.ID, "fake", "somewhere")
+	storage.SetNotificationTransport(brokenUser.ID, "broken", "nowhere")
+
+	recorder := NewRecordingNotifier()
+	notifier := WithUserTransports(recorder)
+	notifier.Emit(WinNotice{UserID: telegramUser.ID, MarketID: 1, Question: "Will it rain?", BetAmount: 10, Outcome: "YES", Payout: 25})
+	notifier.Emit(DisputeAlert{MarketID: 1, Question: "Will it rain?"})
+	notifier.Emit(WinNotice{UserID: fakeUser.ID, MarketID: 1, Question: "Will it rain?", BetAmount: 10, Outcome: "YES", Payout: 25})
+	notifier.Emit(RefundNotice{UserID: brokenUser.ID, MarketID: 1, Question: "Will it rain?", Amount: 10})
+
+	select {
+	case message := <-fake.sent:
+		if message.UserID != fakeUser.ID || message.Kind != "win_notice" || !strings.Contains(message.Text, "Will it rain?") {
+			t.Errorf("Unexpected message through the transport: %+v", message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the win to go through the user's transport")
+	}
+
+	// The Telegram user's DM, the admin alert and the failed delivery reach the notifier
+	events := recorder.WaitFor(3, time.Second)
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events for the notifier, got %d: %+v", len(events), events)
+	}
+	if refund, ok := events[2].(RefundNotice); !ok || refund.UserID != brokenUser.ID {
+		t.Errorf("Expected the failed refund DM to fall back to the notifier, got %+v", events[2])
+	}
+}
+
+func TestMutedDMs(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := storage.CreateUser(13011, "quiet", "Quiet")
+	fake := &fakeTransport{sent: make(chan UserMessage, 2)}
+	RegisterTransport("fake", fake)
+	storage.SetNotificationTransport(user.ID, "fake", "somewhere")
+	if err := storage.SetNotificationsMuted(user.ID, true); err != nil {
+		t.Fatalf("SetNotificationsMuted failed: %v", err)
+	}
+
+	if !isMutedDM(LossNotice{UserID: user.ID}) || !isMutedDM(DailyDigest{UserID: user.ID}) {
+		t.Error("Expected losses and digests to be muted")
+	}
+	if isMutedDM(WinNotice{UserID: user.ID}) || isMutedDM(RefundNotice{UserID: user.ID}) {
+		t.Error("Expected payouts and refunds to go out while muted")
+	}
+
+	notifier := WithUserTransports(NewRecordingNotifier())
+	notifier.Emit(BetReceipt{UserID: user.ID, MarketID: 1, Question: "Will it rain?", Amount: 10, Outcome: "YES"})
+	notifier.Emit(WinNotice{UserID: user.ID, MarketID: 1, Question: "Will it rain?", BetAmount: 10, Outcome: "YES", Payout: 25})
+	select {
+	case message := <-fake.sent:
+		if message.Kind != "win_notice" {
+			t.Errorf("Expected only the win through the transport, got %+v", message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the win to go through the user's transport")
+	}
+
+	storage.SetNotificationsMuted(user.ID, false)
+	if dmMuted(user.ID) || isMutedDM(LossNotice{UserID: user.ID}) {
+		t.Error("Expected DMs again after unmuting")
+	}
+}
+
+func TestMatrixTransport(t *testing.T) {
+	var path, auth, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Body string `json:"body"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		path, auth, body = r.URL.EscapedPath(), r.Header.Get("Authorization"), payload.Body
+		w.Write([]byte(`{"event_id":"$1"}`))
+	}))
+	defer server.Close()
+
+	matrix := NewMatrixTransport(server.URL+"/", "secret-token")
+	if err := matrix.ValidateTarget("#general:matrix.org"); err == nil {
+		t.Error("Expected a room alias to be rejected")
+	}
+	if err := matrix.Send(context.Background(), "!room:matrix.org", UserMessage{Text: "You won"}); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	if !strings.HasPrefix(path, "/_matrix/client/v3/rooms/%21room:matrix.org/send/m.room.message/") {
+		t.Errorf("Unexpected path %q", path)
+	}
+	if auth != "Bearer secret-token" || body != "You won" {
+		t.Errorf("Unexpected request: auth=%q body=%q", auth, body)
+	}
+}
+
+func TestWebhookTransportPublicHostsOnly(t *testing.T) {
+	webhook := NewWebhookTransport()
+	for _, target := range []string{"http://example.com/hook", "https://127.0.0.1/hook", "https://10.1.2.3/hook", "https://[fe80::1]/hook", "https://localhost/hook"} {
+		if err := webhook.ValidateTarget(target); err == nil {
+			t.Errorf("Expected %s to be rejected", target)
+		}
+	}
+	if err := webhook.ValidateTarget("https://example.com/hook"); err != nil {
+		t.Errorf("Expected a public https URL to be accepted, got %v", err)
+	}
+
+	// A target that reaches a private address anyway is refused when dialing
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+	if err := webhook.Send(context.Background(), server.URL, UserMessage{Text: "You won"}); err == nil || called {
+		t.Errorf("Expected the loopback server not to be reached, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"os"
+	"strconv"
+)
+
+// Default upset thresholds, overridable through the environment
+const (
+	// DefaultUpsetMultiplier is the payout multiplier (payout pool / winning pool) that counts as a big win
+	DefaultUpsetMultiplier = 5.0
+	// DefaultUpsetUnderdogShare is the largest share of bettors the winning side may have for an underdog win
+	DefaultUpsetUnderdogShare = 0.25
+	// DefaultUpsetMinPool keeps tiny markets from triggering upsets
+	DefaultUpsetMinPool = 100
+)
+
+// UpsetConfig holds the thresholds for "Upset!" announcements
+type UpsetConfig struct {
+	MinMultiplier float64
+	UnderdogShare float64
+	MinPool       int64
+}
+
+// UpsetReason says why a finalized market counts as an upset
+type UpsetReason string
+
+const (
+	// UpsetBigWin means winners multiplied their stake by at least MinMultiplier
+	UpsetBigWin UpsetReason = "big_win"
+	// UpsetUnderdog means most bettors backed the losing side
+	UpsetUnderdog UpsetReason = "underdog"
+)
+
+// LoadUpsetConfig reads UPSET_MIN_MULTIPLIER, UPSET_UNDERDOG_SHARE and UPSET_MIN_POOL,
+// falling back to the defaults for missing or invalid values.
+// Setting UPSET_MIN_MULTIPLIER=0 and UPSET_UNDERDOG_SHARE=0 turns the announcements off.
+func LoadUpsetConfig() UpsetConfig {
+	cfg := UpsetConfig{
+		MinMultiplier: DefaultUpsetMultiplier,
+		UnderdogShare: DefaultUpsetUnderdogShare,
+		MinPool:       DefaultUpsetMinPool,
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("UPSET_MIN_MULTIPLIER"), 64); err == nil && v >= 0 {
+		cfg.MinMultiplier = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("UPSET_UNDERDOG_SHARE"), 64); err == nil && v >= 0 && v < 1 {
+		cfg.UnderdogShare = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("UPSET_MIN_POOL"), 10, 64); err == nil && v >= 0 {
+		cfg.MinPool = v
+	}
+	return cfg
+}
+
+// DetectUpset decides whether a finalized market is notable. pool is what the winners share,
+// after the house fee and the creator reward; winners and bettors count distinct users.
+// Markets where everyone was refunded are never upsets.
+func (c UpsetConfig) DetectUpset(pool, winningPool int64, winners, bettors int) (UpsetReason, bool) {
+	if winningPool <= 0 || pool < c.MinPool || bettors == 0 {
+		return "", false
+	}
+	if c.MinMultiplier > 0 && float64(pool)/float64(winningPool) >= c.MinMultiplier {
+		return UpsetBigWin, true
+	}
+	if c.UnderdogShare > 0 && float64(winners)/float64(bettors) <= c.UnderdogShare {
+		return UpsetUnderdog, true
+	}
+	return "", false
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestDetectUpset(t *testing.T) {
+	cfg := UpsetConfig{MinMultiplier: 5, UnderdogShare: 0.25, MinPool: 100}
+
+	tests := []struct {
+		name        string
+		pool        int64
+		winningPool int64
+		winners     int
+		bettors     int
+		want        UpsetReason
+	}{
+		{"big multiplier", 600, 100, 2, 4, UpsetBigWin},
+		{"crowd was wrong", 400, 200, 1, 5, UpsetUnderdog},
+		{"favourite won", 400, 300, 3, 4, ""},
+		{"pool too small", 60, 10, 1, 5, ""},
+		{"everyone refunded", 400, 0, 0, 4, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, ok := cfg.DetectUpset(tt.pool, tt.winningPool, tt.winners, tt.bettors)
+			if reason != tt.want || ok != (tt.want != "") {
+				t.Errorf("DetectUpset = (%q, %t), want %q", reason, ok, tt.want)
+			}
+		})
+	}
+
+	off := UpsetConfig{MinPool: 100}
+	if _, ok := off.DetectUpset(600, 100, 1, 5); ok {
+		t.Error("Expected zero thresholds to disable upsets")
+	}
+}
+
+func TestLoadUpsetConfig(t *testing.T) {
+	t.Setenv("UPSET_MIN_MULTIPLIER", "3.5")
+	t.Setenv("UPSET_UNDERDOG_SHARE", "2")
+	t.Setenv("UPSET_MIN_POOL", "")
+
+	cfg := LoadUpsetConfig()
+	if cfg.MinMultiplier != 3.5 {
+		t.Errorf("Expected multiplier 3.5, got %v", cfg.MinMultiplier)
+	}
+	if cfg.UnderdogShare != DefaultUpsetUnderdogShare || cfg.MinPool != DefaultUpsetMinPool {
+		t.Errorf("Expected defaults for invalid or missing values, got %+v", cfg)
+	}
+}
+
+func TestFinalizeMarketEmitsUpset(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	recorder := NewRecordingNotifier()
+	payoutService := NewPayoutServiceWithNotifier(recorder)
+
+	creator, _ := storage.CreateUser(99999, "creator", "Creator")
+	underdog, _ := storage.CreateUser(1001, "underdog", "Underdog")
+	favourite, _ := storage.CreateUser(1002, "favourite", "Favourite")
+	market, _ := storage.CreateMarket(creator.ID, "Will the underdog win the cup?", time.Now().Add(time.Hour))
+	_ = storage.PlaceBet(ctx, underdog.ID, market.ID, "YES", 50)
+	_ = storage.PlaceBet(ctx, favourite.ID, market.ID, "NO", 300)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	if _, err := payoutService.FinalizeMarket(ctx, market.ID, ""); err != nil {
+		t.Fatalf("FinalizeMarket failed: %v", err)
+	}
+
+	var upset *UpsetPublished
+	for _, e := range recorder.WaitFor(4, time.Second) {
+		if ev, ok := e.(UpsetPublished); ok {
+			upset = &ev
+		}
+	}
+	if upset == nil {
+		t.Fatal("Expected an UpsetPublished event")
+	}
+	if upset.Reason != UpsetBigWin || upset.Multiplier != 7 || upset.WinnersCount != 1 || upset.BettorsCount != 2 {
+		t.Errorf("Unexpected upset event: %+v", upset)
+	}
+}
+
+func TestFinalizeMarketUpsetUsesPayoutPool(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("RAKE_BPS", "500")
+	t.Setenv("CREATOR_REWARD_BPS", "250")
+
+	ctx := context.Background()
+	creator, _ := storage.CreateUser(99999, "creator", "Creator")
+	underdog, _ := storage.CreateUser(1001, "underdog", "Underdog")
+	favourite, _ := storage.CreateUser(1002, "favourite", "Favourite")
+
+	finalize := func(question string, favouriteStake int64) *UpsetPublished {
+		recorder := NewRecordingNotifier()
+		market, _ := storage.CreateMarket(creator.ID, question, time.Now().Add(time.Hour))
+		_ = storage.PlaceBet(ctx, underdog.ID, market.ID, "YES", 50)
+		_ = storage.PlaceBet(ctx, favourite.ID, market.ID, "NO", favouriteStake)
+		storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+		storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+		if _, err := NewPayoutServiceWithNotifier(recorder).FinalizeMarket(ctx, market.ID, ""); err != nil {
+			t.Fatalf("FinalizeMarket failed: %v", err)
+		}
+		for _, e := range recorder.WaitFor(3, time.Second) {
+			if ev, ok := e.(UpsetPublished); ok {
+				return &ev
+			}
+		}
+		return nil
+	}
+
+	// 250/50 is 5x gross, but the winner only shares 250-12-6: 4.64x
+	if upset := finalize("Will the underdog win the league?", 200); upset != nil {
+		t.Errorf("Expected no upset below the multiplier after fee and reward, got %+v", upset)
+	}
+	// 450-22-11 shared by 50 is 8.34x
+	upset := finalize("Will the underdog win the cup?", 400)
+	if upset == nil || upset.Reason != UpsetBigWin || upset.Multiplier != float64(417)/50 {
+		t.Errorf("Expected a big win announced at the net multiplier, got %+v", upset)
+	}
+}
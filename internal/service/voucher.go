@@ -0,0 +1,56 @@
+package service
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+// Default first-bet voucher given to new users during onboarding, overridable through the environment
+const (
+	// DefaultFirstBetVoucherMax is the most of a first bet the voucher pays back if it loses
+	DefaultFirstBetVoucherMax int64 = 100
+	// DefaultFirstBetVoucherTTL is how long a new user has to place their first bet
+	DefaultFirstBetVoucherTTL = 7 * 24 * time.Hour
+)
+
+// FirstBetVoucher is the insurance voucher new users get for their first bet
+type FirstBetVoucher struct {
+	// MaxAmount is the most of the stake paid back; 0 gives no voucher
+	MaxAmount int64
+	// TTL is how long the voucher stays valid; 0 never expires
+	TTL time.Duration
+}
+
+// LoadFirstBetVoucher reads FIRST_BET_VOUCHER_MAX (0 turns the voucher off) and
+// FIRST_BET_VOUCHER_TTL (a Go duration such as "168h", 0 for no expiry), falling back to the
+// defaults for missing or invalid values
+func LoadFirstBetVoucher() FirstBetVoucher {
+	voucher := FirstBetVoucher{
+		MaxAmount: DefaultFirstBetVoucherMax,
+		TTL:       DefaultFirstBetVoucherTTL,
+	}
+	if v, err := strconv.ParseInt(os.Getenv("FIRST_BET_VOUCHER_MAX"), 10, 64); err == nil && v >= 0 {
+		voucher.MaxAmount = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("FIRST_BET_VOUCHER_TTL")); err == nil && v >= 0 {
+		voucher.TTL = v
+	}
+	return voucher
+}
+
+// GrantFirstBetVoucher gives a new user (internal ID) the first-bet insurance voucher, nil when
+// it is turned off
+func GrantFirstBetVoucher(userID int64) (*storage.Voucher, error) {
+	config := LoadFirstBetVoucher()
+	if config.MaxAmount == 0 {
+		return nil, nil
+	}
+	var expiresAt time.Time
+	if config.TTL > 0 {
+		expiresAt = time.Now().Add(config.TTL)
+	}
+	return storage.GrantVoucher(userID, storage.VoucherInsurance, config.MaxAmount, expiresAt, 0)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestLoadFirstBetVoucher(t *testing.T) {
+	t.Setenv("FIRST_BET_VOUCHER_MAX", "")
+	t.Setenv("FIRST_BET_VOUCHER_TTL", "")
+	if config := LoadFirstBetVoucher(); config.MaxAmount != DefaultFirstBetVoucherMax || config.TTL != DefaultFirstBetVoucherTTL {
+		t.Errorf("Expected defaults, got %+v", config)
+	}
+
+	t.Setenv("FIRST_BET_VOUCHER_MAX", "0")
+	t.Setenv("FIRST_BET_VOUCHER_TTL", "0")
+	if config := LoadFirstBetVoucher(); config.MaxAmount != 0 || config.TTL != 0 {
+		t.Errorf("Expected the voucher turned off, got %+v", config)
+	}
+}
+
+func TestFinalizeRefundsInsuredLosses(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("FIRST_BET_VOUCHER_MAX", "100")
+	t.Setenv("FIRST_BET_VOUCHER_TTL", "")
+
+	ctx := context.Background()
+	recorder := NewRecordingNotifier()
+	payoutService := NewPayoutServiceWithNotifier(recorder)
+
+	creator, _ := storage.CreateUser(11111, "creator", "Creator")
+	winner, _ := storage.CreateUser(22222, "winner", "Winner")
+	loser, _ := storage.CreateUser(33333, "loser", "Loser")
+	if v, err := GrantFirstBetVoucher(winner.ID); err != nil || v == nil || v.ExpiresAt == nil {
+		t.Fatalf("Expected an expiring first bet voucher, got %+v (%v)", v, err)
+	}
+	GrantFirstBetVoucher(loser.ID)
+
+	market, _ := storage.CreateMarket(creator.ID, "Will the insured bet lose?", time.Now().Add(time.Hour))
+	storage.PlaceBet(ctx, winner.ID, market.ID, "YES", 100)
+	storage.PlaceBet(ctx, loser.ID, market.ID, "NO", 150)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	if _, err := payoutService.FinalizeMarket(ctx, market.ID, ""); err != nil {
+		t.Fatalf("FinalizeMarket failed: %v", err)
+	}
+
+	// The loser gets the insured 100 of their 150 back; the winner's voucher is simply used up
+	l, _ := storage.GetUserByID(loser.ID)
+	if l.Balance != storage.WelcomeBonusAmount-50 {
+		t.Errorf("Expected the loser to lose only the uninsured 50, got balance %d", l.Balance)
+	}
+	w, _ := storage.GetUserByID(winner.ID)
+	if w.Balance != storage.WelcomeBonusAmount+150 {
+		t.Errorf("Expected the winner to take the whole pool, got balance %d", w.Balance)
+	}
+	var unsettled int
+	storage.DB().QueryRow(`SELECT COUNT(*) FROM vouchers WHERE settled_at IS NULL`).Scan(&unsettled)
+	if unsettled != 0 {
+		t.Errorf("Expected both vouchers to be settled, %d are not", unsettled)
+	}
+
+	var refunded int64
+	for _, event := range recorder.WaitFor(2, time.Second) {
+		if e, ok := event.(LossNotice); ok {
+			refunded = e.Refunded
+		}
+	}
+	if refunded != 100 {
+		t.Errorf("Expected the loss notice to mention the 100 refunded, got %d", refunded)
+	}
+}
+
+func TestFinalizeRefundedMarketReleasesVoucher(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	payoutService := NewPayoutServiceWithNotifier(NewRecordingNotifier())
+
+	creator, _ := storage.CreateUser(11111, "creator", "Creator")
+	bettor, _ := storage.CreateUser(22222, "bettor", "Bettor")
+	storage.GrantVoucher(bettor.ID, storage.VoucherCredit, 40, time.Time{}, 0)
+
+	market, _ := storage.CreateMarket(creator.ID, "Will anybody bet on YES?", time.Now().Add(time.Hour))
+	storage.PlaceBet(ctx, bettor.ID, market.ID, "NO", 100)
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusResolved, "YES")
+
+	if _, err := payoutService.FinalizeMarket(ctx, market.ID, ""); err != nil {
+		t.Fatalf("FinalizeMarket failed: %v", err)
+	}
+
+	// Nobody won, so the 60 paid comes back and the credit can be used again
+	b, _ := storage.GetUserByID(bettor.ID)
+	if b.Balance != storage.WelcomeBonusAmount {
+		t.Errorf("Expected the paid stake back, got balance %d", b.Balance)
+	}
+	if vouchers, _ := storage.ListAvailableVouchers(bettor.ID); len(vouchers) != 1 {
+		t.Errorf("Expected the credit to be available again, got %+v", vouchers)
+	}
+}
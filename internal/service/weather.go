@@ -0,0 +1,425 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+const (
+	// DefaultWeatherAPIURL and DefaultWeatherGeocodingURL are Open-Meteo's free, keyless APIs
+	DefaultWeatherAPIURL       = "https://api.open-meteo.com"
+	DefaultWeatherGeocodingURL = "https://geocoding-api.open-meteo.com"
+	// WeatherResolveDelay is how long after the day ends the oracle waits for its observations
+	WeatherResolveDelay = 6 * time.Hour
+	// weatherPollInterval is how often the weather worker looks for days that are over
+	weatherPollInterval = 15 * time.Minute
+	// weatherTimeout bounds a single weather API call
+	weatherTimeout = 15 * time.Second
+	// weatherWetThreshold is the least rain (mm) or snow (cm) that counts; lower is trace noise
+	weatherWetThreshold = 0.1
+	// maxWeatherThreshold bounds temperature thresholds, in °C either way
+	maxWeatherThreshold = 60
+)
+
+// WeatherPlace is a geocoded place
+type WeatherPlace struct {
+	Name      string  `json:"name"`
+	Country   string  `json:"country"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Timezone  string  `json:"timezone"`
+}
+
+// WeatherDay is a place's weather over one calendar day; nil values are not available yet
+type WeatherDay struct {
+	RainMM  *float64
+	SnowCM  *float64
+	MaxTemp *float64
+	MinTemp *float64
+}
+
+// WeatherClient looks places and daily weather up with the Open-Meteo APIs
+type WeatherClient struct {
+	apiURL       string
+	geocodingURL string
+	client       *http.Client
+}
+
+// NewWeatherClient creates a client for an Open-Meteo compatible API and geocoder
+func NewWeatherClient(apiURL, geocodingURL string) *WeatherClient {
+	return &WeatherClient{
+		apiURL:       strings.TrimRight(apiURL, "/"),
+		geocodingURL: strings.TrimRight(geocodingURL, "/"),
+		client:       &http.Client{Timeout: weatherTimeout},
+	}
+}
+
+var (
+	weatherMu     sync.RWMutex
+	weatherClient *WeatherClient
+)
+
+// WeatherOracleEnabled reads WEATHER_ORACLE; the oracle is on unless it is "false"
+func WeatherOracleEnabled() bool {
+	return !strings.EqualFold(strings.TrimSpace(os.Getenv("WEATHER_ORACLE")), "false")
+}
+
+// GetWeatherClient returns the configured weather client, reading WEATHER_API_URL and
+// WEATHER_GEOCODING_URL the first time
+func GetWeatherClient() *WeatherClient {
+	weatherMu.Lock()
+	defer weatherMu.Unlock()
+	if weatherClient == nil {
+		apiURL, geocodingURL := os.Getenv("WEATHER_API_URL"), os.Getenv("WEATHER_GEOCODING_URL")
+		if apiURL == "" {
+			apiURL = DefaultWeatherAPIURL
+		}
+		if geocodingURL == "" {
+			geocodingURL = DefaultWeatherGeocodingURL
+		}
+		weatherClient = NewWeatherClient(apiURL, geocodingURL)
+	}
+	return weatherClient
+}
+
+// SetWeatherClient replaces the weather client, e.g. with one pointing at a test server
+func SetWeatherClient(c *WeatherClient) {
+	weatherMu.Lock()
+	defer weatherMu.Unlock()
+	weatherClient = c
+}
+
+// getJSON fetches endpoint and decodes its JSON answer into v
+func (c *WeatherClient) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Geocode returns the best match for a place name
+func (c *WeatherClient) Geocode(ctx context.Context, name string) (*WeatherPlace, error) {
+	query := url.Values{"name": {name}, "count": {"1"}, "language": {"en"}, "format": {"json"}}
+	var answer struct {
+		Results []WeatherPlace `json:"results"`
+	}
+	if err := c.getJSON(ctx, c.geocodingURL+"/v1/search?"+query.Encode(), &answer); err != nil {
+		return nil, fmt.Errorf("failed to look up place: %w", err)
+	}
+	if len(answer.Results) == 0 {
+		return nil, fmt.Errorf("place not found: %s", name)
+	}
+	return &answer.Results[0], nil
+}
+
+// Day returns the weather of a day (YYYY-MM-DD, in the place's timezone)
+func (c *WeatherClient) Day(ctx context.Context, w *storage.WeatherMarket) (WeatherDay, error) {
+	query := url.Values{
+		"latitude":   {fmt.Sprintf("%.4f", w.Latitude)},
+		"longitude":  {fmt.Sprintf("%.4f", w.Longitude)},
+		"daily":      {"rain_sum,showers_sum,snowfall_sum,temperature_2m_max,temperature_2m_min"},
+		"timezone":   {w.Timezone},
+		"start_date": {w.Day},
+		"end_date":   {w.Day},
+	}
+	var answer struct {
+		Daily struct {
+			Rain    []*float64 `json:"rain_sum"`
+			Showers []*float64 `json:"showers_sum"`
+			Snow    []*float64 `json:"snowfall_sum"`
+			MaxTemp []*float64 `json:"temperature_2m_max"`
+			MinTemp []*float64 `json:"temperature_2m_min"`
+		} `json:"daily"`
+	}
+	if err := c.getJSON(ctx, c.apiURL+"/v1/forecast?"+query.Encode(), &answer); err != nil {
+		return WeatherDay{}, fmt.Errorf("failed to get weather: %w", err)
+	}
+
+	first := func(values []*float64) *float64 {
+		if len(values) == 0 {
+			return nil
+		}
+		return values[0]
+	}
+	day := WeatherDay{SnowCM: first(answer.Daily.Snow), MaxTemp: first(answer.Daily.MaxTemp), MinTemp: first(answer.Daily.MinTemp)}
+	// Rain counts showers too
+	if rain := first(answer.Daily.Rain); rain != nil {
+		total := *rain
+		if showers := first(answer.Daily.Showers); showers != nil {
+			total += *showers
+		}
+		day.RainMM = &total
+	}
+	return day, nil
+}
+
+// WeatherOutcome answers a weather market from a day's weather: the outcome and the observed
+// value it rests on. It fails while the value it needs is not available.
+func WeatherOutcome(w *storage.WeatherMarket, day WeatherDay) (string, float64, error) {
+	var observed *float64
+	var yes func(v float64) bool
+	switch w.Condition {
+	case storage.WeatherRain:
+		observed, yes = day.RainMM, func(v float64) bool { return v >= weatherWetThreshold }
+	case storage.WeatherSnow:
+		observed, yes = day.SnowCM, func(v float64) bool { return v >= weatherWetThreshold }
+	case storage.WeatherAbove:
+		observed, yes = day.MaxTemp, func(v float64) bool { return v > w.Threshold }
+	case storage.WeatherBelow:
+		observed, yes = day.MinTemp, func(v float64) bool { return v < w.Threshold }
+	default:
+		return "", 0, fmt.Errorf("invalid weather condition: %s", w.Condition)
+	}
+	if observed == nil {
+		return "", 0, fmt.Errorf("weather for %s not available yet", w.Day)
+	}
+	if yes(*observed) {
+		return string(storage.OutcomeYes), *observed, nil
+	}
+	return string(storage.OutcomeNo), *observed, nil
+}
+
+// WeatherTemplate is a weather market to create: a place, a day and a condition
+type WeatherTemplate struct {
+	Place string
+	// Day is "tomorrow" or a date as YYYY-MM-DD, in the place's timezone
+	Day       string
+	Condition storage.WeatherCondition
+	// Threshold is the temperature (°C) for WeatherAbove and WeatherBelow
+	Threshold float64
+	// Key makes the creation idempotent, like MarketOptions.IdempotencyKey (optional)
+	Key string
+}
+
+// ParseWeatherCondition reads a condition the way users write it: "rain", "snow", "above 25"
+// or "below 0"
+func ParseWeatherCondition(words []string) (storage.WeatherCondition, float64, error) {
+	if len(words) == 0 {
+		return "", 0, fmt.Errorf("invalid condition: use rain, snow, above <°C> or below <°C>")
+	}
+	condition := storage.WeatherCondition(strings.ToLower(words[0]))
+	switch {
+	case (condition == storage.WeatherRain || condition == storage.WeatherSnow) && len(words) == 1:
+		return condition, 0, nil
+	case (condition == storage.WeatherAbove || condition == storage.WeatherBelow) && len(words) == 2:
+		var threshold float64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(strings.TrimSuffix(words[1], "C"), "°"), "%g", &threshold); err != nil {
+			return "", 0, fmt.Errorf("invalid threshold: %s", words[1])
+		}
+		return condition, threshold, nil
+	}
+	return "", 0, fmt.Errorf("invalid condition: use rain, snow, above <°C> or below <°C>")
+}
+
+// weatherQuestion phrases a weather market's question
+func weatherQuestion(place string, day time.Time, condition storage.WeatherCondition, threshold float64) string {
+	when := day.Format("Mon Jan 2")
+	var question string
+	switch condition {
+	case storage.WeatherRain:
+		question = fmt.Sprintf("Will it rain in %s on %s?", place, when)
+	case storage.WeatherSnow:
+		question = fmt.Sprintf("Will it snow in %s on %s?", place, when)
+	case storage.WeatherAbove:
+		question = fmt.Sprintf("Will it get above %g°C in %s on %s?", threshold, place, when)
+	case storage.WeatherBelow:
+		question = fmt.Sprintf("Will it drop below %g°C in %s on %s?", threshold, place, when)
+	}
+	return AddCategoryTag(question, "weather")
+}
+
+// weatherCriteria spells out how the oracle resolves a weather market
+func weatherCriteria(w *storage.WeatherMarket, placeName string) string {
+	var rule string
+	switch w.Condition {
+	case storage.WeatherRain:
+		rule = fmt.Sprintf("at least %g mm of rain", weatherWetThreshold)
+	case storage.WeatherSnow:
+		rule = fmt.Sprintf("at least %g cm of snowfall", weatherWetThreshold)
+	case storage.WeatherAbove:
+		rule = fmt.Sprintf("a high above %g°C", w.Threshold)
+	case storage.WeatherBelow:
+		rule = fmt.Sprintf("a low below %g°C", w.Threshold)
+	}
+	return fmt.Sprintf("YES if Open-Meteo reports %s for %s (%.2f, %.2f) on %s, local time. Resolved automatically after the day ends.",
+		rule, placeName, w.Latitude, w.Longitude, w.Day)
+}
+
+// CreateWeatherMarket creates a market from the weather template. Betting closes when the day
+// starts at the place, and the weather oracle resolves it on the creator's behalf once the day
+// is over. A repeated creation returns a *DuplicateMarketError like MarketService.CreateMarket.
+func CreateWeatherMarket(ctx context.Context, creator *storage.User, t WeatherTemplate) (*storage.WeatherMarket, error) {
+	if !t.Condition.Valid() {
+		return nil, fmt.Errorf("invalid condition: use rain, snow, above or below")
+	}
+	if math.IsNaN(t.Threshold) || math.Abs(t.Threshold) > maxWeatherThreshold {
+		return nil, fmt.Errorf("invalid threshold: must be between -%d and %d °C", maxWeatherThreshold, maxWeatherThreshold)
+	}
+	if t.Condition == storage.WeatherRain || t.Condition == storage.WeatherSnow {
+		t.Threshold = 0
+	}
+	name := strings.TrimSpace(t.Place)
+	if name == "" || len(name) > 100 {
+		return nil, fmt.Errorf("invalid place: give a city name")
+	}
+
+	place, err := GetWeatherClient().Geocode(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(place.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid place: unknown timezone %q", place.Timezone)
+	}
+
+	now := time.Now().In(loc)
+	var day time.Time
+	if strings.EqualFold(strings.TrimSpace(t.Day), "tomorrow") {
+		day = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, loc)
+	} else if day, err = time.ParseInLocation("2006-01-02", strings.TrimSpace(t.Day), loc); err != nil {
+		return nil, fmt.Errorf("invalid day: use tomorrow or a date like 2026-07-01")
+	}
+	if !day.After(now) {
+		return nil, fmt.Errorf("invalid day: %s has already started in %s", day.Format("2006-01-02"), place.Name)
+	}
+
+	weather := &storage.WeatherMarket{
+		Place:        place.Name,
+		Latitude:     place.Latitude,
+		Longitude:    place.Longitude,
+		Timezone:     place.Timezone,
+		Day:          day.Format("2006-01-02"),
+		Condition:    t.Condition,
+		Threshold:    t.Threshold,
+		ResolveAfter: day.AddDate(0, 0, 1).Add(WeatherResolveDelay),
+		Status:       storage.WeatherPending,
+	}
+	placeName := place.Name
+	if place.Country != "" {
+		placeName += ", " + place.Country
+	}
+
+	market, err := NewMarketService().CreateMarket(ctx, creator, weatherQuestion(place.Name, day, t.Condition, t.Threshold), day,
+		weatherCriteria(weather, placeName), storage.MarketOptions{Icon: categoryIcons["weather"], IdempotencyKey: t.Key})
+	if err != nil {
+		return nil, err
+	}
+	weather.MarketID = market.ID
+	if err := storage.CreateWeatherMarket(*weather); err != nil {
+		return nil, err
+	}
+
+	logger.Debug(creator.TelegramID, "weather_market_created", fmt.Sprintf("market_id=%d place=%s day=%s condition=%s", market.ID, place.Name, weather.Day, t.Condition))
+	return weather, nil
+}
+
+// WeatherWorker resolves weather markets once their day is over
+type WeatherWorker struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	now    func() time.Time
+}
+
+// NewWeatherWorker creates a weather worker
+func NewWeatherWorker() *WeatherWorker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WeatherWorker{ctx: ctx, cancel: cancel, now: time.Now}
+}
+
+// Start resolves due markets right away and then every weatherPollInterval
+func (w *WeatherWorker) Start() {
+	logger.Debug(0, "weather_worker_started", fmt.Sprintf("interval=%v", weatherPollInterval))
+	ticker := time.NewTicker(weatherPollInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			if err := w.ResolveDue(w.ctx); err != nil {
+				logger.Debug(0, "weather_worker_error", "error="+err.Error())
+			}
+			select {
+			case <-ticker.C:
+			case <-w.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the worker
+func (w *WeatherWorker) Stop() {
+	w.cancel()
+	logger.Debug(0, "weather_worker_stopped", "")
+}
+
+// ResolveDue resolves the weather markets whose day is over. A market whose weather is not
+// available yet is tried again on the next run; one its creator settled by hand only gets the
+// observation recorded.
+func (w *WeatherWorker) ResolveDue(ctx context.Context) error {
+	due, err := storage.ListDueWeatherMarkets(w.now())
+	if err != nil {
+		return err
+	}
+
+	for i := range due {
+		weather := &due[i]
+		if err := w.resolve(ctx, weather); err != nil {
+			logger.Debug(0, "weather_resolve_failed", fmt.Sprintf("market_id=%d error=%v", weather.MarketID, err))
+		}
+	}
+	return nil
+}
+
+// resolve answers one due weather market
+func (w *WeatherWorker) resolve(ctx context.Context, weather *storage.WeatherMarket) error {
+	market, err := storage.GetMarketByID(weather.MarketID)
+	if err != nil {
+		return err
+	}
+	if market == nil {
+		return fmt.Errorf("market not found")
+	}
+	if market.Status == storage.MarketStatusActive || market.Status == storage.MarketStatusLastCall {
+		return nil
+	}
+
+	day, err := GetWeatherClient().Day(ctx, weather)
+	if err != nil {
+		return err
+	}
+	outcome, observed, err := WeatherOutcome(weather, day)
+	if err != nil {
+		return err
+	}
+
+	if market.Status == storage.MarketStatusLocked {
+		creator, err := storage.GetUserByID(market.CreatorID)
+		if err != nil {
+			return err
+		}
+		if _, err := NewPayoutService().ResolveMarket(ctx, market.ID, creator, outcome); err != nil {
+			return err
+		}
+		logger.Debug(0, "weather_market_resolved", fmt.Sprintf("market_id=%d observed=%g outcome=%s", market.ID, observed, outcome))
+	}
+	return storage.ResolveWeatherMarket(weather.MarketID, observed)
+}
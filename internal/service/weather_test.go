@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+// newWeatherServer fakes the Open-Meteo geocoder and daily forecast; daily is the JSON of the
+// "daily" object
+func newWeatherServer(t *testing.T, daily *string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/search":
+			if r.URL.Query().Get("name") != "Berlin" {
+				w.Write([]byte(`{}`))
+				return
+			}
+			w.Write([]byte(`{"results": [{"name": "Berlin", "country": "Germany", "latitude": 52.52, "longitude": 13.41, "timezone": "Europe/Berlin"}]}`))
+		case "/v1/forecast":
+			w.Write([]byte(`{"daily": ` + *daily + `}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	SetWeatherClient(NewWeatherClient(server.URL, server.URL))
+	t.Cleanup(func() {
+		server.Close()
+		SetWeatherClient(nil)
+	})
+	return server
+}
+
+func TestCreateAndResolveWeatherMarket(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	daily := `{"time": ["x"], "rain_sum": [null], "showers_sum": [null], "snowfall_sum": [null], "temperature_2m_max": [null], "temperature_2m_min": [null]}`
+	newWeatherServer(t, &daily)
+	creator, _ := storage.CreateUser(7301, "creator", "Creator")
+
+	for _, tmpl := range []WeatherTemplate{
+		{Place: "Atlantis", Day: "tomorrow", Condition: storage.WeatherRain},
+		{Place: "Berlin", Day: "2020-01-01", Condition: storage.WeatherRain},
+		{Place: "Berlin", Day: "someday", Condition: storage.WeatherRain},
+		{Place: "Berlin", Day: "tomorrow", Condition: "fog"},
+		{Place: "Berlin", Day: "tomorrow", Condition: storage.WeatherAbove, Threshold: 99},
+	} {
+		if _, err := CreateWeatherMarket(context.Background(), creator, tmpl); err == nil {
+			t.Errorf("Expected %+v to be rejected", tmpl)
+		}
+	}
+
+	// Tomorrow may start within the minimum market duration, so ask about a later day
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	day := time.Now().In(berlin).AddDate(0, 0, 3).Format("2006-01-02")
+	weather, err := CreateWeatherMarket(context.Background(), creator, WeatherTemplate{Place: "Berlin", Day: day, Condition: storage.WeatherRain})
+	if err != nil {
+		t.Fatalf("CreateWeatherMarket failed: %v", err)
+	}
+	market, _ := storage.GetMarketByID(weather.MarketID)
+	start := market.ExpiresAt.In(berlin)
+	if !strings.HasPrefix(market.Question, "Will it rain in Berlin on ") || !strings.HasSuffix(market.Question, "#weather") ||
+		start.Hour() != 0 || start.Minute() != 0 || weather.Day != day || start.Format("2006-01-02") != day {
+		t.Fatalf("Unexpected market %q closing %v for %+v", market.Question, start, weather)
+	}
+
+	worker := NewWeatherWorker()
+	worker.now = func() time.Time { return weather.ResolveAfter }
+	storage.UpdateMarketStatus(market.ID, storage.MarketStatusLocked, "")
+
+	// No observation yet: the market waits
+	worker.ResolveDue(context.Background())
+	if w, _ := storage.GetWeatherMarket(market.ID); w.Status != storage.WeatherPending {
+		t.Errorf("Expected the market to wait for the weather, got %s", w.Status)
+	}
+
+	// Showers count as rain
+	daily = `{"time": ["x"], "rain_sum": [0.0], "showers_sum": [1.2], "snowfall_sum": [0.0], "temperature_2m_max": [14.0], "temperature_2m_min": [6.0]}`
+	worker.ResolveDue(context.Background())
+	market, _ = storage.GetMarketByID(market.ID)
+	w, _ := storage.GetWeatherMarket(market.ID)
+	if market.Status != storage.MarketStatusResolved || market.Outcome != "YES" || w.Status != storage.WeatherResolved || *w.Observed != 1.2 {
+		t.Errorf("Expected the market resolved YES on 1.2 mm, got %s/%s and %+v", market.Status, market.Outcome, w)
+	}
+}
+
+func TestWeatherOutcome(t *testing.T) {
+	v := func(f float64) *float64 { return &f }
+	day := WeatherDay{RainMM: v(0.05), SnowCM: v(0.1), MaxTemp: v(30), MinTemp: v(-1)}
+	for _, tc := range []struct {
+		weather storage.WeatherMarket
+		want    string
+	}{
+		{storage.WeatherMarket{Condition: storage.WeatherRain}, "NO"},
+		{storage.WeatherMarket{Condition: storage.WeatherSnow}, "YES"},
+		{storage.WeatherMarket{Condition: storage.WeatherAbove, Threshold: 30}, "NO"},
+		{storage.WeatherMarket{Condition: storage.WeatherAbove, Threshold: 29.5}, "YES"},
+		{storage.WeatherMarket{Condition: storage.WeatherBelow, Threshold: 0}, "YES"},
+	} {
+		if got, _, err := WeatherOutcome(&tc.weather, day); err != nil || got != tc.want {
+			t.Errorf("%s %g: expected %s, got %s (%v)", tc.weather.Condition, tc.weather.Threshold, tc.want, got, err)
+		}
+	}
+	if _, _, err := WeatherOutcome(&storage.WeatherMarket{Condition: storage.WeatherRain}, WeatherDay{}); err == nil {
+		t.Error("Expected missing observations to fail")
+	}
+}
+
+func TestParseWeatherCondition(t *testing.T) {
+	if c, th, err := ParseWeatherCondition([]string{"above", "25°C"}); err != nil || c != storage.WeatherAbove || th != 25 {
+		t.Errorf("Unexpected %s %g %v", c, th, err)
+	}
+	if c, _, err := ParseWeatherCondition([]string{"Rain"}); err != nil || c != storage.WeatherRain {
+		t.Errorf("Unexpected %s %v", c, err)
+	}
+	for _, words := range [][]string{{"rain", "5"}, {"below"}, {"above", "hot"}, {"hail"}, nil} {
+		if _, _, err := ParseWeatherCondition(words); err == nil {
+			t.Errorf("Expected %q to be rejected", words)
+		}
+	}
+}
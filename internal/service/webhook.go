@@ -0,0 +1,330 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+const (
+	// webhookTimeout bounds a single delivery attempt
+	webhookTimeout = 10 * time.Second
+	// maxPublicURLLength caps the length of a webhook or oracle URL
+	maxPublicURLLength = 500
+	// maxWebhookRedirects caps the redirects a delivery follows
+	maxWebhookRedirects = 3
+
+	// WebhookEventHeader names the event of a delivery, e.g. market.locked
+	WebhookEventHeader = "X-Webhook-Event"
+	// WebhookTimestampHeader carries the Unix time the delivery was signed at
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	// WebhookSignatureHeader carries "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>"
+	// keyed with the webhook's secret
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// webhookRetryDelays are the waits before the second and third delivery attempts
+var webhookRetryDelays = []time.Duration{5 * time.Second, 30 * time.Second}
+
+// MarketWebhookPayload is the JSON body posted to a market's webhook when it locks
+type MarketWebhookPayload struct {
+	Event    string    `json:"event"`
+	MarketID int64     `json:"market_id"`
+	Question string    `json:"question"`
+	LockedAt time.Time `json:"locked_at"`
+	// ResolvePath is the API endpoint that resolves the market
+	ResolvePath string `json:"resolve_path"`
+}
+
+// SignWebhook returns the signature of a delivery: the hex HMAC-SHA256 of "<timestamp>.<body>"
+// keyed with the webhook's secret
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateWebhookURL checks a callback URL with validatePublicURL
+func ValidateWebhookURL(raw string) (string, error) {
+	return validatePublicURL(raw, "webhook URL")
+}
+
+// validatePublicURL checks a URL the bot makes requests to: https only, and no localhost or
+// private, loopback or link-local IP addresses, so it cannot be aimed at the bot's own network.
+// what names the URL in errors.
+func validatePublicURL(raw, what string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) > maxPublicURLLength {
+		return "", fmt.Errorf("invalid %s: at most %d characters", what, maxPublicURLLength)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid %s", what)
+	}
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("invalid %s: must use https", what)
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("invalid %s: credentials are not allowed", what)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return "", fmt.Errorf("invalid %s: host is not public", what)
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublicIP(ip) {
+		return "", fmt.Errorf("invalid %s: host is not public", what)
+	}
+	return u.String(), nil
+}
+
+// isPublicIP reports whether ip is a routable public address
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsUnspecified() && !ip.IsMulticast()
+}
+
+// newPublicHTTPClient creates a client for URLs chosen by users. Every redirect target is checked
+// with validatePublicURL, and connections are refused when dialing unless the address is public,
+// so a host that resolves or redirects into the bot's own network is never reached.
+func newPublicHTTPClient(timeout time.Duration, what string) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialPublicOnly}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would make the dialed address the proxy's, not the target's
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxWebhookRedirects {
+				return fmt.Errorf("too many redirects")
+			}
+			_, err := validatePublicURL(req.URL.String(), what)
+			return err
+		},
+	}
+}
+
+// dialPublicOnly is a net.Dialer Control hook refusing connections to addresses that are not
+// public. It runs after DNS resolution, for every address tried.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("connection refused: host is not public")
+	}
+	return nil
+}
+
+// RegisterMarketWebhook sets the callback URL of a market the actor created and returns the
+// webhook with its new signing secret, which is only shown this once. Webhooks can be set
+// until the market locks.
+func RegisterMarketWebhook(actor *storage.User, marketID int64, rawURL string) (*storage.MarketWebhook, error) {
+	market, err := webhookMarket(actor, marketID)
+	if err != nil {
+		return nil, err
+	}
+	if market.Status != storage.MarketStatusActive && market.Status != storage.MarketStatusLastCall {
+		return nil, fmt.Errorf("market is not open: webhooks can only be set before it locks")
+	}
+	hookURL, err := ValidateWebhookURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	if err := storage.SetMarketWebhook(marketID, hookURL, hex.EncodeToString(secret)); err != nil {
+		return nil, err
+	}
+	logger.Debug(actor.TelegramID, "market_webhook_set", fmt.Sprintf("market_id=%d host=%s", marketID, hostOf(hookURL)))
+	return storage.GetMarketWebhook(marketID)
+}
+
+// GetMarketWebhook returns the webhook of a market the actor created, without its secret, or
+// nil if it has none
+func GetMarketWebhook(actor *storage.User, marketID int64) (*storage.MarketWebhook, error) {
+	if _, err := webhookMarket(actor, marketID); err != nil {
+		return nil, err
+	}
+	hook, err := storage.GetMarketWebhook(marketID)
+	if hook != nil {
+		hook.Secret = ""
+	}
+	return hook, err
+}
+
+// DeleteMarketWebhook removes the webhook of a market the actor created
+func DeleteMarketWebhook(actor *storage.User, marketID int64) error {
+	if _, err := webhookMarket(actor, marketID); err != nil {
+		return err
+	}
+	deleted, err := storage.DeleteMarketWebhook(marketID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("webhook not found")
+	}
+	logger.Debug(actor.TelegramID, "market_webhook_deleted", fmt.Sprintf("market_id=%d", marketID))
+	return nil
+}
+
+// webhookMarket returns a market whose webhook the actor may manage: only its creator can
+func webhookMarket(actor *storage.User, marketID int64) (*storage.Market, error) {
+	market, err := storage.GetMarketByID(marketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+	if market == nil || market.Hidden {
+		return nil, fmt.Errorf("market not found")
+	}
+	if market.CreatorID != actor.ID {
+		return nil, fmt.Errorf("only the market creator can manage its webhook")
+	}
+	return market, nil
+}
+
+// hostOf returns the host of a URL for logging, without its path and query
+func hostOf(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Host
+	}
+	return ""
+}
+
+// MarketWebhookNotifier posts a signed market.locked delivery to a market's webhook when the
+// market locks. Failed deliveries are retried twice; the last outcome is kept on the webhook.
+type MarketWebhookNotifier struct {
+	client      *http.Client
+	retryDelays []time.Duration
+	now         func() time.Time
+	// wg tracks deliveries in flight, so tests can wait for them
+	wg sync.WaitGroup
+}
+
+var (
+	globalWebhookNotifier *MarketWebhookNotifier
+	webhookNotifierOnce   sync.Once
+)
+
+// GetMarketWebhookNotifier returns the shared market webhook notifier
+func GetMarketWebhookNotifier() *MarketWebhookNotifier {
+	webhookNotifierOnce.Do(func() {
+		globalWebhookNotifier = NewMarketWebhookNotifier()
+	})
+	return globalWebhookNotifier
+}
+
+// NewMarketWebhookNotifier creates a market webhook notifier
+func NewMarketWebhookNotifier() *MarketWebhookNotifier {
+	return &MarketWebhookNotifier{
+		client:      newPublicHTTPClient(webhookTimeout, "webhook URL"),
+		retryDelays: webhookRetryDelays,
+		now:         time.Now,
+	}
+}
+
+// Emit delivers MarketLocked events to the market's webhook in the background
+func (n *MarketWebhookNotifier) Emit(event NotificationEvent) {
+	locked, ok := event.(MarketLocked)
+	if !ok || locked.Market == nil {
+		return
+	}
+	hook, err := storage.GetMarketWebhook(locked.Market.ID)
+	if err != nil {
+		logger.Debug(0, "market_webhook_error", fmt.Sprintf("market_id=%d error=%v", locked.Market.ID, err))
+		return
+	}
+	if hook == nil {
+		return
+	}
+
+	body, err := json.Marshal(MarketWebhookPayload{
+		Event:       "market.locked",
+		MarketID:    locked.Market.ID,
+		Question:    locked.Market.Question,
+		LockedAt:    n.now().UTC(),
+		ResolvePath: fmt.Sprintf("/api/markets/%d/resolve", locked.Market.ID),
+	})
+	if err != nil {
+		return
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.deliver(hook, "market.locked", body)
+	}()
+}
+
+// Wait blocks until the deliveries in flight are done
+func (n *MarketWebhookNotifier) Wait() {
+	n.wg.Wait()
+}
+
+// deliver posts body to the webhook, retrying failures, and records the last attempt
+func (n *MarketWebhookNotifier) deliver(hook *storage.MarketWebhook, event string, body []byte) {
+	var status int
+	var err error
+	for attempt := 0; ; attempt++ {
+		status, err = n.post(hook, event, body)
+		if err == nil || attempt == len(n.retryDelays) {
+			break
+		}
+		time.Sleep(n.retryDelays[attempt])
+	}
+
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+		logger.Debug(0, "market_webhook_failed", fmt.Sprintf("market_id=%d host=%s error=%v", hook.MarketID, hostOf(hook.URL), err))
+	} else {
+		logger.Debug(0, "market_webhook_delivered", fmt.Sprintf("market_id=%d host=%s status=%d", hook.MarketID, hostOf(hook.URL), status))
+	}
+	if err := storage.RecordWebhookDelivery(hook.MarketID, status, errMsg); err != nil {
+		logger.Debug(0, "market_webhook_error", fmt.Sprintf("market_id=%d error=%v", hook.MarketID, err))
+	}
+}
+
+// post sends one signed delivery and returns the HTTP status; any 2xx is a success
+func (n *MarketWebhookNotifier) post(hook *storage.MarketWebhook, event string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := n.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, event)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(hook.Secret, timestamp, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
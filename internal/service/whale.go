@@ -0,0 +1,126 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"predictionbot/internal/logger"
+	"predictionbot/internal/storage"
+)
+
+// Default whale alert thresholds, overridable through the environment
+const (
+	// DefaultWhaleMinBet is the single bet size (WSC) that always counts as a whale
+	DefaultWhaleMinBet = 500
+	// DefaultWhaleMinShift is how many percentage points of implied YES probability a bet must move
+	DefaultWhaleMinShift = 20.0
+	// DefaultWhaleMinPool keeps the first bets on a fresh market from counting as big moves
+	DefaultWhaleMinPool = 100
+)
+
+// WhaleConfig holds the thresholds for whale alerts
+type WhaleConfig struct {
+	MinBet   int64
+	MinShift float64
+	MinPool  int64
+}
+
+// LoadWhaleConfig reads WHALE_MIN_BET, WHALE_MIN_SHIFT and WHALE_MIN_POOL,
+// falling back to the defaults for missing or invalid values.
+// Setting WHALE_MIN_BET=0 and WHALE_MIN_SHIFT=0 turns the alerts off.
+func LoadWhaleConfig() WhaleConfig {
+	cfg := WhaleConfig{
+		MinBet:   DefaultWhaleMinBet,
+		MinShift: DefaultWhaleMinShift,
+		MinPool:  DefaultWhaleMinPool,
+	}
+	if v, err := strconv.ParseInt(os.Getenv("WHALE_MIN_BET"), 10, 64); err == nil && v >= 0 {
+		cfg.MinBet = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("WHALE_MIN_SHIFT"), 64); err == nil && v >= 0 && v <= 100 {
+		cfg.MinShift = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("WHALE_MIN_POOL"), 10, 64); err == nil && v >= 0 {
+		cfg.MinPool = v
+	}
+	return cfg
+}
+
+// impliedYes is the YES share of the pool in percent
+func impliedYes(poolYes, poolNo int64) float64 {
+	return float64(poolYes) * 100 / float64(poolYes+poolNo)
+}
+
+// DetectWhale decides whether a bet is worth an alert. poolYes and poolNo are the pools
+// after the bet; before and after are the implied YES probabilities in percent.
+// Moves are only measured once the market already had bets and reaches MinPool.
+func (c WhaleConfig) DetectWhale(outcome string, amount, poolYes, poolNo int64) (before, after float64, ok bool) {
+	if amount <= 0 || poolYes+poolNo <= 0 {
+		return 0, 0, false
+	}
+	after = impliedYes(poolYes, poolNo)
+
+	yesBefore, noBefore := poolYes, poolNo
+	if outcome == "YES" {
+		yesBefore -= amount
+	} else {
+		noBefore -= amount
+	}
+	if yesBefore+noBefore > 0 {
+		before = impliedYes(yesBefore, noBefore)
+	} else {
+		before = after
+	}
+
+	if c.MinBet > 0 && amount >= c.MinBet {
+		return before, after, true
+	}
+	shift := after - before
+	if shift < 0 {
+		shift = -shift
+	}
+	if c.MinShift > 0 && yesBefore+noBefore > 0 && poolYes+poolNo >= c.MinPool && shift >= c.MinShift {
+		return before, after, true
+	}
+	return before, after, false
+}
+
+// CheckWhaleBet emits a WhaleAlert when a just-placed bet crosses the whale thresholds.
+// poolYes and poolNo are the pools after the bet. A nil notifier uses the global notification service.
+// The market is looked up and the event emitted in the background so the bet response is not delayed.
+func CheckWhaleBet(notifier Notifier, marketID int64, outcome string, amount, poolYes, poolNo int64) {
+	before, after, ok := LoadWhaleConfig().DetectWhale(outcome, amount, poolYes, poolNo)
+	if !ok {
+		return
+	}
+	if notifier == nil {
+		notifier = defaultNotifier()
+	}
+
+	go func() {
+		market, err := storage.GetMarketByID(marketID)
+		if err != nil || market == nil {
+			logger.Debug(0, "whale_alert_error", fmt.Sprintf("market_id=%d error=%v", marketID, err))
+			return
+		}
+		if market.Hidden {
+			// Moderators hid the market; don't advertise it on the channel
+			return
+		}
+		if market.Status == storage.MarketStatusLastCall || market.PoolsHidden() || market.SidesHidden() {
+			// Odds updates are frozen once the last call was announced, blind markets show none
+			// and sealed markets must not reveal which side a bet is on
+			return
+		}
+		notifier.Emit(WhaleAlert{
+			MarketID:  marketID,
+			Question:  market.Question,
+			Outcome:   outcome,
+			Amount:    amount,
+			YesBefore: before,
+			YesAfter:  after,
+			TotalPool: poolYes + poolNo,
+		})
+	}()
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"predictionbot/internal/storage"
+)
+
+func TestDetectWhale(t *testing.T) {
+	cfg := WhaleConfig{MinBet: 500, MinShift: 20, MinPool: 100}
+
+	tests := []struct {
+		name          string
+		outcome       string
+		amount        int64
+		poolYes       int64
+		poolNo        int64
+		want          bool
+		before, after float64
+	}{
+		{name: "big bet", outcome: "YES", amount: 500, poolYes: 1500, poolNo: 1000, want: true, before: 50, after: 60},
+		{name: "small nudge", outcome: "NO", amount: 10, poolYes: 100, poolNo: 110, want: false},
+		{name: "market mover", outcome: "NO", amount: 100, poolYes: 50, poolNo: 150, want: true, before: 50, after: 25},
+		{name: "move below min pool", outcome: "NO", amount: 30, poolYes: 20, poolNo: 50, want: false},
+		{name: "first bet", outcome: "YES", amount: 200, poolYes: 200, poolNo: 0, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after, ok := cfg.DetectWhale(tt.outcome, tt.amount, tt.poolYes, tt.poolNo)
+			if ok != tt.want {
+				t.Fatalf("DetectWhale() ok = %v, want %v (before=%.1f after=%.1f)", ok, tt.want, before, after)
+			}
+			if ok && (before != tt.before || after != tt.after) {
+				t.Errorf("Expected %.1f -> %.1f, got %.1f -> %.1f", tt.before, tt.after, before, after)
+			}
+		})
+	}
+
+	if _, _, ok := (WhaleConfig{}).DetectWhale("YES", 10000, 10000, 100); ok {
+		t.Error("Expected zero thresholds to turn alerts off")
+	}
+}
+
+func TestLoadWhaleConfig(t *testing.T) {
+	t.Setenv("WHALE_MIN_BET", "250")
+	t.Setenv("WHALE_MIN_SHIFT", "150")
+	t.Setenv("WHALE_MIN_POOL", "x")
+
+	cfg := LoadWhaleConfig()
+	if cfg.MinBet != 250 || cfg.MinShift != DefaultWhaleMinShift || cfg.MinPool != DefaultWhaleMinPool {
+		t.Errorf("Unexpected config %+v", cfg)
+	}
+}
+
+func TestCheckWhaleBetEmitsAlert(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("WHALE_MIN_BET", "500")
+
+	creator, _ := storage.CreateUser(5001, "creator", "Creator")
+	market, _ := storage.CreateMarket(creator.ID, "Will the whale surface?", time.Now().Add(time.Hour))
+	storage.PlaceBet(context.Background(), creator.ID, market.ID, "YES", 600)
+
+	recorder := NewRecordingNotifier()
+	CheckWhaleBet(recorder, market.ID, "NO", 10, 600, 10)
+	CheckWhaleBet(recorder, market.ID, "YES", 600, 600, 0)
+
+	events := recorder.WaitFor(1, time.Second)
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 whale alert, got %d", len(events))
+	}
+	alert, ok := events[0].(WhaleAlert)
+	if !ok || alert.MarketID != market.ID || alert.Amount != 600 || alert.Question != market.Question {
+		t.Errorf("Unexpected event %+v", events[0])
+	}
+}
+
+func TestCheckWhaleBetSkipsMaskedMarkets(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	t.Setenv("WHALE_MIN_BET", "500")
+
+	creator, _ := storage.CreateUser(5001, "creator", "Creator")
+	blind, _ := storage.CreateMarketWithOptions(creator.ID, "Will the blind whale surface?", time.Now().Add(time.Hour), storage.MarketOptions{Blind: true})
+	sealed, _ := storage.CreateMarketWithOptions(creator.ID, "Will the sealed whale surface?", time.Now().Add(time.Hour), storage.MarketOptions{Sealed: true})
+
+	recorder := NewRecordingNotifier()
+	CheckWhaleBet(recorder, blind.ID, "YES", 600, 600, 0)
+	CheckWhaleBet(recorder, sealed.ID, "YES", 600, 600, 0)
+
+	if events := recorder.WaitFor(1, 200*time.Millisecond); len(events) != 0 {
+		t.Errorf("Expected no whale alerts for blind or sealed markets, got %+v", events)
+	}
+}
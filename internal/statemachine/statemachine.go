@@ -0,0 +1,96 @@
+// Package statemachine holds the lifecycle of a market: which statuses exist and which moves
+// between them are allowed.
+//
+//	ACTIVE ─► LAST_CALL ─► LOCKED ─► RESOLVED ◄─► DISPUTED ─► FINALIZED
+//	   └─────────────────────►┘      │  └─────────────────────►┘
+//	                                 ↕
+//	                          NEEDS_ATTENTION
+//
+// A LOCKED market goes straight to DISPUTED when a community vote escalates it, and ACTIVE,
+// LAST_CALL and LOCKED markets can be MERGED into a duplicate. A RESOLVED market whose
+// auto-finalization keeps failing waits in NEEDS_ATTENTION until an admin sends it back.
+// FINALIZED and MERGED are final.
+// There is no void status: a market nobody won is finalized with every bet refunded.
+package statemachine
+
+import "fmt"
+
+// Status is a market's status, as stored in markets.status
+type Status string
+
+const (
+	Active    Status = "ACTIVE"
+	LastCall  Status = "LAST_CALL"
+	Locked    Status = "LOCKED"
+	Resolved  Status = "RESOLVED"
+	Disputed  Status = "DISPUTED"
+	Finalized Status = "FINALIZED"
+	Merged    Status = "MERGED"
+	// NeedsAttention is a resolved market the worker gave up finalizing
+	NeedsAttention Status = "NEEDS_ATTENTION"
+)
+
+// transitions lists the statuses each status may move to
+var transitions = map[Status][]Status{
+	Active:         {LastCall, Locked, Merged},
+	LastCall:       {Locked, Merged},
+	Locked:         {Resolved, Disputed, Merged},
+	Resolved:       {Disputed, Finalized, NeedsAttention},
+	Disputed:       {Resolved, Finalized},
+	NeedsAttention: {Resolved},
+	Finalized:      nil,
+	Merged:         nil,
+}
+
+// Statuses returns every status, in lifecycle order
+func Statuses() []Status {
+	return []Status{Active, LastCall, Locked, Resolved, Disputed, NeedsAttention, Finalized, Merged}
+}
+
+// UnknownStatusError is returned for a status that is not part of the lifecycle
+type UnknownStatusError struct {
+	Status Status
+}
+
+func (e *UnknownStatusError) Error() string {
+	return fmt.Sprintf("invalid status: unknown market status %q", e.Status)
+}
+
+// TransitionError is returned for a move the lifecycle does not allow
+type TransitionError struct {
+	From Status
+	To   Status
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("invalid status transition: market cannot move from %s to %s", e.From, e.To)
+}
+
+// Next returns the statuses a market in status may move to; none for final statuses
+func Next(status Status) []Status {
+	return append([]Status(nil), transitions[status]...)
+}
+
+// IsFinal reports whether a market in status can no longer change
+func IsFinal(status Status) bool {
+	next, ok := transitions[status]
+	return ok && len(next) == 0
+}
+
+// Validate checks that a market may move from one status to another. Staying in the same
+// status is not a move and is rejected too.
+func Validate(from, to Status) error {
+	next, ok := transitions[from]
+	if !ok {
+		return &UnknownStatusError{Status: from}
+	}
+	if _, ok := transitions[to]; !ok {
+		return &UnknownStatusError{Status: to}
+	}
+	for _, allowed := range next {
+		if allowed == to {
+			return nil
+		}
+	}
+	return &TransitionError{From: from, To: to}
+}
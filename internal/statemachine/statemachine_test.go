@@ -0,0 +1,77 @@
+package statemachine
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateEveryPair(t *testing.T) {
+	allowed := map[[2]Status]bool{
+		{Active, LastCall}:         true,
+		{Active, Locked}:           true,
+		{Active, Merged}:           true,
+		{LastCall, Locked}:         true,
+		{LastCall, Merged}:         true,
+		{Locked, Resolved}:         true,
+		{Locked, Disputed}:         true,
+		{Locked, Merged}:           true,
+		{Resolved, Disputed}:       true,
+		{Resolved, Finalized}:      true,
+		{Disputed, Resolved}:       true,
+		{Disputed, Finalized}:      true,
+		{Resolved, NeedsAttention}: true,
+		{NeedsAttention, Resolved}: true,
+	}
+
+	for _, from := range Statuses() {
+		for _, to := range Statuses() {
+			err := Validate(from, to)
+			if allowed[[2]Status{from, to}] {
+				if err != nil {
+					t.Errorf("Expected %s -> %s to be allowed, got %v", from, to, err)
+				}
+				continue
+			}
+			var transitionErr *TransitionError
+			if !errors.As(err, &transitionErr) || transitionErr.From != from || transitionErr.To != to {
+				t.Errorf("Expected a TransitionError for %s -> %s, got %v", from, to, err)
+			}
+		}
+	}
+}
+
+func TestValidateUnknownStatus(t *testing.T) {
+	var unknown *UnknownStatusError
+	if err := Validate("VOIDED", Finalized); !errors.As(err, &unknown) || unknown.Status != "VOIDED" {
+		t.Errorf("Expected an UnknownStatusError for the source, got %v", err)
+	}
+	if err := Validate(Resolved, "VOIDED"); !errors.As(err, &unknown) || unknown.Status != "VOIDED" {
+		t.Errorf("Expected an UnknownStatusError for the target, got %v", err)
+	}
+}
+
+func TestNextAndIsFinal(t *testing.T) {
+	for _, status := range Statuses() {
+		next := Next(status)
+		if IsFinal(status) != (len(next) == 0) {
+			t.Errorf("Expected IsFinal(%s) to match an empty Next, got %v", status, next)
+		}
+		for _, to := range next {
+			if err := Validate(status, to); err != nil {
+				t.Errorf("Next(%s) lists %s, but Validate rejects it: %v", status, to, err)
+			}
+		}
+	}
+	if !IsFinal(Finalized) || !IsFinal(Merged) || IsFinal(Disputed) {
+		t.Error("Expected only FINALIZED and MERGED to be final")
+	}
+	if IsFinal("VOIDED") {
+		t.Error("Expected an unknown status not to be final")
+	}
+
+	// Next returns a copy
+	Next(Active)[0] = Finalized
+	if err := Validate(Active, Finalized); err == nil {
+		t.Error("Expected changing Next's result to leave the lifecycle alone")
+	}
+}
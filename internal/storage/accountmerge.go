@@ -0,0 +1,333 @@
+package storage
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AccountMergeTTL is how long the confirmation codes of an account merge stay valid
+const AccountMergeTTL = 24 * time.Hour
+
+// AccountMergeStatus represents the status of an account merge
+type AccountMergeStatus string
+
+const (
+	// AccountMergePending means the codes were sent and the admin has not completed the merge
+	AccountMergePending   AccountMergeStatus = "PENDING"
+	AccountMergeCompleted AccountMergeStatus = "COMPLETED"
+	AccountMergeCancelled AccountMergeStatus = "CANCELLED"
+)
+
+// AccountMerge is an admin-assisted move of everything an old account (FromUserID) owns to a
+// user's new account (ToUserID). Each account gets a confirmation code; the admin completes the
+// merge with both. The codes are never serialized.
+type AccountMerge struct {
+	ID          int64              `json:"id"`
+	FromUserID  int64              `json:"from_user_id"`
+	ToUserID    int64              `json:"to_user_id"`
+	RequestedBy int64              `json:"requested_by"`
+	Status      AccountMergeStatus `json:"status"`
+	FromCode    string             `json:"-"`
+	ToCode      string             `json:"-"`
+	ExpiresAt   time.Time          `json:"expires_at"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// AccountMergeResult counts what a completed merge moved to the new account
+type AccountMergeResult struct {
+	MergeID      int64 `json:"merge_id"`
+	FromUserID   int64 `json:"from_user_id"`
+	ToUserID     int64 `json:"to_user_id"`
+	Balance      int64 `json:"balance"`
+	NewBalance   int64 `json:"new_balance"`
+	Bets         int64 `json:"bets"`
+	Transactions int64 `json:"transactions"`
+	Markets      int64 `json:"markets"`
+}
+
+// CreateAccountMerge opens a merge of fromUserID into toUserID (internal IDs) requested by an admin.
+// A user can only be part of one pending merge at a time.
+func CreateAccountMerge(ctx context.Context, fromUserID, toUserID, requestedBy int64, fromCode, toCode string) (*AccountMerge, error) {
+	if fromUserID == toUserID {
+		return nil, fmt.Errorf("invalid merge: cannot merge an account into itself")
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var openCount int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM account_merges
+		WHERE status = 'PENDING' AND expires_at > CURRENT_TIMESTAMP
+		AND (from_user_id IN (?, ?) OR to_user_id IN (?, ?))
+	`, fromUserID, toUserID, fromUserID, toUserID).Scan(&openCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check open merges: %w", err)
+	}
+	if openCount > 0 {
+		return nil, fmt.Errorf("a merge is already pending for one of these accounts")
+	}
+
+	var mergeID int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO account_merges (from_user_id, to_user_id, requested_by, from_code, to_code, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, fromUserID, toUserID, requestedBy, fromCode, toCode, sqlTimestamp(time.Now().Add(AccountMergeTTL))).Scan(&mergeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert merge: %w", err)
+	}
+
+	details := fmt.Sprintf("merge_id=%d to_user_id=%d", mergeID, toUserID)
+	if err := logAuditTx(ctx, tx, requestedBy, "account_merge_requested", AuditEntityUser, fromUserID, details); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetAccountMerge(mergeID)
+}
+
+// GetAccountMerge retrieves a merge by its ID
+func GetAccountMerge(id int64) (*AccountMerge, error) {
+	var merge AccountMerge
+	err := db.QueryRow(`
+		SELECT id, from_user_id, to_user_id, requested_by, status, from_code, to_code, expires_at, created_at, updated_at
+		FROM account_merges
+		WHERE id = ?
+	`, id).Scan(
+		&merge.ID,
+		&merge.FromUserID,
+		&merge.ToUserID,
+		&merge.RequestedBy,
+		&merge.Status,
+		&merge.FromCode,
+		&merge.ToCode,
+		&merge.ExpiresAt,
+		&merge.CreatedAt,
+		&merge.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get merge by id: %w", err)
+	}
+	return &merge, nil
+}
+
+// ListPendingAccountMerges returns the merges still waiting for their codes, oldest first
+func ListPendingAccountMerges() ([]AccountMerge, error) {
+	rows, err := db.Query(`
+		SELECT id, from_user_id, to_user_id, requested_by, status, expires_at, created_at, updated_at
+		FROM account_merges
+		WHERE status = 'PENDING' AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query merges: %w", err)
+	}
+	defer rows.Close()
+
+	merges := []AccountMerge{}
+	for rows.Next() {
+		var m AccountMerge
+		if err := rows.Scan(&m.ID, &m.FromUserID, &m.ToUserID, &m.RequestedBy, &m.Status, &m.ExpiresAt, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan merge: %w", err)
+		}
+		merges = append(merges, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating merges: %w", err)
+	}
+	return merges, nil
+}
+
+// CancelAccountMerge withdraws a pending merge
+func CancelAccountMerge(ctx context.Context, mergeID, actorID int64) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var fromUserID int64
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT from_user_id, status FROM account_merges WHERE id = ?`, mergeID).Scan(&fromUserID, &status)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("merge not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get merge: %w", err)
+	}
+	if status != string(AccountMergePending) {
+		return fmt.Errorf("merge is no longer pending: status is %s", status)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE account_merges
+		SET status = 'CANCELLED', updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, mergeID)
+	if err != nil {
+		return fmt.Errorf("failed to update merge: %w", err)
+	}
+
+	if err := logAuditTx(ctx, tx, actorID, "account_merge_cancelled", AuditEntityUser, fromUserID, fmt.Sprintf("merge_id=%d", mergeID)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// CompleteAccountMerge checks both confirmation codes and moves the old account's balance, bets,
+// shares, transactions and markets to the new account in one transaction. Transactions move with the
+// balance, so each account's balance still matches its ledger. The old account stays behind,
+// empty, so its Telegram ID cannot sign up for a second welcome bonus.
+func CompleteAccountMerge(ctx context.Context, mergeID int64, fromCode, toCode string, actorID int64) (*AccountMergeResult, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status, wantFrom, wantTo string
+	var expiresAt time.Time
+	result := &AccountMergeResult{MergeID: mergeID}
+	err = tx.QueryRowContext(ctx, `
+		SELECT from_user_id, to_user_id, status, from_code, to_code, expires_at
+		FROM account_merges
+		WHERE id = ?
+	`, mergeID).Scan(&result.FromUserID, &result.ToUserID, &status, &wantFrom, &wantTo, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("merge not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get merge: %w", err)
+	}
+
+	if status != string(AccountMergePending) {
+		return nil, fmt.Errorf("merge is no longer pending: status is %s", status)
+	}
+	if time.Now().After(expiresAt) {
+		return nil, fmt.Errorf("merge is no longer pending: the confirmation codes expired")
+	}
+	if subtle.ConstantTimeCompare([]byte(fromCode), []byte(wantFrom)) != 1 || subtle.ConstantTimeCompare([]byte(toCode), []byte(wantTo)) != 1 {
+		return nil, fmt.Errorf("invalid confirmation codes")
+	}
+
+	from, to := result.FromUserID, result.ToUserID
+	err = tx.QueryRowContext(ctx, `SELECT balance FROM users WHERE id = ?`, from).Scan(&result.Balance)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("old account not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get old account balance: %w", err)
+	}
+
+	moves := []struct {
+		query string
+		count *int64
+	}{
+		{`UPDATE bets SET user_id = ? WHERE user_id = ?`, &result.Bets},
+		{`UPDATE transactions SET user_id = ? WHERE user_id = ?`, &result.Transactions},
+		{`UPDATE markets SET creator_id = ? WHERE creator_id = ?`, &result.Markets},
+		{`UPDATE market_transfers SET from_user_id = ? WHERE from_user_id = ?`, nil},
+		{`UPDATE market_transfers SET to_user_id = ? WHERE to_user_id = ?`, nil},
+		{`UPDATE resolution_cosigns SET proposed_by = ? WHERE proposed_by = ?`, nil},
+		{`UPDATE community_proposals SET proposed_by = ? WHERE proposed_by = ?`, nil},
+		{`UPDATE vouchers SET user_id = ? WHERE user_id = ?`, nil},
+		{`UPDATE disputes SET user_id = ? WHERE user_id = ?`, nil},
+		{`UPDATE share_trades SET user_id = ? WHERE user_id = ?`, nil},
+		// Share positions on a market both accounts traded are added together
+		{`INSERT INTO share_positions (market_id, user_id, outcome, shares)
+		  SELECT market_id, ?, outcome, shares FROM share_positions WHERE user_id = ?
+		  ON CONFLICT(market_id, user_id, outcome) DO UPDATE SET shares = share_positions.shares + excluded.shares`, nil},
+	}
+	for _, m := range moves {
+		res, err := tx.ExecContext(ctx, m.query, to, from)
+		if err != nil {
+			return nil, fmt.Errorf("failed to move account data: %w", err)
+		}
+		if m.count != nil {
+			*m.count, _ = res.RowsAffected()
+		}
+	}
+	// Snoozes and votes the new account already has win. A code both accounts redeemed keeps
+	// the old account's redemption on record.
+	for _, query := range []string{
+		`UPDATE market_snoozes SET user_id = ? WHERE user_id = ? AND market_id NOT IN (SELECT market_id FROM market_snoozes WHERE user_id = ?)`,
+		`UPDATE community_votes SET user_id = ? WHERE user_id = ? AND proposal_id NOT IN (SELECT proposal_id FROM community_votes WHERE user_id = ?)`,
+		`UPDATE promo_redemptions SET user_id = ? WHERE user_id = ? AND code_id NOT IN (SELECT code_id FROM promo_redemptions WHERE user_id = ?)`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, to, from, to); err != nil {
+			return nil, fmt.Errorf("failed to move account data: %w", err)
+		}
+	}
+
+	// Keep the better of the two best streaks; the current streak is the new account's
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_streaks (user_id, current, best)
+		SELECT ?, current, best FROM user_streaks WHERE user_id = ?
+		ON CONFLICT(user_id) DO UPDATE SET best = CASE WHEN excluded.best > user_streaks.best THEN excluded.best ELSE user_streaks.best END
+	`, to, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge streaks: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_streaks WHERE user_id = ?`, from); err != nil {
+		return nil, fmt.Errorf("failed to merge streaks: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM share_positions WHERE user_id = ?`, from); err != nil {
+		return nil, fmt.Errorf("failed to merge share positions: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET balance = 0 WHERE id = ?`, from); err != nil {
+		return nil, fmt.Errorf("failed to update balance: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET balance = balance + ? WHERE id = ?`, result.Balance, to); err != nil {
+		return nil, fmt.Errorf("failed to update balance: %w", err)
+	}
+	err = tx.QueryRowContext(ctx, `SELECT balance FROM users WHERE id = ?`, to).Scan(&result.NewBalance)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("new account not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get new account balance: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE account_merges
+		SET status = 'COMPLETED', updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, mergeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update merge: %w", err)
+	}
+
+	details := fmt.Sprintf("merge_id=%d to_user_id=%d balance=%d bets=%d transactions=%d markets=%d",
+		mergeID, to, result.Balance, result.Bets, result.Transactions, result.Markets)
+	if err := logAuditTx(ctx, tx, actorID, "account_merged", AuditEntityUser, from, details); err != nil {
+		return nil, err
+	}
+	details = fmt.Sprintf("merge_id=%d from_user_id=%d", mergeID, from)
+	if err := logAuditTx(ctx, tx, actorID, "account_merged", AuditEntityUser, to, details); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCompleteAccountMerge(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	admin, _ := CreateUser(6001, "admin", "Admin")
+	old, _ := CreateUser(6002, "old", "Old")
+	current, _ := CreateUser(6003, "new", "New")
+
+	market, _ := CreateMarket(old.ID, "Will the accounts merge?", time.Now().Add(time.Hour))
+	other, _ := CreateMarket(admin.ID, "Will the bet follow?", time.Now().Add(time.Hour))
+	if err := PlaceBet(ctx, old.ID, other.ID, "YES", 300); err != nil {
+		t.Fatalf("PlaceBet failed: %v", err)
+	}
+
+	// Both accounts hold YES shares on the same market maker market
+	maker, err := CreateMarketWithOptions(admin.ID, "Will the shares follow?", time.Now().Add(time.Hour), MarketOptions{PricingMode: PricingMarketMaker})
+	if err != nil {
+		t.Fatalf("CreateMarketWithOptions failed: %v", err)
+	}
+	trade := func(userID, shares, amount int64) {
+		tx, _ := db.BeginTx(ctx, nil)
+		defer tx.Rollback()
+		if _, err := RecordShareTradeTx(ctx, tx, ShareTrade{MarketID: maker.ID, UserID: userID, Outcome: "YES", Shares: shares, Amount: amount}); err != nil {
+			t.Fatalf("RecordShareTradeTx failed: %v", err)
+		}
+		tx.Commit()
+	}
+	trade(old.ID, 10, 6)
+	trade(current.ID, 4, 3)
+
+	merge, err := CreateAccountMerge(ctx, old.ID, current.ID, admin.ID, "111111", "222222")
+	if err != nil {
+		t.Fatalf("CreateAccountMerge failed: %v", err)
+	}
+	if _, err := CreateAccountMerge(ctx, current.ID, admin.ID, admin.ID, "333333", "444444"); err == nil {
+		t.Error("Expected a second merge for the same account to fail")
+	}
+
+	if _, err := CompleteAccountMerge(ctx, merge.ID, "111111", "000000", admin.ID); err == nil {
+		t.Fatal("Expected a wrong code to be rejected")
+	}
+
+	result, err := CompleteAccountMerge(ctx, merge.ID, "111111", "222222", admin.ID)
+	if err != nil {
+		t.Fatalf("CompleteAccountMerge failed: %v", err)
+	}
+	wantBalance := WelcomeBonusAmount - 300 - 6
+	if result.Balance != wantBalance || result.Bets != 1 || result.Markets != 1 || result.Transactions != 3 {
+		t.Errorf("Unexpected merge result %+v", result)
+	}
+
+	oldUser, _ := GetUserByID(old.ID)
+	newUser, _ := GetUserByID(current.ID)
+	if oldUser.Balance != 0 || newUser.Balance != WelcomeBonusAmount-3+wantBalance {
+		t.Errorf("Expected balances 0 and %d, got %d and %d", WelcomeBonusAmount-3+wantBalance, oldUser.Balance, newUser.Balance)
+	}
+
+	// The ledger still adds up to the balance
+	var ledger int64
+	db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE user_id = ?`, current.ID).Scan(&ledger)
+	if ledger != newUser.Balance {
+		t.Errorf("Expected the ledger to add up to %d, got %d", newUser.Balance, ledger)
+	}
+
+	// The shares, and so their payout, follow the new account
+	holding, _ := GetShareHolding(maker.ID, current.ID)
+	if holding.SharesYes != 14 || holding.Spent != 9 {
+		t.Errorf("Expected the holdings to add up to 14 YES for 9, got %+v", holding)
+	}
+	var positions, shares int64
+	db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(shares), 0) FROM share_positions WHERE market_id = ? AND user_id = ?`, maker.ID, current.ID).Scan(&positions, &shares)
+	if positions != 1 || shares != 14 {
+		t.Errorf("Expected one position of 14 shares, got %d holding %d", positions, shares)
+	}
+	db.QueryRow(`SELECT COUNT(*) FROM share_positions WHERE user_id = ?`, old.ID).Scan(&positions)
+	if positions != 0 {
+		t.Errorf("Expected the old account to hold no shares, got %d positions", positions)
+	}
+
+	moved, _ := GetMarketByID(market.ID)
+	if moved.CreatorID != current.ID {
+		t.Errorf("Expected the market to move to the new account, creator is %d", moved.CreatorID)
+	}
+
+	if _, err := CompleteAccountMerge(ctx, merge.ID, "111111", "222222", admin.ID); err == nil {
+		t.Error("Expected a completed merge not to run twice")
+	}
+}
+
+func TestCancelAccountMerge(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	admin, _ := CreateUser(6001, "admin", "Admin")
+	old, _ := CreateUser(6002, "old", "Old")
+	current, _ := CreateUser(6003, "new", "New")
+
+	merge, _ := CreateAccountMerge(ctx, old.ID, current.ID, admin.ID, "111111", "222222")
+	if err := CancelAccountMerge(ctx, merge.ID, admin.ID); err != nil {
+		t.Fatalf("CancelAccountMerge failed: %v", err)
+	}
+	if merges, _ := ListPendingAccountMerges(); len(merges) != 0 {
+		t.Errorf("Expected no pending merges, got %d", len(merges))
+	}
+	if _, err := CompleteAccountMerge(ctx, merge.ID, "111111", "222222", admin.ID); err == nil {
+		t.Error("Expected a cancelled merge not to complete")
+	}
+}
@@ -0,0 +1,53 @@
+package storage
+
+import "fmt"
+
+// BetActivity counts bets by weekday (0 = Sunday) and hour of day, for activity heatmaps
+type BetActivity struct {
+	// TZOffsetMinutes is the offset from UTC the buckets were computed in
+	TZOffsetMinutes int        `json:"tz_offset_minutes"`
+	Counts          [7][24]int `json:"counts"`
+	Total           int        `json:"total"`
+}
+
+// GetBetActivity buckets bets by the weekday and hour they were placed, shifted by tzOffsetMinutes.
+// userID is an internal user ID; 0 counts the bets of every user.
+func GetBetActivity(userID int64, tzOffsetMinutes int) (*BetActivity, error) {
+	shift := fmt.Sprintf("%+d minutes", tzOffsetMinutes)
+	buckets := `CAST(strftime('%w', placed_at, ?) AS INTEGER) AS weekday,
+		       CAST(strftime('%H', placed_at, ?) AS INTEGER) AS hour`
+	if usingPostgres {
+		buckets = `CAST(EXTRACT(DOW FROM placed_at + CAST(? AS INTERVAL)) AS INTEGER) AS weekday,
+		       CAST(EXTRACT(HOUR FROM placed_at + CAST(? AS INTERVAL)) AS INTEGER) AS hour`
+	}
+	rows, err := db.Query(`
+		SELECT `+buckets+`,
+		       COUNT(*)
+		FROM bets
+		WHERE (? = 0 OR user_id = ?) AND placed_at IS NOT NULL
+		GROUP BY weekday, hour
+	`, shift, shift, userID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bet activity: %w", err)
+	}
+	defer rows.Close()
+
+	activity := &BetActivity{TZOffsetMinutes: tzOffsetMinutes}
+	for rows.Next() {
+		var weekday, hour, count int
+		if err := rows.Scan(&weekday, &hour, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan bet activity: %w", err)
+		}
+		if weekday < 0 || weekday > 6 || hour < 0 || hour > 23 {
+			continue
+		}
+		activity.Counts[weekday][hour] += count
+		activity.Total += count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bet activity: %w", err)
+	}
+
+	return activity, nil
+}
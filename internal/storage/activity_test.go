@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetBetActivity(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	alice, _ := CreateUser(4001, "alice", "Alice")
+	bob, _ := CreateUser(4002, "bob", "Bob")
+	market, _ := CreateMarket(alice.ID, "Will it rain tomorrow?", time.Now().Add(time.Hour))
+	PlaceBet(ctx, alice.ID, market.ID, "YES", 10)
+	PlaceBet(ctx, alice.ID, market.ID, "YES", 10)
+	PlaceBet(ctx, bob.ID, market.ID, "NO", 10)
+
+	// 2024-01-07 was a Sunday
+	db.Exec(`UPDATE bets SET placed_at = '2024-01-07 23:30:00' WHERE user_id = ?`, alice.ID)
+	db.Exec(`UPDATE bets SET placed_at = '2024-01-08 09:00:00' WHERE user_id = ?`, bob.ID)
+
+	mine, err := GetBetActivity(alice.ID, 0)
+	if err != nil {
+		t.Fatalf("GetBetActivity failed: %v", err)
+	}
+	if mine.Total != 2 || mine.Counts[0][23] != 2 {
+		t.Errorf("Expected 2 bets on Sunday 23h, got total=%d sunday23=%d", mine.Total, mine.Counts[0][23])
+	}
+
+	// One hour ahead of UTC moves alice's bets to Monday midnight
+	shifted, _ := GetBetActivity(alice.ID, 60)
+	if shifted.Counts[1][0] != 2 {
+		t.Errorf("Expected shifted bets on Monday 0h, got %v", shifted.Counts[1])
+	}
+
+	all, _ := GetBetActivity(0, 0)
+	if all.Total != 3 || all.Counts[1][9] != 1 {
+		t.Errorf("Expected 3 platform bets with one on Monday 9h, got total=%d monday9=%d", all.Total, all.Counts[1][9])
+	}
+}
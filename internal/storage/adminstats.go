@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// DailyActivity is one UTC day of platform activity
+type DailyActivity struct {
+	Date string `json:"date"`
+	// ActiveUsers counts users who placed a bet, created a market or were last seen that day
+	ActiveUsers int   `json:"active_users"`
+	NewUsers    int   `json:"new_users"`
+	Bets        int   `json:"bets"`
+	BetVolume   int64 `json:"bet_volume"`
+}
+
+// MarketBacklog counts the markets waiting on someone to close them out
+type MarketBacklog struct {
+	// Locked markets wait for their creator (or the community) to resolve them
+	Locked            int     `json:"locked"`
+	OldestLockedHours float64 `json:"oldest_locked_hours"`
+	// Resolved markets wait out their dispute window
+	Resolved int `json:"resolved"`
+	// Disputed markets wait for an admin or oracle
+	Disputed int `json:"disputed"`
+}
+
+// PlatformStats is the admin dashboard: activity over the last Days days and the current backlog
+type PlatformStats struct {
+	Days int `json:"days"`
+	// DAU and WAU count users seen or active in the last 24 hours and 7 days, whatever the window
+	DAU int `json:"dau"`
+	WAU int `json:"wau"`
+	// InactiveUsers counts users who joined and then stayed away for InactiveAfter
+	InactiveUsers int   `json:"inactive_users"`
+	NewUsers      int   `json:"new_users"`
+	Bets          int   `json:"bets"`
+	BetVolume     int64 `json:"bet_volume"`
+	// BetsBySource splits the window's bets by where they were placed
+	BetsBySource  map[BetSource]BetSourceActivity `json:"bets_by_source"`
+	Bailouts      int                             `json:"bailouts"`
+	BailoutUsers  int                             `json:"bailout_users"`
+	BailoutAmount int64                           `json:"bailout_amount"`
+	// FeesCollected is what the house took from market pools in the window
+	FeesCollected int64 `json:"fees_collected"`
+	// MarketsResolved counts markets resolved in the window, MarketsDisputed those disputed in it
+	MarketsResolved int             `json:"markets_resolved"`
+	MarketsDisputed int             `json:"markets_disputed"`
+	DisputeRate     float64         `json:"dispute_rate"`
+	Backlog         MarketBacklog   `json:"backlog"`
+	Daily           []DailyActivity `json:"daily"`
+}
+
+// InactiveAfter is how long a user must have been away to count as inactive
+const InactiveAfter = 30 * 24 * time.Hour
+
+// activitySQL lists (user, time) pairs for everything that counts as being active. last_seen_at
+// only keeps the latest visit, so older days rely on bets and markets.
+const activitySQL = `
+	SELECT user_id, placed_at AS at FROM bets
+	UNION ALL
+	SELECT creator_id, created_at FROM markets
+	UNION ALL
+	SELECT id, last_seen_at FROM users WHERE last_seen_at IS NOT NULL
+`
+
+// GetPlatformStats aggregates platform activity over the last days days (UTC), oldest day first
+func GetPlatformStats(days int, now time.Time) (*PlatformStats, error) {
+	if days <= 0 {
+		return nil, fmt.Errorf("invalid window: must be at least 1 day")
+	}
+	now = now.UTC()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1-days)
+	sinceStr := sqlTimestamp(since)
+	nowStr := sqlTimestamp(now)
+
+	stats := &PlatformStats{Days: days}
+
+	err := db.QueryRow(`
+		SELECT COUNT(DISTINCT CASE WHEN at >= ? THEN user_id END),
+		       COUNT(DISTINCT CASE WHEN at >= ? THEN user_id END)
+		FROM (`+activitySQL+`) AS activity
+		WHERE at <= ?
+	`, sqlTimestamp(now.AddDate(0, 0, -1)), sqlTimestamp(now.AddDate(0, 0, -7)), nowStr).Scan(&stats.DAU, &stats.WAU)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count active users: %w", err)
+	}
+
+	inactiveSince := sqlTimestamp(now.Add(-InactiveAfter))
+	err = db.QueryRow(`
+		SELECT COUNT(*) FROM users
+		WHERE created_at < ? AND telegram_id != ? AND id NOT IN (SELECT user_id FROM (`+activitySQL+`) AS activity WHERE at >= ?)
+	`, inactiveSince, HouseTelegramID, inactiveSince).Scan(&stats.InactiveUsers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count inactive users: %w", err)
+	}
+
+	err = db.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT user_id), COALESCE(SUM(amount), 0)
+		FROM transactions
+		WHERE source_type = 'BAILOUT' AND created_at >= ?
+	`, sinceStr).Scan(&stats.Bailouts, &stats.BailoutUsers, &stats.BailoutAmount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count bailouts: %w", err)
+	}
+
+	err = db.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0) FROM transactions
+		WHERE source_type = 'FEE' AND created_at >= ?
+	`, sinceStr).Scan(&stats.FeesCollected)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum fees: %w", err)
+	}
+
+	err = db.QueryRow(`
+		SELECT COUNT(CASE WHEN resolved_at >= ? THEN 1 END),
+		       COUNT(CASE WHEN disputed_at >= ? THEN 1 END)
+		FROM markets
+	`, sinceStr, sinceStr).Scan(&stats.MarketsResolved, &stats.MarketsDisputed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count resolutions: %w", err)
+	}
+	if stats.MarketsResolved > 0 {
+		stats.DisputeRate = float64(stats.MarketsDisputed) / float64(stats.MarketsResolved)
+	}
+
+	err = db.QueryRow(`
+		SELECT COUNT(CASE WHEN status = 'LOCKED' THEN 1 END),
+		       COALESCE(MAX(CASE WHEN status = 'LOCKED' THEN `+sqlHoursSince("locked_at")+` END), 0),
+		       COUNT(CASE WHEN status = 'RESOLVED' THEN 1 END),
+		       COUNT(CASE WHEN status = 'DISPUTED' THEN 1 END)
+		FROM markets
+		WHERE hidden = 0
+	`, nowStr).Scan(&stats.Backlog.Locked, &stats.Backlog.OldestLockedHours, &stats.Backlog.Resolved, &stats.Backlog.Disputed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count market backlog: %w", err)
+	}
+
+	stats.BetsBySource = make(map[BetSource]BetSourceActivity)
+	rows, err := db.Query(`
+		SELECT source, COUNT(*), COALESCE(SUM(amount), 0)
+		FROM bets
+		WHERE placed_at >= ?
+		GROUP BY source
+	`, sinceStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count bets by source: %w", err)
+	}
+	for rows.Next() {
+		var source BetSource
+		var activity BetSourceActivity
+		if err := rows.Scan(&source, &activity.Bets, &activity.BetVolume); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan bets by source: %w", err)
+		}
+		stats.BetsBySource[source] = activity
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error iterating bets by source: %w", err)
+	}
+
+	daily := make(map[string]*DailyActivity, days)
+	for i := 0; i < days; i++ {
+		date := since.AddDate(0, 0, i).Format("2006-01-02")
+		stats.Daily = append(stats.Daily, DailyActivity{Date: date})
+	}
+	for i := range stats.Daily {
+		daily[stats.Daily[i].Date] = &stats.Daily[i]
+	}
+
+	// One query per series; each collects its rows before the next runs. Every query takes the
+	// start of the window first.
+	series := []struct {
+		query string
+		args  []any
+		scan  func(day *DailyActivity, count int, sum int64)
+	}{
+		{
+			query: `SELECT ` + sqlDate("at") + `, COUNT(DISTINCT user_id), 0 FROM (` + activitySQL + `) AS activity WHERE at >= ? GROUP BY ` + sqlDate("at"),
+			scan:  func(day *DailyActivity, count int, _ int64) { day.ActiveUsers = count },
+		},
+		{
+			// The house account is created with the first fee, it is not a signup
+			query: `SELECT ` + sqlDate("created_at") + `, COUNT(*), 0 FROM users WHERE created_at >= ? AND telegram_id != ? GROUP BY ` + sqlDate("created_at"),
+			args:  []any{HouseTelegramID},
+			scan:  func(day *DailyActivity, count int, _ int64) { day.NewUsers = count },
+		},
+		{
+			query: `SELECT ` + sqlDate("placed_at") + `, COUNT(*), COALESCE(SUM(amount), 0) FROM bets WHERE placed_at >= ? GROUP BY ` + sqlDate("placed_at"),
+			scan:  func(day *DailyActivity, count int, sum int64) { day.Bets, day.BetVolume = count, sum },
+		},
+	}
+	for _, s := range series {
+		rows, err := db.Query(s.query, append([]any{sinceStr}, s.args...)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query daily activity: %w", err)
+		}
+		for rows.Next() {
+			var date string
+			var count int
+			var sum int64
+			if err := rows.Scan(&date, &count, &sum); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan daily activity: %w", err)
+			}
+			if day := daily[date]; day != nil {
+				s.scan(day, count, sum)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating daily activity: %w", err)
+		}
+	}
+
+	for _, day := range stats.Daily {
+		stats.NewUsers += day.NewUsers
+		stats.Bets += day.Bets
+		stats.BetVolume += day.BetVolume
+	}
+	return stats, nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetPlatformStats(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(1, "creator", "Creator")
+	bettor, _ := CreateUser(2, "bettor", "Bettor")
+	old, _ := CreateUser(3, "old", "Old")
+	db.Exec(`UPDATE users SET created_at = ? WHERE id = ?`, sqlTimestamp(time.Now().AddDate(0, 0, -60)), old.ID)
+
+	market, _ := CreateMarket(creator.ID, "Will the dashboard add up?", time.Now().Add(time.Hour))
+	if err := PlaceBet(ctx, bettor.ID, market.ID, "YES", 100); err != nil {
+		t.Fatalf("PlaceBet failed: %v", err)
+	}
+	// A bet from three days ago only counts towards the weekly numbers
+	db.Exec(`INSERT INTO bets (user_id, market_id, outcome, amount, placed_at) VALUES (?, ?, 'NO', 50, ?)`, old.ID, market.ID, sqlTimestamp(time.Now().AddDate(0, 0, -3)))
+	db.Exec(`INSERT INTO transactions (user_id, amount, source_type) VALUES (?, 500, 'BAILOUT')`, bettor.ID)
+
+	resolved, _ := CreateMarket(creator.ID, "Was this one resolved?", time.Now().Add(time.Hour))
+	UpdateMarketStatus(resolved.ID, MarketStatusLocked, "")
+	UpdateMarketStatus(resolved.ID, MarketStatusResolved, "YES")
+	UpdateMarketStatus(resolved.ID, MarketStatusDisputed, "")
+	locked, _ := CreateMarket(creator.ID, "Is this one waiting?", time.Now().Add(time.Hour))
+	UpdateMarketStatus(locked.ID, MarketStatusLocked, "")
+
+	// The house account is neither a signup nor an inactive user
+	tx, _ := db.BeginTx(ctx, nil)
+	if err := CreditHouseFeeTx(ctx, tx, resolved.ID, 5, "House fee"); err != nil {
+		t.Fatalf("CreditHouseFeeTx failed: %v", err)
+	}
+	tx.Commit()
+	db.Exec(`UPDATE users SET created_at = ? WHERE telegram_id = ?`, sqlTimestamp(time.Now().AddDate(0, 0, -1)), HouseTelegramID)
+
+	stats, err := GetPlatformStats(7, time.Now())
+	if err != nil {
+		t.Fatalf("GetPlatformStats failed: %v", err)
+	}
+	if stats.DAU != 2 || stats.WAU != 3 {
+		t.Errorf("Expected DAU 2 and WAU 3, got %d and %d", stats.DAU, stats.WAU)
+	}
+	if stats.NewUsers != 2 || stats.Bets != 2 || stats.BetVolume != 150 {
+		t.Errorf("Expected 2 new users and 2 bets worth 150, got %+v", stats)
+	}
+	if stats.InactiveUsers != 0 || stats.FeesCollected != 5 {
+		t.Errorf("Expected no inactive users and the 5 fee, got %d and %d", stats.InactiveUsers, stats.FeesCollected)
+	}
+	if stats.Bailouts != 1 || stats.BailoutUsers != 1 || stats.BailoutAmount != 500 {
+		t.Errorf("Expected one bailout of 500, got %d/%d/%d", stats.Bailouts, stats.BailoutUsers, stats.BailoutAmount)
+	}
+	if stats.MarketsResolved != 1 || stats.MarketsDisputed != 1 || stats.DisputeRate != 1 {
+		t.Errorf("Expected one disputed resolution, got %d/%d rate %.2f", stats.MarketsResolved, stats.MarketsDisputed, stats.DisputeRate)
+	}
+	if stats.Backlog.Locked != 1 || stats.Backlog.Disputed != 1 {
+		t.Errorf("Unexpected backlog %+v", stats.Backlog)
+	}
+	if len(stats.Daily) != 7 {
+		t.Fatalf("Expected 7 days, got %d", len(stats.Daily))
+	}
+	if today := stats.Daily[6]; today.Bets != 1 || today.ActiveUsers != 2 || today.NewUsers != 2 {
+		t.Errorf("Unexpected activity today %+v", today)
+	}
+
+	if _, err := GetPlatformStats(0, time.Now()); err == nil {
+		t.Error("Expected an empty window to be rejected")
+	}
+}
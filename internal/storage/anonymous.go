@@ -0,0 +1,15 @@
+package storage
+
+// AnonymousCreatorName is shown instead of the creator of an anonymous market (or of a creator without a name)
+const AnonymousCreatorName = "Anonymous"
+
+// creatorNameSQL is the public creator name of a market (alias m) joined with its creator (alias u)
+const creatorNameSQL = `CASE WHEN m.anonymous = 1 THEN '` + AnonymousCreatorName + `' ELSE COALESCE(NULLIF(u.first_name, ''), '` + AnonymousCreatorName + `') END`
+
+// PublicCreatorName returns the creator name to show for the market; creator may be nil
+func (m *Market) PublicCreatorName(creator *User) string {
+	if m.Anonymous || creator == nil || creator.FirstName == "" {
+		return AnonymousCreatorName
+	}
+	return creator.FirstName
+}
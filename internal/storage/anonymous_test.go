@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnonymousMarketCreator(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator, _ := CreateUser(4001, "creator", "Creator")
+
+	market, err := CreateMarketWithOptions(creator.ID, "Will the anonymous market stay anonymous?", time.Now().Add(time.Hour), MarketOptions{Anonymous: true})
+	if err != nil {
+		t.Fatalf("CreateMarketWithOptions failed: %v", err)
+	}
+	if !market.Anonymous || market.CreatorID != creator.ID {
+		t.Fatalf("Expected an anonymous market that still records its creator, got %+v", market)
+	}
+	if name := market.PublicCreatorName(creator); name != AnonymousCreatorName {
+		t.Errorf("Expected %q, got %q", AnonymousCreatorName, name)
+	}
+
+	public, _ := CreateMarket(creator.ID, "Will the public market name its creator?", time.Now().Add(time.Hour))
+	if name := public.PublicCreatorName(creator); name != "Creator" {
+		t.Errorf("Expected creator name, got %q", name)
+	}
+
+	markets, err := ListActiveMarketsWithCreator()
+	if err != nil || len(markets) != 2 {
+		t.Fatalf("Expected 2 markets, got %d (err=%v)", len(markets), err)
+	}
+	names := map[int64]string{}
+	for _, m := range markets {
+		names[m.ID] = m.CreatorName
+	}
+	if names[market.ID] != AnonymousCreatorName || names[public.ID] != "Creator" {
+		t.Errorf("Unexpected creator names in list: %v", names)
+	}
+
+	withPools, err := GetMarketWithPools(market.ID)
+	if err != nil || withPools.CreatorName != AnonymousCreatorName {
+		t.Errorf("Expected anonymous creator, got %+v (err=%v)", withPools, err)
+	}
+}
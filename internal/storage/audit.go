@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AuditEntry represents a recorded action in the audit log
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	ActorID    int64     `json:"actor_id"`
+	Action     string    `json:"action"`
+	EntityType string    `json:"entity_type"`
+	EntityID   int64     `json:"entity_id"`
+	Details    string    `json:"details,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// LogAudit records an action performed by a user (internal ID) on an entity
+func LogAudit(actorID int64, action, entityType string, entityID int64, details string) error {
+	_, err := db.Exec(`
+		INSERT INTO audit_log (actor_id, action, entity_type, entity_id, details)
+		VALUES (?, ?, ?, ?, ?)
+	`, actorID, action, entityType, entityID, details)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// logAuditTx records an audit entry as part of an existing transaction
+func logAuditTx(ctx context.Context, tx *sql.Tx, actorID int64, action, entityType string, entityID int64, details string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO audit_log (actor_id, action, entity_type, entity_id, details)
+		VALUES (?, ?, ?, ?, ?)
+	`, actorID, action, entityType, entityID, details)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// GetAuditLog returns the audit entries for an entity, oldest first
+func GetAuditLog(entityType string, entityID int64) ([]AuditEntry, error) {
+	rows, err := db.Query(`
+		SELECT id, actor_id, action, entity_type, entity_id, details, created_at
+		FROM audit_log
+		WHERE entity_type = ? AND entity_id = ?
+		ORDER BY id ASC
+	`, entityType, entityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		var details sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.EntityType, &entry.EntityID, &details, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if details.Valid {
+			entry.Details = details.String
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit log: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BetCancelPolicy is when and at what cost users may cancel a bet. Cancelling is always limited to
+// markets that are still active; the zero value allows it until then, free of charge.
+type BetCancelPolicy struct {
+	// Window is how long after placing a bet it may be cancelled; 0 is until the market stops taking bets
+	Window time.Duration
+	// FeePercent is the share of the stake kept on cancellation, rounded down
+	FeePercent int
+}
+
+var (
+	betCancelPolicyMu sync.RWMutex
+	betCancelPolicy   BetCancelPolicy
+)
+
+// SetBetCancelPolicy configures the policy CancelBet enforces
+func SetBetCancelPolicy(policy BetCancelPolicy) {
+	betCancelPolicyMu.Lock()
+	defer betCancelPolicyMu.Unlock()
+	betCancelPolicy = policy
+}
+
+// GetBetCancelPolicy returns the policy CancelBet enforces
+func GetBetCancelPolicy() BetCancelPolicy {
+	betCancelPolicyMu.RLock()
+	defer betCancelPolicyMu.RUnlock()
+	return betCancelPolicy
+}
+
+// Fee returns the fee kept when a bet of amount is cancelled
+func (p BetCancelPolicy) Fee(amount int64) int64 {
+	return amount * int64(p.FeePercent) / 100
+}
+
+// BetCancellation is the record of a cancelled bet
+type BetCancellation struct {
+	BetID       int64     `json:"bet_id"`
+	UserID      int64     `json:"-"`
+	MarketID    int64     `json:"market_id"`
+	Outcome     string    `json:"outcome"`
+	Amount      int64     `json:"amount"`
+	Fee         int64     `json:"fee"`
+	Refund      int64     `json:"refund"`
+	PlacedAt    time.Time `json:"placed_at"`
+	CancelledAt time.Time `json:"cancelled_at"`
+}
+
+// CancelBet cancels a user's (internal ID) bet while its market still takes bets: the bet leaves
+// the pools and the stake, less the cancellation fee, goes back to the user. Bets of other users
+// are reported as not found. During a last call the pools are frozen, so bets can no longer be
+// cancelled.
+func CancelBet(ctx context.Context, userID, betID int64) (*BetCancellation, error) {
+	policy := GetBetCancelPolicy()
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	c := BetCancellation{BetID: betID}
+	var owner int64
+	var marketStatus string
+	var expiresAt time.Time
+	var hidden bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT b.user_id, b.market_id, b.outcome, b.amount, b.placed_at, m.status, m.expires_at, m.hidden
+		FROM bets b
+		JOIN markets m ON b.market_id = m.id
+		WHERE b.id = ?
+	`, betID).Scan(&owner, &c.MarketID, &c.Outcome, &c.Amount, &c.PlacedAt, &marketStatus, &expiresAt, &hidden)
+	if err == sql.ErrNoRows || (err == nil && owner != userID) {
+		return nil, fmt.Errorf("bet not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bet: %w", err)
+	}
+	c.UserID = owner
+
+	if marketStatus != string(MarketStatusActive) {
+		return nil, fmt.Errorf("market is not active: status is %s", marketStatus)
+	}
+	if hidden {
+		return nil, fmt.Errorf("market is not active: hidden by a moderator")
+	}
+	if time.Now().After(expiresAt) {
+		return nil, fmt.Errorf("market has expired")
+	}
+	if policy.Window > 0 && time.Since(c.PlacedAt) > policy.Window {
+		return nil, fmt.Errorf("cancel window closed: bets can only be cancelled within %s of placing them", policy.Window)
+	}
+
+	// The voucher is the user's to use again; what a promo credit paid is not refunded
+	credit, err := ReleaseVoucherTx(ctx, tx, betID)
+	if err != nil {
+		return nil, err
+	}
+	c.Fee = policy.Fee(c.Amount)
+	c.Refund = max(c.Amount-c.Fee-credit, 0)
+
+	// Copy placed_at as stored, so the bet limits compare it like the placed_at of bets
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO bet_cancellations (bet_id, user_id, market_id, outcome, amount, fee, placed_at)
+		SELECT id, user_id, market_id, outcome, amount, ?, placed_at FROM bets WHERE id = ?
+	`, c.Fee, betID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record cancellation: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM bets WHERE id = ?`, betID); err != nil {
+		return nil, fmt.Errorf("failed to delete bet: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET balance = balance + ? WHERE id = ?`, c.Refund, userID); err != nil {
+		return nil, fmt.Errorf("failed to update balance: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+		VALUES (?, ?, 'BET_CANCELLED', ?, ?)
+	`, userID, c.Refund, fmt.Sprintf("Cancelled bet #%d on market #%d (fee %d)", betID, c.MarketID, c.Fee), c.MarketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log transaction: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	c.CancelledAt = time.Now()
+	return &c, nil
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCancelBet(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	SetBetCancelPolicy(BetCancelPolicy{Window: time.Minute, FeePercent: 10})
+	defer SetBetCancelPolicy(BetCancelPolicy{})
+
+	ctx := context.Background()
+	bettor, _ := CreateUser(4001, "fickle", "Fickle")
+	other, _ := CreateUser(4002, "other", "Other")
+	market, _ := CreateMarket(bettor.ID, "Will I change my mind?", time.Now().Add(time.Hour))
+	PlaceBet(ctx, bettor.ID, market.ID, "YES", 105)
+	PlaceBet(ctx, other.ID, market.ID, "NO", 50)
+
+	bets, _ := GetUserBets(bettor.ID)
+	if len(bets) != 1 || !bets[0].Cancellable {
+		t.Fatalf("Expected a cancellable bet, got %+v", bets)
+	}
+	betID := bets[0].ID
+
+	if _, err := CancelBet(ctx, other.ID, betID); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected another user's bet not to be found, got %v", err)
+	}
+
+	cancellation, err := CancelBet(ctx, bettor.ID, betID)
+	if err != nil {
+		t.Fatalf("CancelBet failed: %v", err)
+	}
+	if cancellation.Fee != 10 || cancellation.Refund != 95 {
+		t.Errorf("Expected a fee of 10 and 95 back, got %+v", cancellation)
+	}
+	user, _ := GetUserByID(bettor.ID)
+	if user.Balance != WelcomeBonusAmount-10 {
+		t.Errorf("Expected only the fee to be lost, got balance %d", user.Balance)
+	}
+	if yes, no, _ := GetPoolTotals(market.ID); yes != 0 || no != 50 {
+		t.Errorf("Expected the bet to leave the pools, got %d / %d", yes, no)
+	}
+	if _, err := CancelBet(ctx, bettor.ID, betID); err == nil {
+		t.Error("Expected a cancelled bet not to be cancelled twice")
+	}
+
+	// Bets past the window stay
+	PlaceBet(ctx, other.ID, market.ID, "YES", 20)
+	db.Exec(`UPDATE bets SET placed_at = ? WHERE user_id = ?`, sqlTimestamp(time.Now().Add(-2*time.Minute)), other.ID)
+	bets, _ = GetUserBets(other.ID)
+	if bets[0].Cancellable {
+		t.Error("Expected a bet past the window not to be cancellable")
+	}
+	if _, err := CancelBet(ctx, other.ID, bets[0].ID); err == nil || !strings.Contains(err.Error(), "cancel window") {
+		t.Errorf("Expected the cancel window to be closed, got %v", err)
+	}
+
+	// Without a window bets can be cancelled until the last call
+	SetBetCancelPolicy(BetCancelPolicy{})
+	db.Exec(`UPDATE markets SET status = 'LAST_CALL' WHERE id = ?`, market.ID)
+	if _, err := CancelBet(ctx, other.ID, bets[0].ID); err == nil || !strings.Contains(err.Error(), "not active") {
+		t.Errorf("Expected no cancellations during the last call, got %v", err)
+	}
+}
+
+func TestCancelledBetsCountTowardsLimits(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	SetBetLimits(BetLimits{Cooldown: time.Minute})
+	defer SetBetLimits(BetLimits{})
+
+	ctx := context.Background()
+	user, _ := CreateUser(4001, "flipper", "Flipper")
+	market, _ := CreateMarket(user.ID, "Will cancelling dodge the cooldown?", time.Now().Add(time.Hour))
+	PlaceBet(ctx, user.ID, market.ID, "YES", 10)
+	bets, _ := GetUserBets(user.ID)
+	if _, err := CancelBet(ctx, user.ID, bets[0].ID); err != nil {
+		t.Fatalf("CancelBet failed: %v", err)
+	}
+
+	if err := PlaceBet(ctx, user.ID, market.ID, "NO", 10); err == nil || !strings.Contains(err.Error(), "bet cooldown") {
+		t.Errorf("Expected the cancelled bet to keep the cooldown, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BetLimits throttles rapid betting and bounds stakes. Zero values turn a limit off, which is
+// the default until the server configures them with SetBetLimits.
+type BetLimits struct {
+	// Cooldown is the minimum time between two bets by the same user on the same market
+	Cooldown time.Duration
+	// DailyCap is the maximum number of bets a user may place in any 24 hours
+	DailyCap int
+	// MinBet is the smallest stake a bet may have
+	MinBet int64
+	// MaxPerMarket is the most a user may have staked on one market, all their open bets together
+	MaxPerMarket int64
+}
+
+var (
+	betLimitsMu sync.RWMutex
+	betLimits   BetLimits
+)
+
+// SetBetLimits configures the limits PlaceBet enforces
+func SetBetLimits(limits BetLimits) {
+	betLimitsMu.Lock()
+	defer betLimitsMu.Unlock()
+	betLimits = limits
+}
+
+// GetBetLimits returns the limits PlaceBet enforces
+func GetBetLimits() BetLimits {
+	betLimitsMu.RLock()
+	defer betLimitsMu.RUnlock()
+	return betLimits
+}
+
+// checkBetLimitsTx returns an error when a bet of amount is below the minimum stake or would
+// take the user's stake on the market over the maximum, or when the user is still cooling down
+// on the market or has used up their daily bets. Cancelled bets count towards the throttles, so
+// cancelling doesn't reset them, but not towards the stake, since their money was refunded.
+func checkBetLimitsTx(ctx context.Context, tx *sql.Tx, userID, marketID, amount int64) error {
+	limits := GetBetLimits()
+
+	if limits.MinBet > 0 && amount < limits.MinBet {
+		return fmt.Errorf("invalid amount: the minimum bet is %d", limits.MinBet)
+	}
+
+	if limits.MaxPerMarket > 0 {
+		var staked int64
+		err := tx.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(amount), 0) FROM bets WHERE user_id = ? AND market_id = ?
+		`, userID, marketID).Scan(&staked)
+		if err != nil {
+			return fmt.Errorf("failed to sum stake on market: %w", err)
+		}
+		if staked+amount > limits.MaxPerMarket {
+			return fmt.Errorf("market bet limit reached: at most %d per user on a market, %d already staked", limits.MaxPerMarket, staked)
+		}
+	}
+
+	if limits.Cooldown > 0 {
+		var lastBet sql.NullInt64
+		err := tx.QueryRowContext(ctx, `
+			SELECT `+sqlUnixSeconds("MAX(placed_at)")+`
+			FROM (
+				SELECT placed_at FROM bets WHERE user_id = ? AND market_id = ?
+				UNION ALL
+				SELECT placed_at FROM bet_cancellations WHERE user_id = ? AND market_id = ?
+			) AS placed
+		`, userID, marketID, userID, marketID).Scan(&lastBet)
+		if err != nil {
+			return fmt.Errorf("failed to check bet cooldown: %w", err)
+		}
+		if lastBet.Valid {
+			remaining := time.Unix(lastBet.Int64, 0).Add(limits.Cooldown).Sub(time.Now())
+			if remaining > 0 {
+				return fmt.Errorf("bet cooldown: wait %ds before betting on this market again", int(remaining.Seconds()+0.999))
+			}
+		}
+	}
+
+	if limits.DailyCap > 0 {
+		var count int
+		dayAgo := sqlTimestamp(time.Now().Add(-24 * time.Hour))
+		err := tx.QueryRowContext(ctx, `
+			SELECT (SELECT COUNT(*) FROM bets WHERE user_id = ? AND placed_at > ?)
+			     + (SELECT COUNT(*) FROM bet_cancellations WHERE user_id = ? AND placed_at > ?)
+		`, userID, dayAgo, userID, dayAgo).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("failed to check daily bet limit: %w", err)
+		}
+		if count >= limits.DailyCap {
+			return fmt.Errorf("daily bet limit reached: at most %d bets per 24 hours", limits.DailyCap)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlaceBetCooldown(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	SetBetLimits(BetLimits{Cooldown: time.Minute})
+	defer SetBetLimits(BetLimits{})
+
+	ctx := context.Background()
+	user, _ := CreateUser(4001, "flipper", "Flipper")
+	first, _ := CreateMarket(user.ID, "Will it rain tomorrow?", time.Now().Add(time.Hour))
+	second, _ := CreateMarket(user.ID, "Will it snow tomorrow?", time.Now().Add(time.Hour))
+
+	if err := PlaceBet(ctx, user.ID, first.ID, "YES", 10); err != nil {
+		t.Fatalf("First bet failed: %v", err)
+	}
+	err := PlaceBet(ctx, user.ID, first.ID, "NO", 10)
+	if err == nil || !strings.Contains(err.Error(), "bet cooldown") {
+		t.Fatalf("Expected cooldown error, got %v", err)
+	}
+	// The cooldown is per market
+	if err := PlaceBet(ctx, user.ID, second.ID, "NO", 10); err != nil {
+		t.Errorf("Expected bet on another market to pass, got %v", err)
+	}
+
+	// Once the last bet is old enough, betting is allowed again
+	if _, err := db.Exec(`UPDATE bets SET placed_at = ? WHERE market_id = ?`, sqlTimestamp(time.Now().Add(-2*time.Minute)), first.ID); err != nil {
+		t.Fatalf("Failed to age bets: %v", err)
+	}
+	if err := PlaceBet(ctx, user.ID, first.ID, "NO", 10); err != nil {
+		t.Errorf("Expected bet after cooldown to pass, got %v", err)
+	}
+}
+
+func TestPlaceBetDailyCap(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	SetBetLimits(BetLimits{DailyCap: 2})
+	defer SetBetLimits(BetLimits{})
+
+	ctx := context.Background()
+	user, _ := CreateUser(4001, "busy", "Busy")
+	market, _ := CreateMarket(user.ID, "Will it rain tomorrow?", time.Now().Add(time.Hour))
+
+	PlaceBet(ctx, user.ID, market.ID, "YES", 10)
+	PlaceBet(ctx, user.ID, market.ID, "YES", 10)
+	err := PlaceBet(ctx, user.ID, market.ID, "YES", 10)
+	if err == nil || !strings.Contains(err.Error(), "daily bet limit") {
+		t.Fatalf("Expected daily limit error, got %v", err)
+	}
+
+	// Yesterday's bets don't count
+	db.Exec(`UPDATE bets SET placed_at = ?`, sqlTimestamp(time.Now().Add(-25*time.Hour)))
+	if err := PlaceBet(ctx, user.ID, market.ID, "YES", 10); err != nil {
+		t.Errorf("Expected bet to pass once old bets age out, got %v", err)
+	}
+	if user, _ := GetUserByID(user.ID); user.Balance != WelcomeBonusAmount-30 {
+		t.Errorf("Expected only 3 bets charged, balance is %d", user.Balance)
+	}
+}
+
+func TestPlaceBetStakeLimits(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	SetBetLimits(BetLimits{MinBet: 5, MaxPerMarket: 50})
+	defer SetBetLimits(BetLimits{})
+
+	ctx := context.Background()
+	user, _ := CreateUser(4001, "whale", "Whale")
+	market, _ := CreateMarket(user.ID, "Will it rain tomorrow?", time.Now().Add(time.Hour))
+	other, _ := CreateMarket(user.ID, "Will it snow tomorrow?", time.Now().Add(time.Hour))
+
+	err := PlaceBet(ctx, user.ID, market.ID, "YES", 4)
+	if err == nil || !strings.Contains(err.Error(), "minimum bet is 5") {
+		t.Fatalf("Expected minimum bet error, got %v", err)
+	}
+	if err := PlaceBet(ctx, user.ID, market.ID, "YES", 30); err != nil {
+		t.Fatalf("First bet failed: %v", err)
+	}
+	// Both sides count towards the cap
+	err = PlaceBet(ctx, user.ID, market.ID, "NO", 25)
+	if err == nil || !strings.Contains(err.Error(), "market bet limit") {
+		t.Fatalf("Expected market bet limit error, got %v", err)
+	}
+	if err := PlaceBet(ctx, user.ID, market.ID, "NO", 20); err != nil {
+		t.Errorf("Expected a bet up to the cap to pass, got %v", err)
+	}
+	// The cap is per market
+	if err := PlaceBet(ctx, user.ID, other.ID, "YES", 50); err != nil {
+		t.Errorf("Expected a bet on another market to pass, got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package storage
+
+// BetSource is the surface a bet was placed from, as stored in bets.source
+type BetSource string
+
+const (
+	// BetSourceWebApp is a bet placed in the Telegram Web App
+	BetSourceWebApp BetSource = "webapp"
+	// BetSourceInlineButton is a bet placed with the bet buttons of /list
+	BetSourceInlineButton BetSource = "inline_button"
+	// BetSourceAPI is a bet sent to the HTTP API by anything but the Web App
+	BetSourceAPI BetSource = "api"
+	// BetSourceUnknown is a bet placed before sources were recorded
+	BetSourceUnknown BetSource = "unknown"
+)
+
+// Valid reports whether s is one of the known bet sources
+func (s BetSource) Valid() bool {
+	switch s {
+	case BetSourceWebApp, BetSourceInlineButton, BetSourceAPI, BetSourceUnknown:
+		return true
+	}
+	return false
+}
+
+// BetSourceActivity counts the bets placed from one source
+type BetSourceActivity struct {
+	Bets      int   `json:"bets"`
+	BetVolume int64 `json:"bet_volume"`
+}
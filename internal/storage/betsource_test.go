@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPlaceBetFromRecordsSource(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	user, _ := CreateUser(4201, "surfaces", "Surfaces")
+	market, _ := CreateMarket(user.ID, "Which surface wins?", time.Now().Add(time.Hour))
+
+	if err := PlaceBetFrom(ctx, user.ID, market.ID, "YES", 10, "carrier_pigeon"); err == nil {
+		t.Error("Expected an unknown source to be rejected")
+	}
+	PlaceBetFrom(ctx, user.ID, market.ID, "YES", 10, BetSourceWebApp)
+	PlaceBetFrom(ctx, user.ID, market.ID, "NO", 20, BetSourceInlineButton)
+	PlaceBetFrom(ctx, user.ID, market.ID, "YES", 30, BetSourceInlineButton)
+	PlaceBet(ctx, user.ID, market.ID, "NO", 40)
+
+	bets, _ := GetUserBets(user.ID)
+	if len(bets) != 4 || bets[0].Source != BetSourceUnknown || bets[1].Source != BetSourceInlineButton || bets[3].Source != BetSourceWebApp {
+		t.Errorf("Expected each bet's source in the history, got %+v", bets)
+	}
+
+	stats, err := GetPlatformStats(1, time.Now())
+	if err != nil {
+		t.Fatalf("GetPlatformStats failed: %v", err)
+	}
+	want := map[BetSource]BetSourceActivity{
+		BetSourceWebApp:       {Bets: 1, BetVolume: 10},
+		BetSourceInlineButton: {Bets: 2, BetVolume: 50},
+		BetSourceUnknown:      {Bets: 1, BetVolume: 40},
+	}
+	if len(stats.BetsBySource) != len(want) {
+		t.Fatalf("Expected %d sources, got %+v", len(want), stats.BetsBySource)
+	}
+	for source, activity := range want {
+		if stats.BetsBySource[source] != activity {
+			t.Errorf("%s: expected %+v, got %+v", source, activity, stats.BetsBySource[source])
+		}
+	}
+}
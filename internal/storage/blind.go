@@ -0,0 +1,9 @@
+package storage
+
+// poolsHiddenSQL is true for blind markets (alias m) that have not locked yet
+const poolsHiddenSQL = `(m.blind = 1 AND m.status IN ('ACTIVE', 'LAST_CALL'))`
+
+// PoolsHidden reports whether the market's pools are masked: blind markets reveal them once locked
+func (m *Market) PoolsHidden() bool {
+	return m.Blind && (m.Status == MarketStatusActive || m.Status == MarketStatusLastCall)
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBlindMarketPools(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(4001, "creator", "Creator")
+	bettor, _ := CreateUser(4002, "bettor", "Bettor")
+
+	market, err := CreateMarketWithOptions(creator.ID, "Will the blind market stay hidden?", time.Now().Add(time.Hour), MarketOptions{Blind: true})
+	if err != nil {
+		t.Fatalf("CreateMarketWithOptions failed: %v", err)
+	}
+	if !market.Blind || !market.PoolsHidden() {
+		t.Fatalf("Expected a blind market with hidden pools, got %+v", market)
+	}
+	if err := PlaceBet(ctx, bettor.ID, market.ID, "YES", 100); err != nil {
+		t.Fatalf("PlaceBet failed: %v", err)
+	}
+
+	if pools, _ := GetPublicPools(market.ID); !pools.PoolsHidden || pools.Yes != 0 || pools.Total != 0 {
+		t.Errorf("Expected masked pools, got %+v", pools)
+	}
+	if poolYes, _, _ := GetPoolTotals(market.ID); poolYes != 100 {
+		t.Errorf("Expected real pool 100, got %d", poolYes)
+	}
+	markets, _ := ListActiveMarketsWithCreator()
+	if len(markets) != 1 || !markets[0].PoolsHidden || markets[0].PoolYes != 0 {
+		t.Errorf("Expected masked market in list, got %+v", markets)
+	}
+	bets, _ := GetUserActiveBets(bettor.ID)
+	if len(bets) != 1 || !bets[0].PoolsHidden || bets[0].PoolYes != 0 {
+		t.Errorf("Expected masked active bet, got %+v", bets)
+	}
+	created, _ := GetMarketsByCreator(creator.ID)
+	if len(created) != 1 || !created[0].PoolsHidden || created[0].PoolYes != 0 {
+		t.Errorf("Expected masked creator market, got %+v", created)
+	}
+
+	// Everything is revealed once the market locks
+	if err := UpdateMarketStatus(market.ID, MarketStatusLocked, ""); err != nil {
+		t.Fatalf("UpdateMarketStatus failed: %v", err)
+	}
+	if pools, _ := GetPublicPools(market.ID); pools.PoolsHidden || pools.Yes != 100 {
+		t.Errorf("Expected revealed pool 100 after lock, got %+v", pools)
+	}
+	created, _ = GetMarketsByCreator(creator.ID)
+	if len(created) != 1 || created[0].PoolsHidden || created[0].PoolYes != 100 {
+		t.Errorf("Expected revealed creator market, got %+v", created)
+	}
+}
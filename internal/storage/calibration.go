@@ -0,0 +1,75 @@
+package storage
+
+import "fmt"
+
+// CalibrationBucket is one decile of implied YES probability
+type CalibrationBucket struct {
+	// Lower and Upper bound the implied probability, Upper is exclusive except for the last bucket
+	Lower       float64 `json:"lower"`
+	Upper       float64 `json:"upper"`
+	Markets     int     `json:"markets"`
+	ResolvedYes int     `json:"resolved_yes"`
+	// MeanImplied and YesRate are nil when the bucket has no markets
+	MeanImplied *float64 `json:"mean_implied"`
+	YesRate     *float64 `json:"yes_rate"`
+}
+
+// GetCalibration groups finalized markets by the YES share of their pool at lock (bets can
+// only be placed before lock, so that is the final pool) and counts how many resolved YES.
+// Markets without bets and hidden markets are left out.
+func GetCalibration() ([]CalibrationBucket, error) {
+	rows, err := db.Query(`
+		SELECT m.outcome,
+		       SUM(CASE WHEN b.outcome = 'YES' THEN b.amount ELSE 0 END),
+		       SUM(b.amount)
+		FROM markets m
+		JOIN bets b ON b.market_id = m.id
+		WHERE m.status = 'FINALIZED' AND m.hidden = 0 AND m.outcome IN ('YES', 'NO')
+		GROUP BY m.id
+		HAVING SUM(b.amount) > 0
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query calibration: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := make([]CalibrationBucket, 10)
+	sums := make([]float64, 10)
+	for i := range buckets {
+		buckets[i].Lower = float64(i) / 10
+		buckets[i].Upper = float64(i+1) / 10
+	}
+
+	for rows.Next() {
+		var outcome string
+		var poolYes, total int64
+		if err := rows.Scan(&outcome, &poolYes, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan calibration: %w", err)
+		}
+		implied := float64(poolYes) / float64(total)
+		i := int(implied * 10)
+		if i > 9 {
+			i = 9
+		}
+		buckets[i].Markets++
+		sums[i] += implied
+		if outcome == "YES" {
+			buckets[i].ResolvedYes++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating calibration: %w", err)
+	}
+
+	for i := range buckets {
+		if buckets[i].Markets == 0 {
+			continue
+		}
+		mean := sums[i] / float64(buckets[i].Markets)
+		rate := float64(buckets[i].ResolvedYes) / float64(buckets[i].Markets)
+		buckets[i].MeanImplied = &mean
+		buckets[i].YesRate = &rate
+	}
+
+	return buckets, nil
+}
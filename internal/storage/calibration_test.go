@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetCalibration(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(4001, "creator", "Creator")
+	ann, _ := CreateUser(4002, "ann", "Ann")
+	bob, _ := CreateUser(4003, "bob", "Bob")
+
+	// finalized creates a market with the given pools and settles it
+	finalized := func(question string, yes, no int64, outcome string) {
+		market, _ := CreateMarket(creator.ID, question, time.Now().Add(time.Hour))
+		if yes > 0 {
+			PlaceBet(ctx, ann.ID, market.ID, "YES", yes)
+		}
+		if no > 0 {
+			PlaceBet(ctx, bob.ID, market.ID, "NO", no)
+		}
+		UpdateMarketStatus(market.ID, MarketStatusLocked, "")
+		UpdateMarketStatus(market.ID, MarketStatusResolved, outcome)
+		UpdateMarketStatus(market.ID, MarketStatusFinalized, outcome)
+	}
+
+	finalized("Favourite one?", 80, 20, "YES")
+	finalized("Favourite two?", 85, 15, "NO")
+	finalized("Underdog?", 5, 95, "NO")
+	finalized("Sure thing?", 100, 0, "YES")
+	finalized("Nobody cared?", 0, 0, "YES")
+
+	// Still active: ignored
+	active, _ := CreateMarket(creator.ID, "Not settled yet?", time.Now().Add(time.Hour))
+	PlaceBet(ctx, ann.ID, active.ID, "YES", 10)
+
+	buckets, err := GetCalibration()
+	if err != nil {
+		t.Fatalf("GetCalibration failed: %v", err)
+	}
+	if len(buckets) != 10 {
+		t.Fatalf("Expected 10 buckets, got %d", len(buckets))
+	}
+
+	if b := buckets[8]; b.Markets != 2 || b.ResolvedYes != 1 || b.YesRate == nil || *b.YesRate != 0.5 {
+		t.Errorf("Expected 2 markets with half resolving YES in the 0.8 bucket, got %+v", b)
+	}
+	if b := buckets[8]; b.MeanImplied == nil || *b.MeanImplied < 0.824 || *b.MeanImplied > 0.826 {
+		t.Errorf("Expected mean implied 0.825, got %+v", b.MeanImplied)
+	}
+	if b := buckets[0]; b.Markets != 1 || b.ResolvedYes != 0 {
+		t.Errorf("Expected the underdog in the first bucket, got %+v", b)
+	}
+	// A 100% pool lands in the last bucket instead of an eleventh one
+	if b := buckets[9]; b.Markets != 1 || b.ResolvedYes != 1 {
+		t.Errorf("Expected the sure thing in the last bucket, got %+v", b)
+	}
+	if b := buckets[5]; b.Markets != 0 || b.YesRate != nil || b.MeanImplied != nil {
+		t.Errorf("Expected an empty bucket, got %+v", b)
+	}
+}
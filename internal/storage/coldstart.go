@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// ClaimColdStartMarkets marks and returns the open, visible markets created at or before
+// createdBefore that nobody has bet on and whose creator was not nudged yet. Each market is
+// claimed once, so the nudge goes out once even if sending it fails.
+func ClaimColdStartMarkets(createdBefore time.Time, now time.Time) ([]*Market, error) {
+	rows, err := db.Query(`
+		SELECT m.id FROM markets m
+		WHERE m.status = 'ACTIVE' AND m.hidden = 0 AND m.cold_start_nudged_at IS NULL AND m.created_at <= ?
+		  AND NOT EXISTS (SELECT 1 FROM bets b WHERE b.market_id = m.id)
+		ORDER BY m.id
+	`, sqlTimestamp(createdBefore))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cold start markets: %w", err)
+	}
+	var due []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan market: %w", err)
+		}
+		due = append(due, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("error iterating markets: %w", err)
+	}
+	rows.Close()
+
+	var claimed []*Market
+	for _, id := range due {
+		result, err := db.Exec(`UPDATE markets SET cold_start_nudged_at = ? WHERE id = ? AND cold_start_nudged_at IS NULL`,
+			sqlTimestamp(now), id)
+		if err != nil {
+			return claimed, fmt.Errorf("failed to claim cold start market: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+		market, err := GetMarketByID(id)
+		if err != nil {
+			return claimed, err
+		}
+		if market != nil {
+			claimed = append(claimed, market)
+		}
+	}
+	return claimed, nil
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClaimColdStartMarkets(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator, _ := CreateUser(5201, "creator", "Creator")
+	bettor, _ := CreateUser(5202, "bettor", "Bettor")
+	quiet, _ := CreateMarket(creator.ID, "Will anyone bet on this?", time.Now().Add(24*time.Hour))
+	busy, _ := CreateMarket(creator.ID, "Will this one get bets?", time.Now().Add(24*time.Hour))
+	fresh, _ := CreateMarket(creator.ID, "Is this market brand new?", time.Now().Add(24*time.Hour))
+	PlaceBet(context.Background(), bettor.ID, busy.ID, "YES", 10)
+
+	old := time.Now().UTC().Add(-7 * time.Hour).Format("2006-01-02 15:04:05")
+	db.Exec(`UPDATE markets SET created_at = ? WHERE id IN (?, ?)`, old, quiet.ID, busy.ID)
+
+	now := time.Now()
+	claimed, err := ClaimColdStartMarkets(now.Add(-6*time.Hour), now)
+	if err != nil {
+		t.Fatalf("ClaimColdStartMarkets failed: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != quiet.ID {
+		t.Fatalf("Expected only market %d, got %+v", quiet.ID, claimed)
+	}
+	if again, _ := ClaimColdStartMarkets(now.Add(-6*time.Hour), now); len(again) != 0 {
+		t.Errorf("Expected each market to be claimed once, got %+v", again)
+	}
+	if later, _ := ClaimColdStartMarkets(now.Add(time.Minute), now); len(later) != 1 || later[0].ID != fresh.ID {
+		t.Errorf("Expected market %d once it is old enough, got %+v", fresh.ID, later)
+	}
+}
@@ -0,0 +1,388 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ProposalStatus represents the status of a community-proposed outcome
+type ProposalStatus string
+
+const (
+	// ProposalStatusOpen means bettors are still voting on the proposed outcome
+	ProposalStatusOpen      ProposalStatus = "OPEN"
+	ProposalStatusAccepted  ProposalStatus = "ACCEPTED"
+	ProposalStatusEscalated ProposalStatus = "ESCALATED"
+	// ProposalStatusCancelled means the market was resolved some other way during the vote
+	ProposalStatusCancelled ProposalStatus = "CANCELLED"
+)
+
+// CommunityMinConfirmers is how many bettors, the proposer included, must confirm an outcome
+// for it to be accepted, so one bettor cannot resolve a market alone
+const CommunityMinConfirmers = 2
+
+// CommunityProposal is an outcome proposed by a bettor for a market its creator left unresolved.
+// Bettors confirm or reject it with votes weighted by their stake in the market.
+type CommunityProposal struct {
+	ID           int64          `json:"id"`
+	MarketID     int64          `json:"market_id"`
+	ProposedBy   int64          `json:"proposed_by"`
+	Outcome      string         `json:"outcome"`
+	Status       ProposalStatus `json:"status"`
+	ConfirmStake int64          `json:"confirm_stake"`
+	RejectStake  int64          `json:"reject_stake"`
+	Confirmers   int            `json:"confirmers"`
+	ClosesAt     time.Time      `json:"closes_at"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+const proposalColumns = `p.id, p.market_id, p.proposed_by, p.outcome, p.status,
+	COALESCE((SELECT SUM(stake) FROM community_votes WHERE proposal_id = p.id AND confirm = 1), 0),
+	COALESCE((SELECT SUM(stake) FROM community_votes WHERE proposal_id = p.id AND confirm = 0), 0),
+	(SELECT COUNT(*) FROM community_votes WHERE proposal_id = p.id AND confirm = 1),
+	p.closes_at, p.created_at`
+
+func scanProposal(row interface{ Scan(...interface{}) error }) (*CommunityProposal, error) {
+	var proposal CommunityProposal
+	err := row.Scan(
+		&proposal.ID,
+		&proposal.MarketID,
+		&proposal.ProposedBy,
+		&proposal.Outcome,
+		&proposal.Status,
+		&proposal.ConfirmStake,
+		&proposal.RejectStake,
+		&proposal.Confirmers,
+		&proposal.ClosesAt,
+		&proposal.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// userStakeTx returns how much a user bet on a market in total
+func userStakeTx(ctx context.Context, tx *sql.Tx, marketID, userID int64) (int64, error) {
+	var stake int64
+	err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount), 0) FROM bets WHERE market_id = ? AND user_id = ?
+	`, marketID, userID).Scan(&stake)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get stake: %w", err)
+	}
+	return stake, nil
+}
+
+// GetMarketLockedAt returns when a market locked. Markets locked before locked_at was
+// recorded fall back to their deadline.
+func GetMarketLockedAt(marketID int64) (time.Time, error) {
+	var lockedAt sql.NullTime
+	var expiresAt time.Time
+	err := db.QueryRow(`SELECT locked_at, expires_at FROM markets WHERE id = ?`, marketID).Scan(&lockedAt, &expiresAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, fmt.Errorf("market not found")
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get lock time: %w", err)
+	}
+	if lockedAt.Valid {
+		return lockedAt.Time, nil
+	}
+	return expiresAt, nil
+}
+
+// CreateCommunityProposal lets a bettor (internal ID) propose the outcome of a market whose creator
+// has not resolved it within absence of it locking. The proposer's stake counts as the first
+// confirmation; the vote runs for window.
+func CreateCommunityProposal(ctx context.Context, marketID, proposedBy int64, outcome string, absence, window time.Duration) (*CommunityProposal, error) {
+	lockedAt, err := GetMarketLockedAt(marketID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	var creatorID int64
+	err = tx.QueryRowContext(ctx, `SELECT status, creator_id FROM markets WHERE id = ?`, marketID).Scan(&status, &creatorID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("market not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+	if status != string(MarketStatusLocked) {
+		return nil, fmt.Errorf("outcome cannot be proposed: status is %s", status)
+	}
+	if creatorID == proposedBy {
+		return nil, fmt.Errorf("outcome cannot be proposed: the creator can resolve this market directly")
+	}
+	if opensAt := lockedAt.Add(absence); time.Now().Before(opensAt) {
+		return nil, fmt.Errorf("outcome cannot be proposed yet: the creator has until %s", opensAt.UTC().Format("2006-01-02 15:04 UTC"))
+	}
+
+	var pending int
+	err = tx.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM community_proposals WHERE market_id = ? AND status = 'OPEN')
+		     + (SELECT COUNT(*) FROM resolution_cosigns WHERE market_id = ? AND status = 'PENDING')
+	`, marketID, marketID).Scan(&pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check pending resolutions: %w", err)
+	}
+	if pending > 0 {
+		return nil, fmt.Errorf("outcome cannot be proposed: a resolution is already pending")
+	}
+
+	stake, err := userStakeTx(ctx, tx, marketID, proposedBy)
+	if err != nil {
+		return nil, err
+	}
+	if stake == 0 {
+		return nil, fmt.Errorf("you must have placed a bet on this market to propose an outcome")
+	}
+
+	var proposalID int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO community_proposals (market_id, proposed_by, outcome, status, closes_at)
+		VALUES (?, ?, ?, 'OPEN', ?)
+		RETURNING id
+	`, marketID, proposedBy, outcome, sqlTimestamp(time.Now().Add(window))).Scan(&proposalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert proposal: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO community_votes (proposal_id, user_id, confirm, stake) VALUES (?, ?, 1, ?)
+	`, proposalID, proposedBy, stake)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record vote: %w", err)
+	}
+
+	details := fmt.Sprintf("proposal_id=%d outcome=%s", proposalID, outcome)
+	if err := logAuditTx(ctx, tx, proposedBy, "community_outcome_proposed", AuditEntityMarket, marketID, details); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetCommunityProposal(proposalID)
+}
+
+// GetCommunityProposal retrieves a proposal with its current tally
+func GetCommunityProposal(id int64) (*CommunityProposal, error) {
+	proposal, err := scanProposal(db.QueryRow(`SELECT `+proposalColumns+` FROM community_proposals p WHERE p.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proposal by id: %w", err)
+	}
+	return proposal, nil
+}
+
+// GetOpenCommunityProposal returns the proposal bettors are voting on for a market, or nil
+func GetOpenCommunityProposal(marketID int64) (*CommunityProposal, error) {
+	proposal, err := scanProposal(db.QueryRow(`
+		SELECT `+proposalColumns+` FROM community_proposals p
+		WHERE p.market_id = ? AND p.status = 'OPEN'
+	`, marketID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open proposal: %w", err)
+	}
+	return proposal, nil
+}
+
+// ListDueCommunityProposals returns the IDs of open proposals whose vote has ended
+func ListDueCommunityProposals() ([]int64, error) {
+	rows, err := db.Query(`
+		SELECT id FROM community_proposals
+		WHERE status = 'OPEN' AND closes_at <= CURRENT_TIMESTAMP
+		ORDER BY closes_at, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due proposals: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan proposal: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating proposals: %w", err)
+	}
+	return ids, nil
+}
+
+// ListProposalVoterTelegramIDs returns the Telegram IDs of everyone who bet on the market
+// of a proposal and has not voted on it yet, leaving out users who muted their DMs
+func ListProposalVoterTelegramIDs(proposalID int64) ([]int64, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT u.telegram_id
+		FROM community_proposals p
+		JOIN bets b ON b.market_id = p.market_id
+		JOIN users u ON u.id = b.user_id
+		WHERE p.id = ?
+		AND b.user_id NOT IN (SELECT user_id FROM community_votes WHERE proposal_id = p.id)
+		AND u.notify_muted = 0
+	`, proposalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query voters: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan voter: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating voters: %w", err)
+	}
+	return ids, nil
+}
+
+// VoteOnCommunityProposal records a bettor's (internal ID) vote, weighted by their stake in the
+// market. Voting again replaces the earlier vote.
+func VoteOnCommunityProposal(ctx context.Context, proposalID, userID int64, confirm bool) (*CommunityProposal, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var marketID int64
+	var status string
+	var open bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT market_id, status, closes_at > CURRENT_TIMESTAMP FROM community_proposals WHERE id = ?
+	`, proposalID).Scan(&marketID, &status, &open)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("proposal not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proposal: %w", err)
+	}
+	if status != string(ProposalStatusOpen) || !open {
+		return nil, fmt.Errorf("voting on this proposal is closed")
+	}
+
+	stake, err := userStakeTx(ctx, tx, marketID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if stake == 0 {
+		return nil, fmt.Errorf("you must have placed a bet on this market to vote")
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO community_votes (proposal_id, user_id, confirm, stake) VALUES (?, ?, ?, ?)
+		ON CONFLICT (proposal_id, user_id) DO UPDATE SET confirm = excluded.confirm, stake = excluded.stake
+	`, proposalID, userID, confirm, stake)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record vote: %w", err)
+	}
+
+	details := fmt.Sprintf("proposal_id=%d confirm=%t stake=%d", proposalID, confirm, stake)
+	if err := logAuditTx(ctx, tx, userID, "community_outcome_vote", AuditEntityMarket, marketID, details); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetCommunityProposal(proposalID)
+}
+
+// CloseCommunityProposal tallies a proposal once its vote has ended. The outcome is accepted when
+// the confirming stake outweighs the rejecting stake and at least CommunityMinConfirmers bettors
+// confirmed; the market is then resolved and its dispute window starts. Otherwise the proposal is
+// escalated: the market becomes DISPUTED with the proposed outcome so an admin decides it.
+// Proposals for markets that were resolved meanwhile are cancelled.
+func CloseCommunityProposal(ctx context.Context, proposalID int64) (*CommunityProposal, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	proposal, err := scanProposal(tx.QueryRowContext(ctx, `SELECT `+proposalColumns+` FROM community_proposals p WHERE p.id = ?`, proposalID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("proposal not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proposal: %w", err)
+	}
+	if proposal.Status != ProposalStatusOpen {
+		return nil, fmt.Errorf("proposal is no longer open: status is %s", proposal.Status)
+	}
+
+	var marketStatus string
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM markets WHERE id = ?`, proposal.MarketID).Scan(&marketStatus); err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+
+	to := ProposalStatusCancelled
+	if marketStatus == string(MarketStatusLocked) {
+		to = ProposalStatusEscalated
+		next := MarketStatusDisputed
+		if proposal.ConfirmStake > proposal.RejectStake && proposal.Confirmers >= CommunityMinConfirmers {
+			to = ProposalStatusAccepted
+			next = MarketStatusResolved
+		}
+		change := MarketStatusChange{
+			To:   next,
+			From: []MarketStatus{MarketStatusLocked},
+			Set:  "outcome = ?, resolved_at = CURRENT_TIMESTAMP",
+			Args: []interface{}{proposal.Outcome},
+		}
+		if next == MarketStatusDisputed {
+			change.Set += ", disputed_at = CURRENT_TIMESTAMP"
+		}
+		if _, err := UpdateMarketStatusTx(ctx, tx, proposal.MarketID, change); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE community_proposals SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?
+	`, to, proposalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update proposal: %w", err)
+	}
+
+	action := "community_outcome_" + strings.ToLower(string(to))
+	details := fmt.Sprintf("proposal_id=%d outcome=%s confirm_stake=%d reject_stake=%d confirmers=%d",
+		proposalID, proposal.Outcome, proposal.ConfirmStake, proposal.RejectStake, proposal.Confirmers)
+	if err := logAuditTx(ctx, tx, 0, action, AuditEntityMarket, proposal.MarketID, details); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	proposal.Status = to
+	return proposal, nil
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// abandonedMarket creates a market locked 73 hours ago with bets from the given users
+func abandonedMarket(t *testing.T, creatorID int64, stakes map[int64]int64) *Market {
+	t.Helper()
+	ctx := context.Background()
+	market, err := CreateMarket(creatorID, "Will the absent creator ever come back?", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("CreateMarket failed: %v", err)
+	}
+	for userID, amount := range stakes {
+		if err := PlaceBet(ctx, userID, market.ID, "YES", amount); err != nil {
+			t.Fatalf("PlaceBet failed: %v", err)
+		}
+	}
+	if _, err := db.Exec(`UPDATE markets SET status = 'LOCKED', locked_at = ? WHERE id = ?`, sqlTimestamp(time.Now().Add(-73*time.Hour)), market.ID); err != nil {
+		t.Fatalf("Failed to lock market: %v", err)
+	}
+	return market
+}
+
+// endVote moves a proposal's closing time into the past
+func endVote(t *testing.T, proposalID int64) {
+	t.Helper()
+	if _, err := db.Exec(`UPDATE community_proposals SET closes_at = ? WHERE id = ?`, sqlTimestamp(time.Now().Add(-time.Minute)), proposalID); err != nil {
+		t.Fatalf("Failed to end vote: %v", err)
+	}
+}
+
+func TestCreateCommunityProposal(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(6001, "creator", "Creator")
+	alice, _ := CreateUser(6002, "alice", "Alice")
+	outsider, _ := CreateUser(6003, "outsider", "Outsider")
+	market := abandonedMarket(t, creator.ID, map[int64]int64{alice.ID: 100})
+
+	if _, err := CreateCommunityProposal(ctx, market.ID, alice.ID, "YES", 100*time.Hour, time.Hour); err == nil {
+		t.Error("Expected an error before the creator's time is up")
+	}
+	if _, err := CreateCommunityProposal(ctx, market.ID, creator.ID, "YES", 72*time.Hour, time.Hour); err == nil {
+		t.Error("Expected the creator to be turned away")
+	}
+	if _, err := CreateCommunityProposal(ctx, market.ID, outsider.ID, "YES", 72*time.Hour, time.Hour); err == nil {
+		t.Error("Expected an error for a user without a bet")
+	}
+
+	proposal, err := CreateCommunityProposal(ctx, market.ID, alice.ID, "YES", 72*time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateCommunityProposal failed: %v", err)
+	}
+	if proposal.Status != ProposalStatusOpen || proposal.ConfirmStake != 100 || proposal.Confirmers != 1 {
+		t.Errorf("Expected an open proposal confirmed by its proposer, got %+v", proposal)
+	}
+	if !proposal.ClosesAt.After(time.Now()) {
+		t.Errorf("Expected the vote to close in the future, got %v", proposal.ClosesAt)
+	}
+	if _, err := CreateCommunityProposal(ctx, market.ID, alice.ID, "NO", 72*time.Hour, time.Hour); err == nil {
+		t.Error("Expected an error for a second open proposal")
+	}
+	if open, _ := GetOpenCommunityProposal(market.ID); open == nil || open.ID != proposal.ID {
+		t.Errorf("Expected proposal %d to be open, got %+v", proposal.ID, open)
+	}
+}
+
+func TestCloseCommunityProposalAccepted(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(6101, "creator", "Creator")
+	alice, _ := CreateUser(6102, "alice", "Alice")
+	bob, _ := CreateUser(6103, "bob", "Bob")
+	carol, _ := CreateUser(6104, "carol", "Carol")
+	market := abandonedMarket(t, creator.ID, map[int64]int64{alice.ID: 100, bob.ID: 50, carol.ID: 120})
+
+	proposal, _ := CreateCommunityProposal(ctx, market.ID, alice.ID, "YES", 72*time.Hour, time.Hour)
+	if _, err := VoteOnCommunityProposal(ctx, proposal.ID, carol.ID, false); err != nil {
+		t.Fatalf("VoteOnCommunityProposal failed: %v", err)
+	}
+	voted, err := VoteOnCommunityProposal(ctx, proposal.ID, bob.ID, true)
+	if err != nil {
+		t.Fatalf("VoteOnCommunityProposal failed: %v", err)
+	}
+	if voted.ConfirmStake != 150 || voted.RejectStake != 120 || voted.Confirmers != 2 {
+		t.Errorf("Unexpected tally: %+v", voted)
+	}
+
+	if due, _ := ListDueCommunityProposals(); len(due) != 0 {
+		t.Errorf("Expected no due proposals while voting, got %v", due)
+	}
+	endVote(t, proposal.ID)
+	if _, err := VoteOnCommunityProposal(ctx, proposal.ID, carol.ID, true); err == nil {
+		t.Error("Expected an error voting after the window")
+	}
+	due, _ := ListDueCommunityProposals()
+	if len(due) != 1 || due[0] != proposal.ID {
+		t.Fatalf("Expected proposal %d to be due, got %v", proposal.ID, due)
+	}
+
+	closed, err := CloseCommunityProposal(ctx, proposal.ID)
+	if err != nil {
+		t.Fatalf("CloseCommunityProposal failed: %v", err)
+	}
+	if closed.Status != ProposalStatusAccepted {
+		t.Errorf("Expected ACCEPTED, got %s", closed.Status)
+	}
+	if m, _ := GetMarketByID(market.ID); m.Status != MarketStatusResolved || m.Outcome != "YES" {
+		t.Errorf("Expected RESOLVED YES, got %s %s", m.Status, m.Outcome)
+	}
+}
+
+func TestCloseCommunityProposalEscalated(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(6201, "creator", "Creator")
+	alice, _ := CreateUser(6202, "alice", "Alice")
+	market := abandonedMarket(t, creator.ID, map[int64]int64{alice.ID: 500})
+
+	// A lone proposer cannot resolve the market, however big their stake
+	proposal, _ := CreateCommunityProposal(ctx, market.ID, alice.ID, "NO", 72*time.Hour, time.Hour)
+	endVote(t, proposal.ID)
+
+	closed, err := CloseCommunityProposal(ctx, proposal.ID)
+	if err != nil {
+		t.Fatalf("CloseCommunityProposal failed: %v", err)
+	}
+	if closed.Status != ProposalStatusEscalated {
+		t.Errorf("Expected ESCALATED, got %s", closed.Status)
+	}
+	if m, _ := GetMarketByID(market.ID); m.Status != MarketStatusDisputed || m.Outcome != "NO" {
+		t.Errorf("Expected DISPUTED with the proposed outcome, got %s %s", m.Status, m.Outcome)
+	}
+	if _, err := CloseCommunityProposal(ctx, proposal.ID); err == nil {
+		t.Error("Expected an error closing a proposal twice")
+	}
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ContentPage is an admin-editable text such as the house rules
+type ContentPage struct {
+	Key       string    `json:"key"`
+	Body      string    `json:"body"`
+	UpdatedBy int64     `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GetContent returns the stored page for key, or nil if it was never edited
+func GetContent(key string) (*ContentPage, error) {
+	page := ContentPage{Key: key}
+	err := db.QueryRow(`SELECT body, updated_by, updated_at FROM content WHERE key = ?`, key).Scan(&page.Body, &page.UpdatedBy, &page.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get content: %w", err)
+	}
+	return &page, nil
+}
+
+// SetContent replaces the page for key and records who changed it (internal ID) in the audit log
+func SetContent(ctx context.Context, key, body string, actorID int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO content (key, body, updated_by)
+		VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			body = excluded.body,
+			updated_by = excluded.updated_by,
+			updated_at = CURRENT_TIMESTAMP
+	`, key, body, actorID)
+	if err != nil {
+		return fmt.Errorf("failed to save content: %w", err)
+	}
+
+	if err := logAuditTx(ctx, tx, actorID, "content_update", "content", 0, "key="+key); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
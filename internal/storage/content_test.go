@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContent(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin, _ := CreateUser(4001, "admin", "Admin")
+	if page, err := GetContent("rules"); err != nil || page != nil {
+		t.Fatalf("Expected no stored rules, got %+v (err=%v)", page, err)
+	}
+
+	ctx := context.Background()
+	if err := SetContent(ctx, "rules", "Be nice.", admin.ID); err != nil {
+		t.Fatalf("SetContent failed: %v", err)
+	}
+	if err := SetContent(ctx, "rules", "Be nicer.", admin.ID); err != nil {
+		t.Fatalf("SetContent (update) failed: %v", err)
+	}
+
+	page, err := GetContent("rules")
+	if err != nil || page == nil {
+		t.Fatalf("GetContent failed: %v", err)
+	}
+	if page.Body != "Be nicer." || page.UpdatedBy != admin.ID || page.UpdatedAt.IsZero() {
+		t.Errorf("Unexpected page %+v", page)
+	}
+
+	entries, _ := GetAuditLog("content", 0)
+	if len(entries) != 2 || entries[0].Action != "content_update" || entries[0].Details != "key=rules" {
+		t.Errorf("Expected 2 audit entries, got %+v", entries)
+	}
+}
@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"predictionbot/internal/statemachine"
+)
+
+// CosignStatus represents the status of a resolution awaiting a second signer
+type CosignStatus string
+
+const (
+	// CosignStatusPending means the creator resolved the market and a co-signer must confirm
+	CosignStatusPending   CosignStatus = "PENDING"
+	CosignStatusConfirmed CosignStatus = "CONFIRMED"
+	CosignStatusRejected  CosignStatus = "REJECTED"
+)
+
+// ResolutionCosign is a creator's resolution of a big market that needs a second signer.
+// The market stays LOCKED until it is confirmed, so the dispute window only starts then.
+type ResolutionCosign struct {
+	ID         int64        `json:"id"`
+	MarketID   int64        `json:"market_id"`
+	ProposedBy int64        `json:"proposed_by"`
+	Outcome    string       `json:"outcome"`
+	Status     CosignStatus `json:"status"`
+	CosignerID int64        `json:"cosigner_id,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+const cosignColumns = `id, market_id, proposed_by, outcome, status, COALESCE(cosigner_id, 0), created_at, updated_at`
+
+func scanCosign(row interface{ Scan(...interface{}) error }) (*ResolutionCosign, error) {
+	var cosign ResolutionCosign
+	err := row.Scan(
+		&cosign.ID,
+		&cosign.MarketID,
+		&cosign.ProposedBy,
+		&cosign.Outcome,
+		&cosign.Status,
+		&cosign.CosignerID,
+		&cosign.CreatedAt,
+		&cosign.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &cosign, nil
+}
+
+// CreateResolutionCosign records the creator's outcome for a locked market until a co-signer confirms it.
+// proposedBy is the internal ID of the creator.
+func CreateResolutionCosign(ctx context.Context, marketID, proposedBy int64, outcome string) (*ResolutionCosign, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM markets WHERE id = ?`, marketID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("market not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+	if status != string(MarketStatusLocked) {
+		return nil, fmt.Errorf("market cannot be resolved: status is %s", status)
+	}
+
+	var pending int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM resolution_cosigns
+		WHERE market_id = ? AND status = 'PENDING'
+	`, marketID).Scan(&pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check pending co-signatures: %w", err)
+	}
+	if pending > 0 {
+		return nil, fmt.Errorf("market cannot be resolved: a resolution is already waiting for a co-signer")
+	}
+
+	var cosignID int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO resolution_cosigns (market_id, proposed_by, outcome, status)
+		VALUES (?, ?, ?, 'PENDING')
+		RETURNING id
+	`, marketID, proposedBy, outcome).Scan(&cosignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert co-signature request: %w", err)
+	}
+
+	details := fmt.Sprintf("cosign_id=%d outcome=%s", cosignID, outcome)
+	if err := logAuditTx(ctx, tx, proposedBy, "resolution_cosign_requested", AuditEntityMarket, marketID, details); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetResolutionCosign(cosignID)
+}
+
+// GetResolutionCosign retrieves a co-signature request by its ID
+func GetResolutionCosign(id int64) (*ResolutionCosign, error) {
+	cosign, err := scanCosign(db.QueryRow(`SELECT `+cosignColumns+` FROM resolution_cosigns WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get co-signature by id: %w", err)
+	}
+	return cosign, nil
+}
+
+// GetPendingResolutionCosign returns the resolution of a market waiting for a co-signer, or nil
+func GetPendingResolutionCosign(marketID int64) (*ResolutionCosign, error) {
+	cosign, err := scanCosign(db.QueryRow(`
+		SELECT `+cosignColumns+` FROM resolution_cosigns
+		WHERE market_id = ? AND status = 'PENDING'
+	`, marketID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending co-signature: %w", err)
+	}
+	return cosign, nil
+}
+
+// ListPendingResolutionCosigns returns every resolution waiting for a co-signer, oldest first
+func ListPendingResolutionCosigns() ([]ResolutionCosign, error) {
+	rows, err := db.Query(`
+		SELECT ` + cosignColumns + ` FROM resolution_cosigns
+		WHERE status = 'PENDING'
+		ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending co-signatures: %w", err)
+	}
+	defer rows.Close()
+
+	cosigns := []ResolutionCosign{}
+	for rows.Next() {
+		cosign, err := scanCosign(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan co-signature: %w", err)
+		}
+		cosigns = append(cosigns, *cosign)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating co-signatures: %w", err)
+	}
+	return cosigns, nil
+}
+
+// DecideResolutionCosign confirms or rejects a pending resolution (internal cosigner ID).
+// Confirming resolves the market with the proposed outcome, which starts its dispute window;
+// rejecting leaves it LOCKED so the creator can resolve it again.
+func DecideResolutionCosign(ctx context.Context, cosignID, cosignerID int64, confirm bool) (*ResolutionCosign, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	cosign, err := scanCosign(tx.QueryRowContext(ctx, `SELECT `+cosignColumns+` FROM resolution_cosigns WHERE id = ?`, cosignID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("co-signature request not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get co-signature: %w", err)
+	}
+	if cosign.Status != CosignStatusPending {
+		return nil, fmt.Errorf("co-signature request is no longer pending: status is %s", cosign.Status)
+	}
+	if cosign.ProposedBy == cosignerID {
+		return nil, fmt.Errorf("cannot co-sign your own resolution")
+	}
+
+	to := CosignStatusRejected
+	if confirm {
+		to = CosignStatusConfirmed
+		change := MarketStatusChange{
+			To:   MarketStatusResolved,
+			From: []MarketStatus{MarketStatusLocked},
+			Set:  "outcome = ?, resolved_at = CURRENT_TIMESTAMP",
+			Args: []interface{}{cosign.Outcome},
+		}
+		_, err := UpdateMarketStatusTx(ctx, tx, cosign.MarketID, change)
+		var transition *statemachine.TransitionError
+		if errors.As(err, &transition) {
+			return nil, fmt.Errorf("market cannot be resolved: it is no longer locked")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE resolution_cosigns
+		SET status = ?, cosigner_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, to, cosignerID, cosignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update co-signature: %w", err)
+	}
+
+	action := "resolution_cosign_" + strings.ToLower(string(to))
+	details := fmt.Sprintf("cosign_id=%d outcome=%s", cosignID, cosign.Outcome)
+	if err := logAuditTx(ctx, tx, cosignerID, action, AuditEntityMarket, cosign.MarketID, details); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	cosign.Status = to
+	cosign.CosignerID = cosignerID
+	return cosign, nil
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestResolutionCosignConfirm(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(5001, "creator", "Creator")
+	admin, _ := CreateUser(5002, "admin", "Admin")
+	market, _ := CreateMarket(creator.ID, "Will the big market need two signatures?", time.Now().Add(time.Hour))
+
+	if _, err := CreateResolutionCosign(ctx, market.ID, creator.ID, "YES"); err == nil {
+		t.Fatal("Expected an error for a market that is not locked")
+	}
+	UpdateMarketStatus(market.ID, MarketStatusLocked, "")
+
+	cosign, err := CreateResolutionCosign(ctx, market.ID, creator.ID, "YES")
+	if err != nil {
+		t.Fatalf("CreateResolutionCosign failed: %v", err)
+	}
+	if cosign.Status != CosignStatusPending || cosign.Outcome != "YES" {
+		t.Errorf("Unexpected co-signature request: %+v", cosign)
+	}
+	if _, err := CreateResolutionCosign(ctx, market.ID, creator.ID, "NO"); err == nil {
+		t.Error("Expected an error for a second pending request")
+	}
+	if pending, _ := ListPendingResolutionCosigns(); len(pending) != 1 || pending[0].ID != cosign.ID {
+		t.Errorf("Expected one pending request, got %+v", pending)
+	}
+
+	// The market stays locked until a second person signs
+	if m, _ := GetMarketByID(market.ID); m.Status != MarketStatusLocked {
+		t.Errorf("Expected LOCKED while pending, got %s", m.Status)
+	}
+	if _, err := DecideResolutionCosign(ctx, cosign.ID, creator.ID, true); err == nil {
+		t.Error("Expected the creator to be unable to co-sign their own resolution")
+	}
+
+	decided, err := DecideResolutionCosign(ctx, cosign.ID, admin.ID, true)
+	if err != nil {
+		t.Fatalf("DecideResolutionCosign failed: %v", err)
+	}
+	if decided.Status != CosignStatusConfirmed || decided.CosignerID != admin.ID {
+		t.Errorf("Unexpected decision: %+v", decided)
+	}
+	if m, _ := GetMarketByID(market.ID); m.Status != MarketStatusResolved || m.Outcome != "YES" {
+		t.Errorf("Expected RESOLVED YES, got %s %s", m.Status, m.Outcome)
+	}
+	if _, err := DecideResolutionCosign(ctx, cosign.ID, admin.ID, true); err == nil {
+		t.Error("Expected an error deciding a request twice")
+	}
+}
+
+func TestResolutionCosignReject(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(5101, "creator", "Creator")
+	oracle, _ := CreateUser(5102, "oracle", "Oracle")
+	market, _ := CreateMarket(creator.ID, "Will the co-signer reject this outcome?", time.Now().Add(time.Hour))
+	UpdateMarketStatus(market.ID, MarketStatusLocked, "")
+
+	cosign, _ := CreateResolutionCosign(ctx, market.ID, creator.ID, "NO")
+	if _, err := DecideResolutionCosign(ctx, cosign.ID, oracle.ID, false); err != nil {
+		t.Fatalf("DecideResolutionCosign failed: %v", err)
+	}
+	if m, _ := GetMarketByID(market.ID); m.Status != MarketStatusLocked {
+		t.Errorf("Expected the market to stay LOCKED, got %s", m.Status)
+	}
+	if pending, _ := GetPendingResolutionCosign(market.ID); pending != nil {
+		t.Errorf("Expected no pending request, got %+v", pending)
+	}
+
+	// The creator can resolve again after a rejection
+	if _, err := CreateResolutionCosign(ctx, market.ID, creator.ID, "YES"); err != nil {
+		t.Errorf("Expected a new request after rejection, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// SetMarketDescription stores the generated description of a market
+func SetMarketDescription(marketID int64, description string) error {
+	if _, err := db.Exec(`UPDATE markets SET description = ? WHERE id = ?`, description, marketID); err != nil {
+		return fmt.Errorf("failed to save market description: %w", err)
+	}
+	return nil
+}
+
+// GetMarketDescription returns the description of a market, or "" if it has none
+func GetMarketDescription(marketID int64) (string, error) {
+	var description sql.NullString
+	err := db.QueryRow(`SELECT description FROM markets WHERE id = ?`, marketID).Scan(&description)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get market description: %w", err)
+	}
+	return description.String, nil
+}
+
+// SetMarketResolutionCriteria stores the creator's resolution criteria for a market
+func SetMarketResolutionCriteria(marketID int64, criteria string) error {
+	if _, err := db.Exec(`UPDATE markets SET resolution_criteria = ? WHERE id = ?`, criteria, marketID); err != nil {
+		return fmt.Errorf("failed to save resolution criteria: %w", err)
+	}
+	return nil
+}
+
+// GetMarketResolutionCriteria returns the resolution criteria of a market, or "" if it has none
+func GetMarketResolutionCriteria(marketID int64) (string, error) {
+	var criteria sql.NullString
+	err := db.QueryRow(`SELECT resolution_criteria FROM markets WHERE id = ?`, marketID).Scan(&criteria)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get resolution criteria: %w", err)
+	}
+	return criteria.String, nil
+}
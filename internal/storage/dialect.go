@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// The bot stores its data in SQLite by default, or in PostgreSQL when DATABASE_URL names a
+// postgres:// server. Queries are written once, with ? placeholders and SQL both engines
+// accept; the connection wrapper in slowquery.go rebinds the placeholders for PostgreSQL. The
+// few statements that cannot be shared check usingPostgres.
+
+// usingPostgres is true while the open database is PostgreSQL
+var usingPostgres bool
+
+// UsingPostgres reports whether the open database is PostgreSQL rather than SQLite
+func UsingPostgres() bool {
+	return usingPostgres
+}
+
+// IsPostgresURL reports whether dsn names a PostgreSQL server rather than a SQLite file
+func IsPostgresURL(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// postgresDSN pins the session time zone to UTC, which is how SQLite keeps CURRENT_TIMESTAMP,
+// and sends parameters inline as quoted literals, unless the URL chooses otherwise. The queries
+// lean on SQLite's loose typing, binding the same Go value where PostgreSQL wants a number in
+// one place and text or a timestamp in another; a quoted literal takes whichever type its
+// context needs, where a bind parameter keeps the type the server guessed for it.
+func postgresDSN(dsn string) (string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	if query.Get("timezone") == "" {
+		query.Set("timezone", "UTC")
+	}
+	if query.Get("default_query_exec_mode") == "" {
+		query.Set("default_query_exec_mode", "simple_protocol")
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// sqlTimestamp formats t as a UTC timestamp both engines compare against stored ones, so it
+// stands in for datetime('now', ...) arithmetic. SQLite keeps whole seconds, the way datetime()
+// prints them; PostgreSQL's CURRENT_TIMESTAMP has microseconds, which are kept so a row written
+// this second is not later than now.
+func sqlTimestamp(t time.Time) string {
+	if usingPostgres {
+		return t.UTC().Format("2006-01-02 15:04:05.999999")
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// sqlDate is the SQL for the UTC day (YYYY-MM-DD) of a timestamp expression
+func sqlDate(expr string) string {
+	if usingPostgres {
+		return "to_char(" + expr + ", 'YYYY-MM-DD')"
+	}
+	return "date(" + expr + ")"
+}
+
+// sqlUnixSeconds is the SQL for a timestamp expression in whole seconds since the Unix epoch
+func sqlUnixSeconds(expr string) string {
+	if usingPostgres {
+		return "CAST(EXTRACT(EPOCH FROM " + expr + ") AS BIGINT)"
+	}
+	return "CAST(strftime('%s', " + expr + ") AS INTEGER)"
+}
+
+// sqlHoursSince is the SQL for the hours from a timestamp expression to the time given as the
+// statement's next parameter
+func sqlHoursSince(expr string) string {
+	if usingPostgres {
+		return "(EXTRACT(EPOCH FROM CAST(? AS TIMESTAMPTZ) - " + expr + ") / 3600)"
+	}
+	return "((julianday(?) - julianday(" + expr + ")) * 24)"
+}
+
+// postgresErrorCode returns the SQLSTATE of a PostgreSQL error, "" for any other error
+func postgresErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// isUniqueViolation reports whether err is a UNIQUE constraint failing on either engine
+func isUniqueViolation(err error) bool {
+	return postgresErrorCode(err) == "23505" || strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// reboundQueries caches rebind's result per query text; the set of queries is fixed
+var reboundQueries sync.Map
+
+// rebind rewrites the ? placeholders of query as PostgreSQL's $1, $2, ... Question marks in
+// string literals, quoted identifiers, comments and dollar-quoted bodies are left alone.
+func rebind(query string) string {
+	if cached, ok := reboundQueries.Load(query); ok {
+		return cached.(string)
+	}
+
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(query); {
+		// skip copies a quoted or commented span that ends with closing, or the rest of the query
+		skip := func(open, closing string) {
+			end := strings.Index(query[i+len(open):], closing)
+			if end < 0 {
+				end = len(query)
+			} else {
+				end += i + len(open) + len(closing)
+			}
+			b.WriteString(query[i:end])
+			i = end
+		}
+		switch rest := query[i:]; {
+		case rest[0] == '\'':
+			skip("'", "'")
+		case rest[0] == '"':
+			skip(`"`, `"`)
+		case strings.HasPrefix(rest, "--"):
+			skip("--", "\n")
+		case strings.HasPrefix(rest, "/*"):
+			skip("/*", "*/")
+		case strings.HasPrefix(rest, "$$"):
+			skip("$$", "$$")
+		case rest[0] == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			i++
+		default:
+			b.WriteByte(rest[0])
+			i++
+		}
+	}
+
+	rebound := b.String()
+	reboundQueries.Store(query, rebound)
+	return rebound
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// DigestOff is the digest hour of users who have not asked for the daily digest
+const DigestOff = -1
+
+// DigestSubscriber is a user who asked for the daily digest at Hour in their Timezone
+type DigestSubscriber struct {
+	UserID   int64
+	Hour     int
+	Timezone string
+	// SentOn is the local date (YYYY-MM-DD) the scheduled digest was last sent, "" if never
+	SentOn string
+}
+
+// SetDigestHour records the local hour (0-23) a user (internal ID) wants the daily digest at,
+// or DigestOff to stop it
+func SetDigestHour(userID int64, hour int) error {
+	if hour < DigestOff || hour > 23 {
+		return fmt.Errorf("invalid digest hour: must be 0-23 or %d", DigestOff)
+	}
+	result, err := db.Exec(`UPDATE users SET digest_hour = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, hour, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update preferences: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// ListDigestSubscribers returns every user who asked for the daily digest and has not muted
+// their DMs
+func ListDigestSubscribers() ([]DigestSubscriber, error) {
+	rows, err := db.Query(`
+		SELECT id, digest_hour, timezone, digest_sent_on
+		FROM users
+		WHERE digest_hour >= 0 AND notify_muted = 0
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query digest subscribers: %w", err)
+	}
+	defer rows.Close()
+
+	var subscribers []DigestSubscriber
+	for rows.Next() {
+		var sub DigestSubscriber
+		if err := rows.Scan(&sub.UserID, &sub.Hour, &sub.Timezone, &sub.SentOn); err != nil {
+			return nil, fmt.Errorf("failed to scan digest subscriber: %w", err)
+		}
+		subscribers = append(subscribers, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating digest subscribers: %w", err)
+	}
+	return subscribers, nil
+}
+
+// GetUserRank returns the leaderboard position of a user (internal ID), 1 being the richest.
+// Users with the same balance are ordered by who joined first.
+func GetUserRank(userID int64) (int, error) {
+	var rank int
+	err := db.QueryRow(`
+		SELECT COUNT(*) + 1 FROM users o, users u
+		WHERE u.id = ? AND o.telegram_id != ? AND (o.balance > u.balance OR (o.balance = u.balance AND o.id < u.id))
+	`, userID, HouseTelegramID).Scan(&rank)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rank: %w", err)
+	}
+	return rank, nil
+}
+
+// GetDigestRank returns the rank a user (internal ID) had at their last digest, 0 if they never got one
+func GetDigestRank(userID int64) (int, error) {
+	var rank int
+	err := db.QueryRow(`SELECT digest_rank FROM users WHERE id = ?`, userID).Scan(&rank)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get digest rank: %w", err)
+	}
+	return rank, nil
+}
+
+// RecordDigestRank remembers the rank shown in a user's (internal ID) digest, so the next one
+// can tell how it changed
+func RecordDigestRank(userID int64, rank int) error {
+	_, err := db.Exec(`UPDATE users SET digest_rank = ? WHERE id = ?`, rank, userID)
+	if err != nil {
+		return fmt.Errorf("failed to record digest rank: %w", err)
+	}
+	return nil
+}
+
+// MarkDigestSent records the local date (YYYY-MM-DD) a user's (internal ID) scheduled digest went out
+func MarkDigestSent(userID int64, date string) error {
+	_, err := db.Exec(`UPDATE users SET digest_sent_on = ? WHERE id = ?`, date, userID)
+	if err != nil {
+		return fmt.Errorf("failed to record digest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package storage
+
+import "testing"
+
+func TestGetUserRank(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	alice, _ := CreateUser(4501, "alice", "Alice")
+	bob, _ := CreateUser(4502, "bob", "Bob")
+	carol, _ := CreateUser(4503, "carol", "Carol")
+	db.Exec(`UPDATE users SET balance = 500 WHERE id = ?`, alice.ID)
+	db.Exec(`UPDATE users SET balance = 2000 WHERE id = ?`, bob.ID)
+	db.Exec(`UPDATE users SET balance = 500 WHERE id = ?`, carol.ID)
+
+	for _, tt := range []struct {
+		user *User
+		want int
+	}{{bob, 1}, {alice, 2}, {carol, 3}} {
+		if rank, err := GetUserRank(tt.user.ID); err != nil || rank != tt.want {
+			t.Errorf("Expected %s at rank %d, got %d (err %v)", tt.user.FirstName, tt.want, rank, err)
+		}
+	}
+}
+
+func TestDigestSubscription(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	alice, _ := CreateUser(4601, "alice", "Alice")
+	CreateUser(4602, "bob", "Bob")
+
+	if subs, _ := ListDigestSubscribers(); len(subs) != 0 {
+		t.Errorf("Expected nobody to get the digest by default, got %d", len(subs))
+	}
+	if err := SetDigestHour(alice.ID, 24); err == nil {
+		t.Error("Expected hour 24 to be rejected")
+	}
+	if err := SetDigestHour(alice.ID, 7); err != nil {
+		t.Fatalf("SetDigestHour failed: %v", err)
+	}
+	MarkDigestSent(alice.ID, "2026-03-02")
+
+	subs, err := ListDigestSubscribers()
+	if err != nil || len(subs) != 1 {
+		t.Fatalf("Expected 1 subscriber, got %d (err %v)", len(subs), err)
+	}
+	if subs[0].UserID != alice.ID || subs[0].Hour != 7 || subs[0].SentOn != "2026-03-02" {
+		t.Errorf("Unexpected subscriber %+v", subs[0])
+	}
+
+	SetDigestHour(alice.ID, DigestOff)
+	if subs, _ := ListDigestSubscribers(); len(subs) != 0 {
+		t.Errorf("Expected the digest to be off, got %d subscribers", len(subs))
+	}
+}
@@ -0,0 +1,294 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DisputeStatus is where a dispute stands
+type DisputeStatus string
+
+const (
+	// DisputeOpen waits for an admin decision
+	DisputeOpen DisputeStatus = "OPEN"
+	// DisputeRejected was turned down and the original resolution restored
+	DisputeRejected DisputeStatus = "REJECTED"
+	// DisputeSettled ended with the market being finalized
+	DisputeSettled DisputeStatus = "SETTLED"
+)
+
+// DisputeBondStatus is what became of the bond a disputer staked
+type DisputeBondStatus string
+
+const (
+	// DisputeBondHeld waits for the market to be finalized
+	DisputeBondHeld DisputeBondStatus = "HELD"
+	// DisputeBondRefunded went back to the disputer because the resolution was overturned
+	DisputeBondRefunded DisputeBondStatus = "REFUNDED"
+	// DisputeBondForfeited was added to the market's pool
+	DisputeBondForfeited DisputeBondStatus = "FORFEITED"
+)
+
+// DisputeBond is a bond held for a dispute until its market is finalized
+type DisputeBond struct {
+	DisputeID int64
+	UserID    int64
+	// Outcome is the resolution the dispute challenged
+	Outcome string
+	Amount  int64
+}
+
+// DisputedMarket is a DISPUTED market as admins triage it: the resolution under dispute, the
+// real pools and the dispute that was raised. Markets escalated by a community vote have no
+// disputer.
+type DisputedMarket struct {
+	MarketID int64  `json:"market_id"`
+	Question string `json:"question"`
+	// Outcome is the disputed resolution (or the community's proposed outcome)
+	Outcome    string     `json:"outcome"`
+	PoolYes    int64      `json:"pool_yes"`
+	PoolNo     int64      `json:"pool_no"`
+	DisputedAt *time.Time `json:"disputed_at,omitempty"`
+	// DisputeID is 0 for markets escalated by a community vote
+	DisputeID          int64  `json:"dispute_id,omitempty"`
+	Reason             string `json:"reason,omitempty"`
+	EvidenceURL        string `json:"evidence_url,omitempty"`
+	DisputerTelegramID int64  `json:"disputer_telegram_id,omitempty"`
+	DisputerName       string `json:"disputer_name,omitempty"`
+}
+
+// Dispute is a bettor's challenge of a market's resolution
+type Dispute struct {
+	ID          int64         `json:"id"`
+	MarketID    int64         `json:"market_id"`
+	UserID      int64         `json:"-"`
+	Outcome     string        `json:"outcome"`
+	Reason      string        `json:"reason,omitempty"`
+	EvidenceURL string        `json:"evidence_url,omitempty"`
+	Bond        int64         `json:"bond,omitempty"`
+	Status      DisputeStatus `json:"status"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// OpenDispute moves a RESOLVED market to DISPUTED and records who disputed it (internal ID), why
+// and the evidence they linked, if any. A bond above 0 is taken from the disputer's balance and
+// held until the market is finalized. A market whose dispute was already rejected cannot be
+// disputed again.
+func OpenDispute(ctx context.Context, marketID, userID int64, reason, evidenceURL string, bond int64) (*Dispute, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	var outcome sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT status, outcome FROM markets WHERE id = ?`, marketID).Scan(&status, &outcome)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("market not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+	if status != string(MarketStatusResolved) {
+		return nil, fmt.Errorf("market cannot be disputed: status is %s", status)
+	}
+	var rejected int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM disputes WHERE market_id = ? AND status = 'REJECTED'`, marketID).Scan(&rejected); err != nil {
+		return nil, fmt.Errorf("failed to check disputes: %w", err)
+	}
+	if rejected > 0 {
+		return nil, fmt.Errorf("market cannot be disputed: an admin already rejected a dispute")
+	}
+
+	bondStatus := ""
+	if bond > 0 {
+		var balance int64
+		if err := tx.QueryRowContext(ctx, `SELECT balance FROM users WHERE id = ?`, userID).Scan(&balance); err != nil {
+			return nil, fmt.Errorf("failed to get user balance: %w", err)
+		}
+		if balance < bond {
+			return nil, fmt.Errorf("insufficient funds for the dispute bond: have %d, need %d", balance, bond)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET balance = balance - ? WHERE id = ?`, bond, userID); err != nil {
+			return nil, fmt.Errorf("failed to take dispute bond: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+			VALUES (?, ?, 'DISPUTE_BOND', ?, ?)
+		`, userID, -bond, fmt.Sprintf("Bond for disputing market #%d", marketID), marketID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to log dispute bond transaction: %w", err)
+		}
+		bondStatus = string(DisputeBondHeld)
+	}
+
+	change := MarketStatusChange{To: MarketStatusDisputed, From: []MarketStatus{MarketStatusResolved}, Set: "disputed_at = CURRENT_TIMESTAMP"}
+	if _, err := UpdateMarketStatusTx(ctx, tx, marketID, change); err != nil {
+		return nil, err
+	}
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO disputes (market_id, user_id, outcome, reason, evidence_url, bond, bond_status)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, marketID, userID, outcome.String, reason, evidenceURL, bond, bondStatus).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record dispute: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &Dispute{
+		ID:          id,
+		MarketID:    marketID,
+		UserID:      userID,
+		Outcome:     outcome.String,
+		Reason:      reason,
+		EvidenceURL: evidenceURL,
+		Bond:        bond,
+		Status:      DisputeOpen,
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+// ListDisputedMarkets returns the DISPUTED markets, longest disputed first, each with its latest
+// open dispute
+func ListDisputedMarkets() ([]DisputedMarket, error) {
+	rows, err := db.Query(`
+		SELECT m.id, m.question, COALESCE(m.outcome, ''), m.disputed_at,
+		       COALESCE((SELECT SUM(amount) FROM bets WHERE market_id = m.id AND outcome = 'YES'), 0),
+		       COALESCE((SELECT SUM(amount) FROM bets WHERE market_id = m.id AND outcome = 'NO'), 0),
+		       COALESCE(d.id, 0), COALESCE(d.reason, ''), COALESCE(d.evidence_url, ''), COALESCE(u.telegram_id, 0), COALESCE(u.first_name, '')
+		FROM markets m
+		LEFT JOIN disputes d ON d.id = (
+		    SELECT MAX(id) FROM disputes WHERE market_id = m.id AND status = 'OPEN'
+		)
+		LEFT JOIN users u ON d.user_id = u.id
+		WHERE m.status = 'DISPUTED'
+		ORDER BY m.disputed_at IS NULL, m.disputed_at, m.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query disputed markets: %w", err)
+	}
+	defer rows.Close()
+
+	var markets []DisputedMarket
+	for rows.Next() {
+		var m DisputedMarket
+		var disputedAt sql.NullTime
+		err := rows.Scan(&m.MarketID, &m.Question, &m.Outcome, &disputedAt, &m.PoolYes, &m.PoolNo,
+			&m.DisputeID, &m.Reason, &m.EvidenceURL, &m.DisputerTelegramID, &m.DisputerName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan disputed market: %w", err)
+		}
+		if disputedAt.Valid {
+			m.DisputedAt = &disputedAt.Time
+		}
+		markets = append(markets, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating disputed markets: %w", err)
+	}
+	return markets, nil
+}
+
+// RejectDispute turns down the disputes of a DISPUTED market and restores its resolution, so it
+// is finalized with the original outcome once its dispute period is over. The decision is
+// audited under the admin (internal ID). It returns the restored outcome.
+func RejectDispute(ctx context.Context, marketID, adminID int64) (string, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	var outcome sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT status, outcome FROM markets WHERE id = ?`, marketID).Scan(&status, &outcome)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("market not found")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get market: %w", err)
+	}
+	if status != string(MarketStatusDisputed) {
+		return "", fmt.Errorf("market is not disputed: status is %s", status)
+	}
+	if outcome.String != string(OutcomeYes) && outcome.String != string(OutcomeNo) {
+		return "", fmt.Errorf("market has no resolution to restore")
+	}
+
+	if _, err := UpdateMarketStatusTx(ctx, tx, marketID, MarketStatusChange{To: MarketStatusResolved, From: []MarketStatus{MarketStatusDisputed}}); err != nil {
+		return "", err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE disputes SET status = 'REJECTED', closed_at = CURRENT_TIMESTAMP, closed_by = ?
+		WHERE market_id = ? AND status = 'OPEN'
+	`, adminID, marketID)
+	if err != nil {
+		return "", fmt.Errorf("failed to reject disputes: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_log (actor_id, action, entity_type, entity_id, details)
+		VALUES (?, 'dispute_rejected', ?, ?, ?)
+	`, adminID, AuditEntityMarket, marketID, "outcome="+outcome.String)
+	if err != nil {
+		return "", fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return outcome.String, nil
+}
+
+// SettleDisputesTx closes the open disputes of a market being finalized, inside a transaction
+func SettleDisputesTx(ctx context.Context, tx *sql.Tx, marketID int64) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE disputes SET status = 'SETTLED', closed_at = CURRENT_TIMESTAMP
+		WHERE market_id = ? AND status = 'OPEN'
+	`, marketID)
+	if err != nil {
+		return fmt.Errorf("failed to settle disputes: %w", err)
+	}
+	return nil
+}
+
+// ListHeldDisputeBondsTx returns the bonds still held for a market's disputes, rejected ones
+// included
+func ListHeldDisputeBondsTx(ctx context.Context, tx *sql.Tx, marketID int64) ([]DisputeBond, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, user_id, outcome, bond FROM disputes
+		WHERE market_id = ? AND bond_status = 'HELD'
+		ORDER BY id
+	`, marketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dispute bonds: %w", err)
+	}
+	defer rows.Close()
+
+	var bonds []DisputeBond
+	for rows.Next() {
+		var b DisputeBond
+		if err := rows.Scan(&b.DisputeID, &b.UserID, &b.Outcome, &b.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan dispute bond: %w", err)
+		}
+		bonds = append(bonds, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating dispute bonds: %w", err)
+	}
+	return bonds, nil
+}
+
+// SetDisputeBondStatusTx records that a held bond was refunded or forfeited
+func SetDisputeBondStatusTx(ctx context.Context, tx *sql.Tx, disputeID int64, status DisputeBondStatus) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE disputes SET bond_status = ? WHERE id = ?`, string(status), disputeID); err != nil {
+		return fmt.Errorf("failed to settle dispute bond: %w", err)
+	}
+	return nil
+}
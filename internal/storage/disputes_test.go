@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDisputeLifecycle(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(4401, "creator", "Creator")
+	bettor, _ := CreateUser(4402, "bettor", "Bettor")
+	admin, _ := CreateUser(4403, "admin", "Admin")
+	market, _ := CreateMarket(creator.ID, "Disputed?", time.Now().Add(time.Hour))
+	PlaceBet(ctx, creator.ID, market.ID, "YES", 100)
+	PlaceBet(ctx, bettor.ID, market.ID, "NO", 250)
+
+	// Only RESOLVED markets can be disputed
+	if _, err := OpenDispute(ctx, market.ID, bettor.ID, "", "", 0); err == nil {
+		t.Error("Expected an ACTIVE market to be undisputable")
+	}
+
+	UpdateMarketStatus(market.ID, MarketStatusLocked, "")
+	UpdateMarketStatus(market.ID, MarketStatusResolved, "YES")
+	dispute, err := OpenDispute(ctx, market.ID, bettor.ID, "wrong source", "https://example.com/proof", 0)
+	if err != nil {
+		t.Fatalf("OpenDispute failed: %v", err)
+	}
+	if dispute.Outcome != "YES" || dispute.Status != DisputeOpen {
+		t.Errorf("Expected an open dispute of YES, got %+v", dispute)
+	}
+
+	markets, err := ListDisputedMarkets()
+	if err != nil || len(markets) != 1 {
+		t.Fatalf("Expected 1 disputed market, got %d, %v", len(markets), err)
+	}
+	m := markets[0]
+	if m.MarketID != market.ID || m.Outcome != "YES" || m.PoolYes != 100 || m.PoolNo != 250 {
+		t.Errorf("Unexpected disputed market %+v", m)
+	}
+	if m.DisputeID != dispute.ID || m.Reason != "wrong source" || m.EvidenceURL != "https://example.com/proof" || m.DisputerTelegramID != 4402 || m.DisputerName != "Bettor" {
+		t.Errorf("Expected the disputer on the market, got %+v", m)
+	}
+
+	outcome, err := RejectDispute(ctx, market.ID, admin.ID)
+	if err != nil || outcome != "YES" {
+		t.Fatalf("Expected YES restored, got %q, %v", outcome, err)
+	}
+	restored, _ := GetMarketByID(market.ID)
+	if restored.Status != MarketStatusResolved {
+		t.Errorf("Expected the market RESOLVED again, got %s", restored.Status)
+	}
+	var audited int
+	db.QueryRow(`SELECT COUNT(*) FROM audit_log WHERE action = 'dispute_rejected' AND actor_id = ?`, admin.ID).Scan(&audited)
+	if audited != 1 {
+		t.Errorf("Expected the rejection audited, got %d entries", audited)
+	}
+	if _, err := RejectDispute(ctx, market.ID, admin.ID); err == nil {
+		t.Error("Expected rejecting an undisputed market to fail")
+	}
+
+	// A rejected dispute is final
+	if _, err := OpenDispute(ctx, market.ID, creator.ID, "", "", 0); err == nil {
+		t.Error("Expected a market with a rejected dispute to be undisputable")
+	}
+	if markets, _ := ListDisputedMarkets(); len(markets) != 0 {
+		t.Errorf("Expected no disputed markets, got %d", len(markets))
+	}
+}
+
+func TestSettleDisputesTx(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	user, _ := CreateUser(4411, "user", "User")
+	market, _ := CreateMarket(user.ID, "Settled?", time.Now().Add(time.Hour))
+	UpdateMarketStatus(market.ID, MarketStatusLocked, "")
+	UpdateMarketStatus(market.ID, MarketStatusResolved, "NO")
+	if _, err := OpenDispute(ctx, market.ID, user.ID, "", "", 0); err != nil {
+		t.Fatalf("OpenDispute failed: %v", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx failed: %v", err)
+	}
+	if err := SettleDisputesTx(ctx, tx, market.ID); err != nil {
+		tx.Rollback()
+		t.Fatalf("SettleDisputesTx failed: %v", err)
+	}
+	tx.Commit()
+
+	var status string
+	db.QueryRow(`SELECT status FROM disputes WHERE market_id = ?`, market.ID).Scan(&status)
+	if status != string(DisputeSettled) {
+		t.Errorf("Expected the dispute SETTLED, got %s", status)
+	}
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DMFailures is how a user's DMs have been failing
+type DMFailures struct {
+	TelegramID int64  `json:"telegram_id"`
+	Username   string `json:"username,omitempty"`
+	Name       string `json:"name"`
+	// Failures counts every failed DM
+	Failures int64 `json:"failures"`
+	// BlockedStreak counts the DMs refused because the user blocked the bot since the last one delivered
+	BlockedStreak int64      `json:"blocked_streak"`
+	DisabledAt    *time.Time `json:"disabled_at,omitempty"`
+}
+
+// RecordDMFailure counts a failed DM to a user (Telegram ID). Refusals because the user blocked
+// the bot also extend their blocked streak, and once it reaches limit (0 for never) their DMs
+// are turned off. It reports whether this failure turned them off.
+func RecordDMFailure(telegramID int64, blocked bool, limit int) (bool, error) {
+	_, err := db.Exec(`
+		UPDATE users
+		SET dm_failures = dm_failures + 1,
+		    dm_blocked_streak = dm_blocked_streak + CASE WHEN ? THEN 1 ELSE 0 END
+		WHERE telegram_id = ?
+	`, blocked, telegramID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record DM failure: %w", err)
+	}
+	if !blocked || limit <= 0 {
+		return false, nil
+	}
+
+	result, err := db.Exec(`
+		UPDATE users SET dm_disabled_at = CURRENT_TIMESTAMP
+		WHERE telegram_id = ? AND dm_disabled_at IS NULL AND dm_blocked_streak >= ?
+	`, telegramID, limit)
+	if err != nil {
+		return false, fmt.Errorf("failed to disable DMs: %w", err)
+	}
+	disabled, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return disabled > 0, nil
+}
+
+// RecordDMDelivered ends the blocked streak of a user (Telegram ID) after a DM went through
+func RecordDMDelivered(telegramID int64) error {
+	if _, err := db.Exec(`UPDATE users SET dm_blocked_streak = 0 WHERE telegram_id = ? AND dm_blocked_streak > 0`, telegramID); err != nil {
+		return fmt.Errorf("failed to reset blocked streak: %w", err)
+	}
+	return nil
+}
+
+// IsDMDisabled reports whether DMs to a user (Telegram ID) were turned off; unknown users get DMs
+func IsDMDisabled(telegramID int64) (bool, error) {
+	var disabledAt sql.NullTime
+	err := db.QueryRow(`SELECT dm_disabled_at FROM users WHERE telegram_id = ?`, telegramID).Scan(&disabledAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get DM status: %w", err)
+	}
+	return disabledAt.Valid, nil
+}
+
+// EnableDMs turns DMs back on for a user (internal ID) who talked to the bot again, and reports
+// whether they were off
+func EnableDMs(userID int64) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE users SET dm_disabled_at = NULL, dm_blocked_streak = 0
+		WHERE id = ? AND (dm_disabled_at IS NOT NULL OR dm_blocked_streak > 0)
+	`, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to enable DMs: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ListDMFailures returns the users whose DMs failed, those with DMs turned off first, then the
+// most failures
+func ListDMFailures(limit int) ([]DMFailures, error) {
+	rows, err := db.Query(`
+		SELECT telegram_id, username, first_name, dm_failures, dm_blocked_streak, dm_disabled_at
+		FROM users
+		WHERE dm_failures > 0
+		ORDER BY dm_disabled_at IS NULL, dm_failures DESC, id
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DM failures: %w", err)
+	}
+	defer rows.Close()
+
+	var users []DMFailures
+	for rows.Next() {
+		var u DMFailures
+		var username sql.NullString
+		var disabledAt sql.NullTime
+		if err := rows.Scan(&u.TelegramID, &username, &u.Name, &u.Failures, &u.BlockedStreak, &disabledAt); err != nil {
+			return nil, fmt.Errorf("failed to scan DM failures: %w", err)
+		}
+		u.Username = username.String
+		if disabledAt.Valid {
+			u.DisabledAt = &disabledAt.Time
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating DM failures: %w", err)
+	}
+	return users, nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import "testing"
+
+func TestDMFailures(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := CreateUser(4501, "blocker", "Blocker")
+
+	// Other failures count but do not add to the blocked streak
+	if disabled, err := RecordDMFailure(user.TelegramID, false, 2); err != nil || disabled {
+		t.Fatalf("Expected DMs to stay on, got %v, %v", disabled, err)
+	}
+	if disabled, _ := RecordDMFailure(user.TelegramID, true, 2); disabled {
+		t.Error("Expected DMs to stay on after one refusal")
+	}
+
+	// A delivered DM ends the streak
+	RecordDMDelivered(user.TelegramID)
+	if disabled, _ := RecordDMFailure(user.TelegramID, true, 2); disabled {
+		t.Error("Expected the streak to start over after a delivered DM")
+	}
+	if disabled, _ := RecordDMFailure(user.TelegramID, true, 2); !disabled {
+		t.Error("Expected DMs off after two refusals in a row")
+	}
+	if off, _ := IsDMDisabled(user.TelegramID); !off {
+		t.Error("Expected DMs to be off")
+	}
+
+	failures, err := ListDMFailures(10)
+	if err != nil || len(failures) != 1 {
+		t.Fatalf("Expected 1 user, got %d, %v", len(failures), err)
+	}
+	if f := failures[0]; f.Failures != 4 || f.BlockedStreak != 2 || f.DisabledAt == nil {
+		t.Errorf("Unexpected failures %+v", f)
+	}
+
+	if enabled, err := EnableDMs(user.ID); err != nil || !enabled {
+		t.Fatalf("Expected DMs turned back on, got %v, %v", enabled, err)
+	}
+	if off, _ := IsDMDisabled(user.TelegramID); off {
+		t.Error("Expected DMs to be on again")
+	}
+	if enabled, _ := EnableDMs(user.ID); enabled {
+		t.Error("Expected nothing to enable the second time")
+	}
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// FindDuplicateMarket returns the market a creation request repeats, or nil: the creator's market
+// with the same idempotency key (when key is set), or else their market with the same question
+// created within window
+func FindDuplicateMarket(creatorID int64, question, key string, window time.Duration) (*Market, error) {
+	var marketID int64
+	err := db.QueryRow(`
+		SELECT id FROM markets
+		WHERE creator_id = ?
+		  AND ((? != '' AND idempotency_key = ?)
+		       OR (question = ? AND created_at >= ?))
+		ORDER BY (idempotency_key IS NOT NULL AND idempotency_key = ?) DESC, id DESC
+		LIMIT 1
+	`, creatorID, key, key, question, sqlTimestamp(time.Now().Add(-window)), key).Scan(&marketID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up duplicate market: %w", err)
+	}
+	return GetMarketByID(marketID)
+}
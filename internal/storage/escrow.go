@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// EscrowedPayout is a payout or refund finalization could not credit because the bettor's
+// account no longer exists. It is held until an admin settles it.
+type EscrowedPayout struct {
+	ID       int64 `json:"id"`
+	MarketID int64 `json:"market_id"`
+	// UserID is the internal ID the bet was placed with
+	UserID int64 `json:"user_id"`
+	BetID  int64 `json:"bet_id"`
+	Amount int64 `json:"amount"`
+	// SourceType is the transaction type the payout would have had: WIN_PAYOUT, REFUND,
+	// SHARES_PAYOUT, MARKET_MAKER_REFUND, DISPUTE_BOND_REFUND or CREATOR_REWARD. Only wins and
+	// refunds have a bet; the others have a BetID of 0.
+	SourceType string    `json:"source_type"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// UserExistsTx reports whether a user (internal ID) still has an account, inside a transaction
+func UserExistsTx(ctx context.Context, tx *sql.Tx, userID int64) (bool, error) {
+	var exists int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE id = ?`, userID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check user: %w", err)
+	}
+	return exists > 0, nil
+}
+
+// EscrowPayoutTx holds an unclaimable payout inside the finalization transaction
+func EscrowPayoutTx(ctx context.Context, tx *sql.Tx, payout EscrowedPayout) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO payout_escrow (market_id, user_id, bet_id, amount, source_type)
+		VALUES (?, ?, ?, ?, ?)
+	`, payout.MarketID, payout.UserID, payout.BetID, payout.Amount, payout.SourceType)
+	if err != nil {
+		return fmt.Errorf("failed to escrow payout: %w", err)
+	}
+	return nil
+}
+
+// ListEscrowedPayouts returns every payout held in escrow, oldest first
+func ListEscrowedPayouts() ([]EscrowedPayout, error) {
+	rows, err := db.Query(`
+		SELECT id, market_id, user_id, bet_id, amount, source_type, created_at
+		FROM payout_escrow
+		ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query escrowed payouts: %w", err)
+	}
+	defer rows.Close()
+
+	payouts := []EscrowedPayout{}
+	for rows.Next() {
+		var p EscrowedPayout
+		if err := rows.Scan(&p.ID, &p.MarketID, &p.UserID, &p.BetID, &p.Amount, &p.SourceType, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan escrowed payout: %w", err)
+		}
+		payouts = append(payouts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating escrowed payouts: %w", err)
+	}
+	return payouts, nil
+}
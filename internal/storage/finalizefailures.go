@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// RecordFinalizeFailure counts a failed auto-finalization of a RESOLVED market and keeps its
+// error. Once the market failed limit times (0 for never) it moves to NEEDS_ATTENTION, which the
+// worker no longer retries. It returns the failure count and whether the market was just moved.
+func RecordFinalizeFailure(ctx context.Context, marketID int64, errMsg string, limit int) (int, bool, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// A market disputed or finalized in the meantime has nothing left to count
+	result, err := tx.ExecContext(ctx, `
+		UPDATE markets SET finalize_failures = finalize_failures + 1, finalize_error = ?
+		WHERE id = ? AND status = 'RESOLVED'
+	`, errMsg, marketID)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to record finalization failure: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if updated == 0 {
+		return 0, false, nil
+	}
+
+	var failures int
+	if err := tx.QueryRowContext(ctx, `SELECT finalize_failures FROM markets WHERE id = ?`, marketID).Scan(&failures); err != nil {
+		return 0, false, fmt.Errorf("failed to get finalization failures: %w", err)
+	}
+
+	stuck := limit > 0 && failures >= limit
+	if stuck {
+		if _, err := UpdateMarketStatusTx(ctx, tx, marketID, MarketStatusChange{To: MarketStatusNeedsAttention}); err != nil {
+			return 0, false, err
+		}
+		details := fmt.Sprintf("failures=%d error=%s", failures, errMsg)
+		if err := logAuditTx(ctx, tx, 0, "finalization_stuck", AuditEntityMarket, marketID, details); err != nil {
+			return 0, false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return failures, stuck, nil
+}
+
+// RetryFinalization sends a NEEDS_ATTENTION market back to RESOLVED with its failures cleared,
+// so the worker finalizes it again on its next run
+func RetryFinalization(ctx context.Context, marketID, actorID int64) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	var failures int
+	var lastError string
+	err = tx.QueryRowContext(ctx, `SELECT status, finalize_failures, finalize_error FROM markets WHERE id = ?`, marketID).Scan(&status, &failures, &lastError)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("market not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get market: %w", err)
+	}
+	if status != string(MarketStatusNeedsAttention) {
+		return fmt.Errorf("invalid status: market is %s, not %s", status, MarketStatusNeedsAttention)
+	}
+
+	change := MarketStatusChange{
+		To:   MarketStatusResolved,
+		From: []MarketStatus{MarketStatusNeedsAttention},
+		Set:  "finalize_failures = 0, finalize_error = ''",
+	}
+	if _, err := UpdateMarketStatusTx(ctx, tx, marketID, change); err != nil {
+		return err
+	}
+
+	details := fmt.Sprintf("failures=%d error=%s", failures, lastError)
+	if err := logAuditTx(ctx, tx, actorID, "finalization_retried", AuditEntityMarket, marketID, details); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordFinalizeFailure(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	ctx := context.Background()
+
+	creator, _ := CreateUser(4601, "creator", "Creator")
+	market, _ := CreateMarket(creator.ID, "Will the payout ever go through?", time.Now().Add(time.Hour))
+	UpdateMarketStatus(market.ID, MarketStatusLocked, "")
+	UpdateMarketStatus(market.ID, MarketStatusResolved, "YES")
+	db.Exec(`UPDATE markets SET resolved_at = ? WHERE id = ?`, sqlTimestamp(time.Now().AddDate(0, 0, -2)), market.ID)
+
+	if err := RetryFinalization(ctx, market.ID, creator.ID); err == nil || !strings.Contains(err.Error(), "invalid status") {
+		t.Errorf("Expected a resolved market not to be retried, got %v", err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		failures, stuck, err := RecordFinalizeFailure(ctx, market.ID, "payout failed", 3)
+		if err != nil || failures != i || stuck {
+			t.Fatalf("Expected failure %d to be retried, got %d, %v, %v", i, failures, stuck, err)
+		}
+	}
+	failures, stuck, err := RecordFinalizeFailure(ctx, market.ID, "payout failed again", 3)
+	if err != nil || failures != 3 || !stuck {
+		t.Fatalf("Expected the third failure to need attention, got %d, %v, %v", failures, stuck, err)
+	}
+
+	got, _ := GetMarketByID(market.ID)
+	if got.Status != MarketStatusNeedsAttention {
+		t.Errorf("Expected NEEDS_ATTENTION, got %s", got.Status)
+	}
+	if pending, _ := GetMarketsPendingFinalization(time.Hour); len(pending) != 0 {
+		t.Errorf("Expected the worker to stop retrying, got %v", pending)
+	}
+	if failures, stuck, _ := RecordFinalizeFailure(ctx, market.ID, "late failure", 3); failures != 0 || stuck {
+		t.Error("Expected no more failures counted once the market needs attention")
+	}
+
+	if err := RetryFinalization(ctx, market.ID, creator.ID); err != nil {
+		t.Fatalf("RetryFinalization failed: %v", err)
+	}
+	if pending, _ := GetMarketsPendingFinalization(time.Hour); len(pending) != 1 || pending[0] != market.ID {
+		t.Errorf("Expected the market to be pending again, got %v", pending)
+	}
+	if failures, _, _ := RecordFinalizeFailure(ctx, market.ID, "payout failed", 3); failures != 1 {
+		t.Errorf("Expected the retry to clear the failures, got %d", failures)
+	}
+	if err := RetryFinalization(ctx, 9999, creator.ID); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected an unknown market to be not found, got %v", err)
+	}
+}
+
+func TestRecordFinalizeFailureWithoutLimit(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	ctx := context.Background()
+
+	creator, _ := CreateUser(4602, "creator", "Creator")
+	market, _ := CreateMarket(creator.ID, "Will the worker retry forever?", time.Now().Add(time.Hour))
+	UpdateMarketStatus(market.ID, MarketStatusLocked, "")
+	UpdateMarketStatus(market.ID, MarketStatusResolved, "NO")
+
+	for i := 0; i < 5; i++ {
+		if _, stuck, err := RecordFinalizeFailure(ctx, market.ID, "payout failed", 0); err != nil || stuck {
+			t.Fatalf("Expected a limit of 0 to keep retrying, got %v, %v", stuck, err)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// FixtureStatus is where a sports fixture is in its review and resolution
+type FixtureStatus string
+
+const (
+	// FixturePending waits for an admin to approve or reject it
+	FixturePending FixtureStatus = "PENDING"
+	// FixtureApproved has a match market waiting for the final score
+	FixtureApproved FixtureStatus = "APPROVED"
+	// FixtureRejected was turned down and gets no market
+	FixtureRejected FixtureStatus = "REJECTED"
+	// FixtureResolved had its market resolved from the final score
+	FixtureResolved FixtureStatus = "RESOLVED"
+	// FixtureCancelled was called off by the feed after its market was created; an admin
+	// settles the market
+	FixtureCancelled FixtureStatus = "CANCELLED"
+)
+
+// Valid reports whether s is a known fixture status
+func (s FixtureStatus) Valid() bool {
+	switch s {
+	case FixturePending, FixtureApproved, FixtureRejected, FixtureResolved, FixtureCancelled:
+		return true
+	}
+	return false
+}
+
+// SportsFixture is a match from the sports feed
+type SportsFixture struct {
+	ID int64 `json:"id"`
+	// ExternalID is the feed's ID for the match
+	ExternalID string        `json:"external_id"`
+	League     string        `json:"league,omitempty"`
+	HomeTeam   string        `json:"home_team"`
+	AwayTeam   string        `json:"away_team"`
+	KickoffAt  time.Time     `json:"kickoff_at"`
+	Status     FixtureStatus `json:"status"`
+	// MarketID is the match market, once approved
+	MarketID int64 `json:"market_id,omitempty"`
+	// ReviewedBy is the internal ID of the admin who approved or rejected the fixture
+	ReviewedBy int64 `json:"reviewed_by,omitempty"`
+	// HomeScore and AwayScore are the final score, once resolved
+	HomeScore *int      `json:"home_score,omitempty"`
+	AwayScore *int      `json:"away_score,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const fixtureColumns = `id, external_id, league, home_team, away_team, kickoff_at, status,
+	COALESCE(market_id, 0), COALESCE(reviewed_by, 0), home_score, away_score, created_at`
+
+func scanFixture(row interface{ Scan(...interface{}) error }) (*SportsFixture, error) {
+	var f SportsFixture
+	var homeScore, awayScore sql.NullInt64
+	err := row.Scan(&f.ID, &f.ExternalID, &f.League, &f.HomeTeam, &f.AwayTeam, &f.KickoffAt, &f.Status,
+		&f.MarketID, &f.ReviewedBy, &homeScore, &awayScore, &f.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if homeScore.Valid && awayScore.Valid {
+		home, away := int(homeScore.Int64), int(awayScore.Int64)
+		f.HomeScore, f.AwayScore = &home, &away
+	}
+	return &f, nil
+}
+
+// SaveFixture records a fixture seen in the feed and reports whether it is new. A fixture still
+// waiting for review follows the feed's teams and kickoff; reviewed ones are left alone.
+func SaveFixture(externalID, league, homeTeam, awayTeam string, kickoffAt time.Time) (bool, error) {
+	kickoff := sqlTimestamp(kickoffAt)
+	result, err := db.Exec(`
+		INSERT INTO sports_fixtures (external_id, league, home_team, away_team, kickoff_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(external_id) DO NOTHING
+	`, externalID, league, homeTeam, awayTeam, kickoff)
+	if err != nil {
+		return false, fmt.Errorf("failed to save fixture: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		return true, nil
+	}
+
+	_, err = db.Exec(`
+		UPDATE sports_fixtures
+		SET league = ?, home_team = ?, away_team = ?, kickoff_at = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE external_id = ? AND status = ?
+	`, league, homeTeam, awayTeam, kickoff, externalID, FixturePending)
+	if err != nil {
+		return false, fmt.Errorf("failed to update fixture: %w", err)
+	}
+	return false, nil
+}
+
+// GetFixture returns a fixture by ID, or nil if there is none
+func GetFixture(id int64) (*SportsFixture, error) {
+	f, err := scanFixture(db.QueryRow(`SELECT `+fixtureColumns+` FROM sports_fixtures WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fixture: %w", err)
+	}
+	return f, nil
+}
+
+// GetFixtureByExternalID returns a fixture by its feed ID, or nil if there is none
+func GetFixtureByExternalID(externalID string) (*SportsFixture, error) {
+	f, err := scanFixture(db.QueryRow(`SELECT `+fixtureColumns+` FROM sports_fixtures WHERE external_id = ?`, externalID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fixture: %w", err)
+	}
+	return f, nil
+}
+
+// ListFixtures returns the fixtures in a status, soonest kickoff first
+func ListFixtures(status FixtureStatus, limit int) ([]SportsFixture, error) {
+	rows, err := db.Query(`
+		SELECT `+fixtureColumns+`
+		FROM sports_fixtures
+		WHERE status = ?
+		ORDER BY kickoff_at, id
+		LIMIT ?
+	`, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixtures: %w", err)
+	}
+	defer rows.Close()
+
+	var fixtures []SportsFixture
+	for rows.Next() {
+		f, err := scanFixture(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan fixture: %w", err)
+		}
+		fixtures = append(fixtures, *f)
+	}
+	return fixtures, rows.Err()
+}
+
+// ApproveFixture links a pending fixture to the match market created for it
+func ApproveFixture(id, marketID, reviewerID int64) error {
+	return reviewFixture(id, FixtureApproved, marketID, reviewerID)
+}
+
+// RejectFixture turns a pending fixture down
+func RejectFixture(id, reviewerID int64) error {
+	return reviewFixture(id, FixtureRejected, 0, reviewerID)
+}
+
+// reviewFixture moves a pending fixture to status; marketID 0 leaves the market unset
+func reviewFixture(id int64, status FixtureStatus, marketID, reviewerID int64) error {
+	result, err := db.Exec(`
+		UPDATE sports_fixtures
+		SET status = ?, market_id = NULLIF(?, 0), reviewed_by = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?
+	`, status, marketID, reviewerID, id, FixturePending)
+	if err != nil {
+		return fmt.Errorf("failed to review fixture: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("fixture not pending")
+	}
+	return nil
+}
+
+// ResolveFixture records the final score of an approved fixture
+func ResolveFixture(id int64, homeScore, awayScore int) error {
+	return closeFixture(id, FixtureResolved, homeScore, awayScore)
+}
+
+// CancelFixture marks an approved fixture called off by the feed
+func CancelFixture(id int64) error {
+	return closeFixture(id, FixtureCancelled, 0, 0)
+}
+
+// closeFixture moves an approved fixture to status, with the score when it is resolved
+func closeFixture(id int64, status FixtureStatus, homeScore, awayScore int) error {
+	var home, away interface{}
+	if status == FixtureResolved {
+		home, away = homeScore, awayScore
+	}
+	result, err := db.Exec(`
+		UPDATE sports_fixtures
+		SET status = ?, home_score = ?, away_score = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?
+	`, status, home, away, id, FixtureApproved)
+	if err != nil {
+		return fmt.Errorf("failed to close fixture: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("fixture not approved")
+	}
+	return nil
+}
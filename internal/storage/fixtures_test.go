@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSportsFixtures(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin, _ := CreateUser(4801, "admin", "Admin")
+	kickoff := time.Date(2026, 5, 9, 15, 0, 0, 0, time.UTC)
+
+	created, err := SaveFixture("m-1", "Premier League", "Arsenal", "Chelsea", kickoff)
+	if err != nil || !created {
+		t.Fatalf("Expected a new fixture, got %v, %v", created, err)
+	}
+	// A pending fixture follows the feed's kickoff
+	if created, _ := SaveFixture("m-1", "Premier League", "Arsenal", "Chelsea", kickoff.Add(time.Hour)); created {
+		t.Error("Expected the fixture to be known")
+	}
+	fixture, err := GetFixtureByExternalID("m-1")
+	if err != nil || fixture == nil || fixture.Status != FixturePending || !fixture.KickoffAt.Equal(kickoff.Add(time.Hour)) {
+		t.Fatalf("Unexpected fixture %+v, %v", fixture, err)
+	}
+	SaveFixture("m-2", "", "Inter", "Milan", kickoff.Add(-time.Hour))
+
+	pending, _ := ListFixtures(FixturePending, 10)
+	if len(pending) != 2 || pending[0].ExternalID != "m-2" {
+		t.Errorf("Expected both fixtures, soonest first, got %+v", pending)
+	}
+
+	market, _ := CreateMarket(admin.ID, "Will Arsenal beat Chelsea?", kickoff)
+	if err := ApproveFixture(fixture.ID, market.ID, admin.ID); err != nil {
+		t.Fatalf("ApproveFixture failed: %v", err)
+	}
+	if err := RejectFixture(fixture.ID, admin.ID); err == nil {
+		t.Error("Expected a reviewed fixture not to be reviewed again")
+	}
+	// Reviewed fixtures ignore the feed's changes
+	SaveFixture("m-1", "Premier League", "Arsenal FC", "Chelsea", kickoff)
+	if err := ResolveFixture(fixture.ID, 2, 1); err != nil {
+		t.Fatalf("ResolveFixture failed: %v", err)
+	}
+	fixture, _ = GetFixture(fixture.ID)
+	if fixture.Status != FixtureResolved || fixture.MarketID != market.ID || fixture.ReviewedBy != admin.ID ||
+		fixture.HomeTeam != "Arsenal" || *fixture.HomeScore != 2 || *fixture.AwayScore != 1 {
+		t.Errorf("Unexpected resolved fixture %+v", fixture)
+	}
+
+	other, _ := GetFixtureByExternalID("m-2")
+	if err := CancelFixture(other.ID); err == nil {
+		t.Error("Expected only approved fixtures to be cancelled")
+	}
+	if err := RejectFixture(other.ID, admin.ID); err != nil {
+		t.Fatalf("RejectFixture failed: %v", err)
+	}
+	if other, _ = GetFixture(other.ID); other.Status != FixtureRejected || other.MarketID != 0 || other.HomeScore != nil {
+		t.Errorf("Unexpected rejected fixture %+v", other)
+	}
+	if missing, err := GetFixture(999); missing != nil || err != nil {
+		t.Errorf("Expected no fixture, got %+v, %v", missing, err)
+	}
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GroupDigest is a group chat that asked for the morning digest at Hour in Timezone
+type GroupDigest struct {
+	ChatID   int64  `json:"chat_id"`
+	Hour     int    `json:"hour"`
+	Timezone string `json:"timezone"`
+	// EnabledBy is the Telegram ID of the group admin who turned the digest on
+	EnabledBy int64 `json:"enabled_by"`
+	// SentOn is the local date (YYYY-MM-DD) of the last digest, "" if none was sent yet
+	SentOn string `json:"sent_on"`
+}
+
+// EnableGroupDigest turns the morning digest on for a group chat, or changes its hour and timezone
+func EnableGroupDigest(chatID int64, hour int, timezone string, enabledBy int64) error {
+	if hour < 0 || hour > 23 {
+		return fmt.Errorf("invalid digest hour: must be 0-23")
+	}
+	_, err := db.Exec(`
+		INSERT INTO group_digests (chat_id, hour, timezone, enabled_by)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET hour = excluded.hour, timezone = excluded.timezone, enabled_by = excluded.enabled_by
+	`, chatID, hour, timezone, enabledBy)
+	if err != nil {
+		return fmt.Errorf("failed to enable group digest: %w", err)
+	}
+	return nil
+}
+
+// DisableGroupDigest turns the morning digest off for a group chat
+func DisableGroupDigest(chatID int64) error {
+	if _, err := db.Exec(`DELETE FROM group_digests WHERE chat_id = ?`, chatID); err != nil {
+		return fmt.Errorf("failed to disable group digest: %w", err)
+	}
+	return nil
+}
+
+// GetGroupDigest returns the digest settings of a group chat, or nil if it has none
+func GetGroupDigest(chatID int64) (*GroupDigest, error) {
+	var g GroupDigest
+	err := db.QueryRow(`
+		SELECT chat_id, hour, timezone, enabled_by, sent_on FROM group_digests WHERE chat_id = ?
+	`, chatID).Scan(&g.ChatID, &g.Hour, &g.Timezone, &g.EnabledBy, &g.SentOn)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group digest: %w", err)
+	}
+	return &g, nil
+}
+
+// ListGroupDigests returns every group chat that asked for the morning digest
+func ListGroupDigests() ([]GroupDigest, error) {
+	rows, err := db.Query(`SELECT chat_id, hour, timezone, enabled_by, sent_on FROM group_digests ORDER BY chat_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group digests: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []GroupDigest
+	for rows.Next() {
+		var g GroupDigest
+		if err := rows.Scan(&g.ChatID, &g.Hour, &g.Timezone, &g.EnabledBy, &g.SentOn); err != nil {
+			return nil, fmt.Errorf("failed to scan group digest: %w", err)
+		}
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating group digests: %w", err)
+	}
+	return groups, nil
+}
+
+// MarkGroupDigestSent records the local date (YYYY-MM-DD) a group's digest was posted
+func MarkGroupDigestSent(chatID int64, date string) error {
+	if _, err := db.Exec(`UPDATE group_digests SET sent_on = ? WHERE chat_id = ?`, date, chatID); err != nil {
+		return fmt.Errorf("failed to record group digest: %w", err)
+	}
+	return nil
+}
+
+// ListMarketsResolvedSince returns the visible markets resolved (or finalized) within the last
+// `since`, oldest resolution first
+func ListMarketsResolvedSince(since time.Duration) ([]Market, error) {
+	rows, err := db.Query(`
+		SELECT id, creator_id, icon, question, status, outcome, resolved_at, expires_at, created_at
+		FROM markets
+		WHERE status IN ('RESOLVED', 'FINALIZED') AND hidden = 0 AND outcome IS NOT NULL
+		  AND resolved_at >= ?
+		ORDER BY resolved_at, id
+	`, sqlTimestamp(time.Now().Add(-since)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query resolved markets: %w", err)
+	}
+	defer rows.Close()
+
+	var markets []Market
+	for rows.Next() {
+		var market Market
+		err := rows.Scan(
+			&market.ID,
+			&market.CreatorID,
+			&market.Icon,
+			&market.Question,
+			&market.Status,
+			&market.Outcome,
+			&market.ResolvedAt,
+			&market.ExpiresAt,
+			&market.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan market: %w", err)
+		}
+		markets = append(markets, market)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating markets: %w", err)
+	}
+	return markets, nil
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroupDigestSettings(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	if err := EnableGroupDigest(-1001, 24, "", 42); err == nil {
+		t.Error("Expected hour 24 to be rejected")
+	}
+	if err := EnableGroupDigest(-1001, 8, "Europe/Berlin", 42); err != nil {
+		t.Fatalf("EnableGroupDigest failed: %v", err)
+	}
+	if err := EnableGroupDigest(-1001, 9, "Europe/Berlin", 43); err != nil {
+		t.Fatalf("EnableGroupDigest (update) failed: %v", err)
+	}
+	MarkGroupDigestSent(-1001, "2026-03-02")
+
+	group, err := GetGroupDigest(-1001)
+	if err != nil || group == nil {
+		t.Fatalf("Expected the group digest, got %v (err %v)", group, err)
+	}
+	if group.Hour != 9 || group.EnabledBy != 43 || group.SentOn != "2026-03-02" {
+		t.Errorf("Unexpected group digest %+v", group)
+	}
+	if groups, _ := ListGroupDigests(); len(groups) != 1 {
+		t.Errorf("Expected 1 group, got %d", len(groups))
+	}
+
+	DisableGroupDigest(-1001)
+	if group, _ := GetGroupDigest(-1001); group != nil {
+		t.Errorf("Expected the digest to be off, got %+v", group)
+	}
+}
+
+func TestListMarketsResolvedSince(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	alice, _ := CreateUser(4701, "alice", "Alice")
+	recent, _ := CreateMarket(alice.ID, "Resolved an hour ago?", time.Now().Add(-2*time.Hour))
+	old, _ := CreateMarket(alice.ID, "Resolved last week?", time.Now().Add(-200*time.Hour))
+	CreateMarket(alice.ID, "Still open?", time.Now().Add(time.Hour))
+	db.Exec(`UPDATE markets SET status = 'RESOLVED', outcome = 'YES', resolved_at = ? WHERE id = ?`, sqlTimestamp(time.Now().Add(-time.Hour)), recent.ID)
+	db.Exec(`UPDATE markets SET status = 'FINALIZED', outcome = 'NO', resolved_at = ? WHERE id = ?`, sqlTimestamp(time.Now().AddDate(0, 0, -7)), old.ID)
+
+	markets, err := ListMarketsResolvedSince(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("ListMarketsResolvedSince failed: %v", err)
+	}
+	if len(markets) != 1 || markets[0].ID != recent.ID || markets[0].Outcome != "YES" {
+		t.Errorf("Expected only market %d, got %+v", recent.ID, markets)
+	}
+	if since := time.Since(markets[0].ResolvedAt); since < 50*time.Minute || since > 70*time.Minute {
+		t.Errorf("Expected resolved_at about an hour ago, got %v", markets[0].ResolvedAt)
+	}
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// HouseTelegramID is the telegram_id of the house account, which collects the fees taken from
+// market pools. No Telegram user has ID 0, so nobody can sign in as the house or be mistaken for
+// it, and it is left off the leaderboard.
+const HouseTelegramID = 0
+
+// GetHouseAccount returns the house account, nil until it collected its first fee
+func GetHouseAccount() (*User, error) {
+	return GetUserByTelegramID(HouseTelegramID)
+}
+
+// CreditHouseFeeTx credits fee to the house account, creating the account on first use, and
+// logs it as a FEE transaction on the market
+func CreditHouseFeeTx(ctx context.Context, tx *sql.Tx, marketID, fee int64, description string) error {
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO users (telegram_id, username, first_name, balance)
+		VALUES (?, 'house', 'House', 0)
+		ON CONFLICT DO NOTHING
+	`, HouseTelegramID); err != nil {
+		return fmt.Errorf("failed to create house account: %w", err)
+	}
+	var houseID int64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM users WHERE telegram_id = ?`, HouseTelegramID).Scan(&houseID); err != nil {
+		return fmt.Errorf("failed to get house account: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET balance = balance + ? WHERE id = ?`, fee, houseID); err != nil {
+		return fmt.Errorf("failed to credit house fee: %w", err)
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+		VALUES (?, ?, 'FEE', ?, ?)
+	`, houseID, fee, description, marketID)
+	if err != nil {
+		return fmt.Errorf("failed to log house fee: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// DefaultMarketIcon is the icon of markets that neither chose one nor match a category
+const DefaultMarketIcon = "🎯"
+
+// GetMarketIcon returns the icon of a market, DefaultMarketIcon if the market is unknown
+func GetMarketIcon(marketID int64) (string, error) {
+	var icon string
+	err := db.QueryRow(`SELECT icon FROM markets WHERE id = ?`, marketID).Scan(&icon)
+	if err == sql.ErrNoRows {
+		return DefaultMarketIcon, nil
+	}
+	if err != nil {
+		return DefaultMarketIcon, fmt.Errorf("failed to get market icon: %w", err)
+	}
+	return icon, nil
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"predictionbot/internal/statemachine"
+)
+
+// publicBetSQL limits a bets join (alias b) on markets (alias m) to the bets users may see:
+// while a market is in its last call, pools stay frozen at the bets placed before it started,
+// and blind markets show no bets at all until they lock
+const publicBetSQL = `(m.status != 'LAST_CALL' OR b.id <= COALESCE(m.last_call_bet_id, 0)) AND NOT ` + poolsHiddenSQL
+
+// PublicPools are the pool totals shown to users for a market
+type PublicPools struct {
+	Yes   int64
+	No    int64
+	Total int64
+	// PoolsHidden is set while a blind market is open; everything is zero
+	PoolsHidden bool
+	// SidesHidden is set until a sealed market is finalized; only Total is shown
+	SidesHidden bool
+}
+
+// GetPublicPools returns the pools shown to users: frozen during a market's last call, zero while
+// a blind market is open and only a total for sealed markets. Payouts and limits must keep using GetPoolTotals.
+func GetPublicPools(marketID int64) (PublicPools, error) {
+	var pools PublicPools
+	err := db.QueryRow(`
+		SELECT `+poolsHiddenSQL+`, `+sidesHiddenSQL+`,
+		       COALESCE(SUM(CASE WHEN b.outcome = 'YES' THEN b.amount ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN b.outcome = 'NO' THEN b.amount ELSE 0 END), 0)
+		FROM markets m
+		LEFT JOIN bets b ON b.market_id = m.id AND `+publicBetSQL+`
+		WHERE m.id = ?
+		GROUP BY m.id
+	`, marketID).Scan(&pools.PoolsHidden, &pools.SidesHidden, &pools.Yes, &pools.No)
+	if err == sql.ErrNoRows {
+		return PublicPools{}, nil
+	}
+	if err != nil {
+		return PublicPools{}, fmt.Errorf("failed to get pool totals: %w", err)
+	}
+	pools.Total = pools.Yes + pools.No
+	if pools.SidesHidden {
+		pools.Yes, pools.No = 0, 0
+	}
+	return pools, nil
+}
+
+// StartLastCalls moves active markets expiring within window into LAST_CALL, freezing their
+// public pools at the current bets, and returns them. Bets are still accepted until expiry.
+func StartLastCalls(window time.Duration) ([]*Market, error) {
+	rows, err := db.Query(`SELECT id, expires_at FROM markets WHERE status = 'ACTIVE'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active markets: %w", err)
+	}
+
+	// Compare deadlines in Go; expires_at is stored with its time zone
+	now := time.Now()
+	var due []int64
+	for rows.Next() {
+		var id int64
+		var expiresAt time.Time
+		if err := rows.Scan(&id, &expiresAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan market: %w", err)
+		}
+		if expiresAt.After(now) && expiresAt.Sub(now) <= window {
+			due = append(due, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("error iterating markets: %w", err)
+	}
+	rows.Close()
+
+	var started []*Market
+	for _, id := range due {
+		ok, err := startLastCall(id)
+		if err != nil {
+			return started, err
+		}
+		if !ok {
+			continue
+		}
+		market, err := GetMarketByID(id)
+		if err != nil {
+			return started, err
+		}
+		if market != nil {
+			started = append(started, market)
+		}
+	}
+	return started, nil
+}
+
+// startLastCall moves an ACTIVE market to LAST_CALL, freezing its public pools at the bets placed
+// so far. It returns false when the market was no longer active.
+func startLastCall(marketID int64) (bool, error) {
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	change := MarketStatusChange{
+		To:   MarketStatusLastCall,
+		From: []MarketStatus{MarketStatusActive},
+		Set:  "last_call_bet_id = (SELECT COALESCE(MAX(id), 0) FROM bets WHERE market_id = ?)",
+		Args: []interface{}{marketID},
+	}
+	_, err = UpdateMarketStatusTx(ctx, tx, marketID, change)
+	var transition *statemachine.TransitionError
+	if errors.As(err, &transition) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to start last call: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStartLastCalls(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(4001, "creator", "Creator")
+	early, _ := CreateUser(4002, "early", "Early")
+	late, _ := CreateUser(4003, "late", "Late")
+
+	closing, _ := CreateMarket(creator.ID, "Is this market about to close?", time.Now().Add(3*time.Minute))
+	later, _ := CreateMarket(creator.ID, "Is this market closing much later?", time.Now().Add(time.Hour))
+	if err := PlaceBet(ctx, early.ID, closing.ID, "YES", 100); err != nil {
+		t.Fatalf("PlaceBet failed: %v", err)
+	}
+
+	started, err := StartLastCalls(5 * time.Minute)
+	if err != nil {
+		t.Fatalf("StartLastCalls failed: %v", err)
+	}
+	if len(started) != 1 || started[0].ID != closing.ID || started[0].Status != MarketStatusLastCall {
+		t.Fatalf("Expected only market %d in last call, got %+v", closing.ID, started)
+	}
+	if again, _ := StartLastCalls(5 * time.Minute); len(again) != 0 {
+		t.Errorf("Expected last call to start only once, got %+v", again)
+	}
+	if market, _ := GetMarketByID(later.ID); market.Status != MarketStatusActive {
+		t.Errorf("Expected market %d to stay active, got %s", later.ID, market.Status)
+	}
+
+	// Bets are still taken, but the public pools stay frozen
+	if err := PlaceBet(ctx, late.ID, closing.ID, "NO", 300); err != nil {
+		t.Fatalf("PlaceBet during last call failed: %v", err)
+	}
+	pools, err := GetPublicPools(closing.ID)
+	if err != nil || pools.Yes != 100 || pools.No != 0 {
+		t.Errorf("Expected frozen pools 100/0, got %+v (err=%v)", pools, err)
+	}
+	if poolYes, poolNo, _ := GetPoolTotals(closing.ID); poolYes != 100 || poolNo != 300 {
+		t.Errorf("Expected real pools 100/300, got %d/%d", poolYes, poolNo)
+	}
+
+	markets, _ := ListActiveMarketsWithCreator()
+	for _, m := range markets {
+		if m.ID == closing.ID && (m.Status != string(MarketStatusLastCall) || m.PoolNo != 0) {
+			t.Errorf("Expected frozen last call market in list, got %+v", m)
+		}
+	}
+
+	// Once the market locks, everything is visible again
+	db.Exec(`UPDATE markets SET status = 'LOCKED' WHERE id = ?`, closing.ID)
+	if pools, _ = GetPublicPools(closing.ID); pools.Yes != 100 || pools.No != 300 {
+		t.Errorf("Expected pools 100/300 after lock, got %+v", pools)
+	}
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LastSeenThrottle is how often a user's last_seen_at is written at most; activity in between
+// is not recorded, so every request doesn't turn into a write
+const LastSeenThrottle = 5 * time.Minute
+
+var (
+	lastSeenMu      sync.Mutex
+	lastSeenWritten map[int64]time.Time
+)
+
+// resetLastSeen forgets the throttle state; called when the database is opened
+func resetLastSeen() {
+	lastSeenMu.Lock()
+	defer lastSeenMu.Unlock()
+	lastSeenWritten = make(map[int64]time.Time)
+}
+
+// TouchLastSeen records that a user (internal ID) is active now, at most once per LastSeenThrottle
+func TouchLastSeen(userID int64) error {
+	now := time.Now()
+	lastSeenMu.Lock()
+	if at, ok := lastSeenWritten[userID]; ok && now.Sub(at) < LastSeenThrottle {
+		lastSeenMu.Unlock()
+		return nil
+	}
+	lastSeenWritten[userID] = now
+	lastSeenMu.Unlock()
+
+	if _, err := db.Exec(`UPDATE users SET last_seen_at = CURRENT_TIMESTAMP WHERE id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to record last seen: %w", err)
+	}
+	return nil
+}
+
+// GetLastSeen returns when a user (internal ID) was last active, and false if they never were
+// since last_seen_at was introduced
+func GetLastSeen(userID int64) (time.Time, bool, error) {
+	var lastSeen sql.NullTime
+	err := db.QueryRow(`SELECT last_seen_at FROM users WHERE id = ?`, userID).Scan(&lastSeen)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get last seen: %w", err)
+	}
+	return lastSeen.Time, lastSeen.Valid, nil
+}
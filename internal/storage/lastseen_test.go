@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTouchLastSeen(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := CreateUser(1, "seen", "Seen")
+	if _, ok, _ := GetLastSeen(user.ID); ok {
+		t.Fatal("Expected a new user to have no last seen time")
+	}
+
+	if err := TouchLastSeen(user.ID); err != nil {
+		t.Fatalf("TouchLastSeen failed: %v", err)
+	}
+	first, ok, err := GetLastSeen(user.ID)
+	if err != nil || !ok {
+		t.Fatalf("Expected a last seen time, got ok=%v err=%v", ok, err)
+	}
+
+	// A second visit within the throttle window is not written
+	db.Exec(`UPDATE users SET last_seen_at = ? WHERE id = ?`, sqlTimestamp(time.Now().Add(-time.Hour)), user.ID)
+	if err := TouchLastSeen(user.ID); err != nil {
+		t.Fatalf("TouchLastSeen failed: %v", err)
+	}
+	second, _, _ := GetLastSeen(user.ID)
+	if !second.Before(first) {
+		t.Errorf("Expected the throttled touch to be skipped, last seen moved to %v", second)
+	}
+}
+
+func TestUsersUpdatedAtTrigger(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := CreateUser(1, "writer", "Writer")
+	updatedAt := func() time.Time {
+		var at time.Time
+		db.QueryRow(`SELECT updated_at FROM users WHERE id = ?`, user.ID).Scan(&at)
+		return at
+	}
+
+	db.Exec(`UPDATE users SET updated_at = ? WHERE id = ?`, sqlTimestamp(time.Now().AddDate(0, 0, -1)), user.ID)
+	stale := updatedAt()
+	if err := TouchLastSeen(user.ID); err != nil {
+		t.Fatalf("TouchLastSeen failed: %v", err)
+	}
+	if !updatedAt().Equal(stale) {
+		t.Error("Expected last seen updates to leave updated_at alone")
+	}
+
+	if _, err := db.Exec(`UPDATE users SET balance = balance + 1 WHERE id = ?`, user.ID); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if !updatedAt().After(stale) {
+		t.Error("Expected a balance change to bump updated_at")
+	}
+}
+
+func TestPlatformStatsInactiveUsers(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	away, _ := CreateUser(1, "away", "Away")
+	back, _ := CreateUser(2, "back", "Back")
+	CreateUser(3, "new", "New")
+	db.Exec(`UPDATE users SET created_at = ?, last_seen_at = ? WHERE id = ?`, sqlTimestamp(time.Now().AddDate(0, 0, -90)), sqlTimestamp(time.Now().AddDate(0, 0, -45)), away.ID)
+	db.Exec(`UPDATE users SET created_at = ? WHERE id = ?`, sqlTimestamp(time.Now().AddDate(0, 0, -90)), back.ID)
+	TouchLastSeen(back.ID)
+
+	stats, err := GetPlatformStats(1, time.Now())
+	if err != nil {
+		t.Fatalf("GetPlatformStats failed: %v", err)
+	}
+	if stats.InactiveUsers != 1 {
+		t.Errorf("Expected 1 inactive user, got %d", stats.InactiveUsers)
+	}
+	if stats.DAU != 1 {
+		t.Errorf("Expected the returning user to count as active, got DAU %d", stats.DAU)
+	}
+}
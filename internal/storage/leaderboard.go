@@ -0,0 +1,330 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// rankedUsersSQL ranks every user but the house account by balance (ties by signup order) next
+// to their rank and balance in the latest daily balance snapshot, and counts the ranked users.
+// Its only argument is HouseTelegramID.
+const rankedUsersSQL = `
+	SELECT ROW_NUMBER() OVER (ORDER BY u.balance DESC, u.id) AS rank,
+	       COUNT(*) OVER () AS total,
+	       u.id AS user_id,
+	       u.username,
+	       u.first_name,
+	       u.balance,
+	       COALESCE(s.rank, 0) AS previous_rank,
+	       s.balance AS previous_balance
+	FROM users u
+	LEFT JOIN balance_snapshots s
+	       ON s.user_id = u.id AND s.day = (SELECT MAX(day) FROM balance_snapshots)
+	WHERE u.telegram_id != ?`
+
+// LeaderboardPosition is a user's place on the leaderboard with the users around them
+type LeaderboardPosition struct {
+	Rank  int64 `json:"rank"`
+	Total int64 `json:"total"`
+	// Entries are the user and their neighbours, best first; the user's own entry has IsMe set
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+// GetTopUsers returns the top users by balance for the leaderboard, with their movement since
+// the latest daily balance snapshot
+func GetTopUsers(limit int) ([]LeaderboardEntry, error) {
+	entries, _, err := GetLeaderboardPage(limit, 0)
+	return entries, err
+}
+
+// GetLeaderboardPage returns one page of the leaderboard and how many users it ranks in total
+func GetLeaderboardPage(limit, offset int) ([]LeaderboardEntry, int, error) {
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM users WHERE telegram_id != ?`, HouseTelegramID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	rows, err := db.Query(`
+		WITH ranked AS (`+rankedUsersSQL+`)
+		SELECT rank, total, user_id, username, first_name, balance, previous_rank, previous_balance
+		FROM ranked
+		ORDER BY rank
+		LIMIT ? OFFSET ?
+	`, HouseTelegramID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query leaderboard: %w", err)
+	}
+	entries, _, err := scanLeaderboard(rows, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// GetLeaderboardPosition returns the rank of a user (internal ID) together with up to
+// neighbours users above and below them, in a single query. It returns nil for unknown users.
+func GetLeaderboardPosition(userID int64, neighbours int) (*LeaderboardPosition, error) {
+	rows, err := db.Query(`
+		WITH ranked AS (`+rankedUsersSQL+`)
+		SELECT r.rank, r.total, r.user_id, r.username, r.first_name, r.balance, r.previous_rank, r.previous_balance
+		FROM ranked r
+		JOIN ranked me ON me.user_id = ?
+		WHERE r.rank BETWEEN me.rank - ? AND me.rank + ?
+		ORDER BY r.rank
+	`, HouseTelegramID, userID, neighbours, neighbours)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query leaderboard position: %w", err)
+	}
+	entries, total, err := scanLeaderboard(rows, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsMe {
+			return &LeaderboardPosition{Rank: entry.Rank, Total: total, Entries: entries}, nil
+		}
+	}
+	return nil, nil
+}
+
+// scanLeaderboard reads and closes rows selected from rankedUsersSQL, marking the entry of
+// userID (0 for none), and returns the ranked total
+func scanLeaderboard(rows *sql.Rows, userID int64) ([]LeaderboardEntry, int64, error) {
+	defer rows.Close()
+
+	var leaderboard []LeaderboardEntry
+	var total int64
+	for rows.Next() {
+		var entry LeaderboardEntry
+		var entryUserID int64
+		var username sql.NullString
+		var previousBalance sql.NullInt64
+		err := rows.Scan(&entry.Rank, &total, &entryUserID, &username, &entry.Name, &entry.Balance, &entry.PreviousRank, &previousBalance)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan leaderboard entry: %w", err)
+		}
+
+		entry.Username = username.String
+		entry.BalanceDisplay = fmt.Sprintf("%d", entry.Balance)
+		if entry.PreviousRank > 0 {
+			entry.RankChange = entry.PreviousRank - entry.Rank
+			entry.BalanceChange = entry.Balance - previousBalance.Int64
+		}
+		entry.IsMe = userID != 0 && entryUserID == userID
+
+		leaderboard = append(leaderboard, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating leaderboard: %w", err)
+	}
+
+	return leaderboard, total, nil
+}
+
+// LeaderboardPeriod is the window a leaderboard ranks over
+type LeaderboardPeriod string
+
+const (
+	// LeaderboardAllTime ranks by current balance
+	LeaderboardAllTime LeaderboardPeriod = "all_time"
+	// LeaderboardWeekly ranks by profit since Monday 00:00 UTC
+	LeaderboardWeekly LeaderboardPeriod = "weekly"
+	// LeaderboardMonthly ranks by profit since the 1st of the month, 00:00 UTC
+	LeaderboardMonthly LeaderboardPeriod = "monthly"
+)
+
+// Since returns when the period's window began at now; the zero time for LeaderboardAllTime
+func (p LeaderboardPeriod) Since(now time.Time) time.Time {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch p {
+	case LeaderboardWeekly:
+		// Go weeks start on Sunday; leaderboard weeks on Monday
+		return today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	case LeaderboardMonthly:
+		return today.AddDate(0, 0, 1-today.Day())
+	}
+	return time.Time{}
+}
+
+// profitSourceTypes are the transactions that count towards profit: bets, shares and dispute
+// bonds, and what they paid back. Bonuses, bailouts, promos, vouchers and adjustments are gifts,
+// except that a promo credit's share of a stake was never the user's to lose, so VOUCHER_CREDIT
+// offsets it.
+var profitSourceTypes = []string{
+	"BET_PLACED", "VOUCHER_CREDIT", "BET_CANCELLED", "WIN_PAYOUT", "REFUND",
+	"SHARES_BOUGHT", "SHARES_SOLD", "SHARES_PAYOUT",
+	"DISPUTE_BOND", "DISPUTE_BOND_REFUND",
+}
+
+// rankedProfitsSQL ranks the users who traded since a time (its only argument) by the profit
+// they made since then (ties by signup order), and counts the ranked users. A stake counts as
+// spent until its market pays out.
+var rankedProfitsSQL = `
+	SELECT ROW_NUMBER() OVER (ORDER BY p.profit DESC, u.id) AS rank,
+	       COUNT(*) OVER () AS total,
+	       u.id AS user_id,
+	       u.username,
+	       u.first_name,
+	       u.balance,
+	       p.profit
+	FROM (
+		SELECT user_id, SUM(amount) AS profit
+		FROM transactions
+		WHERE created_at >= ? AND source_type IN ('` + strings.Join(profitSourceTypes, "', '") + `')
+		GROUP BY user_id
+	) p
+	JOIN users u ON u.id = p.user_id`
+
+// GetProfitLeaderboardPage returns one page of the users ranked by profit since a time, e.g.
+// LeaderboardWeekly.Since(time.Now()), and how many users traded since then
+func GetProfitLeaderboardPage(since time.Time, limit, offset int) ([]LeaderboardEntry, int, error) {
+	rows, err := db.Query(`
+		WITH ranked AS (`+rankedProfitsSQL+`)
+		SELECT rank, total, username, first_name, balance, profit
+		FROM ranked
+		ORDER BY rank
+		LIMIT ? OFFSET ?
+	`, sqlTimestamp(since), limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query profit leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	var leaderboard []LeaderboardEntry
+	var total int
+	for rows.Next() {
+		var entry LeaderboardEntry
+		var username sql.NullString
+		if err := rows.Scan(&entry.Rank, &total, &username, &entry.Name, &entry.Balance, &entry.Profit); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan profit leaderboard entry: %w", err)
+		}
+		entry.Username = username.String
+		entry.BalanceDisplay = fmt.Sprintf("%d", entry.Balance)
+		leaderboard = append(leaderboard, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating profit leaderboard: %w", err)
+	}
+
+	// A page past the end has no rows to read the total from
+	if len(leaderboard) == 0 && offset > 0 {
+		err := db.QueryRow(`SELECT COUNT(*) FROM (`+rankedProfitsSQL+`) AS ranked`, sqlTimestamp(since)).Scan(&total)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to count profit leaderboard: %w", err)
+		}
+	}
+	return leaderboard, total, nil
+}
+
+// LeaderboardMetric is what a leaderboard ranks users by
+type LeaderboardMetric string
+
+const (
+	// MetricBalance ranks by balance, or by profit for a weekly or monthly period
+	MetricBalance LeaderboardMetric = "balance"
+	// MetricWinRate ranks by the percentage of decided bets won, among users with enough of them
+	MetricWinRate LeaderboardMetric = "win_rate"
+	// MetricStreak ranks by the current run of won markets
+	MetricStreak LeaderboardMetric = "streak"
+	// MetricMarketsCreated ranks by how many markets the user created
+	MetricMarketsCreated LeaderboardMetric = "markets_created"
+)
+
+// DefaultWinRateMinBets is how many decided bets a user needs to be ranked by win rate
+const DefaultWinRateMinBets = 5
+
+// metricValuesSQL selects user_id and value for the users each metric ranks, best value first.
+// A bet is decided once its market is finalized with an outcome; refunded markets have none.
+// The win rate query takes the minimum number of decided bets as its only argument.
+var metricValuesSQL = map[LeaderboardMetric]string{
+	MetricWinRate: `
+		SELECT b.user_id,
+		       ROUND(100.0 * SUM(CASE WHEN b.outcome = m.outcome THEN 1 ELSE 0 END) / COUNT(*), 1) AS value,
+		       COUNT(*) AS tiebreak
+		FROM bets b
+		JOIN markets m ON m.id = b.market_id
+		WHERE m.status = 'FINALIZED' AND m.outcome != ''
+		GROUP BY b.user_id
+		HAVING COUNT(*) >= ?`,
+	MetricStreak: `
+		SELECT user_id, current AS value, best AS tiebreak
+		FROM user_streaks
+		WHERE current > 0`,
+	MetricMarketsCreated: `
+		SELECT creator_id AS user_id, COUNT(*) AS value, 0 AS tiebreak
+		FROM markets
+		WHERE hidden = 0
+		GROUP BY creator_id`,
+}
+
+// Valid reports whether m is a known metric
+func (m LeaderboardMetric) Valid() bool {
+	_, ok := metricValuesSQL[m]
+	return ok || m == MetricBalance
+}
+
+// GetMetricLeaderboardPage returns one page of the users ranked by a win rate, streak or
+// markets created metric (ties by the larger sample or best streak, then signup order), and
+// how many users the metric ranks. minBets only applies to MetricWinRate.
+func GetMetricLeaderboardPage(metric LeaderboardMetric, minBets, limit, offset int) ([]LeaderboardEntry, int, error) {
+	values, ok := metricValuesSQL[metric]
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid leaderboard metric: %s", metric)
+	}
+	var args []interface{}
+	if metric == MetricWinRate {
+		args = append(args, minBets)
+	}
+
+	ranked := `
+		SELECT ROW_NUMBER() OVER (ORDER BY v.value DESC, v.tiebreak DESC, u.id) AS rank,
+		       COUNT(*) OVER () AS total,
+		       u.username,
+		       u.first_name,
+		       u.balance,
+		       v.value
+		FROM (` + values + `) v
+		JOIN users u ON u.id = v.user_id`
+	rows, err := db.Query(`
+		WITH ranked AS (`+ranked+`)
+		SELECT rank, total, username, first_name, balance, value
+		FROM ranked
+		ORDER BY rank
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query %s leaderboard: %w", metric, err)
+	}
+	defer rows.Close()
+
+	var leaderboard []LeaderboardEntry
+	var total int
+	for rows.Next() {
+		var entry LeaderboardEntry
+		var username sql.NullString
+		var value float64
+		if err := rows.Scan(&entry.Rank, &total, &username, &entry.Name, &entry.Balance, &value); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan %s leaderboard entry: %w", metric, err)
+		}
+		entry.Username = username.String
+		entry.BalanceDisplay = fmt.Sprintf("%d", entry.Balance)
+		entry.Value = &value
+		leaderboard = append(leaderboard, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating %s leaderboard: %w", metric, err)
+	}
+
+	// A page past the end has no rows to read the total from
+	if len(leaderboard) == 0 && offset > 0 {
+		if err := db.QueryRow(`SELECT COUNT(*) FROM (`+ranked+`) AS ranked`, args...).Scan(&total); err != nil {
+			return nil, 0, fmt.Errorf("failed to count %s leaderboard: %w", metric, err)
+		}
+	}
+	return leaderboard, total, nil
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLeaderboardPageAndPosition(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	// Seven users with balances 7000 (rank 1) down to 1000 (rank 7)
+	var ids []int64
+	for i := int64(1); i <= 7; i++ {
+		user, _ := CreateUser(4500+i, "", "User")
+		db.Exec(`UPDATE users SET balance = ? WHERE id = ?`, (8-i)*1000, user.ID)
+		ids = append(ids, user.ID)
+	}
+
+	page, total, err := GetLeaderboardPage(2, 3)
+	if err != nil {
+		t.Fatalf("GetLeaderboardPage failed: %v", err)
+	}
+	if total != 7 || len(page) != 2 || page[0].Rank != 4 || page[1].Rank != 5 {
+		t.Errorf("Expected ranks 4 and 5 of 7, got %+v (total %d)", page, total)
+	}
+	if page, total, _ := GetLeaderboardPage(5, 10); len(page) != 0 || total != 7 {
+		t.Errorf("Expected an empty page past the end, got %d entries (total %d)", len(page), total)
+	}
+
+	position, err := GetLeaderboardPosition(ids[3], 2)
+	if err != nil || position == nil {
+		t.Fatalf("GetLeaderboardPosition failed: %v", err)
+	}
+	if position.Rank != 4 || position.Total != 7 || len(position.Entries) != 5 {
+		t.Fatalf("Expected rank 4 of 7 with 5 entries, got %+v", position)
+	}
+	if position.Entries[0].Rank != 2 || position.Entries[4].Rank != 6 || !position.Entries[2].IsMe || position.Entries[1].IsMe {
+		t.Errorf("Expected ranks 2-6 with the user in the middle, got %+v", position.Entries)
+	}
+
+	// The top and bottom users have fewer neighbours
+	if top, _ := GetLeaderboardPosition(ids[0], 2); top == nil || top.Rank != 1 || len(top.Entries) != 3 {
+		t.Errorf("Expected the leader with 2 neighbours below, got %+v", top)
+	}
+	if bottom, _ := GetLeaderboardPosition(ids[6], 2); bottom == nil || bottom.Rank != 7 || len(bottom.Entries) != 3 {
+		t.Errorf("Expected the last user with 2 neighbours above, got %+v", bottom)
+	}
+	if unknown, err := GetLeaderboardPosition(9999, 2); err != nil || unknown != nil {
+		t.Errorf("Expected nil for an unknown user, got %+v, %v", unknown, err)
+	}
+}
+
+func TestLeaderboardPeriodSince(t *testing.T) {
+	tests := []struct {
+		period LeaderboardPeriod
+		now    time.Time
+		want   time.Time
+	}{
+		// Monday itself starts the week, Sunday night still belongs to the previous one
+		{LeaderboardWeekly, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{LeaderboardWeekly, time.Date(2026, 3, 8, 23, 59, 59, 0, time.UTC), time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{LeaderboardWeekly, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)},
+		// A week can start in the previous month, and times are read in UTC
+		{LeaderboardWeekly, time.Date(2026, 4, 1, 1, 0, 0, 0, time.FixedZone("UTC+3", 3*3600)), time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC)},
+		{LeaderboardMonthly, time.Date(2026, 3, 31, 23, 59, 59, 0, time.UTC), time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{LeaderboardMonthly, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{LeaderboardAllTime, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := tt.period.Since(tt.now); !got.Equal(tt.want) {
+			t.Errorf("%s at %s: expected %s, got %s", tt.period, tt.now, tt.want, got)
+		}
+	}
+}
+
+func TestProfitLeaderboard(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	since := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	record := func(userID, amount int64, sourceType, at string) {
+		if _, err := db.Exec(`INSERT INTO transactions (user_id, amount, source_type, created_at) VALUES (?, ?, ?, ?)`, userID, amount, sourceType, at); err != nil {
+			t.Fatalf("Failed to record transaction: %v", err)
+		}
+	}
+
+	winner, _ := CreateUser(4601, "winner", "Winner")
+	loser, _ := CreateUser(4602, "loser", "Loser")
+	gifted, _ := CreateUser(4603, "gifted", "Gifted")
+	idle, _ := CreateUser(4604, "idle", "Idle")
+
+	// Only the window's trades count: the bet just before the boundary does not
+	record(winner.ID, -100, "BET_PLACED", "2026-03-01 23:59:59")
+	record(winner.ID, -100, "BET_PLACED", "2026-03-02 00:00:00")
+	record(winner.ID, 300, "WIN_PAYOUT", "2026-03-04 12:00:00")
+	record(loser.ID, -50, "BET_PLACED", "2026-03-03 09:00:00")
+	record(loser.ID, 1000, "BAILOUT", "2026-03-03 10:00:00")
+	// Gifts are not profit
+	record(gifted.ID, 500, "PROMO", "2026-03-03 10:00:00")
+	record(gifted.ID, 20, "SHARES_SOLD", "2026-03-03 11:00:00")
+	record(idle.ID, -10, "BET_PLACED", "2026-02-20 10:00:00")
+
+	entries, total, err := GetProfitLeaderboardPage(since, 10, 0)
+	if err != nil {
+		t.Fatalf("GetProfitLeaderboardPage failed: %v", err)
+	}
+	if total != 3 || len(entries) != 3 {
+		t.Fatalf("Expected the 3 users who traded in the window, got %+v (total %d)", entries, total)
+	}
+	if entries[0].Name != "Winner" || entries[0].Profit != 200 || entries[0].Rank != 1 {
+		t.Errorf("Expected the winner first with 200, got %+v", entries[0])
+	}
+	if entries[1].Name != "Gifted" || entries[1].Profit != 20 || entries[2].Name != "Loser" || entries[2].Profit != -50 {
+		t.Errorf("Unexpected ranking %+v", entries)
+	}
+
+	if page, total, _ := GetProfitLeaderboardPage(since, 1, 1); len(page) != 1 || page[0].Name != "Gifted" || total != 3 {
+		t.Errorf("Expected the second user on page 2 of 3, got %+v (total %d)", page, total)
+	}
+	if page, total, _ := GetProfitLeaderboardPage(since, 10, 5); len(page) != 0 || total != 3 {
+		t.Errorf("Expected an empty page past the end of 3, got %+v (total %d)", page, total)
+	}
+}
+
+func TestProfitLeaderboardVoucherFundedBet(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator, _ := CreateUser(4611, "creator", "Creator")
+	bettor, _ := CreateUser(4612, "bettor", "Bettor")
+	market, _ := CreateMarket(creator.ID, "Will the voucher pay?", time.Now().Add(time.Hour))
+	GrantVoucher(bettor.ID, VoucherCredit, 30, time.Time{}, 0)
+	if err := PlaceBet(context.Background(), bettor.ID, market.ID, "YES", 100); err != nil {
+		t.Fatalf("PlaceBet failed: %v", err)
+	}
+
+	// The bettor only paid 70 of the 100 staked
+	entries, _, err := GetProfitLeaderboardPage(time.Now().Add(-time.Hour), 10, 0)
+	if err != nil {
+		t.Fatalf("GetProfitLeaderboardPage failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name != "Bettor" || entries[0].Profit != -70 {
+		t.Errorf("Expected the bettor at -70, got %+v", entries)
+	}
+}
+
+func TestMetricLeaderboards(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	sharp, _ := CreateUser(4701, "sharp", "Sharp")
+	busy, _ := CreateUser(4702, "busy", "Busy")
+	rookie, _ := CreateUser(4703, "rookie", "Rookie")
+
+	finalized := func(creatorID int64, outcome string) int64 {
+		market, err := CreateMarket(creatorID, "Will this settle?", time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to create market: %v", err)
+		}
+		if _, err := db.Exec(`UPDATE markets SET status = 'FINALIZED', outcome = ? WHERE id = ?`, outcome, market.ID); err != nil {
+			t.Fatalf("Failed to finalize market: %v", err)
+		}
+		return market.ID
+	}
+	bet := func(userID, marketID int64, outcome string) {
+		if _, err := db.Exec(`INSERT INTO bets (user_id, market_id, outcome, amount) VALUES (?, ?, ?, 10)`, userID, marketID, outcome); err != nil {
+			t.Fatalf("Failed to place bet: %v", err)
+		}
+	}
+
+	// sharp wins 2 of 2, busy wins 3 of 4, rookie wins 1 of 1; a refunded market decides nothing
+	for i := 0; i < 2; i++ {
+		bet(sharp.ID, finalized(busy.ID, "YES"), "YES")
+	}
+	for _, outcome := range []string{"YES", "YES", "YES", "NO"} {
+		bet(busy.ID, finalized(busy.ID, "YES"), outcome)
+	}
+	bet(rookie.ID, finalized(sharp.ID, "NO"), "NO")
+	bet(rookie.ID, finalized(sharp.ID, ""), "YES")
+
+	entries, total, err := GetMetricLeaderboardPage(MetricWinRate, 2, 10, 0)
+	if err != nil {
+		t.Fatalf("GetMetricLeaderboardPage failed: %v", err)
+	}
+	if total != 2 || len(entries) != 2 || entries[0].Name != "Sharp" || *entries[0].Value != 100 || *entries[1].Value != 75 {
+		t.Errorf("Expected Sharp at 100%% then Busy at 75%%, got %+v (total %d)", entries, total)
+	}
+	if _, total, _ := GetMetricLeaderboardPage(MetricWinRate, 1, 10, 0); total != 3 {
+		t.Errorf("Expected a lower minimum to rank the rookie too, got %d", total)
+	}
+
+	db.Exec(`INSERT INTO user_streaks (user_id, current, best) VALUES (?, 3, 3), (?, 3, 5), (?, -2, 1)`, sharp.ID, busy.ID, rookie.ID)
+	entries, total, _ = GetMetricLeaderboardPage(MetricStreak, 0, 10, 0)
+	if total != 2 || entries[0].Name != "Busy" || *entries[0].Value != 3 || entries[1].Name != "Sharp" {
+		t.Errorf("Expected the tie broken by best streak and losing streaks left out, got %+v", entries)
+	}
+
+	entries, total, _ = GetMetricLeaderboardPage(MetricMarketsCreated, 0, 1, 1)
+	if total != 2 || len(entries) != 1 || entries[0].Name != "Sharp" || *entries[0].Value != 2 || entries[0].Rank != 2 {
+		t.Errorf("Expected Sharp second with 2 markets, got %+v (total %d)", entries, total)
+	}
+	if page, total, _ := GetMetricLeaderboardPage(MetricMarketsCreated, 0, 10, 5); len(page) != 0 || total != 2 {
+		t.Errorf("Expected an empty page past the end of 2, got %+v (total %d)", page, total)
+	}
+
+	if _, _, err := GetMetricLeaderboardPage("luck", 0, 10, 0); err == nil {
+		t.Error("Expected an unknown metric to be rejected")
+	}
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"predictionbot/internal/statemachine"
+)
+
+// LockMode is what stops a market taking bets
+type LockMode string
+
+const (
+	// LockModeDeadline markets lock when they expire
+	LockModeDeadline LockMode = "DEADLINE"
+	// LockModeManual markets lock when their creator says so
+	LockModeManual LockMode = "MANUAL"
+	// LockModeOracle markets lock when an oracle signals the event has completed
+	LockModeOracle LockMode = "ORACLE"
+)
+
+// ParseLockMode parses a lock mode case-insensitively; an empty string is LockModeDeadline
+func ParseLockMode(value string) (LockMode, error) {
+	switch mode := LockMode(strings.ToUpper(strings.TrimSpace(value))); mode {
+	case "":
+		return LockModeDeadline, nil
+	case LockModeDeadline, LockModeManual, LockModeOracle:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid lock mode: %s", value)
+	}
+}
+
+// EventLocked reports whether the market waits for a lock signal rather than its deadline.
+// expires_at is then only the latest time it may stay open.
+func (m *Market) EventLocked() bool {
+	return m.LockMode == LockModeManual || m.LockMode == LockModeOracle
+}
+
+// LockMarket locks an open market ahead of its deadline.
+// It returns false when the market was not open for bets.
+func LockMarket(marketID int64) (bool, error) {
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	change := MarketStatusChange{
+		To:   MarketStatusLocked,
+		From: []MarketStatus{MarketStatusActive, MarketStatusLastCall},
+		Set:  "locked_at = CURRENT_TIMESTAMP",
+	}
+	_, err = UpdateMarketStatusTx(ctx, tx, marketID, change)
+	var transition *statemachine.TransitionError
+	if errors.As(err, &transition) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock market: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
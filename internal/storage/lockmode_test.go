@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLockMode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected LockMode
+		wantErr  bool
+	}{
+		{"", LockModeDeadline, false},
+		{"manual", LockModeManual, false},
+		{" ORACLE ", LockModeOracle, false},
+		{"whenever", "", true},
+	}
+
+	for _, tt := range tests {
+		mode, err := ParseLockMode(tt.input)
+		if (err != nil) != tt.wantErr || mode != tt.expected {
+			t.Errorf("ParseLockMode(%q) = %q, %v", tt.input, mode, err)
+		}
+	}
+}
+
+func TestLockMarket(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator, _ := CreateUser(4001, "creator", "Creator")
+	market, err := CreateMarketWithOptions(creator.ID, "Will the final whistle blow today?", time.Now().Add(time.Hour), MarketOptions{LockMode: LockModeOracle})
+	if err != nil {
+		t.Fatalf("CreateMarketWithOptions failed: %v", err)
+	}
+	if market.LockMode != LockModeOracle || !market.EventLocked() {
+		t.Fatalf("Expected an oracle-locked market, got %+v", market)
+	}
+	if plain, _ := CreateMarket(creator.ID, "Will the default stay a deadline?", time.Now().Add(time.Hour)); plain.LockMode != LockModeDeadline || plain.EventLocked() {
+		t.Errorf("Expected a deadline market by default, got %+v", plain)
+	}
+
+	if locked, err := LockMarket(market.ID); err != nil || !locked {
+		t.Fatalf("Expected market to lock, got %t (err=%v)", locked, err)
+	}
+	if m, _ := GetMarketByID(market.ID); m.Status != MarketStatusLocked {
+		t.Errorf("Expected LOCKED, got %s", m.Status)
+	}
+	if lockedAt, err := GetMarketLockedAt(market.ID); err != nil || time.Since(lockedAt) > time.Minute {
+		t.Errorf("Expected the lock time to be recorded, got %v (err=%v)", lockedAt, err)
+	}
+	if locked, err := LockMarket(market.ID); err != nil || locked {
+		t.Errorf("Expected a second lock to do nothing, got %t (err=%v)", locked, err)
+	}
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportableTables lists the tables that can be exported, in dependency order
+var ExportableTables = []string{"users", "transactions", "markets", "bets", "audit_log", "market_transfers", "user_roles", "market_tags", "market_snoozes", "user_streaks", "market_translations", "content", "resolution_cosigns", "community_proposals", "community_votes", "group_digests", "payout_escrow", "account_merges", "market_makers", "share_positions", "share_trades", "user_onboarding", "bet_cancellations", "vouchers", "promo_codes", "promo_redemptions", "balance_snapshots", "disputes", "sports_fixtures", "weather_markets", "market_oracles", "pool_snapshots", "odds_alerts"}
+
+// BackupDB writes a consistent copy of the open database to destPath.
+// It refuses to overwrite an existing file.
+func BackupDB(ctx context.Context, destPath string) error {
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if usingPostgres {
+		return fmt.Errorf("backup copies SQLite files only: back up PostgreSQL with pg_dump")
+	}
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("backup destination %s already exists", destPath)
+	}
+
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, destPath); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// ExportTable writes every row of table to w as "csv" (with a header row) or "json" (an array of objects)
+func ExportTable(ctx context.Context, w io.Writer, table, format string) error {
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if !isExportableTable(table) {
+		return fmt.Errorf("invalid table: %s", table)
+	}
+	if format != "csv" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use csv or json)", format)
+	}
+
+	// table is checked against the allowlist above, so it is safe to interpolate. PostgreSQL
+	// has no rowid; every table's first column is its id or leads its primary key.
+	order := "rowid"
+	if usingPostgres {
+		order = "1"
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`SELECT * FROM %s ORDER BY %s`, table, order))
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	var records []map[string]interface{}
+	var csvWriter *csv.Writer
+	if format == "csv" {
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write(columns); err != nil {
+			return fmt.Errorf("failed to write header: %w", err)
+		}
+	} else {
+		records = []map[string]interface{}{}
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return fmt.Errorf("failed to scan %s row: %w", table, err)
+		}
+
+		if format == "csv" {
+			record := make([]string, len(columns))
+			for i, v := range values {
+				record[i] = formatExportValue(v)
+			}
+			if err := csvWriter.Write(record); err != nil {
+				return fmt.Errorf("failed to write row: %w", err)
+			}
+			continue
+		}
+
+		record := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			if b, ok := values[i].([]byte); ok {
+				record[col] = string(b)
+			} else {
+				record[col] = values[i]
+			}
+		}
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating %s: %w", table, err)
+	}
+
+	if format == "csv" {
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// IsBusyError reports whether err is lock contention: SQLITE_BUSY / SQLITE_LOCKED, or a
+// PostgreSQL serialization failure, deadlock or lock timeout
+func IsBusyError(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch postgresErrorCode(err) {
+	case "40001", "40P01", "55P03":
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "SQLITE_BUSY") ||
+		strings.Contains(msg, "SQLITE_LOCKED") ||
+		strings.Contains(msg, "database is locked")
+}
+
+// isExportableTable reports whether table is in ExportableTables
+func isExportableTable(table string) bool {
+	for _, t := range ExportableTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+// formatExportValue renders a scanned database value for CSV output
+func formatExportValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(val)
+	case string:
+		return val
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case time.Time:
+		return val.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupDB(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	if usingPostgres {
+		if err := BackupDB(context.Background(), filepath.Join(t.TempDir(), "backup.db")); err == nil {
+			t.Error("Expected a PostgreSQL backup to be refused")
+		}
+		return
+	}
+
+	ctx := context.Background()
+	CreateUser(4001, "backup", "Backup")
+
+	dest := filepath.Join(t.TempDir(), "backup.db")
+	if err := BackupDB(ctx, dest); err != nil {
+		t.Fatalf("BackupDB failed: %v", err)
+	}
+
+	copyDB, err := sql.Open("sqlite", dest)
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	defer copyDB.Close()
+
+	var count int
+	if err := copyDB.QueryRow(`SELECT COUNT(*) FROM users WHERE telegram_id = 4001`).Scan(&count); err != nil {
+		t.Fatalf("Failed to query backup: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 user in backup, got %d", count)
+	}
+
+	if err := BackupDB(ctx, dest); err == nil {
+		t.Error("Expected error when backup destination already exists")
+	}
+}
+
+func TestExportTable(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	CreateUser(4001, "alice", "Alice")
+	CreateUser(4002, "bob", "Bob")
+
+	var buf bytes.Buffer
+	if err := ExportTable(ctx, &buf, "users", "csv"); err != nil {
+		t.Fatalf("ExportTable csv failed: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse csv: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %d records", len(records))
+	}
+
+	buf.Reset()
+	if err := ExportTable(ctx, &buf, "users", "json"); err != nil {
+		t.Fatalf("ExportTable json failed: %v", err)
+	}
+	var users []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &users); err != nil {
+		t.Fatalf("Failed to parse json: %v", err)
+	}
+	if len(users) != 2 || users[0]["username"] != "alice" {
+		t.Errorf("Unexpected json export: %+v", users)
+	}
+
+	if err := ExportTable(ctx, &buf, "sqlite_master", "csv"); err == nil {
+		t.Error("Expected error for table outside the allowlist")
+	}
+	if err := ExportTable(ctx, &buf, "users", "xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestIsBusyError(t *testing.T) {
+	busy := fmt.Errorf("failed to place bet: %w", errors.New("database is locked (5) (SQLITE_BUSY)"))
+	if !IsBusyError(busy) {
+		t.Error("Expected wrapped SQLITE_BUSY to be detected")
+	}
+	if IsBusyError(errors.New("insufficient funds")) {
+		t.Error("Expected unrelated error not to be detected as busy")
+	}
+	if IsBusyError(nil) {
+		t.Error("Expected nil not to be detected as busy")
+	}
+}
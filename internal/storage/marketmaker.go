@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// PricingMode is how a market prices its bets
+type PricingMode string
+
+const (
+	// PricingParimutuel markets split the losing pool between the winning bets
+	PricingParimutuel PricingMode = "PARIMUTUEL"
+	// PricingMarketMaker markets sell shares at prices set by an automated market maker
+	// (logarithmic market scoring rule); each winning share pays out 1
+	PricingMarketMaker PricingMode = "LMSR"
+)
+
+const (
+	// DefaultLiquidity is the market maker's liquidity parameter when the creator picks none
+	DefaultLiquidity int64 = 100
+	// MaxLiquidity caps the liquidity parameter, and so the subsidy, of a single market
+	MaxLiquidity int64 = 10000
+)
+
+// MarketMakerSubsidy is the market maker's worst-case loss with liquidity b, b × ln 2 rounded up.
+// The creator pays it when creating the market, so the market maker never pays out money
+// nobody put in; finalization refunds what it did not lose.
+func MarketMakerSubsidy(liquidity int64) int64 {
+	return int64(math.Ceil(float64(liquidity) * math.Ln2))
+}
+
+// ParsePricingMode parses a pricing mode case-insensitively; an empty string is PricingParimutuel
+func ParsePricingMode(value string) (PricingMode, error) {
+	switch mode := PricingMode(strings.ToUpper(strings.TrimSpace(value))); mode {
+	case "":
+		return PricingParimutuel, nil
+	case PricingParimutuel, PricingMarketMaker:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid pricing mode: %s", value)
+	}
+}
+
+// MarketMaker is the state of a market's automated market maker
+type MarketMaker struct {
+	MarketID int64 `json:"market_id"`
+	// Liquidity is the LMSR liquidity parameter b: the higher, the less each trade moves the price
+	Liquidity int64 `json:"liquidity"`
+	// SharesYes and SharesNo are the shares outstanding on each side
+	SharesYes int64 `json:"shares_yes"`
+	SharesNo  int64 `json:"shares_no"`
+	// Subsidy is what the creator paid to cover the market maker's worst-case loss; 0 for
+	// markets the house subsidized
+	Subsidy int64 `json:"subsidy"`
+}
+
+// ShareTrade is one purchase or sale of shares. Shares and Amount are positive for a
+// purchase (shares bought, price paid) and negative for a sale (shares sold, price received).
+type ShareTrade struct {
+	ID        int64     `json:"id"`
+	MarketID  int64     `json:"market_id"`
+	UserID    int64     `json:"user_id"`
+	Outcome   string    `json:"outcome"`
+	Shares    int64     `json:"shares"`
+	Amount    int64     `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ShareHolding is what one user (internal ID) holds on a market maker market
+type ShareHolding struct {
+	UserID    int64 `json:"-"`
+	SharesYes int64 `json:"shares_yes"`
+	SharesNo  int64 `json:"shares_no"`
+	// Spent is what the shares cost net of sales; negative when sales made more than purchases
+	Spent int64 `json:"spent"`
+}
+
+// GetMarketMaker returns a market's market maker, or nil when the market is parimutuel
+func GetMarketMaker(marketID int64) (*MarketMaker, error) {
+	mm := MarketMaker{MarketID: marketID}
+	err := db.QueryRow(`
+		SELECT liquidity, shares_yes, shares_no, subsidy FROM market_makers WHERE market_id = ?
+	`, marketID).Scan(&mm.Liquidity, &mm.SharesYes, &mm.SharesNo, &mm.Subsidy)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market maker: %w", err)
+	}
+	return &mm, nil
+}
+
+// GetTradableMarketMakerTx returns the market maker of a market that is open for trading, inside
+// a transaction. Markets are open for trading while they take bets.
+func GetTradableMarketMakerTx(ctx context.Context, tx *sql.Tx, marketID int64) (*MarketMaker, error) {
+	var status string
+	var expiresAt time.Time
+	var hidden bool
+	err := tx.QueryRowContext(ctx, `SELECT status, expires_at, hidden FROM markets WHERE id = ?`, marketID).Scan(&status, &expiresAt, &hidden)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("market not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+	if status != string(MarketStatusActive) && status != string(MarketStatusLastCall) {
+		return nil, fmt.Errorf("market is not active: status is %s", status)
+	}
+	if hidden {
+		return nil, fmt.Errorf("market is not active: hidden by a moderator")
+	}
+	if time.Now().After(expiresAt) {
+		return nil, fmt.Errorf("market has expired")
+	}
+
+	mm := MarketMaker{MarketID: marketID}
+	err = tx.QueryRowContext(ctx, `
+		SELECT liquidity, shares_yes, shares_no, subsidy FROM market_makers WHERE market_id = ?
+	`, marketID).Scan(&mm.Liquidity, &mm.SharesYes, &mm.SharesNo, &mm.Subsidy)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("invalid trade: market uses parimutuel betting, place a bet instead")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market maker: %w", err)
+	}
+	return &mm, nil
+}
+
+// RecordShareTradeTx applies a priced trade inside a transaction: it moves the balance, the
+// user's position and the market maker's outstanding shares, and logs the trade. It fails
+// on insufficient funds or shares.
+func RecordShareTradeTx(ctx context.Context, tx *sql.Tx, trade ShareTrade) (*ShareTrade, error) {
+	if trade.Outcome != string(OutcomeYes) && trade.Outcome != string(OutcomeNo) {
+		return nil, fmt.Errorf("invalid outcome: must be 'YES' or 'NO'")
+	}
+	if trade.Shares == 0 {
+		return nil, fmt.Errorf("invalid trade: no shares")
+	}
+
+	var balance int64
+	err := tx.QueryRowContext(ctx, `SELECT balance FROM users WHERE id = ?`, trade.UserID).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user balance: %w", err)
+	}
+	if balance < trade.Amount {
+		return nil, fmt.Errorf("insufficient funds: have %d, need %d", balance, trade.Amount)
+	}
+
+	var held int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT shares FROM share_positions WHERE market_id = ? AND user_id = ? AND outcome = ?
+	`, trade.MarketID, trade.UserID, trade.Outcome).Scan(&held)
+	hasPosition := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get position: %w", err)
+	}
+	if held+trade.Shares < 0 {
+		return nil, fmt.Errorf("insufficient shares: have %d %s, selling %d", held, trade.Outcome, -trade.Shares)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET balance = balance - ? WHERE id = ?`, trade.Amount, trade.UserID); err != nil {
+		return nil, fmt.Errorf("failed to update balance: %w", err)
+	}
+
+	if hasPosition {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE share_positions SET shares = shares + ? WHERE market_id = ? AND user_id = ? AND outcome = ?
+		`, trade.Shares, trade.MarketID, trade.UserID, trade.Outcome)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO share_positions (market_id, user_id, outcome, shares) VALUES (?, ?, ?, ?)
+		`, trade.MarketID, trade.UserID, trade.Outcome, trade.Shares)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update position: %w", err)
+	}
+
+	// outcome is validated above, so the column name is safe to interpolate
+	column := "shares_" + strings.ToLower(trade.Outcome)
+	_, err = tx.ExecContext(ctx, `
+		UPDATE market_makers SET `+column+` = `+column+` + ?, updated_at = CURRENT_TIMESTAMP WHERE market_id = ?
+	`, trade.Shares, trade.MarketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update market maker: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO share_trades (market_id, user_id, outcome, shares, amount)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id
+	`, trade.MarketID, trade.UserID, trade.Outcome, trade.Shares, trade.Amount).Scan(&trade.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert trade: %w", err)
+	}
+
+	sourceType := "SHARES_BOUGHT"
+	description := fmt.Sprintf("Bought %d %s shares on market #%d", trade.Shares, trade.Outcome, trade.MarketID)
+	if trade.Shares < 0 {
+		sourceType = "SHARES_SOLD"
+		description = fmt.Sprintf("Sold %d %s shares on market #%d", -trade.Shares, trade.Outcome, trade.MarketID)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+		VALUES (?, ?, ?, ?, ?)
+	`, trade.UserID, -trade.Amount, sourceType, description, trade.MarketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log transaction: %w", err)
+	}
+
+	trade.CreatedAt = time.Now()
+	return &trade, nil
+}
+
+// GetShareHolding returns what a user (internal ID) holds on a market maker market
+func GetShareHolding(marketID, userID int64) (ShareHolding, error) {
+	holding := ShareHolding{UserID: userID}
+	err := db.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN outcome = 'YES' THEN shares ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN outcome = 'NO' THEN shares ELSE 0 END), 0),
+		       COALESCE(SUM(amount), 0)
+		FROM share_trades
+		WHERE market_id = ? AND user_id = ?
+	`, marketID, userID).Scan(&holding.SharesYes, &holding.SharesNo, &holding.Spent)
+	if err != nil {
+		return holding, fmt.Errorf("failed to get holding: %w", err)
+	}
+	return holding, nil
+}
+
+// ListShareHoldingsTx returns every trader's holding on a market maker market, in the order
+// they first traded, inside a transaction
+func ListShareHoldingsTx(ctx context.Context, tx *sql.Tx, marketID int64) ([]ShareHolding, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT user_id,
+		       COALESCE(SUM(CASE WHEN outcome = 'YES' THEN shares ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN outcome = 'NO' THEN shares ELSE 0 END), 0),
+		       COALESCE(SUM(amount), 0)
+		FROM share_trades
+		WHERE market_id = ?
+		GROUP BY user_id
+		ORDER BY MIN(id)
+	`, marketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holdings: %w", err)
+	}
+	defer rows.Close()
+
+	var holdings []ShareHolding
+	for rows.Next() {
+		var h ShareHolding
+		if err := rows.Scan(&h.UserID, &h.SharesYes, &h.SharesNo, &h.Spent); err != nil {
+			return nil, fmt.Errorf("failed to scan holding: %w", err)
+		}
+		holdings = append(holdings, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating holdings: %w", err)
+	}
+	return holdings, nil
+}
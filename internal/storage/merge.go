@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MergedBet is a bet affected by a market merge
+type MergedBet struct {
+	BetID   int64  `json:"bet_id"`
+	UserID  int64  `json:"user_id"`
+	Outcome string `json:"outcome"`
+	Amount  int64  `json:"amount"`
+}
+
+// MergeResult describes what happened to the bets of a merged duplicate market
+type MergeResult struct {
+	SourceID int64       `json:"source_id"`
+	TargetID int64       `json:"target_id"`
+	Moved    []MergedBet `json:"moved"`
+	Refunded []MergedBet `json:"refunded"`
+}
+
+// MergeMarkets moves the bets of a duplicate market (source) into the surviving market (target)
+// and closes the duplicate with status MERGED. Bets placed after the target's deadline could not
+// have been placed on the target, so they are refunded instead of moved.
+// actorID is the internal ID of the admin performing the merge, recorded in the audit log.
+func MergeMarkets(ctx context.Context, sourceID, targetID, actorID int64) (*MergeResult, error) {
+	if sourceID == targetID {
+		return nil, fmt.Errorf("invalid merge: cannot merge a market into itself")
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sourceStatus, targetStatus string
+	var targetExpiresAt time.Time
+	err = tx.QueryRowContext(ctx, `SELECT status FROM markets WHERE id = ?`, sourceID).Scan(&sourceStatus)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("market not found: #%d", sourceID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+	err = tx.QueryRowContext(ctx, `SELECT status, expires_at FROM markets WHERE id = ?`, targetID).Scan(&targetStatus, &targetExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("market not found: #%d", targetID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+
+	// Only unresolved markets can be merged, otherwise payouts could be applied twice
+	for _, status := range []string{sourceStatus, targetStatus} {
+		if status != string(MarketStatusActive) && status != string(MarketStatusLastCall) && status != string(MarketStatusLocked) {
+			return nil, fmt.Errorf("market cannot be merged: status is %s", status)
+		}
+	}
+
+	// Shares are priced by one market maker, so they cannot move to another market
+	var makers int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM market_makers WHERE market_id IN (?, ?)`, sourceID, targetID).Scan(&makers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market maker: %w", err)
+	}
+	if makers > 0 {
+		return nil, fmt.Errorf("market cannot be merged: it is priced by a market maker")
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, user_id, outcome, amount, placed_at
+		FROM bets
+		WHERE market_id = ?
+		ORDER BY id
+	`, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bets: %w", err)
+	}
+
+	result := &MergeResult{SourceID: sourceID, TargetID: targetID}
+	for rows.Next() {
+		var b MergedBet
+		var placedAt time.Time
+		if err := rows.Scan(&b.BetID, &b.UserID, &b.Outcome, &b.Amount, &placedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan bet: %w", err)
+		}
+		if placedAt.After(targetExpiresAt) {
+			result.Refunded = append(result.Refunded, b)
+		} else {
+			result.Moved = append(result.Moved, b)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bets: %w", err)
+	}
+
+	for _, b := range result.Moved {
+		_, err = tx.ExecContext(ctx, `UPDATE bets SET market_id = ? WHERE id = ?`, targetID, b.BetID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to move bet %d: %w", b.BetID, err)
+		}
+	}
+
+	// Refunded bets stay on the duplicate so they show up as REFUNDED in the bettor's history
+	for _, b := range result.Refunded {
+		_, err = tx.ExecContext(ctx, `UPDATE users SET balance = balance + ? WHERE id = ?`, b.Amount, b.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to refund user %d: %w", b.UserID, err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+			VALUES (?, ?, 'REFUND', ?, ?)
+		`, b.UserID, b.Amount, fmt.Sprintf("Refund for bet #%d on market #%d (merged into market #%d)", b.BetID, sourceID, targetID), sourceID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to log refund transaction: %w", err)
+		}
+	}
+
+	if _, err := UpdateMarketStatusTx(ctx, tx, sourceID, MarketStatusChange{To: MarketStatusMerged}); err != nil {
+		return nil, err
+	}
+
+	details := fmt.Sprintf("source=%d target=%d moved=%d refunded=%d", sourceID, targetID, len(result.Moved), len(result.Refunded))
+	if err := logAuditTx(ctx, tx, actorID, "market_merged", AuditEntityMarket, sourceID, details); err != nil {
+		return nil, err
+	}
+	if err := logAuditTx(ctx, tx, actorID, "market_merged_into", AuditEntityMarket, targetID, details); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMergeMarkets(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	admin, _ := CreateUser(5001, "admin", "Admin")
+	early, _ := CreateUser(5002, "early", "Early")
+	late, _ := CreateUser(5003, "late", "Late")
+
+	target, _ := CreateMarket(admin.ID, "Will it snow on Friday?", time.Now().Add(2*time.Hour))
+	source, _ := CreateMarket(admin.ID, "Will it snow on Friday?", time.Now().Add(4*time.Hour))
+
+	if err := PlaceBet(ctx, early.ID, source.ID, "YES", 100); err != nil {
+		t.Fatalf("PlaceBet failed: %v", err)
+	}
+	if err := PlaceBet(ctx, late.ID, source.ID, "NO", 200); err != nil {
+		t.Fatalf("PlaceBet failed: %v", err)
+	}
+
+	// Pretend the early bet predates the target's deadline and the late bet came after it
+	deadline := time.Now().Add(-time.Hour).UTC()
+	db.Exec(`UPDATE markets SET expires_at = ? WHERE id = ?`, deadline, target.ID)
+	db.Exec(`UPDATE bets SET placed_at = ? WHERE user_id = ?`, deadline.Add(-time.Hour), early.ID)
+
+	result, err := MergeMarkets(ctx, source.ID, target.ID, admin.ID)
+	if err != nil {
+		t.Fatalf("MergeMarkets failed: %v", err)
+	}
+	if len(result.Moved) != 1 || result.Moved[0].UserID != early.ID {
+		t.Errorf("Expected early bet to move, got %+v", result.Moved)
+	}
+	if len(result.Refunded) != 1 || result.Refunded[0].UserID != late.ID {
+		t.Errorf("Expected late bet to be refunded, got %+v", result.Refunded)
+	}
+
+	yes, no, _ := GetPoolTotals(target.ID)
+	if yes != 100 || no != 0 {
+		t.Errorf("Expected target pools 100/0, got %d/%d", yes, no)
+	}
+
+	refunded, _ := GetUserByID(late.ID)
+	if refunded.Balance != WelcomeBonusAmount {
+		t.Errorf("Expected late bettor balance %d after refund, got %d", WelcomeBonusAmount, refunded.Balance)
+	}
+
+	closed, _ := GetMarketByID(source.ID)
+	if closed.Status != MarketStatusMerged {
+		t.Errorf("Expected source status MERGED, got %s", closed.Status)
+	}
+
+	// A merged market cannot be merged again
+	if _, err := MergeMarkets(ctx, source.ID, target.ID, admin.ID); err == nil {
+		t.Error("Expected error when merging an already merged market")
+	}
+	if _, err := MergeMarkets(ctx, target.ID, target.ID, admin.ID); err == nil {
+		t.Error("Expected error when merging a market into itself")
+	}
+}
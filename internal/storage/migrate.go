@@ -0,0 +1,317 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema changes are numbered migrations in migrations/: NNNN_name.up.sql applies a change and
+// NNNN_name.down.sql reverts it. Applied versions are recorded in schema_migrations. Never edit
+// a migration that has shipped; add the next number instead. migrations/postgres/ holds the
+// same migrations written for PostgreSQL; every change is added to both.
+//
+//go:embed migrations/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied to the open database
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+const schemaMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at %s DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// legacyColumns are the columns added with ad-hoc ALTER TABLE checks before versioned
+// migrations, in the order they were introduced. Databases from that time may lack any of
+// them, so they are added before the baseline migration is recorded.
+var legacyColumns = []struct {
+	table, column, definition string
+}{
+	{"markets", "outcome", "TEXT"},
+	{"markets", "resolved_at", "DATETIME"},
+	{"markets", "hidden", "INTEGER NOT NULL DEFAULT 0"},
+	{"markets", "channel_message_id", "INTEGER"},
+	{"markets", "description", "TEXT"},
+	{"markets", "resolution_criteria", "TEXT"},
+	{"markets", "lock_mode", "TEXT NOT NULL DEFAULT 'DEADLINE'"},
+	{"markets", "icon", "TEXT NOT NULL DEFAULT '" + DefaultMarketIcon + "'"},
+	{"markets", "idempotency_key", "TEXT"},
+	{"markets", "locked_at", "DATETIME"},
+	{"markets", "disputed_at", "DATETIME"},
+	{"markets", "anonymous", "INTEGER NOT NULL DEFAULT 0"},
+	{"markets", "sealed", "INTEGER NOT NULL DEFAULT 0"},
+	{"markets", "blind", "INTEGER NOT NULL DEFAULT 0"},
+	{"markets", "last_call_bet_id", "INTEGER"},
+	{"users", "language", "TEXT NOT NULL DEFAULT ''"},
+	{"users", "timezone", "TEXT NOT NULL DEFAULT ''"},
+	{"users", "digest_hour", "INTEGER NOT NULL DEFAULT -1"},
+	{"users", "digest_rank", "INTEGER NOT NULL DEFAULT 0"},
+	{"users", "digest_sent_on", "TEXT NOT NULL DEFAULT ''"},
+	{"users", "notify_streaks", "INTEGER NOT NULL DEFAULT 1"},
+	{"users", "notify_bet_receipts", "INTEGER NOT NULL DEFAULT 1"},
+	{"users", "notify_lock_summaries", "INTEGER NOT NULL DEFAULT 1"},
+	{"users", "show_in_winners", "INTEGER NOT NULL DEFAULT 0"},
+	{"users", "last_seen_at", "DATETIME"},
+	{"users", "notify_transport", "TEXT NOT NULL DEFAULT ''"},
+	{"users", "notify_target", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// runMigrations applies every migration the database has not seen yet
+func runMigrations() error {
+	timestamp := "DATETIME"
+	if usingPostgres {
+		timestamp = "TIMESTAMPTZ"
+	}
+	if _, err := db.Exec(fmt.Sprintf(schemaMigrationsTable, timestamp)); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	if err := adoptLegacySchema(); err != nil {
+		return err
+	}
+	if err := MigrateUp(context.Background()); err != nil {
+		return err
+	}
+	return initMarketSearch()
+}
+
+// adoptLegacySchema adds the legacy columns a database created before versioned migrations
+// is missing, so the baseline migration can be applied to it. Those were all SQLite files.
+func adoptLegacySchema() error {
+	if usingPostgres {
+		return nil
+	}
+	var applied, tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('users', 'markets')`).Scan(&tables); err != nil {
+		return fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	if applied > 0 || tables < 2 {
+		return nil
+	}
+
+	for _, c := range legacyColumns {
+		var exists int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", c.table, err)
+		}
+		if exists > 0 {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)); err != nil {
+			return fmt.Errorf("failed to add %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	return nil
+}
+
+// Migrations returns the embedded migrations for the open database's engine, oldest first
+func Migrations() ([]Migration, error) {
+	if usingPostgres {
+		return parseMigrations(migrationFiles, "migrations/postgres")
+	}
+	return parseMigrations(migrationFiles, "migrations")
+}
+
+// parseMigrations reads the NNNN_name.up.sql and NNNN_name.down.sql pairs in dir
+func parseMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		file := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		number, name, named := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || !named || err != nil || version <= 0 || !strings.HasSuffix(file, ".sql") || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %s: want NNNN_name.up.sql or NNNN_name.down.sql", file)
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("invalid migration %04d: named both %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("invalid migration %04d_%s: needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// appliedMigrations returns the recorded versions and when they were applied
+func appliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// MigrateUp applies the pending migrations in order, each in its own transaction.
+// It refuses to run against a database migrated by a newer build.
+func MigrateUp(ctx context.Context) error {
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+	}
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("database has migration %04d, which this build does not know: upgrade the binary", version)
+		}
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := applyMigration(ctx, m.Up, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.Version, m.Name); err != nil {
+			return fmt.Errorf("failed to apply migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// MigrateDown reverts the applied migrations newer than target, newest first.
+// MigrateDown(ctx, 0) reverts everything, deleting all data.
+func MigrateDown(ctx context.Context, target int) error {
+	if target < 0 {
+		return fmt.Errorf("invalid target version %d", target)
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok || m.Version <= target {
+			continue
+		}
+		if err := applyMigration(ctx, m.Down, `DELETE FROM schema_migrations WHERE version = ?`, m.Version); err != nil {
+			return fmt.Errorf("failed to revert migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs one direction of a migration together with its schema_migrations update
+func applyMigration(ctx context.Context, script, record string, args ...interface{}) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SchemaVersion returns the newest applied migration, 0 for an empty database
+func SchemaVersion() (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// MigrationStatuses lists every known migration and when it was applied, oldest first
+func MigrationStatuses(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
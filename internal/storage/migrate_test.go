@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigrationsApplied(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	version, err := SchemaVersion()
+	if err != nil {
+		t.Fatalf("Failed to get schema version: %v", err)
+	}
+	if latest := migrations[len(migrations)-1].Version; version != latest {
+		t.Errorf("Expected schema version %d, got %d", latest, version)
+	}
+
+	// Running the migrations again is a no-op
+	if err := runMigrations(); err != nil {
+		t.Fatalf("Failed to rerun migrations: %v", err)
+	}
+	statuses, _ := MigrationStatuses(context.Background())
+	for _, m := range statuses {
+		if m.AppliedAt == nil {
+			t.Errorf("Expected migration %04d_%s to be applied", m.Version, m.Name)
+		}
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	if _, err := CreateUser(12345, "reverted", "Reverted"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	if err := MigrateDown(ctx, 0); err != nil {
+		t.Fatalf("Failed to migrate down: %v", err)
+	}
+	tablesQuery := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'users'`
+	if usingPostgres {
+		tablesQuery = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'users'`
+	}
+	var tables int
+	db.QueryRow(tablesQuery).Scan(&tables)
+	if version, _ := SchemaVersion(); version != 0 || tables != 0 {
+		t.Errorf("Expected an empty schema at version 0, got version %d with %d users tables", version, tables)
+	}
+
+	if err := MigrateUp(ctx); err != nil {
+		t.Fatalf("Failed to migrate up: %v", err)
+	}
+	if _, err := CreateUser(12345, "restored", "Restored"); err != nil {
+		t.Errorf("Expected the schema to be usable again, got %v", err)
+	}
+}
+
+func TestMigrateUpRefusesNewerDatabase(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	db.Exec(`INSERT INTO schema_migrations (version, name) VALUES (9999, 'from_the_future')`)
+	// A PostgreSQL test database outlives the test; leave it migratable for the next one
+	defer db.Exec(`DELETE FROM schema_migrations WHERE version = 9999`)
+	if err := MigrateUp(context.Background()); err == nil {
+		t.Error("Expected a database with an unknown migration to be refused")
+	}
+}
+
+func TestLegacyDatabaseAdopted(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+
+	// A database from before versioned migrations: original tables, a few later columns
+	legacy, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	_, err = legacy.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, telegram_id INTEGER UNIQUE NOT NULL, username TEXT,
+			first_name TEXT NOT NULL, balance INTEGER DEFAULT 0, created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP, language TEXT NOT NULL DEFAULT '');
+		CREATE TABLE markets (id INTEGER PRIMARY KEY AUTOINCREMENT, creator_id INTEGER NOT NULL, question TEXT NOT NULL,
+			image_url TEXT, status TEXT NOT NULL DEFAULT 'ACTIVE', expires_at DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP, hidden INTEGER NOT NULL DEFAULT 0);
+		INSERT INTO users (telegram_id, username, first_name, balance, language) VALUES (777, 'veteran', 'Veteran', 1500, 'de');
+	`)
+	legacy.Close()
+	if err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+
+	if err := InitDB(dbPath); err != nil {
+		t.Fatalf("Failed to migrate legacy database: %v", err)
+	}
+	defer CloseDB()
+
+	user, err := GetUserByTelegramID(777)
+	if err != nil || user == nil || user.Balance != 1500 {
+		t.Fatalf("Expected the legacy user to survive, got %+v (%v)", user, err)
+	}
+	if lang, _ := GetUserLanguage(user.ID); lang != "de" {
+		t.Errorf("Expected language de, got %q", lang)
+	}
+	if prefs, err := GetUserPreferences(user.ID); err != nil || !prefs.BetReceipts {
+		t.Errorf("Expected the added columns with their defaults, got %+v (%v)", prefs, err)
+	}
+	if version, _ := SchemaVersion(); version == 0 {
+		t.Error("Expected the baseline migration to be recorded")
+	}
+}
+
+func TestPostgresMigrationsMatchSQLite(t *testing.T) {
+	sqlite, err := parseMigrations(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatalf("Failed to load SQLite migrations: %v", err)
+	}
+	postgres, err := parseMigrations(migrationFiles, "migrations/postgres")
+	if err != nil {
+		t.Fatalf("Failed to load PostgreSQL migrations: %v", err)
+	}
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("Expected %d PostgreSQL migrations, got %d", len(sqlite), len(postgres))
+	}
+	for i, m := range sqlite {
+		if postgres[i].Version != m.Version || postgres[i].Name != m.Name {
+			t.Errorf("Expected PostgreSQL migration %04d_%s, got %04d_%s", m.Version, m.Name, postgres[i].Version, postgres[i].Name)
+		}
+	}
+}
+
+func TestParseMigrations(t *testing.T) {
+	files := fstest.MapFS{
+		"m/0002_add_notes.up.sql":   {Data: []byte("ALTER TABLE bets ADD COLUMN note TEXT;")},
+		"m/0002_add_notes.down.sql": {Data: []byte("ALTER TABLE bets DROP COLUMN note;")},
+		"m/0001_baseline.up.sql":    {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"m/0001_baseline.down.sql":  {Data: []byte("DROP TABLE a;")},
+	}
+	migrations, err := parseMigrations(files, "m")
+	if err != nil {
+		t.Fatalf("Failed to parse migrations: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Name != "add_notes" {
+		t.Errorf("Expected baseline then add_notes, got %+v", migrations)
+	}
+
+	delete(files, "m/0002_add_notes.down.sql")
+	if _, err := parseMigrations(files, "m"); err == nil {
+		t.Error("Expected a migration without a down file to be rejected")
+	}
+
+	files["m/notes.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	files["m/0002_add_notes.down.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	if _, err := parseMigrations(files, "m"); err == nil {
+		t.Error("Expected a badly named file to be rejected")
+	}
+}
@@ -0,0 +1,21 @@
+-- Drops every table, in reverse dependency order. This deletes all data.
+
+DROP TRIGGER IF EXISTS users_updated_at;
+DROP TABLE IF EXISTS account_merges;
+DROP TABLE IF EXISTS payout_escrow;
+DROP TABLE IF EXISTS group_digests;
+DROP TABLE IF EXISTS community_votes;
+DROP TABLE IF EXISTS community_proposals;
+DROP TABLE IF EXISTS resolution_cosigns;
+DROP TABLE IF EXISTS content;
+DROP TABLE IF EXISTS market_translations;
+DROP TABLE IF EXISTS user_streaks;
+DROP TABLE IF EXISTS market_snoozes;
+DROP TABLE IF EXISTS market_tags;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS market_transfers;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS bets;
+DROP TABLE IF EXISTS markets;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,6 @@
+-- Drops the market maker tables, including every share held. This deletes data.
+
+DROP TABLE IF EXISTS share_trades;
+DROP TABLE IF EXISTS share_positions;
+DROP TABLE IF EXISTS market_makers;
+ALTER TABLE markets DROP COLUMN pricing_mode;
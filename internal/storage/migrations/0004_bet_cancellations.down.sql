@@ -0,0 +1,3 @@
+-- Drops the record of cancelled bets. Their refunds stay in the transactions log.
+
+DROP TABLE IF EXISTS bet_cancellations;
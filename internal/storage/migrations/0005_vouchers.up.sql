@@ -0,0 +1,20 @@
+-- Vouchers back a user's next bet: first-bet insurance pays the stake back if the bet loses,
+-- promo credits pay the stake in the first place. Each covers at most max_amount and may
+-- expire; bet_id is set once redeemed and settled_at once its market is finalized.
+
+CREATE TABLE IF NOT EXISTS vouchers (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	kind TEXT NOT NULL CHECK (kind IN ('INSURANCE', 'CREDIT')),
+	max_amount INTEGER NOT NULL CHECK (max_amount > 0),
+	expires_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	bet_id INTEGER UNIQUE,
+	covered INTEGER NOT NULL DEFAULT 0,
+	redeemed_at DATETIME,
+	refunded INTEGER NOT NULL DEFAULT 0,
+	settled_at DATETIME,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_vouchers_user ON vouchers(user_id, bet_id);
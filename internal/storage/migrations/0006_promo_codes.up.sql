@@ -0,0 +1,25 @@
+-- Promo codes created by admins credit a fixed amount to each user who redeems them, at most
+-- once per user, until they expire or max_uses redemptions (0 for no limit) are used up.
+
+CREATE TABLE IF NOT EXISTS promo_codes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	code TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	amount INTEGER NOT NULL CHECK (amount > 0),
+	max_uses INTEGER NOT NULL DEFAULT 0 CHECK (max_uses >= 0),
+	uses INTEGER NOT NULL DEFAULT 0,
+	expires_at DATETIME,
+	created_by INTEGER,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	code_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	amount INTEGER NOT NULL,
+	redeemed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (code_id, user_id),
+	FOREIGN KEY (code_id) REFERENCES promo_codes(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
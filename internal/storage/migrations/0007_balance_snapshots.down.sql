@@ -0,0 +1,3 @@
+-- Drops the balance snapshots; the leaderboard then shows no movement until the next one.
+
+DROP TABLE IF EXISTS balance_snapshots;
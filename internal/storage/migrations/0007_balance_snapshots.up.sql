@@ -0,0 +1,14 @@
+-- Daily balance snapshots: every user's balance and leaderboard rank at the start of a UTC day,
+-- which the leaderboard compares against to show movement since yesterday.
+
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+	user_id INTEGER NOT NULL,
+	day TEXT NOT NULL,
+	balance INTEGER NOT NULL,
+	rank INTEGER NOT NULL,
+	taken_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, day),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_balance_snapshots_day ON balance_snapshots(day);
@@ -0,0 +1,3 @@
+-- Drops the dispute records; disputed markets keep their DISPUTED status.
+
+DROP TABLE IF EXISTS disputes;
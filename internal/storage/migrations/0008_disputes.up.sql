@@ -0,0 +1,18 @@
+-- Disputes raised by bettors against a market's resolution. An OPEN dispute is closed as
+-- REJECTED when an admin restores the resolution, or SETTLED when the market is finalized.
+
+CREATE TABLE IF NOT EXISTS disputes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	market_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	outcome TEXT NOT NULL,
+	reason TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'REJECTED', 'SETTLED')),
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	closed_at DATETIME,
+	closed_by INTEGER,
+	FOREIGN KEY (market_id) REFERENCES markets(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_disputes_market ON disputes(market_id, status);
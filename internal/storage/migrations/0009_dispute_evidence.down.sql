@@ -0,0 +1,3 @@
+-- Drops the evidence links of disputes; their reasons are kept.
+
+ALTER TABLE disputes DROP COLUMN evidence_url;
@@ -0,0 +1,3 @@
+-- Drops the DM mute; muted users get every DM again.
+
+ALTER TABLE users DROP COLUMN notify_muted;
@@ -0,0 +1,4 @@
+-- Records the bond a disputer staked and whether it is still held, was refunded or forfeited.
+
+ALTER TABLE disputes ADD COLUMN bond INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE disputes ADD COLUMN bond_status TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- Drops the DM failure counters; DMs are sent to every user again.
+
+ALTER TABLE users DROP COLUMN dm_disabled_at;
+ALTER TABLE users DROP COLUMN dm_blocked_streak;
+ALTER TABLE users DROP COLUMN dm_failures;
@@ -0,0 +1,5 @@
+-- Counts failed DMs per user and turns DMs off for users who keep blocking the bot.
+
+ALTER TABLE users ADD COLUMN dm_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN dm_blocked_streak INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN dm_disabled_at DATETIME;
@@ -0,0 +1,5 @@
+-- Drops the finalization failure counters; stuck markets go back to RESOLVED and are retried.
+
+UPDATE markets SET status = 'RESOLVED' WHERE status = 'NEEDS_ATTENTION';
+ALTER TABLE markets DROP COLUMN finalize_error;
+ALTER TABLE markets DROP COLUMN finalize_failures;
@@ -0,0 +1,14 @@
+-- Links transactions to their market, so a user's history can be filtered by market.
+-- Older rows are linked from the "market #N" (or, for vouchers, "bet #N") in their description.
+
+ALTER TABLE transactions ADD COLUMN market_id INTEGER;
+
+UPDATE transactions
+SET market_id = CAST(substr(description, instr(description, 'market #') + 8) AS INTEGER)
+WHERE instr(description, 'market #') > 0;
+
+UPDATE transactions
+SET market_id = (SELECT market_id FROM bets WHERE bets.id = CAST(substr(transactions.description, instr(transactions.description, 'bet #') + 5) AS INTEGER))
+WHERE source_type IN ('VOUCHER_CREDIT', 'VOUCHER_REFUND') AND instr(description, 'bet #') > 0;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_market_id ON transactions(market_id);
@@ -0,0 +1,3 @@
+-- Records where each bet was placed (webapp, inline_button, api). Earlier bets are 'unknown'.
+
+ALTER TABLE bets ADD COLUMN source TEXT NOT NULL DEFAULT 'unknown';
@@ -0,0 +1,4 @@
+-- Makes every group market public again.
+
+DROP INDEX IF EXISTS idx_markets_group_id;
+ALTER TABLE markets DROP COLUMN group_id;
@@ -0,0 +1,3 @@
+-- Drops the market webhooks.
+
+DROP TABLE IF EXISTS market_webhooks;
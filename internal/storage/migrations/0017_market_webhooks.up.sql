@@ -0,0 +1,12 @@
+-- Per-market callback URLs that get a signed POST when the market locks, for creators who
+-- resolve their markets from a script. The secret signs the deliveries.
+
+CREATE TABLE IF NOT EXISTS market_webhooks (
+	market_id INTEGER PRIMARY KEY REFERENCES markets(id),
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	last_attempt_at DATETIME,
+	last_status INTEGER,
+	last_error TEXT
+);
@@ -0,0 +1,4 @@
+-- Drops the sports feed fixtures.
+
+DROP INDEX IF EXISTS idx_sports_fixtures_status;
+DROP TABLE IF EXISTS sports_fixtures;
@@ -0,0 +1,20 @@
+-- Fixtures pulled from the sports feed. Each waits for an admin to approve it into a match
+-- market or reject it; approved fixtures are resolved from their final score.
+
+CREATE TABLE IF NOT EXISTS sports_fixtures (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	external_id TEXT NOT NULL UNIQUE,
+	league TEXT NOT NULL DEFAULT '',
+	home_team TEXT NOT NULL,
+	away_team TEXT NOT NULL,
+	kickoff_at DATETIME NOT NULL,
+	status TEXT NOT NULL DEFAULT 'PENDING',
+	market_id INTEGER REFERENCES markets(id),
+	reviewed_by INTEGER REFERENCES users(id),
+	home_score INTEGER,
+	away_score INTEGER,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sports_fixtures_status ON sports_fixtures(status, kickoff_at);
@@ -0,0 +1,20 @@
+-- Markets created from the weather template: the place and day they ask about, and what the
+-- weather oracle observed once the day was over.
+
+CREATE TABLE IF NOT EXISTS weather_markets (
+	market_id INTEGER PRIMARY KEY REFERENCES markets(id),
+	place TEXT NOT NULL,
+	latitude REAL NOT NULL,
+	longitude REAL NOT NULL,
+	timezone TEXT NOT NULL,
+	day TEXT NOT NULL,
+	condition TEXT NOT NULL,
+	threshold REAL NOT NULL DEFAULT 0,
+	resolve_after DATETIME NOT NULL,
+	status TEXT NOT NULL DEFAULT 'PENDING',
+	observed REAL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	resolved_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_weather_markets_due ON weather_markets(status, resolve_after);
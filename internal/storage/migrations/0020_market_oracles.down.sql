@@ -0,0 +1,4 @@
+-- Drops the HTTP-JSON oracles.
+
+DROP INDEX IF EXISTS idx_market_oracles_status;
+DROP TABLE IF EXISTS market_oracles;
@@ -0,0 +1,23 @@
+-- HTTP-JSON oracles: a market resolves from a value read out of a JSON document once it
+-- locks. Creators propose the mapping, admins review it, and the fetched payload is kept as
+-- evidence.
+
+CREATE TABLE IF NOT EXISTS market_oracles (
+	market_id INTEGER PRIMARY KEY REFERENCES markets(id),
+	url TEXT NOT NULL,
+	path TEXT NOT NULL,
+	operator TEXT NOT NULL,
+	value TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'PENDING_REVIEW',
+	reviewed_by INTEGER REFERENCES users(id),
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	fetched_at DATETIME,
+	observed TEXT,
+	outcome TEXT,
+	evidence TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_market_oracles_status ON market_oracles(status);
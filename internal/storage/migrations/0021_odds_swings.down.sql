@@ -0,0 +1,5 @@
+-- Drops the pool snapshots and odds swing alerts.
+
+ALTER TABLE users DROP COLUMN notify_odds_swings;
+DROP TABLE IF EXISTS odds_alerts;
+DROP TABLE IF EXISTS pool_snapshots;
@@ -0,0 +1,3 @@
+-- Drops the cold start nudge tracking.
+
+ALTER TABLE markets DROP COLUMN cold_start_nudged_at;
@@ -0,0 +1,6 @@
+-- Remembers when a market's creator was nudged because nobody had bet on it yet, so the nudge
+-- goes out once. Markets created before the nudge existed count as nudged.
+
+ALTER TABLE markets ADD COLUMN cold_start_nudged_at DATETIME;
+
+UPDATE markets SET cold_start_nudged_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,3 @@
+-- Drops the market maker subsidy.
+
+ALTER TABLE market_makers DROP COLUMN subsidy;
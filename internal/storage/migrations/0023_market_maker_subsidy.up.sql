@@ -0,0 +1,5 @@
+-- Remembers what the creator of a market maker market paid up front to cover the market
+-- maker's worst-case loss. Finalization refunds it less what the market maker lost. Markets
+-- created before were subsidized by the house and keep 0.
+
+ALTER TABLE market_makers ADD COLUMN subsidy INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,254 @@
+-- The PostgreSQL schema as it stood when versioned migrations were introduced. PostgreSQL
+-- databases always start from here; only SQLite has databases from before migrations.
+
+CREATE TABLE IF NOT EXISTS users (
+	id BIGSERIAL PRIMARY KEY,
+	telegram_id BIGINT UNIQUE NOT NULL,
+	username TEXT,
+	first_name TEXT NOT NULL,
+	balance BIGINT DEFAULT 0,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	language TEXT NOT NULL DEFAULT '',
+	timezone TEXT NOT NULL DEFAULT '',
+	-- Daily digest opt-in: local hour (-1 = off), the rank at the last digest and the
+	-- local date the scheduled digest was last sent
+	digest_hour BIGINT NOT NULL DEFAULT -1,
+	digest_rank BIGINT NOT NULL DEFAULT 0,
+	digest_sent_on TEXT NOT NULL DEFAULT '',
+	notify_streaks BIGINT NOT NULL DEFAULT 1,
+	notify_bet_receipts BIGINT NOT NULL DEFAULT 1,
+	notify_lock_summaries BIGINT NOT NULL DEFAULT 1,
+	show_in_winners BIGINT NOT NULL DEFAULT 0,
+	last_seen_at TIMESTAMPTZ,
+	notify_transport TEXT NOT NULL DEFAULT '',
+	notify_target TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	amount BIGINT NOT NULL,
+	source_type TEXT NOT NULL,
+	description TEXT,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS markets (
+	id BIGSERIAL PRIMARY KEY,
+	creator_id BIGINT NOT NULL,
+	question TEXT NOT NULL,
+	image_url TEXT,
+	status TEXT NOT NULL DEFAULT 'ACTIVE',
+	outcome TEXT,
+	resolved_at TIMESTAMPTZ,
+	expires_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	hidden BIGINT NOT NULL DEFAULT 0,
+	channel_message_id BIGINT,
+	description TEXT,
+	resolution_criteria TEXT,
+	lock_mode TEXT NOT NULL DEFAULT 'DEADLINE',
+	-- Icon shown before the question; markets from before icons get the default one
+	icon TEXT NOT NULL DEFAULT '🎯',
+	-- Client-generated key that makes retried creations return the first market
+	idempotency_key TEXT,
+	locked_at TIMESTAMPTZ,
+	disputed_at TIMESTAMPTZ,
+	anonymous BIGINT NOT NULL DEFAULT 0,
+	sealed BIGINT NOT NULL DEFAULT 0,
+	blind BIGINT NOT NULL DEFAULT 0,
+	last_call_bet_id BIGINT,
+	FOREIGN KEY (creator_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS bets (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	market_id BIGINT NOT NULL,
+	outcome TEXT NOT NULL CHECK (outcome IN ('YES', 'NO')),
+	amount BIGINT NOT NULL,
+	placed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (market_id) REFERENCES markets(id)
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+	id BIGSERIAL PRIMARY KEY,
+	actor_id BIGINT NOT NULL,
+	action TEXT NOT NULL,
+	entity_type TEXT NOT NULL,
+	entity_id BIGINT NOT NULL,
+	details TEXT,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS market_transfers (
+	id BIGSERIAL PRIMARY KEY,
+	market_id BIGINT NOT NULL,
+	from_user_id BIGINT NOT NULL,
+	to_user_id BIGINT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'PENDING',
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (market_id) REFERENCES markets(id),
+	FOREIGN KEY (from_user_id) REFERENCES users(id),
+	FOREIGN KEY (to_user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+	user_id BIGINT NOT NULL,
+	role TEXT NOT NULL CHECK (role IN ('admin', 'moderator', 'oracle')),
+	granted_by BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, role),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS market_tags (
+	market_id BIGINT NOT NULL,
+	tag TEXT NOT NULL,
+	PRIMARY KEY (market_id, tag),
+	FOREIGN KEY (market_id) REFERENCES markets(id)
+);
+
+CREATE TABLE IF NOT EXISTS market_snoozes (
+	user_id BIGINT NOT NULL,
+	market_id BIGINT NOT NULL,
+	until TIMESTAMPTZ,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, market_id),
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (market_id) REFERENCES markets(id)
+);
+
+CREATE TABLE IF NOT EXISTS user_streaks (
+	user_id BIGINT PRIMARY KEY,
+	current BIGINT NOT NULL DEFAULT 0,
+	best BIGINT NOT NULL DEFAULT 0,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS market_translations (
+	market_id BIGINT NOT NULL,
+	lang TEXT NOT NULL,
+	question TEXT NOT NULL,
+	source TEXT NOT NULL CHECK (source IN ('creator', 'machine')),
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (market_id, lang),
+	FOREIGN KEY (market_id) REFERENCES markets(id)
+);
+
+CREATE TABLE IF NOT EXISTS content (
+	key TEXT PRIMARY KEY,
+	body TEXT NOT NULL,
+	updated_by BIGINT NOT NULL DEFAULT 0,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS resolution_cosigns (
+	id BIGSERIAL PRIMARY KEY,
+	market_id BIGINT NOT NULL,
+	proposed_by BIGINT NOT NULL,
+	outcome TEXT NOT NULL CHECK (outcome IN ('YES', 'NO')),
+	status TEXT NOT NULL DEFAULT 'PENDING',
+	cosigner_id BIGINT,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (market_id) REFERENCES markets(id),
+	FOREIGN KEY (proposed_by) REFERENCES users(id),
+	FOREIGN KEY (cosigner_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS community_proposals (
+	id BIGSERIAL PRIMARY KEY,
+	market_id BIGINT NOT NULL,
+	proposed_by BIGINT NOT NULL,
+	outcome TEXT NOT NULL CHECK (outcome IN ('YES', 'NO')),
+	status TEXT NOT NULL DEFAULT 'OPEN',
+	closes_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (market_id) REFERENCES markets(id),
+	FOREIGN KEY (proposed_by) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS community_votes (
+	proposal_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	confirm BIGINT NOT NULL,
+	stake BIGINT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (proposal_id, user_id),
+	FOREIGN KEY (proposal_id) REFERENCES community_proposals(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS group_digests (
+	chat_id BIGINT PRIMARY KEY,
+	hour BIGINT NOT NULL CHECK (hour BETWEEN 0 AND 23),
+	timezone TEXT NOT NULL DEFAULT '',
+	enabled_by BIGINT NOT NULL,
+	sent_on TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS payout_escrow (
+	id BIGSERIAL PRIMARY KEY,
+	market_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	bet_id BIGINT NOT NULL,
+	amount BIGINT NOT NULL,
+	source_type TEXT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (market_id) REFERENCES markets(id)
+);
+
+CREATE TABLE IF NOT EXISTS account_merges (
+	id BIGSERIAL PRIMARY KEY,
+	from_user_id BIGINT NOT NULL,
+	to_user_id BIGINT NOT NULL,
+	requested_by BIGINT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'PENDING',
+	from_code TEXT NOT NULL,
+	to_code TEXT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (from_user_id) REFERENCES users(id),
+	FOREIGN KEY (to_user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_user_id ON transactions(user_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions(created_at);
+CREATE INDEX IF NOT EXISTS idx_markets_status ON markets(status);
+CREATE INDEX IF NOT EXISTS idx_markets_created_at ON markets(created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_markets_idempotency_key ON markets(creator_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_bets_user_market ON bets(user_id, market_id);
+CREATE INDEX IF NOT EXISTS idx_bets_market ON bets(market_id);
+CREATE INDEX IF NOT EXISTS idx_users_balance ON users(balance DESC);
+CREATE INDEX IF NOT EXISTS idx_users_last_seen_at ON users(last_seen_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_market_transfers_market ON market_transfers(market_id, status);
+CREATE INDEX IF NOT EXISTS idx_market_tags_tag ON market_tags(tag);
+CREATE INDEX IF NOT EXISTS idx_resolution_cosigns_market ON resolution_cosigns(market_id, status);
+CREATE INDEX IF NOT EXISTS idx_community_proposals_market ON community_proposals(market_id, status);
+CREATE INDEX IF NOT EXISTS idx_payout_escrow_market ON payout_escrow(market_id);
+CREATE INDEX IF NOT EXISTS idx_account_merges_status ON account_merges(status);
+
+-- Keep users.updated_at current on every write that doesn't set it itself.
+-- Seeing a user is not a change to their account, so last_seen_at updates are left out.
+CREATE OR REPLACE FUNCTION users_touch_updated_at() RETURNS trigger AS $$
+BEGIN
+	IF NEW.updated_at IS NOT DISTINCT FROM OLD.updated_at AND NEW.last_seen_at IS NOT DISTINCT FROM OLD.last_seen_at THEN
+		NEW.updated_at := CURRENT_TIMESTAMP;
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_updated_at ON users;
+CREATE TRIGGER users_updated_at BEFORE UPDATE ON users
+FOR EACH ROW EXECUTE FUNCTION users_touch_updated_at();
@@ -0,0 +1,40 @@
+-- Markets priced by an automated market maker (logarithmic market scoring rule) instead of
+-- parimutuel pools. Bettors buy and sell shares that pay out 1 each if their outcome wins.
+
+ALTER TABLE markets ADD COLUMN pricing_mode TEXT NOT NULL DEFAULT 'PARIMUTUEL';
+
+-- The market maker's state: its liquidity parameter b and the shares outstanding on each side
+CREATE TABLE IF NOT EXISTS market_makers (
+	market_id BIGINT PRIMARY KEY,
+	liquidity BIGINT NOT NULL CHECK (liquidity > 0),
+	shares_yes BIGINT NOT NULL DEFAULT 0,
+	shares_no BIGINT NOT NULL DEFAULT 0,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (market_id) REFERENCES markets(id)
+);
+
+CREATE TABLE IF NOT EXISTS share_positions (
+	market_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	outcome TEXT NOT NULL CHECK (outcome IN ('YES', 'NO')),
+	shares BIGINT NOT NULL CHECK (shares >= 0),
+	PRIMARY KEY (market_id, user_id, outcome),
+	FOREIGN KEY (market_id) REFERENCES markets(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Every buy (positive shares and amount paid) and sell (negative shares and amount received)
+CREATE TABLE IF NOT EXISTS share_trades (
+	id BIGSERIAL PRIMARY KEY,
+	market_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	outcome TEXT NOT NULL CHECK (outcome IN ('YES', 'NO')),
+	shares BIGINT NOT NULL,
+	amount BIGINT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (market_id) REFERENCES markets(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_share_positions_user ON share_positions(user_id);
+CREATE INDEX IF NOT EXISTS idx_share_trades_market ON share_trades(market_id, user_id);
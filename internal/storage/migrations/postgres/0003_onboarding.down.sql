@@ -0,0 +1,3 @@
+-- Drops the onboarding state; users part way through are not onboarded again.
+
+DROP TABLE IF EXISTS user_onboarding;
@@ -0,0 +1,10 @@
+-- Where each new user is in the bot's onboarding conversation. Users who joined before
+-- onboarding existed have no row and are never onboarded.
+
+CREATE TABLE IF NOT EXISTS user_onboarding (
+	user_id BIGINT PRIMARY KEY,
+	step TEXT NOT NULL,
+	started_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
@@ -0,0 +1,18 @@
+-- Bets cancelled before their market locked. The bet itself is deleted so it leaves the pools;
+-- this keeps the record, the fee kept and the refund.
+
+CREATE TABLE IF NOT EXISTS bet_cancellations (
+	id BIGSERIAL PRIMARY KEY,
+	bet_id BIGINT NOT NULL UNIQUE,
+	user_id BIGINT NOT NULL,
+	market_id BIGINT NOT NULL,
+	outcome TEXT NOT NULL CHECK (outcome IN ('YES', 'NO')),
+	amount BIGINT NOT NULL,
+	fee BIGINT NOT NULL DEFAULT 0,
+	placed_at TIMESTAMPTZ NOT NULL,
+	cancelled_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (market_id) REFERENCES markets(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bet_cancellations_user ON bet_cancellations(user_id, placed_at);
@@ -0,0 +1,3 @@
+-- Drops every voucher, redeemed or not. Refunds already paid stay in the transactions log.
+
+DROP TABLE IF EXISTS vouchers;
@@ -0,0 +1,20 @@
+-- Vouchers back a user's next bet: first-bet insurance pays the stake back if the bet loses,
+-- promo credits pay the stake in the first place. Each covers at most max_amount and may
+-- expire; bet_id is set once redeemed and settled_at once its market is finalized.
+
+CREATE TABLE IF NOT EXISTS vouchers (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	kind TEXT NOT NULL CHECK (kind IN ('INSURANCE', 'CREDIT')),
+	max_amount BIGINT NOT NULL CHECK (max_amount > 0),
+	expires_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	bet_id BIGINT UNIQUE,
+	covered BIGINT NOT NULL DEFAULT 0,
+	redeemed_at TIMESTAMPTZ,
+	refunded BIGINT NOT NULL DEFAULT 0,
+	settled_at TIMESTAMPTZ,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_vouchers_user ON vouchers(user_id, bet_id);
@@ -0,0 +1,4 @@
+-- Drops the promo codes and their redemptions. Credits already paid stay in the transactions log.
+
+DROP TABLE IF EXISTS promo_redemptions;
+DROP TABLE IF EXISTS promo_codes;
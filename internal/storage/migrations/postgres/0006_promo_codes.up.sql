@@ -0,0 +1,28 @@
+-- Promo codes created by admins credit a fixed amount to each user who redeems them, at most
+-- once per user, until they expire or max_uses redemptions (0 for no limit) are used up.
+
+CREATE TABLE IF NOT EXISTS promo_codes (
+	id BIGSERIAL PRIMARY KEY,
+	code TEXT NOT NULL,
+	amount BIGINT NOT NULL CHECK (amount > 0),
+	max_uses BIGINT NOT NULL DEFAULT 0 CHECK (max_uses >= 0),
+	uses BIGINT NOT NULL DEFAULT 0,
+	expires_at TIMESTAMPTZ,
+	created_by BIGINT,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+-- Codes are unique regardless of case, like SQLite's COLLATE NOCASE
+CREATE UNIQUE INDEX IF NOT EXISTS idx_promo_codes_code ON promo_codes(LOWER(code));
+
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+	id BIGSERIAL PRIMARY KEY,
+	code_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	amount BIGINT NOT NULL,
+	redeemed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (code_id, user_id),
+	FOREIGN KEY (code_id) REFERENCES promo_codes(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
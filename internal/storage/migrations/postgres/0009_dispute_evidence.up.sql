@@ -0,0 +1,3 @@
+-- Lets a dispute link to evidence (a URL) next to its free-text reason.
+
+ALTER TABLE disputes ADD COLUMN evidence_url TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Lets users mute every DM except payouts and refunds (/mute, /unmute).
+
+ALTER TABLE users ADD COLUMN notify_muted BIGINT NOT NULL DEFAULT 0;
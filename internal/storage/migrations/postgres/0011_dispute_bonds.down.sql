@@ -0,0 +1,4 @@
+-- Drops the dispute bonds; held bonds are not paid back.
+
+ALTER TABLE disputes DROP COLUMN bond_status;
+ALTER TABLE disputes DROP COLUMN bond;
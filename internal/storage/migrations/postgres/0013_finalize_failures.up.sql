@@ -0,0 +1,4 @@
+-- Counts failed auto-finalizations per market; markets that keep failing wait in NEEDS_ATTENTION.
+
+ALTER TABLE markets ADD COLUMN finalize_failures BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE markets ADD COLUMN finalize_error TEXT NOT NULL DEFAULT '';
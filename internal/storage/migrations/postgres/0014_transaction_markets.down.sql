@@ -0,0 +1,4 @@
+-- Unlinks transactions from their markets; descriptions still name them.
+
+DROP INDEX IF EXISTS idx_transactions_market_id;
+ALTER TABLE transactions DROP COLUMN market_id;
@@ -0,0 +1,6 @@
+-- Links transactions to their market, so a user's history can be filtered by market.
+-- PostgreSQL databases start from this schema, so there are no older rows to link.
+
+ALTER TABLE transactions ADD COLUMN market_id BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_market_id ON transactions(market_id);
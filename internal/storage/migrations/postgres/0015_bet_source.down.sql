@@ -0,0 +1,3 @@
+-- Forgets where bets were placed.
+
+ALTER TABLE bets DROP COLUMN source;
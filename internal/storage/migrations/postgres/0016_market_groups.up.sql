@@ -0,0 +1,5 @@
+-- Scopes markets to the Telegram group chat they were created in. NULL is a public market.
+
+ALTER TABLE markets ADD COLUMN group_id BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_markets_group_id ON markets(group_id);
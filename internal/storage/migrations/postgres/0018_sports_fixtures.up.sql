@@ -0,0 +1,20 @@
+-- Fixtures pulled from the sports feed. Each waits for an admin to approve it into a match
+-- market or reject it; approved fixtures are resolved from their final score.
+
+CREATE TABLE IF NOT EXISTS sports_fixtures (
+	id BIGSERIAL PRIMARY KEY,
+	external_id TEXT NOT NULL UNIQUE,
+	league TEXT NOT NULL DEFAULT '',
+	home_team TEXT NOT NULL,
+	away_team TEXT NOT NULL,
+	kickoff_at TIMESTAMPTZ NOT NULL,
+	status TEXT NOT NULL DEFAULT 'PENDING',
+	market_id BIGINT REFERENCES markets(id),
+	reviewed_by BIGINT REFERENCES users(id),
+	home_score BIGINT,
+	away_score BIGINT,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sports_fixtures_status ON sports_fixtures(status, kickoff_at);
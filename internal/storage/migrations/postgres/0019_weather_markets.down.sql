@@ -0,0 +1,4 @@
+-- Drops the weather template markets.
+
+DROP INDEX IF EXISTS idx_weather_markets_due;
+DROP TABLE IF EXISTS weather_markets;
@@ -0,0 +1,20 @@
+-- Markets created from the weather template: the place and day they ask about, and what the
+-- weather oracle observed once the day was over.
+
+CREATE TABLE IF NOT EXISTS weather_markets (
+	market_id BIGINT PRIMARY KEY REFERENCES markets(id),
+	place TEXT NOT NULL,
+	latitude DOUBLE PRECISION NOT NULL,
+	longitude DOUBLE PRECISION NOT NULL,
+	timezone TEXT NOT NULL,
+	day TEXT NOT NULL,
+	condition TEXT NOT NULL,
+	threshold DOUBLE PRECISION NOT NULL DEFAULT 0,
+	resolve_after TIMESTAMPTZ NOT NULL,
+	status TEXT NOT NULL DEFAULT 'PENDING',
+	observed DOUBLE PRECISION,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_weather_markets_due ON weather_markets(status, resolve_after);
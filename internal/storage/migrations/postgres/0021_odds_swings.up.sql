@@ -0,0 +1,21 @@
+-- Odds swing alerts: the market worker snapshots the pools of open markets whenever they change,
+-- and tells bettors when the implied probability moved far from where it was after their bet.
+
+CREATE TABLE IF NOT EXISTS pool_snapshots (
+	market_id BIGINT NOT NULL REFERENCES markets(id),
+	taken_at TIMESTAMPTZ NOT NULL,
+	pool_yes BIGINT NOT NULL,
+	pool_no BIGINT NOT NULL,
+	PRIMARY KEY (market_id, taken_at)
+);
+
+-- The implied YES probability a bettor was last told about on a market
+CREATE TABLE IF NOT EXISTS odds_alerts (
+	user_id BIGINT NOT NULL REFERENCES users(id),
+	market_id BIGINT NOT NULL REFERENCES markets(id),
+	implied_yes DOUBLE PRECISION NOT NULL,
+	alerted_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (user_id, market_id)
+);
+
+ALTER TABLE users ADD COLUMN notify_odds_swings BIGINT NOT NULL DEFAULT 1;
@@ -22,6 +22,7 @@ type Transaction struct {
 	Amount      int64     `json:"amount" db:"amount"`           // can be negative
 	SourceType  string    `json:"source_type" db:"source_type"` // 'WELCOME_BONUS', 'BET', 'WIN'
 	Description string    `json:"description" db:"description"`
+	MarketID    int64     `json:"market_id,omitempty" db:"market_id"` // 0 for bonuses, promos and adjustments
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 }
 
@@ -30,23 +31,35 @@ type MarketStatus string
 
 const (
 	MarketStatusActive     MarketStatus = "ACTIVE"
+	MarketStatusLastCall   MarketStatus = "LAST_CALL"
 	MarketStatusLocked     MarketStatus = "LOCKED"
 	MarketStatusResolved   MarketStatus = "RESOLVED"
 	MarketStatusDisputed   MarketStatus = "DISPUTED"
 	MarketStatusFinalized  MarketStatus = "FINALIZED"
+	MarketStatusMerged     MarketStatus = "MERGED"
+	// MarketStatusNeedsAttention is a resolved market whose auto-finalization kept failing
+	MarketStatusNeedsAttention MarketStatus = "NEEDS_ATTENTION"
 )
 
 // Market represents a prediction market
 type Market struct {
-	ID         int64        `json:"id" db:"id"`
-	CreatorID  int64        `json:"creator_id" db:"creator_id"`
-	Question   string       `json:"question" db:"question"`
-	ImageURL   string       `json:"image_url,omitempty" db:"image_url"`
-	Status     MarketStatus `json:"status" db:"status"`
-	Outcome    string       `json:"outcome,omitempty" db:"outcome"`
-	ResolvedAt time.Time    `json:"resolved_at,omitempty" db:"resolved_at"`
-	ExpiresAt  time.Time    `json:"expires_at" db:"expires_at"`
-	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+	ID          int64        `json:"id" db:"id"`
+	CreatorID   int64        `json:"creator_id" db:"creator_id"`
+	Question    string       `json:"question" db:"question"`
+	ImageURL    string       `json:"image_url,omitempty" db:"image_url"`
+	Status      MarketStatus `json:"status" db:"status"`
+	Outcome     string       `json:"outcome,omitempty" db:"outcome"`
+	ResolvedAt  time.Time    `json:"resolved_at,omitempty" db:"resolved_at"`
+	ExpiresAt   time.Time    `json:"expires_at" db:"expires_at"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	Hidden      bool         `json:"hidden,omitempty" db:"hidden"`
+	Blind       bool         `json:"blind,omitempty" db:"blind"`
+	Sealed      bool         `json:"sealed,omitempty" db:"sealed"`
+	Anonymous   bool         `json:"anonymous,omitempty" db:"anonymous"`
+	LockMode    LockMode     `json:"lock_mode" db:"lock_mode"`
+	Icon        string       `json:"icon" db:"icon"`
+	PricingMode PricingMode  `json:"pricing_mode" db:"pricing_mode"`
+	GroupID     int64        `json:"group_id,omitempty" db:"group_id"` // Telegram chat the market belongs to, 0 for public markets
 }
 
 // MarketResponse is the API response for a market
@@ -84,6 +97,18 @@ type LeaderboardEntry struct {
 	Username       string `json:"username"`
 	Balance        int64  `json:"balance"`
 	BalanceDisplay string `json:"balance_display"`
+	// PreviousRank is the rank in the latest daily snapshot, 0 for users who joined since
+	PreviousRank int64 `json:"previous_rank,omitempty"`
+	// RankChange is how many places the user climbed since the snapshot, negative when they fell
+	RankChange int64 `json:"rank_change"`
+	// BalanceChange is the balance gained since the snapshot, negative when lost
+	BalanceChange int64 `json:"balance_change"`
+	// IsMe marks the caller's own entry in GET /api/leaderboard/me
+	IsMe bool `json:"is_me,omitempty"`
+	// Profit is what the user made in a weekly or monthly leaderboard's window
+	Profit int64 `json:"profit,omitempty"`
+	// Value is what a win rate, streak or markets created leaderboard ranks by; nil by balance
+	Value *float64 `json:"value,omitempty"`
 }
 
 // BailoutResult represents the result of a bailout operation
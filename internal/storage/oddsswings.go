@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// oddsSwingMarketsSQL selects the markets (alias m) whose odds swings are watched: open, visible,
+// and showing both pools, so alerts never reveal what blind or sealed markets hide. Pools are
+// frozen during a last call, so those markets have nothing to report.
+const oddsSwingMarketsSQL = `m.status = 'ACTIVE' AND m.hidden = 0 AND m.blind = 0 AND m.sealed = 0`
+
+// TakePoolSnapshots records the pools of every watched market whose pools changed since its last
+// snapshot, and drops the snapshots and alerts of markets that are no longer open. It returns how
+// many snapshots were taken.
+func TakePoolSnapshots(now time.Time) (int64, error) {
+	result, err := db.Exec(`
+		INSERT INTO pool_snapshots (market_id, taken_at, pool_yes, pool_no)
+		SELECT p.market_id, ?, p.pool_yes, p.pool_no
+		FROM (
+			SELECT m.id AS market_id,
+			       COALESCE(SUM(CASE WHEN b.outcome = 'YES' THEN b.amount ELSE 0 END), 0) AS pool_yes,
+			       COALESCE(SUM(CASE WHEN b.outcome = 'NO' THEN b.amount ELSE 0 END), 0) AS pool_no
+			FROM markets m
+			JOIN bets b ON b.market_id = m.id
+			WHERE `+oddsSwingMarketsSQL+`
+			GROUP BY m.id
+		) p
+		WHERE p.pool_yes + p.pool_no > 0 AND NOT EXISTS (
+			SELECT 1 FROM pool_snapshots s
+			WHERE s.market_id = p.market_id AND s.pool_yes = p.pool_yes AND s.pool_no = p.pool_no
+			  AND s.taken_at = (SELECT MAX(taken_at) FROM pool_snapshots WHERE market_id = p.market_id)
+		)
+		ON CONFLICT DO NOTHING
+	`, sqlTimestamp(now))
+	if err != nil {
+		return 0, fmt.Errorf("failed to take pool snapshots: %w", err)
+	}
+	count, _ := result.RowsAffected()
+
+	for _, table := range []string{"pool_snapshots", "odds_alerts"} {
+		if _, err := db.Exec(`DELETE FROM ` + table + ` WHERE market_id IN (SELECT id FROM markets WHERE status NOT IN ('ACTIVE', 'LAST_CALL'))`); err != nil {
+			return count, fmt.Errorf("failed to prune %s: %w", table, err)
+		}
+	}
+	return count, nil
+}
+
+// OddsSwingPosition is a bettor's position on a watched market, with the implied YES probability
+// (in percent) their alerts are measured from and the current one
+type OddsSwingPosition struct {
+	UserID     int64
+	TelegramID int64
+	MarketID   int64
+	Question   string
+	// Yes and No are the user's stakes on each side
+	Yes int64
+	No  int64
+	// BaselineYes is the implied YES probability after the user's latest bet, or the one they
+	// were last alerted about if that came later
+	BaselineYes float64
+	CurrentYes  float64
+}
+
+// ListOddsSwingPositions returns the positions on watched markets of every bettor who wants odds
+// swing DMs and has not muted their DMs. The baseline after a bet is the market's first snapshot
+// taken at or after it; positions without one yet are left out.
+func ListOddsSwingPositions() ([]OddsSwingPosition, error) {
+	rows, err := db.Query(`
+		WITH positions AS (
+			SELECT b.user_id, b.market_id, MAX(b.placed_at) AS last_bet_at,
+			       SUM(CASE WHEN b.outcome = 'YES' THEN b.amount ELSE 0 END) AS yes,
+			       SUM(CASE WHEN b.outcome = 'NO' THEN b.amount ELSE 0 END) AS no
+			FROM bets b
+			JOIN markets m ON m.id = b.market_id
+			WHERE ` + oddsSwingMarketsSQL + `
+			GROUP BY b.user_id, b.market_id
+		),
+		latest AS (
+			SELECT s.market_id, 100.0 * s.pool_yes / (s.pool_yes + s.pool_no) AS implied_yes
+			FROM pool_snapshots s
+			WHERE s.taken_at = (SELECT MAX(taken_at) FROM pool_snapshots WHERE market_id = s.market_id)
+		)
+		SELECT p.user_id, u.telegram_id, p.market_id, m.question, p.yes, p.no,
+		       COALESCE(
+		           (SELECT a.implied_yes FROM odds_alerts a
+		            WHERE a.user_id = p.user_id AND a.market_id = p.market_id AND a.alerted_at >= p.last_bet_at),
+		           (SELECT 100.0 * s.pool_yes / (s.pool_yes + s.pool_no) FROM pool_snapshots s
+		            WHERE s.market_id = p.market_id AND s.taken_at >= p.last_bet_at
+		            ORDER BY s.taken_at LIMIT 1)
+		       ),
+		       l.implied_yes
+		FROM positions p
+		JOIN latest l ON l.market_id = p.market_id
+		JOIN users u ON u.id = p.user_id
+		JOIN markets m ON m.id = p.market_id
+		WHERE u.notify_odds_swings = 1 AND u.notify_muted = 0
+		ORDER BY p.market_id, p.user_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query odds swing positions: %w", err)
+	}
+	defer rows.Close()
+
+	var positions []OddsSwingPosition
+	for rows.Next() {
+		var p OddsSwingPosition
+		var baseline sql.NullFloat64
+		if err := rows.Scan(&p.UserID, &p.TelegramID, &p.MarketID, &p.Question, &p.Yes, &p.No, &baseline, &p.CurrentYes); err != nil {
+			return nil, fmt.Errorf("failed to scan odds swing position: %w", err)
+		}
+		if !baseline.Valid {
+			continue
+		}
+		p.BaselineYes = baseline.Float64
+		positions = append(positions, p)
+	}
+	return positions, rows.Err()
+}
+
+// RecordOddsAlert remembers the implied YES probability a user was alerted about on a market, so
+// the next alert is measured from there
+func RecordOddsAlert(userID, marketID int64, impliedYes float64, now time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO odds_alerts (user_id, market_id, implied_yes, alerted_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, market_id) DO UPDATE SET implied_yes = excluded.implied_yes, alerted_at = excluded.alerted_at
+	`, userID, marketID, impliedYes, sqlTimestamp(now))
+	if err != nil {
+		return fmt.Errorf("failed to record odds alert: %w", err)
+	}
+	return nil
+}
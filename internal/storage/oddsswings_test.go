@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOddsSwingPositions(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(5101, "creator", "Creator")
+	alice, _ := CreateUser(5102, "alice", "Alice")
+	bob, _ := CreateUser(5103, "bob", "Bob")
+	market, _ := CreateMarket(creator.ID, "Will the bridge open on time?", time.Now().Add(time.Hour))
+	blind, _ := CreateMarketWithOptions(creator.ID, "Will the blind market stay secret?", time.Now().Add(time.Hour), MarketOptions{Blind: true})
+
+	PlaceBet(ctx, alice.ID, market.ID, "YES", 100)
+	PlaceBet(ctx, alice.ID, blind.ID, "YES", 100)
+	now := time.Now().UTC().Add(-10 * time.Minute)
+	db.Exec(`UPDATE bets SET placed_at = ?`, now.Add(-time.Minute).Format("2006-01-02 15:04:05"))
+
+	if taken, err := TakePoolSnapshots(now); err != nil || taken != 1 {
+		t.Fatalf("Expected one snapshot, got %d, %v", taken, err)
+	}
+	if taken, _ := TakePoolSnapshots(now.Add(time.Minute)); taken != 0 {
+		t.Errorf("Expected unchanged pools not to be snapshotted again, got %d", taken)
+	}
+
+	PlaceBet(ctx, bob.ID, market.ID, "NO", 300)
+	TakePoolSnapshots(now.Add(20 * time.Minute))
+
+	positions, err := ListOddsSwingPositions()
+	if err != nil {
+		t.Fatalf("ListOddsSwingPositions failed: %v", err)
+	}
+	// Bob's bet came after the first snapshot, so his baseline is the second one
+	if len(positions) != 2 {
+		t.Fatalf("Expected positions for alice and bob, got %+v", positions)
+	}
+	a, b := positions[0], positions[1]
+	if a.UserID != alice.ID || a.Yes != 100 || a.BaselineYes != 100 || a.CurrentYes != 25 {
+		t.Errorf("Unexpected position for alice %+v", a)
+	}
+	if b.UserID != bob.ID || b.No != 300 || b.BaselineYes != 25 || b.CurrentYes != 25 {
+		t.Errorf("Unexpected position for bob %+v", b)
+	}
+
+	// An alert moves the baseline, and opting out drops the position
+	RecordOddsAlert(alice.ID, market.ID, 25, now.Add(30*time.Minute))
+	SetOddsSwingNotifications(bob.ID, false)
+	positions, _ = ListOddsSwingPositions()
+	if len(positions) != 1 || positions[0].UserID != alice.ID || positions[0].BaselineYes != 25 {
+		t.Errorf("Expected only alice measured from her alert, got %+v", positions)
+	}
+
+	// Snapshots and alerts of closed markets are dropped
+	UpdateMarketStatus(market.ID, MarketStatusLocked, "")
+	TakePoolSnapshots(now.Add(40 * time.Minute))
+	var count int
+	db.QueryRow(`SELECT (SELECT COUNT(*) FROM pool_snapshots) + (SELECT COUNT(*) FROM odds_alerts)`).Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected closed market snapshots and alerts to be pruned, %d left", count)
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// OnboardingStep is where a user is in the bot's onboarding conversation
+type OnboardingStep string
+
+const (
+	// OnboardingNone is the step of users who were never onboarded
+	OnboardingNone OnboardingStep = ""
+	// OnboardingCurrency explains the play currency and the welcome bonus
+	OnboardingCurrency OnboardingStep = "CURRENCY"
+	// OnboardingDemo shows a market to place a first bet on
+	OnboardingDemo OnboardingStep = "DEMO"
+	// OnboardingChannel invites the user to the announcements channel
+	OnboardingChannel OnboardingStep = "CHANNEL"
+	// OnboardingDone is the step of users who finished or skipped onboarding
+	OnboardingDone OnboardingStep = "DONE"
+)
+
+// StartOnboarding puts a user (internal ID) at the first onboarding step. Users who were
+// onboarded before are left where they are.
+func StartOnboarding(userID int64) error {
+	_, err := db.Exec(`
+		INSERT INTO user_onboarding (user_id, step) VALUES (?, ?)
+		ON CONFLICT(user_id) DO NOTHING
+	`, userID, OnboardingCurrency)
+	if err != nil {
+		return fmt.Errorf("failed to start onboarding: %w", err)
+	}
+	return nil
+}
+
+// GetOnboardingStep returns a user's (internal ID) onboarding step, OnboardingNone if they were
+// never onboarded
+func GetOnboardingStep(userID int64) (OnboardingStep, error) {
+	var step string
+	err := db.QueryRow(`SELECT step FROM user_onboarding WHERE user_id = ?`, userID).Scan(&step)
+	if err == sql.ErrNoRows {
+		return OnboardingNone, nil
+	}
+	if err != nil {
+		return OnboardingNone, fmt.Errorf("failed to get onboarding step: %w", err)
+	}
+	return OnboardingStep(step), nil
+}
+
+// AdvanceOnboarding moves a user (internal ID) from one onboarding step to the next. It reports
+// false when the user is no longer at from, so a button tapped twice advances only once.
+func AdvanceOnboarding(userID int64, from, to OnboardingStep) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE user_onboarding SET step = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND step = ?
+	`, to, userID, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to advance onboarding: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
@@ -0,0 +1,34 @@
+package storage
+
+import "testing"
+
+func TestOnboardingSteps(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := CreateUser(1, "newbie", "Newbie")
+	if step, err := GetOnboardingStep(user.ID); err != nil || step != OnboardingNone {
+		t.Fatalf("Expected a user who was never onboarded, got %q (%v)", step, err)
+	}
+
+	if err := StartOnboarding(user.ID); err != nil {
+		t.Fatalf("StartOnboarding failed: %v", err)
+	}
+	if step, _ := GetOnboardingStep(user.ID); step != OnboardingCurrency {
+		t.Fatalf("Expected onboarding to start with the currency, got %q", step)
+	}
+
+	if ok, err := AdvanceOnboarding(user.ID, OnboardingCurrency, OnboardingDemo); err != nil || !ok {
+		t.Fatalf("Expected to advance to the demo, got %v (%v)", ok, err)
+	}
+	// A second tap on the same button finds the user already moved on
+	if ok, _ := AdvanceOnboarding(user.ID, OnboardingCurrency, OnboardingDemo); ok {
+		t.Error("Expected a stale step not to advance")
+	}
+
+	// Starting again doesn't rewind a user part way through
+	StartOnboarding(user.ID)
+	if step, _ := GetOnboardingStep(user.ID); step != OnboardingDemo {
+		t.Errorf("Expected the user to stay at the demo, got %q", step)
+	}
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// OracleStatus is where an HTTP-JSON oracle is in its review and resolution
+type OracleStatus string
+
+const (
+	// OraclePendingReview waits for an admin to approve or reject the mapping
+	OraclePendingReview OracleStatus = "PENDING_REVIEW"
+	// OracleApproved resolves its market once the market locks
+	OracleApproved OracleStatus = "APPROVED"
+	// OracleRejected was turned down; its market is resolved by hand
+	OracleRejected OracleStatus = "REJECTED"
+	// OracleResolved resolved its market, with the fetched payload kept as evidence
+	OracleResolved OracleStatus = "RESOLVED"
+	// OracleFailed gave up after repeated fetch or evaluation errors; its market is resolved by hand
+	OracleFailed OracleStatus = "FAILED"
+)
+
+// Valid reports whether s is a known oracle status
+func (s OracleStatus) Valid() bool {
+	switch s {
+	case OraclePendingReview, OracleApproved, OracleRejected, OracleResolved, OracleFailed:
+		return true
+	}
+	return false
+}
+
+// MarketOracle is an HTTP-JSON oracle: the market resolves YES when the value at Path in the
+// JSON document at URL compares to Value with Operator, and NO otherwise
+type MarketOracle struct {
+	MarketID int64        `json:"market_id"`
+	URL      string       `json:"url"`
+	Path     string       `json:"path"`
+	Operator string       `json:"operator"`
+	Value    string       `json:"value"`
+	Status   OracleStatus `json:"status"`
+	// ReviewedBy is the internal ID of the admin who approved or rejected the mapping
+	ReviewedBy int64 `json:"reviewed_by,omitempty"`
+	// Attempts counts the failed fetches; LastError is the latest failure
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+	// FetchedAt, Observed, Outcome and Evidence are set once the oracle resolved its market:
+	// Observed is the value found at Path and Evidence the raw payload it was read from
+	FetchedAt *time.Time `json:"fetched_at,omitempty"`
+	Observed  string     `json:"observed,omitempty"`
+	Outcome   string     `json:"outcome,omitempty"`
+	Evidence  string     `json:"evidence,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+const oracleColumns = `market_id, url, path, operator, value, status, COALESCE(reviewed_by, 0), attempts,
+	COALESCE(last_error, ''), fetched_at, COALESCE(observed, ''), COALESCE(outcome, ''), COALESCE(evidence, ''), created_at`
+
+func scanMarketOracle(row interface{ Scan(...interface{}) error }) (*MarketOracle, error) {
+	var o MarketOracle
+	var fetchedAt sql.NullTime
+	err := row.Scan(&o.MarketID, &o.URL, &o.Path, &o.Operator, &o.Value, &o.Status, &o.ReviewedBy, &o.Attempts,
+		&o.LastError, &fetchedAt, &o.Observed, &o.Outcome, &o.Evidence, &o.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if fetchedAt.Valid {
+		o.FetchedAt = &fetchedAt.Time
+	}
+	return &o, nil
+}
+
+// SetMarketOracle sets the oracle of a market, replacing the one it had. The new mapping waits
+// for review again.
+func SetMarketOracle(marketID int64, url, path, operator, value string) error {
+	_, err := db.Exec(`
+		INSERT INTO market_oracles (market_id, url, path, operator, value)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(market_id) DO UPDATE SET
+			url = excluded.url, path = excluded.path, operator = excluded.operator, value = excluded.value,
+			status = ?, reviewed_by = NULL, attempts = 0, last_error = NULL,
+			fetched_at = NULL, observed = NULL, outcome = NULL, evidence = NULL,
+			updated_at = CURRENT_TIMESTAMP
+	`, marketID, url, path, operator, value, OraclePendingReview)
+	if err != nil {
+		return fmt.Errorf("failed to set market oracle: %w", err)
+	}
+	return nil
+}
+
+// GetMarketOracle returns the oracle of a market, or nil if it has none
+func GetMarketOracle(marketID int64) (*MarketOracle, error) {
+	o, err := scanMarketOracle(db.QueryRow(`SELECT `+oracleColumns+` FROM market_oracles WHERE market_id = ?`, marketID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market oracle: %w", err)
+	}
+	return o, nil
+}
+
+// ListMarketOracles returns the oracles in a status, oldest first
+func ListMarketOracles(status OracleStatus, limit int) ([]MarketOracle, error) {
+	return queryMarketOracles(`
+		SELECT `+oracleColumns+`
+		FROM market_oracles
+		WHERE status = ?
+		ORDER BY created_at, market_id
+		LIMIT ?
+	`, status, limit)
+}
+
+// ListDueMarketOracles returns the approved oracles whose market has locked
+func ListDueMarketOracles() ([]MarketOracle, error) {
+	return queryMarketOracles(`
+		SELECT `+oracleColumns+`
+		FROM market_oracles
+		WHERE status = ? AND market_id IN (SELECT id FROM markets WHERE status = ?)
+		ORDER BY market_id
+	`, OracleApproved, MarketStatusLocked)
+}
+
+func queryMarketOracles(query string, args ...interface{}) ([]MarketOracle, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list market oracles: %w", err)
+	}
+	defer rows.Close()
+
+	var oracles []MarketOracle
+	for rows.Next() {
+		o, err := scanMarketOracle(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan market oracle: %w", err)
+		}
+		oracles = append(oracles, *o)
+	}
+	return oracles, rows.Err()
+}
+
+// ApproveMarketOracle approves the mapping of an oracle waiting for review
+func ApproveMarketOracle(marketID, reviewerID int64) error {
+	return reviewMarketOracle(marketID, OracleApproved, reviewerID)
+}
+
+// RejectMarketOracle turns down the mapping of an oracle waiting for review
+func RejectMarketOracle(marketID, reviewerID int64) error {
+	return reviewMarketOracle(marketID, OracleRejected, reviewerID)
+}
+
+func reviewMarketOracle(marketID int64, status OracleStatus, reviewerID int64) error {
+	result, err := db.Exec(`
+		UPDATE market_oracles
+		SET status = ?, reviewed_by = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE market_id = ? AND status = ?
+	`, status, reviewerID, marketID, OraclePendingReview)
+	if err != nil {
+		return fmt.Errorf("failed to review market oracle: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("oracle not pending review")
+	}
+	return nil
+}
+
+// RecordMarketOracleFailure counts a failed fetch of an approved oracle; after maxAttempts
+// failures the oracle is marked FAILED
+func RecordMarketOracleFailure(marketID int64, message string, maxAttempts int) error {
+	_, err := db.Exec(`
+		UPDATE market_oracles
+		SET attempts = attempts + 1, last_error = ?,
+			status = CASE WHEN attempts + 1 >= ? THEN ? ELSE status END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE market_id = ? AND status = ?
+	`, message, maxAttempts, OracleFailed, marketID, OracleApproved)
+	if err != nil {
+		return fmt.Errorf("failed to record market oracle failure: %w", err)
+	}
+	return nil
+}
+
+// ResolveMarketOracle records what an approved oracle fetched and the outcome it resolved with
+func ResolveMarketOracle(marketID int64, fetchedAt time.Time, observed, outcome, evidence string) error {
+	result, err := db.Exec(`
+		UPDATE market_oracles
+		SET status = ?, fetched_at = ?, observed = ?, outcome = ?, evidence = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE market_id = ? AND status = ?
+	`, OracleResolved, sqlTimestamp(fetchedAt), observed, outcome, evidence, marketID, OracleApproved)
+	if err != nil {
+		return fmt.Errorf("failed to resolve market oracle: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("oracle not approved")
+	}
+	return nil
+}
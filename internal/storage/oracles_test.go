@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarketOracles(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin, _ := CreateUser(4901, "admin", "Admin")
+	market, _ := CreateMarket(admin.ID, "Will BTC close above 100k?", time.Now().Add(time.Hour))
+
+	if o, err := GetMarketOracle(market.ID); err != nil || o != nil {
+		t.Fatalf("Expected no oracle, got %+v, %v", o, err)
+	}
+	if err := SetMarketOracle(market.ID, "https://api.example.com/btc", "$.price", ">", "100000"); err != nil {
+		t.Fatalf("SetMarketOracle failed: %v", err)
+	}
+	if err := ApproveMarketOracle(market.ID, admin.ID); err != nil {
+		t.Fatalf("ApproveMarketOracle failed: %v", err)
+	}
+	if err := RejectMarketOracle(market.ID, admin.ID); err == nil {
+		t.Error("Expected a reviewed oracle not to be reviewed again")
+	}
+
+	// A new mapping waits for review again
+	SetMarketOracle(market.ID, "https://api.example.com/btc", "$.data.close", ">=", "100000")
+	o, _ := GetMarketOracle(market.ID)
+	if o.Status != OraclePendingReview || o.Path != "$.data.close" || o.ReviewedBy != 0 {
+		t.Errorf("Unexpected oracle %+v", o)
+	}
+	if pending, _ := ListMarketOracles(OraclePendingReview, 10); len(pending) != 1 {
+		t.Errorf("Expected one oracle waiting for review, got %+v", pending)
+	}
+	ApproveMarketOracle(market.ID, admin.ID)
+
+	// Only oracles of locked markets are due
+	if due, _ := ListDueMarketOracles(); len(due) != 0 {
+		t.Errorf("Expected no due oracles while the market is open, got %+v", due)
+	}
+	UpdateMarketStatus(market.ID, MarketStatusLocked, "")
+	if due, _ := ListDueMarketOracles(); len(due) != 1 || due[0].MarketID != market.ID {
+		t.Errorf("Expected the oracle to be due, got %+v", due)
+	}
+
+	RecordMarketOracleFailure(market.ID, "status 503", 2)
+	if o, _ = GetMarketOracle(market.ID); o.Status != OracleApproved || o.Attempts != 1 || o.LastError != "status 503" {
+		t.Errorf("Unexpected oracle after a failure %+v", o)
+	}
+
+	fetchedAt := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := ResolveMarketOracle(market.ID, fetchedAt, "101000", "YES", `{"data":{"close":101000}}`); err != nil {
+		t.Fatalf("ResolveMarketOracle failed: %v", err)
+	}
+	o, _ = GetMarketOracle(market.ID)
+	if o.Status != OracleResolved || o.Observed != "101000" || o.Outcome != "YES" || o.FetchedAt == nil ||
+		!o.FetchedAt.Equal(fetchedAt) || o.Evidence != `{"data":{"close":101000}}` {
+		t.Errorf("Unexpected resolved oracle %+v", o)
+	}
+	if err := ResolveMarketOracle(market.ID, fetchedAt, "1", "NO", "{}"); err == nil {
+		t.Error("Expected a resolved oracle not to resolve again")
+	}
+}
+
+func TestMarketOracleGivesUp(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin, _ := CreateUser(4902, "admin", "Admin")
+	market, _ := CreateMarket(admin.ID, "Will the API answer?", time.Now().Add(time.Hour))
+	SetMarketOracle(market.ID, "https://api.example.com/x", "$.ok", "==", "true")
+	ApproveMarketOracle(market.ID, admin.ID)
+
+	RecordMarketOracleFailure(market.ID, "timeout", 2)
+	RecordMarketOracleFailure(market.ID, "timeout", 2)
+	if o, _ := GetMarketOracle(market.ID); o.Status != OracleFailed || o.Attempts != 2 {
+		t.Errorf("Expected the oracle to give up, got %+v", o)
+	}
+}
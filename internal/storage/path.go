@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// dbFileName is the SQLite file name used by the default paths
+const dbFileName = "market.db"
+
+// DefaultDBPath returns the database path used when neither --db nor DATABASE_PATH is set.
+// It prefers $XDG_DATA_HOME/predictionbot and falls back to ./data, which works on
+// Linux, macOS and Windows alike. The container sets DATABASE_PATH explicitly.
+func DefaultDBPath() string {
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "predictionbot", dbFileName)
+	}
+	return filepath.Join("data", dbFileName)
+}
+
+// prepareDBPath creates the database directory if needed and checks that it can be written,
+// so a bad path fails at startup with a clear message instead of on the first write.
+func prepareDBPath(dbPath string) error {
+	absPath, err := filepath.Abs(dbPath)
+	if err != nil {
+		return fmt.Errorf("invalid database path %s: %w", dbPath, err)
+	}
+
+	dir := filepath.Dir(absPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create database directory %s: %w", dir, err)
+	}
+
+	probe, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return fmt.Errorf("database directory %s is not writable: %w", dir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	if _, err := os.Stat(absPath); err == nil {
+		f, err := os.OpenFile(absPath, os.O_RDWR, 0)
+		if err != nil {
+			return fmt.Errorf("database file %s is not writable: %w", absPath, err)
+		}
+		f.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultDBPath(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "")
+	if got := DefaultDBPath(); got != filepath.Join("data", "market.db") {
+		t.Errorf("Expected ./data fallback, got %s", got)
+	}
+
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	expected := filepath.Join(dataHome, "predictionbot", "market.db")
+	if got := DefaultDBPath(); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestInitDBCreatesDirectory(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "nested", "dir", "market.db")
+
+	if err := InitDB(dbPath); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer CloseDB()
+
+	if _, err := os.Stat(dbPath); err != nil {
+		t.Errorf("Expected database file to exist: %v", err)
+	}
+}
+
+func TestInitDBUnwritablePath(t *testing.T) {
+	// A regular file where a directory is expected cannot be created, even as root
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := InitDB(filepath.Join(blocker, "market.db"))
+	if err == nil {
+		CloseDB()
+		t.Fatal("Expected error for unwritable path")
+	}
+	if !strings.Contains(err.Error(), "database directory") {
+		t.Errorf("Expected error to name the database directory, got: %v", err)
+	}
+}
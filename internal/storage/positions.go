@@ -0,0 +1,43 @@
+package storage
+
+import "fmt"
+
+// MarketPosition is one bettor's total stake on each side of a market
+type MarketPosition struct {
+	UserID     int64
+	TelegramID int64
+	Yes        int64
+	No         int64
+}
+
+// ListLockSummaryPositions returns the position of every bettor on a market who wants a summary
+// when it locks and has not muted their DMs, in the order they first bet
+func ListLockSummaryPositions(marketID int64) ([]MarketPosition, error) {
+	rows, err := db.Query(`
+		SELECT u.id, u.telegram_id,
+		       COALESCE(SUM(CASE WHEN b.outcome = 'YES' THEN b.amount ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN b.outcome = 'NO' THEN b.amount ELSE 0 END), 0)
+		FROM bets b
+		JOIN users u ON u.id = b.user_id
+		WHERE b.market_id = ? AND u.notify_lock_summaries = 1 AND u.notify_muted = 0
+		GROUP BY u.id, u.telegram_id
+		ORDER BY MIN(b.id)
+	`, marketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query positions: %w", err)
+	}
+	defer rows.Close()
+
+	var positions []MarketPosition
+	for rows.Next() {
+		var p MarketPosition
+		if err := rows.Scan(&p.UserID, &p.TelegramID, &p.Yes, &p.No); err != nil {
+			return nil, fmt.Errorf("failed to scan position: %w", err)
+		}
+		positions = append(positions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating positions: %w", err)
+	}
+	return positions, nil
+}
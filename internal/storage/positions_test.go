@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListLockSummaryPositions(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator, _ := CreateUser(1, "creator", "Creator")
+	hedger, _ := CreateUser(2, "hedger", "Hedger")
+	quiet, _ := CreateUser(3, "quiet", "Quiet")
+	market, _ := CreateMarket(creator.ID, "Will the positions add up?", time.Now().Add(time.Hour))
+
+	for _, bet := range []struct {
+		userID  int64
+		outcome string
+		amount  int64
+	}{{hedger.ID, "YES", 100}, {hedger.ID, "NO", 40}, {quiet.ID, "NO", 50}} {
+		if _, err := db.Exec(`INSERT INTO bets (user_id, market_id, outcome, amount) VALUES (?, ?, ?, ?)`, bet.userID, market.ID, bet.outcome, bet.amount); err != nil {
+			t.Fatalf("Failed to insert bet: %v", err)
+		}
+	}
+	if err := SetLockSummaries(quiet.ID, false); err != nil {
+		t.Fatalf("SetLockSummaries failed: %v", err)
+	}
+
+	positions, err := ListLockSummaryPositions(market.ID)
+	if err != nil {
+		t.Fatalf("ListLockSummaryPositions failed: %v", err)
+	}
+	if len(positions) != 1 {
+		t.Fatalf("Expected only the hedger's position, got %+v", positions)
+	}
+	if p := positions[0]; p.UserID != hedger.ID || p.TelegramID != 2 || p.Yes != 100 || p.No != 40 {
+		t.Errorf("Unexpected position %+v", p)
+	}
+}
+
+func TestListLockSummaryPositionsSkipsMuted(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator, _ := CreateUser(1, "creator", "Creator")
+	muted, _ := CreateUser(2, "muted", "Muted")
+	market, _ := CreateMarket(creator.ID, "Will the muted bettor hear about it?", time.Now().Add(time.Hour))
+	db.Exec(`INSERT INTO bets (user_id, market_id, outcome, amount) VALUES (?, ?, 'YES', 10)`, muted.ID, market.ID)
+
+	if err := SetNotificationsMuted(muted.ID, true); err != nil {
+		t.Fatalf("SetNotificationsMuted failed: %v", err)
+	}
+	if positions, _ := ListLockSummaryPositions(market.ID); len(positions) != 0 {
+		t.Errorf("Expected no summaries for a muted bettor, got %+v", positions)
+	}
+	if prefs, _ := GetUserPreferences(muted.ID); prefs == nil || !prefs.Muted {
+		t.Errorf("Expected the mute in the preferences, got %+v", prefs)
+	}
+
+	SetNotificationsMuted(muted.ID, false)
+	if positions, _ := ListLockSummaryPositions(market.ID); len(positions) != 1 {
+		t.Errorf("Expected the summary again after unmuting, got %+v", positions)
+	}
+}
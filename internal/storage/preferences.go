@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// UserPreferences are the per-user settings exposed at /api/me/preferences
+type UserPreferences struct {
+	// ShowInWinners names the user in winner lists and results posts
+	ShowInWinners bool `json:"show_in_winners"`
+	// StreakNotifications enables DMs about win streaks
+	StreakNotifications bool `json:"streak_notifications"`
+	// BetReceipts enables a DM confirming each bet placed in the Web App
+	BetReceipts bool `json:"bet_receipts"`
+	// LockSummaries enables a DM with the user's position when a market they bet on locks
+	LockSummaries bool `json:"lock_summaries"`
+	// OddsSwings enables a DM when the odds of a market the user bet on move far since their bet
+	OddsSwings bool `json:"odds_swings"`
+	// Muted silences every DM except payouts and refunds, whatever the settings above say
+	Muted bool `json:"muted"`
+	// Language is the preferred language for market questions, "" for the default
+	Language string `json:"language"`
+	// Timezone is the IANA timezone deadlines are read in, "" for UTC
+	Timezone string `json:"timezone"`
+	// DigestHour is the local hour of the daily digest DM, DigestOff if the user has not asked for it
+	DigestHour int `json:"digest_hour"`
+	// Transport is where the user's DMs go instead of Telegram ("" for Telegram), TransportTarget
+	// the address there such as a Matrix room or a webhook URL
+	Transport       string `json:"transport"`
+	TransportTarget string `json:"transport_target"`
+}
+
+// GetUserPreferences returns the preferences of a user (internal ID)
+func GetUserPreferences(userID int64) (*UserPreferences, error) {
+	var prefs UserPreferences
+	err := db.QueryRow(`SELECT show_in_winners, notify_streaks, notify_bet_receipts, notify_lock_summaries, notify_odds_swings, notify_muted, language, timezone, digest_hour, notify_transport, notify_target FROM users WHERE id = ?`, userID).Scan(&prefs.ShowInWinners, &prefs.StreakNotifications, &prefs.BetReceipts, &prefs.LockSummaries, &prefs.OddsSwings, &prefs.Muted, &prefs.Language, &prefs.Timezone, &prefs.DigestHour, &prefs.Transport, &prefs.TransportTarget)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	return &prefs, nil
+}
+
+// SetShowInWinners records whether a user (internal ID) agrees to be named in winner lists
+func SetShowInWinners(userID int64, show bool) error {
+	return setPreference(userID, "show_in_winners", show)
+}
+
+// SetStreakNotifications records whether a user (internal ID) wants streak DMs
+func SetStreakNotifications(userID int64, enabled bool) error {
+	return setPreference(userID, "notify_streaks", enabled)
+}
+
+// SetBetReceipts records whether a user (internal ID) wants a DM for each bet they place
+func SetBetReceipts(userID int64, enabled bool) error {
+	return setPreference(userID, "notify_bet_receipts", enabled)
+}
+
+// SetLockSummaries records whether a user (internal ID) wants a position summary when their markets lock
+func SetLockSummaries(userID int64, enabled bool) error {
+	return setPreference(userID, "notify_lock_summaries", enabled)
+}
+
+// SetOddsSwingNotifications records whether a user (internal ID) wants a DM when the odds of their markets swing
+func SetOddsSwingNotifications(userID int64, enabled bool) error {
+	return setPreference(userID, "notify_odds_swings", enabled)
+}
+
+// SetNotificationsMuted records whether a user (internal ID) muted every DM except payouts and refunds
+func SetNotificationsMuted(userID int64, muted bool) error {
+	return setPreference(userID, "notify_muted", muted)
+}
+
+// IsNotificationsMuted reports whether a user (internal ID) muted their DMs; unknown users are not muted
+func IsNotificationsMuted(userID int64) (bool, error) {
+	var muted bool
+	err := db.QueryRow(`SELECT notify_muted FROM users WHERE id = ?`, userID).Scan(&muted)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get mute setting: %w", err)
+	}
+	return muted, nil
+}
+
+// SetUserLanguage records the preferred language (a normalized code such as "de") of a user (internal ID)
+func SetUserLanguage(userID int64, language string) error {
+	result, err := db.Exec(`UPDATE users SET language = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, language, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update preferences: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// GetUserLanguage returns the preferred language of a user (internal ID), "" if unset or unknown
+func GetUserLanguage(userID int64) (string, error) {
+	var language string
+	err := db.QueryRow(`SELECT language FROM users WHERE id = ?`, userID).Scan(&language)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get language: %w", err)
+	}
+	return language, nil
+}
+
+// SetUserTimezone records the IANA timezone (e.g. "Europe/Berlin") of a user (internal ID)
+func SetUserTimezone(userID int64, timezone string) error {
+	result, err := db.Exec(`UPDATE users SET timezone = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, timezone, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update preferences: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// GetUserTimezone returns the timezone of a user (internal ID), "" if unset or unknown
+func GetUserTimezone(userID int64) (string, error) {
+	var timezone string
+	err := db.QueryRow(`SELECT timezone FROM users WHERE id = ?`, userID).Scan(&timezone)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get timezone: %w", err)
+	}
+	return timezone, nil
+}
+
+// SetNotificationTransport records where a user's (internal ID) DMs go instead of Telegram;
+// an empty transport goes back to Telegram
+func SetNotificationTransport(userID int64, transport, target string) error {
+	result, err := db.Exec(`UPDATE users SET notify_transport = ?, notify_target = ? WHERE id = ?`, transport, target, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update preferences: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// GetNotificationTransport returns the transport and target of a user (internal ID), "" for Telegram
+func GetNotificationTransport(userID int64) (string, string, error) {
+	var transport, target string
+	err := db.QueryRow(`SELECT notify_transport, notify_target FROM users WHERE id = ?`, userID).Scan(&transport, &target)
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get notification transport: %w", err)
+	}
+	return transport, target, nil
+}
+
+// setPreference updates one boolean preference column; column is never user input
+func setPreference(userID int64, column string, value bool) error {
+	result, err := db.Exec(fmt.Sprintf(`UPDATE users SET %s = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, column), value, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update preferences: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
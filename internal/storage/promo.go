@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// AuditEntityPromoCode is the audit log entity type of promo codes
+const AuditEntityPromoCode = "promo_code"
+
+// PromoCode credits a fixed amount to every user who redeems it, once per user
+type PromoCode struct {
+	ID     int64  `json:"id"`
+	Code   string `json:"code"`
+	Amount int64  `json:"amount"`
+	// MaxUses is how many users may redeem the code, 0 for no limit
+	MaxUses int64 `json:"max_uses"`
+	Uses    int64 `json:"uses"`
+	// ExpiresAt is when the code can no longer be redeemed, nil if never
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// PromoRedemption is the credit a user got for redeeming a promo code
+type PromoRedemption struct {
+	Code       string    `json:"code"`
+	Amount     int64     `json:"amount"`
+	NewBalance int64     `json:"new_balance"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+const promoCodeColumns = `id, code, amount, max_uses, uses, expires_at, created_at`
+
+func scanPromoCode(row interface{ Scan(...interface{}) error }) (*PromoCode, error) {
+	var p PromoCode
+	var expiresAt sql.NullTime
+	if err := row.Scan(&p.ID, &p.Code, &p.Amount, &p.MaxUses, &p.Uses, &expiresAt, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		p.ExpiresAt = &expiresAt.Time
+	}
+	return &p, nil
+}
+
+// NormalizePromoCode trims a promo code and upper-cases it; codes are matched case-insensitively
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// validPromoCode reports whether code is 3 to 32 letters, digits, dashes or underscores
+func validPromoCode(code string) bool {
+	if len(code) < 3 || len(code) > 32 {
+		return false
+	}
+	for _, r := range code {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// CreatePromoCode creates a promo code worth amount to each user who redeems it, for up to
+// maxUses users (0 for no limit). A zero expiresAt never expires. The creation is audited
+// under the admin (createdBy, internal ID).
+func CreatePromoCode(code string, amount, maxUses int64, expiresAt time.Time, createdBy int64) (*PromoCode, error) {
+	code = NormalizePromoCode(code)
+	if !validPromoCode(code) {
+		return nil, fmt.Errorf("invalid promo code: use 3 to 32 letters, digits, dashes or underscores")
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("invalid promo amount: must be greater than 0")
+	}
+	if maxUses < 0 {
+		return nil, fmt.Errorf("invalid promo usage limit: must not be negative")
+	}
+	var expires interface{}
+	if !expiresAt.IsZero() {
+		expires = expiresAt
+	}
+
+	var id int64
+	err := db.QueryRow(`
+		INSERT INTO promo_codes (code, amount, max_uses, expires_at, created_by) VALUES (?, ?, ?, ?, ?) RETURNING id
+	`, code, amount, maxUses, expires, createdBy).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("promo code %s already exists", code)
+		}
+		return nil, fmt.Errorf("failed to create promo code: %w", err)
+	}
+	p, err := scanPromoCode(db.QueryRow(`SELECT `+promoCodeColumns+` FROM promo_codes WHERE id = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promo code: %w", err)
+	}
+	details := fmt.Sprintf("code=%s amount=%d max_uses=%d", p.Code, p.Amount, p.MaxUses)
+	if err := LogAudit(createdBy, "promo_code_created", AuditEntityPromoCode, p.ID, details); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// ListPromoCodes returns every promo code, newest first
+func ListPromoCodes() ([]PromoCode, error) {
+	rows, err := db.Query(`SELECT ` + promoCodeColumns + ` FROM promo_codes ORDER BY id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query promo codes: %w", err)
+	}
+	defer rows.Close()
+
+	var codes []PromoCode
+	for rows.Next() {
+		p, err := scanPromoCode(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan promo code: %w", err)
+		}
+		codes = append(codes, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating promo codes: %w", err)
+	}
+	return codes, nil
+}
+
+// RedeemPromoCode credits a promo code's amount to a user (internal ID) and logs it as a PROMO
+// transaction. Each user redeems a code at most once; expired and used-up codes are refused.
+func RedeemPromoCode(ctx context.Context, userID int64, code string) (*PromoRedemption, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	p, err := scanPromoCode(tx.QueryRowContext(ctx, `SELECT `+promoCodeColumns+` FROM promo_codes WHERE code = ?`, NormalizePromoCode(code)))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("promo code not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promo code: %w", err)
+	}
+	if p.ExpiresAt != nil && !time.Now().Before(*p.ExpiresAt) {
+		return nil, fmt.Errorf("promo code expired")
+	}
+
+	var redeemed int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM promo_redemptions WHERE code_id = ? AND user_id = ?`, p.ID, userID).Scan(&redeemed); err != nil {
+		return nil, fmt.Errorf("failed to check redemptions: %w", err)
+	}
+	if redeemed > 0 {
+		return nil, fmt.Errorf("promo code already redeemed")
+	}
+	if p.MaxUses > 0 && p.Uses >= p.MaxUses {
+		return nil, fmt.Errorf("promo code used up")
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO promo_redemptions (code_id, user_id, amount) VALUES (?, ?, ?)
+	`, p.ID, userID, p.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record redemption: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE promo_codes SET uses = uses + 1 WHERE id = ?`, p.ID); err != nil {
+		return nil, fmt.Errorf("failed to count redemption: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET balance = balance + ? WHERE id = ?`, p.Amount, userID); err != nil {
+		return nil, fmt.Errorf("failed to update balance: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO transactions (user_id, amount, source_type, description)
+		VALUES (?, ?, 'PROMO', ?)
+	`, userID, p.Amount, fmt.Sprintf("Promo code %s", p.Code))
+	if err != nil {
+		return nil, fmt.Errorf("failed to log transaction: %w", err)
+	}
+
+	r := PromoRedemption{Code: p.Code, Amount: p.Amount, RedeemedAt: time.Now()}
+	if err := tx.QueryRowContext(ctx, `SELECT balance FROM users WHERE id = ?`, userID).Scan(&r.NewBalance); err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &r, nil
+}
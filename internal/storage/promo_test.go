@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedeemPromoCode(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	admin, _ := CreateUser(4201, "admin", "Admin")
+	first, _ := CreateUser(4202, "first", "First")
+	second, _ := CreateUser(4203, "second", "Second")
+
+	if _, err := CreatePromoCode("x", 50, 0, time.Time{}, admin.ID); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Errorf("Expected a too short code to be invalid, got %v", err)
+	}
+	code, err := CreatePromoCode(" launch-50 ", 50, 1, time.Time{}, admin.ID)
+	if err != nil {
+		t.Fatalf("CreatePromoCode failed: %v", err)
+	}
+	if code.Code != "LAUNCH-50" {
+		t.Errorf("Expected the code to be normalized, got %q", code.Code)
+	}
+	if _, err := CreatePromoCode("Launch-50", 10, 0, time.Time{}, admin.ID); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected a duplicate code to be refused, got %v", err)
+	}
+
+	redemption, err := RedeemPromoCode(ctx, first.ID, "launch-50")
+	if err != nil {
+		t.Fatalf("RedeemPromoCode failed: %v", err)
+	}
+	if redemption.Amount != 50 || redemption.NewBalance != WelcomeBonusAmount+50 {
+		t.Errorf("Expected 50 credited, got %+v", redemption)
+	}
+	var source string
+	db.QueryRow(`SELECT source_type FROM transactions WHERE user_id = ? ORDER BY id DESC LIMIT 1`, first.ID).Scan(&source)
+	if source != "PROMO" {
+		t.Errorf("Expected a PROMO transaction, got %q", source)
+	}
+
+	if _, err := RedeemPromoCode(ctx, first.ID, "LAUNCH-50"); err == nil || !strings.Contains(err.Error(), "already redeemed") {
+		t.Errorf("Expected a second redemption to be refused, got %v", err)
+	}
+	if _, err := RedeemPromoCode(ctx, second.ID, "LAUNCH-50"); err == nil || !strings.Contains(err.Error(), "used up") {
+		t.Errorf("Expected the usage limit to be reached, got %v", err)
+	}
+	if _, err := RedeemPromoCode(ctx, second.ID, "NOPE"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected an unknown code not to be found, got %v", err)
+	}
+
+	CreatePromoCode("OLD", 10, 0, time.Now().Add(-time.Minute), admin.ID)
+	if _, err := RedeemPromoCode(ctx, second.ID, "old"); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Expected an expired code to be refused, got %v", err)
+	}
+	user, _ := GetUserByID(second.ID)
+	if user.Balance != WelcomeBonusAmount {
+		t.Errorf("Expected refused codes not to credit anything, got balance %d", user.Balance)
+	}
+
+	codes, _ := ListPromoCodes()
+	if len(codes) != 2 || codes[1].Uses != 1 {
+		t.Errorf("Expected 2 codes, the first used once, got %+v", codes)
+	}
+}
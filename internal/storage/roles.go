@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Role represents an elevated role a user can hold
+type Role string
+
+const (
+	// RoleAdmin can do everything, including adjusting balances and managing roles
+	RoleAdmin Role = "admin"
+	// RoleModerator can hide markets but cannot touch balances or roles
+	RoleModerator Role = "moderator"
+	// RoleOracle can resolve locked markets it did not create
+	RoleOracle Role = "oracle"
+)
+
+// AuditEntityUser is the audit log entity type for users
+const AuditEntityUser = "user"
+
+// RoleAssignment represents a role held by a user
+type RoleAssignment struct {
+	UserID     int64     `json:"user_id"`
+	TelegramID int64     `json:"telegram_id"`
+	Username   string    `json:"username"`
+	FirstName  string    `json:"first_name"`
+	Role       Role      `json:"role"`
+	GrantedBy  int64     `json:"granted_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// IsValidRole reports whether the role is one of the known roles
+func IsValidRole(role Role) bool {
+	switch role {
+	case RoleAdmin, RoleModerator, RoleOracle:
+		return true
+	}
+	return false
+}
+
+// GrantRole gives a role to a user (internal IDs). Granting a role the user already holds is a no-op.
+func GrantRole(userID int64, role Role, grantedBy int64) error {
+	if !IsValidRole(role) {
+		return fmt.Errorf("invalid role: %s", role)
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO user_roles (user_id, role, granted_by)
+		VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`, userID, role, grantedBy)
+	if err != nil {
+		return fmt.Errorf("failed to grant role: %w", err)
+	}
+
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		return LogAudit(grantedBy, "role_granted", AuditEntityUser, userID, fmt.Sprintf("role=%s", role))
+	}
+	return nil
+}
+
+// RevokeRole removes a role from a user (internal IDs)
+func RevokeRole(userID int64, role Role, revokedBy int64) error {
+	if !IsValidRole(role) {
+		return fmt.Errorf("invalid role: %s", role)
+	}
+
+	result, err := db.Exec(`DELETE FROM user_roles WHERE user_id = ? AND role = ?`, userID, role)
+	if err != nil {
+		return fmt.Errorf("failed to revoke role: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("role not found: user does not hold %s", role)
+	}
+
+	return LogAudit(revokedBy, "role_revoked", AuditEntityUser, userID, fmt.Sprintf("role=%s", role))
+}
+
+// GetUserRoles returns the roles held by a user (internal ID)
+func GetUserRoles(userID int64) ([]Role, error) {
+	rows, err := db.Query(`SELECT role FROM user_roles WHERE user_id = ? ORDER BY role`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []Role
+	for rows.Next() {
+		var role Role
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+// ListRoleAssignments returns every role assignment with user details
+func ListRoleAssignments() ([]RoleAssignment, error) {
+	rows, err := db.Query(`
+		SELECT r.user_id, u.telegram_id, u.username, u.first_name, r.role, r.granted_by, r.created_at
+		FROM user_roles r
+		JOIN users u ON r.user_id = u.id
+		ORDER BY r.role, r.created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query role assignments: %w", err)
+	}
+	defer rows.Close()
+
+	var assignments []RoleAssignment
+	for rows.Next() {
+		var a RoleAssignment
+		var username sql.NullString
+		if err := rows.Scan(&a.UserID, &a.TelegramID, &username, &a.FirstName, &a.Role, &a.GrantedBy, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan role assignment: %w", err)
+		}
+		if username.Valid {
+			a.Username = username.String
+		}
+		assignments = append(assignments, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating role assignments: %w", err)
+	}
+
+	return assignments, nil
+}
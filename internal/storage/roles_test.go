@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGrantAndRevokeRole(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	admin, _ := CreateUser(4001, "admin", "Admin")
+	user, _ := CreateUser(4002, "user", "User")
+
+	if err := GrantRole(user.ID, RoleModerator, admin.ID); err != nil {
+		t.Fatalf("GrantRole failed: %v", err)
+	}
+	// Granting twice is a no-op
+	if err := GrantRole(user.ID, RoleModerator, admin.ID); err != nil {
+		t.Fatalf("GrantRole (repeat) failed: %v", err)
+	}
+	if err := GrantRole(user.ID, Role("superuser"), admin.ID); err == nil {
+		t.Error("Expected error for unknown role")
+	}
+
+	roles, err := GetUserRoles(user.ID)
+	if err != nil {
+		t.Fatalf("GetUserRoles failed: %v", err)
+	}
+	if len(roles) != 1 || roles[0] != RoleModerator {
+		t.Errorf("Expected [moderator], got %v", roles)
+	}
+
+	assignments, _ := ListRoleAssignments()
+	if len(assignments) != 1 || assignments[0].TelegramID != 4002 {
+		t.Errorf("Expected one assignment for telegram_id 4002, got %+v", assignments)
+	}
+
+	if err := RevokeRole(user.ID, RoleModerator, admin.ID); err != nil {
+		t.Fatalf("RevokeRole failed: %v", err)
+	}
+	if err := RevokeRole(user.ID, RoleModerator, admin.ID); err == nil {
+		t.Error("Expected error when revoking a role the user does not hold")
+	}
+
+	entries, _ := GetAuditLog(AuditEntityUser, user.ID)
+	if len(entries) != 2 || entries[0].Action != "role_granted" || entries[1].Action != "role_revoked" {
+		t.Errorf("Unexpected audit entries: %+v", entries)
+	}
+}
+
+func TestHiddenMarketRejectsBets(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := CreateUser(4101, "bettor", "Bettor")
+	market, _ := CreateMarket(user.ID, "Will this market be hidden?", time.Now().Add(time.Hour))
+
+	if err := SetMarketHidden(market.ID, true, user.ID); err != nil {
+		t.Fatalf("SetMarketHidden failed: %v", err)
+	}
+
+	hidden, _ := GetMarketByID(market.ID)
+	if !hidden.Hidden {
+		t.Error("Expected market to be hidden")
+	}
+
+	if err := PlaceBet(context.Background(), user.ID, market.ID, "YES", 10); err == nil {
+		t.Error("Expected error when betting on a hidden market")
+	}
+
+	if err := SetMarketHidden(9999, true, user.ID); err == nil {
+		t.Error("Expected error for missing market")
+	}
+}
+
+func TestAdjustBalance(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	admin, _ := CreateUser(4201, "admin", "Admin")
+	user, _ := CreateUser(4202, "user", "User")
+
+	balance, err := AdjustBalance(ctx, user.ID, 250, "compensation", admin.ID)
+	if err != nil {
+		t.Fatalf("AdjustBalance failed: %v", err)
+	}
+	if balance != WelcomeBonusAmount+250 {
+		t.Errorf("Expected balance %d, got %d", WelcomeBonusAmount+250, balance)
+	}
+
+	if _, err := AdjustBalance(ctx, user.ID, -5000, "too much", admin.ID); err == nil {
+		t.Error("Expected error when balance would go negative")
+	}
+	if _, err := AdjustBalance(ctx, user.ID, 0, "nothing", admin.ID); err == nil {
+		t.Error("Expected error for zero amount")
+	}
+}
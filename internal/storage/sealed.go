@@ -0,0 +1,14 @@
+package storage
+
+// sidesHiddenSQL is true for sealed markets (alias m) that have not been finalized yet
+const sidesHiddenSQL = `(m.sealed = 1 AND m.status IN ('ACTIVE', 'LAST_CALL', 'LOCKED', 'RESOLVED', 'DISPUTED'))`
+
+// SidesHidden reports whether the market hides which side stakes are on: sealed markets
+// only show their total pool until they are finalized
+func (m *Market) SidesHidden() bool {
+	switch m.Status {
+	case MarketStatusActive, MarketStatusLastCall, MarketStatusLocked, MarketStatusResolved, MarketStatusDisputed:
+		return m.Sealed
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSealedMarketPools(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(4001, "creator", "Creator")
+	yes, _ := CreateUser(4002, "yes", "Yes")
+	no, _ := CreateUser(4003, "no", "No")
+
+	market, err := CreateMarketWithOptions(creator.ID, "Will the sealed market keep its secrets?", time.Now().Add(time.Hour), MarketOptions{Sealed: true})
+	if err != nil {
+		t.Fatalf("CreateMarketWithOptions failed: %v", err)
+	}
+	if !market.Sealed || !market.SidesHidden() || market.PoolsHidden() {
+		t.Fatalf("Expected a sealed market, got %+v", market)
+	}
+	PlaceBet(ctx, yes.ID, market.ID, "YES", 100)
+	PlaceBet(ctx, no.ID, market.ID, "NO", 50)
+
+	pools, err := GetPublicPools(market.ID)
+	if err != nil || !pools.SidesHidden || pools.Yes != 0 || pools.No != 0 || pools.Total != 150 {
+		t.Errorf("Expected only the total, got %+v (err=%v)", pools, err)
+	}
+	markets, _ := ListActiveMarketsWithCreator()
+	if len(markets) != 1 || !markets[0].SidesHidden || markets[0].PoolYes != 0 || markets[0].PoolTotal != 150 {
+		t.Errorf("Expected sealed market in list, got %+v", markets)
+	}
+	bets, _ := GetUserActiveBets(yes.ID)
+	if len(bets) != 1 || !bets[0].SidesHidden || bets[0].PoolNo != 0 || bets[0].PoolTotal != 150 || bets[0].OutcomeChosen != "YES" {
+		t.Errorf("Expected sealed active bet with the bettor's own side, got %+v", bets)
+	}
+
+	// Sides stay hidden through resolution and are revealed at finalization
+	UpdateMarketStatus(market.ID, MarketStatusLocked, "")
+	UpdateMarketStatus(market.ID, MarketStatusResolved, "YES")
+	if pools, _ = GetPublicPools(market.ID); !pools.SidesHidden {
+		t.Errorf("Expected sides hidden after resolution, got %+v", pools)
+	}
+	UpdateMarketStatus(market.ID, MarketStatusFinalized, "")
+	if pools, _ = GetPublicPools(market.ID); pools.SidesHidden || pools.Yes != 100 || pools.No != 50 {
+		t.Errorf("Expected revealed sides after finalization, got %+v", pools)
+	}
+}
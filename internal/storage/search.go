@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// marketSearchFTS reports whether market questions are indexed with FTS5. PostgreSQL and SQLite
+// builds without the extension fall back to LIKE matching.
+var marketSearchFTS bool
+
+// marketSearchSchema is the FTS5 index of market questions and resolution criteria, kept in sync
+// with the markets table by triggers
+var marketSearchSchema = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS markets_fts USING fts5(
+		question, resolution_criteria, content = 'markets', content_rowid = 'id'
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS markets_fts_insert AFTER INSERT ON markets BEGIN
+		INSERT INTO markets_fts (rowid, question, resolution_criteria)
+		VALUES (new.id, new.question, new.resolution_criteria);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS markets_fts_delete AFTER DELETE ON markets BEGIN
+		INSERT INTO markets_fts (markets_fts, rowid, question, resolution_criteria)
+		VALUES ('delete', old.id, old.question, old.resolution_criteria);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS markets_fts_update AFTER UPDATE OF question, resolution_criteria ON markets BEGIN
+		INSERT INTO markets_fts (markets_fts, rowid, question, resolution_criteria)
+		VALUES ('delete', old.id, old.question, old.resolution_criteria);
+		INSERT INTO markets_fts (rowid, question, resolution_criteria)
+		VALUES (new.id, new.question, new.resolution_criteria);
+	END`,
+}
+
+// initMarketSearch creates the FTS5 index of markets, filling it from the existing markets.
+// The index is derived data outside the versioned migrations, so a SQLite without FTS5 still
+// starts and searches with LIKE instead.
+func initMarketSearch() error {
+	marketSearchFTS = false
+	if usingPostgres {
+		return nil
+	}
+
+	var markets int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'markets'`).Scan(&markets); err != nil {
+		return fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	if markets == 0 {
+		return nil
+	}
+
+	var indexed int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'markets_fts_%'`).Scan(&indexed); err != nil {
+		return fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	for _, stmt := range marketSearchSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			// No FTS5 in this SQLite build
+			return nil
+		}
+	}
+	if indexed < len(marketSearchSchema)-1 {
+		// New index, or one whose triggers went with a dropped markets table
+		if _, err := db.Exec(`INSERT INTO markets_fts (markets_fts) VALUES ('rebuild')`); err != nil {
+			return fmt.Errorf("failed to build market search index: %w", err)
+		}
+	}
+	marketSearchFTS = true
+	return nil
+}
+
+// searchTerms splits a search query into its words, dropping punctuation and FTS5 syntax
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// SearchMarkets returns up to limit markets whose question or resolution criteria contain every
+// word of query (words match as prefixes with FTS5), open markets first and then the best
+// matches. Markets hidden by a moderator and group chat markets are left out. A query without
+// words finds nothing.
+func SearchMarkets(query string, limit int) ([]MarketWithCreator, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	// matches yields (id, rank) for the matching markets, lower ranks first
+	var matches string
+	var args []interface{}
+	if marketSearchFTS {
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = `"` + term + `"*`
+		}
+		matches = `SELECT rowid AS id, bm25(markets_fts) AS rank FROM markets_fts WHERE markets_fts MATCH ?`
+		args = append(args, strings.Join(quoted, " "))
+	} else {
+		conditions := make([]string, len(terms))
+		for i, term := range terms {
+			conditions[i] = `LOWER(question || ' ' || COALESCE(resolution_criteria, '')) LIKE ? ESCAPE '\'`
+			args = append(args, "%"+escapeLike(term)+"%")
+		}
+		matches = `SELECT id, 0 AS rank FROM markets WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit)
+
+	rows, err := db.Query(`
+		WITH matches AS MATERIALIZED (`+matches+`)
+		SELECT m.id, m.question, `+creatorNameSQL+`, m.expires_at, m.status, m.lock_mode, m.pricing_mode, m.icon, `+poolsHiddenSQL+`, `+sidesHiddenSQL+`,
+		       COALESCE(SUM(CASE WHEN b.outcome = 'YES' THEN b.amount ELSE 0 END), 0) as pool_yes,
+		       COALESCE(SUM(CASE WHEN b.outcome = 'NO' THEN b.amount ELSE 0 END), 0) as pool_no,
+		       COALESCE(m.resolution_criteria, '')
+		FROM matches x
+		JOIN markets m ON m.id = x.id
+		LEFT JOIN users u ON m.creator_id = u.id
+		LEFT JOIN bets b ON m.id = b.market_id AND `+publicBetSQL+`
+		WHERE m.hidden = 0 AND m.group_id IS NULL
+		GROUP BY m.id, u.id
+		ORDER BY m.status IN ('ACTIVE', 'LAST_CALL') DESC, MIN(x.rank), m.id DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search markets: %w", err)
+	}
+	return scanMarketsWithCreator(rows)
+}
+
+// escapeLike escapes the LIKE wildcards in s for a pattern with ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
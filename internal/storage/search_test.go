@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearchMarkets(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	// PostgreSQL databases have no FTS5 index and always search with LIKE
+	modes := []bool{true, false}
+	if usingPostgres {
+		modes = []bool{false}
+	} else if !marketSearchFTS {
+		t.Fatal("Expected the FTS5 index to be available")
+	}
+	user, _ := CreateUser(4101, "seeker", "Seeker")
+	derby, _ := CreateMarket(user.ID, "Will United win the derby?", time.Now().Add(time.Hour))
+	rain, _ := CreateMarket(user.ID, "Will it rain in Manchester on Sunday?", time.Now().Add(time.Hour))
+	hidden, _ := CreateMarket(user.ID, "Will the derby be postponed?", time.Now().Add(time.Hour))
+	SetMarketHidden(hidden.ID, true, user.ID)
+
+	for _, fts := range modes {
+		marketSearchFTS = fts
+
+		markets, err := SearchMarkets("derby", 10)
+		if err != nil {
+			t.Fatalf("SearchMarkets failed (fts=%v): %v", fts, err)
+		}
+		if len(markets) != 1 || markets[0].ID != derby.ID {
+			t.Errorf("Expected only the visible derby market (fts=%v), got %+v", fts, markets)
+		}
+
+		// Every word has to match, in any case and order
+		if markets, _ := SearchMarkets("SUNDAY manchester", 10); len(markets) != 1 || markets[0].ID != rain.ID {
+			t.Errorf("Expected the rain market (fts=%v), got %+v", fts, markets)
+		}
+		if markets, _ := SearchMarkets("derby rain", 10); len(markets) != 0 {
+			t.Errorf("Expected no market with both words (fts=%v), got %+v", fts, markets)
+		}
+		// Query syntax is taken literally
+		if markets, err := SearchMarkets(`"derby"* ^(united)`, 10); err != nil || len(markets) != 1 {
+			t.Errorf("Expected operators to be ignored (fts=%v), got %+v, %v", fts, markets, err)
+		}
+		if markets, _ := SearchMarkets("%", 10); len(markets) != 0 {
+			t.Errorf("Expected a query without words to find nothing (fts=%v), got %+v", fts, markets)
+		}
+	}
+	marketSearchFTS = modes[0]
+
+	// The index follows edits of the question
+	db.Exec(`UPDATE markets SET question = 'Will City win the derby?' WHERE id = ?`, derby.ID)
+	if markets, _ := SearchMarkets("united", 10); len(markets) != 0 {
+		t.Errorf("Expected the old question not to match, got %+v", markets)
+	}
+	if markets, _ := SearchMarkets("cit", 10); len(markets) != 1 || markets[0].ID != derby.ID {
+		t.Errorf("Expected a prefix of the new question to match, got %+v", markets)
+	}
+}
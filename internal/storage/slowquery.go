@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"predictionbot/internal/logger"
+)
+
+// DefaultSlowQueryThreshold is how long a query may take before it is logged as slow
+const DefaultSlowQueryThreshold = 100 * time.Millisecond
+
+// slowQueryLimit caps how many distinct slow statements are kept for GET /api/admin/slow-queries
+const slowQueryLimit = 50
+
+// SlowQueryThreshold reads SLOW_QUERY_MS. SLOW_QUERY_MS=0 turns the slow query log off,
+// reported as a zero duration.
+func SlowQueryThreshold() time.Duration {
+	if ms, err := strconv.Atoi(os.Getenv("SLOW_QUERY_MS")); err == nil && ms >= 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return DefaultSlowQueryThreshold
+}
+
+// SlowQuery aggregates the slow runs of one SQL statement. Parameters are never recorded.
+type SlowQuery struct {
+	Query   string    `json:"query"`
+	Count   int64     `json:"count"`
+	TotalMs float64   `json:"total_ms"`
+	MaxMs   float64   `json:"max_ms"`
+	LastAt  time.Time `json:"last_at"`
+}
+
+// QueryMetrics are the query counters since the database was opened
+type QueryMetrics struct {
+	ThresholdMs float64 `json:"threshold_ms"`
+	Queries     int64   `json:"queries"`
+	TotalMs     float64 `json:"total_ms"`
+	SlowQueries int64   `json:"slow_queries"`
+	// Slowest lists the slow statements, slowest total first
+	Slowest []SlowQuery `json:"slowest"`
+}
+
+// queryRecorder times every statement and keeps the slow ones
+type queryRecorder struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	queries   int64
+	total     time.Duration
+	slowCount int64
+	slow      map[string]*SlowQuery
+}
+
+var recorder = &queryRecorder{slow: make(map[string]*SlowQuery)}
+
+// record counts one statement that took d; slow ones are logged with their parameters redacted
+func (r *queryRecorder) record(query string, args int, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.queries++
+	r.total += d
+	if r.threshold <= 0 || d < r.threshold {
+		return
+	}
+
+	query = strings.Join(strings.Fields(query), " ")
+	r.slowCount++
+	ms := float64(d) / float64(time.Millisecond)
+	entry := r.slow[query]
+	if entry == nil {
+		if len(r.slow) >= slowQueryLimit {
+			r.evictFastest()
+		}
+		entry = &SlowQuery{Query: query}
+		r.slow[query] = entry
+	}
+	entry.Count++
+	entry.TotalMs += ms
+	if ms > entry.MaxMs {
+		entry.MaxMs = ms
+	}
+	entry.LastAt = time.Now().UTC()
+
+	logger.Debug(0, "slow_query", fmt.Sprintf("duration_ms=%.1f args=%s query=%s", ms, redactedArgs(args), query))
+}
+
+// evictFastest drops the slow statement with the least total time to make room for a new one
+func (r *queryRecorder) evictFastest() {
+	var fastest string
+	for query, entry := range r.slow {
+		if fastest == "" || entry.TotalMs < r.slow[fastest].TotalMs {
+			fastest = query
+		}
+	}
+	delete(r.slow, fastest)
+}
+
+// redactedArgs stands in for the parameter values, which may hold user data
+func redactedArgs(n int) string {
+	if n == 0 {
+		return "[]"
+	}
+	return "[" + strings.TrimSuffix(strings.Repeat("?, ", n), ", ") + "]"
+}
+
+// GetQueryMetrics returns the query counters and the slow statements seen so far
+func GetQueryMetrics() QueryMetrics {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	metrics := QueryMetrics{
+		ThresholdMs: float64(recorder.threshold) / float64(time.Millisecond),
+		Queries:     recorder.queries,
+		TotalMs:     float64(recorder.total) / float64(time.Millisecond),
+		SlowQueries: recorder.slowCount,
+		Slowest:     []SlowQuery{},
+	}
+	for _, entry := range recorder.slow {
+		metrics.Slowest = append(metrics.Slowest, *entry)
+	}
+	sort.Slice(metrics.Slowest, func(i, j int) bool { return metrics.Slowest[i].TotalMs > metrics.Slowest[j].TotalMs })
+	return metrics
+}
+
+// resetQueryMetrics clears the counters and applies the current SLOW_QUERY_MS; called when the database is opened
+func resetQueryMetrics() {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.threshold = SlowQueryThreshold()
+	recorder.queries = 0
+	recorder.total = 0
+	recorder.slowCount = 0
+	recorder.slow = make(map[string]*SlowQuery)
+}
+
+// openInstrumented opens the database through the SQLite driver, or the PostgreSQL one for a
+// postgres:// URL, wrapped so that every statement is timed
+func openInstrumented(dsn string) (*sql.DB, error) {
+	driverName, postgres := "sqlite", IsPostgresURL(dsn)
+	if postgres {
+		driverName = "pgx"
+		var err error
+		if dsn, err = postgresDSN(dsn); err != nil {
+			return nil, fmt.Errorf("invalid DATABASE_URL: %w", err)
+		}
+	}
+
+	// sql.Open does not connect; it is only used to get hold of the registered driver
+	base, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := base.Driver()
+	base.Close()
+
+	resetQueryMetrics()
+	usingPostgres = postgres
+	return sql.OpenDB(&timedConnector{name: dsn, driver: drv, postgres: postgres}), nil
+}
+
+// timedConnector opens connections of the wrapped driver as timedConn
+type timedConnector struct {
+	name     string
+	driver   driver.Driver
+	postgres bool
+}
+
+func (c *timedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.name)
+	if err != nil {
+		return nil, err
+	}
+	return &timedConn{Conn: conn, postgres: c.postgres}, nil
+}
+
+func (c *timedConnector) Driver() driver.Driver { return c.driver }
+
+// timedConn times the statements run on a connection. Both drivers implement every context
+// interface used here, so they are forwarded without fallbacks. On PostgreSQL it also rebinds
+// the ? placeholders, see rebind.
+type timedConn struct {
+	driver.Conn
+	postgres bool
+}
+
+// bind returns query as the wrapped driver expects it
+func (c *timedConn) bind(query string) string {
+	if c.postgres {
+		return rebind(query)
+	}
+	return query
+}
+
+func (c *timedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *timedConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+// ResetSession lets the PostgreSQL driver check a pooled connection before it is reused
+func (c *timedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue converts arguments the way database/sql does for SQLite. PostgreSQL keeps
+// flags in integer columns like SQLite, so booleans are passed as 0 or 1.
+func (c *timedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if !c.postgres {
+		return driver.ErrSkip
+	}
+	value, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	if flag, ok := value.(bool); ok {
+		value = int64(0)
+		if flag {
+			value = int64(1)
+		}
+	}
+	nv.Value = value
+	return nil
+}
+
+func (c *timedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, c.bind(query))
+	if err != nil {
+		return nil, err
+	}
+	return &timedStmt{Stmt: stmt, query: query}, nil
+}
+
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	result, err := c.Conn.(driver.ExecerContext).ExecContext(ctx, c.bind(query), args)
+	recorder.record(query, len(args), time.Since(start))
+	return result, err
+}
+
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, c.bind(query), args)
+	if err != nil {
+		recorder.record(query, len(args), time.Since(start))
+		return nil, err
+	}
+	return &timedRows{Rows: rows, query: query, args: len(args), elapsed: time.Since(start)}, nil
+}
+
+// timedStmt times a prepared statement
+type timedStmt struct {
+	driver.Stmt
+	query string
+}
+
+func (s *timedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	result, err := s.Stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+	recorder.record(s.query, len(args), time.Since(start))
+	return result, err
+}
+
+func (s *timedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+	if err != nil {
+		recorder.record(s.query, len(args), time.Since(start))
+		return nil, err
+	}
+	return &timedRows{Rows: rows, query: s.query, args: len(args), elapsed: time.Since(start)}, nil
+}
+
+// timedRows adds the time the driver spends stepping through the result to the query's time,
+// which is recorded when the rows are closed. Time the caller spends between rows is not counted.
+type timedRows struct {
+	driver.Rows
+	query   string
+	args    int
+	elapsed time.Duration
+	closed  bool
+}
+
+func (r *timedRows) Next(dest []driver.Value) error {
+	start := time.Now()
+	err := r.Rows.Next(dest)
+	r.elapsed += time.Since(start)
+	return err
+}
+
+func (r *timedRows) Close() error {
+	err := r.Rows.Close()
+	if !r.closed {
+		r.closed = true
+		recorder.record(r.query, r.args, r.elapsed)
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryMetrics(t *testing.T) {
+	t.Setenv("SLOW_QUERY_MS", "50")
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+	// Leave out the schema setup, which a PostgreSQL test database runs after opening
+	resetQueryMetrics()
+
+	if _, err := CreateUser(1, "alice", "Alice"); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if _, err := GetUserByTelegramID(1); err != nil {
+		t.Fatalf("GetUserByTelegramID failed: %v", err)
+	}
+
+	metrics := GetQueryMetrics()
+	if metrics.ThresholdMs != 50 {
+		t.Errorf("Expected a 50ms threshold, got %.0f", metrics.ThresholdMs)
+	}
+	if metrics.Queries == 0 {
+		t.Error("Expected the queries to be counted")
+	}
+
+	recorder.record("SELECT *\n\t\tFROM users WHERE telegram_id = ?", 1, 80*time.Millisecond)
+	recorder.record("SELECT * FROM users WHERE telegram_id = ?", 1, 120*time.Millisecond)
+	recorder.record("SELECT 1", 0, 10*time.Millisecond)
+
+	metrics = GetQueryMetrics()
+	if metrics.SlowQueries != 2 || len(metrics.Slowest) != 1 {
+		t.Fatalf("Expected one slow statement run twice, got %+v", metrics)
+	}
+	slow := metrics.Slowest[0]
+	if slow.Query != "SELECT * FROM users WHERE telegram_id = ?" || slow.Count != 2 || slow.MaxMs != 120 || slow.TotalMs != 200 {
+		t.Errorf("Unexpected slow statement %+v", slow)
+	}
+}
+
+func TestRedactedArgs(t *testing.T) {
+	if got := redactedArgs(3); got != "[?, ?, ?]" {
+		t.Errorf("Expected [?, ?, ?], got %s", got)
+	}
+	if got := redactedArgs(0); got != "[]" {
+		t.Errorf("Expected [], got %s", got)
+	}
+}
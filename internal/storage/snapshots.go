@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// BalanceSnapshotRetention is how long daily balance snapshots are kept
+const BalanceSnapshotRetention = 30 * 24 * time.Hour
+
+// snapshotDay is the UTC day a snapshot taken at t is filed under
+func snapshotDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// TakeBalanceSnapshot records every user's balance and leaderboard rank for the UTC day of now,
+// unless that day already has a snapshot, and drops snapshots past BalanceSnapshotRetention.
+// It returns how many users were recorded, 0 when the day was already taken.
+func TakeBalanceSnapshot(now time.Time) (int64, error) {
+	day := snapshotDay(now)
+
+	var taken int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM balance_snapshots WHERE day = ?`, day).Scan(&taken); err != nil {
+		return 0, fmt.Errorf("failed to check balance snapshot: %w", err)
+	}
+	if taken > 0 {
+		return 0, nil
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO balance_snapshots (user_id, day, balance, rank)
+		SELECT id, ?, balance, ROW_NUMBER() OVER (ORDER BY balance DESC, id)
+		FROM users
+		WHERE telegram_id != ?
+		ON CONFLICT DO NOTHING
+	`, day, HouseTelegramID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to take balance snapshot: %w", err)
+	}
+	count, _ := result.RowsAffected()
+
+	if _, err := db.Exec(`DELETE FROM balance_snapshots WHERE day < ?`, snapshotDay(now.Add(-BalanceSnapshotRetention))); err != nil {
+		return count, fmt.Errorf("failed to prune balance snapshots: %w", err)
+	}
+	return count, nil
+}
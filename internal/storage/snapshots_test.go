@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBalanceSnapshotLeaderboard(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	leader, _ := CreateUser(4301, "leader", "Leader")
+	climber, _ := CreateUser(4302, "climber", "Climber")
+	db.Exec(`UPDATE users SET balance = 2000 WHERE id = ?`, leader.ID)
+
+	// No snapshot yet: no movement
+	entries, _ := GetTopUsers(10)
+	if entries[0].PreviousRank != 0 || entries[0].RankChange != 0 || entries[0].BalanceChange != 0 {
+		t.Errorf("Expected no movement without a snapshot, got %+v", entries[0])
+	}
+
+	now := time.Now()
+	if count, err := TakeBalanceSnapshot(now); err != nil || count != 2 {
+		t.Fatalf("Expected 2 users recorded, got %d, %v", count, err)
+	}
+	if count, _ := TakeBalanceSnapshot(now); count != 0 {
+		t.Errorf("Expected one snapshot per day, got %d more users", count)
+	}
+
+	db.Exec(`UPDATE users SET balance = 2500 WHERE id = ?`, climber.ID)
+	CreateUser(4303, "late", "Late")
+
+	entries, _ = GetTopUsers(10)
+	byName := map[string]LeaderboardEntry{}
+	for _, e := range entries {
+		byName[e.Username] = e
+	}
+	if e := byName["climber"]; e.Rank != 1 || e.PreviousRank != 2 || e.RankChange != 1 || e.BalanceChange != 1500 {
+		t.Errorf("Expected the climber up one place and 1500 richer, got %+v", e)
+	}
+	if e := byName["leader"]; e.RankChange != -1 || e.BalanceChange != 0 {
+		t.Errorf("Expected the leader down one place, got %+v", e)
+	}
+	if e := byName["late"]; e.PreviousRank != 0 || e.RankChange != 0 {
+		t.Errorf("Expected no movement for a new user, got %+v", e)
+	}
+
+	// Old snapshots are pruned when a new day is taken
+	db.Exec(`UPDATE balance_snapshots SET day = '2000-01-01'`)
+	if count, _ := TakeBalanceSnapshot(now); count != 3 {
+		t.Errorf("Expected 3 users recorded, got %d", count)
+	}
+	var days int
+	db.QueryRow(`SELECT COUNT(DISTINCT day) FROM balance_snapshots`).Scan(&days)
+	if days != 1 {
+		t.Errorf("Expected old snapshots to be pruned, got %d days", days)
+	}
+}
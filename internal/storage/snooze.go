@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// HideMarketForUser removes a market from one user's (internal ID) feed without affecting anyone else.
+// With hours > 0 the market comes back after that many hours; otherwise it stays hidden until
+// UnhideMarketForUser. Hiding again replaces the previous period.
+func HideMarketForUser(userID, marketID int64, hours int) error {
+	var until interface{}
+	if hours > 0 {
+		until = sqlTimestamp(time.Now().Add(time.Duration(hours) * time.Hour))
+	}
+	_, err := db.Exec(`
+		INSERT INTO market_snoozes (user_id, market_id, until)
+		VALUES (?, ?, ?)
+		ON CONFLICT(user_id, market_id) DO UPDATE SET until = excluded.until, created_at = CURRENT_TIMESTAMP
+	`, userID, marketID, until)
+	if err != nil {
+		return fmt.Errorf("failed to hide market: %w", err)
+	}
+	return nil
+}
+
+// UnhideMarketForUser puts a hidden market back into a user's (internal ID) feed
+func UnhideMarketForUser(userID, marketID int64) error {
+	if _, err := db.Exec(`DELETE FROM market_snoozes WHERE user_id = ? AND market_id = ?`, userID, marketID); err != nil {
+		return fmt.Errorf("failed to unhide market: %w", err)
+	}
+	return nil
+}
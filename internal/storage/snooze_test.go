@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHideMarketForUser(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	alice, _ := CreateUser(4001, "alice", "Alice")
+	bob, _ := CreateUser(4002, "bob", "Bob")
+	expiresAt := time.Now().Add(24 * time.Hour)
+	dismissed, _ := CreateMarket(alice.ID, "Will it rain tomorrow?", expiresAt)
+	snoozed, _ := CreateMarket(alice.ID, "Will the sun shine?", expiresAt)
+	CreateMarket(alice.ID, "Will it snow in May?", expiresAt)
+
+	if err := HideMarketForUser(alice.ID, dismissed.ID, 0); err != nil {
+		t.Fatalf("HideMarketForUser failed: %v", err)
+	}
+	if err := HideMarketForUser(alice.ID, snoozed.ID, 24); err != nil {
+		t.Fatalf("HideMarketForUser (snooze) failed: %v", err)
+	}
+
+	if markets, _ := ListActiveMarketsForUser(alice.ID); len(markets) != 1 {
+		t.Errorf("Expected 1 market for alice, got %d", len(markets))
+	}
+	if markets, _ := ListActiveMarketsForUser(bob.ID); len(markets) != 3 {
+		t.Errorf("Expected hiding not to affect bob, got %d markets", len(markets))
+	}
+	if markets, _ := ListActiveMarketsWithCreator(); len(markets) != 3 {
+		t.Errorf("Expected the public list to keep all markets, got %d", len(markets))
+	}
+
+	// An elapsed snooze brings the market back
+	db.Exec(`UPDATE market_snoozes SET until = ? WHERE market_id = ?`, sqlTimestamp(time.Now().Add(-time.Minute)), snoozed.ID)
+	if err := UnhideMarketForUser(alice.ID, dismissed.ID); err != nil {
+		t.Fatalf("UnhideMarketForUser failed: %v", err)
+	}
+	if markets, _ := ListActiveMarketsForUser(alice.ID); len(markets) != 3 {
+		t.Errorf("Expected all markets back for alice, got %d", len(markets))
+	}
+}
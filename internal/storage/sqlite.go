@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"path/filepath"
+	"slices"
 	"time"
 
+	"predictionbot/internal/statemachine"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "modernc.org/sqlite"
 )
 
@@ -23,28 +26,40 @@ const (
 
 var db *sql.DB
 
-// InitDB initializes the SQLite database connection with WAL mode
+// Identity convention: every userID, creatorID and actorID parameter in this package is the
+// internal users.id. Only GetUserByTelegramID and CreateUser take a Telegram ID, so callers
+// holding a Telegram ID (API handlers, bot commands) must look the user up first.
+
+// InitDB initializes the database connection: PostgreSQL for a postgres:// URL, otherwise the
+// SQLite file at dbPath in WAL mode
 func InitDB(dbPath string) error {
 	var err error
 
-	// For in-memory databases, use the path directly
-	// Otherwise ensure the directory exists
-	if dbPath != ":memory:" {
-		_, err = filepath.Abs(dbPath)
-		if err != nil {
+	// For in-memory databases and PostgreSQL, use the path directly
+	// Otherwise ensure the directory exists and is writable
+	if dbPath != ":memory:" && !IsPostgresURL(dbPath) {
+		if err := prepareDBPath(dbPath); err != nil {
 			return err
 		}
 	}
 
-	db, err = sql.Open("sqlite", dbPath)
+	// Every statement is timed; slow ones are logged, see SlowQueryThreshold
+	db, err = openInstrumented(dbPath)
 	if err != nil {
 		return err
 	}
+	resetLastSeen()
+	if dbPath == ":memory:" {
+		// Every connection to :memory: is a separate, empty database
+		db.SetMaxOpenConns(1)
+	}
 
 	// Enable WAL mode for better concurrency
-	_, err = db.Exec("PRAGMA journal_mode=WAL")
-	if err != nil {
-		return err
+	if !usingPostgres {
+		_, err = db.Exec("PRAGMA journal_mode=WAL")
+		if err != nil {
+			return err
+		}
 	}
 
 	// Run migrations
@@ -60,126 +75,6 @@ func DB() *sql.DB {
 	return db
 }
 
-// runMigrations creates the necessary tables
-func runMigrations() error {
-	usersTable := `
-		CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			telegram_id INTEGER UNIQUE NOT NULL,
-			username TEXT,
-			first_name TEXT NOT NULL,
-			balance INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`
-
-	transactionsTable := `
-		CREATE TABLE IF NOT EXISTS transactions (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			amount INTEGER NOT NULL,
-			source_type TEXT NOT NULL,
-			description TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		)
-	`
-
-	marketsTable := `
-		CREATE TABLE IF NOT EXISTS markets (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			creator_id INTEGER NOT NULL,
-			question TEXT NOT NULL,
-			image_url TEXT,
-			status TEXT NOT NULL DEFAULT 'ACTIVE',
-			outcome TEXT,
-			resolved_at DATETIME,
-			expires_at DATETIME NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (creator_id) REFERENCES users(id)
-		)
-	`
-
-	betsTable := `
-		CREATE TABLE IF NOT EXISTS bets (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			market_id INTEGER NOT NULL,
-			outcome TEXT NOT NULL CHECK (outcome IN ('YES', 'NO')),
-			amount INTEGER NOT NULL,
-			placed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id),
-			FOREIGN KEY (market_id) REFERENCES markets(id)
-		)
-	`
-
-	// Create indexes for better query performance
-	createIndexes := `
-		CREATE INDEX IF NOT EXISTS idx_transactions_user_id ON transactions(user_id);
-		CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions(created_at);
-		CREATE INDEX IF NOT EXISTS idx_markets_status ON markets(status);
-		CREATE INDEX IF NOT EXISTS idx_markets_created_at ON markets(created_at);
-		CREATE INDEX IF NOT EXISTS idx_bets_user_market ON bets(user_id, market_id);
-		CREATE INDEX IF NOT EXISTS idx_bets_market ON bets(market_id);
-		CREATE INDEX IF NOT EXISTS idx_users_balance ON users(balance DESC);
-	`
-
-	_, err := db.Exec(usersTable)
-	if err != nil {
-		return err
-	}
-
-	_, err = db.Exec(transactionsTable)
-	if err != nil {
-		return err
-	}
-
-	_, err = db.Exec(marketsTable)
-	if err != nil {
-		return err
-	}
-
-	_, err = db.Exec(betsTable)
-	if err != nil {
-		return err
-	}
-
-	_, err = db.Exec(createIndexes)
-	if err != nil {
-		return err
-	}
-
-	// Migration: Add outcome and resolved_at columns if they don't exist
-	// SQLite's ALTER TABLE ADD COLUMN is idempotent-ish (won't fail if column exists in newer versions)
-	// But we'll check first to be safe
-	var outcomeExists int
-	err = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('markets') WHERE name='outcome'").Scan(&outcomeExists)
-	if err != nil {
-		return err
-	}
-	if outcomeExists == 0 {
-		_, err = db.Exec("ALTER TABLE markets ADD COLUMN outcome TEXT")
-		if err != nil {
-			return err
-		}
-	}
-
-	var resolvedAtExists int
-	err = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('markets') WHERE name='resolved_at'").Scan(&resolvedAtExists)
-	if err != nil {
-		return err
-	}
-	if resolvedAtExists == 0 {
-		_, err = db.Exec("ALTER TABLE markets ADD COLUMN resolved_at DATETIME")
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
 // CloseDB closes the database connection
 func CloseDB() error {
 	if db != nil {
@@ -238,6 +133,31 @@ func GetUserByID(id int64) (*User, error) {
 	return &user, nil
 }
 
+// GetUserByUsername retrieves a user by their Telegram username (case-insensitive, without @)
+func GetUserByUsername(username string) (*User, error) {
+	var user User
+	err := db.QueryRow(`
+		SELECT id, telegram_id, username, first_name, balance, created_at, updated_at
+		FROM users
+		WHERE LOWER(username) = LOWER(?)
+	`, username).Scan(
+		&user.ID,
+		&user.TelegramID,
+		&user.Username,
+		&user.FirstName,
+		&user.Balance,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by username: %w", err)
+	}
+	return &user, nil
+}
+
 // CreateUser creates a new user with the given Telegram info and welcome bonus
 func CreateUser(telegramID int64, username, firstName string) (*User, error) {
 	tx, err := db.Begin()
@@ -247,19 +167,16 @@ func CreateUser(telegramID int64, username, firstName string) (*User, error) {
 	defer tx.Rollback()
 
 	// Insert user with initial balance
-	result, err := tx.Exec(`
+	var userID int64
+	err = tx.QueryRow(`
 		INSERT INTO users (telegram_id, username, first_name, balance)
 		VALUES (?, ?, ?, ?)
-	`, telegramID, username, firstName, WelcomeBonusAmount)
+		RETURNING id
+	`, telegramID, username, firstName, WelcomeBonusAmount).Scan(&userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert user: %w", err)
 	}
 
-	userID, err := result.LastInsertId()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get last insert id: %w", err)
-	}
-
 	// Create welcome bonus transaction
 	_, err = tx.Exec(`
 		INSERT INTO transactions (user_id, amount, source_type, description)
@@ -277,19 +194,110 @@ func CreateUser(telegramID int64, username, firstName string) (*User, error) {
 	return GetUserByTelegramID(telegramID)
 }
 
-// CreateMarket creates a new market
+// MarketOptions are the betting modes chosen when a market is created
+type MarketOptions struct {
+	// Blind markets hide their pools until they lock, so early bets can't herd later ones
+	Blind bool
+	// Sealed markets hide which side each stake is on until they are finalized
+	Sealed bool
+	// Anonymous markets hide their creator from everyone but the creator and moderators
+	Anonymous bool
+	// LockMode is what locks the market; empty means LockModeDeadline
+	LockMode LockMode
+	// Icon is the emoji shown before the question; empty means DefaultMarketIcon
+	Icon string
+	// IdempotencyKey is a client-generated token; a creator can only use it once (optional)
+	IdempotencyKey string
+	// PricingMode is how bets are priced; empty means PricingParimutuel
+	PricingMode PricingMode
+	// Liquidity is the market maker's liquidity parameter for PricingMarketMaker markets;
+	// zero means DefaultLiquidity
+	Liquidity int64
+	// GroupID scopes the market to a Telegram group chat; zero makes it public
+	GroupID int64
+}
+
+// CreateMarket creates a new market owned by creatorID (internal user ID)
 func CreateMarket(creatorID int64, question string, expiresAt time.Time) (*Market, error) {
-	result, err := db.Exec(`
-		INSERT INTO markets (creator_id, question, status, expires_at)
-		VALUES (?, ?, 'ACTIVE', ?)
-	`, creatorID, question, expiresAt)
+	return CreateMarketWithOptions(creatorID, question, expiresAt, MarketOptions{})
+}
+
+// CreateMarketWithOptions creates a new market with the given betting modes
+func CreateMarketWithOptions(creatorID int64, question string, expiresAt time.Time, opts MarketOptions) (*Market, error) {
+	if opts.LockMode == "" {
+		opts.LockMode = LockModeDeadline
+	}
+	if opts.Icon == "" {
+		opts.Icon = DefaultMarketIcon
+	}
+	if opts.PricingMode == "" {
+		opts.PricingMode = PricingParimutuel
+	}
+	if opts.PricingMode == PricingMarketMaker {
+		if opts.Blind || opts.Sealed {
+			return nil, fmt.Errorf("invalid pricing mode: market maker prices would reveal blind or sealed bets")
+		}
+		if opts.Liquidity == 0 {
+			opts.Liquidity = DefaultLiquidity
+		}
+		if opts.Liquidity < 0 || opts.Liquidity > MaxLiquidity {
+			return nil, fmt.Errorf("invalid liquidity: must be between 1 and %d", MaxLiquidity)
+		}
+	}
+	var idempotencyKey sql.NullString
+	if opts.IdempotencyKey != "" {
+		idempotencyKey = sql.NullString{String: opts.IdempotencyKey, Valid: true}
+	}
+	var groupID sql.NullInt64
+	if opts.GroupID != 0 {
+		groupID = sql.NullInt64{Int64: opts.GroupID, Valid: true}
+	}
+
+	tx, err := db.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert market: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	marketID, err := result.LastInsertId()
+	var marketID int64
+	err = tx.QueryRow(`
+		INSERT INTO markets (creator_id, question, status, expires_at, blind, sealed, anonymous, lock_mode, icon, idempotency_key, pricing_mode, group_id)
+		VALUES (?, ?, 'ACTIVE', ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, creatorID, question, expiresAt, opts.Blind, opts.Sealed, opts.Anonymous, opts.LockMode, opts.Icon, idempotencyKey, opts.PricingMode, groupID).Scan(&marketID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+		return nil, fmt.Errorf("failed to insert market: %w", err)
+	}
+
+	if opts.PricingMode == PricingMarketMaker {
+		// The creator covers the market maker's worst-case loss, so trading on their own
+		// market and resolving it cannot make money out of nothing
+		subsidy := MarketMakerSubsidy(opts.Liquidity)
+		var balance int64
+		if err := tx.QueryRow(`SELECT balance FROM users WHERE id = ?`, creatorID).Scan(&balance); err != nil {
+			return nil, fmt.Errorf("failed to get creator balance: %w", err)
+		}
+		if balance < subsidy {
+			return nil, fmt.Errorf("insufficient funds: a market maker with liquidity %d needs a subsidy of %d, have %d", opts.Liquidity, subsidy, balance)
+		}
+		if _, err := tx.Exec(`UPDATE users SET balance = balance - ? WHERE id = ?`, subsidy, creatorID); err != nil {
+			return nil, fmt.Errorf("failed to update balance: %w", err)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+			VALUES (?, ?, 'MARKET_MAKER_SUBSIDY', ?, ?)
+		`, creatorID, -subsidy, fmt.Sprintf("Market maker subsidy for market #%d (liquidity: %d)", marketID, opts.Liquidity), marketID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to log subsidy: %w", err)
+		}
+		_, err = tx.Exec(`INSERT INTO market_makers (market_id, liquidity, subsidy) VALUES (?, ?, ?)`, marketID, opts.Liquidity, subsidy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert market maker: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	// Fetch and return the created market
@@ -302,8 +310,9 @@ func GetMarketByID(id int64) (*Market, error) {
 	var imageURL sql.NullString
 	var outcome sql.NullString
 	var resolvedAt sql.NullTime
+	var groupID sql.NullInt64
 	err := db.QueryRow(`
-		SELECT id, creator_id, question, image_url, status, outcome, resolved_at, expires_at, created_at
+		SELECT id, creator_id, question, image_url, status, outcome, resolved_at, expires_at, created_at, hidden, blind, sealed, anonymous, lock_mode, icon, pricing_mode, group_id
 		FROM markets
 		WHERE id = ?
 	`, id).Scan(
@@ -316,6 +325,14 @@ func GetMarketByID(id int64) (*Market, error) {
 		&resolvedAt,
 		&market.ExpiresAt,
 		&market.CreatedAt,
+		&market.Hidden,
+		&market.Blind,
+		&market.Sealed,
+		&market.Anonymous,
+		&market.LockMode,
+		&market.Icon,
+		&market.PricingMode,
+		&groupID,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -334,16 +351,17 @@ func GetMarketByID(id int64) (*Market, error) {
 	if resolvedAt.Valid {
 		market.ResolvedAt = resolvedAt.Time
 	}
+	market.GroupID = groupID.Int64
 
 	return &market, nil
 }
 
-// ListActiveMarkets retrieves all active markets ordered by creation date (newest first)
+// ListActiveMarkets retrieves all active public markets ordered by creation date (newest first)
 func ListActiveMarkets() ([]Market, error) {
 	rows, err := db.Query(`
-		SELECT id, creator_id, question, image_url, status, expires_at, created_at
+		SELECT id, creator_id, question, image_url, status, expires_at, created_at, icon
 		FROM markets
-		WHERE status = 'ACTIVE'
+		WHERE status IN ('ACTIVE', 'LAST_CALL') AND hidden = 0 AND group_id IS NULL
 		ORDER BY created_at DESC
 	`)
 	if err != nil {
@@ -363,6 +381,7 @@ func ListActiveMarkets() ([]Market, error) {
 			&market.Status,
 			&market.ExpiresAt,
 			&market.CreatedAt,
+			&market.Icon,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan market: %w", err)
@@ -384,30 +403,102 @@ func ListActiveMarkets() ([]Market, error) {
 
 // MarketWithCreator represents a market with creator name for API responses
 type MarketWithCreator struct {
-	ID          int64  `json:"id"`
-	Question    string `json:"question"`
-	CreatorName string `json:"creator_name"`
-	ExpiresAt   string `json:"expires_at"`
-	PoolYes     int64  `json:"pool_yes"`
-	PoolNo      int64  `json:"pool_no"`
+	ID                 int64    `json:"id"`
+	Question           string   `json:"question"`
+	CreatorName        string   `json:"creator_name"`
+	ExpiresAt          string   `json:"expires_at"`
+	Status             string   `json:"status"`
+	LockMode           string   `json:"lock_mode"`
+	PricingMode        string   `json:"pricing_mode"`
+	Icon               string   `json:"icon"`
+	PoolsHidden        bool     `json:"pools_hidden,omitempty"`
+	SidesHidden        bool     `json:"sides_hidden,omitempty"`
+	PoolYes            int64    `json:"pool_yes"`
+	PoolNo             int64    `json:"pool_no"`
+	PoolTotal          int64    `json:"pool_total,omitempty"`
+	Tags               []string `json:"tags"`
+	ResolutionCriteria string   `json:"resolution_criteria,omitempty"`
 }
 
-// ListActiveMarketsWithCreator returns active markets with creator names
+// ListActiveMarketsWithCreator returns active public markets (including those in their last call) with creator names
 func ListActiveMarketsWithCreator() ([]MarketWithCreator, error) {
+	return listActiveMarketsWithCreator(0, 0, "", -1, 0)
+}
+
+// ListActiveMarketsForUser returns active public markets with creator names, leaving out
+// the markets the user (internal ID) has hidden from their feed
+func ListActiveMarketsForUser(userID int64) ([]MarketWithCreator, error) {
+	return listActiveMarketsWithCreator(userID, 0, "", -1, 0)
+}
+
+// ListGroupMarkets returns the active markets of a Telegram group chat with creator names. A
+// non-zero viewerID leaves out the markets that user (internal ID) hid.
+func ListGroupMarkets(groupID, viewerID int64) ([]MarketWithCreator, error) {
+	return listActiveMarketsWithCreator(viewerID, groupID, "", -1, 0)
+}
+
+// ListActiveMarketsPaged returns one page of active markets, newest first, and how many there
+// are in total. A non-zero viewerID leaves out the markets that user (internal ID) hid; a
+// non-zero groupID lists that group chat's markets instead of the public ones; a non-empty tag
+// keeps only markets with that tag.
+func ListActiveMarketsPaged(viewerID, groupID int64, tag string, limit, offset int) ([]MarketWithCreator, int, error) {
+	var total int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM markets m
+		WHERE `+activeMarketsFilterSQL, viewerID, groupID, tag, tag).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count active markets: %w", err)
+	}
+
+	markets, err := listActiveMarketsWithCreator(viewerID, groupID, tag, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return markets, total, nil
+}
+
+// activeMarketsFilterSQL selects the active markets (alias m) a viewer sees. It takes the
+// viewer's internal ID (0 for anonymous), the group chat (0 for public markets) and the tag
+// filter twice (empty for every tag).
+const activeMarketsFilterSQL = `m.status IN ('ACTIVE', 'LAST_CALL') AND m.hidden = 0
+		  AND NOT EXISTS (
+		      SELECT 1 FROM market_snoozes s
+		      WHERE s.market_id = m.id AND s.user_id = ?
+		        AND (s.until IS NULL OR s.until > CURRENT_TIMESTAMP)
+		  )
+		  AND COALESCE(m.group_id, 0) = ?
+		  AND (? = '' OR EXISTS (SELECT 1 FROM market_tags t WHERE t.market_id = m.id AND t.tag = ?))`
+
+// listActiveMarketsWithCreator lists the active markets of a group chat, or the public ones for
+// groupID 0; a non-zero viewerID excludes their hidden markets. A negative limit returns every
+// market from offset on.
+func listActiveMarketsWithCreator(viewerID, groupID int64, tag string, limit, offset int) ([]MarketWithCreator, error) {
+	// PostgreSQL rejects a negative LIMIT; a NULL one means no limit there
+	var limitArg interface{} = limit
+	if limit < 0 && usingPostgres {
+		limitArg = nil
+	}
 	rows, err := db.Query(`
-		SELECT m.id, m.question, COALESCE(NULLIF(u.first_name, ''), 'Anonymous'), m.expires_at,
+		SELECT m.id, m.question, `+creatorNameSQL+`, m.expires_at, m.status, m.lock_mode, m.pricing_mode, m.icon, `+poolsHiddenSQL+`, `+sidesHiddenSQL+`,
 		       COALESCE(SUM(CASE WHEN b.outcome = 'YES' THEN b.amount ELSE 0 END), 0) as pool_yes,
-		       COALESCE(SUM(CASE WHEN b.outcome = 'NO' THEN b.amount ELSE 0 END), 0) as pool_no
+		       COALESCE(SUM(CASE WHEN b.outcome = 'NO' THEN b.amount ELSE 0 END), 0) as pool_no,
+		       COALESCE(m.resolution_criteria, '')
 		FROM markets m
 		LEFT JOIN users u ON m.creator_id = u.id
-		LEFT JOIN bets b ON m.id = b.market_id
-		WHERE m.status = 'ACTIVE'
+		LEFT JOIN bets b ON m.id = b.market_id AND `+publicBetSQL+`
+		WHERE `+activeMarketsFilterSQL+`
 		GROUP BY m.id, m.question, u.first_name, m.expires_at, m.created_at
-		ORDER BY m.created_at DESC
-	`)
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT ? OFFSET ?
+	`, viewerID, groupID, tag, tag, limitArg, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query active markets: %w", err)
 	}
+	return scanMarketsWithCreator(rows)
+}
+
+// scanMarketsWithCreator reads and closes rows of markets with creator names and public pools
+func scanMarketsWithCreator(rows *sql.Rows) ([]MarketWithCreator, error) {
 	defer rows.Close()
 
 	var markets []MarketWithCreator
@@ -418,12 +509,24 @@ func ListActiveMarketsWithCreator() ([]MarketWithCreator, error) {
 			&market.Question,
 			&market.CreatorName,
 			&market.ExpiresAt,
+			&market.Status,
+			&market.LockMode,
+			&market.PricingMode,
+			&market.Icon,
+			&market.PoolsHidden,
+			&market.SidesHidden,
 			&market.PoolYes,
 			&market.PoolNo,
+			&market.ResolutionCriteria,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan market: %w", err)
 		}
+		if market.SidesHidden {
+			// Sealed market: only the total is shown until it is finalized
+			market.PoolTotal = market.PoolYes + market.PoolNo
+			market.PoolYes, market.PoolNo = 0, 0
+		}
 		markets = append(markets, market)
 	}
 
@@ -437,25 +540,32 @@ func ListActiveMarketsWithCreator() ([]MarketWithCreator, error) {
 // MarketCreatorInfo represents market info for creator view
 type MarketCreatorInfo struct {
 	ID        int64  `json:"id"`
+	Icon      string `json:"icon"`
 	Question  string `json:"question"`
 	Status    string `json:"status"`
 	Outcome   string `json:"outcome,omitempty"`
 	ExpiresAt string `json:"expires_at"`
 	PoolYes   int64  `json:"pool_yes"`
 	PoolNo    int64  `json:"pool_no"`
+	// PoolTotal is set while a sealed market hides its sides; PoolYes and PoolNo are then zero
+	PoolTotal   int64 `json:"pool_total,omitempty"`
+	SidesHidden bool  `json:"sides_hidden,omitempty"`
+	// PoolsHidden is set while a blind market is open; the pools are then zero
+	PoolsHidden bool `json:"pools_hidden,omitempty"`
 }
 
 // MarketResolutionInfo represents market info for resolution selection
 type MarketResolutionInfo struct {
-	ID        int64  `json:"id"`
-	Question  string `json:"question"`
-	ExpiresAt string `json:"expires_at"`
+	ID                 int64  `json:"id"`
+	Question           string `json:"question"`
+	ExpiresAt          string `json:"expires_at"`
+	ResolutionCriteria string `json:"resolution_criteria,omitempty"`
 }
 
-// GetMarketsByCreator returns all markets created by a user
+// GetMarketsByCreator returns all markets created by a user (internal user ID)
 func GetMarketsByCreator(creatorID int64) ([]MarketCreatorInfo, error) {
 	rows, err := db.Query(`
-		SELECT m.id, m.question, m.status, m.outcome, m.expires_at
+		SELECT m.id, m.icon, m.question, m.status, m.outcome, m.expires_at
 		FROM markets m
 		WHERE m.creator_id = ?
 		ORDER BY m.created_at DESC
@@ -470,7 +580,7 @@ func GetMarketsByCreator(creatorID int64) ([]MarketCreatorInfo, error) {
 		var market MarketCreatorInfo
 		var outcome sql.NullString
 		var expiresAt time.Time
-		err := rows.Scan(&market.ID, &market.Question, &market.Status, &outcome, &expiresAt)
+		err := rows.Scan(&market.ID, &market.Icon, &market.Question, &market.Status, &outcome, &expiresAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan market: %w", err)
 		}
@@ -479,26 +589,26 @@ func GetMarketsByCreator(creatorID int64) ([]MarketCreatorInfo, error) {
 		}
 		market.ExpiresAt = expiresAt.Format("Jan 2, 15:04")
 
-		// Get pool totals
-		var poolYes, poolNo int64
-		err = db.QueryRow(`
-			SELECT
-				COALESCE(SUM(CASE WHEN outcome = 'YES' THEN amount ELSE 0 END), 0),
-				COALESCE(SUM(CASE WHEN outcome = 'NO' THEN amount ELSE 0 END), 0)
-			FROM bets WHERE market_id = ?
-		`, market.ID).Scan(&poolYes, &poolNo)
-		if err != nil {
-			poolYes, poolNo = 0, 0
-		}
-		market.PoolYes = poolYes
-		market.PoolNo = poolNo
-
 		markets = append(markets, market)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating markets: %w", err)
 	}
+	rows.Close()
+
+	// Get pool totals, as shown to users, once the markets are read
+	for i := range markets {
+		pools, err := GetPublicPools(markets[i].ID)
+		if err != nil {
+			pools = PublicPools{}
+		}
+		markets[i].PoolYes, markets[i].PoolNo = pools.Yes, pools.No
+		markets[i].PoolsHidden, markets[i].SidesHidden = pools.PoolsHidden, pools.SidesHidden
+		if pools.SidesHidden {
+			markets[i].PoolTotal = pools.Total
+		}
+	}
 
 	return markets, nil
 }
@@ -538,7 +648,7 @@ func GetMarketsEligibleForResolution(creatorID int64) ([]MarketResolutionInfo, e
 // GetMarketsEligibleForDispute returns RESOLVED markets that user has bet on and can dispute
 func GetMarketsEligibleForDispute(userID int64) ([]MarketResolutionInfo, error) {
 	rows, err := db.Query(`
-		SELECT DISTINCT m.id, m.question, m.expires_at
+		SELECT DISTINCT m.id, m.question, m.expires_at, COALESCE(m.resolution_criteria, '')
 		FROM markets m
 		INNER JOIN bets b ON m.id = b.market_id
 		WHERE b.user_id = ? AND m.status = 'RESOLVED'
@@ -553,7 +663,7 @@ func GetMarketsEligibleForDispute(userID int64) ([]MarketResolutionInfo, error)
 	for rows.Next() {
 		var market MarketResolutionInfo
 		var expiresAt time.Time
-		if err := rows.Scan(&market.ID, &market.Question, &expiresAt); err != nil {
+		if err := rows.Scan(&market.ID, &market.Question, &expiresAt, &market.ResolutionCriteria); err != nil {
 			return nil, fmt.Errorf("failed to scan market: %w", err)
 		}
 		market.ExpiresAt = expiresAt.Format("2006-01-02 15:04")
@@ -598,8 +708,14 @@ func GetDisputedMarkets() ([]MarketResolutionInfo, error) {
 	return markets, nil
 }
 
-// PlaceBet places a bet on a market with ACID transaction
+// PlaceBet places a bet of unknown source; see PlaceBetFrom
 func PlaceBet(ctx context.Context, userID, marketID int64, outcome string, amount int64) error {
+	return PlaceBetFrom(ctx, userID, marketID, outcome, amount, BetSourceUnknown)
+}
+
+// PlaceBetFrom places a bet on a market with ACID transaction, recording where it was placed
+// userID is the internal user ID, not the Telegram ID
+func PlaceBetFrom(ctx context.Context, userID, marketID int64, outcome string, amount int64, source BetSource) error {
 	// Validate outcome
 	if outcome != string(OutcomeYes) && outcome != string(OutcomeNo) {
 		return fmt.Errorf("invalid outcome: must be 'YES' or 'NO'")
@@ -610,6 +726,10 @@ func PlaceBet(ctx context.Context, userID, marketID int64, outcome string, amoun
 		return fmt.Errorf("invalid amount: must be greater than 0")
 	}
 
+	if !source.Valid() {
+		return fmt.Errorf("invalid bet source: %s", source)
+	}
+
 	// Begin immediate transaction for atomicity
 	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
 	if err != nil {
@@ -627,14 +747,12 @@ func PlaceBet(ctx context.Context, userID, marketID int64, outcome string, amoun
 		return fmt.Errorf("failed to get user balance: %w", err)
 	}
 
-	if userBalance < amount {
-		return fmt.Errorf("insufficient funds: have %d, need %d", userBalance, amount)
-	}
-
 	// Check market exists and is active
 	var marketStatus string
 	var expiresAt time.Time
-	err = tx.QueryRowContext(ctx, `SELECT status, expires_at FROM markets WHERE id = ?`, marketID).Scan(&marketStatus, &expiresAt)
+	var hidden bool
+	var pricingMode PricingMode
+	err = tx.QueryRowContext(ctx, `SELECT status, expires_at, hidden, pricing_mode FROM markets WHERE id = ?`, marketID).Scan(&marketStatus, &expiresAt, &hidden, &pricingMode)
 	if err == sql.ErrNoRows {
 		return fmt.Errorf("market not found")
 	}
@@ -642,43 +760,86 @@ func PlaceBet(ctx context.Context, userID, marketID int64, outcome string, amoun
 		return fmt.Errorf("failed to get market: %w", err)
 	}
 
-	if marketStatus != string(MarketStatusActive) {
+	// Bets are still taken during the last call
+	if marketStatus != string(MarketStatusActive) && marketStatus != string(MarketStatusLastCall) {
 		return fmt.Errorf("market is not active: status is %s", marketStatus)
 	}
 
+	if hidden {
+		return fmt.Errorf("market is not active: hidden by a moderator")
+	}
+
 	if time.Now().After(expiresAt) {
 		return fmt.Errorf("market has expired")
 	}
 
+	if pricingMode == PricingMarketMaker {
+		return fmt.Errorf("invalid bet: market is priced by a market maker, buy shares instead")
+	}
+
+	// Bound stakes and throttle rapid alternating bets that could be used to push the pool around
+	if err := checkBetLimitsTx(ctx, tx, userID, marketID, amount); err != nil {
+		return err
+	}
+
+	// The user's next voucher backs this bet; a promo credit pays its share of the stake
+	voucher, err := nextVoucherTx(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	var covered, credit int64
+	if voucher != nil {
+		covered = min(amount, voucher.MaxAmount)
+		if voucher.Kind == VoucherCredit {
+			credit = covered
+		}
+	}
+
+	if userBalance < amount-credit {
+		return fmt.Errorf("insufficient funds: have %d, need %d", userBalance, amount-credit)
+	}
+
 	// Update user balance
-	_, err = tx.ExecContext(ctx, `UPDATE users SET balance = balance - ? WHERE id = ?`, amount, userID)
+	_, err = tx.ExecContext(ctx, `UPDATE users SET balance = balance - ? WHERE id = ?`, amount-credit, userID)
 	if err != nil {
 		return fmt.Errorf("failed to update balance: %w", err)
 	}
 
 	// Insert bet record
-	result, err := tx.ExecContext(ctx, `
-		INSERT INTO bets (user_id, market_id, outcome, amount)
-		VALUES (?, ?, ?, ?)
-	`, userID, marketID, outcome, amount)
+	var betID int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO bets (user_id, market_id, outcome, amount, source)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id
+	`, userID, marketID, outcome, amount, source).Scan(&betID)
 	if err != nil {
 		return fmt.Errorf("failed to insert bet: %w", err)
 	}
 
-	betID, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get bet id: %w", err)
-	}
-
 	// Log the transaction
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO transactions (user_id, amount, source_type, description)
-		VALUES (?, ?, 'BET_PLACED', ?)
-	`, userID, -amount, fmt.Sprintf("Bet #%d on market #%d (%s)", betID, marketID, outcome))
+		INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+		VALUES (?, ?, 'BET_PLACED', ?, ?)
+	`, userID, -amount, fmt.Sprintf("Bet #%d on market #%d (%s)", betID, marketID, outcome), marketID)
 	if err != nil {
 		return fmt.Errorf("failed to log transaction: %w", err)
 	}
 
+	if voucher != nil {
+		if err := redeemVoucherTx(ctx, tx, voucher.ID, betID, covered); err != nil {
+			return err
+		}
+		if credit > 0 {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+				VALUES (?, ?, 'VOUCHER_CREDIT', ?, ?)
+			`, userID, credit, fmt.Sprintf("Voucher #%d paid %d of bet #%d", voucher.ID, credit, betID), marketID)
+			if err != nil {
+				return fmt.Errorf("failed to log transaction: %w", err)
+			}
+		}
+	}
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
@@ -705,7 +866,7 @@ func GetPoolTotals(marketID int64) (poolYes, poolNo int64, err error) {
 func GetMarketWithPools(marketID int64) (*MarketWithCreator, error) {
 	var market MarketWithCreator
 	err := db.QueryRow(`
-		SELECT m.id, m.question, COALESCE(NULLIF(u.first_name, ''), 'Anonymous'),
+		SELECT m.id, m.question, `+creatorNameSQL+`,
 		       m.expires_at, 0, 0
 		FROM markets m
 		LEFT JOIN users u ON m.creator_id = u.id
@@ -734,24 +895,149 @@ func GetMarketWithPools(marketID int64) (*MarketWithCreator, error) {
 	return &market, nil
 }
 
-// UpdateMarketStatus updates the status and optionally the outcome of a market
+// UpdateMarketStatus updates the status and optionally the outcome of a market. Moves the market
+// lifecycle does not allow are rejected with a *statemachine.TransitionError.
 func UpdateMarketStatus(marketID int64, status MarketStatus, outcome string) error {
-	var query string
-	var args []interface{}
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
+	change := MarketStatusChange{To: status}
 	if outcome != "" {
-		query = `UPDATE markets SET status = ?, outcome = ?, resolved_at = CURRENT_TIMESTAMP WHERE id = ?`
-		args = []interface{}{status, outcome, marketID}
-	} else {
-		query = `UPDATE markets SET status = ? WHERE id = ?`
-		args = []interface{}{status, marketID}
+		change.Set, change.Args = "outcome = ?, resolved_at = CURRENT_TIMESTAMP", []interface{}{outcome}
+	} else if status == MarketStatusLocked {
+		change.Set = "locked_at = CURRENT_TIMESTAMP"
+	} else if status == MarketStatusDisputed {
+		change.Set = "disputed_at = CURRENT_TIMESTAMP"
+	}
+	if _, err := UpdateMarketStatusTx(ctx, tx, marketID, change); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	return nil
+}
+
+// MarketStatusChange is a move of a market to another status, see UpdateMarketStatusTx
+type MarketStatusChange struct {
+	To MarketStatus
+	// From narrows the statuses the market may move from, for moves the lifecycle allows from
+	// more statuses than the caller does; empty means any
+	From []MarketStatus
+	// Set assigns more columns along with the status, e.g. "locked_at = CURRENT_TIMESTAMP",
+	// taking its placeholders from Args
+	Set  string
+	Args []interface{}
+}
 
-	_, err := db.Exec(query, args...)
+// UpdateMarketStatusTx moves a market to another status inside a transaction, the one place
+// market statuses are written. It reads the market's status, checks the move against the market
+// lifecycle and change.From, rejecting it with a *statemachine.TransitionError, and only writes
+// while the market is still in the status it read. It returns that status.
+func UpdateMarketStatusTx(ctx context.Context, tx *sql.Tx, marketID int64, change MarketStatusChange) (MarketStatus, error) {
+	var current MarketStatus
+	err := tx.QueryRowContext(ctx, `SELECT status FROM markets WHERE id = ?`, marketID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("market not found")
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update market status: %w", err)
+		return "", fmt.Errorf("failed to get market status: %w", err)
 	}
-	return nil
+	if err := statemachine.Validate(statemachine.Status(current), statemachine.Status(change.To)); err != nil {
+		return current, err
+	}
+	if len(change.From) > 0 && !slices.Contains(change.From, current) {
+		return current, &statemachine.TransitionError{From: statemachine.Status(current), To: statemachine.Status(change.To)}
+	}
+
+	query := `UPDATE markets SET status = ?`
+	if change.Set != "" {
+		query += ", " + change.Set
+	}
+	query += ` WHERE id = ? AND status = ?`
+	args := append(append([]interface{}{change.To}, change.Args...), marketID, current)
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return current, fmt.Errorf("failed to update market status: %w", err)
+	}
+	// Another request moved the market on since its status was read
+	if n, _ := result.RowsAffected(); n == 0 {
+		return current, fmt.Errorf("failed to update market status: status changed from %s", current)
+	}
+	return current, nil
+}
+
+// SetMarketHidden hides or unhides a market from listings (moderation action).
+// actorID is the internal ID of the moderator, recorded in the audit log.
+func SetMarketHidden(marketID int64, hidden bool, actorID int64) error {
+	result, err := db.Exec(`UPDATE markets SET hidden = ? WHERE id = ?`, hidden, marketID)
+	if err != nil {
+		return fmt.Errorf("failed to update market visibility: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("market not found")
+	}
+
+	action := "market_unhidden"
+	if hidden {
+		action = "market_hidden"
+	}
+	return LogAudit(actorID, action, AuditEntityMarket, marketID, "")
+}
+
+// AdjustBalance credits (positive amount) or debits (negative amount) a user's balance as an admin correction.
+// The balance cannot go below zero. Returns the new balance.
+func AdjustBalance(ctx context.Context, userID, amount int64, reason string, actorID int64) (int64, error) {
+	if amount == 0 {
+		return 0, fmt.Errorf("invalid amount: must not be zero")
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var balance int64
+	err = tx.QueryRowContext(ctx, `SELECT balance FROM users WHERE id = ?`, userID).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get user balance: %w", err)
+	}
+
+	if balance+amount < 0 {
+		return 0, fmt.Errorf("insufficient funds: have %d, adjustment %d", balance, amount)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET balance = balance + ? WHERE id = ?`, amount, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update balance: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO transactions (user_id, amount, source_type, description)
+		VALUES (?, ?, 'ADJUSTMENT', ?)
+	`, userID, amount, fmt.Sprintf("Admin adjustment: %s", reason))
+	if err != nil {
+		return 0, fmt.Errorf("failed to log transaction: %w", err)
+	}
+
+	details := fmt.Sprintf("amount=%d reason=%s", amount, reason)
+	if err := logAuditTx(ctx, tx, actorID, "balance_adjusted", AuditEntityUser, userID, details); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return balance + amount, nil
 }
 
 // GetMarketsPendingFinalization returns markets that are resolved and ready for auto-finalization
@@ -760,8 +1046,8 @@ func GetMarketsPendingFinalization(threshold time.Duration) ([]int64, error) {
 	rows, err := db.Query(`
 		SELECT id FROM markets
 		WHERE status = 'RESOLVED'
-		AND resolved_at < datetime('now', '-' || ? || ' seconds')
-	`, int64(threshold.Seconds()))
+		AND resolved_at < ?
+	`, sqlTimestamp(time.Now().Add(-threshold)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query pending markets: %w", err)
 	}
@@ -797,89 +1083,153 @@ const (
 type BetHistoryItem struct {
 	ID            int64     `json:"id"`
 	MarketID      int64     `json:"market_id"`
+	Icon          string    `json:"icon"`
 	Question      string    `json:"question"`
 	OutcomeChosen string    `json:"outcome_chosen"`
 	Amount        int64     `json:"amount"`
 	Status        BetStatus `json:"status"`
 	Payout        int64     `json:"payout,omitempty"`
 	PlacedAt      string    `json:"placed_at"`
+	Source        BetSource `json:"source"`
+	// Cancellable is set while the bet may still be cancelled under the BetCancelPolicy
+	Cancellable bool `json:"cancellable,omitempty"`
 }
 
 // ActiveBetItem represents a bet on an active market for the /mybets command
 type ActiveBetItem struct {
 	ID            int64  `json:"id"`
 	MarketID      int64  `json:"market_id"`
+	Icon          string `json:"icon"`
 	Question      string `json:"question"`
 	OutcomeChosen string `json:"outcome_chosen"`
 	Amount        int64  `json:"amount"`
 	ExpiresAt     string `json:"expires_at"`
 	PoolYes       int64  `json:"pool_yes"`
 	PoolNo        int64  `json:"pool_no"`
+	// PoolTotal is set while a sealed market hides its sides; PoolYes and PoolNo are then zero
+	PoolTotal   int64 `json:"pool_total,omitempty"`
+	SidesHidden bool  `json:"sides_hidden,omitempty"`
+	// PoolsHidden is set while a blind market is open; the pools are then zero
+	PoolsHidden bool `json:"pools_hidden,omitempty"`
+}
+
+// BetHistoryFilter selects a page of a user's bet history
+type BetHistoryFilter struct {
+	// Cursor is the ID of the last bet of the previous page; 0 starts from the newest bet
+	Cursor int64
+	// Limit is the page size; 0 returns all matching bets
+	Limit int
+	// Status filters by computed bet status (optional)
+	Status BetStatus
+	// Outcome filters by the outcome chosen, YES or NO (optional)
+	Outcome string
 }
 
-// GetUserBets returns all bets for a user with computed status based on market outcome
+// betStatusSQL computes a bet's status from its market in SQL:
+// open markets are pending, bets left on merged duplicates were refunded,
+// markets with an outcome decide win or loss.
+const betStatusSQL = `
+	CASE
+		WHEN m.status IN ('ACTIVE', 'LAST_CALL', 'LOCKED') THEN 'PENDING'
+		WHEN m.status = 'MERGED' THEN 'REFUNDED'
+		WHEN COALESCE(m.outcome, '') = '' THEN 'PENDING'
+		WHEN b.outcome = m.outcome THEN 'WON'
+		ELSE 'LOST'
+	END`
+
+// GetUserBets returns all bets for a user (internal user ID) with computed status based on market outcome
 func GetUserBets(userID int64) ([]BetHistoryItem, error) {
-	rows, err := db.Query(`
-		SELECT b.id, b.market_id, m.question, b.outcome, b.amount, b.placed_at,
-		       m.status as market_status, m.outcome as market_outcome
-		FROM bets b
-		JOIN markets m ON b.market_id = m.id
-		WHERE b.user_id = ?
-		ORDER BY b.placed_at DESC
-	`, userID)
+	bets, _, err := GetUserBetsPage(userID, BetHistoryFilter{})
+	return bets, err
+}
+
+// GetUserBetsPage returns a page of a user's bets, newest first, and the cursor for the next page
+// (0 when there are no more bets). Payouts are looked up with a single join instead of a query per bet.
+func GetUserBetsPage(userID int64, filter BetHistoryFilter) ([]BetHistoryItem, int64, error) {
+	query := `
+		SELECT id, market_id, icon, question, outcome, amount, placed_at, source, status, payout, open, expires_at
+		FROM (
+			SELECT b.id, b.market_id, m.icon, m.question, b.outcome, b.amount, b.placed_at, b.source,
+			       ` + betStatusSQL + ` AS status,
+			       m.status = 'ACTIVE' AND m.hidden = 0 AS open, m.expires_at,
+			       COALESCE(t.amount, 0) AS payout
+			FROM bets b
+			JOIN markets m ON b.market_id = m.id
+			LEFT JOIN transactions t ON t.user_id = b.user_id
+				AND t.source_type = 'WIN_PAYOUT'
+				AND t.description LIKE 'Win payout for bet #' || b.id || ' on market %'
+			WHERE b.user_id = ?
+		) AS history
+		WHERE 1 = 1`
+	args := []interface{}{userID}
+
+	if filter.Cursor > 0 {
+		query += ` AND id < ?`
+		args = append(args, filter.Cursor)
+	}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.Outcome != "" {
+		query += ` AND outcome = ?`
+		args = append(args, filter.Outcome)
+	}
+
+	query += ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		// Fetch one extra row to know whether there is a next page
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit+1)
+	}
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query user bets: %w", err)
+		return nil, 0, fmt.Errorf("failed to query user bets: %w", err)
 	}
 	defer rows.Close()
 
+	cancelWindow := GetBetCancelPolicy().Window
 	var bets []BetHistoryItem
 	for rows.Next() {
 		var b BetHistoryItem
-		var marketStatus, marketOutcome sql.NullString
-		var placedAt time.Time
+		var placedAt, expiresAt time.Time
+		var open bool
 
-		err := rows.Scan(&b.ID, &b.MarketID, &b.Question, &b.OutcomeChosen, &b.Amount, &placedAt, &marketStatus, &marketOutcome)
+		err := rows.Scan(&b.ID, &b.MarketID, &b.Icon, &b.Question, &b.OutcomeChosen, &b.Amount, &placedAt, &b.Source, &b.Status, &b.Payout, &open, &expiresAt)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan bet: %w", err)
+			return nil, 0, fmt.Errorf("failed to scan bet: %w", err)
 		}
+		b.Cancellable = open && time.Now().Before(expiresAt) && (cancelWindow == 0 || time.Since(placedAt) <= cancelWindow)
 
 		b.PlacedAt = placedAt.Format("2006-01-02T15:04:05Z07:00")
-
-		// Determine bet status based on market status and outcome
-		b.Status = computeBetStatus(marketStatus.String, marketOutcome.String, b.OutcomeChosen)
-
-		// Calculate payout for won bets
-		if b.Status == BetStatusWon {
-			// Get the payout amount from transactions
-			var payout int64
-			err = db.QueryRow(`
-				SELECT amount
-				FROM transactions
-				WHERE user_id = ? AND source_type = 'WIN_PAYOUT'
-				AND description LIKE ?
-			`, userID, fmt.Sprintf("%%bet #%% on market #%d%%", b.MarketID)).Scan(&payout)
-			if err == nil && payout > 0 {
-				b.Payout = payout
-			}
+		if b.Status != BetStatusWon {
+			b.Payout = 0
 		}
 
 		bets = append(bets, b)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating bets: %w", err)
+		return nil, 0, fmt.Errorf("error iterating bets: %w", err)
 	}
 
-	return bets, nil
+	var nextCursor int64
+	if filter.Limit > 0 && len(bets) > filter.Limit {
+		bets = bets[:filter.Limit]
+		nextCursor = bets[len(bets)-1].ID
+	}
+
+	return bets, nextCursor, nil
 }
 
-// GetUserActiveBets returns all bets for a user on active markets
+// GetUserActiveBets returns all bets for a user (internal user ID) on active markets
 func GetUserActiveBets(userID int64) ([]ActiveBetItem, error) {
 	rows, err := db.Query(`
-		SELECT b.id, b.market_id, m.question, b.outcome, b.amount, m.expires_at
+		SELECT b.id, b.market_id, m.icon, m.question, b.outcome, b.amount, m.expires_at
 		FROM bets b
 		JOIN markets m ON b.market_id = m.id
-		WHERE b.user_id = ? AND m.status = 'ACTIVE'
+		WHERE b.user_id = ? AND m.status IN ('ACTIVE', 'LAST_CALL')
 		ORDER BY m.expires_at ASC
 	`, userID)
 	if err != nil {
@@ -892,7 +1242,7 @@ func GetUserActiveBets(userID int64) ([]ActiveBetItem, error) {
 		var b ActiveBetItem
 		var expiresAt time.Time
 
-		err := rows.Scan(&b.ID, &b.MarketID, &b.Question, &b.OutcomeChosen, &b.Amount, &expiresAt)
+		err := rows.Scan(&b.ID, &b.MarketID, &b.Icon, &b.Question, &b.OutcomeChosen, &b.Amount, &expiresAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan active bet: %w", err)
 		}
@@ -905,51 +1255,22 @@ func GetUserActiveBets(userID int64) ([]ActiveBetItem, error) {
 		return nil, fmt.Errorf("error iterating active bets: %w", err)
 	}
 
-	// Get pool totals for each market
+	// Get pool totals for each market, as shown to users
 	for i := range bets {
-		var poolYes, poolNo int64
-		err = db.QueryRow(`
-			SELECT 
-				COALESCE(SUM(CASE WHEN outcome = 'YES' THEN amount ELSE 0 END), 0),
-				COALESCE(SUM(CASE WHEN outcome = 'NO' THEN amount ELSE 0 END), 0)
-			FROM bets WHERE market_id = ?
-		`, bets[i].MarketID).Scan(&poolYes, &poolNo)
+		pools, err := GetPublicPools(bets[i].MarketID)
 		if err != nil {
-			poolYes, poolNo = 0, 0
+			pools = PublicPools{}
+		}
+		bets[i].PoolYes, bets[i].PoolNo = pools.Yes, pools.No
+		bets[i].PoolsHidden, bets[i].SidesHidden = pools.PoolsHidden, pools.SidesHidden
+		if pools.SidesHidden {
+			bets[i].PoolTotal = pools.Total
 		}
-		bets[i].PoolYes = poolYes
-		bets[i].PoolNo = poolNo
 	}
 
 	return bets, nil
 }
 
-// computeBetStatus determines the status of a bet based on market state
-func computeBetStatus(marketStatus, marketOutcome, betOutcome string) BetStatus {
-	// Active markets are pending
-	if marketStatus == string(MarketStatusActive) || marketStatus == string(MarketStatusLocked) {
-		return BetStatusPending
-	}
-
-	// Refunded if market was never resolved (edge case)
-	if marketStatus == "" && marketOutcome == "" {
-		return BetStatusRefunded
-	}
-
-	// Market is resolved/finalized
-	if marketOutcome == "" {
-		return BetStatusPending
-	}
-
-	// If bet outcome matches market outcome, it's a win
-	if betOutcome == marketOutcome {
-		return BetStatusWon
-	}
-
-	// Otherwise it's a loss
-	return BetStatusLost
-}
-
 // UserStats represents user statistics
 type UserStats struct {
 	TotalBets  int     `json:"total_bets"`
@@ -958,9 +1279,12 @@ type UserStats struct {
 	WinRate    float64 `json:"win_rate"`
 	TotalWager int64   `json:"total_wager"`
 	TotalWins  int64   `json:"total_wins"`
+	// CurrentStreak counts consecutive won markets, or lost ones as a negative number
+	CurrentStreak int `json:"current_streak"`
+	BestStreak    int `json:"best_streak"`
 }
 
-// GetUserStats returns statistics for a user
+// GetUserStats returns statistics for a user (internal user ID)
 func GetUserStats(userID int64) (*UserStats, error) {
 	stats := &UserStats{}
 
@@ -1009,6 +1333,14 @@ func GetUserStats(userID int64) (*UserStats, error) {
 		return nil, fmt.Errorf("failed to get total wins: %w", err)
 	}
 
+	// Get streaks (no row until the user's first market is finalized)
+	err = db.QueryRow(`
+		SELECT current, best FROM user_streaks WHERE user_id = ?
+	`, userID).Scan(&stats.CurrentStreak, &stats.BestStreak)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get streaks: %w", err)
+	}
+
 	// Calculate win rate
 	if stats.TotalBets > 0 {
 		stats.WinRate = float64(stats.Wins) / float64(stats.TotalBets) * 100
@@ -1017,50 +1349,6 @@ func GetUserStats(userID int64) (*UserStats, error) {
 	return stats, nil
 }
 
-// GetTopUsers returns the top users by balance for the leaderboard
-func GetTopUsers(limit int) ([]LeaderboardEntry, error) {
-	// Use ROW_NUMBER() for proper ranking
-	rows, err := db.Query(`
-		SELECT 
-			ROW_NUMBER() OVER (ORDER BY balance DESC) as rank,
-			username,
-			first_name,
-			balance
-		FROM users
-		ORDER BY balance DESC
-		LIMIT ?
-	`, limit)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query leaderboard: %w", err)
-	}
-	defer rows.Close()
-
-	var leaderboard []LeaderboardEntry
-	for rows.Next() {
-		var entry LeaderboardEntry
-		var username sql.NullString
-		err := rows.Scan(&entry.Rank, &username, &entry.Name, &entry.Balance)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan leaderboard entry: %w", err)
-		}
-
-		if username.Valid {
-			entry.Username = username.String
-		} else {
-			entry.Username = ""
-		}
-		entry.BalanceDisplay = fmt.Sprintf("%d", entry.Balance)
-
-		leaderboard = append(leaderboard, entry)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating leaderboard: %w", err)
-	}
-
-	return leaderboard, nil
-}
-
 // GetLastBailout returns the timestamp of the last bailout transaction for a user
 // Returns (time.Time{}, false) if no bailout exists
 func GetLastBailout(userID int64) (time.Time, bool, error) {
@@ -1079,6 +1367,21 @@ func GetLastBailout(userID int64) (time.Time, bool, error) {
 	return lastBailout, true, nil
 }
 
+// GetLockedInOpenBets returns the total a user has staked on markets that are not settled yet
+func GetLockedInOpenBets(userID int64) (int64, error) {
+	var locked int64
+	err := db.QueryRow(`
+		SELECT COALESCE(SUM(b.amount), 0)
+		FROM bets b
+		JOIN markets m ON b.market_id = m.id
+		WHERE b.user_id = ? AND m.status IN ('ACTIVE', 'LAST_CALL', 'LOCKED', 'RESOLVED', 'DISPUTED')
+	`, userID).Scan(&locked)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get locked bets: %w", err)
+	}
+	return locked, nil
+}
+
 // ExecuteBailout executes a bailout transaction for a bankrupt user
 // Sets balance to BailoutAmount (50000 cents = 500 WSC)
 // Returns the new balance or an error
@@ -2,14 +2,18 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
+
+	"predictionbot/internal/statemachine"
 )
 
 func setupTestDB(t *testing.T) {
-	// Use in-memory database for tests
-	if err := InitDB(":memory:"); err != nil {
+	// In-memory SQLite, or PostgreSQL when TEST_DATABASE_URL is set
+	if err := InitTestDB(); err != nil {
 		t.Fatalf("Failed to initialize test database: %v", err)
 	}
 }
@@ -171,6 +175,7 @@ func TestUpdateMarketStatusWithOutcome(t *testing.T) {
 	market, _ := CreateMarket(user.ID, "Outcome test market?", expiresAt)
 
 	// Update to RESOLVED with outcome
+	UpdateMarketStatus(market.ID, MarketStatusLocked, "")
 	err := UpdateMarketStatus(market.ID, MarketStatusResolved, "YES")
 	if err != nil {
 		t.Fatalf("UpdateMarketStatus failed: %v", err)
@@ -186,6 +191,71 @@ func TestUpdateMarketStatusWithOutcome(t *testing.T) {
 	}
 }
 
+func TestUpdateMarketStatusRejectsInvalidTransition(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := CreateUser(33334, "jumper", "Jumper")
+	market, _ := CreateMarket(user.ID, "Can this skip ahead?", time.Now().Add(24*time.Hour))
+
+	err := UpdateMarketStatus(market.ID, MarketStatusFinalized, "YES")
+	var transitionErr *statemachine.TransitionError
+	if !errors.As(err, &transitionErr) || transitionErr.From != statemachine.Active || transitionErr.To != statemachine.Finalized {
+		t.Fatalf("Expected a TransitionError from ACTIVE to FINALIZED, got %v", err)
+	}
+	if updated, _ := GetMarketByID(market.ID); updated.Status != MarketStatusActive || updated.Outcome != "" {
+		t.Errorf("Expected the market left ACTIVE without an outcome, got %s %q", updated.Status, updated.Outcome)
+	}
+
+	if err := UpdateMarketStatus(999999, MarketStatusLocked, ""); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected market not found, got %v", err)
+	}
+}
+
+func TestUpdateMarketStatusTx(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	user, _ := CreateUser(33335, "mover", "Mover")
+	market, _ := CreateMarket(user.ID, "Will every move be checked?", time.Now().Add(24*time.Hour))
+	UpdateMarketStatus(market.ID, MarketStatusLocked, "")
+
+	move := func(change MarketStatusChange) (MarketStatus, error) {
+		tx, _ := db.BeginTx(ctx, nil)
+		defer tx.Rollback()
+		from, err := UpdateMarketStatusTx(ctx, tx, market.ID, change)
+		if err == nil {
+			tx.Commit()
+		}
+		return from, err
+	}
+
+	// A move the lifecycle allows is still refused when the caller expects another status
+	var transitionErr *statemachine.TransitionError
+	if _, err := move(MarketStatusChange{To: MarketStatusResolved, From: []MarketStatus{MarketStatusDisputed}}); !errors.As(err, &transitionErr) {
+		t.Errorf("Expected a TransitionError from LOCKED, got %v", err)
+	}
+	from, err := move(MarketStatusChange{To: MarketStatusResolved, Set: "outcome = ?", Args: []interface{}{"NO"}})
+	if err != nil || from != MarketStatusLocked {
+		t.Fatalf("Expected a move from LOCKED, got %s, %v", from, err)
+	}
+	if updated, _ := GetMarketByID(market.ID); updated.Status != MarketStatusResolved || updated.Outcome != "NO" {
+		t.Errorf("Expected the market RESOLVED NO, got %s %q", updated.Status, updated.Outcome)
+	}
+
+	// Writers that used to set statuses themselves go through the lifecycle too
+	if _, err := move(MarketStatusChange{To: MarketStatusLocked}); !errors.As(err, &transitionErr) {
+		t.Errorf("Expected a TransitionError from RESOLVED to LOCKED, got %v", err)
+	}
+	if locked, err := LockMarket(market.ID); locked || err != nil {
+		t.Errorf("Expected a resolved market not to lock, got %t, %v", locked, err)
+	}
+	if updated, _ := GetMarketByID(market.ID); updated.Status != MarketStatusResolved {
+		t.Errorf("Expected the market left RESOLVED, got %s", updated.Status)
+	}
+}
+
 func TestPlaceBet(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
@@ -313,6 +383,67 @@ func TestGetUserBets(t *testing.T) {
 	}
 }
 
+func TestGetUserBetsPage(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := CreateUser(333334, "pager", "Pager")
+	expiresAt := time.Now().Add(24 * time.Hour)
+	ctx := context.Background()
+
+	var marketIDs []int64
+	for i := 0; i < 5; i++ {
+		market, _ := CreateMarket(user.ID, fmt.Sprintf("Paged market %d", i), expiresAt)
+		marketIDs = append(marketIDs, market.ID)
+		outcome := "YES"
+		if i%2 == 1 {
+			outcome = "NO"
+		}
+		if err := PlaceBet(ctx, user.ID, market.ID, outcome, 10); err != nil {
+			t.Fatalf("PlaceBet failed: %v", err)
+		}
+	}
+
+	// Finalize the first market with the user's outcome and record the payout
+	UpdateMarketStatus(marketIDs[0], MarketStatusLocked, "")
+	UpdateMarketStatus(marketIDs[0], MarketStatusResolved, "YES")
+	UpdateMarketStatus(marketIDs[0], MarketStatusFinalized, "YES")
+	var betID int64
+	db.QueryRow(`SELECT id FROM bets WHERE market_id = ?`, marketIDs[0]).Scan(&betID)
+	db.Exec(`INSERT INTO transactions (user_id, amount, source_type, description) VALUES (?, 25, 'WIN_PAYOUT', ?)`,
+		user.ID, fmt.Sprintf("Win payout for bet #%d on market #%d (bet: 10, payout: 25, profit: 15)", betID, marketIDs[0]))
+
+	page, cursor, err := GetUserBetsPage(user.ID, BetHistoryFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetUserBetsPage failed: %v", err)
+	}
+	if len(page) != 2 || cursor != page[1].ID {
+		t.Fatalf("Expected 2 bets and a cursor, got %d bets cursor %d", len(page), cursor)
+	}
+
+	seen := len(page)
+	for cursor != 0 {
+		page, cursor, err = GetUserBetsPage(user.ID, BetHistoryFilter{Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("GetUserBetsPage failed: %v", err)
+		}
+		seen += len(page)
+	}
+	if seen != 5 {
+		t.Errorf("Expected to page through 5 bets, got %d", seen)
+	}
+
+	won, _, _ := GetUserBetsPage(user.ID, BetHistoryFilter{Status: BetStatusWon})
+	if len(won) != 1 || won[0].Payout != 25 {
+		t.Errorf("Expected 1 won bet with payout 25, got %+v", won)
+	}
+
+	no, _, _ := GetUserBetsPage(user.ID, BetHistoryFilter{Outcome: "NO"})
+	if len(no) != 2 {
+		t.Errorf("Expected 2 NO bets, got %d", len(no))
+	}
+}
+
 func TestGetUserStats(t *testing.T) {
 	setupTestDB(t)
 	defer cleanupTestDB(t)
@@ -433,3 +564,97 @@ func TestGetMarketWithPools(t *testing.T) {
 		t.Errorf("Expected poolNo 300, got %d", marketWithPools.PoolNo)
 	}
 }
+
+func TestMarketResolutionCriteria(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	user, _ := CreateUser(12345, "creator", "Creator")
+	market, _ := CreateMarket(user.ID, "Will it rain tomorrow?", time.Now().Add(time.Hour))
+
+	if criteria, err := GetMarketResolutionCriteria(market.ID); err != nil || criteria != "" {
+		t.Fatalf("Expected no criteria, got %q (err=%v)", criteria, err)
+	}
+	if err := SetMarketResolutionCriteria(market.ID, "YES if the weather service reports rain"); err != nil {
+		t.Fatalf("SetMarketResolutionCriteria failed: %v", err)
+	}
+
+	PlaceBet(ctx, user.ID, market.ID, "YES", 10)
+	UpdateMarketStatus(market.ID, MarketStatusLocked, "")
+	UpdateMarketStatus(market.ID, MarketStatusResolved, "YES")
+	eligible, err := GetMarketsEligibleForDispute(user.ID)
+	if err != nil || len(eligible) != 1 || eligible[0].ResolutionCriteria != "YES if the weather service reports rain" {
+		t.Errorf("Expected criteria on disputeable market, got %+v (err=%v)", eligible, err)
+	}
+}
+
+func TestListActiveMarketsPaged(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := CreateUser(888881, "pages", "Pages")
+	viewer, _ := CreateUser(888882, "viewer", "Viewer")
+	expiresAt := time.Now().Add(24 * time.Hour)
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		market, _ := CreateMarket(user.ID, fmt.Sprintf("Paged market %d", i), expiresAt)
+		ids = append(ids, market.ID)
+	}
+	SetMarketTags(ids[0], []string{"sports"})
+	SetMarketTags(ids[3], []string{"sports"})
+	HideMarketForUser(viewer.ID, ids[4], 0)
+
+	page, total, err := ListActiveMarketsPaged(0, 0, "", 2, 1)
+	if err != nil {
+		t.Fatalf("ListActiveMarketsPaged failed: %v", err)
+	}
+	if total != 5 || len(page) != 2 || page[0].ID != ids[3] || page[1].ID != ids[2] {
+		t.Errorf("Expected markets %d and %d of 5, got %+v (total %d)", ids[3], ids[2], page, total)
+	}
+
+	page, total, _ = ListActiveMarketsPaged(viewer.ID, 0, "", 10, 0)
+	if total != 4 || len(page) != 4 {
+		t.Errorf("Expected the snoozed market to be left out, got %d of %d", len(page), total)
+	}
+
+	page, total, _ = ListActiveMarketsPaged(0, 0, "sports", 1, 1)
+	if total != 2 || len(page) != 1 || page[0].ID != ids[0] {
+		t.Errorf("Expected the second sports market of 2, got %+v (total %d)", page, total)
+	}
+}
+
+func TestGroupMarkets(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := CreateUser(888883, "groupie", "Groupie")
+	expiresAt := time.Now().Add(24 * time.Hour)
+	public, _ := CreateMarket(user.ID, "Will the public market stay public?", expiresAt)
+	group, err := CreateMarketWithOptions(user.ID, "Will the team lunch be pizza?", expiresAt, MarketOptions{GroupID: -100123})
+	if err != nil {
+		t.Fatalf("CreateMarketWithOptions failed: %v", err)
+	}
+	CreateMarketWithOptions(user.ID, "Will the other group agree?", expiresAt, MarketOptions{GroupID: -100456})
+
+	if market, _ := GetMarketByID(group.ID); market.GroupID != -100123 {
+		t.Errorf("Expected the market to belong to the group, got %d", market.GroupID)
+	}
+
+	// Group markets stay in their group
+	if markets, _ := ListActiveMarketsWithCreator(); len(markets) != 1 || markets[0].ID != public.ID {
+		t.Errorf("Expected only the public market, got %+v", markets)
+	}
+	if markets, _ := ListActiveMarkets(); len(markets) != 1 {
+		t.Errorf("Expected only the public market, got %+v", markets)
+	}
+	if markets, _ := SearchMarkets("pizza", 10); len(markets) != 0 {
+		t.Errorf("Expected group markets not to be found by search, got %+v", markets)
+	}
+	if markets, _ := ListGroupMarkets(-100123, 0); len(markets) != 1 || markets[0].ID != group.ID {
+		t.Errorf("Expected only the group's market, got %+v", markets)
+	}
+	if page, total, _ := ListActiveMarketsPaged(0, -100456, "", 10, 0); total != 1 || len(page) != 1 {
+		t.Errorf("Expected one market for the other group, got %+v (total %d)", page, total)
+	}
+}
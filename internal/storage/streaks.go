@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// StreakUpdate is a user's streak after one market result
+type StreakUpdate struct {
+	UserID int64
+	// Current counts consecutive won markets, or lost ones as a negative number
+	Current int
+	Best    int
+	// Ended is the length of the win streak this result broke, or 0
+	Ended int
+}
+
+// UpdateStreakTx records a won or lost market for a user (internal ID) inside the finalization
+// transaction and returns the new streak.
+func UpdateStreakTx(ctx context.Context, tx *sql.Tx, userID int64, won bool) (StreakUpdate, error) {
+	update := StreakUpdate{UserID: userID}
+
+	var current, best int
+	err := tx.QueryRowContext(ctx, `SELECT current, best FROM user_streaks WHERE user_id = ?`, userID).Scan(&current, &best)
+	if err != nil && err != sql.ErrNoRows {
+		return update, fmt.Errorf("failed to get streak: %w", err)
+	}
+
+	switch {
+	case won && current > 0:
+		current++
+	case won:
+		current = 1
+	case current > 0:
+		update.Ended = current
+		current = -1
+	default:
+		current--
+	}
+	if current > best {
+		best = current
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_streaks (user_id, current, best, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(user_id) DO UPDATE SET current = excluded.current, best = excluded.best, updated_at = CURRENT_TIMESTAMP
+	`, userID, current, best)
+	if err != nil {
+		return update, fmt.Errorf("failed to update streak: %w", err)
+	}
+
+	update.Current = current
+	update.Best = best
+	return update, nil
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUpdateStreakTx(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	user, _ := CreateUser(4001, "streaker", "Streaker")
+
+	results := []bool{true, true, true, false, false, true}
+	want := []StreakUpdate{
+		{Current: 1, Best: 1},
+		{Current: 2, Best: 2},
+		{Current: 3, Best: 3},
+		{Current: -1, Best: 3, Ended: 3},
+		{Current: -2, Best: 3},
+		{Current: 1, Best: 3},
+	}
+
+	for i, won := range results {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("Failed to begin transaction: %v", err)
+		}
+		update, err := UpdateStreakTx(ctx, tx, user.ID, won)
+		if err != nil {
+			t.Fatalf("UpdateStreakTx failed: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		want[i].UserID = user.ID
+		if update != want[i] {
+			t.Errorf("Result %d: got %+v, want %+v", i+1, update, want[i])
+		}
+	}
+
+	stats, err := GetUserStats(user.ID)
+	if err != nil {
+		t.Fatalf("GetUserStats failed: %v", err)
+	}
+	if stats.CurrentStreak != 1 || stats.BestStreak != 3 {
+		t.Errorf("Expected current 1 and best 3 in stats, got %d and %d", stats.CurrentStreak, stats.BestStreak)
+	}
+}
+
+func TestUserPreferences(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := CreateUser(4001, "prefs", "Prefs")
+
+	prefs, err := GetUserPreferences(user.ID)
+	if err != nil {
+		t.Fatalf("GetUserPreferences failed: %v", err)
+	}
+	if prefs.ShowInWinners || !prefs.StreakNotifications {
+		t.Errorf("Expected anonymous winners and streak DMs by default, got %+v", prefs)
+	}
+
+	SetShowInWinners(user.ID, true)
+	SetStreakNotifications(user.ID, false)
+	prefs, _ = GetUserPreferences(user.ID)
+	if !prefs.ShowInWinners || prefs.StreakNotifications {
+		t.Errorf("Expected updated preferences, got %+v", prefs)
+	}
+
+	if err := SetStreakNotifications(9999, true); err == nil {
+		t.Error("Expected error for unknown user")
+	}
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetMarketTags attaches tags to a market. Tags the market already has are kept.
+func SetMarketTags(marketID int64, tags []string) error {
+	for _, tag := range tags {
+		if _, err := db.Exec(`INSERT INTO market_tags (market_id, tag) VALUES (?, ?) ON CONFLICT DO NOTHING`, marketID, tag); err != nil {
+			return fmt.Errorf("failed to tag market: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetMarketTags returns a market's tags in alphabetical order
+func GetMarketTags(marketID int64) ([]string, error) {
+	tagsByMarket, err := GetTagsForMarkets([]int64{marketID})
+	if err != nil {
+		return nil, err
+	}
+	return tagsByMarket[marketID], nil
+}
+
+// GetTagsForMarkets returns the tags of several markets at once, keyed by market ID
+func GetTagsForMarkets(marketIDs []int64) (map[int64][]string, error) {
+	tagsByMarket := make(map[int64][]string)
+	if len(marketIDs) == 0 {
+		return tagsByMarket, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(marketIDs)), ", ")
+	args := make([]interface{}, len(marketIDs))
+	for i, id := range marketIDs {
+		args[i] = id
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT market_id, tag FROM market_tags
+		WHERE market_id IN (%s)
+		ORDER BY market_id, tag
+	`, placeholders), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query market tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var marketID int64
+		var tag string
+		if err := rows.Scan(&marketID, &tag); err != nil {
+			return nil, fmt.Errorf("failed to scan market tag: %w", err)
+		}
+		tagsByMarket[marketID] = append(tagsByMarket[marketID], tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating market tags: %w", err)
+	}
+
+	return tagsByMarket, nil
+}
+
+// MarketAffinity summarizes what a user has bet on, for ranking the market list
+type MarketAffinity struct {
+	// BetMarkets holds the markets the user has at least one bet on
+	BetMarkets map[int64]bool
+	// TagBets counts the user's bets per tag, across all markets
+	TagBets map[string]int
+}
+
+// GetMarketAffinity returns the bet-based affinity of a user (internal ID)
+func GetMarketAffinity(userID int64) (*MarketAffinity, error) {
+	affinity := &MarketAffinity{
+		BetMarkets: make(map[int64]bool),
+		TagBets:    make(map[string]int),
+	}
+
+	rows, err := db.Query(`SELECT DISTINCT market_id FROM bets WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bet markets: %w", err)
+	}
+	for rows.Next() {
+		var marketID int64
+		if err := rows.Scan(&marketID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan bet market: %w", err)
+		}
+		affinity.BetMarkets[marketID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bet markets: %w", err)
+	}
+
+	rows, err = db.Query(`
+		SELECT t.tag, COUNT(*)
+		FROM bets b
+		JOIN market_tags t ON t.market_id = b.market_id
+		WHERE b.user_id = ?
+		GROUP BY t.tag
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag affinity: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag string
+		var count int
+		if err := rows.Scan(&tag, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag affinity: %w", err)
+		}
+		affinity.TagBets[tag] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tag affinity: %w", err)
+	}
+
+	return affinity, nil
+}
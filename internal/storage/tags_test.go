@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMarketTags(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := CreateUser(4001, "tagger", "Tagger")
+	expiresAt := time.Now().Add(24 * time.Hour)
+	first, _ := CreateMarket(user.ID, "Will #football be on TV?", expiresAt)
+	second, _ := CreateMarket(user.ID, "Will it rain tomorrow?", expiresAt)
+
+	if err := SetMarketTags(first.ID, []string{"football", "tv"}); err != nil {
+		t.Fatalf("SetMarketTags failed: %v", err)
+	}
+	// Tagging twice keeps a single copy of each tag
+	if err := SetMarketTags(first.ID, []string{"football"}); err != nil {
+		t.Fatalf("SetMarketTags (repeat) failed: %v", err)
+	}
+
+	tags, err := GetMarketTags(first.ID)
+	if err != nil {
+		t.Fatalf("GetMarketTags failed: %v", err)
+	}
+	if !reflect.DeepEqual(tags, []string{"football", "tv"}) {
+		t.Errorf("Expected [football tv], got %v", tags)
+	}
+
+	byMarket, err := GetTagsForMarkets([]int64{first.ID, second.ID})
+	if err != nil {
+		t.Fatalf("GetTagsForMarkets failed: %v", err)
+	}
+	if len(byMarket[first.ID]) != 2 || byMarket[second.ID] != nil {
+		t.Errorf("Unexpected tags by market: %v", byMarket)
+	}
+}
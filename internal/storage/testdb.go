@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// InitTestDB opens an empty database for tests: in-memory SQLite, or PostgreSQL when
+// TEST_DATABASE_URL names a server. Each test binary gets its own PostgreSQL database, named
+// after the package, which is emptied by reverting every migration and applying them again.
+func InitTestDB() error {
+	serverURL := os.Getenv("TEST_DATABASE_URL")
+	if serverURL == "" {
+		return InitDB(":memory:")
+	}
+
+	dsn, err := testDatabaseURL(serverURL)
+	if err != nil {
+		return err
+	}
+	if err := InitDB(dsn); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if err := MigrateDown(ctx, 0); err != nil {
+		return err
+	}
+	return MigrateUp(ctx)
+}
+
+// testDatabaseURL creates the test binary's database on the server at serverURL if it does
+// not exist yet, and returns its URL
+func testDatabaseURL(serverURL string) (string, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil || !IsPostgresURL(serverURL) {
+		return "", fmt.Errorf("invalid TEST_DATABASE_URL: want a postgres:// URL")
+	}
+	binary := strings.TrimSuffix(filepath.Base(os.Args[0]), ".test")
+	name := strings.Trim(u.Path, "/") + "_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(binary))
+
+	server, err := sql.Open("pgx", serverURL)
+	if err != nil {
+		return "", err
+	}
+	defer server.Close()
+	var exists int
+	if err := server.QueryRow(`SELECT COUNT(*) FROM pg_database WHERE datname = $1`, name).Scan(&exists); err != nil {
+		return "", fmt.Errorf("failed to look up test database %s: %w", name, err)
+	}
+	if exists == 0 {
+		if _, err := server.Exec(`CREATE DATABASE ` + name); err != nil {
+			return "", fmt.Errorf("failed to create test database %s: %w", name, err)
+		}
+	}
+
+	u.Path = "/" + name
+	return u.String(), nil
+}
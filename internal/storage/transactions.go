@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// TransactionTypes are the source types of the transactions log
+var TransactionTypes = []string{
+	"WELCOME_BONUS", "BAILOUT", "PROMO", "ADJUSTMENT",
+	"BET_PLACED", "BET_CANCELLED", "WIN_PAYOUT", "REFUND",
+	"VOUCHER_CREDIT", "VOUCHER_REFUND",
+	"SHARES_BOUGHT", "SHARES_SOLD", "SHARES_PAYOUT",
+	"MARKET_MAKER_SUBSIDY", "MARKET_MAKER_REFUND",
+	"DISPUTE_BOND", "DISPUTE_BOND_REFUND",
+	"FEE", "CREATOR_REWARD",
+}
+
+// TransactionSort orders a page of transactions
+type TransactionSort string
+
+const (
+	TransactionSortNewest TransactionSort = "newest"
+	TransactionSortOldest TransactionSort = "oldest"
+	// TransactionSortLargest puts the biggest movements first, credits and debits alike
+	TransactionSortLargest TransactionSort = "largest"
+)
+
+// transactionOrderSQL maps each sort to its ORDER BY; the ID keeps pages stable
+var transactionOrderSQL = map[TransactionSort]string{
+	TransactionSortNewest:  `created_at DESC, id DESC`,
+	TransactionSortOldest:  `created_at ASC, id ASC`,
+	TransactionSortLargest: `ABS(amount) DESC, id DESC`,
+}
+
+// TransactionFilter selects a page of a user's transactions. Zero fields do not filter.
+type TransactionFilter struct {
+	Type     string
+	MarketID int64
+	// From and To bound created_at: From inclusive, To exclusive
+	From   time.Time
+	To     time.Time
+	Sort   TransactionSort
+	Limit  int
+	Offset int
+}
+
+// ListUserTransactions returns a page of a user's (internal ID) transactions and how many match
+// the filter in total. A Limit of 0 returns every match.
+func ListUserTransactions(userID int64, filter TransactionFilter) ([]Transaction, int, error) {
+	where := ` WHERE user_id = ?`
+	args := []interface{}{userID}
+	if filter.Type != "" {
+		where += ` AND source_type = ?`
+		args = append(args, filter.Type)
+	}
+	if filter.MarketID != 0 {
+		where += ` AND market_id = ?`
+		args = append(args, filter.MarketID)
+	}
+	if !filter.From.IsZero() {
+		where += ` AND created_at >= ?`
+		args = append(args, sqlTimestamp(filter.From))
+	}
+	if !filter.To.IsZero() {
+		where += ` AND created_at < ?`
+		args = append(args, sqlTimestamp(filter.To))
+	}
+
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM transactions`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count transactions: %w", err)
+	}
+
+	order, ok := transactionOrderSQL[filter.Sort]
+	if !ok {
+		order = transactionOrderSQL[TransactionSortNewest]
+	}
+	query := `SELECT id, user_id, amount, source_type, COALESCE(description, ''), market_id, created_at FROM transactions` + where + ` ORDER BY ` + order
+	if filter.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []Transaction
+	for rows.Next() {
+		var t Transaction
+		var marketID sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Amount, &t.SourceType, &t.Description, &marketID, &t.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		t.MarketID = marketID.Int64
+		transactions = append(transactions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating transactions: %w", err)
+	}
+	return transactions, total, nil
+}
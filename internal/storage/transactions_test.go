@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestListUserTransactions(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	user, _ := CreateUser(4101, "ledger", "Ledger")
+	first, _ := CreateMarket(user.ID, "Will the first market settle?", time.Now().Add(time.Hour))
+	second, _ := CreateMarket(user.ID, "Will the second market settle?", time.Now().Add(time.Hour))
+	PlaceBet(ctx, user.ID, first.ID, "YES", 30)
+	PlaceBet(ctx, user.ID, second.ID, "NO", 70)
+	AdjustBalance(ctx, user.ID, 5, "support", 0)
+
+	all, total, err := ListUserTransactions(user.ID, TransactionFilter{})
+	if err != nil {
+		t.Fatalf("ListUserTransactions failed: %v", err)
+	}
+	// Welcome bonus, two bets and the adjustment, newest first
+	if total != 4 || len(all) != 4 || all[0].SourceType != "ADJUSTMENT" || all[3].SourceType != "WELCOME_BONUS" {
+		t.Fatalf("Expected 4 transactions newest first, got %d %+v", total, all)
+	}
+
+	bets, total, _ := ListUserTransactions(user.ID, TransactionFilter{Type: "BET_PLACED", Limit: 1, Offset: 1, Sort: TransactionSortOldest})
+	if total != 2 || len(bets) != 1 || bets[0].MarketID != second.ID || bets[0].Amount != -70 {
+		t.Errorf("Expected the second bet on the second page, got %d %+v", total, bets)
+	}
+
+	byMarket, _, _ := ListUserTransactions(user.ID, TransactionFilter{MarketID: first.ID})
+	if len(byMarket) != 1 || byMarket[0].Amount != -30 {
+		t.Errorf("Expected only the first market's bet, got %+v", byMarket)
+	}
+
+	largest, _, _ := ListUserTransactions(user.ID, TransactionFilter{Sort: TransactionSortLargest, Limit: 2})
+	if len(largest) != 2 || largest[0].SourceType != "WELCOME_BONUS" || largest[1].Amount != -70 {
+		t.Errorf("Expected the largest movements first, got %+v", largest)
+	}
+
+	if future, total, _ := ListUserTransactions(user.ID, TransactionFilter{From: time.Now().Add(time.Hour)}); total != 0 || len(future) != 0 {
+		t.Errorf("Expected nothing after the from date, got %+v", future)
+	}
+	if past, total, _ := ListUserTransactions(user.ID, TransactionFilter{To: time.Now().Add(-time.Hour)}); total != 0 || len(past) != 0 {
+		t.Errorf("Expected nothing before the to date, got %+v", past)
+	}
+}
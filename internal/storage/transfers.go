@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TransferStatus represents the status of a market ownership transfer
+type TransferStatus string
+
+const (
+	// TransferStatusPending means the creator has requested the transfer and must confirm it
+	TransferStatusPending TransferStatus = "PENDING"
+	// TransferStatusConfirmed means the creator confirmed and the recipient must accept
+	TransferStatusConfirmed TransferStatus = "CONFIRMED"
+	TransferStatusAccepted  TransferStatus = "ACCEPTED"
+	TransferStatusDeclined  TransferStatus = "DECLINED"
+	TransferStatusCancelled TransferStatus = "CANCELLED"
+)
+
+// AuditEntityMarket is the audit log entity type for markets
+const AuditEntityMarket = "market"
+
+// MarketTransfer represents a request to hand a market over to another user
+type MarketTransfer struct {
+	ID         int64          `json:"id"`
+	MarketID   int64          `json:"market_id"`
+	FromUserID int64          `json:"from_user_id"`
+	ToUserID   int64          `json:"to_user_id"`
+	Status     TransferStatus `json:"status"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// CreateMarketTransfer opens a transfer of a market from its creator to another user.
+// Both user IDs are internal IDs.
+func CreateMarketTransfer(ctx context.Context, marketID, fromUserID, toUserID int64) (*MarketTransfer, error) {
+	if fromUserID == toUserID {
+		return nil, fmt.Errorf("invalid recipient: cannot transfer a market to yourself")
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var creatorID int64
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT creator_id, status FROM markets WHERE id = ?`, marketID).Scan(&creatorID, &status)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("market not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+
+	if creatorID != fromUserID {
+		return nil, fmt.Errorf("only the market creator can transfer this market")
+	}
+
+	if status != string(MarketStatusActive) && status != string(MarketStatusLastCall) && status != string(MarketStatusLocked) {
+		return nil, fmt.Errorf("market cannot be transferred: status is %s", status)
+	}
+
+	var openCount int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM market_transfers
+		WHERE market_id = ? AND status IN ('PENDING', 'CONFIRMED')
+	`, marketID).Scan(&openCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check open transfers: %w", err)
+	}
+	if openCount > 0 {
+		return nil, fmt.Errorf("a transfer is already pending for this market")
+	}
+
+	var transferID int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO market_transfers (market_id, from_user_id, to_user_id, status)
+		VALUES (?, ?, ?, 'PENDING')
+		RETURNING id
+	`, marketID, fromUserID, toUserID).Scan(&transferID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert transfer: %w", err)
+	}
+
+	details := fmt.Sprintf("transfer_id=%d to_user_id=%d", transferID, toUserID)
+	if err := logAuditTx(ctx, tx, fromUserID, "transfer_requested", AuditEntityMarket, marketID, details); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetMarketTransfer(transferID)
+}
+
+// GetMarketTransfer retrieves a transfer by its ID
+func GetMarketTransfer(id int64) (*MarketTransfer, error) {
+	var transfer MarketTransfer
+	err := db.QueryRow(`
+		SELECT id, market_id, from_user_id, to_user_id, status, created_at, updated_at
+		FROM market_transfers
+		WHERE id = ?
+	`, id).Scan(
+		&transfer.ID,
+		&transfer.MarketID,
+		&transfer.FromUserID,
+		&transfer.ToUserID,
+		&transfer.Status,
+		&transfer.CreatedAt,
+		&transfer.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transfer by id: %w", err)
+	}
+	return &transfer, nil
+}
+
+// UpdateMarketTransferStatus moves a transfer from one status to another and records it in the audit log.
+// The update only happens if the transfer is still in the expected status.
+func UpdateMarketTransferStatus(ctx context.Context, transferID, actorID int64, from, to TransferStatus) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var marketID int64
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT market_id, status FROM market_transfers WHERE id = ?`, transferID).Scan(&marketID, &status)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("transfer not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get transfer: %w", err)
+	}
+
+	if status != string(from) {
+		return fmt.Errorf("transfer is no longer %s: status is %s", strings.ToLower(string(from)), status)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE market_transfers
+		SET status = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, to, transferID)
+	if err != nil {
+		return fmt.Errorf("failed to update transfer: %w", err)
+	}
+
+	action := "transfer_" + strings.ToLower(string(to))
+	if err := logAuditTx(ctx, tx, actorID, action, AuditEntityMarket, marketID, fmt.Sprintf("transfer_id=%d", transferID)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// CompleteMarketTransfer hands the market over to the transfer recipient.
+// The transfer must be CONFIRMED by the creator and the market must still belong to them.
+func CompleteMarketTransfer(ctx context.Context, transferID, actorID int64) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var marketID, fromUserID, toUserID int64
+	var status string
+	err = tx.QueryRowContext(ctx, `
+		SELECT market_id, from_user_id, to_user_id, status
+		FROM market_transfers
+		WHERE id = ?
+	`, transferID).Scan(&marketID, &fromUserID, &toUserID, &status)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("transfer not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get transfer: %w", err)
+	}
+
+	if actorID != toUserID {
+		return fmt.Errorf("only the transfer recipient can accept this transfer")
+	}
+
+	if status != string(TransferStatusConfirmed) {
+		return fmt.Errorf("transfer is not awaiting acceptance: status is %s", status)
+	}
+
+	var creatorID int64
+	var marketStatus string
+	err = tx.QueryRowContext(ctx, `SELECT creator_id, status FROM markets WHERE id = ?`, marketID).Scan(&creatorID, &marketStatus)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("market not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get market: %w", err)
+	}
+
+	if creatorID != fromUserID {
+		return fmt.Errorf("market ownership has changed since the transfer was requested")
+	}
+
+	if marketStatus != string(MarketStatusActive) && marketStatus != string(MarketStatusLastCall) && marketStatus != string(MarketStatusLocked) {
+		return fmt.Errorf("market cannot be transferred: status is %s", marketStatus)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE markets SET creator_id = ? WHERE id = ?`, toUserID, marketID)
+	if err != nil {
+		return fmt.Errorf("failed to update market creator: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE market_transfers
+		SET status = 'ACCEPTED', updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, transferID)
+	if err != nil {
+		return fmt.Errorf("failed to update transfer: %w", err)
+	}
+
+	details := fmt.Sprintf("transfer_id=%d from_user_id=%d to_user_id=%d", transferID, fromUserID, toUserID)
+	if err := logAuditTx(ctx, tx, actorID, "transfer_accepted", AuditEntityMarket, marketID, details); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
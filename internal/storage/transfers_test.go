@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMarketTransferFlow(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(1001, "creator", "Creator")
+	recipient, _ := CreateUser(1002, "recipient", "Recipient")
+	market, _ := CreateMarket(creator.ID, "Will the transfer work?", time.Now().Add(time.Hour))
+
+	transfer, err := CreateMarketTransfer(ctx, market.ID, creator.ID, recipient.ID)
+	if err != nil {
+		t.Fatalf("CreateMarketTransfer failed: %v", err)
+	}
+	if transfer.Status != TransferStatusPending {
+		t.Errorf("Expected status PENDING, got %s", transfer.Status)
+	}
+
+	// Recipient cannot accept before the creator confirms
+	if err := CompleteMarketTransfer(ctx, transfer.ID, recipient.ID); err == nil {
+		t.Error("Expected error when accepting an unconfirmed transfer")
+	}
+
+	if err := UpdateMarketTransferStatus(ctx, transfer.ID, creator.ID, TransferStatusPending, TransferStatusConfirmed); err != nil {
+		t.Fatalf("UpdateMarketTransferStatus failed: %v", err)
+	}
+
+	// Only the recipient can accept
+	if err := CompleteMarketTransfer(ctx, transfer.ID, creator.ID); err == nil {
+		t.Error("Expected error when creator accepts their own transfer")
+	}
+
+	if err := CompleteMarketTransfer(ctx, transfer.ID, recipient.ID); err != nil {
+		t.Fatalf("CompleteMarketTransfer failed: %v", err)
+	}
+
+	updated, _ := GetMarketByID(market.ID)
+	if updated.CreatorID != recipient.ID {
+		t.Errorf("Expected creator %d, got %d", recipient.ID, updated.CreatorID)
+	}
+
+	completed, _ := GetMarketTransfer(transfer.ID)
+	if completed.Status != TransferStatusAccepted {
+		t.Errorf("Expected status ACCEPTED, got %s", completed.Status)
+	}
+
+	entries, err := GetAuditLog(AuditEntityMarket, market.ID)
+	if err != nil {
+		t.Fatalf("GetAuditLog failed: %v", err)
+	}
+	expectedActions := []string{"transfer_requested", "transfer_confirmed", "transfer_accepted"}
+	if len(entries) != len(expectedActions) {
+		t.Fatalf("Expected %d audit entries, got %d", len(expectedActions), len(entries))
+	}
+	for i, action := range expectedActions {
+		if entries[i].Action != action {
+			t.Errorf("Expected audit action %s at %d, got %s", action, i, entries[i].Action)
+		}
+	}
+}
+
+func TestCreateMarketTransferValidation(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(2001, "creator", "Creator")
+	other, _ := CreateUser(2002, "other", "Other")
+	market, _ := CreateMarket(creator.ID, "Will validation hold?", time.Now().Add(time.Hour))
+
+	if _, err := CreateMarketTransfer(ctx, market.ID, creator.ID, creator.ID); err == nil {
+		t.Error("Expected error when transferring to yourself")
+	}
+	if _, err := CreateMarketTransfer(ctx, market.ID, other.ID, creator.ID); err == nil {
+		t.Error("Expected error when non-creator requests a transfer")
+	}
+	if _, err := CreateMarketTransfer(ctx, 9999, creator.ID, other.ID); err == nil {
+		t.Error("Expected error for missing market")
+	}
+
+	if _, err := CreateMarketTransfer(ctx, market.ID, creator.ID, other.ID); err != nil {
+		t.Fatalf("CreateMarketTransfer failed: %v", err)
+	}
+	if _, err := CreateMarketTransfer(ctx, market.ID, creator.ID, other.ID); err == nil {
+		t.Error("Expected error when a transfer is already pending")
+	}
+
+	finalized, _ := CreateMarket(creator.ID, "Already finalized market?", time.Now().Add(time.Hour))
+	UpdateMarketStatus(finalized.ID, MarketStatusLocked, "")
+	UpdateMarketStatus(finalized.ID, MarketStatusResolved, "YES")
+	UpdateMarketStatus(finalized.ID, MarketStatusFinalized, "YES")
+	if _, err := CreateMarketTransfer(ctx, finalized.ID, creator.ID, other.ID); err == nil {
+		t.Error("Expected error when transferring a finalized market")
+	}
+}
+
+func TestGetUserByUsername(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	CreateUser(3001, "MixedCase", "Mixed")
+
+	user, err := GetUserByUsername("mixedcase")
+	if err != nil {
+		t.Fatalf("GetUserByUsername failed: %v", err)
+	}
+	if user == nil || user.TelegramID != 3001 {
+		t.Errorf("Expected user with telegram_id 3001, got %+v", user)
+	}
+
+	missing, err := GetUserByUsername("nobody")
+	if err != nil {
+		t.Fatalf("GetUserByUsername failed: %v", err)
+	}
+	if missing != nil {
+		t.Error("Expected nil for unknown username")
+	}
+}
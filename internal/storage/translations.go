@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Translation sources. Creator translations are never replaced by machine ones.
+const (
+	TranslationCreator = "creator"
+	TranslationMachine = "machine"
+)
+
+// MarketTranslation is a market question in another language
+type MarketTranslation struct {
+	Lang     string `json:"lang"`
+	Question string `json:"question"`
+	Source   string `json:"source"`
+}
+
+// SetMarketTranslation stores a translated question. A machine translation does not
+// overwrite one the creator provided.
+func SetMarketTranslation(marketID int64, lang, question, source string) error {
+	query := `
+		INSERT INTO market_translations (market_id, lang, question, source)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (market_id, lang) DO UPDATE SET
+			question = excluded.question,
+			source = excluded.source,
+			created_at = CURRENT_TIMESTAMP
+	`
+	if source == TranslationMachine {
+		query += ` WHERE market_translations.source = 'machine'`
+	}
+	if _, err := db.Exec(query, marketID, lang, question, source); err != nil {
+		return fmt.Errorf("failed to save translation: %w", err)
+	}
+	return nil
+}
+
+// GetMarketTranslation returns the question of a market in lang, or "" if there is none
+func GetMarketTranslation(marketID int64, lang string) (string, error) {
+	var question string
+	err := db.QueryRow(`SELECT question FROM market_translations WHERE market_id = ? AND lang = ?`, marketID, lang).Scan(&question)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get translation: %w", err)
+	}
+	return question, nil
+}
+
+// GetMarketTranslations returns every translation of a market, ordered by language
+func GetMarketTranslations(marketID int64) ([]MarketTranslation, error) {
+	rows, err := db.Query(`
+		SELECT lang, question, source FROM market_translations
+		WHERE market_id = ?
+		ORDER BY lang
+	`, marketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query translations: %w", err)
+	}
+	defer rows.Close()
+
+	translations := []MarketTranslation{}
+	for rows.Next() {
+		var t MarketTranslation
+		if err := rows.Scan(&t.Lang, &t.Question, &t.Source); err != nil {
+			return nil, fmt.Errorf("failed to scan translation: %w", err)
+		}
+		translations = append(translations, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating translations: %w", err)
+	}
+
+	return translations, nil
+}
+
+// GetTranslationsForMarkets returns the questions in lang for the markets that have one
+func GetTranslationsForMarkets(marketIDs []int64, lang string) (map[int64]string, error) {
+	translated := make(map[int64]string)
+	if len(marketIDs) == 0 {
+		return translated, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(marketIDs)), ", ")
+	args := make([]interface{}, 0, len(marketIDs)+1)
+	args = append(args, lang)
+	for _, id := range marketIDs {
+		args = append(args, id)
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT market_id, question FROM market_translations
+		WHERE lang = ? AND market_id IN (%s)
+	`, placeholders), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query translations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var marketID int64
+		var question string
+		if err := rows.Scan(&marketID, &question); err != nil {
+			return nil, fmt.Errorf("failed to scan translation: %w", err)
+		}
+		translated[marketID] = question
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating translations: %w", err)
+	}
+
+	return translated, nil
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarketTranslations(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := CreateUser(4001, "translator", "Translator")
+	expiresAt := time.Now().Add(24 * time.Hour)
+	first, _ := CreateMarket(user.ID, "Will it rain tomorrow?", expiresAt)
+	second, _ := CreateMarket(user.ID, "Will it snow tomorrow?", expiresAt)
+
+	if err := SetMarketTranslation(first.ID, "de", "Regnet es morgen?", TranslationMachine); err != nil {
+		t.Fatalf("SetMarketTranslation failed: %v", err)
+	}
+	// The creator's wording replaces the machine translation...
+	if err := SetMarketTranslation(first.ID, "de", "Wird es morgen regnen?", TranslationCreator); err != nil {
+		t.Fatalf("SetMarketTranslation (creator) failed: %v", err)
+	}
+	// ...and is not replaced by a later machine translation
+	if err := SetMarketTranslation(first.ID, "de", "Regnet es morgen?", TranslationMachine); err != nil {
+		t.Fatalf("SetMarketTranslation (machine) failed: %v", err)
+	}
+
+	question, err := GetMarketTranslation(first.ID, "de")
+	if err != nil || question != "Wird es morgen regnen?" {
+		t.Errorf("Expected creator translation, got %q (err=%v)", question, err)
+	}
+	if question, _ := GetMarketTranslation(first.ID, "fr"); question != "" {
+		t.Errorf("Expected no French translation, got %q", question)
+	}
+
+	SetMarketTranslation(first.ID, "fr", "Pleuvra-t-il demain ?", TranslationMachine)
+	translations, err := GetMarketTranslations(first.ID)
+	if err != nil {
+		t.Fatalf("GetMarketTranslations failed: %v", err)
+	}
+	if len(translations) != 2 || translations[0].Lang != "de" || translations[0].Source != TranslationCreator || translations[1].Source != TranslationMachine {
+		t.Errorf("Unexpected translations: %+v", translations)
+	}
+
+	byMarket, err := GetTranslationsForMarkets([]int64{first.ID, second.ID}, "de")
+	if err != nil {
+		t.Fatalf("GetTranslationsForMarkets failed: %v", err)
+	}
+	if len(byMarket) != 1 || byMarket[first.ID] != "Wird es morgen regnen?" {
+		t.Errorf("Unexpected translations by market: %v", byMarket)
+	}
+}
+
+func TestUserLanguage(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := CreateUser(4001, "polyglot", "Polyglot")
+	if lang, err := GetUserLanguage(user.ID); err != nil || lang != "" {
+		t.Errorf("Expected no language by default, got %q (err=%v)", lang, err)
+	}
+	if err := SetUserLanguage(user.ID, "es"); err != nil {
+		t.Fatalf("SetUserLanguage failed: %v", err)
+	}
+	prefs, err := GetUserPreferences(user.ID)
+	if err != nil || prefs.Language != "es" {
+		t.Errorf("Expected language es in preferences, got %+v (err=%v)", prefs, err)
+	}
+	if err := SetUserLanguage(999, "es"); err == nil {
+		t.Error("Expected error for unknown user")
+	}
+}
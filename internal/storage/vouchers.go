@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// VoucherKind is what a voucher does for the bet it backs
+type VoucherKind string
+
+const (
+	// VoucherInsurance pays the stake, up to the voucher's maximum, back if the bet loses
+	VoucherInsurance VoucherKind = "INSURANCE"
+	// VoucherCredit pays the stake, up to the voucher's maximum, instead of the user's balance
+	VoucherCredit VoucherKind = "CREDIT"
+)
+
+// ParseVoucherKind parses a voucher kind case-insensitively
+func ParseVoucherKind(value string) (VoucherKind, error) {
+	switch kind := VoucherKind(strings.ToUpper(strings.TrimSpace(value))); kind {
+	case VoucherInsurance, VoucherCredit:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("invalid voucher kind: %s", value)
+	}
+}
+
+// Voucher backs one bet of its user. It is redeemed by the user's next bet after it was granted,
+// unless it expired first.
+type Voucher struct {
+	ID        int64       `json:"id"`
+	UserID    int64       `json:"-"`
+	Kind      VoucherKind `json:"kind"`
+	MaxAmount int64       `json:"max_amount"`
+	// ExpiresAt is when the voucher can no longer be redeemed, nil if never
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	// BetID is the bet that redeemed the voucher, 0 while it is available
+	BetID int64 `json:"bet_id,omitempty"`
+	// Covered is how much of that bet's stake the voucher backs
+	Covered int64 `json:"covered,omitempty"`
+}
+
+const voucherColumns = `id, user_id, kind, max_amount, expires_at, created_at, COALESCE(bet_id, 0), covered`
+
+func scanVoucher(row interface{ Scan(...interface{}) error }) (*Voucher, error) {
+	var v Voucher
+	var expiresAt sql.NullTime
+	if err := row.Scan(&v.ID, &v.UserID, &v.Kind, &v.MaxAmount, &expiresAt, &v.CreatedAt, &v.BetID, &v.Covered); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		v.ExpiresAt = &expiresAt.Time
+	}
+	return &v, nil
+}
+
+// GrantVoucher gives a user (internal ID) a voucher covering up to maxAmount of their next bet.
+// A zero expiresAt never expires. Vouchers granted by an admin (grantedBy, internal ID) are
+// audited; 0 is the bot itself.
+func GrantVoucher(userID int64, kind VoucherKind, maxAmount int64, expiresAt time.Time, grantedBy int64) (*Voucher, error) {
+	if kind != VoucherInsurance && kind != VoucherCredit {
+		return nil, fmt.Errorf("invalid voucher kind: %s", kind)
+	}
+	if maxAmount <= 0 {
+		return nil, fmt.Errorf("invalid voucher amount: must be greater than 0")
+	}
+	var expires interface{}
+	if !expiresAt.IsZero() {
+		expires = expiresAt
+	}
+
+	var id int64
+	err := db.QueryRow(`
+		INSERT INTO vouchers (user_id, kind, max_amount, expires_at) VALUES (?, ?, ?, ?) RETURNING id
+	`, userID, kind, maxAmount, expires).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to grant voucher: %w", err)
+	}
+	v, err := scanVoucher(db.QueryRow(`SELECT `+voucherColumns+` FROM vouchers WHERE id = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get voucher: %w", err)
+	}
+	if grantedBy != 0 {
+		details := fmt.Sprintf("voucher_id=%d kind=%s max_amount=%d", v.ID, v.Kind, v.MaxAmount)
+		if err := LogAudit(grantedBy, "voucher_granted", AuditEntityUser, userID, details); err != nil {
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+// availableVouchersSQL selects a user's unredeemed vouchers, the one the next bet redeems first
+const availableVouchersSQL = `
+	SELECT ` + voucherColumns + ` FROM vouchers
+	WHERE user_id = ? AND bet_id IS NULL
+	ORDER BY expires_at IS NULL, expires_at, id`
+
+// ListAvailableVouchers returns a user's (internal ID) vouchers that are neither redeemed nor
+// expired, the one the next bet redeems first
+func ListAvailableVouchers(userID int64) ([]Voucher, error) {
+	rows, err := db.Query(availableVouchersSQL, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vouchers: %w", err)
+	}
+	return scanAvailableVouchers(rows)
+}
+
+// nextVoucherTx returns the voucher a user's next bet redeems, nil if there is none
+func nextVoucherTx(ctx context.Context, tx *sql.Tx, userID int64) (*Voucher, error) {
+	rows, err := tx.QueryContext(ctx, availableVouchersSQL, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vouchers: %w", err)
+	}
+	vouchers, err := scanAvailableVouchers(rows)
+	if err != nil || len(vouchers) == 0 {
+		return nil, err
+	}
+	return &vouchers[0], nil
+}
+
+// scanAvailableVouchers reads and closes rows of availableVouchersSQL, leaving out expired
+// vouchers. Expiry is compared in Go, like the markets' expires_at.
+func scanAvailableVouchers(rows *sql.Rows) ([]Voucher, error) {
+	defer rows.Close()
+
+	now := time.Now()
+	var vouchers []Voucher
+	for rows.Next() {
+		v, err := scanVoucher(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan voucher: %w", err)
+		}
+		if v.ExpiresAt == nil || now.Before(*v.ExpiresAt) {
+			vouchers = append(vouchers, *v)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating vouchers: %w", err)
+	}
+	return vouchers, nil
+}
+
+// redeemVoucherTx ties a voucher to the bet that redeemed it, backing covered of its stake
+func redeemVoucherTx(ctx context.Context, tx *sql.Tx, voucherID, betID, covered int64) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE vouchers SET bet_id = ?, covered = ?, redeemed_at = CURRENT_TIMESTAMP WHERE id = ?
+	`, betID, covered, voucherID)
+	if err != nil {
+		return fmt.Errorf("failed to redeem voucher: %w", err)
+	}
+	return nil
+}
+
+// RefundVoucherBetTx settles the voucher of a lost bet inside a transaction: first-bet insurance
+// pays the covered stake back to the bettor. It returns the refund, 0 when the bet had no
+// insurance.
+func RefundVoucherBetTx(ctx context.Context, tx *sql.Tx, betID int64) (int64, error) {
+	var id, userID, covered int64
+	var kind VoucherKind
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, user_id, kind, covered FROM vouchers WHERE bet_id = ? AND settled_at IS NULL
+	`, betID).Scan(&id, &userID, &kind, &covered)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get voucher: %w", err)
+	}
+
+	refund := int64(0)
+	if kind == VoucherInsurance {
+		refund = covered
+	}
+	if refund > 0 {
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET balance = balance + ? WHERE id = ?`, refund, userID); err != nil {
+			return 0, fmt.Errorf("failed to refund voucher: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO transactions (user_id, amount, source_type, description, market_id)
+			VALUES (?, ?, 'VOUCHER_REFUND', ?, (SELECT market_id FROM bets WHERE id = ?))
+		`, userID, refund, fmt.Sprintf("Voucher #%d refund for lost bet #%d", id, betID), betID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to log voucher refund: %w", err)
+		}
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE vouchers SET refunded = ?, settled_at = CURRENT_TIMESTAMP WHERE id = ?
+	`, refund, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to settle voucher: %w", err)
+	}
+	return refund, nil
+}
+
+// ReleaseVoucherTx frees the voucher of a bet that is being refunded or cancelled, inside a
+// transaction, so the user can redeem it again. It returns how much of the stake a promo credit
+// paid, which is not the user's to get back.
+func ReleaseVoucherTx(ctx context.Context, tx *sql.Tx, betID int64) (int64, error) {
+	var id, covered int64
+	var kind VoucherKind
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, kind, covered FROM vouchers WHERE bet_id = ? AND settled_at IS NULL
+	`, betID).Scan(&id, &kind, &covered)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get voucher: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE vouchers SET bet_id = NULL, covered = 0, redeemed_at = NULL WHERE id = ?
+	`, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release voucher: %w", err)
+	}
+	if kind == VoucherCredit {
+		return covered, nil
+	}
+	return 0, nil
+}
+
+// SettleMarketVouchersTx marks the vouchers of a finalized market's remaining bets as used,
+// inside a transaction
+func SettleMarketVouchersTx(ctx context.Context, tx *sql.Tx, marketID int64) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE vouchers SET settled_at = CURRENT_TIMESTAMP
+		WHERE settled_at IS NULL AND bet_id IN (SELECT id FROM bets WHERE market_id = ?)
+	`, marketID)
+	if err != nil {
+		return fmt.Errorf("failed to settle vouchers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVoucherRedemption(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	user, _ := CreateUser(4001, "lucky", "Lucky")
+	market, _ := CreateMarket(user.ID, "Will the voucher pay for this?", time.Now().Add(time.Hour))
+
+	if _, err := GrantVoucher(user.ID, "GIFT", 10, time.Time{}, 0); err == nil {
+		t.Error("Expected an unknown kind to be refused")
+	}
+	GrantVoucher(user.ID, VoucherCredit, 500, time.Now().Add(-time.Minute), 0)
+	credit, _ := GrantVoucher(user.ID, VoucherCredit, 50, time.Time{}, 0)
+	insurance, _ := GrantVoucher(user.ID, VoucherInsurance, 100, time.Now().Add(time.Hour), 0)
+
+	// The voucher expiring first is redeemed first, expired ones never
+	vouchers, _ := ListAvailableVouchers(user.ID)
+	if len(vouchers) != 2 || vouchers[0].ID != insurance.ID || vouchers[1].ID != credit.ID {
+		t.Fatalf("Expected the insurance then the credit, got %+v", vouchers)
+	}
+
+	// Insurance backs the stake but doesn't pay it
+	PlaceBet(ctx, user.ID, market.ID, "YES", 150)
+	u, _ := GetUserByID(user.ID)
+	if u.Balance != WelcomeBonusAmount-150 {
+		t.Errorf("Expected the full stake to be paid, got balance %d", u.Balance)
+	}
+
+	// A promo credit pays its share of the stake, even beyond the balance
+	if err := PlaceBet(ctx, user.ID, market.ID, "NO", u.Balance+50); err != nil {
+		t.Fatalf("Expected the credit to cover the shortfall, got %v", err)
+	}
+	u, _ = GetUserByID(user.ID)
+	if u.Balance != 0 {
+		t.Errorf("Expected the rest of the balance to be paid, got %d", u.Balance)
+	}
+
+	var covered []int64
+	rows, _ := db.Query(`SELECT covered FROM vouchers WHERE bet_id IS NOT NULL ORDER BY id`)
+	for rows.Next() {
+		var c int64
+		rows.Scan(&c)
+		covered = append(covered, c)
+	}
+	rows.Close()
+	if len(covered) != 2 || covered[0] != 50 || covered[1] != 100 {
+		t.Errorf("Expected the credit to cover 50 and the insurance 100, got %v", covered)
+	}
+	if vouchers, _ := ListAvailableVouchers(user.ID); len(vouchers) != 0 {
+		t.Errorf("Expected every voucher to be used, got %+v", vouchers)
+	}
+}
+
+func TestCancelBetReleasesVoucher(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	user, _ := CreateUser(4001, "fickle", "Fickle")
+	market, _ := CreateMarket(user.ID, "Will I keep the promo bet?", time.Now().Add(time.Hour))
+	GrantVoucher(user.ID, VoucherCredit, 30, time.Time{}, 0)
+
+	PlaceBet(ctx, user.ID, market.ID, "YES", 100)
+	bets, _ := GetUserBets(user.ID)
+	cancellation, err := CancelBet(ctx, user.ID, bets[0].ID)
+	if err != nil {
+		t.Fatalf("CancelBet failed: %v", err)
+	}
+
+	// Only the 70 the user paid comes back, and the credit can be used again
+	if cancellation.Refund != 70 {
+		t.Errorf("Expected 70 back, got %+v", cancellation)
+	}
+	u, _ := GetUserByID(user.ID)
+	if u.Balance != WelcomeBonusAmount {
+		t.Errorf("Expected the balance to be whole again, got %d", u.Balance)
+	}
+	if vouchers, _ := ListAvailableVouchers(user.ID); len(vouchers) != 1 || vouchers[0].BetID != 0 {
+		t.Errorf("Expected the voucher to be available again, got %+v", vouchers)
+	}
+
+	if err := PlaceBet(ctx, user.ID, market.ID, "NO", 5000); err == nil || !strings.Contains(err.Error(), "insufficient funds") {
+		t.Errorf("Expected the credit not to cover more than its maximum, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// WeatherCondition is what a weather market asks about
+type WeatherCondition string
+
+const (
+	// WeatherRain asks whether it rains at all
+	WeatherRain WeatherCondition = "rain"
+	// WeatherSnow asks whether it snows at all
+	WeatherSnow WeatherCondition = "snow"
+	// WeatherAbove asks whether the day's high goes above the threshold (°C)
+	WeatherAbove WeatherCondition = "above"
+	// WeatherBelow asks whether the day's low drops below the threshold (°C)
+	WeatherBelow WeatherCondition = "below"
+)
+
+// Valid reports whether c is a known condition
+func (c WeatherCondition) Valid() bool {
+	switch c {
+	case WeatherRain, WeatherSnow, WeatherAbove, WeatherBelow:
+		return true
+	}
+	return false
+}
+
+// WeatherStatus is where the weather oracle is with a market
+type WeatherStatus string
+
+const (
+	// WeatherPending waits for the day to be over
+	WeatherPending WeatherStatus = "PENDING"
+	// WeatherResolved had the oracle's observation recorded
+	WeatherResolved WeatherStatus = "RESOLVED"
+)
+
+// WeatherMarket is the weather question behind a market
+type WeatherMarket struct {
+	MarketID  int64   `json:"market_id"`
+	Place     string  `json:"place"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Timezone is the place's IANA timezone; Day is a calendar day there, as YYYY-MM-DD
+	Timezone  string           `json:"timezone"`
+	Day       string           `json:"day"`
+	Condition WeatherCondition `json:"condition"`
+	Threshold float64          `json:"threshold,omitempty"`
+	// ResolveAfter is when the day's observations are expected to be complete
+	ResolveAfter time.Time     `json:"resolve_after"`
+	Status       WeatherStatus `json:"status"`
+	// Observed is the value the oracle resolved with: rain in mm, snow in cm or a temperature in °C
+	Observed *float64 `json:"observed,omitempty"`
+}
+
+const weatherColumns = `market_id, place, latitude, longitude, timezone, day, condition, threshold, resolve_after, status, observed`
+
+func scanWeatherMarket(row interface{ Scan(...interface{}) error }) (*WeatherMarket, error) {
+	var w WeatherMarket
+	var observed sql.NullFloat64
+	err := row.Scan(&w.MarketID, &w.Place, &w.Latitude, &w.Longitude, &w.Timezone, &w.Day, &w.Condition,
+		&w.Threshold, &w.ResolveAfter, &w.Status, &observed)
+	if err != nil {
+		return nil, err
+	}
+	if observed.Valid {
+		w.Observed = &observed.Float64
+	}
+	return &w, nil
+}
+
+// CreateWeatherMarket records the weather question of a market
+func CreateWeatherMarket(w WeatherMarket) error {
+	_, err := db.Exec(`
+		INSERT INTO weather_markets (market_id, place, latitude, longitude, timezone, day, condition, threshold, resolve_after)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, w.MarketID, w.Place, w.Latitude, w.Longitude, w.Timezone, w.Day, w.Condition, w.Threshold,
+		sqlTimestamp(w.ResolveAfter))
+	if err != nil {
+		return fmt.Errorf("failed to create weather market: %w", err)
+	}
+	return nil
+}
+
+// GetWeatherMarket returns the weather question of a market, or nil if it has none
+func GetWeatherMarket(marketID int64) (*WeatherMarket, error) {
+	w, err := scanWeatherMarket(db.QueryRow(`SELECT `+weatherColumns+` FROM weather_markets WHERE market_id = ?`, marketID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weather market: %w", err)
+	}
+	return w, nil
+}
+
+// ListDueWeatherMarkets returns the pending weather markets whose day is over at now
+func ListDueWeatherMarkets(now time.Time) ([]WeatherMarket, error) {
+	rows, err := db.Query(`
+		SELECT `+weatherColumns+`
+		FROM weather_markets
+		WHERE status = ? AND resolve_after <= ?
+		ORDER BY resolve_after, market_id
+	`, WeatherPending, sqlTimestamp(now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due weather markets: %w", err)
+	}
+	defer rows.Close()
+
+	var markets []WeatherMarket
+	for rows.Next() {
+		w, err := scanWeatherMarket(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan weather market: %w", err)
+		}
+		markets = append(markets, *w)
+	}
+	return markets, rows.Err()
+}
+
+// ResolveWeatherMarket records what the oracle observed for a pending weather market
+func ResolveWeatherMarket(marketID int64, observed float64) error {
+	result, err := db.Exec(`
+		UPDATE weather_markets
+		SET status = ?, observed = ?, resolved_at = CURRENT_TIMESTAMP
+		WHERE market_id = ? AND status = ?
+	`, WeatherResolved, observed, marketID, WeatherPending)
+	if err != nil {
+		return fmt.Errorf("failed to resolve weather market: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("weather market not pending")
+	}
+	return nil
+}
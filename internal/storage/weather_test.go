@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWeatherMarkets(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator, _ := CreateUser(4901, "creator", "Creator")
+	day := time.Date(2026, 5, 9, 0, 0, 0, 0, time.UTC)
+	market, _ := CreateMarket(creator.ID, "Will it rain in Berlin on Sat May 9?", day)
+	later, _ := CreateMarket(creator.ID, "Will it snow in Oslo on Sun May 10?", day.AddDate(0, 0, 1))
+
+	for _, w := range []WeatherMarket{
+		{MarketID: market.ID, Place: "Berlin", Latitude: 52.52, Longitude: 13.41, Timezone: "Europe/Berlin", Day: "2026-05-09", Condition: WeatherRain, ResolveAfter: day.Add(30 * time.Hour)},
+		{MarketID: later.ID, Place: "Oslo", Latitude: 59.91, Longitude: 10.75, Timezone: "Europe/Oslo", Day: "2026-05-10", Condition: WeatherSnow, ResolveAfter: day.Add(54 * time.Hour)},
+	} {
+		if err := CreateWeatherMarket(w); err != nil {
+			t.Fatalf("CreateWeatherMarket failed: %v", err)
+		}
+	}
+
+	if due, _ := ListDueWeatherMarkets(day.Add(29 * time.Hour)); len(due) != 0 {
+		t.Errorf("Expected nothing due before the day is over, got %+v", due)
+	}
+	due, err := ListDueWeatherMarkets(day.Add(30 * time.Hour))
+	if err != nil || len(due) != 1 || due[0].MarketID != market.ID || due[0].Status != WeatherPending || due[0].Timezone != "Europe/Berlin" {
+		t.Fatalf("Expected the Berlin market due, got %+v, %v", due, err)
+	}
+
+	if err := ResolveWeatherMarket(market.ID, 3.4); err != nil {
+		t.Fatalf("ResolveWeatherMarket failed: %v", err)
+	}
+	if err := ResolveWeatherMarket(market.ID, 3.4); err == nil {
+		t.Error("Expected a resolved weather market not to be resolved again")
+	}
+	w, _ := GetWeatherMarket(market.ID)
+	if w.Status != WeatherResolved || w.Observed == nil || *w.Observed != 3.4 {
+		t.Errorf("Unexpected resolved weather market %+v", w)
+	}
+	if due, _ := ListDueWeatherMarkets(day.Add(60 * time.Hour)); len(due) != 1 || due[0].MarketID != later.ID {
+		t.Errorf("Expected only the pending market due, got %+v", due)
+	}
+	if w, err := GetWeatherMarket(999); w != nil || err != nil {
+		t.Errorf("Expected no weather market, got %+v, %v", w, err)
+	}
+}
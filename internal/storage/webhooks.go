@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MarketWebhook is a creator's callback URL for a market, posted to when the market locks
+type MarketWebhook struct {
+	MarketID int64  `json:"market_id"`
+	URL      string `json:"url"`
+	// Secret signs the deliveries; it is only shown when the webhook is registered
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// LastAttemptAt, LastStatus and LastError describe the latest delivery attempt, if any.
+	// LastStatus is the HTTP status, 0 when no answer came back.
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	LastStatus    int        `json:"last_status,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+// SetMarketWebhook registers the callback URL and signing secret of a market, replacing any
+// earlier one along with its delivery history
+func SetMarketWebhook(marketID int64, url, secret string) error {
+	_, err := db.Exec(`
+		INSERT INTO market_webhooks (market_id, url, secret)
+		VALUES (?, ?, ?)
+		ON CONFLICT(market_id) DO UPDATE SET url = excluded.url, secret = excluded.secret,
+			created_at = CURRENT_TIMESTAMP, last_attempt_at = NULL, last_status = NULL, last_error = NULL
+	`, marketID, url, secret)
+	if err != nil {
+		return fmt.Errorf("failed to set market webhook: %w", err)
+	}
+	return nil
+}
+
+// GetMarketWebhook returns a market's webhook, secret included, or nil if it has none
+func GetMarketWebhook(marketID int64) (*MarketWebhook, error) {
+	var hook MarketWebhook
+	var lastAttemptAt sql.NullTime
+	var lastStatus sql.NullInt64
+	var lastError sql.NullString
+	err := db.QueryRow(`
+		SELECT market_id, url, secret, created_at, last_attempt_at, last_status, last_error
+		FROM market_webhooks WHERE market_id = ?
+	`, marketID).Scan(&hook.MarketID, &hook.URL, &hook.Secret, &hook.CreatedAt, &lastAttemptAt, &lastStatus, &lastError)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market webhook: %w", err)
+	}
+	if lastAttemptAt.Valid {
+		hook.LastAttemptAt = &lastAttemptAt.Time
+	}
+	hook.LastStatus = int(lastStatus.Int64)
+	hook.LastError = lastError.String
+	return &hook, nil
+}
+
+// DeleteMarketWebhook removes a market's webhook and reports whether it had one
+func DeleteMarketWebhook(marketID int64) (bool, error) {
+	result, err := db.Exec(`DELETE FROM market_webhooks WHERE market_id = ?`, marketID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete market webhook: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+	return deleted > 0, nil
+}
+
+// RecordWebhookDelivery stores the outcome of a delivery attempt: the HTTP status (0 for none)
+// and the error, "" when the delivery succeeded
+func RecordWebhookDelivery(marketID int64, status int, errMsg string) error {
+	_, err := db.Exec(`
+		UPDATE market_webhooks SET last_attempt_at = CURRENT_TIMESTAMP, last_status = ?, last_error = ?
+		WHERE market_id = ?
+	`, status, errMsg, marketID)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarketWebhooks(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	user, _ := CreateUser(4701, "scripter", "Scripter")
+	market, _ := CreateMarket(user.ID, "Will the script resolve this?", time.Now().Add(time.Hour))
+
+	if hook, err := GetMarketWebhook(market.ID); err != nil || hook != nil {
+		t.Fatalf("Expected no webhook, got %+v, %v", hook, err)
+	}
+
+	SetMarketWebhook(market.ID, "https://example.com/old", "s1")
+	RecordWebhookDelivery(market.ID, 500, "unexpected status 500")
+	hook, _ := GetMarketWebhook(market.ID)
+	if hook == nil || hook.LastStatus != 500 || hook.LastError == "" || hook.LastAttemptAt == nil {
+		t.Fatalf("Expected the failed delivery to be recorded, got %+v", hook)
+	}
+
+	// Registering again replaces the URL, the secret and the delivery history
+	SetMarketWebhook(market.ID, "https://example.com/new", "s2")
+	hook, _ = GetMarketWebhook(market.ID)
+	if hook.URL != "https://example.com/new" || hook.Secret != "s2" || hook.LastStatus != 0 || hook.LastAttemptAt != nil {
+		t.Errorf("Expected a fresh webhook, got %+v", hook)
+	}
+
+	if deleted, _ := DeleteMarketWebhook(market.ID); !deleted {
+		t.Error("Expected the webhook to be deleted")
+	}
+	if deleted, _ := DeleteMarketWebhook(market.ID); deleted {
+		t.Error("Expected nothing left to delete")
+	}
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// MarketWinner is one winning bettor of a finalized market.
+// Name is only filled in for users who opted in with SetShowInWinners.
+type MarketWinner struct {
+	Rank      int    `json:"rank"`
+	Name      string `json:"name,omitempty"`
+	BetAmount int64  `json:"bet_amount"`
+	Payout    int64  `json:"payout"`
+	Profit    int64  `json:"profit"`
+}
+
+// GetTopWinners returns the biggest winners of a finalized market, largest payout first.
+// Payouts are recomputed from the bets with the same parimutuel formula used at finalization.
+// Markets that are not finalized, or where everyone was refunded, have no winners.
+func GetTopWinners(marketID int64, limit int) ([]MarketWinner, error) {
+	var status string
+	var outcome sql.NullString
+	err := db.QueryRow(`SELECT status, outcome FROM markets WHERE id = ?`, marketID).Scan(&status, &outcome)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+	if MarketStatus(status) != MarketStatusFinalized || !outcome.Valid {
+		return []MarketWinner{}, nil
+	}
+
+	rows, err := db.Query(`
+		SELECT b.user_id, b.outcome, b.amount, u.first_name, u.show_in_winners
+		FROM bets b
+		JOIN users u ON u.id = b.user_id
+		WHERE b.market_id = ?
+		ORDER BY b.id
+	`, marketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bets: %w", err)
+	}
+	defer rows.Close()
+
+	type winnerTotals struct {
+		winner MarketWinner
+		userID int64
+	}
+	byUser := make(map[int64]*winnerTotals)
+	var winningBets []struct{ userID, amount int64 }
+	totalPool, winningPool := int64(0), int64(0)
+	for rows.Next() {
+		var userID, amount int64
+		var betOutcome, firstName string
+		var showName bool
+		if err := rows.Scan(&userID, &betOutcome, &amount, &firstName, &showName); err != nil {
+			return nil, fmt.Errorf("failed to scan bet: %w", err)
+		}
+		totalPool += amount
+		if betOutcome != outcome.String {
+			continue
+		}
+		winningPool += amount
+		winningBets = append(winningBets, struct{ userID, amount int64 }{userID, amount})
+		if byUser[userID] == nil {
+			byUser[userID] = &winnerTotals{userID: userID}
+			if showName {
+				byUser[userID].winner.Name = firstName
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bets: %w", err)
+	}
+
+	// Payouts are computed per bet, as FinalizeMarket does, so rounding matches what was paid
+	for _, b := range winningBets {
+		totals := byUser[b.userID]
+		totals.winner.BetAmount += b.amount
+		totals.winner.Payout += (b.amount * totalPool) / winningPool
+	}
+
+	winners := make([]*winnerTotals, 0, len(byUser))
+	for _, totals := range byUser {
+		totals.winner.Profit = totals.winner.Payout - totals.winner.BetAmount
+		winners = append(winners, totals)
+	}
+	sort.Slice(winners, func(i, j int) bool {
+		if winners[i].winner.Payout != winners[j].winner.Payout {
+			return winners[i].winner.Payout > winners[j].winner.Payout
+		}
+		return winners[i].userID < winners[j].userID
+	})
+
+	if limit > 0 && len(winners) > limit {
+		winners = winners[:limit]
+	}
+	result := make([]MarketWinner, len(winners))
+	for i, totals := range winners {
+		result[i] = totals.winner
+		result[i].Rank = i + 1
+	}
+	return result, nil
+}
+
+// SetMarketChannelMessage remembers the channel post that announced a market, so later posts can link to its comments
+func SetMarketChannelMessage(marketID int64, messageID int) error {
+	if _, err := db.Exec(`UPDATE markets SET channel_message_id = ? WHERE id = ?`, messageID, marketID); err != nil {
+		return fmt.Errorf("failed to save channel message: %w", err)
+	}
+	return nil
+}
+
+// GetMarketChannelMessage returns the ID of the channel post announcing a market, or 0 if it was never posted
+func GetMarketChannelMessage(marketID int64) (int, error) {
+	var messageID sql.NullInt64
+	err := db.QueryRow(`SELECT channel_message_id FROM markets WHERE id = ?`, marketID).Scan(&messageID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get channel message: %w", err)
+	}
+	return int(messageID.Int64), nil
+}
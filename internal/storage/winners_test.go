@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetTopWinners(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	ctx := context.Background()
+	creator, _ := CreateUser(4001, "creator", "Creator")
+	ann, _ := CreateUser(4002, "ann", "Ann")
+	bob, _ := CreateUser(4003, "bob", "Bob")
+	carl, _ := CreateUser(4004, "carl", "Carl")
+	market, _ := CreateMarket(creator.ID, "Will it rain tomorrow?", time.Now().Add(time.Hour))
+
+	PlaceBet(ctx, ann.ID, market.ID, "YES", 100)
+	PlaceBet(ctx, ann.ID, market.ID, "YES", 50)
+	PlaceBet(ctx, bob.ID, market.ID, "YES", 50)
+	PlaceBet(ctx, carl.ID, market.ID, "NO", 200)
+
+	// Not finalized yet: no winners
+	if winners, err := GetTopWinners(market.ID, 10); err != nil || len(winners) != 0 {
+		t.Fatalf("Expected no winners before finalization, got %+v (err=%v)", winners, err)
+	}
+
+	if err := SetShowInWinners(ann.ID, true); err != nil {
+		t.Fatalf("SetShowInWinners failed: %v", err)
+	}
+	UpdateMarketStatus(market.ID, MarketStatusLocked, "")
+	UpdateMarketStatus(market.ID, MarketStatusResolved, "YES")
+	UpdateMarketStatus(market.ID, MarketStatusFinalized, "YES")
+
+	winners, err := GetTopWinners(market.ID, 10)
+	if err != nil {
+		t.Fatalf("GetTopWinners failed: %v", err)
+	}
+	if len(winners) != 2 {
+		t.Fatalf("Expected 2 winners, got %+v", winners)
+	}
+	// Pool 400, winning pool 200: every winning WSC pays 2
+	if winners[0].Rank != 1 || winners[0].Name != "Ann" || winners[0].BetAmount != 150 || winners[0].Payout != 300 || winners[0].Profit != 150 {
+		t.Errorf("Unexpected first winner: %+v", winners[0])
+	}
+	if winners[1].Name != "" || winners[1].Payout != 100 {
+		t.Errorf("Expected bob to stay anonymous with payout 100, got %+v", winners[1])
+	}
+
+	if winners, _ := GetTopWinners(market.ID, 1); len(winners) != 1 {
+		t.Errorf("Expected limit to apply, got %d winners", len(winners))
+	}
+	if winners, err := GetTopWinners(9999, 10); err != nil || winners != nil {
+		t.Errorf("Expected nil for missing market, got %+v (err=%v)", winners, err)
+	}
+}
+
+func TestMarketChannelMessage(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB(t)
+
+	creator, _ := CreateUser(4001, "creator", "Creator")
+	market, _ := CreateMarket(creator.ID, "Will it rain tomorrow?", time.Now().Add(time.Hour))
+
+	if id, err := GetMarketChannelMessage(market.ID); err != nil || id != 0 {
+		t.Fatalf("Expected no channel message yet, got %d (err=%v)", id, err)
+	}
+	if err := SetMarketChannelMessage(market.ID, 77); err != nil {
+		t.Fatalf("SetMarketChannelMessage failed: %v", err)
+	}
+	if id, _ := GetMarketChannelMessage(market.ID); id != 77 {
+		t.Errorf("Expected message 77, got %d", id)
+	}
+}
@@ -0,0 +1,178 @@
+// Package validation checks request structs against the constraints declared in their
+// `validate` struct tags, e.g.
+//
+//	Outcome string `json:"outcome" validate:"required,oneof=YES NO"`
+//
+// Rules are separated by commas:
+//
+//	required   the field is not its zero value
+//	omitempty  the other rules are skipped when the field is its zero value
+//	min=N      numbers are at least N, strings have at least N characters
+//	max=N      numbers are at most N, strings have at most N characters
+//	oneof=A B  the value is one of the space-separated options
+//
+// Fields are named by their json tag, so errors point at the request's own keys.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError is a field that broke one of its rules
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// Errors lists every invalid field of a request, in field order
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Error()
+	}
+	return "invalid request: " + strings.Join(messages, "; ")
+}
+
+// Struct checks the validate tags of a struct or pointer to a struct. It returns Errors when a
+// field is invalid and nil otherwise. A malformed tag is a programming error and panics.
+func Struct(v interface{}) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validation: %T is not a struct", v))
+	}
+
+	var errs Errors
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag, ok := field.Tag.Lookup("validate")
+		if !ok || tag == "" || field.PkgPath != "" {
+			continue
+		}
+		if fieldErr := checkField(jsonName(field), value.Field(i), tag); fieldErr != nil {
+			errs = append(errs, *fieldErr)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// jsonName returns the key a field is decoded from
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// checkField applies a field's rules in order and returns the first one it breaks
+func checkField(name string, value reflect.Value, tag string) *FieldError {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			break
+		}
+		value = value.Elem()
+	}
+	zero := value.IsZero()
+
+	for _, rule := range strings.Split(tag, ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		var message string
+		switch rule {
+		case "omitempty":
+			if zero {
+				return nil
+			}
+		case "required":
+			if zero {
+				message = "is required"
+			}
+		case "min", "max":
+			message = checkBound(name, value, rule, param)
+		case "oneof":
+			options := strings.Fields(param)
+			if !isOneOf(value, options) {
+				message = "must be one of " + strings.Join(options, ", ")
+			}
+		default:
+			panic(fmt.Sprintf("validation: unknown rule %q on %s", rule, name))
+		}
+		if message != "" {
+			return &FieldError{Field: name, Rule: rule, Message: message}
+		}
+	}
+	return nil
+}
+
+// checkBound applies min or max to a number or a string's length in characters
+func checkBound(name string, value reflect.Value, rule, param string) string {
+	bound, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: %s=%q on %s is not a number", rule, param, name))
+	}
+
+	var n float64
+	unit := ""
+	switch value.Kind() {
+	case reflect.String:
+		n = float64(utf8.RuneCountInString(value.String()))
+		unit = " characters"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = value.Float()
+	default:
+		panic(fmt.Sprintf("validation: %s does not apply to %s (%s)", rule, name, value.Kind()))
+	}
+
+	if rule == "min" && n < bound {
+		return fmt.Sprintf("must be at least %s%s", param, unit)
+	}
+	if rule == "max" && n > bound {
+		return fmt.Sprintf("must be at most %s%s", param, unit)
+	}
+	return ""
+}
+
+// isOneOf compares a string or number with the options of oneof
+func isOneOf(value reflect.Value, options []string) bool {
+	var s string
+	switch value.Kind() {
+	case reflect.String:
+		s = value.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = strconv.FormatUint(value.Uint(), 10)
+	default:
+		panic(fmt.Sprintf("validation: oneof does not apply to %s", value.Kind()))
+	}
+	for _, option := range options {
+		if s == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type betRequest struct {
+	MarketID int64  `json:"market_id" validate:"required,min=1"`
+	Outcome  string `json:"outcome" validate:"required,oneof=YES NO"`
+	Amount   int64  `json:"amount" validate:"omitempty,min=1,max=1000"`
+	Note     string `json:"note,omitempty" validate:"max=5"`
+	Hidden   string
+}
+
+func TestStruct(t *testing.T) {
+	if err := Struct(&betRequest{MarketID: 1, Outcome: "YES"}); err != nil {
+		t.Errorf("Expected a valid request, got %v", err)
+	}
+	if err := Struct(betRequest{MarketID: 1, Outcome: "NO", Amount: 1000, Note: "héllo"}); err != nil {
+		t.Errorf("Expected bounds to be inclusive and to count characters, got %v", err)
+	}
+
+	err := Struct(&betRequest{Outcome: "MAYBE", Amount: -5, Note: "too long"})
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected Errors, got %v", err)
+	}
+	want := []FieldError{
+		{Field: "market_id", Rule: "required", Message: "is required"},
+		{Field: "outcome", Rule: "oneof", Message: "must be one of YES, NO"},
+		{Field: "amount", Rule: "min", Message: "must be at least 1"},
+		{Field: "note", Rule: "max", Message: "must be at most 5 characters"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Expected %d field errors, got %+v", len(want), errs)
+	}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], errs[i])
+		}
+	}
+	if !strings.HasPrefix(err.Error(), "invalid request: market_id is required; outcome must be one of YES, NO") {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+}
+
+func TestStructPanicsOnBadTags(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected an unknown rule to panic")
+		}
+	}()
+	Struct(struct {
+		Name string `validate:"email"`
+	}{})
+}
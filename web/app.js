@@ -41,9 +41,19 @@ async function fetchUserProfile() {
     return response.json();
 }
 
-// Format balance as currency
+// Format an amount in minor units with the currency's decimals, e.g. 1050 as "10.50"
 function formatBalance(balance) {
-    return balance.toFixed(2);
+    const decimals = currency.decimals || 0;
+    return (balance / 10 ** decimals).toFixed(decimals);
+}
+
+// Currency label and decimals from /api/me (CURRENCY_NAME, CURRENCY_EMOJI, CURRENCY_DECIMALS)
+let currency = { name: 'WSC', emoji: '', decimals: 0 };
+
+// Format an amount with the currency label, e.g. "500.00 WSC"
+function formatAmount(amount) {
+    const label = `${formatBalance(amount)} ${currency.name}`;
+    return currency.emoji ? `${currency.emoji} ${label}` : label;
 }
 
 // Format date for display
@@ -57,6 +67,18 @@ function formatDate(dateString) {
     });
 }
 
+// Prefix for the deadline of markets that lock on a signal; their deadline is only the latest close
+function lockModeLabel(lockMode) {
+    switch (lockMode) {
+        case 'MANUAL':
+            return '🔓 Until the creator locks it, at the latest ';
+        case 'ORACLE':
+            return '🔓 Until the event ends, at the latest ';
+        default:
+            return '';
+    }
+}
+
 // Escape HTML to prevent XSS
 function escapeHtml(text) {
     const div = document.createElement('div');
@@ -100,6 +122,9 @@ async function displayUserProfile() {
 
         // Store current user for leaderboard comparison
         currentUser = user;
+        if (user.currency) {
+            currency = user.currency;
+        }
 
         // Update header name (if it exists)
         if (userNameEl) {
@@ -107,11 +132,11 @@ async function displayUserProfile() {
         }
 
         // Update balance in header
-        userBalanceEl.textContent = formatBalance(user.balance) + ' WSC';
+        userBalanceEl.textContent = formatAmount(user.balance);
 
         // Update profile tab
         profileNameEl.textContent = user.first_name;
-        profileBalanceEl.textContent = formatBalance(user.balance) + ' WSC';
+        profileBalanceEl.textContent = formatAmount(user.balance);
 
         // Set avatar initial
         const initial = user.first_name ? user.first_name.charAt(0).toUpperCase() : '?';
@@ -125,6 +150,7 @@ async function displayUserProfile() {
 
         // Render mortgage button if balance is low
         renderMortgageButton();
+        renderVoucherHint();
 
         // Load initial tab content
         if (currentTab === 'markets') {
@@ -146,6 +172,24 @@ async function displayUserProfile() {
     }
 }
 
+// Tell the user what their next bet's voucher covers
+function renderVoucherHint() {
+    const hintEl = document.getElementById('voucher-hint');
+    const voucher = currentUser.vouchers && currentUser.vouchers[0];
+    if (!voucher) {
+        hintEl.style.display = 'none';
+        return;
+    }
+    const covered = formatBalance(voucher.max_amount);
+    hintEl.textContent = voucher.kind === 'CREDIT'
+        ? `🎟️ Your next bet is on the house, up to ${covered}`
+        : `🎟️ Your next bet is risk-free: up to ${covered} back if it loses`;
+    if (voucher.expires_at) {
+        hintEl.textContent += ` (until ${formatDate(voucher.expires_at)})`;
+    }
+    hintEl.style.display = 'block';
+}
+
 // Set up navigation tabs
 function setupNavigation() {
     const tabs = document.querySelectorAll('.nav-tab');
@@ -176,14 +220,83 @@ function setupNavigation() {
     });
 }
 
-// Render profile tab (stats and history)
+// Render profile tab (stats, history and preferences)
 async function renderProfile() {
     await Promise.all([
         renderUserStats(),
-        renderBetHistory()
+        renderBetHistory(),
+        renderPreferences()
     ]);
 }
 
+// Preference checkboxes on the profile tab, keyed by preference name
+const preferenceCheckboxes = {
+    show_in_winners: 'show-in-winners',
+    streak_notifications: 'streak-notifications',
+    bet_receipts: 'bet-receipts',
+    lock_summaries: 'lock-summaries',
+    odds_swings: 'odds-swings',
+    muted: 'muted'
+};
+
+// Load the user's preferences and save each one when toggled
+async function renderPreferences() {
+    try {
+        const response = await fetch('/api/me/preferences', {
+            headers: { 'X-Telegram-Init-Data': initData }
+        });
+        if (!response.ok) {
+            throw new Error('Failed to load preferences');
+        }
+        const prefs = await response.json();
+        for (const [name, id] of Object.entries(preferenceCheckboxes)) {
+            document.getElementById(id).checked = prefs[name];
+        }
+        // Deadlines typed in the bot ("friday 18:00") are read in this timezone
+        const browserTimezone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+        if (!prefs.timezone && browserTimezone) {
+            await fetch('/api/me/preferences', {
+                method: 'PUT',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-Telegram-Init-Data': initData
+                },
+                body: JSON.stringify({ timezone: browserTimezone })
+            });
+        }
+    } catch (error) {
+        console.error('Failed to load preferences:', error);
+    }
+
+    for (const [name, id] of Object.entries(preferenceCheckboxes)) {
+        const checkbox = document.getElementById(id);
+        checkbox.onchange = async () => {
+            try {
+                const response = await fetch('/api/me/preferences', {
+                    method: 'PUT',
+                    headers: {
+                        'Content-Type': 'application/json',
+                        'X-Telegram-Init-Data': initData
+                    },
+                    body: JSON.stringify({ [name]: checkbox.checked })
+                });
+                if (!response.ok) {
+                    throw new Error('Failed to save preferences');
+                }
+            } catch (error) {
+                console.error('Failed to save preferences:', error);
+                checkbox.checked = !checkbox.checked;
+            }
+        };
+    }
+}
+
+// Format a signed streak: 3 -> "🔥 3W", -2 -> "2L"
+function formatStreak(streak) {
+    if (!streak) return '0';
+    return streak > 0 ? `🔥 ${streak}W` : `${-streak}L`;
+}
+
 // Fetch and display user stats
 async function renderUserStats() {
     try {
@@ -199,6 +312,8 @@ async function renderUserStats() {
         document.getElementById('stat-wins').textContent = stats.wins || 0;
         document.getElementById('stat-win-rate').textContent = (stats.win_rate || 0).toFixed(1) + '%';
         document.getElementById('stat-profit').textContent = formatBalance(stats.total_wins - stats.total_wager);
+        document.getElementById('stat-streak').textContent = formatStreak(stats.current_streak);
+        document.getElementById('stat-best-streak').textContent = stats.best_streak || 0;
         
     } catch (error) {
         console.error('Failed to render stats:', error);
@@ -206,62 +321,112 @@ async function renderUserStats() {
         document.getElementById('stat-wins').textContent = '-';
         document.getElementById('stat-win-rate').textContent = '-';
         document.getElementById('stat-profit').textContent = '-';
+        document.getElementById('stat-streak').textContent = '-';
+        document.getElementById('stat-best-streak').textContent = '-';
     }
 }
 
-// Fetch and display bet history
-async function renderBetHistory() {
+// Cursor for the next page of bet history (null when there are no more bets)
+let betHistoryCursor = null;
+
+// Render a single bet history card
+function renderBetHistoryCard(bet) {
+    const statusClass = 'status-' + bet.status.toLowerCase();
+    const amountText = formatAmount(bet.amount);
+    const payoutText = bet.payout ? formatAmount(bet.payout) : null;
+    
+    let resultText = '';
+    if (bet.status === 'WON') {
+        resultText = `<span class="history-payout" style="color: #4ade80;">+${payoutText}</span>`;
+    } else if (bet.status === 'REFUNDED') {
+        resultText = `<span class="history-payout" style="color: #aaaaaa;">Refunded</span>`;
+    } else if (bet.status === 'LOST') {
+        resultText = `<span class="history-payout" style="color: #ff6b6b;">-${amountText}</span>`;
+    } else {
+        resultText = `<span class="history-payout" style="color: #aaaacc;">${amountText}</span>`;
+    }
+    
+    return `
+        <div class="history-card">
+            <div class="history-info">
+                <div class="history-question">${escapeHtml(bet.icon)} ${escapeHtml(bet.question)}</div>
+                <div class="history-meta">
+                    Bet ${bet.outcome_chosen} • ${formatDate(bet.placed_at)}
+                </div>
+                <span class="status-badge ${statusClass}">${bet.status}</span>
+                ${bet.cancellable ? `<button class="cancel-bet-btn" data-bet="${bet.id}">Cancel bet</button>` : ''}
+            </div>
+            <div class="history-amount">
+                ${resultText}
+            </div>
+        </div>
+    `;
+}
+
+// Fetch and display bet history; loadMore appends the next page
+async function renderBetHistory(loadMore = false) {
     const historyListEl = document.getElementById('history-list');
+    const moreBtn = document.getElementById('history-more-btn');
 
     try {
-        const response = await fetch('/api/me/bets', {
+        let url = '/api/me/bets';
+        if (loadMore && betHistoryCursor) {
+            url += '?cursor=' + encodeURIComponent(betHistoryCursor);
+        }
+
+        const response = await fetch(url, {
             headers: { 'X-Telegram-Init-Data': initData }
         });
         
         if (!response.ok) throw new Error('Failed to fetch bet history');
         
         const bets = await response.json();
+        betHistoryCursor = response.headers.get('X-Next-Cursor');
+        moreBtn.style.display = betHistoryCursor ? 'block' : 'none';
         
-        if (bets.length === 0) {
+        if (!loadMore && bets.length === 0) {
             historyListEl.innerHTML = '<div class="no-markets">No bets placed yet. Start predicting!</div>';
             return;
         }
         
-        historyListEl.innerHTML = bets.map(bet => {
-            const statusClass = 'status-' + bet.status.toLowerCase();
-            const amountWSC = formatBalance(bet.amount);
-            const payoutWSC = bet.payout ? formatBalance(bet.payout) : null;
-            
-            let resultText = '';
-            if (bet.status === 'WON') {
-                resultText = `<span class="history-payout" style="color: #4ade80;">+${payoutWSC} WSC</span>`;
-            } else if (bet.status === 'REFUNDED') {
-                resultText = `<span class="history-payout" style="color: #aaaaaa;">Refunded</span>`;
-            } else if (bet.status === 'LOST') {
-                resultText = `<span class="history-payout" style="color: #ff6b6b;">-${amountWSC} WSC</span>`;
-            } else {
-                resultText = `<span class="history-payout" style="color: #aaaacc;">${amountWSC} WSC</span>`;
-            }
-            
-            return `
-                <div class="history-card">
-                    <div class="history-info">
-                        <div class="history-question">${escapeHtml(bet.question)}</div>
-                        <div class="history-meta">
-                            Bet ${bet.outcome_chosen} • ${formatDate(bet.placed_at)}
-                        </div>
-                        <span class="status-badge ${statusClass}">${bet.status}</span>
-                    </div>
-                    <div class="history-amount">
-                        ${resultText}
-                    </div>
-                </div>
-            `;
-        }).join('');
+        const html = bets.map(renderBetHistoryCard).join('');
+        if (loadMore) {
+            historyListEl.insertAdjacentHTML('beforeend', html);
+        } else {
+            historyListEl.innerHTML = html;
+        }
+        historyListEl.querySelectorAll('.cancel-bet-btn').forEach(btn => {
+            btn.onclick = handleCancelBetClick;
+        });
         
     } catch (error) {
         console.error('Failed to render bet history:', error);
         historyListEl.innerHTML = '<div class="error-message">Failed to load bet history</div>';
+        moreBtn.style.display = 'none';
+    }
+}
+
+// Cancel a bet from the history and refund the stake, less any cancellation fee
+async function handleCancelBetClick(event) {
+    const btn = event.currentTarget;
+    btn.disabled = true;
+    try {
+        const response = await fetch(`/api/bets/${btn.dataset.bet}/cancel`, {
+            method: 'POST',
+            headers: { 'X-Telegram-Init-Data': initData }
+        });
+        if (!response.ok) {
+            const error = await response.json();
+            throw new Error(error.message || error.error || 'Failed to cancel bet');
+        }
+        const result = await response.json();
+        currentUser.balance = result.new_balance;
+        document.getElementById('user-balance').textContent = formatAmount(result.new_balance);
+        document.getElementById('profile-balance').textContent = formatAmount(result.new_balance);
+        const feeText = result.fee > 0 ? ` (fee ${formatBalance(result.fee)})` : '';
+        btn.closest('.history-card').outerHTML = `<div class="success-message">Bet cancelled, ${formatBalance(result.refund)} refunded${feeText}.</div>`;
+    } catch (error) {
+        btn.insertAdjacentHTML('afterend', `<div class="error-message">${escapeHtml(error.message)}</div>`);
     }
 }
 
@@ -297,24 +462,47 @@ async function renderMarkets() {
             const noPercent = totalPool > 0 ? ((market.pool_no || 0) / totalPool * 100).toFixed(0) : 50;
             const isCreator = currentUser && market.creator_id === currentUser.id;
             const isLocked = market.status === 'LOCKED';
+            const isLastCall = market.status === 'LAST_CALL';
             const canResolve = isCreator && isLocked;
+            const isMarketMaker = market.pricing_mode === 'LMSR';
             
             return `
                 <div class="market-card" id="market-${market.id}">
-                    <div class="market-question">${escapeHtml(market.question)}</div>
+                    <div class="market-question">${escapeHtml(market.icon)} ${escapeHtml(market.question)}</div>
+                    ${market.tags && market.tags.length > 0 ? `
+                    <div class="market-tags">${market.tags.map(tag => `<span class="market-tag">#${escapeHtml(tag)}</span>`).join(' ')}</div>
+                    ` : ''}
+                    ${market.resolution_criteria ? `
+                    <div class="market-criteria">📋 ${escapeHtml(market.resolution_criteria)}</div>
+                    ` : ''}
                     <div class="market-meta">
                         <span class="market-creator">By ${escapeHtml(market.creator_name)}</span>
-                        <span class="market-deadline">${formatDate(market.expires_at)}</span>
+                        <span class="market-deadline">${lockModeLabel(market.lock_mode)}${formatDate(market.expires_at)}</span>
+                        <button class="share-market-btn" data-market="${market.id}" title="Share">📤</button>
+                        <button class="hide-market-btn" data-market="${market.id}" title="Hide from my feed">✕</button>
                     </div>
+                    ${isLastCall ? `
+                    <div class="status-badge status-last-call" title="Bets are still open; odds are frozen until the market closes">⏳ Last call</div>
+                    ` : ''}
+                    ${isMarketMaker ? `
+                    <div class="market-odds" id="market-price-${market.id}">📈 Loading prices...</div>
+                    <div class="market-position" id="market-position-${market.id}"></div>
+                    ` : market.pools_hidden ? `
+                    <div class="market-odds">🙈 Pools hidden until close</div>
+                    ` : market.sides_hidden ? `
+                    <div class="market-odds">🤐 Sealed bets · Pool ${formatBalance(market.pool_total || 0)}</div>
+                    ` : `
                     <div class="market-odds">
                         <span class="odds-yes">YES ${yesPercent}%</span>
                         <span class="odds-separator">|</span>
                         <span class="odds-no">NO ${noPercent}%</span>
                     </div>
+                    `}
                     ${canResolve ? `
                     <div class="resolve-section" id="resolve-section-${market.id}">
                         <div class="resolve-section-title">🎯 Resolve Market</div>
                         <div class="resolve-question">${escapeHtml(market.question)}</div>
+                        ${market.resolution_criteria ? `<div class="resolve-question">Resolve according to: ${escapeHtml(market.resolution_criteria)}</div>` : ''}
                         <div class="resolve-buttons">
                             <button class="resolve-btn resolve-btn-yes"
                                     data-market="${market.id}"
@@ -335,21 +523,24 @@ async function renderMarkets() {
                             <input type="number" 
                                    id="bet-amount-${market.id}" 
                                    placeholder="Amount" 
-                                   min="1" 
+                                   min="${formatBalance(1)}"
+                                   step="${formatBalance(1)}" 
                                    ${isExpired || isLocked ? 'disabled' : ''}>
                         </div>
                         <div class="bet-buttons">
                             <button class="btn btn-yes bet-btn"
                                     data-market="${market.id}"
                                     data-outcome="YES"
+                                    ${isMarketMaker ? 'data-pricing="LMSR"' : ''}
                                     ${isExpired || isLocked ? 'disabled' : ''}>
-                                YES<br><small>${formatBalance(market.pool_yes || 0)}</small>
+                                ${isMarketMaker ? 'Buy YES' : 'YES'}${isMarketMaker || market.pools_hidden || market.sides_hidden ? '' : `<br><small>${formatBalance(market.pool_yes || 0)}</small>`}
                             </button>
                             <button class="btn btn-no bet-btn"
                                     data-market="${market.id}"
                                     data-outcome="NO"
+                                    ${isMarketMaker ? 'data-pricing="LMSR"' : ''}
                                     ${isExpired || isLocked ? 'disabled' : ''}>
-                                NO<br><small>${formatBalance(market.pool_no || 0)}</small>
+                                ${isMarketMaker ? 'Buy NO' : 'NO'}${isMarketMaker || market.pools_hidden || market.sides_hidden ? '' : `<br><small>${formatBalance(market.pool_no || 0)}</small>`}
                             </button>
                         </div>
                         <div class="bet-message" id="bet-message-${market.id}"></div>
@@ -367,6 +558,23 @@ async function renderMarkets() {
         document.querySelectorAll('.resolve-btn').forEach(btn => {
             btn.addEventListener('click', handleResolveClick);
         });
+
+        // Add click handlers for hide buttons
+        document.querySelectorAll('.hide-market-btn').forEach(btn => {
+            btn.addEventListener('click', handleHideClick);
+        });
+
+        // Add click handlers for share buttons
+        document.querySelectorAll('.share-market-btn').forEach(btn => {
+            btn.addEventListener('click', handleShareClick);
+        });
+
+        // Market maker markets show live prices instead of pools
+        markets.filter(market => market.pricing_mode === 'LMSR').forEach(market => {
+            renderMarketPrice(market.id);
+        });
+
+        scrollToSharedMarket();
     } catch (error) {
         console.error('Failed to render markets:', error);
         marketsListEl.innerHTML = '<div class="error-message">Failed to load markets</div>';
@@ -382,16 +590,21 @@ async function handleBetClick(event) {
     const messageEl = document.getElementById(`bet-message-${marketId}`);
     const bettingUi = document.getElementById(`betting-ui-${marketId}`);
     
-    const amount = parseInt(amountInput.value, 10);
-    const balance = parseFloat(document.getElementById('user-balance').textContent);
+    // The server parses the amount as displayed, using the currency's decimals
+    const amountText = amountInput.value.trim();
+    const amount = Math.round(parseFloat(amountText) * 10 ** (currency.decimals || 0));
     
     // Validation
     if (!amount || amount < 1) {
-        messageEl.innerHTML = '<div class="error-message">Please enter a valid amount (minimum 1)</div>';
+        messageEl.innerHTML = `<div class="error-message">Please enter a valid amount (minimum ${formatBalance(1)})</div>`;
         return;
     }
     
-    if (amount > balance) {
+    // A promo credit voucher pays part of the next bet
+    const marketMaker = btn.dataset.pricing === 'LMSR';
+    const voucher = !marketMaker && currentUser.vouchers && currentUser.vouchers[0];
+    const credit = voucher && voucher.kind === 'CREDIT' ? Math.min(amount, voucher.max_amount) : 0;
+    if (amount - credit > currentUser.balance) {
         messageEl.innerHTML = '<div class="error-message">Insufficient balance</div>';
         return;
     }
@@ -402,14 +615,24 @@ async function handleBetClick(event) {
     messageEl.innerHTML = '';
     
     try {
-        const result = await placeBet(marketId, outcome, amount);
+        const result = marketMaker
+            ? await tradeShares(marketId, { action: 'buy', outcome: outcome, amount: amount })
+            : await placeBet(marketId, outcome, amountText);
+        currentUser.balance = result.new_balance;
         
         // Show success
-        messageEl.innerHTML = `<div class="success-message">Bet placed! New balance: ${formatBalance(result.new_balance)}</div>`;
+        const placed = marketMaker ? `Bought ${result.trade.shares} ${outcome} shares!` : 'Bet placed!';
+        messageEl.innerHTML = `<div class="success-message">${placed} New balance: ${formatBalance(result.new_balance)}</div>`;
         
         // Update balance display
-        document.getElementById('user-balance').textContent = formatBalance(result.new_balance) + ' WSC';
-        document.getElementById('profile-balance').textContent = formatBalance(result.new_balance) + ' WSC';
+        document.getElementById('user-balance').textContent = formatAmount(result.new_balance);
+        document.getElementById('profile-balance').textContent = formatAmount(result.new_balance);
+
+        // The bet redeemed the next voucher
+        if (voucher) {
+            currentUser.vouchers = currentUser.vouchers.slice(1);
+            renderVoucherHint();
+        }
         
         // Refresh markets to show updated pools
         await renderMarkets();
@@ -426,18 +649,19 @@ async function handleBetClick(event) {
     }
 }
 
-// Place a bet on a market
+// Place a bet on a market; amount is the displayed amount, e.g. "10.50"
 async function placeBet(marketId, outcome, amount) {
     const response = await fetch('/api/bets', {
         method: 'POST',
         headers: {
             'Content-Type': 'application/json',
-            'X-Telegram-Init-Data': initData
+            'X-Telegram-Init-Data': initData,
+            'X-Bet-Source': 'webapp'
         },
         body: JSON.stringify({
             market_id: marketId,
             outcome: outcome,
-            amount: amount
+            amount_display: amount
         })
     });
 
@@ -449,6 +673,87 @@ async function placeBet(marketId, outcome, amount) {
     return response.json();
 }
 
+// Show a market maker market's live prices and the user's shares, with buttons to sell them
+async function renderMarketPrice(marketId) {
+    const priceEl = document.getElementById(`market-price-${marketId}`);
+    const positionEl = document.getElementById(`market-position-${marketId}`);
+    if (!priceEl) return;
+
+    try {
+        const response = await fetch(`/api/markets/${marketId}/price`, {
+            headers: { 'X-Telegram-Init-Data': initData }
+        });
+        if (!response.ok) throw new Error('Failed to load prices');
+        const quote = await response.json();
+
+        priceEl.innerHTML = `
+            <span class="odds-yes">YES ${quote.price_yes.toFixed(2)}</span>
+            <span class="odds-separator">|</span>
+            <span class="odds-no">NO ${quote.price_no.toFixed(2)}</span>
+        `;
+
+        const position = quote.position;
+        if (!position || (position.shares_yes === 0 && position.shares_no === 0)) {
+            positionEl.innerHTML = '';
+            return;
+        }
+        positionEl.innerHTML = ['YES', 'NO'].map(outcome => {
+            const shares = outcome === 'YES' ? position.shares_yes : position.shares_no;
+            if (!shares) return '';
+            return `<button class="sell-shares-btn" data-market="${marketId}" data-outcome="${outcome}" data-shares="${shares}">Sell ${shares} ${outcome}</button>`;
+        }).join(' ');
+        positionEl.querySelectorAll('.sell-shares-btn').forEach(btn => {
+            btn.addEventListener('click', handleSellClick);
+        });
+    } catch (error) {
+        console.error('Failed to load prices:', error);
+        priceEl.textContent = '📈 Prices unavailable';
+    }
+}
+
+// Sell all of the user's shares on one side of a market maker market
+async function handleSellClick(event) {
+    const btn = event.currentTarget;
+    const marketId = parseInt(btn.dataset.market, 10);
+    const messageEl = document.getElementById(`bet-message-${marketId}`);
+
+    btn.disabled = true;
+    try {
+        const result = await tradeShares(marketId, {
+            action: 'sell',
+            outcome: btn.dataset.outcome,
+            shares: parseInt(btn.dataset.shares, 10)
+        });
+        currentUser.balance = result.new_balance;
+        messageEl.innerHTML = `<div class="success-message">Sold for ${formatBalance(-result.trade.amount)}! New balance: ${formatBalance(result.new_balance)}</div>`;
+        document.getElementById('user-balance').textContent = formatAmount(result.new_balance);
+        document.getElementById('profile-balance').textContent = formatAmount(result.new_balance);
+        await renderMarketPrice(marketId);
+    } catch (error) {
+        messageEl.innerHTML = `<div class="error-message">${escapeHtml(error.message)}</div>`;
+        btn.disabled = false;
+    }
+}
+
+// Buy or sell shares of a market maker market
+async function tradeShares(marketId, trade) {
+    const response = await fetch(`/api/markets/${marketId}/trade`, {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json',
+            'X-Telegram-Init-Data': initData
+        },
+        body: JSON.stringify(trade)
+    });
+
+    if (!response.ok) {
+        const error = await response.json();
+        throw new Error(error.message || error.error || 'Failed to trade shares');
+    }
+
+    return response.json();
+}
+
 // Resolve a market (owner only, when LOCKED)
 async function resolveMarket(marketId, outcome) {
     const response = await fetch(`/api/markets/${marketId}/resolve`, {
@@ -513,8 +818,120 @@ async function handleResolveClick(event) {
     }
 }
 
+// Hide a market from the current user's feed
+async function hideMarket(marketId) {
+    const response = await fetch(`/api/markets/${marketId}/hide`, {
+        method: 'POST',
+        headers: {
+            'X-Telegram-Init-Data': initData
+        }
+    });
+
+    if (!response.ok) {
+        const error = await response.json();
+        throw new Error(error.message || error.error || 'Failed to hide market');
+    }
+
+    return response.json();
+}
+
+// Handle hide button clicks
+async function handleHideClick(event) {
+    const btn = event.currentTarget;
+    const marketId = parseInt(btn.dataset.market, 10);
+    btn.disabled = true;
+
+    try {
+        await hideMarket(marketId);
+        const card = document.getElementById(`market-${marketId}`);
+        if (card) {
+            card.remove();
+        }
+    } catch (error) {
+        console.error('Failed to hide market:', error);
+        btn.disabled = false;
+        if (telegramWebApp) {
+            telegramWebApp.HapticFeedback.notificationOccurred('error');
+        }
+    }
+}
+
+// Fetch the ready-made share text for a market
+async function fetchMarketShare(marketId) {
+    const response = await fetch(`/api/markets/${marketId}/share`, {
+        headers: {
+            'X-Telegram-Init-Data': initData
+        }
+    });
+
+    if (!response.ok) {
+        const error = await response.json();
+        throw new Error(error.message || error.error || 'Failed to share market');
+    }
+
+    return response.json();
+}
+
+// Handle share button clicks: Telegram's share sheet inside Telegram, the system one elsewhere
+async function handleShareClick(event) {
+    const btn = event.currentTarget;
+    const marketId = parseInt(btn.dataset.market, 10);
+
+    try {
+        const share = await fetchMarketShare(marketId);
+        if (telegramWebApp && share.deep_link) {
+            const text = share.text.replace(`\nBet on it: ${share.deep_link}`, '');
+            telegramWebApp.openTelegramLink(`https://t.me/share/url?url=${encodeURIComponent(share.deep_link)}&text=${encodeURIComponent(text)}`);
+        } else if (navigator.share) {
+            await navigator.share({ text: share.text });
+        } else {
+            await navigator.clipboard.writeText(share.text);
+        }
+    } catch (error) {
+        console.error('Failed to share market:', error);
+        if (telegramWebApp) {
+            telegramWebApp.HapticFeedback.notificationOccurred('error');
+        }
+    }
+}
+
+// Scroll to the market a link opened the Web App for, once: a shared link's start parameter
+// (market_<id>) or the ?market=<id> the bot adds to the Web App URL after /start market_<id>
+let sharedMarketShown = false;
+
+function sharedMarketId() {
+    const startParam = telegramWebApp && telegramWebApp.initDataUnsafe ? telegramWebApp.initDataUnsafe.start_param : '';
+    if (startParam && startParam.startsWith('market_')) {
+        return parseInt(startParam.slice('market_'.length), 10);
+    }
+    return parseInt(new URLSearchParams(window.location.search).get('market'), 10);
+}
+
+function scrollToSharedMarket() {
+    const marketId = sharedMarketId();
+    if (sharedMarketShown || !(marketId > 0)) {
+        return;
+    }
+    sharedMarketShown = true;
+    const card = document.getElementById(`market-${marketId}`);
+    if (card) {
+        card.scrollIntoView({ behavior: 'smooth', block: 'center' });
+    }
+}
+
+// The same key is sent for every submit of one form, so a double submit or a retry after a
+// dropped connection returns the first market instead of creating a twin
+let marketIdempotencyKey = newIdempotencyKey();
+
+function newIdempotencyKey() {
+    if (window.crypto && crypto.randomUUID) {
+        return crypto.randomUUID();
+    }
+    return `${Date.now()}-${Math.random().toString(36).slice(2)}`;
+}
+
 // Create a new market
-async function createMarket(question, expiresAt) {
+async function createMarket(question, expiresAt, resolutionCriteria, blind, sealed, anonymous, lockMode, icon, pricingMode) {
     const response = await fetch('/api/markets', {
         method: 'POST',
         headers: {
@@ -523,7 +940,15 @@ async function createMarket(question, expiresAt) {
         },
         body: JSON.stringify({
             question: question,
-            expires_at: expiresAt
+            expires_at: expiresAt,
+            resolution_criteria: resolutionCriteria,
+            blind: blind,
+            sealed: sealed,
+            anonymous: anonymous,
+            lock_mode: lockMode,
+            icon: icon,
+            pricing_mode: pricingMode,
+            idempotency_key: marketIdempotencyKey
         })
     });
     
@@ -545,9 +970,11 @@ function setupMarketForm() {
     const cancelBtn = document.getElementById('cancel-market-btn');
     const messageEl = document.getElementById('form-message');
     
-    // Set minimum deadline to 1 hour from now
+    // Set deadline bounds to the server defaults: 1 hour to 1 year from now
     const minDate = new Date(Date.now() + 60 * 60 * 1000);
+    const maxDate = new Date(Date.now() + 365 * 24 * 60 * 60 * 1000);
     deadlineInput.min = minDate.toISOString().slice(0, 16);
+    deadlineInput.max = maxDate.toISOString().slice(0, 16);
     
     // Show form
     createBtn.addEventListener('click', () => {
@@ -567,6 +994,13 @@ function setupMarketForm() {
     submitBtn.addEventListener('click', async () => {
         const question = questionInput.value.trim();
         const deadline = deadlineInput.value;
+        const criteria = document.getElementById('market-criteria').value.trim();
+        const blind = document.getElementById('market-blind').checked;
+        const sealed = document.getElementById('market-sealed').checked;
+        const anonymous = document.getElementById('market-anonymous').checked;
+        const lockMode = document.getElementById('market-lock-mode').value;
+        const icon = document.getElementById('market-icon').value.trim();
+        const pricingMode = document.getElementById('market-maker').checked ? 'LMSR' : 'PARIMUTUEL';
         
         // Validation
         if (question.length < 10 || question.length > 140) {
@@ -574,21 +1008,22 @@ function setupMarketForm() {
             return;
         }
         
-        if (!deadline) {
+        if (!deadline && lockMode === 'DEADLINE') {
             messageEl.innerHTML = '<div class="error-message">Please select a deadline</div>';
             return;
         }
         
-        // Convert to RFC3339 format
-        const expiresAt = new Date(deadline).toISOString();
+        // Convert to RFC3339 format; markets locked on a signal may leave it empty
+        const expiresAt = deadline ? new Date(deadline).toISOString() : '';
         
         try {
             submitBtn.disabled = true;
             submitBtn.textContent = 'Creating...';
             
-            await createMarket(question, expiresAt);
+            await createMarket(question, expiresAt, criteria, blind, sealed, anonymous, lockMode, icon, pricingMode);
             
             messageEl.innerHTML = '<div class="success-message">Market created successfully!</div>';
+            marketIdempotencyKey = newIdempotencyKey();
             
             // Clear form and refresh markets
             setTimeout(() => {
@@ -608,7 +1043,14 @@ function setupMarketForm() {
 
 function clearForm() {
     document.getElementById('market-question').value = '';
+    document.getElementById('market-icon').value = '';
     document.getElementById('market-deadline').value = '';
+    document.getElementById('market-criteria').value = '';
+    document.getElementById('market-blind').checked = false;
+    document.getElementById('market-sealed').checked = false;
+    document.getElementById('market-anonymous').checked = false;
+    document.getElementById('market-maker').checked = false;
+    document.getElementById('market-lock-mode').value = 'DEADLINE';
     document.getElementById('form-message').innerHTML = '';
 }
 
@@ -620,10 +1062,16 @@ function renderMortgageButton() {
     
     if (!mortgageBtn || !currentUser) return;
     
-    // Show button if balance < 1
-    if (currentUser.balance < 1) {
+    // Show button when the server says a bailout is available
+    if (currentUser.eligible_for_bailout) {
         mortgageBtn.style.display = 'block';
         mortgageInfo.style.display = 'block';
+        mortgageInfo.textContent = `Get ${formatAmount(500)} free (once per 24h)`;
+    } else if (currentUser.balance < 1 && currentUser.bailout_available_at) {
+        // Broke but on cooldown: tell the user when to come back
+        mortgageBtn.style.display = 'none';
+        mortgageInfo.style.display = 'block';
+        mortgageInfo.textContent = 'Next mortgage available ' + formatDate(currentUser.bailout_available_at);
     } else {
         mortgageBtn.style.display = 'none';
         mortgageInfo.style.display = 'none';
@@ -646,7 +1094,7 @@ async function handleMortgageClick() {
         const result = await takeMortgage();
         
         // Show success message
-        mortgageMessage.innerHTML = `<div class="success-message">${escapeHtml(result.message)}! New balance: ${formatBalance(result.new_balance)} WSC</div>`;
+        mortgageMessage.innerHTML = `<div class="success-message">${escapeHtml(result.message)}! New balance: ${formatAmount(result.new_balance)}</div>`;
         
         // Play success sound (optional)
         if (telegramWebApp) {
@@ -658,8 +1106,8 @@ async function handleMortgageClick() {
         currentUser = user;
         
         // Update balance displays
-        document.getElementById('user-balance').textContent = formatBalance(user.balance) + ' WSC';
-        document.getElementById('profile-balance').textContent = formatBalance(user.balance) + ' WSC';
+        document.getElementById('user-balance').textContent = formatAmount(user.balance);
+        document.getElementById('profile-balance').textContent = formatAmount(user.balance);
         
         // Hide mortgage button after successful bailout
         renderMortgageButton();
@@ -713,6 +1161,74 @@ async function fetchLeaderboard() {
     return response.json();
 }
 
+// Movement since yesterday's balance snapshot, e.g. "▲3 +120"; empty for users who joined since
+function renderLeaderboardMovement(entry) {
+    if (!entry.previous_rank) return '';
+    let arrow = '';
+    if (entry.rank_change > 0) {
+        arrow = `<span class="rank-up">▲${entry.rank_change}</span>`;
+    } else if (entry.rank_change < 0) {
+        arrow = `<span class="rank-down">▼${-entry.rank_change}</span>`;
+    }
+    let change = '';
+    if (entry.balance_change !== 0) {
+        const sign = entry.balance_change > 0 ? '+' : '-';
+        const cls = entry.balance_change > 0 ? 'rank-up' : 'rank-down';
+        change = `<span class="${cls}">${sign}${formatBalance(Math.abs(entry.balance_change))}</span>`;
+    }
+    if (!arrow && !change) return '';
+    return `<div class="leaderboard-movement" title="Since yesterday">${arrow} ${change}</div>`;
+}
+
+// Fetch the current user's leaderboard position with their neighbours
+async function fetchLeaderboardPosition() {
+    const response = await fetch('/api/leaderboard/me', {
+        headers: { 'X-Telegram-Init-Data': initData }
+    });
+    if (!response.ok) throw new Error('Failed to fetch leaderboard position');
+    return response.json();
+}
+
+// Render one leaderboard row
+function renderLeaderboardCard(entry, isMe) {
+    // Get medal or rank
+    let rankDisplay = '';
+    let rankClass = '';
+    let badge = '';
+    
+    if (entry.rank === 1) {
+        rankDisplay = '🥇';
+        rankClass = 'gold';
+        badge = '<span class="leaderboard-badge">🥇</span>';
+    } else if (entry.rank === 2) {
+        rankDisplay = '2';
+        rankClass = 'silver';
+        badge = '<span class="leaderboard-badge">🥈</span>';
+    } else if (entry.rank === 3) {
+        rankDisplay = '3';
+        rankClass = 'bronze';
+        badge = '<span class="leaderboard-badge">🥉</span>';
+    } else {
+        rankDisplay = entry.rank;
+    }
+    
+    const name = escapeHtml(entry.name);
+    const username = entry.username ? '@' + escapeHtml(entry.username) : '';
+    const movement = renderLeaderboardMovement(entry);
+    
+    return `
+        <div class="leaderboard-card ${isMe ? 'is-me' : ''}">
+            <div class="leaderboard-rank ${rankClass}">${rankDisplay}</div>
+            ${badge}
+            <div class="leaderboard-info">
+                <div class="leaderboard-name">${name}${isMe ? ' (You)' : ''}</div>
+                <div class="leaderboard-username">${username}</div>
+            </div>
+            <div class="leaderboard-balance">${entry.balance_display} ${currency.name}${movement}</div>
+        </div>
+    `;
+}
+
 // Render leaderboard to the DOM
 async function renderLeaderboard() {
     const leaderboardListEl = document.getElementById('leaderboard-list');
@@ -727,54 +1243,112 @@ async function renderLeaderboard() {
             return;
         }
         
-        leaderboardListEl.innerHTML = leaderboard.map(entry => {
+        let foundMe = false;
+        let html = leaderboard.map(entry => {
             const isMe = currentUser && entry.name === currentUser.first_name;
-            
-            // Get medal or rank
-            let rankDisplay = '';
-            let rankClass = '';
-            let badge = '';
-            
-            if (entry.rank === 1) {
-                rankDisplay = '🥇';
-                rankClass = 'gold';
-                badge = '<span class="leaderboard-badge">🥇</span>';
-            } else if (entry.rank === 2) {
-                rankDisplay = '2';
-                rankClass = 'silver';
-                badge = '<span class="leaderboard-badge">🥈</span>';
-            } else if (entry.rank === 3) {
-                rankDisplay = '3';
-                rankClass = 'bronze';
-                badge = '<span class="leaderboard-badge">🥉</span>';
-            } else {
-                rankDisplay = entry.rank;
-            }
-            
-            const name = escapeHtml(entry.name);
-            const username = entry.username ? '@' + escapeHtml(entry.username) : '';
-            
-            return `
-                <div class="leaderboard-card ${isMe ? 'is-me' : ''}">
-                    <div class="leaderboard-rank ${rankClass}">${rankDisplay}</div>
-                    ${badge}
-                    <div class="leaderboard-info">
-                        <div class="leaderboard-name">${name}${isMe ? ' (You)' : ''}</div>
-                        <div class="leaderboard-username">${username}</div>
-                    </div>
-                    <div class="leaderboard-balance">${entry.balance_display} WSC</div>
-                </div>
-            `;
+            if (isMe) foundMe = true;
+            return renderLeaderboardCard(entry, isMe);
         }).join('');
         
+        // Outside the top: show the user's own rank with the users around them
+        if (!foundMe && currentUser) {
+            try {
+                const position = await fetchLeaderboardPosition();
+                const below = position.entries.filter(entry => entry.rank > leaderboard.length);
+                if (below.length > 0) {
+                    if (below[0].rank > leaderboard.length + 1) {
+                        html += '<div class="leaderboard-gap">⋯</div>';
+                    }
+                    html += below.map(entry => renderLeaderboardCard(entry, entry.is_me)).join('');
+                }
+            } catch (error) {
+                console.error('Failed to load leaderboard position:', error);
+            }
+        }
+        
+        leaderboardListEl.innerHTML = html;
+        
     } catch (error) {
         console.error('Failed to render leaderboard:', error);
         leaderboardListEl.innerHTML = '<div class="error-message">Failed to load leaderboard</div>';
     }
 }
 
+// Live updates from /api/stream (Server-Sent Events, read through fetch so the auth header is sent)
+let marketsRefreshTimer = null;
+
+// Re-render the market list once after a burst of status changes
+function scheduleMarketsRefresh() {
+    clearTimeout(marketsRefreshTimer);
+    marketsRefreshTimer = setTimeout(renderMarkets, 500);
+}
+
+// Update a market card's odds and pool amounts in place, keeping any amount being typed
+function applyPoolUpdate(event) {
+    const card = document.getElementById(`market-${event.market_id}`);
+    if (!card || document.getElementById(`market-price-${event.market_id}`)) return;
+    const oddsEl = card.querySelector('.market-odds');
+    if (!oddsEl || event.pools_hidden) return;
+    if (event.sides_hidden) {
+        oddsEl.textContent = `🤐 Sealed bets · Pool ${formatBalance(event.pool_total || 0)}`;
+        return;
+    }
+    const total = event.pool_yes + event.pool_no;
+    const yesPercent = total > 0 ? (event.pool_yes / total * 100).toFixed(0) : 50;
+    const noPercent = total > 0 ? (event.pool_no / total * 100).toFixed(0) : 50;
+    oddsEl.innerHTML = `
+        <span class="odds-yes">YES ${yesPercent}%</span>
+        <span class="odds-separator">|</span>
+        <span class="odds-no">NO ${noPercent}%</span>
+    `;
+    const yesPool = card.querySelector('.btn-yes small');
+    const noPool = card.querySelector('.btn-no small');
+    if (yesPool) yesPool.textContent = formatBalance(event.pool_yes);
+    if (noPool) noPool.textContent = formatBalance(event.pool_no);
+}
+
+// Apply one live update to the page
+function handleStreamEvent(event) {
+    if (event.type === 'bet_placed' || event.type === 'bet_cancelled') {
+        applyPoolUpdate(event);
+    } else {
+        scheduleMarketsRefresh();
+    }
+}
+
+// Listen to /api/stream, reconnecting a few seconds after the connection drops
+async function connectStream() {
+    if (!initData) return;
+    try {
+        const response = await fetch('/api/stream', {
+            headers: { 'X-Telegram-Init-Data': initData }
+        });
+        if (!response.ok || !response.body) throw new Error('Failed to open stream');
+        const reader = response.body.getReader();
+        const decoder = new TextDecoder();
+        let buffer = '';
+        for (;;) {
+            const { value, done } = await reader.read();
+            if (done) break;
+            buffer += decoder.decode(value, { stream: true });
+            let end;
+            while ((end = buffer.indexOf('\n\n')) >= 0) {
+                const message = buffer.slice(0, end);
+                buffer = buffer.slice(end + 2);
+                const data = message.split('\n').find(line => line.startsWith('data: '));
+                if (data) handleStreamEvent(JSON.parse(data.slice(6)));
+            }
+        }
+    } catch (error) {
+        console.error('Live updates disconnected:', error);
+    }
+    setTimeout(connectStream, 5000);
+}
+
 // Run on page load
 document.addEventListener('DOMContentLoaded', () => {
     displayUserProfile();
     setupMarketForm();
+    connectStream();
+    document.getElementById('history-more-btn').addEventListener('click', () => renderBetHistory(true));
 });